@@ -0,0 +1,8 @@
+// Command server runs the VMware Avi LLM Agent
+package main
+
+import "aviagent/internal/app"
+
+func main() {
+	app.Main()
+}
@@ -0,0 +1,138 @@
+package alerts
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Severities, matching the notification event severities
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Alert is an alert raised by the Avi controller and received through its alert action webhook
+// (a ControlScript or external webhook posting the alert JSON)
+type Alert struct {
+	ID            string                 `json:"id"`
+	Name          string                 `json:"name"`            // alert config that raised it
+	Level         string                 `json:"level,omitempty"` // ALERT_LOW, ALERT_MEDIUM or ALERT_HIGH
+	Severity      string                 `json:"severity"`
+	Object        string                 `json:"object,omitempty"`      // name of the object the alert is about
+	ObjectUUID    string                 `json:"object_uuid,omitempty"` // e.g. virtualservice-...
+	EventID       string                 `json:"event_id,omitempty"`    // first event of the alert, e.g. VS_DOWN
+	Description   string                 `json:"description,omitempty"`
+	Reported      *time.Time             `json:"reported,omitempty"` // when the controller reported the event
+	Received      time.Time              `json:"received"`
+	Summary       string                 `json:"summary,omitempty"`
+	SummarySource string                 `json:"summary_source,omitempty"` // llm, or rules when the model couldn't be used
+	Raw           map[string]interface{} `json:"raw"`
+}
+
+// Parse reads an alert webhook body. Avi posts the alert object itself; payloads wrapping it in
+// an "alert" key are accepted too. Missing fields are left empty rather than rejected, as the
+// fields sent depend on the controller version and the alert action.
+func Parse(body []byte) (*Alert, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("alert must be a JSON object: %w", err)
+	}
+	if wrapped, ok := raw["alert"].(map[string]interface{}); ok {
+		raw = wrapped
+	}
+
+	alert := &Alert{
+		Name:        stringField(raw, "name", "alert_config_name"),
+		Level:       strings.ToUpper(stringField(raw, "level")),
+		Object:      stringField(raw, "obj_name"),
+		ObjectUUID:  stringField(raw, "obj_uuid", "obj_key"),
+		Description: stringField(raw, "summary", "description", "reason"),
+		Reported:    timeField(raw, "timestamp"),
+		Raw:         raw,
+	}
+	if events, ok := raw["events"].([]interface{}); ok && len(events) > 0 {
+		if event, ok := events[0].(map[string]interface{}); ok {
+			alert.EventID = stringField(event, "event_id")
+			if alert.Object == "" {
+				alert.Object = stringField(event, "obj_name")
+			}
+			if alert.ObjectUUID == "" {
+				alert.ObjectUUID = stringField(event, "obj_uuid")
+			}
+			if description := stringField(event, "event_description"); description != "" {
+				alert.Description = description
+			}
+			if alert.Reported == nil {
+				alert.Reported = timeField(event, "report_timestamp")
+			}
+		}
+	}
+	if alert.Name == "" && alert.EventID == "" {
+		return nil, fmt.Errorf("alert has neither a name nor events")
+	}
+	if alert.Name == "" {
+		alert.Name = alert.EventID
+	}
+	alert.Severity = severity(alert.Level)
+	return alert, nil
+}
+
+// Title is a one-line description of the alert
+func (a *Alert) Title() string {
+	title := a.Name
+	if a.EventID != "" && a.EventID != a.Name {
+		title += " (" + a.EventID + ")"
+	}
+	if a.Object != "" {
+		title += " on " + a.Object
+	}
+	return title
+}
+
+// severity maps an Avi alert level to a notification severity
+func severity(level string) string {
+	switch level {
+	case "ALERT_HIGH":
+		return SeverityCritical
+	case "ALERT_MEDIUM":
+		return SeverityWarning
+	}
+	return SeverityInfo
+}
+
+// stringField returns the first of the keys holding a string or number
+func stringField(m map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		switch v := m[key].(type) {
+		case string:
+			if v = strings.TrimSpace(v); v != "" {
+				return v
+			}
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+	}
+	return ""
+}
+
+// timeField reads a timestamp given in Unix seconds (as a number or string) or RFC 3339, nil
+// when it is missing or invalid
+func timeField(m map[string]interface{}, key string) *time.Time {
+	value := stringField(m, key)
+	if value == "" {
+		return nil
+	}
+	var t time.Time
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 && seconds < 1e11 {
+		t = time.Unix(int64(seconds), 0).UTC()
+	} else if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		t = parsed.UTC()
+	} else {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,131 @@
+package alerts
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"aviagent/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// defaultMaxAlerts is the number of alerts kept when max_alerts isn't set
+const defaultMaxAlerts = 500
+
+// Store keeps the most recent alerts received from the controller. Alerts are kept in memory
+// and saved to a JSON file when one is configured.
+type Store struct {
+	mu     sync.Mutex
+	alerts []*Alert // oldest first
+	max    int
+	file   string
+	logger *zap.Logger
+}
+
+// NewStore creates the alert store, loading the state file when one is configured
+func NewStore(cfg config.AlertsConfig, logger *zap.Logger) (*Store, error) {
+	s := &Store{max: cfg.MaxAlerts, file: cfg.StateFile, logger: logger}
+	if s.max <= 0 {
+		s.max = defaultMaxAlerts
+	}
+	if s.file == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(s.file)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alert state %s: %w", s.file, err)
+	}
+	if err := json.Unmarshal(data, &s.alerts); err != nil {
+		return nil, fmt.Errorf("failed to parse alert state %s: %w", s.file, err)
+	}
+	s.trim()
+	return s, nil
+}
+
+// Add stores a received alert, assigning its ID, and drops the oldest alerts beyond the limit
+func (s *Store) Add(alert *Alert, now time.Time) *Alert {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	alert.ID = fmt.Sprintf("alert_%d", now.UnixNano())
+	for _, existing := range s.alerts {
+		if existing.ID == alert.ID {
+			alert.ID += "0"
+		}
+	}
+	alert.Received = now
+	s.alerts = append(s.alerts, alert)
+	s.trim()
+	s.save()
+	copied := *alert
+	return &copied
+}
+
+// SetSummary records the explanation written for an alert
+func (s *Store) SetSummary(id, summary, source string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, alert := range s.alerts {
+		if alert.ID == id {
+			alert.Summary = summary
+			alert.SummarySource = source
+			s.save()
+			return nil
+		}
+	}
+	return fmt.Errorf("alert %s not found", id)
+}
+
+// Get returns a stored alert
+func (s *Store) Get(id string) (*Alert, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, alert := range s.alerts {
+		if alert.ID == id {
+			copied := *alert
+			return &copied, true
+		}
+	}
+	return nil, false
+}
+
+// List returns up to limit alerts, most recent first (all of them when limit is 0)
+func (s *Store) List(limit int) []Alert {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if limit <= 0 || limit > len(s.alerts) {
+		limit = len(s.alerts)
+	}
+	alerts := make([]Alert, 0, limit)
+	for i := len(s.alerts) - 1; i >= 0 && len(alerts) < limit; i-- {
+		alerts = append(alerts, *s.alerts[i])
+	}
+	return alerts
+}
+
+// trim drops the oldest alerts beyond the limit
+func (s *Store) trim() {
+	if extra := len(s.alerts) - s.max; extra > 0 {
+		s.alerts = append([]*Alert(nil), s.alerts[extra:]...)
+	}
+}
+
+// save writes the state file; failures are logged because the alerts are still kept in memory
+func (s *Store) save() {
+	if s.file == "" {
+		return
+	}
+	data, err := json.MarshalIndent(s.alerts, "", "  ")
+	if err == nil {
+		err = os.WriteFile(s.file, data, 0600)
+	}
+	if err != nil {
+		s.logger.Error("Failed to save alerts", zap.String("file", s.file), zap.Error(err))
+	}
+}
@@ -0,0 +1,44 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"aviagent/pkg/llm"
+)
+
+// alertPrompt instructs the model explaining an alert
+const alertPrompt = `You are a site reliability engineer on call for a VMware Avi load balancer.
+You are given the JSON alert the Avi controller raised. Explain it in 2 to 4 sentences of plain text for the operator:
+what happened, to which object, how serious it is and what to check first.
+Only state what the alert supports; don't invent metrics or causes.`
+
+// Summarize has the model explain an alert. When the model fails the summary is built from the
+// alert fields instead, so every alert gets an explanation. It returns the summary and its
+// source: llm or rules.
+func Summarize(ctx context.Context, completer llm.Completer, model string, alert *Alert) (string, string) {
+	if completer != nil {
+		data, err := json.Marshal(alert.Raw)
+		if err == nil {
+			reply, err := completer.Complete(ctx, model, alertPrompt, string(data))
+			if err == nil && strings.TrimSpace(reply) != "" {
+				return strings.TrimSpace(reply), "llm"
+			}
+		}
+	}
+	return ruleSummary(alert), "rules"
+}
+
+// ruleSummary describes the alert fields as sentences
+func ruleSummary(alert *Alert) string {
+	summary := fmt.Sprintf("The controller raised a %s alert %s.", alert.Severity, alert.Title())
+	if alert.Description != "" {
+		summary += " " + strings.TrimSuffix(alert.Description, ".") + "."
+	}
+	if alert.Reported != nil {
+		summary += fmt.Sprintf(" It was reported at %s UTC.", alert.Reported.UTC().Format("2006-01-02 15:04"))
+	}
+	return summary
+}
@@ -0,0 +1,106 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"aviagent/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// controlScriptAlert is the alert JSON an Avi alert action posts
+const controlScriptAlert = `{
+	"name": "Syst-VS-Down",
+	"level": "ALERT_HIGH",
+	"reason": "threshold_exceeded",
+	"obj_name": "shop-vs",
+	"threshold": 1,
+	"events": [{
+		"event_id": "VS_DOWN",
+		"obj_uuid": "virtualservice-1",
+		"obj_name": "shop-vs",
+		"report_timestamp": 1792137600,
+		"event_description": "Virtual Service shop-vs is down."
+	}]
+}`
+
+type fakeCompleter struct {
+	reply string
+	err   error
+}
+
+func (f fakeCompleter) Complete(ctx context.Context, model, system, prompt string) (string, error) {
+	return f.reply, f.err
+}
+
+func TestParse(t *testing.T) {
+	alert, err := Parse([]byte(controlScriptAlert))
+	require.NoError(t, err)
+	assert.Equal(t, "Syst-VS-Down", alert.Name)
+	assert.Equal(t, SeverityCritical, alert.Severity)
+	assert.Equal(t, "VS_DOWN", alert.EventID)
+	assert.Equal(t, "virtualservice-1", alert.ObjectUUID)
+	assert.Equal(t, "Virtual Service shop-vs is down.", alert.Description)
+	require.NotNil(t, alert.Reported)
+	assert.Equal(t, time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC), *alert.Reported)
+	assert.Equal(t, "Syst-VS-Down (VS_DOWN) on shop-vs", alert.Title())
+
+	wrapped, err := Parse([]byte(`{"alert": {"events": [{"event_id": "POOL_DOWN"}], "level": "alert_medium"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, "POOL_DOWN", wrapped.Name)
+	assert.Equal(t, SeverityWarning, wrapped.Severity)
+	assert.Nil(t, wrapped.Reported)
+
+	for _, body := range []string{`[]`, `{}`, `not json`} {
+		_, err := Parse([]byte(body))
+		assert.Error(t, err, body)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	alert, err := Parse([]byte(controlScriptAlert))
+	require.NoError(t, err)
+
+	summary, source := Summarize(context.Background(), fakeCompleter{reply: " shop-vs is down. \n"}, "model", alert)
+	assert.Equal(t, "shop-vs is down.", summary)
+	assert.Equal(t, "llm", source)
+
+	summary, source = Summarize(context.Background(), fakeCompleter{err: errors.New("timeout")}, "model", alert)
+	assert.Equal(t, "rules", source)
+	assert.Equal(t, "The controller raised a critical alert Syst-VS-Down (VS_DOWN) on shop-vs. Virtual Service shop-vs is down. It was reported at 2026-10-16 08:00 UTC.", summary)
+}
+
+func TestStore(t *testing.T) {
+	cfg := config.AlertsConfig{MaxAlerts: 2, StateFile: filepath.Join(t.TempDir(), "alerts.json")}
+	store, err := NewStore(cfg, zap.NewNop())
+	require.NoError(t, err)
+
+	now := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	var ids []string
+	for i, name := range []string{"first", "second", "third"} {
+		ids = append(ids, store.Add(&Alert{Name: name}, now.Add(time.Duration(i)*time.Second)).ID)
+	}
+	require.NoError(t, store.SetSummary(ids[2], "third summary", "rules"))
+	assert.Error(t, store.SetSummary(ids[0], "dropped", "rules"))
+
+	listed := store.List(0)
+	require.Len(t, listed, 2)
+	assert.Equal(t, "third", listed[0].Name)
+	assert.Equal(t, "second", listed[1].Name)
+	assert.Len(t, store.List(1), 1)
+
+	// Alerts survive a restart
+	reloaded, err := NewStore(cfg, zap.NewNop())
+	require.NoError(t, err)
+	alert, ok := reloaded.Get(ids[2])
+	require.True(t, ok)
+	assert.Equal(t, "third summary", alert.Summary)
+	_, ok = reloaded.Get(ids[0])
+	assert.False(t, ok)
+}
@@ -0,0 +1,171 @@
+// Package ansible writes Avi objects as a playbook of the avi_* modules of the vmware.alb Ansible
+// collection, so teams standardized on Ansible can author changes with the agent and run them
+// through their own automation instead of writing to the controller directly.
+package ansible
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"aviagent/pkg/avi"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Collection is the Ansible collection of the Avi modules
+const Collection = "vmware.alb"
+
+// header introduces a playbook: the collection it needs and the variables it reads
+const header = `---
+# Avi configuration for the ` + Collection + ` collection: ansible-galaxy collection install ` + Collection + `
+# Run it with avi_controller, avi_username, avi_password, avi_api_version and optionally
+# avi_tenant set, e.g. ansible-playbook -e @credentials.yml playbook.yml
+`
+
+// omittedFields are assigned by the controller, or set by the credentials: the tenant
+var omittedFields = map[string]bool{"uuid": true, "url": true, "_last_modified": true, "tenant_ref": true}
+
+// credentials are the avi_credentials every task passes to its module
+var credentials = map[string]string{
+	"controller":  "{{ avi_controller }}",
+	"username":    "{{ avi_username }}",
+	"password":    "{{ avi_password }}",
+	"tenant":      "{{ avi_tenant | default('admin') }}",
+	"api_version": "{{ avi_api_version }}",
+}
+
+// Playbook is Avi objects as an Ansible playbook
+type Playbook struct {
+	YAML  string   `json:"playbook"`
+	Tasks []string `json:"tasks"` // names of the tasks, one per object in the order they run
+}
+
+// Generate writes objects as a playbook of one play on localhost with a task per object, in the
+// order given, dependency order for the objects of avi.ParseConfiguration and
+// avi.CollectObjects. Each task ensures its object is present with the avi_ module of its type.
+// References are written by name, /api/pool?name=web, so the playbook runs against any
+// controller; references by UUID alone are kept as they are. Fields the controller assigns and
+// the tenant, which the credentials set, are left out, and values that would read as Jinja
+// templates are marked !unsafe.
+func Generate(objects []avi.ConfigObject) (*Playbook, error) {
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("no objects to generate")
+	}
+	playbook := &Playbook{}
+	tasks := &yaml.Node{Kind: yaml.SequenceNode}
+	for _, obj := range objects {
+		if obj.Type == "" || obj.Name == "" || obj.Data == nil {
+			return nil, fmt.Errorf("every object needs a type, a name and its fields")
+		}
+		objType := strings.ToLower(obj.Type)
+		name := fmt.Sprintf("%s %s", objType, obj.Name)
+
+		args := mapping("avi_credentials", "{{ avi_credentials }}", "state", "present", "name", obj.Name)
+		keys := make([]string, 0, len(obj.Data))
+		for key := range obj.Data {
+			if key != "name" && !omittedFields[key] && !strings.HasPrefix(key, "_") {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value, err := encode(moduleValue(key, obj.Data[key]))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			markUnsafe(value)
+			args.Content = append(args.Content, scalar(key), value)
+		}
+
+		task := mapping("name", name)
+		task.Content = append(task.Content, scalar(Collection+".avi_"+objType), args)
+		tasks.Content = append(tasks.Content, task)
+		playbook.Tasks = append(playbook.Tasks, name)
+	}
+
+	creds := &yaml.Node{Kind: yaml.MappingNode}
+	for _, key := range []string{"controller", "username", "password", "tenant", "api_version"} {
+		creds.Content = append(creds.Content, scalar(key), scalar(credentials[key]))
+	}
+	vars := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{scalar("avi_credentials"), creds}}
+	play := mapping("name", "Avi configuration", "hosts", "localhost", "connection", "local")
+	play.Content = append(play.Content,
+		scalar("gather_facts"), &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "false"},
+		scalar("vars"), vars,
+		scalar("tasks"), tasks)
+
+	var out bytes.Buffer
+	out.WriteString(header)
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{play}}); err != nil {
+		return nil, fmt.Errorf("failed to write the playbook: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write the playbook: %w", err)
+	}
+	playbook.YAML = out.String()
+	return playbook, nil
+}
+
+// moduleValue returns a field value as the modules take it, with references written by name
+func moduleValue(key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for field, item := range v {
+			object[field] = moduleValue(field, item)
+		}
+		return object
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = moduleValue(key, item)
+		}
+		return list
+	case string:
+		if avi.IsRefField(key) {
+			if ref, ok := avi.ParseRef(v); ok {
+				return fmt.Sprintf("/api/%s?name=%s", ref.Type, ref.Name)
+			}
+		}
+	}
+	return value
+}
+
+// markUnsafe tags the strings of a value holding Jinja delimiters !unsafe, so Ansible keeps them
+// as they are instead of templating them
+func markUnsafe(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!str" && (strings.Contains(node.Value, "{{") || strings.Contains(node.Value, "{%")) {
+		node.Tag = "!unsafe"
+		node.Style = yaml.DoubleQuotedStyle
+	}
+	for _, child := range node.Content {
+		markUnsafe(child)
+	}
+}
+
+// encode returns a value as a YAML node
+func encode(value interface{}) (*yaml.Node, error) {
+	node := &yaml.Node{}
+	if err := node.Encode(value); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// scalar returns a string as a YAML node
+func scalar(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+// mapping returns a mapping of string keys and values, in order
+func mapping(pairs ...string) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		node.Content = append(node.Content, scalar(pairs[i]), scalar(pairs[i+1]))
+	}
+	return node
+}
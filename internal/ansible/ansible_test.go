@@ -0,0 +1,72 @@
+package ansible
+
+import (
+	"testing"
+
+	"aviagent/pkg/avi"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	objects, err := avi.ParseConfiguration(map[string]interface{}{
+		"virtualservice": []interface{}{map[string]interface{}{
+			"name":        "web",
+			"uuid":        "vs-1",
+			"tenant_ref":  "https://controller/api/tenant/admin#admin",
+			"pool_ref":    "https://controller/api/pool/pool-1#web-pool",
+			"vsvip_ref":   "https://controller/api/vsvip/vsvip-1",
+			"services":    []interface{}{map[string]interface{}{"port": 443.0, "enable_ssl": true}},
+			"description": "Shop {{ env }}",
+		}},
+		"pool": []interface{}{map[string]interface{}{
+			"name":                "web-pool",
+			"lb_algorithm":        "LB_ALGORITHM_ROUND_ROBIN",
+			"health_monitor_refs": []interface{}{"https://controller/api/healthmonitor/hm-1#System-HTTP"},
+			"_last_modified":      "1700000000",
+		}},
+	})
+	require.NoError(t, err)
+
+	playbook, err := Generate(objects)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pool web-pool", "virtualservice web"}, playbook.Tasks)
+	assert.Equal(t, header+`- name: Avi configuration
+  hosts: localhost
+  connection: local
+  gather_facts: false
+  vars:
+    avi_credentials:
+      controller: '{{ avi_controller }}'
+      username: '{{ avi_username }}'
+      password: '{{ avi_password }}'
+      tenant: '{{ avi_tenant | default(''admin'') }}'
+      api_version: '{{ avi_api_version }}'
+  tasks:
+    - name: pool web-pool
+      vmware.alb.avi_pool:
+        avi_credentials: '{{ avi_credentials }}'
+        state: present
+        name: web-pool
+        health_monitor_refs:
+          - /api/healthmonitor?name=System-HTTP
+        lb_algorithm: LB_ALGORITHM_ROUND_ROBIN
+    - name: virtualservice web
+      vmware.alb.avi_virtualservice:
+        avi_credentials: '{{ avi_credentials }}'
+        state: present
+        name: web
+        description: !unsafe "Shop {{ env }}"
+        pool_ref: /api/pool?name=web-pool
+        services:
+          - enable_ssl: true
+            port: 443
+        vsvip_ref: https://controller/api/vsvip/vsvip-1
+`, playbook.YAML)
+
+	_, err = Generate(nil)
+	assert.EqualError(t, err, "no objects to generate")
+	_, err = Generate([]avi.ConfigObject{{Type: "pool", Data: map[string]interface{}{}}})
+	assert.EqualError(t, err, "every object needs a type, a name and its fields")
+}
@@ -0,0 +1,343 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	agentchat "aviagent/pkg/agent/chat"
+	"aviagent/pkg/audit"
+	"aviagent/pkg/llm"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats of `aviagent ask`
+var askFormats = map[string]bool{"text": true, "json": true, "yaml": true, "table": true}
+
+// maxTableColumns limits the columns of a table of objects
+const maxTableColumns = 8
+
+// askOutput is the result of `aviagent ask` in the json and yaml formats
+type askOutput struct {
+	Success   bool               `json:"success"`
+	Answer    string             `json:"answer,omitempty"`
+	Model     string             `json:"model,omitempty"`
+	Fallbacks []llm.ModelFailure `json:"fallbacks,omitempty"` // models that failed before Model answered
+	ToolCalls []askToolCall      `json:"tool_calls"`
+	Notices   []string           `json:"notices,omitempty"`
+	Downloads []llm.Download     `json:"downloads,omitempty"`
+	Receipts  []audit.Receipt    `json:"receipts,omitempty"`
+	Usage     *llm.Usage         `json:"usage,omitempty"`
+	Error     string             `json:"error,omitempty"`
+}
+
+// askToolCall is a tool call made for the question, with its result
+type askToolCall struct {
+	Tool   string                 `json:"tool"`
+	Args   map[string]interface{} `json:"args,omitempty"`
+	Status string                 `json:"status"` // ok, error or declined
+	Result interface{}            `json:"result,omitempty"`
+	Error  string                 `json:"error,omitempty"`
+}
+
+// runAsk runs `aviagent ask`: answers one question and exits with a status reflecting the outcome,
+// for scripts and CI pipelines
+func runAsk(args []string) int {
+	flags := flag.NewFlagSet("ask", flag.ExitOnError)
+	configPath, model, logLevel := agentFlags(flags)
+	output := flags.String("output", "text", "Output format: text, json, yaml or table")
+	yes := flags.Bool("yes", false, "Allow tool calls that change configuration (they are declined otherwise)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s ask [flags] <question>\n\nAnswer one question and exit. The question is read from stdin when it is \"-\".\n"+
+			"Exit status: 0 success, 1 the agent couldn't answer, 2 invalid usage, 3 a tool call failed or was declined.\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return exitUsage
+	}
+	question := strings.TrimSpace(strings.Join(positional, " "))
+	if question == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read the question: %v\n", err)
+			return exitError
+		}
+		question = strings.TrimSpace(string(data))
+	}
+	if question == "" {
+		flags.Usage()
+		return exitUsage
+	}
+	if !askFormats[*output] {
+		fmt.Fprintf(os.Stderr, "Unknown output format %q, expected text, json, yaml or table\n", *output)
+		return exitUsage
+	}
+
+	agent, code := startAgent(*configPath, *logLevel)
+	if agent == nil {
+		return code
+	}
+	defer agent.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var calls []askToolCall
+	ctx = audit.WithActor(ctx, audit.Actor{Operator: operatorName(), RemoteAddr: "terminal"})
+	ctx = agentchat.WithHooks(ctx, agentchat.Hooks{
+		Confirm: func(toolCall llm.ToolCall) bool {
+			if !*yes {
+				calls = append(calls, askToolCall{Tool: toolCall.Function.Name, Args: toolCall.Args, Status: "declined",
+					Error: "changes need --yes"})
+			}
+			return *yes
+		},
+		// Delays go to stderr so the output stays parseable
+		Status: func(status string) {
+			fmt.Fprintln(os.Stderr, status)
+		},
+		ToolFinished: func(toolCall llm.ToolCall, result interface{}, err error) {
+			call := askToolCall{Tool: toolCall.Function.Name, Args: toolCall.Args, Status: "ok", Result: result}
+			if err != nil {
+				call.Status, call.Error = "error", err.Error()
+			}
+			calls = append(calls, call)
+		},
+		Unattended: true,
+	})
+
+	out := askOutput{Success: true, ToolCalls: []askToolCall{}}
+	result, err := agent.server.Chat(ctx, "", *model, question)
+	if err != nil {
+		out.Success, out.Error = false, err.Error()
+	} else {
+		out.Answer = strings.TrimSpace(result.Message)
+		out.Model = result.Model
+		out.Fallbacks = result.Fallbacks
+		out.ToolCalls = append(out.ToolCalls, calls...)
+		out.Notices = result.Notices
+		out.Downloads = result.Downloads
+		out.Receipts = result.Receipts
+		out.Usage = &result.Usage
+		out.Success = len(result.ToolErrors) == 0
+	}
+
+	if err := writeAskOutput(os.Stdout, *output, out); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write the answer: %v\n", err)
+		return exitError
+	}
+	switch {
+	case out.Error != "":
+		return exitError
+	case !out.Success:
+		return exitToolError
+	}
+	return exitOK
+}
+
+// writeAskOutput writes the result in the requested format
+func writeAskOutput(w io.Writer, format string, out askOutput) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(out)
+	case "yaml":
+		return writeYAML(w, out)
+	case "table":
+		return writeTables(w, out)
+	}
+
+	if out.Error != "" {
+		_, err := fmt.Fprintf(w, "Error: %s\n", out.Error)
+		return err
+	}
+	fmt.Fprintln(w, out.Answer)
+	for _, notice := range out.Notices {
+		fmt.Fprintf(w, "\nNote: %s\n", notice)
+	}
+	for _, download := range out.Downloads {
+		fmt.Fprintf(w, "\nDownload %s through the server: %s\n", download.Filename, download.URL)
+	}
+	for _, receipt := range out.Receipts {
+		fmt.Fprintf(w, "\nReceipt %s: %s (audit entry %s)\n", receipt.ID, receipt.Tool, receipt.AuditID)
+	}
+	return nil
+}
+
+// writeYAML writes a value as YAML with the keys and key order of its JSON encoding
+func writeYAML(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	// JSON is YAML in flow style; reset the style to write block YAML
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return err
+	}
+	blockStyle(&node)
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// blockStyle clears the flow and quoting styles of a YAML node tree
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// writeTables writes a table per tool call result, then the answer when no tool returned data
+func writeTables(w io.Writer, out askOutput) error {
+	if out.Error != "" {
+		_, err := fmt.Fprintf(w, "Error: %s\n", out.Error)
+		return err
+	}
+
+	tables := 0
+	for _, call := range out.ToolCalls {
+		fmt.Fprintf(w, "%s: %s", call.Tool, call.Status)
+		if call.Error != "" {
+			fmt.Fprintf(w, " (%s)", call.Error)
+		}
+		fmt.Fprintln(w)
+		if call.Result == nil {
+			continue
+		}
+		rows, columns := tableRows(call.Result)
+		if len(columns) == 0 {
+			continue
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(columns, "\t")))
+		for _, row := range rows {
+			cells := make([]string, len(columns))
+			for i, column := range columns {
+				cells[i] = tableCell(row[column])
+			}
+			fmt.Fprintln(tw, strings.Join(cells, "\t"))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+		tables++
+	}
+	if tables == 0 {
+		fmt.Fprintln(w, out.Answer)
+	}
+	return nil
+}
+
+// tableRows turns a tool result into table rows: the objects of a list or of a collection's
+// results, or the fields of a single object as key/value rows
+func tableRows(result interface{}) ([]map[string]interface{}, []string) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, nil
+	}
+	if collection, ok := value.(map[string]interface{}); ok {
+		if results, ok := collection["results"].([]interface{}); ok {
+			value = results
+		}
+	}
+
+	switch v := value.(type) {
+	case []interface{}:
+		rows := make([]map[string]interface{}, 0, len(v))
+		for _, item := range v {
+			if row, ok := item.(map[string]interface{}); ok {
+				rows = append(rows, row)
+			}
+		}
+		return rows, tableColumns(rows)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		rows := make([]map[string]interface{}, 0, len(keys))
+		for _, key := range keys {
+			if isScalar(v[key]) {
+				rows = append(rows, map[string]interface{}{"field": key, "value": v[key]})
+			}
+		}
+		return rows, []string{"field", "value"}
+	}
+	return nil, nil
+}
+
+// tableColumns picks the scalar fields of the rows as columns: name and uuid first, then the
+// others in alphabetical order, up to maxTableColumns
+func tableColumns(rows []map[string]interface{}) []string {
+	seen := map[string]bool{}
+	var others []string
+	for _, row := range rows {
+		for key, value := range row {
+			if !seen[key] && isScalar(value) {
+				seen[key] = true
+				if key != "name" && key != "uuid" {
+					others = append(others, key)
+				}
+			}
+		}
+	}
+	sort.Strings(others)
+	var columns []string
+	for _, key := range []string{"name", "uuid"} {
+		if seen[key] {
+			columns = append(columns, key)
+		}
+	}
+	columns = append(columns, others...)
+	if len(columns) > maxTableColumns {
+		columns = columns[:maxTableColumns]
+	}
+	return columns
+}
+
+// isScalar reports whether a decoded JSON value fits a table cell
+func isScalar(v interface{}) bool {
+	switch v.(type) {
+	case string, float64, bool:
+		return true
+	}
+	return false
+}
+
+// tableCell formats a table cell, empty for missing values. Object references
+// (/api/pool/pool-1#web-pool) are shown by the object name.
+func tableCell(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return fmt.Sprintf("%g", v)
+	case string:
+		if ref, name, ok := strings.Cut(v, "#"); ok && strings.HasPrefix(ref, "/api/") {
+			return name
+		}
+	}
+	return fmt.Sprint(v)
+}
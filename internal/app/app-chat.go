@@ -0,0 +1,325 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"aviagent/internal/web"
+	agentchat "aviagent/pkg/agent/chat"
+	"aviagent/pkg/audit"
+	"aviagent/pkg/config"
+	"aviagent/pkg/llm"
+
+	"github.com/chzyer/readline"
+)
+
+// chatHelp lists the commands of the terminal chat
+const chatHelp = `Commands:
+  /model [name]   show or change the model ("auto" routes each question)
+  /save [file]    save the session (default: the -session file)
+  /load <file>    restore a saved session
+  /new            start a new session
+  /usage          token usage and estimated cost of the session
+  /help           this help
+  /exit           quit (Ctrl-D works too)
+Ctrl-C cancels the question being answered.`
+
+// chatCommands are the slash commands, completed with Tab at the prompt
+var chatCommands = []string{"/model", "/save", "/load", "/new", "/usage", "/help", "/exit"}
+
+// lineReader reads the lines typed at a prompt
+type lineReader interface {
+	SetPrompt(prompt string)
+	Readline() (string, error) // readline.ErrInterrupt on Ctrl-C, io.EOF at end of input
+	Close() error
+}
+
+// newLineReader returns a readline prompt with history and completion when stdin is a terminal,
+// and reads plain lines otherwise, e.g. from a pipe
+func newLineReader(historyFile string) (lineReader, error) {
+	if !readline.DefaultIsTerminal() {
+		return &plainLineReader{in: bufio.NewReader(os.Stdin), out: os.Stdout}, nil
+	}
+	items := make([]readline.PrefixCompleterInterface, len(chatCommands))
+	for i, command := range chatCommands {
+		items[i] = readline.PcItem(command)
+	}
+	return readline.NewEx(&readline.Config{
+		HistoryFile:     historyFile,
+		AutoComplete:    readline.NewPrefixCompleter(items...),
+		InterruptPrompt: "^C",
+		EOFPrompt:       "/exit",
+	})
+}
+
+// plainLineReader reads lines without editing, for input that isn't a terminal
+type plainLineReader struct {
+	in     *bufio.Reader
+	out    io.Writer
+	prompt string
+}
+
+func (r *plainLineReader) SetPrompt(prompt string) { r.prompt = prompt }
+
+func (r *plainLineReader) Readline() (string, error) {
+	fmt.Fprint(r.out, r.prompt)
+	line, err := r.in.ReadString('\n')
+	if err != nil && line != "" {
+		// The last line isn't terminated: answer it, end of input comes with the next read
+		err = nil
+	}
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+func (r *plainLineReader) Close() error { return nil }
+
+// chat is the terminal chat: the agent loop of the web server without HTTP
+type chat struct {
+	server      *web.Server
+	in          lineReader
+	out         io.Writer
+	session     string
+	model       string
+	sessionFile string
+	yes         bool
+}
+
+// runChat runs `aviagent chat`, an interactive chat in the terminal
+func runChat(args []string) int {
+	flags := flag.NewFlagSet("chat", flag.ExitOnError)
+	configPath, model, logLevel := agentFlags(flags)
+	sessionFile := flags.String("session", "", "Session file, restored on start when it exists and saved on exit")
+	history := flags.String("history", defaultHistoryFile(), "File keeping the questions typed at the prompt, empty to keep none")
+	yes := flags.Bool("yes", false, "Run tool calls that change configuration without asking")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s chat [flags]\n\nChat with the agent in the terminal.\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	agent, code := startAgent(*configPath, *logLevel)
+	if agent == nil {
+		return code
+	}
+	defer agent.Close()
+	server := agent.server
+
+	in, err := newLineReader(*history)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open the prompt: %v\n", err)
+		return exitError
+	}
+	defer in.Close()
+
+	c := &chat{
+		server:      server,
+		in:          in,
+		out:         os.Stdout,
+		model:       *model,
+		sessionFile: *sessionFile,
+		yes:         *yes,
+	}
+	if c.sessionFile != "" {
+		if _, err := os.Stat(c.sessionFile); err == nil {
+			if c.session, err = server.LoadSession(c.sessionFile); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return exitError
+			}
+			fmt.Fprintf(c.out, "Restored session %s from %s\n", c.session, c.sessionFile)
+		}
+	}
+
+	fmt.Fprintf(c.out, "%s %s, model %s. Type /help for commands.\n", Name, Version, c.modelName(agent.config))
+	c.loop()
+
+	if c.sessionFile != "" && c.session != "" {
+		if err := server.SaveSession(c.session, c.sessionFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		fmt.Fprintf(c.out, "Session saved to %s\n", c.sessionFile)
+	}
+	return exitOK
+}
+
+// defaultHistoryFile is the prompt history in the home directory, empty when there is none
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".aviagent_history")
+}
+
+// modelName is the model the chat starts with, for the banner
+func (c *chat) modelName(cfg *config.Config) string {
+	if c.model != "" {
+		return c.model
+	}
+	return cfg.LLM.DefaultModel
+}
+
+// loop reads questions and commands until /exit or end of input. Ctrl-C at the prompt clears
+// the line.
+func (c *chat) loop() {
+	for {
+		fmt.Fprintln(c.out)
+		c.in.SetPrompt("avi> ")
+		line, err := c.in.Readline()
+		if errors.Is(err, readline.ErrInterrupt) {
+			continue
+		}
+		line = strings.TrimSpace(line)
+		if err == nil && line != "" {
+			if strings.HasPrefix(line, "/") {
+				if !c.command(line) {
+					return
+				}
+			} else {
+				c.ask(line)
+			}
+		}
+		if err != nil {
+			fmt.Fprintln(c.out)
+			return
+		}
+	}
+}
+
+// command runs a slash command and reports whether the chat goes on
+func (c *chat) command(line string) bool {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	switch name {
+	case "/exit", "/quit":
+		return false
+	case "/help":
+		fmt.Fprintln(c.out, chatHelp)
+	case "/model":
+		if arg != "" {
+			c.model = arg
+		}
+		fmt.Fprintf(c.out, "Model: %s\n", valueOr(c.model, "default"))
+	case "/new":
+		c.session = ""
+		fmt.Fprintln(c.out, "Started a new session")
+	case "/save":
+		path := valueOr(arg, c.sessionFile)
+		switch {
+		case path == "":
+			fmt.Fprintln(c.out, "Usage: /save <file>")
+		case c.session == "":
+			fmt.Fprintln(c.out, "Nothing to save yet")
+		default:
+			if err := c.server.SaveSession(c.session, path); err != nil {
+				fmt.Fprintln(c.out, err)
+			} else {
+				fmt.Fprintf(c.out, "Session saved to %s\n", path)
+			}
+		}
+	case "/load":
+		if arg == "" {
+			fmt.Fprintln(c.out, "Usage: /load <file>")
+			break
+		}
+		session, err := c.server.LoadSession(arg)
+		if err != nil {
+			fmt.Fprintln(c.out, err)
+			break
+		}
+		c.session = session
+		fmt.Fprintf(c.out, "Restored session %s\n", session)
+	case "/usage":
+		c.usage()
+	default:
+		fmt.Fprintf(c.out, "Unknown command %s, type /help for the list\n", name)
+	}
+	return true
+}
+
+// ask has the agent answer a question. Tool calls are shown as they run and calls that change
+// configuration are confirmed first, unless -yes was given.
+func (c *chat) ask(question string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	ctx = audit.WithActor(ctx, audit.Actor{Operator: operatorName(), RemoteAddr: "terminal"})
+	ctx = agentchat.WithHooks(ctx, agentchat.Hooks{
+		Confirm: c.confirm,
+		ToolStarted: func(toolCall llm.ToolCall) {
+			fmt.Fprintf(c.out, "  -> %s\n", toolCall.Function.Name)
+		},
+		Status: func(status string) {
+			fmt.Fprintf(c.out, "  .. %s\n", status)
+		},
+		ToolFinished: func(toolCall llm.ToolCall, result interface{}, err error) {
+			if err != nil {
+				fmt.Fprintf(c.out, "  !! %s: %v\n", toolCall.Function.Name, err)
+			}
+		},
+	})
+
+	result, err := c.server.Chat(ctx, c.session, c.model, question)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			fmt.Fprintln(c.out, "Cancelled")
+			return
+		}
+		fmt.Fprintf(c.out, "Error: %v\n", err)
+		return
+	}
+	c.session = result.Session
+
+	if result.Route != nil {
+		fmt.Fprintf(c.out, "[%s: %s]\n", result.Route.Model, result.Route.Reason)
+	}
+	fmt.Fprintf(c.out, "\n%s\n", strings.TrimSpace(result.Message))
+	for _, notice := range result.Notices {
+		fmt.Fprintf(c.out, "\nNote: %s\n", notice)
+	}
+	for _, download := range result.Downloads {
+		fmt.Fprintf(c.out, "\nDownload %s through the server: %s\n", download.Filename, download.URL)
+	}
+	for _, receipt := range result.Receipts {
+		fmt.Fprintf(c.out, "\nReceipt %s: %s (audit entry %s)\n", receipt.ID, receipt.Tool, receipt.AuditID)
+	}
+}
+
+// confirm asks before a tool call that changes configuration
+func (c *chat) confirm(toolCall llm.ToolCall) bool {
+	if c.yes {
+		return true
+	}
+	args, _ := json.Marshal(toolCall.Args)
+	fmt.Fprintf(c.out, "  ?? %s %s\n", toolCall.Function.Name, args)
+	c.in.SetPrompt("  Run this change? [y/N] ")
+	answer, _ := c.in.Readline()
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// usage prints the token usage of the session
+func (c *chat) usage() {
+	if c.session == "" {
+		fmt.Fprintln(c.out, "No questions asked yet")
+		return
+	}
+	result, err := c.server.SessionUsage(c.session)
+	if err != nil {
+		fmt.Fprintln(c.out, err)
+		return
+	}
+	fmt.Fprintf(c.out, "Tokens: %d prompt, %d completion, %d total\n", result.PromptTokens, result.CompletionTokens, result.TotalTokens)
+	if result.Priced {
+		fmt.Fprintf(c.out, "Estimated cost: %.4f %s\n", result.EstimatedCost, result.Currency)
+	}
+}
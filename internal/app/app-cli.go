@@ -0,0 +1,111 @@
+package app
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+
+	"aviagent/internal/logging"
+	"aviagent/internal/web"
+	"aviagent/pkg/config"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Exit codes of the command line tools
+const (
+	exitOK        = 0
+	exitError     = 1 // the agent couldn't start or answer
+	exitUsage     = 2 // invalid flags or arguments
+	exitToolError = 3 // a tool call failed or was declined
+)
+
+// agent is the agent as run by the command line tools, without the HTTP server
+type agent struct {
+	server *web.Server
+	config *config.Config
+	logger *zap.Logger
+}
+
+// startAgent loads the configuration and creates the agent. Logs go to stderr at the given level,
+// errors by default, as they would interleave with the output. Scheduled reports and the alert
+// receiver belong to the server and are not started.
+func startAgent(configPath, logLevel string) (*agent, int) {
+	if _, err := zapcore.ParseLevel(logLevel); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid log level %q\n", logLevel)
+		return nil, exitUsage
+	}
+	logger, _, err := logging.New(config.LogConfig{Level: logLevel, Redact: true})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		return nil, exitError
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return nil, exitError
+	}
+	cfg.Scheduler.Enabled = false
+	cfg.Alerts.Enabled = false
+
+	server, err := web.NewServer(cfg, logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize agent: %v\n", err)
+		return nil, exitError
+	}
+	return &agent{server: server, config: cfg, logger: logger}, exitOK
+}
+
+// Close logs out of the controller and flushes the logs
+func (a *agent) Close() {
+	a.server.Close()
+	a.logger.Sync()
+}
+
+// agentFlags adds the flags shared by the command line tools
+func agentFlags(flags *flag.FlagSet) (configPath, model, logLevel *string) {
+	configPath = flags.String("config", "config.yaml", "Path to configuration file")
+	model = flags.String("model", "", "Model to use (default: the configured default model, \"auto\" to route)")
+	logLevel = flags.String("log-level", "error", "Level of the logs written to stderr")
+	return configPath, model, logLevel
+}
+
+// parseInterspersed parses flags given before or after the positional arguments, e.g.
+// `ask "question" --output json`, and returns the positional arguments. Everything after "--"
+// is positional, even when it looks like a flag.
+func parseInterspersed(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := flags.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// operatorName is the operator recorded in the audit trail for changes made from the terminal
+func operatorName() string {
+	if current, err := user.Current(); err == nil && current.Username != "" {
+		return current.Username
+	}
+	return os.Getenv("USER")
+}
+
+// valueOr returns value, or fallback when it is empty
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
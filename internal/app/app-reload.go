@@ -0,0 +1,193 @@
+package app
+
+import (
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"aviagent/internal/web"
+	"aviagent/pkg/config"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// liveServer is a web server with the requests it is answering
+type liveServer struct {
+	server *web.Server
+	active sync.WaitGroup
+}
+
+// liveHandler sends each request to the current web server, so a configuration reload switches
+// new requests to the new server while the previous one finishes the chats it is answering
+type liveHandler struct {
+	mu      sync.RWMutex
+	current *liveServer
+}
+
+// newLiveHandler creates a handler serving with server
+func newLiveHandler(server *web.Server) *liveHandler {
+	return &liveHandler{current: &liveServer{server: server}}
+}
+
+// ServeHTTP implements http.Handler
+func (h *liveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	live := h.current
+	live.active.Add(1)
+	h.mu.RUnlock()
+	defer live.active.Done()
+
+	live.server.Router().ServeHTTP(w, r)
+}
+
+// Server returns the current web server
+func (h *liveHandler) Server() *web.Server {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.current.server
+}
+
+// swap makes server the current web server and retires the previous one once its requests are done
+func (h *liveHandler) swap(server *web.Server, logger *zap.Logger) {
+	h.mu.Lock()
+	previous := h.current
+	h.current = &liveServer{server: server}
+	h.mu.Unlock()
+
+	go func() {
+		previous.active.Wait()
+		if err := previous.server.Retire(server); err != nil {
+			logger.Warn("Failed to release the replaced server", zap.Error(err))
+		}
+	}()
+}
+
+// reloadConfig reads the configuration file again and, when it is valid, switches the handler to
+// a server built from it. The listener settings can't change while it listens and are kept; the
+// certificate files are read again by the caller.
+func reloadConfig(configPath string, running *config.Config, handler *liveHandler, level zap.AtomicLevel, logger *zap.Logger) *config.Config {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		logger.Error("Configuration reload failed, keeping the running configuration", zap.Error(err))
+		return running
+	}
+	listener := running.Server
+	if cfg.Server.Address() != listener.Address() || cfg.Server.ReadTimeout != listener.ReadTimeout ||
+		cfg.Server.WriteTimeout != listener.WriteTimeout || cfg.Server.IdleTimeout != listener.IdleTimeout ||
+		cfg.Server.TLS != listener.TLS {
+		logger.Warn("The listen address, timeouts and TLS settings only change on restart")
+	}
+	cfg.Server.Host, cfg.Server.Port, cfg.Server.TLS = listener.Host, listener.Port, listener.TLS
+	cfg.Server.ReadTimeout, cfg.Server.WriteTimeout, cfg.Server.IdleTimeout = listener.ReadTimeout, listener.WriteTimeout, listener.IdleTimeout
+
+	next, err := handler.Server().Reload(cfg)
+	if err != nil {
+		logger.Error("Configuration reload failed, keeping the running configuration", zap.Error(err))
+		return running
+	}
+	setLogLevel(level, cfg.Log.Level, logger)
+	handler.swap(next, logger)
+	return cfg
+}
+
+// secretsRotation is the outcome of reading the secrets of a running configuration again
+type secretsRotation struct {
+	running *config.Config // configuration whose secrets were read
+	rotated *config.Config // running with the rotated secrets, nil when none rotated
+	err     error
+}
+
+// rotateSecrets reads the secrets referenced by running again. It reads files and Vault, so the
+// serve loop runs it in a goroutine and applies the outcome with applyRotatedSecrets.
+func rotateSecrets(running *config.Config) secretsRotation {
+	cfg := *running
+	if err := config.ResolveSecrets(&cfg); err != nil {
+		return secretsRotation{running: running, err: err}
+	}
+	if cfg.Avi.Password == running.Avi.Password && cfg.Mistral.APIKey == running.Mistral.APIKey &&
+		reflect.DeepEqual(cfg.AviUsers, running.AviUsers) {
+		return secretsRotation{running: running}
+	}
+	return secretsRotation{running: running, rotated: &cfg}
+}
+
+// applyRotatedSecrets switches the handler to a server using the rotated secrets and returns the
+// configuration now running. The configuration file isn't read again, and a rotation read before
+// a reload is dropped since the reload read the secrets itself.
+func applyRotatedSecrets(running *config.Config, rotation secretsRotation, handler *liveHandler, logger *zap.Logger) *config.Config {
+	switch {
+	case rotation.running != running:
+		return running
+	case rotation.err != nil:
+		logger.Error("Failed to refresh secrets, keeping the current ones", zap.Error(rotation.err))
+		return running
+	case rotation.rotated == nil:
+		return running
+	}
+	cfg := rotation.rotated
+
+	next, err := handler.Server().Reload(cfg)
+	if err != nil {
+		logger.Error("Failed to apply rotated secrets, keeping the current ones", zap.Error(err))
+		return running
+	}
+	logger.Info("Secrets rotated",
+		zap.Bool("avi_password", cfg.Avi.Password != running.Avi.Password),
+		zap.Bool("mistral_api_key", cfg.Mistral.APIKey != running.Mistral.APIKey),
+		zap.Bool("avi_user_accounts", !reflect.DeepEqual(cfg.AviUsers, running.AviUsers)))
+	handler.swap(next, logger)
+	return cfg
+}
+
+// secretsRefresh ticks when the secrets referenced by the configuration are due to be read again
+type secretsRefresh struct {
+	ticker *time.Ticker
+}
+
+// reset follows the refresh interval of cfg; it never ticks when no secret is referenced
+func (r *secretsRefresh) reset(cfg *config.Config) {
+	r.stop()
+	if cfg.HasSecretReferences() && cfg.Secrets.RefreshInterval > 0 {
+		r.ticker = time.NewTicker(time.Duration(cfg.Secrets.RefreshInterval) * time.Second)
+	}
+}
+
+// C returns the channel of the ticks, nil when refreshing is off
+func (r *secretsRefresh) C() <-chan time.Time {
+	if r.ticker == nil {
+		return nil
+	}
+	return r.ticker.C
+}
+
+// stop stops the ticks
+func (r *secretsRefresh) stop() {
+	if r.ticker != nil {
+		r.ticker.Stop()
+		r.ticker = nil
+	}
+}
+
+// setLogLevel applies the configured log level, keeping the current one when it isn't valid
+func setLogLevel(level zap.AtomicLevel, name string, logger *zap.Logger) {
+	parsed, err := zapcore.ParseLevel(name)
+	if err != nil {
+		logger.Warn("Invalid log level, keeping the current one", zap.String("level", name))
+		return
+	}
+	level.SetLevel(parsed)
+}
+
+// toggleDebugLevel switches the log level to debug, or back to the configured level when it is
+// debug already
+func toggleDebugLevel(level zap.AtomicLevel, configured string, logger *zap.Logger) {
+	if level.Level() != zapcore.DebugLevel {
+		level.SetLevel(zapcore.DebugLevel)
+		logger.Info("Debug logging enabled, send SIGUSR1 again to restore the configured level")
+		return
+	}
+	setLogLevel(level, configured, logger)
+	logger.Info("Restored the configured log level", zap.String("level", level.Level().String()))
+}
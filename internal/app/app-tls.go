@@ -0,0 +1,87 @@
+package app
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"aviagent/pkg/config"
+)
+
+// certificateLoader holds the web server certificate and the client CA, read again on SIGHUP so
+// a renewed certificate or CA bundle is used without a restart
+type certificateLoader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+	current      atomic.Pointer[tls.Certificate]
+	clientCAs    atomic.Pointer[x509.CertPool]
+	base         *tls.Config // configuration the handshakes copy with the current client CA
+}
+
+// load reads the certificate, its key and the client CA; nothing changes when one fails
+func (l *certificateLoader) load() error {
+	cert, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load the server certificate: %w", err)
+	}
+	var pool *x509.CertPool
+	if l.clientCAFile != "" {
+		pem, err := os.ReadFile(l.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read the client CA: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no PEM certificate in the client CA %s", l.clientCAFile)
+		}
+	}
+	l.current.Store(&cert)
+	l.clientCAs.Store(pool)
+	return nil
+}
+
+// getCertificate implements tls.Config.GetCertificate
+func (l *certificateLoader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return l.current.Load(), nil
+}
+
+// getConfigForClient implements tls.Config.GetConfigForClient, verifying client certificates
+// against the client CA last loaded
+func (l *certificateLoader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	cfg := l.base.Clone()
+	cfg.GetConfigForClient = nil
+	cfg.ClientCAs = l.clientCAs.Load()
+	return cfg, nil
+}
+
+// newServerTLS returns the TLS configuration of the web server, nil when it serves plain HTTP
+func newServerTLS(cfg config.TLSConfig) (*tls.Config, *certificateLoader, error) {
+	if !cfg.Enabled() {
+		return nil, nil, nil
+	}
+	certs := &certificateLoader{certFile: cfg.CertFile, keyFile: cfg.KeyFile, clientCAFile: cfg.ClientCAFile}
+	if err := certs.load(); err != nil {
+		return nil, nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		GetCertificate: certs.getCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+	if cfg.MinVersion == "1.3" {
+		tlsConfig.MinVersion = tls.VersionTLS13
+	}
+	if cfg.ClientCAFile != "" {
+		tlsConfig.ClientCAs = certs.clientCAs.Load()
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		if cfg.ClientAuth == "optional" {
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+		certs.base = tlsConfig.Clone()
+		tlsConfig.GetConfigForClient = certs.getConfigForClient
+	}
+	return tlsConfig, certs, nil
+}
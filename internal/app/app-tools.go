@@ -0,0 +1,171 @@
+package app
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"aviagent/pkg/avi"
+	"aviagent/pkg/config"
+	"aviagent/pkg/llm"
+)
+
+// runTools runs `aviagent tools list` and `aviagent tools describe <name>`: the tool catalog
+// offered to the model. The server further drops the tools the controller account's role
+// doesn't permit and those its tools configuration disables; with -config, the tools
+// configuration of the file is applied, so its custom tools are listed too.
+func runTools(args []string) int {
+	flags := flag.NewFlagSet("tools", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "Print the tool definitions as JSON")
+	configPath := flags.String("config", "", "Apply the tools configuration of a configuration file, custom tools included")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s tools list [flags]\n       %[1]s tools describe [flags] <tool>\n\nList or describe the tools offered to the model.\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return exitUsage
+	}
+	if len(positional) == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+	var offered *llm.Tools // the built-in tools without a configuration
+	if *configPath != "" {
+		cfg, err := config.Load(*configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", *configPath, err)
+			return exitError
+		}
+		if offered, err = llm.NewTools(cfg.Tools); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: invalid configuration: tools: %v\n", *configPath, err)
+			return exitError
+		}
+	}
+
+	switch {
+	case positional[0] == "list" && len(positional) == 1:
+		tools := offered.Definitions()
+		if *asJSON {
+			return writeJSON(tools)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tCHANGES\tPERMISSION\tDESCRIPTION")
+		for _, tool := range tools {
+			name := tool.Function.Name
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", name, yesNo(offered.IsMutating(name, nil)), permissionText(name), firstSentence(tool.Function.Description))
+		}
+		tw.Flush()
+		return exitOK
+
+	case positional[0] == "describe" && len(positional) == 2:
+		tool, err := offered.ByName(positional[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		if *asJSON {
+			return writeJSON(tool)
+		}
+		describeTool(offered, tool)
+		return exitOK
+	}
+	flags.Usage()
+	return exitUsage
+}
+
+// describeTool prints a tool with its parameters
+func describeTool(offered *llm.Tools, tool *llm.Tool) {
+	name := tool.Function.Name
+	fmt.Printf("%s\n\n%s\n\n", name, tool.Function.Description)
+	fmt.Printf("Changes configuration: %s\n", yesNo(offered.IsMutating(name, nil)))
+	fmt.Printf("Avi permission:        %s\n", permissionText(name))
+	if avi.IsAdminTool(name) {
+		fmt.Println("Offered to:            administrator accounts only")
+	}
+
+	parameters, _ := tool.Function.Parameters.(map[string]interface{})
+	properties, _ := parameters["properties"].(map[string]interface{})
+	if len(properties) == 0 {
+		fmt.Println("\nNo parameters")
+		return
+	}
+	required := map[string]bool{}
+	if list, ok := parameters["required"].([]string); ok {
+		for _, param := range list {
+			required[param] = true
+		}
+	}
+	names := make([]string, 0, len(properties))
+	for param := range properties {
+		names = append(names, param)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if required[names[i]] != required[names[j]] {
+			return required[names[i]]
+		}
+		return names[i] < names[j]
+	})
+
+	fmt.Println("\nParameters:")
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, param := range names {
+		schema, _ := properties[param].(map[string]interface{})
+		details := []string{fmt.Sprint(schema["type"])}
+		if required[param] {
+			details = append(details, "required")
+		}
+		if enum, ok := schema["enum"].([]string); ok {
+			details = append(details, "one of "+strings.Join(enum, ", "))
+		}
+		if def, ok := schema["default"]; ok {
+			details = append(details, fmt.Sprintf("default %v", def))
+		}
+		description, _ := schema["description"].(string)
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n", param, strings.Join(details, ", "), description)
+	}
+	tw.Flush()
+}
+
+// permissionText describes the Avi role privilege a tool needs
+func permissionText(name string) string {
+	resource, write, ok := avi.ToolPermission(name)
+	switch {
+	case !ok:
+		return "-"
+	case write:
+		return resource + " (write)"
+	}
+	return resource + " (read)"
+}
+
+// firstSentence shortens a tool description for the list
+func firstSentence(description string) string {
+	if i := strings.Index(description, ". "); i >= 0 {
+		return description[:i+1]
+	}
+	return description
+}
+
+// yesNo formats a flag for the tool list
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// writeJSON prints a value as indented JSON to stdout
+func writeJSON(v interface{}) int {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	return exitOK
+}
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"aviagent/internal/moderation"
+	"aviagent/internal/notify"
+	"aviagent/internal/scheduler"
+	"aviagent/pkg/config"
+	"aviagent/pkg/llm"
+
+	"go.uber.org/zap"
+)
+
+// runValidate runs `aviagent validate`: loads the configuration and checks the sections the server
+// would reject at startup, without connecting to the controller or the LLM provider
+func runValidate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	configPath := flags.String("config", "config.yaml", "Path to configuration file")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s validate [flags]\n\nCheck the configuration and exit with status 1 when it is invalid.\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *configPath, err)
+		return exitError
+	}
+	if err := validateSections(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: invalid configuration: %v\n", *configPath, err)
+		return exitError
+	}
+
+	fmt.Printf("%s is valid\n", *configPath)
+	controller := cfg.Avi.Host
+	if cfg.Sandbox.Enabled {
+		controller = "sandbox"
+	}
+	fmt.Printf("  controller:    %s\n", controller)
+	fmt.Printf("  provider:      %s (default model %s)\n", cfg.Provider, valueOr(cfg.LLM.DefaultModel, "none"))
+	fmt.Printf("  listen:        %s%s\n", cfg.Server.Address(), tlsNote(cfg.Server.TLS))
+	fmt.Printf("  report jobs:   %d%s\n", len(cfg.Scheduler.Jobs), disabledNote(cfg.Scheduler.Enabled))
+	fmt.Printf("  notifications: %d channels\n", len(cfg.Notifications.Channels))
+	fmt.Printf("  alerts:        %s\n", enabledWord(cfg.Alerts.Enabled))
+	return exitOK
+}
+
+// validateSections builds the parts of the server that parse their configuration (notification
+// templates, report schedules and destinations, moderation rules, the server certificate) and
+// returns the first error
+func validateSections(cfg *config.Config) error {
+	if _, _, err := newServerTLS(cfg.Server.TLS); err != nil {
+		return fmt.Errorf("server.tls: %w", err)
+	}
+	if err := llm.ValidateToolConfig(cfg.Tools); err != nil {
+		return fmt.Errorf("tools: %w", err)
+	}
+	logger := zap.NewNop()
+	notifier, err := notify.New(cfg.Notifications, logger)
+	if err != nil {
+		return fmt.Errorf("notifications: %w", err)
+	}
+	if cfg.Scheduler.Enabled {
+		if _, err := scheduler.New(cfg.Scheduler, nil, notifier, logger); err != nil {
+			return fmt.Errorf("scheduler: %w", err)
+		}
+	}
+	// The moderation model is checked by the server against its LLM client
+	moderationConfig := cfg.Moderation
+	moderationConfig.Model = ""
+	if _, err := moderation.NewModerator(moderationConfig, nil, logger); err != nil {
+		return fmt.Errorf("moderation: %w", err)
+	}
+	return nil
+}
+
+// disabledNote marks a configured section that is switched off
+func disabledNote(enabled bool) string {
+	if enabled {
+		return ""
+	}
+	return " (disabled)"
+}
+
+// enabledWord describes a switch
+func enabledWord(enabled bool) string {
+	if enabled {
+		return "enabled"
+	}
+	return "disabled"
+}
+
+// tlsNote describes how the web server listens
+func tlsNote(t config.TLSConfig) string {
+	switch {
+	case !t.Enabled():
+		return " (plain HTTP)"
+	case t.ClientCAFile == "":
+		return " (TLS)"
+	case t.ClientAuth == "optional":
+		return " (TLS, client certificates verified when sent)"
+	}
+	return " (TLS, client certificates required)"
+}
@@ -0,0 +1,218 @@
+// Package app runs the agent server. It is shared by the cmd/server entrypoint and the
+// compatibility main package at the module root.
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"aviagent/internal/logging"
+	"aviagent/internal/web"
+	"aviagent/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// Name is the application name
+const Name = "VMware Avi LLM Agent"
+
+// Build information, set by the Makefile with -ldflags "-X aviagent/internal/app.Version=..."
+var (
+	Version   = "1.0.0"
+	Commit    = "unknown"
+	BuildDate = "2026-01-01"
+)
+
+// usage lists the commands
+const usage = `Usage: %s <command> [flags]
+
+Commands:
+  serve      run the web server (the default when no command is given)
+  chat       chat with the agent in the terminal
+  ask        answer one question and exit
+  validate   check the configuration and exit
+  tools      list or describe the tools offered to the model
+  version    print the version
+
+Run "%[1]s <command> -h" for the flags of a command.
+`
+
+// Main runs the command given on the command line. Without a command, or when the first argument
+// is a flag, the server is run as before subcommands existed.
+func Main() {
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "serve":
+		serve(args)
+	case "chat":
+		os.Exit(runChat(args))
+	case "ask":
+		os.Exit(runAsk(args))
+	case "validate":
+		os.Exit(runValidate(args))
+	case "tools":
+		os.Exit(runTools(args))
+	case "version":
+		os.Exit(runVersion(args))
+	case "help":
+		fmt.Printf(usage, os.Args[0])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", command)
+		fmt.Fprintf(os.Stderr, usage, os.Args[0])
+		os.Exit(exitUsage)
+	}
+}
+
+// serve parses the serve flags, runs the server, reloads the configuration on SIGHUP and shuts
+// the server down on SIGINT or SIGTERM
+func serve(args []string) {
+	// Parse command line flags
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	configPath := flags.String("config", "config.yaml", "Path to configuration file")
+	flags.Parse(args)
+
+	// Initialize a redacting logger, then rebuild it as configured once the configuration is loaded
+	logger, levels, err := logging.New(config.LogConfig{Redact: true})
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+
+	// Load configuration
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		logger.Fatal("Failed to load configuration", zap.Error(err))
+	}
+	logger, levels, err = logging.New(cfg.Log)
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	defer logger.Sync()
+
+	// Load the server certificate first, so a bad one fails before connecting to the controller
+	tlsConfig, certs, err := newServerTLS(cfg.Server.TLS)
+	if err != nil {
+		logger.Fatal("Failed to configure TLS", zap.Error(err))
+	}
+
+	// Initialize web server
+	server, err := web.NewServer(cfg, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize web server", zap.Error(err))
+	}
+	server.SetLogLevels(levels)
+	handler := newLiveHandler(server)
+
+	// Create HTTP server
+	httpServer := &http.Server{
+		Addr:         cfg.Server.Address(),
+		Handler:      handler,
+		ReadTimeout:  time.Duration(cfg.Server.ReadTimeout) * time.Second,
+		WriteTimeout: time.Duration(cfg.Server.WriteTimeout) * time.Second,
+		IdleTimeout:  time.Duration(cfg.Server.IdleTimeout) * time.Second,
+		TLSConfig:    tlsConfig,
+	}
+
+	// Start server in a goroutine
+	go func() {
+		logger.Info("Starting "+Name,
+			zap.String("version", Version),
+			zap.String("commit", Commit),
+			zap.String("address", httpServer.Addr),
+			zap.Bool("tls", tlsConfig != nil),
+			zap.Bool("client_certificates", tlsConfig != nil && tlsConfig.ClientCAs != nil),
+			zap.String("ollama_host", cfg.LLM.OllamaHost),
+		)
+		listen := httpServer.ListenAndServe
+		if tlsConfig != nil {
+			listen = func() error { return httpServer.ListenAndServeTLS("", "") }
+		}
+		if err := listen(); err != nil && err != http.ErrServerClosed {
+			logger.Fatal("Failed to start HTTP server", zap.Error(err))
+		}
+	}()
+
+	// Reload the configuration on SIGHUP, switch between the configured level and debug logging
+	// on SIGUSR1, read rotated secrets again periodically and shut down gracefully on SIGINT or
+	// SIGTERM. Secrets are read in the background so a slow Vault doesn't hold up the signals; one
+	// read runs at a time.
+	var refresh secretsRefresh
+	refresh.reset(cfg)
+	defer refresh.stop()
+	rotations := make(chan secretsRotation, 1)
+	rotating := false
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	usr1 := make(chan os.Signal, 1)
+	signal.Notify(usr1, syscall.SIGUSR1)
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	for running := true; running; {
+		select {
+		case <-hup:
+			logger.Info("Reloading configuration", zap.String("path", *configPath))
+			cfg = reloadConfig(*configPath, cfg, handler, levels.AtomicLevel, logger)
+			if certs != nil {
+				if err := certs.load(); err != nil {
+					logger.Error("Keeping the current server certificate and client CA", zap.Error(err))
+				}
+			}
+			refresh.reset(cfg)
+		case <-usr1:
+			toggleDebugLevel(levels.AtomicLevel, cfg.Log.Level, logger)
+		case <-refresh.C():
+			if !rotating {
+				rotating = true
+				go func(running *config.Config) { rotations <- rotateSecrets(running) }(cfg)
+			}
+		case rotation := <-rotations:
+			rotating = false
+			cfg = applyRotatedSecrets(cfg, rotation, handler, logger)
+		case <-quit:
+			running = false
+		}
+	}
+	logger.Info("Shutting down server...")
+
+	// Give outstanding requests 30 seconds to complete
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := httpServer.Shutdown(ctx); err != nil {
+		logger.Fatal("Server forced to shutdown", zap.Error(err))
+	}
+
+	// Stop scheduled reports and log out of the controller
+	if err := handler.Server().Close(); err != nil {
+		logger.Warn("Failed to close server", zap.Error(err))
+	}
+
+	logger.Info("Server exiting")
+}
+
+// runVersion runs `aviagent version`
+func runVersion(args []string) int {
+	flags := flag.NewFlagSet("version", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "Print the build information as JSON")
+	flags.Parse(args)
+
+	if *asJSON {
+		return writeJSON(map[string]string{
+			"name": Name, "version": Version, "commit": Commit, "build_date": BuildDate, "go_version": runtime.Version(),
+		})
+	}
+	fmt.Printf("%s %s (commit %s, built %s, %s)\n", Name, Version, Commit, BuildDate, runtime.Version())
+	return exitOK
+}
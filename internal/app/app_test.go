@@ -0,0 +1,282 @@
+package app
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"aviagent/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRotateSecrets(t *testing.T) {
+	passwordFile := filepath.Join(t.TempDir(), "avi-password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("first"), 0o600))
+	running := &config.Config{}
+	running.Avi.PasswordFile = passwordFile
+	require.NoError(t, config.ResolveSecrets(running))
+
+	rotation := rotateSecrets(running)
+	require.NoError(t, rotation.err)
+	assert.Nil(t, rotation.rotated, "nothing rotated")
+	assert.Same(t, running, applyRotatedSecrets(running, rotation, nil, zap.NewNop()))
+
+	require.NoError(t, os.WriteFile(passwordFile, []byte("second"), 0o600))
+	rotation = rotateSecrets(running)
+	require.NoError(t, rotation.err)
+	require.NotNil(t, rotation.rotated)
+	assert.Equal(t, "second", rotation.rotated.Avi.Password)
+	assert.Equal(t, "first", running.Avi.Password, "the running configuration is left alone")
+
+	// A rotation read before a reload is dropped
+	reloaded := &config.Config{}
+	assert.Same(t, reloaded, applyRotatedSecrets(reloaded, rotation, nil, zap.NewNop()))
+
+	// A failed read keeps the current secrets
+	require.NoError(t, os.Remove(passwordFile))
+	rotation = rotateSecrets(running)
+	assert.Error(t, rotation.err)
+	assert.Same(t, running, applyRotatedSecrets(running, rotation, nil, zap.NewNop()))
+	assert.Same(t, running, applyRotatedSecrets(running, secretsRotation{running: running, err: errors.New("vault sealed")}, nil, zap.NewNop()))
+}
+
+// testCertificate is a certificate with its key, written as PEM files
+type testCertificate struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	certFile string
+	keyFile  string
+}
+
+// tlsPair returns the certificate for a TLS client or server
+func (c testCertificate) tlsPair(t *testing.T) tls.Certificate {
+	pair, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	require.NoError(t, err)
+	return pair
+}
+
+// newTestCertificate writes a certificate named name into dir, signed by issuer or self-signed
+// as a CA when issuer is nil
+func newTestCertificate(t *testing.T, dir, name string, issuer *testCertificate) testCertificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv6loopback, net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	parent, signer := template, key
+	if issuer == nil {
+		template.IsCA, template.BasicConstraintsValid = true, true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		parent, signer = issuer.cert, issuer.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	c := testCertificate{cert: cert, key: key, certFile: filepath.Join(dir, name+".pem"), keyFile: filepath.Join(dir, name+".key")}
+	require.NoError(t, os.WriteFile(c.certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(c.keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return c
+}
+
+// tlsGet sends a request to url, presenting the client certificate even when the server asks
+// for another CA, and returns the handshake error
+func tlsGet(url string, rootCA *x509.Certificate, clientCerts ...tls.Certificate) error {
+	roots := x509.NewCertPool()
+	roots.AddCert(rootCA)
+	clientConfig := &tls.Config{RootCAs: roots, GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		if len(clientCerts) == 0 {
+			return &tls.Certificate{}, nil
+		}
+		return &clientCerts[0], nil
+	}}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// startTLSServer serves a 200 with tlsConfig as is and returns its URL; refused handshakes
+// aren't logged
+func startTLSServer(t *testing.T, tlsConfig *tls.Config) string {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Listener = tls.NewListener(server.Listener, tlsConfig)
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.Start()
+	t.Cleanup(server.Close)
+	return "https://" + server.Listener.Addr().String()
+}
+
+func TestNewServerTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCertificate(t, dir, "ca", nil)
+	serverCert := newTestCertificate(t, dir, "server", &ca)
+
+	tlsConfig, certs, err := newServerTLS(config.TLSConfig{})
+	require.NoError(t, err)
+	assert.Nil(t, tlsConfig, "plain HTTP without a certificate")
+	assert.Nil(t, certs)
+
+	tlsConfig, _, err = newServerTLS(config.TLSConfig{CertFile: serverCert.certFile, KeyFile: serverCert.keyFile})
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), tlsConfig.MinVersion)
+	assert.Nil(t, tlsConfig.ClientCAs)
+	assert.Equal(t, tls.NoClientCert, tlsConfig.ClientAuth)
+	assert.NoError(t, tlsGet(startTLSServer(t, tlsConfig), ca.cert))
+
+	tlsConfig, _, err = newServerTLS(config.TLSConfig{CertFile: serverCert.certFile, KeyFile: serverCert.keyFile, MinVersion: "1.3"})
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), tlsConfig.MinVersion)
+
+	_, _, err = newServerTLS(config.TLSConfig{CertFile: serverCert.certFile, KeyFile: ca.keyFile})
+	assert.ErrorContains(t, err, "failed to load the server certificate")
+
+	_, _, err = newServerTLS(config.TLSConfig{CertFile: serverCert.certFile, KeyFile: serverCert.keyFile, ClientCAFile: serverCert.keyFile})
+	assert.ErrorContains(t, err, "no PEM certificate in the client CA")
+}
+
+func TestServerTLSClientAuth(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCertificate(t, dir, "ca", nil)
+	serverCert := newTestCertificate(t, dir, "server", &ca)
+	clientCert := newTestCertificate(t, dir, "client", &ca)
+	otherCA := newTestCertificate(t, dir, "other-ca", nil)
+	otherClient := newTestCertificate(t, dir, "other-client", &otherCA)
+	cfg := config.TLSConfig{CertFile: serverCert.certFile, KeyFile: serverCert.keyFile, ClientCAFile: ca.certFile}
+
+	t.Run("require", func(t *testing.T) {
+		tlsConfig, _, err := newServerTLS(cfg)
+		require.NoError(t, err)
+		assert.Equal(t, tls.RequireAndVerifyClientCert, tlsConfig.ClientAuth)
+		server := startTLSServer(t, tlsConfig)
+		assert.NoError(t, tlsGet(server, ca.cert, clientCert.tlsPair(t)))
+		assert.Error(t, tlsGet(server, ca.cert), "a certificate is required")
+		assert.Error(t, tlsGet(server, ca.cert, otherClient.tlsPair(t)), "the certificate must chain to the client CA")
+	})
+
+	t.Run("optional", func(t *testing.T) {
+		optional := cfg
+		optional.ClientAuth = "optional"
+		tlsConfig, _, err := newServerTLS(optional)
+		require.NoError(t, err)
+		assert.Equal(t, tls.VerifyClientCertIfGiven, tlsConfig.ClientAuth)
+		server := startTLSServer(t, tlsConfig)
+		assert.NoError(t, tlsGet(server, ca.cert, clientCert.tlsPair(t)))
+		assert.NoError(t, tlsGet(server, ca.cert))
+		assert.Error(t, tlsGet(server, ca.cert, otherClient.tlsPair(t)), "a certificate sent is still verified")
+	})
+}
+
+func TestCertificateReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCertificate(t, dir, "ca", nil)
+	serverCert := newTestCertificate(t, dir, "server", &ca)
+	clientCert := newTestCertificate(t, dir, "client", &ca)
+	tlsConfig, certs, err := newServerTLS(config.TLSConfig{CertFile: serverCert.certFile, KeyFile: serverCert.keyFile, ClientCAFile: ca.certFile})
+	require.NoError(t, err)
+	server := startTLSServer(t, tlsConfig)
+	require.NoError(t, tlsGet(server, ca.cert, clientCert.tlsPair(t)))
+
+	// The renewed files are read again in place, e.g. on SIGHUP
+	newCA := newTestCertificate(t, t.TempDir(), "ca", nil)
+	renewed := newTestCertificate(t, t.TempDir(), "server", &newCA)
+	newClient := newTestCertificate(t, dir, "new-client", &newCA)
+	for from, to := range map[string]string{newCA.certFile: ca.certFile, renewed.certFile: serverCert.certFile, renewed.keyFile: serverCert.keyFile} {
+		data, err := os.ReadFile(from)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(to, data, 0o600))
+	}
+	require.NoError(t, certs.load())
+
+	current, err := certs.getCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, renewed.cert.Raw, current.Certificate[0])
+	assert.NoError(t, tlsGet(server, newCA.cert, newClient.tlsPair(t)), "the renewed certificate and client CA are used")
+	assert.Error(t, tlsGet(server, newCA.cert, clientCert.tlsPair(t)), "clients of the replaced CA are refused")
+
+	// A bad file keeps everything as it was
+	require.NoError(t, os.WriteFile(ca.certFile, []byte("not a certificate"), 0o600))
+	assert.Error(t, certs.load())
+	current, err = certs.getCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, renewed.cert.Raw, current.Certificate[0])
+	assert.NoError(t, tlsGet(server, newCA.cert, newClient.tlsPair(t)))
+}
+
+func TestParseInterspersed(t *testing.T) {
+	newFlags := func() (*flag.FlagSet, *string) {
+		flags := flag.NewFlagSet("ask", flag.ContinueOnError)
+		flags.SetOutput(io.Discard)
+		return flags, flags.String("output", "text", "")
+	}
+
+	flags, output := newFlags()
+	positional, err := parseInterspersed(flags, []string{"list", "pools", "--output", "json", "now"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"list", "pools", "now"}, positional)
+	assert.Equal(t, "json", *output)
+
+	flags, output = newFlags()
+	positional, err = parseInterspersed(flags, []string{"-output", "yaml", "why", "--", "-5%", "--output", "table"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"why", "-5%", "--output", "table"}, positional, "arguments after -- are positional")
+	assert.Equal(t, "yaml", *output)
+
+	flags, _ = newFlags()
+	_, err = parseInterspersed(flags, []string{"question", "--unknown"})
+	assert.Error(t, err)
+}
+
+func TestPlainLineReader(t *testing.T) {
+	var out strings.Builder
+	r := &plainLineReader{in: bufio.NewReader(strings.NewReader("list pools\r\ny\nlast")), out: &out}
+	r.SetPrompt("avi> ")
+	line, err := r.Readline()
+	require.NoError(t, err)
+	assert.Equal(t, "list pools", line)
+	line, err = r.Readline()
+	require.NoError(t, err)
+	assert.Equal(t, "y", line)
+	line, err = r.Readline()
+	require.NoError(t, err, "an unterminated last line is still read")
+	assert.Equal(t, "last", line)
+	_, err = r.Readline()
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, "avi> avi> avi> avi> ", out.String())
+}
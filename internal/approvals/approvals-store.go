@@ -0,0 +1,218 @@
+// Package approvals keeps the queue of high-risk changes waiting for a second person: tool calls
+// that matched an approval rule are held until an approver other than the operator who asked
+// approves or rejects them.
+package approvals
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"aviagent/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// defaultMaxRequests is how many requests are kept when the configuration doesn't say
+const defaultMaxRequests = 500
+
+// Request states
+const (
+	StatePending  = "pending"  // waiting for an approver
+	StateApproved = "approved" // approved, the change is running
+	StateRejected = "rejected" // rejected, the change was never made
+	StateExecuted = "executed" // approved and made
+	StateFailed   = "failed"   // approved, but the change failed or the agent stopped while making it
+)
+
+// Request is a change held for a second person's approval
+type Request struct {
+	ID        string                 `json:"id"`
+	Tool      string                 `json:"tool"`
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+	Rule      string                 `json:"rule"`               // the approval rule the change matched
+	Tenant    string                 `json:"tenant"`             // the tenant the change is made in
+	Operator  string                 `json:"operator,omitempty"` // who asked for the change
+	Session   string                 `json:"session,omitempty"`
+	Model     string                 `json:"model,omitempty"`
+	State     string                 `json:"state"`
+	Approver  string                 `json:"approver,omitempty"` // who approved or rejected it
+	Reason    string                 `json:"reason,omitempty"`   // given with the decision
+	Receipt   string                 `json:"receipt,omitempty"`  // of the change once made
+	Error     string                 `json:"error,omitempty"`
+	Created   time.Time              `json:"created"`
+	Decided   *time.Time             `json:"decided,omitempty"`
+}
+
+// Store keeps the requests in memory and saves them to a JSON file after every change when one is
+// configured
+type Store struct {
+	mu          sync.Mutex
+	requests    []*Request // oldest first
+	file        string
+	maxRequests int
+	seq         int
+	logger      *zap.Logger
+}
+
+// NewStore creates the approval queue, loading the state file when one is configured. Changes
+// that were running when the agent stopped are marked failed, as their outcome is unknown.
+func NewStore(cfg config.ApprovalsConfig, logger *zap.Logger) (*Store, error) {
+	s := &Store{
+		file:        cfg.StateFile,
+		maxRequests: cfg.MaxRequests,
+		logger:      logger,
+	}
+	if s.maxRequests <= 0 {
+		s.maxRequests = defaultMaxRequests
+	}
+	if s.file == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(s.file)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read approvals state %s: %w", s.file, err)
+	}
+	if err := json.Unmarshal(data, &s.requests); err != nil {
+		return nil, fmt.Errorf("failed to parse approvals state %s: %w", s.file, err)
+	}
+	for _, request := range s.requests {
+		if request.State == StateApproved {
+			request.State, request.Error = StateFailed, "the agent stopped while the change was running; check the object before asking again"
+		}
+		if n, err := strconv.Atoi(strings.TrimPrefix(request.ID, "approval-")); err == nil && n > s.seq {
+			s.seq = n
+		}
+	}
+	return s, nil
+}
+
+// Submit queues a change for approval
+func (s *Store) Submit(request Request) Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	request.ID = fmt.Sprintf("approval-%d", s.seq)
+	request.State = StatePending
+	request.Created = time.Now().UTC()
+	request.Approver, request.Reason, request.Receipt, request.Error, request.Decided = "", "", "", "", nil
+	s.requests = append(s.requests, &request)
+	s.prune()
+	s.save()
+	return request
+}
+
+// Decide approves or rejects a pending change. The operator who asked for it can't decide it.
+func (s *Store) Decide(id, approver string, approve bool, reason string) (Request, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	request := s.find(id)
+	if request == nil {
+		return Request{}, fmt.Errorf("approval request %s not found", id)
+	}
+	if request.State != StatePending {
+		return Request{}, fmt.Errorf("approval request %s is %s, not pending", id, request.State)
+	}
+	if approver == "" || strings.EqualFold(approver, request.Operator) {
+		return Request{}, fmt.Errorf("approval request %s must be decided by someone other than the operator who asked for it", id)
+	}
+	now := time.Now().UTC()
+	request.State, request.Approver, request.Reason, request.Decided = StateRejected, approver, strings.TrimSpace(reason), &now
+	if approve {
+		request.State = StateApproved
+	}
+	s.save()
+	return *request, nil
+}
+
+// Finish records the outcome of an approved change: its receipt, or the error it failed with
+func (s *Store) Finish(id, receipt string, changeErr error) (Request, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	request := s.find(id)
+	if request == nil {
+		return Request{}, fmt.Errorf("approval request %s not found", id)
+	}
+	if request.State != StateApproved {
+		return Request{}, fmt.Errorf("approval request %s is %s, not approved", id, request.State)
+	}
+	request.State, request.Receipt = StateExecuted, receipt
+	if changeErr != nil {
+		request.State, request.Error = StateFailed, changeErr.Error()
+	}
+	s.save()
+	return *request, nil
+}
+
+// Get returns a request
+func (s *Store) Get(id string) (Request, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	request := s.find(id)
+	if request == nil {
+		return Request{}, false
+	}
+	return *request, true
+}
+
+// find returns a request by ID, nil when there is none
+func (s *Store) find(id string) *Request {
+	for _, request := range s.requests {
+		if request.ID == id {
+			return request
+		}
+	}
+	return nil
+}
+
+// List returns the requests in a state, or all of them when state is empty, most recent first
+func (s *Store) List(state string) []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requests := []Request{}
+	for i := len(s.requests) - 1; i >= 0; i-- {
+		if state == "" || s.requests[i].State == state {
+			requests = append(requests, *s.requests[i])
+		}
+	}
+	return requests
+}
+
+// prune drops the oldest decided requests beyond the limit; pending requests are always kept
+func (s *Store) prune() {
+	excess := len(s.requests) - s.maxRequests
+	kept := s.requests[:0]
+	for _, request := range s.requests {
+		if excess > 0 && request.State != StatePending && request.State != StateApproved {
+			excess--
+			continue
+		}
+		kept = append(kept, request)
+	}
+	s.requests = kept
+}
+
+// save writes the requests to the state file, if one is configured
+func (s *Store) save() {
+	if s.file == "" {
+		return
+	}
+	data, err := json.MarshalIndent(s.requests, "", "  ")
+	if err == nil {
+		tmp := filepath.Join(filepath.Dir(s.file), "."+filepath.Base(s.file)+".tmp")
+		if err = os.WriteFile(tmp, data, 0600); err == nil {
+			err = os.Rename(tmp, s.file)
+		}
+	}
+	if err != nil {
+		s.logger.Error("Failed to save approvals state", zap.String("file", s.file), zap.Error(err))
+	}
+}
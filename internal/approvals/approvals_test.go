@@ -0,0 +1,83 @@
+package approvals
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"aviagent/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestStore(t *testing.T) {
+	cfg := config.ApprovalsConfig{StateFile: filepath.Join(t.TempDir(), "approvals.json"), MaxRequests: 3}
+	store, err := NewStore(cfg, zaptest.NewLogger(t))
+	require.NoError(t, err)
+
+	deletion := store.Submit(Request{Tool: "delete_pool", Arguments: map[string]interface{}{"uuid": "pool-1"}, Rule: "deletes", Operator: "alice"})
+	assert.Equal(t, "approval-1", deletion.ID)
+	assert.Equal(t, StatePending, deletion.State)
+
+	// Only someone else decides, and only once
+	_, err = store.Decide(deletion.ID, "Alice", true, "")
+	assert.ErrorContains(t, err, "someone other than the operator")
+	_, err = store.Decide(deletion.ID, "", true, "")
+	assert.ErrorContains(t, err, "someone other than the operator")
+	approved, err := store.Decide(deletion.ID, "carol", true, " checked with the app team ")
+	require.NoError(t, err)
+	assert.Equal(t, StateApproved, approved.State)
+	assert.Equal(t, "checked with the app team", approved.Reason)
+	require.NotNil(t, approved.Decided)
+	_, err = store.Decide(deletion.ID, "dave", false, "")
+	assert.ErrorContains(t, err, "is approved, not pending")
+
+	executed, err := store.Finish(deletion.ID, "rcpt-1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, StateExecuted, executed.State)
+	assert.Equal(t, "rcpt-1", executed.Receipt)
+	_, err = store.Finish(deletion.ID, "", nil)
+	assert.ErrorContains(t, err, "not approved")
+
+	rejected := store.Submit(Request{Tool: "delete_virtual_service", Rule: "deletes", Operator: "bob"})
+	_, err = store.Decide(rejected.ID, "carol", false, "still in use")
+	require.NoError(t, err)
+	running := store.Submit(Request{Tool: "update_pool", Rule: "prod", Operator: "bob"})
+	_, err = store.Decide(running.ID, "carol", true, "")
+	require.NoError(t, err)
+	_, err = store.Decide("approval-9", "carol", true, "")
+	assert.ErrorContains(t, err, "not found")
+
+	// Beyond the limit the oldest decided requests are dropped, never the pending ones
+	pending := store.Submit(Request{Tool: "delete_pool", Rule: "deletes", Operator: "bob"})
+	assert.Equal(t, []string{pending.ID, running.ID, rejected.ID}, ids(store.List("")))
+	assert.Equal(t, []string{pending.ID}, ids(store.List(StatePending)))
+
+	// A change running when the agent stopped is failed on restart; numbering continues
+	store, err = NewStore(cfg, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	restarted, ok := store.Get(running.ID)
+	require.True(t, ok)
+	assert.Equal(t, StateFailed, restarted.State)
+	assert.Contains(t, restarted.Error, "agent stopped")
+	assert.Equal(t, "approval-5", store.Submit(Request{Tool: "delete_pool", Rule: "deletes"}).ID)
+
+	failed := store.Submit(Request{Tool: "delete_pool", Rule: "deletes"})
+	_, err = store.Decide(failed.ID, "carol", true, "")
+	require.NoError(t, err)
+	failed, err = store.Finish(failed.ID, "", errors.New("pool is in use"))
+	require.NoError(t, err)
+	assert.Equal(t, StateFailed, failed.State)
+	assert.Equal(t, "pool is in use", failed.Error)
+}
+
+// ids returns the IDs of requests in order
+func ids(requests []Request) []string {
+	ids := make([]string, len(requests))
+	for i, request := range requests {
+		ids[i] = request.ID
+	}
+	return ids
+}
@@ -1,204 +0,0 @@
-package avi
-
-import (
-	"context"
-	"fmt"
-
-	"aviagent/internal/config"
-	"github.com/vmware/alb-sdk/go/clients"
-	"github.com/vmware/alb-sdk/go/models"
-	"github.com/vmware/alb-sdk/go/session"
-	"go.uber.org/zap"
-)
-
-// OfficialClient represents the Avi Load Balancer API client using official SDK
-type OfficialClient struct {
-	aviClient *clients.AviClient
-	config    *config.AviConfig
-	logger    *zap.Logger
-}
-
-// NewOfficialClient creates a new Avi client using the official SDK
-func NewOfficialClient(cfg *config.AviConfig, logger *zap.Logger) (*OfficialClient, error) {
-	logger.Info("Creating Avi client using official SDK",
-		zap.String("host", cfg.Host),
-		zap.String("username", cfg.Username),
-		zap.String("tenant", cfg.Tenant),
-		zap.String("version", cfg.Version))
-
-	// Create Avi client using official SDK
-	options := []func(*session.AviSession) error{
-		session.SetPassword(cfg.Password),
-		session.SetTenant(cfg.Tenant),
-	}
-	
-	// Set insecure option if configured
-	if cfg.Insecure {
-		options = append(options, session.SetInsecure)
-	}
-	
-	// Set version if specified
-	if cfg.Version != "" {
-		options = append(options, session.SetVersion(cfg.Version))
-	}
-	
-	aviClient, err := clients.NewAviClient(cfg.Host, cfg.Username, options...)
-	if err != nil {
-		logger.Error("Failed to create Avi client using official SDK", zap.Error(err))
-		return nil, fmt.Errorf("failed to create Avi client: %w", err)
-	}
-
-	logger.Info("Successfully created Avi client using official SDK")
-
-	return &OfficialClient{
-		aviClient: aviClient,
-		config:    cfg,
-		logger:    logger,
-	}, nil
-}
-
-// ListVirtualServices lists all virtual services
-func (c *OfficialClient) ListVirtualServices(ctx context.Context, params map[string]string) (interface{}, error) {
-	c.logger.Info("Listing virtual services using official SDK")
-	return c.aviClient.VirtualService.GetAll()
-}
-
-// GetVirtualService gets a specific virtual service by UUID
-func (c *OfficialClient) GetVirtualService(ctx context.Context, uuid string, params map[string]string) (interface{}, error) {
-	c.logger.Info("Getting virtual service using official SDK", zap.String("uuid", uuid))
-	return c.aviClient.VirtualService.Get(uuid)
-}
-
-// CreateVirtualService creates a new virtual service
-func (c *OfficialClient) CreateVirtualService(ctx context.Context, data map[string]interface{}) (interface{}, error) {
-	c.logger.Info("Creating virtual service using official SDK")
-	// Convert map to VirtualService model
-	vs := &models.VirtualService{}
-	// TODO: Implement proper conversion from map to model
-	return c.aviClient.VirtualService.Create(vs)
-}
-
-// UpdateVirtualService updates an existing virtual service
-func (c *OfficialClient) UpdateVirtualService(ctx context.Context, uuid string, data map[string]interface{}) (interface{}, error) {
-	c.logger.Info("Updating virtual service using official SDK", zap.String("uuid", uuid))
-	// Convert map to VirtualService model
-	vs := &models.VirtualService{}
-	// TODO: Implement proper conversion from map to model
-	return c.aviClient.VirtualService.Update(vs)
-}
-
-// DeleteVirtualService deletes a virtual service
-func (c *OfficialClient) DeleteVirtualService(ctx context.Context, uuid string) error {
-	c.logger.Info("Deleting virtual service using official SDK", zap.String("uuid", uuid))
-	return c.aviClient.VirtualService.Delete(uuid)
-}
-
-// ListPools lists all pools
-func (c *OfficialClient) ListPools(ctx context.Context, params map[string]string) (interface{}, error) {
-	c.logger.Info("Listing pools using official SDK")
-	return c.aviClient.Pool.GetAll()
-}
-
-// GetPool gets a specific pool by UUID
-func (c *OfficialClient) GetPool(ctx context.Context, uuid string, params map[string]string) (interface{}, error) {
-	c.logger.Info("Getting pool using official SDK", zap.String("uuid", uuid))
-	return c.aviClient.Pool.Get(uuid)
-}
-
-// CreatePool creates a new pool
-func (c *OfficialClient) CreatePool(ctx context.Context, data map[string]interface{}) (interface{}, error) {
-	c.logger.Info("Creating pool using official SDK")
-	// Convert map to Pool model
-	pool := &models.Pool{}
-	// TODO: Implement proper conversion from map to model
-	return c.aviClient.Pool.Create(pool)
-}
-
-// ScaleOutPool scales out a pool
-func (c *OfficialClient) ScaleOutPool(ctx context.Context, uuid string, params map[string]interface{}) error {
-	c.logger.Info("Scaling out pool using official SDK", zap.String("uuid", uuid))
-	// TODO: Implement scale out logic using official SDK
-	return fmt.Errorf("scale out not implemented yet")
-}
-
-// ScaleInPool scales in a pool
-func (c *OfficialClient) ScaleInPool(ctx context.Context, uuid string, params map[string]interface{}) error {
-	c.logger.Info("Scaling in pool using official SDK", zap.String("uuid", uuid))
-	// TODO: Implement scale in logic using official SDK
-	return fmt.Errorf("scale in not implemented yet")
-}
-
-// ListHealthMonitors lists all health monitors
-func (c *OfficialClient) ListHealthMonitors(ctx context.Context, params map[string]string) (interface{}, error) {
-	c.logger.Info("Listing health monitors using official SDK")
-	return c.aviClient.HealthMonitor.GetAll()
-}
-
-// GetHealthMonitor gets a specific health monitor by UUID
-func (c *OfficialClient) GetHealthMonitor(ctx context.Context, uuid string, params map[string]string) (interface{}, error) {
-	c.logger.Info("Getting health monitor using official SDK", zap.String("uuid", uuid))
-	return c.aviClient.HealthMonitor.Get(uuid)
-}
-
-// ListServiceEngines lists all service engines
-func (c *OfficialClient) ListServiceEngines(ctx context.Context, params map[string]string) (interface{}, error) {
-	c.logger.Info("Listing service engines using official SDK")
-	return c.aviClient.ServiceEngine.GetAll()
-}
-
-// GetServiceEngine gets a specific service engine by UUID
-func (c *OfficialClient) GetServiceEngine(ctx context.Context, uuid string, params map[string]string) (interface{}, error) {
-	c.logger.Info("Getting service engine using official SDK", zap.String("uuid", uuid))
-	return c.aviClient.ServiceEngine.Get(uuid)
-}
-
-// GetAnalytics gets analytics data for a resource
-func (c *OfficialClient) GetAnalytics(ctx context.Context, resourceType, uuid string, params map[string]string) (interface{}, error) {
-	c.logger.Info("Getting analytics using official SDK", 
-		zap.String("resource_type", resourceType),
-		zap.String("uuid", uuid))
-	// TODO: Implement analytics retrieval using official SDK
-	return nil, fmt.Errorf("analytics not implemented yet")
-}
-
-// ExecuteGenericOperation executes a generic API operation
-func (c *OfficialClient) ExecuteGenericOperation(ctx context.Context, method, endpoint string, body interface{}, params map[string]string) (interface{}, error) {
-	c.logger.Info("Executing generic operation using official SDK", 
-		zap.String("method", method),
-		zap.String("endpoint", endpoint))
-	
-	// Build the full URL
-	fullURL := "/api" + endpoint
-	
-	// Create a result interface
-	var result interface{}
-	
-	// Execute the request based on method
-	switch method {
-	case "GET":
-		err := c.aviClient.AviSession.Get(fullURL, &result)
-		return result, err
-	case "POST":
-		err := c.aviClient.AviSession.Post(fullURL, body, &result)
-		return result, err
-	case "PUT":
-		err := c.aviClient.AviSession.Put(fullURL, body, &result)
-		return result, err
-	case "DELETE":
-		err := c.aviClient.AviSession.Delete(fullURL)
-		return nil, err
-	case "PATCH":
-		err := c.aviClient.AviSession.Patch(fullURL, body, "", &result)
-		return result, err
-	default:
-		return nil, fmt.Errorf("unsupported HTTP method: %s", method)
-	}
-}
-
-// Close closes the Avi client connection
-func (c *OfficialClient) Close() error {
-	c.logger.Info("Closing Avi client")
-	// The official SDK doesn't have an explicit close method
-	// Session management is handled automatically
-	return nil
-}
\ No newline at end of file
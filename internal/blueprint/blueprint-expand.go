@@ -0,0 +1,298 @@
+package blueprint
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// placeholderPattern matches the ${parameter} placeholders of blueprint objects
+var placeholderPattern = regexp.MustCompile(`\$\{([A-Za-z0-9_]+)\}`)
+
+// namePattern matches the object names a name parameter accepts
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ParameterError lists the parameters an expansion is missing and the values it can't use
+type ParameterError struct {
+	Blueprint string
+	Missing   []Parameter
+	Invalid   []string
+}
+
+func (e *ParameterError) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		names := make([]string, len(e.Missing))
+		for i, param := range e.Missing {
+			names[i] = param.Name
+		}
+		parts = append(parts, "missing "+strings.Join(names, ", "))
+	}
+	parts = append(parts, e.Invalid...)
+	return fmt.Sprintf("blueprint %s: %s", e.Blueprint, strings.Join(parts, "; "))
+}
+
+// Expand returns the objects of a blueprint in export layout for the parameter values, with
+// defaults for the values not given. A placeholder that is a whole string is replaced by the
+// value as it is, e.g. a number or the list of servers; fields of optional parameters without a
+// value are left out. Missing and invalid values are reported together as a *ParameterError.
+func (bp Blueprint) Expand(values map[string]interface{}) (map[string]interface{}, error) {
+	paramErr := &ParameterError{Blueprint: bp.Name}
+	declared := make(map[string]bool, len(bp.Parameters))
+	for _, param := range bp.Parameters {
+		declared[param.Name] = true
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !declared[name] {
+			paramErr.Invalid = append(paramErr.Invalid, fmt.Sprintf("unknown parameter %s", name))
+		}
+	}
+
+	vars := make(map[string]interface{}, len(bp.Parameters))
+	for _, param := range bp.Parameters {
+		value := values[param.Name]
+		if value == nil || value == "" {
+			if param.Default == nil {
+				if param.Required {
+					paramErr.Missing = append(paramErr.Missing, param)
+				}
+				continue
+			}
+			// nil when the parameters the default refers to are missing or invalid
+			if value = substitute(param.Default, vars); value == nil {
+				continue
+			}
+		}
+		converted, err := param.Convert(value)
+		if err != nil {
+			paramErr.Invalid = append(paramErr.Invalid, err.Error())
+			continue
+		}
+		vars[param.Name] = converted
+		if param.Type == TypeIP {
+			vars[param.Name+"_type"] = ipType(net.ParseIP(converted.(string)))
+		}
+	}
+	if len(paramErr.Missing) > 0 || len(paramErr.Invalid) > 0 {
+		return nil, paramErr
+	}
+
+	configuration := make(map[string]interface{}, len(bp.Objects))
+	for objType, objects := range bp.Objects {
+		configuration[objType] = substitute(objects, vars)
+	}
+	return configuration, nil
+}
+
+// substitute replaces the placeholders of a value, leaving out the fields and list items whose
+// placeholder has no value
+func substitute(value interface{}, vars map[string]interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if item = substitute(item, vars); item != nil {
+				object[key] = item
+			}
+		}
+		return object
+	case []interface{}:
+		list := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			if item = substitute(item, vars); item != nil {
+				list = append(list, item)
+			}
+		}
+		return list
+	case string:
+		if match := placeholderPattern.FindStringSubmatch(v); match != nil && match[0] == v {
+			return vars[match[1]]
+		}
+		return placeholderPattern.ReplaceAllStringFunc(v, func(placeholder string) string {
+			if value := vars[placeholder[2:len(placeholder)-1]]; value != nil {
+				return fmt.Sprint(value)
+			}
+			return ""
+		})
+	}
+	return value
+}
+
+// placeholders returns the parameters the placeholders of a value refer to, in order
+func placeholders(value interface{}) []string {
+	found := make(map[string]bool)
+	var walk func(value interface{})
+	walk = func(value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for _, item := range v {
+				walk(item)
+			}
+		case []interface{}:
+			for _, item := range v {
+				walk(item)
+			}
+		case map[string][]interface{}:
+			for _, item := range v {
+				walk(item)
+			}
+		case string:
+			for _, match := range placeholderPattern.FindAllStringSubmatch(v, -1) {
+				found[match[1]] = true
+			}
+		}
+	}
+	walk(value)
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Convert checks a value against the type of the parameter and returns it as objects take it
+func (p Parameter) Convert(value interface{}) (interface{}, error) {
+	switch p.Type {
+	case TypePort, TypeInteger:
+		n, ok := toInt(value)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a whole number, not %v", p.Name, value)
+		}
+		if p.Type == TypePort && (n < 1 || n > 65535) {
+			return nil, fmt.Errorf("%s must be a port between 1 and 65535, not %d", p.Name, n)
+		}
+		return n, nil
+	case TypeBoolean:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b, nil
+			}
+		}
+		return nil, fmt.Errorf("%s must be true or false, not %v", p.Name, value)
+	case TypeServers:
+		return p.servers(value)
+	}
+
+	text, ok := scalarString(value)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a single value", p.Name)
+	}
+	text = strings.TrimSpace(text)
+	switch p.Type {
+	case TypeName:
+		if !namePattern.MatchString(text) {
+			return nil, fmt.Errorf("%s must be a name of letters, digits, dots, dashes and underscores, not %q", p.Name, text)
+		}
+	case TypeIP:
+		ip := net.ParseIP(text)
+		if ip == nil {
+			return nil, fmt.Errorf("%s must be an IP address, not %q", p.Name, text)
+		}
+		text = ip.String()
+	}
+	if len(p.Values) > 0 {
+		for _, allowed := range p.Values {
+			if strings.EqualFold(allowed, text) {
+				return allowed, nil
+			}
+		}
+		return nil, fmt.Errorf("%s must be one of %s, not %q", p.Name, strings.Join(p.Values, ", "), text)
+	}
+	return text, nil
+}
+
+// servers reads backend servers, a list or a comma-separated string of ip or ip:port, as the
+// servers of a pool
+func (p Parameter) servers(value interface{}) (interface{}, error) {
+	var entries []string
+	switch v := value.(type) {
+	case string:
+		entries = strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' })
+	case []interface{}:
+		for _, item := range v {
+			entry, ok := scalarString(item)
+			if !ok {
+				return nil, fmt.Errorf("%s must list servers as ip or ip:port", p.Name)
+			}
+			entries = append(entries, strings.TrimSpace(entry))
+		}
+	default:
+		return nil, fmt.Errorf("%s must list servers as ip or ip:port", p.Name)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%s needs at least one server", p.Name)
+	}
+
+	servers := make([]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		host, port := entry, ""
+		if net.ParseIP(entry) == nil {
+			var err error
+			if host, port, err = net.SplitHostPort(entry); err != nil {
+				return nil, fmt.Errorf("%s: %q is not ip or ip:port", p.Name, entry)
+			}
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return nil, fmt.Errorf("%s: %q is not an IP address", p.Name, host)
+		}
+		server := map[string]interface{}{"ip": map[string]interface{}{"addr": ip.String(), "type": ipType(ip)}}
+		if port != "" {
+			n, err := strconv.Atoi(port)
+			if err != nil || n < 1 || n > 65535 {
+				return nil, fmt.Errorf("%s: %q has no valid port", p.Name, entry)
+			}
+			server["port"] = n
+		}
+		servers = append(servers, server)
+	}
+	return servers, nil
+}
+
+// scalarString returns a string, number or boolean value as a string
+func scalarString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64, int, bool:
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
+
+// toInt returns a whole number given as a number or a string
+func toInt(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case float64:
+		if v == float64(int(v)) {
+			return int(v), true
+		}
+	case string:
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// ipType returns the address type of an IP address as the controller names it
+func ipType(ip net.IP) string {
+	if ip.To4() != nil {
+		return "V4"
+	}
+	return "V6"
+}
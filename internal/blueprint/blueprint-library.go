@@ -0,0 +1,213 @@
+// Package blueprint expands blueprints of common application patterns, such as an HTTPS
+// application with an HTTP redirect or an L4 TCP application, into the objects to create. A
+// blueprint declares its parameters with their types and the objects in export layout, with
+// "${parameter}" placeholders the parameter values replace.
+package blueprint
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed blueprints.yaml
+var builtinData []byte
+
+// SourceBuiltIn is the source of the blueprints shipped with the agent
+const SourceBuiltIn = "built-in"
+
+// ErrNotFound is returned for a blueprint the library doesn't have
+var ErrNotFound = errors.New("blueprint not found")
+
+// Parameter types
+const (
+	TypeString  = "string"
+	TypeName    = "name" // an object name: letters, digits, dots, dashes and underscores
+	TypeIP      = "ip"
+	TypePort    = "port"
+	TypeInteger = "integer"
+	TypeBoolean = "boolean"
+	TypeServers = "servers" // backend servers as ip or ip:port, expanded to pool servers
+)
+
+var parameterTypes = map[string]bool{TypeString: true, TypeName: true, TypeIP: true, TypePort: true, TypeInteger: true, TypeBoolean: true, TypeServers: true}
+
+// Parameter is a value a blueprint is expanded with
+type Parameter struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type"`
+	Description string      `json:"description,omitempty"`
+	Required    bool        `json:"required,omitempty"`
+	Default     interface{} `json:"default,omitempty"` // may refer to earlier parameters, e.g. "${port}"
+	Values      []string    `json:"values,omitempty"`  // allowed values of a string
+}
+
+// Blueprint is a pattern of objects created together
+type Blueprint struct {
+	Name        string                   `json:"name"`
+	Title       string                   `json:"title"`
+	Description string                   `json:"description,omitempty"`
+	Parameters  []Parameter              `json:"parameters"`
+	Objects     map[string][]interface{} `json:"objects"` // export layout with placeholders
+	Source      string                   `json:"source"`  // built-in, or the file relative to blueprints.directory
+}
+
+// blueprintFile is the layout of a file of blueprints
+type blueprintFile struct {
+	Blueprints []Blueprint `json:"blueprints"`
+}
+
+// Library holds the built-in blueprints and those of the blueprints directory
+type Library struct {
+	blueprints []Blueprint
+}
+
+// Load reads the built-in blueprints and the YAML and JSON files of dir, when set. A blueprint
+// of the directory replaces the built-in one of the same name.
+func Load(dir string) (*Library, error) {
+	builtin, err := parseBlueprints(builtinData, SourceBuiltIn)
+	if err != nil {
+		return nil, fmt.Errorf("built-in blueprints: %w", err)
+	}
+	byName := make(map[string]Blueprint)
+	for _, bp := range builtin {
+		byName[strings.ToLower(bp.Name)] = bp
+	}
+
+	if dir != "" {
+		var files []string
+		err := filepath.WalkDir(dir, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			switch strings.ToLower(filepath.Ext(file)) {
+			case ".json", ".yaml", ".yml":
+				if !entry.IsDir() {
+					files = append(files, file)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the blueprints in %s: %w", dir, err)
+		}
+		sort.Strings(files)
+
+		loaded := make(map[string]string)
+		for _, file := range files {
+			rel, _ := filepath.Rel(dir, file)
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", rel, err)
+			}
+			blueprints, err := parseBlueprints(data, rel)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", rel, err)
+			}
+			for _, bp := range blueprints {
+				key := strings.ToLower(bp.Name)
+				if other, ok := loaded[key]; ok {
+					return nil, fmt.Errorf("%s: blueprint %s is already defined in %s", rel, bp.Name, other)
+				}
+				loaded[key] = rel
+				byName[key] = bp
+			}
+		}
+	}
+
+	library := &Library{}
+	for _, bp := range byName {
+		library.blueprints = append(library.blueprints, bp)
+	}
+	sort.Slice(library.blueprints, func(i, j int) bool { return library.blueprints[i].Name < library.blueprints[j].Name })
+	return library, nil
+}
+
+// parseBlueprints reads and checks the blueprints of a file
+func parseBlueprints(data []byte, source string) ([]Blueprint, error) {
+	// YAML reads JSON too; the round trip through JSON gives both the types of decoded JSON
+	var content interface{}
+	if err := yaml.Unmarshal(data, &content); err != nil {
+		return nil, fmt.Errorf("failed to parse: %w", err)
+	}
+	raw, err := json.Marshal(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse: %w", err)
+	}
+	var file blueprintFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("blueprints must be a list of blueprints: %w", err)
+	}
+	for i := range file.Blueprints {
+		file.Blueprints[i].Source = source
+		if err := file.Blueprints[i].check(); err != nil {
+			return nil, err
+		}
+	}
+	return file.Blueprints, nil
+}
+
+// check reports a blueprint that can't be expanded: unknown parameter types, defaults of the
+// wrong type and placeholders of undeclared parameters
+func (bp Blueprint) check() error {
+	if bp.Name == "" {
+		return fmt.Errorf("every blueprint needs a name")
+	}
+	if len(bp.Objects) == 0 {
+		return fmt.Errorf("blueprint %s declares no objects", bp.Name)
+	}
+	declared := make(map[string]bool)
+	for _, param := range bp.Parameters {
+		if param.Name == "" || declared[param.Name] {
+			return fmt.Errorf("blueprint %s: every parameter needs a name of its own", bp.Name)
+		}
+		if !parameterTypes[param.Type] {
+			return fmt.Errorf("blueprint %s: parameter %s has unknown type %q", bp.Name, param.Name, param.Type)
+		}
+		// A default referring to earlier parameters is only known once they are
+		if refs := placeholders(param.Default); len(refs) > 0 {
+			for _, name := range refs {
+				if !declared[name] {
+					return fmt.Errorf("blueprint %s: default of %s refers to ${%s}, not an earlier parameter", bp.Name, param.Name, name)
+				}
+			}
+		} else if param.Default != nil {
+			if _, err := param.Convert(param.Default); err != nil {
+				return fmt.Errorf("blueprint %s: default of %w", bp.Name, err)
+			}
+		}
+		declared[param.Name] = true
+		if param.Type == TypeIP {
+			declared[param.Name+"_type"] = true
+		}
+	}
+	for _, name := range placeholders(bp.Objects) {
+		if !declared[name] {
+			return fmt.Errorf("blueprint %s: ${%s} isn't a parameter", bp.Name, name)
+		}
+	}
+	return nil
+}
+
+// List returns the blueprints by name
+func (l *Library) List() []Blueprint {
+	return append([]Blueprint{}, l.blueprints...)
+}
+
+// Get returns a blueprint by name
+func (l *Library) Get(name string) (Blueprint, error) {
+	for _, bp := range l.blueprints {
+		if strings.EqualFold(bp.Name, name) {
+			return bp, nil
+		}
+	}
+	return Blueprint{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+}
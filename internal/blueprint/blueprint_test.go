@@ -0,0 +1,128 @@
+package blueprint
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	library, err := Load("")
+	require.NoError(t, err)
+	var names []string
+	for _, bp := range library.List() {
+		names = append(names, bp.Name)
+		assert.Equal(t, SourceBuiltIn, bp.Source)
+	}
+	assert.Equal(t, []string{"http-app", "https-app", "l4-tcp-app"}, names)
+
+	// Blueprints of the directory are added, replacing the built-in ones of the same name
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "team.yaml"), []byte(`blueprints:
+  - name: HTTP-APP
+    title: Team HTTP application
+    parameters:
+      - {name: name, type: name, required: true}
+    objects:
+      pool:
+        - name: "${name}-pool"
+`), 0o644))
+	library, err = Load(dir)
+	require.NoError(t, err)
+	assert.Len(t, library.List(), 3)
+	bp, err := library.Get("http-app")
+	require.NoError(t, err)
+	assert.Equal(t, "Team HTTP application", bp.Title)
+	assert.Equal(t, "team.yaml", bp.Source)
+	_, err = library.Get("ftp-app")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	for content, want := range map[string]string{
+		"blueprints:\n  - name: a\n    objects: {pool: [{name: \"${nme}\"}]}\n    parameters: [{name: name, type: name}]\n":    "${nme} isn't a parameter",
+		"blueprints:\n  - name: a\n    objects: {pool: [{name: x}]}\n    parameters: [{name: port, type: socket}]\n":           `unknown type "socket"`,
+		"blueprints:\n  - name: a\n    objects: {pool: [{name: x}]}\n    parameters: [{name: port, type: port, default: 0}]\n": "default of port must be a port",
+		"blueprints:\n  - name: a\n    parameters: []\n":                                                                       "declares no objects",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "team.yaml"), []byte(content), 0o644))
+		_, err = Load(dir)
+		assert.ErrorContains(t, err, want)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	library, err := Load("")
+	require.NoError(t, err)
+	bp, err := library.Get("https-app")
+	require.NoError(t, err)
+
+	configuration, err := bp.Expand(map[string]interface{}{"name": "foo", "vip": "10.1.1.50", "servers": []interface{}{"10.0.0.1", "10.0.0.2:8443"}})
+	require.NoError(t, err)
+	raw, err := json.Marshal(configuration)
+	require.NoError(t, err)
+	var objects map[string][]map[string]interface{}
+	require.NoError(t, json.Unmarshal(raw, &objects))
+	assert.Len(t, objects, 5)
+
+	pool := objects["pool"][0]
+	assert.Equal(t, "foo-pool", pool["name"])
+	assert.Equal(t, 80.0, pool["default_server_port"])
+	assert.Equal(t, "LB_ALGORITHM_LEAST_CONNECTIONS", pool["lb_algorithm"])
+	assert.Equal(t, []interface{}{"/api/healthmonitor?name=foo-hm"}, pool["health_monitor_refs"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"ip": map[string]interface{}{"addr": "10.0.0.1", "type": "V4"}},
+		map[string]interface{}{"ip": map[string]interface{}{"addr": "10.0.0.2", "type": "V4"}, "port": 8443.0},
+	}, pool["servers"])
+	assert.Equal(t, map[string]interface{}{"addr": "10.1.1.50", "type": "V4"}, objects["vsvip"][0]["vip"].([]interface{})[0].(map[string]interface{})["ip_address"])
+	vs := objects["virtualservice"][0]
+	assert.Equal(t, "foo", vs["name"])
+	assert.Equal(t, []interface{}{"/api/sslkeyandcertificate?name=System-Default-Cert"}, vs["ssl_key_and_certificate_refs"])
+	assert.Equal(t, "GET / HTTP/1.0", objects["healthmonitor"][0]["http_monitor"].(map[string]interface{})["http_request"])
+
+	// Defaults may refer to other parameters; values are converted to their type
+	bp, err = library.Get("l4-tcp-app")
+	require.NoError(t, err)
+	configuration, err = bp.Expand(map[string]interface{}{"name": "db", "vip": "2001:db8::10", "port": "5432", "servers": "10.0.0.5, 10.0.0.6", "lb_algorithm": "lb_algorithm_round_robin"})
+	require.NoError(t, err)
+	pool = configuration["pool"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, 5432, pool["default_server_port"])
+	assert.Equal(t, "LB_ALGORITHM_ROUND_ROBIN", pool["lb_algorithm"])
+	assert.Len(t, pool["servers"], 2)
+	vsvip := configuration["vsvip"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "V6", vsvip["vip"].([]interface{})[0].(map[string]interface{})["ip_address"].(map[string]interface{})["type"])
+
+	// Missing and invalid values are reported together
+	_, err = bp.Expand(map[string]interface{}{"name": "my app", "port": 70000, "servers": []interface{}{"10.0.0.5:x"}, "color": "blue"})
+	var paramErr *ParameterError
+	require.ErrorAs(t, err, &paramErr)
+	require.Len(t, paramErr.Missing, 1)
+	assert.Equal(t, "vip", paramErr.Missing[0].Name)
+	assert.Equal(t, []string{
+		"unknown parameter color",
+		`name must be a name of letters, digits, dots, dashes and underscores, not "my app"`,
+		"port must be a port between 1 and 65535, not 70000",
+		`servers: "10.0.0.5:x" has no valid port`,
+	}, paramErr.Invalid)
+	assert.ErrorContains(t, err, "blueprint l4-tcp-app: missing vip; unknown parameter color")
+}
+
+func TestSubstitute(t *testing.T) {
+	vars := map[string]interface{}{"name": "web", "port": 443}
+	value := map[string]interface{}{
+		"name":        "${name}-pool",
+		"port":        "${port}",
+		"description": "${description}",
+		"refs":        []interface{}{"${missing}", "/api/pool?name=${name}"},
+		"note":        "${name} on ${port}${missing}",
+	}
+	assert.Equal(t, map[string]interface{}{
+		"name": "web-pool",
+		"port": 443,
+		"refs": []interface{}{"/api/pool?name=web"},
+		"note": "web on 443",
+	}, substitute(value, vars))
+	assert.Equal(t, []string{"description", "missing", "name", "port"}, placeholders(value))
+}
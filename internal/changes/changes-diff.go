@@ -0,0 +1,86 @@
+package changes
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// ignoredFields change with every write and aren't worth reporting
+var ignoredFields = map[string]bool{
+	"_last_modified": true,
+	"url":            true,
+	"uuid":           true,
+}
+
+// FieldChange is a field a change set, changed or removed. Fields of nested objects are named
+// by their path, e.g. servers[1].enabled.
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before,omitempty"` // none when the change added the field
+	After  interface{} `json:"after,omitempty"`  // none when the change removed the field
+}
+
+// Diff returns the fields that differ between the object before and after a change, in field
+// order. Nested objects and lists of the same length are compared field by field; a creation
+// or deletion lists the top level fields of the object.
+func Diff(before, after json.RawMessage) []FieldChange {
+	var old, updated map[string]interface{}
+	if len(before) > 0 {
+		_ = json.Unmarshal(before, &old)
+	}
+	if len(after) > 0 {
+		_ = json.Unmarshal(after, &updated)
+	}
+	diff := []FieldChange{}
+	diffObjects("", old, updated, &diff)
+	return diff
+}
+
+// diffObjects adds the fields that differ between two objects
+func diffObjects(path string, before, after map[string]interface{}, diff *[]FieldChange) {
+	keys := make([]string, 0, len(before)+len(after))
+	for key := range before {
+		keys = append(keys, key)
+	}
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if path == "" && ignoredFields[key] {
+			continue
+		}
+		field := key
+		if path != "" {
+			field = path + "." + key
+		}
+		diffValues(field, before[key], after[key], diff)
+	}
+}
+
+// diffValues adds a field whose value differs, or the fields of the objects or lists it holds
+// that do
+func diffValues(field string, before, after interface{}, diff *[]FieldChange) {
+	if reflect.DeepEqual(before, after) {
+		return
+	}
+	switch old := before.(type) {
+	case map[string]interface{}:
+		if updated, ok := after.(map[string]interface{}); ok {
+			diffObjects(field, old, updated, diff)
+			return
+		}
+	case []interface{}:
+		if updated, ok := after.([]interface{}); ok && len(updated) == len(old) {
+			for i := range old {
+				diffValues(fmt.Sprintf("%s[%d]", field, i), old[i], updated[i], diff)
+			}
+			return
+		}
+	}
+	*diff = append(*diff, FieldChange{Field: field, Before: before, After: after})
+}
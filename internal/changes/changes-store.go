@@ -0,0 +1,211 @@
+// Package changes keeps the history of the writes made through the agent: the object each of
+// them created, changed or deleted, as it was before and after, so what changed can be shown
+// field by field and a change can be rolled back by restoring the object.
+package changes
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"aviagent/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// defaultMaxChanges is how many changes are kept when the configuration doesn't say
+const defaultMaxChanges = 1000
+
+// Change actions
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// Change is a write of a controller object, with the object as it was before and after
+type Change struct {
+	ID           string          `json:"id"`
+	Tool         string          `json:"tool"`
+	Action       string          `json:"action"`         // create, update or delete
+	URL          string          `json:"url"`            // the object's path through the Avi API proxy
+	Name         string          `json:"name,omitempty"` // the object's name, before the change for a deletion
+	Operator     string          `json:"operator,omitempty"`
+	Session      string          `json:"session,omitempty"`
+	Receipt      string          `json:"receipt,omitempty"`
+	Version      string          `json:"version,omitempty"`     // the object's _last_modified after the change
+	Before       json.RawMessage `json:"before,omitempty"`      // the object before the change, none for a creation
+	After        json.RawMessage `json:"after,omitempty"`       // the object after the change, none for a deletion
+	RollbackOf   string          `json:"rollback_of,omitempty"` // the change this one rolled back
+	Time         time.Time       `json:"time"`
+	RolledBack   *time.Time      `json:"rolled_back,omitempty"`
+	RolledBackBy string          `json:"rolled_back_by,omitempty"` // the operator who rolled it back
+}
+
+// Filter selects changes from the history; empty fields match every change
+type Filter struct {
+	Object   string // name or UUID of the changed object
+	Operator string
+	Tool     string
+	Since    time.Time
+	Limit    int // most recent changes returned, all of them when 0
+}
+
+// matches reports whether a change passes the filter
+func (f Filter) matches(change *Change) bool {
+	if f.Object != "" && !strings.EqualFold(change.Name, f.Object) && path.Base(change.URL) != f.Object {
+		return false
+	}
+	if f.Operator != "" && !strings.EqualFold(change.Operator, f.Operator) {
+		return false
+	}
+	if f.Tool != "" && change.Tool != f.Tool {
+		return false
+	}
+	return f.Since.IsZero() || !change.Time.Before(f.Since)
+}
+
+// Store keeps the changes in memory and saves them to a JSON file after every change when one is
+// configured
+type Store struct {
+	mu         sync.Mutex
+	changes    []*Change // oldest first
+	file       string
+	maxChanges int
+	seq        int
+	logger     *zap.Logger
+}
+
+// NewStore creates the change history, loading the state file when one is configured
+func NewStore(cfg config.ChangesConfig, logger *zap.Logger) (*Store, error) {
+	s := &Store{
+		file:       cfg.StateFile,
+		maxChanges: cfg.MaxChanges,
+		logger:     logger,
+	}
+	if s.maxChanges <= 0 {
+		s.maxChanges = defaultMaxChanges
+	}
+	if s.file == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(s.file)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read change history %s: %w", s.file, err)
+	}
+	if err := json.Unmarshal(data, &s.changes); err != nil {
+		return nil, fmt.Errorf("failed to parse change history %s: %w", s.file, err)
+	}
+	for _, change := range s.changes {
+		if n, err := strconv.Atoi(strings.TrimPrefix(change.ID, "chg-")); err == nil && n > s.seq {
+			s.seq = n
+		}
+	}
+	return s, nil
+}
+
+// Record adds a change to the history
+func (s *Store) Record(change Change) Change {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	change.ID = fmt.Sprintf("chg-%d", s.seq)
+	if change.Time.IsZero() {
+		change.Time = time.Now().UTC()
+	}
+	change.RolledBack, change.RolledBackBy = nil, ""
+	s.changes = append(s.changes, &change)
+	if excess := len(s.changes) - s.maxChanges; excess > 0 {
+		s.changes = append(s.changes[:0], s.changes[excess:]...)
+	}
+	s.save()
+	return change
+}
+
+// Get returns a change
+func (s *Store) Get(id string) (Change, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, change := range s.changes {
+		if change.ID == id {
+			return *change, true
+		}
+	}
+	return Change{}, false
+}
+
+// List returns the changes the filter selects, most recent first
+func (s *Store) List(filter Filter) []Change {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changes := []Change{}
+	for i := len(s.changes) - 1; i >= 0 && (filter.Limit <= 0 || len(changes) < filter.Limit); i-- {
+		if filter.matches(s.changes[i]) {
+			changes = append(changes, *s.changes[i])
+		}
+	}
+	return changes
+}
+
+// Latest returns the most recent change of an operator, or of a session when the operator isn't
+// known, that wasn't rolled back. Rollbacks are left out, so undoing the last change again and
+// again walks back through the history.
+func (s *Store) Latest(operator, session string) (Change, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.changes) - 1; i >= 0; i-- {
+		change := s.changes[i]
+		if change.RolledBack != nil || change.RollbackOf != "" {
+			continue
+		}
+		if operator != "" && strings.EqualFold(change.Operator, operator) || operator == "" && session != "" && change.Session == session {
+			return *change, true
+		}
+	}
+	return Change{}, false
+}
+
+// MarkRolledBack records that an operator rolled a change back
+func (s *Store) MarkRolledBack(id, operator string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, change := range s.changes {
+		if change.ID != id {
+			continue
+		}
+		if change.RolledBack != nil {
+			return fmt.Errorf("change %s was already rolled back at %s", id, change.RolledBack.Format(time.RFC3339))
+		}
+		now := time.Now().UTC()
+		change.RolledBack, change.RolledBackBy = &now, operator
+		s.save()
+		return nil
+	}
+	return fmt.Errorf("change %s not found", id)
+}
+
+// save writes the changes to the state file, if one is configured
+func (s *Store) save() {
+	if s.file == "" {
+		return
+	}
+	data, err := json.MarshalIndent(s.changes, "", "  ")
+	if err == nil {
+		tmp := filepath.Join(filepath.Dir(s.file), "."+filepath.Base(s.file)+".tmp")
+		if err = os.WriteFile(tmp, data, 0600); err == nil {
+			err = os.Rename(tmp, s.file)
+		}
+	}
+	if err != nil {
+		s.logger.Error("Failed to save change history", zap.String("file", s.file), zap.Error(err))
+	}
+}
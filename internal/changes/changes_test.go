@@ -0,0 +1,98 @@
+package changes
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"aviagent/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestStore(t *testing.T) {
+	cfg := config.ChangesConfig{StateFile: filepath.Join(t.TempDir(), "changes.json"), MaxChanges: 4}
+	store, err := NewStore(cfg, zaptest.NewLogger(t))
+	require.NoError(t, err)
+
+	update := store.Record(Change{Tool: "update_pool", Action: ActionUpdate, URL: "/api/v1/avi/pool/pool-1", Name: "web", Operator: "alice", Session: "s1", Before: json.RawMessage(`{"name":"web"}`)})
+	assert.Equal(t, "chg-1", update.ID)
+	assert.False(t, update.Time.IsZero())
+	deletion := store.Record(Change{Tool: "delete_pool", Action: ActionDelete, URL: "/api/v1/avi/pool/pool-2", Name: "api", Operator: "alice", Before: json.RawMessage(`{}`)})
+	other := store.Record(Change{Tool: "update_pool", URL: "/api/v1/avi/pool/pool-3", Operator: "bob", Session: "s2", Before: json.RawMessage(`{}`)})
+
+	// The last change of an operator, or of a session without one
+	latest, ok := store.Latest("ALICE", "")
+	require.True(t, ok)
+	assert.Equal(t, deletion.ID, latest.ID)
+	latest, ok = store.Latest("", "s2")
+	require.True(t, ok)
+	assert.Equal(t, other.ID, latest.ID)
+	_, ok = store.Latest("carol", "s3")
+	assert.False(t, ok)
+
+	// Rolled back changes and rollbacks are skipped, so undoing again goes further back
+	rollback := store.Record(Change{Tool: "rollback_change", URL: deletion.URL, Operator: "alice", RollbackOf: deletion.ID, Before: json.RawMessage(`{}`)})
+	require.NoError(t, store.MarkRolledBack(deletion.ID, "alice"))
+	assert.ErrorContains(t, store.MarkRolledBack(deletion.ID, "bob"), "already rolled back")
+	assert.ErrorContains(t, store.MarkRolledBack("chg-9", "alice"), "not found")
+
+	// Changes are listed most recent first, by object name or UUID, operator or tool
+	assert.Equal(t, []string{rollback.ID, other.ID, deletion.ID, update.ID}, ids(store.List(Filter{})))
+	assert.Equal(t, []string{update.ID}, ids(store.List(Filter{Object: "WEB"})))
+	assert.Equal(t, []string{rollback.ID, deletion.ID}, ids(store.List(Filter{Object: "pool-2"})))
+	assert.Equal(t, []string{other.ID}, ids(store.List(Filter{Operator: "bob", Tool: "update_pool"})))
+	assert.Equal(t, []string{rollback.ID, other.ID}, ids(store.List(Filter{Limit: 2})))
+	assert.Empty(t, store.List(Filter{Since: time.Now().Add(time.Hour)}))
+
+	// Beyond the limit the oldest changes are dropped
+	store.Record(Change{Tool: "update_pool", Operator: "bob"})
+	assert.Equal(t, []string{"chg-5", rollback.ID, other.ID, deletion.ID}, ids(store.List(Filter{})))
+	_, ok = store.Latest("alice", "")
+	assert.False(t, ok)
+
+	// The history survives a restart; numbering continues
+	store, err = NewStore(cfg, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	restored, ok := store.Get(rollback.ID)
+	require.True(t, ok)
+	assert.Equal(t, deletion.ID, restored.RollbackOf)
+	restored, ok = store.Get(deletion.ID)
+	require.True(t, ok)
+	require.NotNil(t, restored.RolledBack)
+	assert.Equal(t, "alice", restored.RolledBackBy)
+	assert.JSONEq(t, `{}`, string(restored.Before))
+	assert.Equal(t, "chg-6", store.Record(Change{Tool: "update_pool"}).ID)
+}
+
+func TestDiff(t *testing.T) {
+	before := json.RawMessage(`{"name": "web", "uuid": "pool-1", "_last_modified": "1", "lb_algorithm": "LB_ALGORITHM_ROUND_ROBIN",
+		"servers": [{"ip": {"addr": "10.0.0.1"}, "enabled": true}, {"ip": {"addr": "10.0.0.2"}, "enabled": true}],
+		"health_monitor_refs": ["/api/healthmonitor/hm-1"], "description": "old"}`)
+	after := json.RawMessage(`{"name": "web", "uuid": "pool-1", "_last_modified": "2", "lb_algorithm": "LB_ALGORITHM_LEAST_CONNECTIONS",
+		"servers": [{"ip": {"addr": "10.0.0.1"}, "enabled": true}, {"ip": {"addr": "10.0.0.2"}, "enabled": false}],
+		"health_monitor_refs": ["/api/healthmonitor/hm-1", "/api/healthmonitor/hm-2"], "enabled": true}`)
+
+	assert.Equal(t, []FieldChange{
+		{Field: "description", Before: "old"},
+		{Field: "enabled", After: true},
+		{Field: "health_monitor_refs", Before: []interface{}{"/api/healthmonitor/hm-1"}, After: []interface{}{"/api/healthmonitor/hm-1", "/api/healthmonitor/hm-2"}},
+		{Field: "lb_algorithm", Before: "LB_ALGORITHM_ROUND_ROBIN", After: "LB_ALGORITHM_LEAST_CONNECTIONS"},
+		{Field: "servers[1].enabled", Before: true, After: false},
+	}, Diff(before, after))
+
+	// A creation lists the fields it set; nothing changed is an empty diff
+	assert.Equal(t, []FieldChange{{Field: "name", After: "api"}}, Diff(nil, json.RawMessage(`{"name": "api", "uuid": "pool-2"}`)))
+	assert.Equal(t, []FieldChange{}, Diff(before, before))
+}
+
+func ids(changes []Change) []string {
+	ids := make([]string, len(changes))
+	for i, change := range changes {
+		ids[i] = change.ID
+	}
+	return ids
+}
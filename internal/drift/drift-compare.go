@@ -0,0 +1,166 @@
+package drift
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"aviagent/pkg/avi"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ignoredFields are assigned by the controller and never declared meaningfully
+var ignoredFields = map[string]bool{"uuid": true, "url": true, "_last_modified": true}
+
+// declaredObject is an object of the declared configuration with the file it is declared in
+type declaredObject struct {
+	avi.ConfigObject
+	File string
+}
+
+// loadDeclared reads the objects declared in the JSON and YAML files of a directory and its
+// subdirectories. Each file is in export layout, a map of object type to a list of objects, as
+// apply_configuration takes it. An object is declared once, by type and name.
+func loadDeclared(dir string) ([]declaredObject, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".json", ".yaml", ".yml":
+			if !entry.IsDir() {
+				files = append(files, file)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the declared configuration in %s: %w", dir, err)
+	}
+	sort.Strings(files)
+
+	var objects []declaredObject
+	declared := make(map[string]string)
+	for _, file := range files {
+		rel, _ := filepath.Rel(dir, file)
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", rel, err)
+		}
+		// YAML reads JSON too; the round trip through JSON gives both the types of decoded JSON
+		var content interface{}
+		if err := yaml.Unmarshal(data, &content); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", rel, err)
+		}
+		if content == nil {
+			continue
+		}
+		raw, err := json.Marshal(content)
+		if err == nil {
+			err = json.Unmarshal(raw, &content)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", rel, err)
+		}
+		parsed, err := avi.ParseConfiguration(content)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", rel, err)
+		}
+		for _, object := range parsed {
+			if object.Data == nil || object.Name == "" {
+				return nil, fmt.Errorf("%s: every %s needs a name", rel, object.Type)
+			}
+			key := object.Type + "/" + object.Name
+			if other, ok := declared[key]; ok {
+				return nil, fmt.Errorf("%s: %s %s is already declared in %s", rel, object.Type, object.Name, other)
+			}
+			declared[key] = rel
+			objects = append(objects, declaredObject{ConfigObject: object, File: rel})
+		}
+	}
+	return objects, nil
+}
+
+// compareObject returns the declared fields of an object whose live value differs. Only the
+// declared fields are compared, as the controller fills in defaults for the others.
+func compareObject(declared, live map[string]interface{}) []FieldDrift {
+	drift := []FieldDrift{}
+	for _, key := range sortedKeys(declared) {
+		if !ignoredFields[key] {
+			compareValue(key, isRef(key), declared[key], live[key], &drift)
+		}
+	}
+	return drift
+}
+
+// compareValue adds a declared field whose live value differs, or the fields of the objects or
+// lists it holds that do
+func compareValue(field string, ref bool, declared, live interface{}, drift *[]FieldDrift) {
+	switch want := declared.(type) {
+	case map[string]interface{}:
+		if got, ok := live.(map[string]interface{}); ok {
+			for _, key := range sortedKeys(want) {
+				compareValue(field+"."+key, isRef(key), want[key], got[key], drift)
+			}
+			return
+		}
+	case []interface{}:
+		if got, ok := live.([]interface{}); ok && len(got) == len(want) {
+			for i := range want {
+				compareValue(fmt.Sprintf("%s[%d]", field, i), ref, want[i], got[i], drift)
+			}
+			return
+		}
+	case string:
+		if got, ok := live.(string); ok && ref && refEqual(want, got) {
+			return
+		}
+	}
+	if !reflect.DeepEqual(declared, live) {
+		*drift = append(*drift, FieldDrift{Field: field, Declared: declared, Live: live})
+	}
+}
+
+// isRef reports whether a field refers to other objects
+func isRef(field string) bool {
+	return strings.HasSuffix(field, "_ref") || strings.HasSuffix(field, "_refs")
+}
+
+// refEqual reports whether a declared reference names the object a live one refers to. Live
+// references are read with their names, https://controller/api/pool/pool-1#web; declared ones
+// may be written the same way, as /api/pool?name=web, /api/pool/pool-1 or as the name alone.
+func refEqual(declared, live string) bool {
+	liveURL, liveName, _ := strings.Cut(live, "#")
+	if declared == live || declared == liveURL {
+		return true
+	}
+	if _, name, ok := strings.Cut(declared, "name="); ok {
+		name, _, _ = strings.Cut(name, "&")
+		return name == liveName
+	}
+	if _, name, ok := strings.Cut(declared, "#"); ok {
+		return name == liveName
+	}
+	if !strings.Contains(declared, "/") {
+		return declared == liveName
+	}
+	return path.Base(declared) == path.Base(liveURL)
+}
+
+// sortedKeys returns the keys of an object in order
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,253 @@
+// Package drift compares the objects declared in a directory of configuration files with the
+// live controller, so changes made outside the declared configuration are noticed: fields set to
+// other values and objects that are missing.
+package drift
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"aviagent/internal/notify"
+	"aviagent/pkg/avi"
+	"aviagent/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// checkTimeout bounds a periodic check
+const checkTimeout = 2 * time.Minute
+
+// Object states
+const (
+	StateInSync  = "in_sync"
+	StateDrifted = "drifted"
+	StateMissing = "missing" // declared but not on the controller
+	StateError   = "error"   // couldn't be read from the controller
+)
+
+// FieldDrift is a declared field whose live value differs
+type FieldDrift struct {
+	Field    string      `json:"field"`
+	Declared interface{} `json:"declared"`
+	Live     interface{} `json:"live,omitempty"` // none when the controller doesn't set the field
+}
+
+// ObjectDrift is the comparison of one declared object with the controller
+type ObjectDrift struct {
+	Type   string       `json:"type"`
+	Name   string       `json:"name"`
+	File   string       `json:"file"` // relative to the directory
+	UUID   string       `json:"uuid,omitempty"`
+	State  string       `json:"state"`
+	Fields []FieldDrift `json:"fields,omitempty"`
+	Error  string       `json:"error,omitempty"`
+}
+
+// Report is the outcome of a drift check, the objects in the order they are declared
+type Report struct {
+	Checked   time.Time     `json:"checked"`
+	Directory string        `json:"directory"`
+	Objects   int           `json:"objects"`
+	InSync    int           `json:"in_sync"`
+	Drifted   int           `json:"drifted"`
+	Missing   int           `json:"missing"`
+	Errors    int           `json:"errors"`
+	Results   []ObjectDrift `json:"results"`
+}
+
+// HasDrift reports whether an object differs from its declaration or is missing
+func (r *Report) HasDrift() bool {
+	return r.Drifted > 0 || r.Missing > 0
+}
+
+// Summary describes the drift in a sentence per object, for notifications
+func (r *Report) Summary() string {
+	var lines []string
+	for _, result := range r.Results {
+		switch result.State {
+		case StateDrifted:
+			fields := make([]string, len(result.Fields))
+			for i, field := range result.Fields {
+				fields[i] = field.Field
+			}
+			lines = append(lines, fmt.Sprintf("%s %s differs in %s", result.Type, result.Name, strings.Join(fields, ", ")))
+		case StateMissing:
+			lines = append(lines, fmt.Sprintf("%s %s is missing", result.Type, result.Name))
+		}
+	}
+	if len(lines) == 0 {
+		return fmt.Sprintf("The %d declared objects match the controller.", r.Objects)
+	}
+	return fmt.Sprintf("%d of the %d objects declared in %s differ from the controller: %s.", len(lines), r.Objects, r.Directory, strings.Join(lines, "; "))
+}
+
+// signature identifies the drift of a report, so the same drift is only notified once
+func (r *Report) signature() string {
+	var parts []string
+	for _, result := range r.Results {
+		if result.State != StateDrifted && result.State != StateMissing {
+			continue
+		}
+		part := result.Type + "/" + result.Name + ":" + result.State
+		for _, field := range result.Fields {
+			part += fmt.Sprintf(" %s=%v", field.Field, field.Live)
+		}
+		parts = append(parts, part)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "\n")
+}
+
+// Detector checks the declared configuration against the controller, on request and every
+// interval, and publishes new drift to the notification channels subscribed to drift.detected
+type Detector struct {
+	cfg      config.DriftConfig
+	exec     avi.GenericExecutor
+	notifier *notify.Notifier
+	logger   *zap.Logger
+	now      func() time.Time
+
+	mu       sync.Mutex
+	last     *Report
+	notified string // signature of the drift last published
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New creates a drift detector for the directory of the configuration. Call Start to check it
+// periodically.
+func New(cfg config.DriftConfig, exec avi.GenericExecutor, notifier *notify.Notifier, logger *zap.Logger) (*Detector, error) {
+	info, err := os.Stat(cfg.Directory)
+	if err != nil {
+		return nil, fmt.Errorf("drift directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("drift directory %s is not a directory", cfg.Directory)
+	}
+	return &Detector{cfg: cfg, exec: exec, notifier: notifier, logger: logger, now: time.Now}, nil
+}
+
+// Start checks the configuration right away and then every interval, in the background until
+// Stop is called. Without an interval the configuration is only checked on request.
+func (d *Detector) Start() {
+	if d.cfg.Interval <= 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+	d.done = make(chan struct{})
+	go d.loop(ctx, time.Duration(d.cfg.Interval)*time.Second)
+	d.logger.Info("Drift detection started", zap.String("directory", d.cfg.Directory), zap.Int("interval", d.cfg.Interval))
+}
+
+// Stop stops the periodic checks and waits for a running one to finish
+func (d *Detector) Stop() {
+	if d.cancel == nil {
+		return
+	}
+	d.cancel()
+	<-d.done
+}
+
+// loop checks the configuration every interval
+func (d *Detector) loop(ctx context.Context, interval time.Duration) {
+	defer close(d.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		if _, err := d.Check(checkCtx); err != nil && ctx.Err() == nil {
+			d.logger.Error("Drift check failed", zap.Error(err))
+		}
+		cancel()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Last returns the report of the last check, nil before the first
+func (d *Detector) Last() *Report {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.last
+}
+
+// Check compares the declared configuration with the controller now. Drift that differs from
+// the drift last published is published; the same drift found again isn't.
+func (d *Detector) Check(ctx context.Context) (*Report, error) {
+	objects, err := loadDeclared(d.cfg.Directory)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{Checked: d.now().UTC(), Directory: d.cfg.Directory, Objects: len(objects), Results: []ObjectDrift{}}
+	for _, object := range objects {
+		result := d.compare(ctx, object)
+		switch result.State {
+		case StateInSync:
+			report.InSync++
+		case StateDrifted:
+			report.Drifted++
+		case StateMissing:
+			report.Missing++
+		default:
+			report.Errors++
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	d.mu.Lock()
+	d.last = report
+	signature := report.signature()
+	publish := signature != "" && signature != d.notified
+	d.notified = signature
+	d.mu.Unlock()
+
+	if publish && d.notifier != nil {
+		// Publish logs the channels that failed
+		_ = d.notifier.Publish(ctx, notify.Event{
+			Type:     notify.EventDriftDetected,
+			Title:    fmt.Sprintf("Configuration drift: %d objects", report.Drifted+report.Missing),
+			Summary:  report.Summary(),
+			Severity: notify.SeverityWarning,
+			Source:   "drift",
+			Time:     report.Checked,
+			Data:     report,
+		})
+	}
+	return report, nil
+}
+
+// compare reads a declared object from the controller by name and compares it
+func (d *Detector) compare(ctx context.Context, object declaredObject) ObjectDrift {
+	result := ObjectDrift{Type: object.Type, Name: object.Name, File: object.File}
+	raw, err := d.exec.ExecuteGenericOperation(ctx, "GET", "/"+object.Type, nil, map[string]string{"name": object.Name, "include_name": "true"})
+	if err != nil {
+		result.State, result.Error = StateError, err.Error()
+		return result
+	}
+	collection, _ := raw.(map[string]interface{})
+	items, _ := collection["results"].([]interface{})
+	for _, item := range items {
+		live, _ := item.(map[string]interface{})
+		if name, _ := live["name"].(string); name != object.Name {
+			continue
+		}
+		result.UUID, _ = live["uuid"].(string)
+		result.State = StateInSync
+		if result.Fields = compareObject(object.Data, live); len(result.Fields) > 0 {
+			result.State = StateDrifted
+		}
+		return result
+	}
+	result.State = StateMissing
+	return result
+}
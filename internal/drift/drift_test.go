@@ -0,0 +1,157 @@
+package drift
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"aviagent/internal/notify"
+	"aviagent/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeExecutor serves the live objects of a type, filtered by name
+type fakeExecutor map[string][]interface{}
+
+func (f fakeExecutor) ExecuteGenericOperation(ctx context.Context, method, endpoint string, body interface{}, params map[string]string) (interface{}, error) {
+	results := []interface{}{}
+	for _, object := range f[endpoint] {
+		if object.(map[string]interface{})["name"] == params["name"] {
+			results = append(results, object)
+		}
+	}
+	return map[string]interface{}{"count": len(results), "results": results}, nil
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+}
+
+func TestLoadDeclared(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "pools.yaml", "pool:\n  - name: web\n    lb_algorithm: LB_ALGORITHM_ROUND_ROBIN\n    servers:\n      - ip: {addr: 10.0.0.1, type: V4}\n")
+	writeFile(t, dir, "vs/shop.json", `{"virtualservice": [{"name": "shop", "enabled": true, "pool_ref": "/api/pool?name=web"}], "META": {"version": "22.1.3"}}`)
+	writeFile(t, dir, "README.md", "not configuration")
+
+	objects, err := loadDeclared(dir)
+	require.NoError(t, err)
+	require.Len(t, objects, 2)
+	assert.Equal(t, "pool", objects[0].Type)
+	assert.Equal(t, "web", objects[0].Name)
+	assert.Equal(t, "pools.yaml", objects[0].File)
+	assert.Equal(t, filepath.Join("vs", "shop.json"), objects[1].File)
+	assert.Equal(t, true, objects[1].Data["enabled"])
+
+	// An object is declared once; each needs a name
+	writeFile(t, dir, "vs/more.yml", "pool:\n  - name: web\n")
+	_, err = loadDeclared(dir)
+	assert.ErrorContains(t, err, "pool web is already declared in pools.yaml")
+	writeFile(t, dir, "vs/more.yml", "pool:\n  - lb_algorithm: LB_ALGORITHM_ROUND_ROBIN\n")
+	_, err = loadDeclared(dir)
+	assert.ErrorContains(t, err, "every pool needs a name")
+}
+
+func TestCompareObject(t *testing.T) {
+	declared := map[string]interface{}{
+		"name": "web", "lb_algorithm": "LB_ALGORITHM_ROUND_ROBIN", "uuid": "ignored",
+		"servers":                             []interface{}{map[string]interface{}{"ip": map[string]interface{}{"addr": "10.0.0.1"}, "enabled": true}},
+		"health_monitor_refs":                 []interface{}{"/api/healthmonitor?name=http"},
+		"application_persistence_profile_ref": "cookie",
+		"description":                         "shop pool",
+	}
+	live := map[string]interface{}{
+		"name": "web", "uuid": "pool-1", "lb_algorithm": "LB_ALGORITHM_LEAST_CONNECTIONS", "default_server_port": 80.0,
+		"servers":                             []interface{}{map[string]interface{}{"ip": map[string]interface{}{"addr": "10.0.0.1", "type": "V4"}, "enabled": false}},
+		"health_monitor_refs":                 []interface{}{"https://controller/api/healthmonitor/hm-1#http"},
+		"application_persistence_profile_ref": "https://controller/api/applicationpersistenceprofile/app-1#cookie",
+	}
+	assert.Equal(t, []FieldDrift{
+		{Field: "description", Declared: "shop pool"},
+		{Field: "lb_algorithm", Declared: "LB_ALGORITHM_ROUND_ROBIN", Live: "LB_ALGORITHM_LEAST_CONNECTIONS"},
+		{Field: "servers[0].enabled", Declared: true, Live: false},
+	}, compareObject(declared, live))
+}
+
+func TestRefEqual(t *testing.T) {
+	live := "https://controller/api/pool/pool-1#web"
+	for _, declared := range []string{live, "https://controller/api/pool/pool-1", "/api/pool?name=web", "/api/pool?name=web&tenant=admin", "/api/pool/pool-1", "/api/pool#web", "web"} {
+		assert.True(t, refEqual(declared, live), declared)
+	}
+	for _, declared := range []string{"/api/pool?name=api", "/api/pool/pool-2", "api"} {
+		assert.False(t, refEqual(declared, live), declared)
+	}
+}
+
+func TestDetector_Check(t *testing.T) {
+	var events []notify.Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event notify.Event
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		events = append(events, event)
+	}))
+	defer server.Close()
+	notifier, err := notify.New(config.NotificationsConfig{Channels: map[string]config.NotificationChannel{
+		"ops": {URL: server.URL, Events: []string{notify.EventDriftDetected}},
+	}}, zap.NewNop())
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	writeFile(t, dir, "config.yaml", "pool:\n  - name: web\n    lb_algorithm: LB_ALGORITHM_ROUND_ROBIN\n  - name: api\n    enabled: true\nvirtualservice:\n  - name: shop\n    pool_ref: /api/pool?name=web\n")
+	exec := fakeExecutor{
+		"/pool": {
+			map[string]interface{}{"name": "web", "uuid": "pool-1", "lb_algorithm": "LB_ALGORITHM_LEAST_CONNECTIONS"},
+			map[string]interface{}{"name": "api", "uuid": "pool-2", "enabled": true},
+		},
+	}
+	_, err = New(config.DriftConfig{Directory: filepath.Join(dir, "missing")}, exec, notifier, zap.NewNop())
+	assert.ErrorContains(t, err, "drift directory")
+	detector, err := New(config.DriftConfig{Enabled: true, Directory: dir}, exec, notifier, zap.NewNop())
+	require.NoError(t, err)
+	detector.now = func() time.Time { return time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC) }
+	assert.Nil(t, detector.Last())
+
+	report, err := detector.Check(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Objects)
+	assert.Equal(t, 1, report.InSync)
+	assert.Equal(t, 1, report.Drifted)
+	assert.Equal(t, 1, report.Missing)
+	assert.True(t, report.HasDrift())
+	assert.Equal(t, ObjectDrift{Type: "pool", Name: "web", File: "config.yaml", UUID: "pool-1", State: StateDrifted,
+		Fields: []FieldDrift{{Field: "lb_algorithm", Declared: "LB_ALGORITHM_ROUND_ROBIN", Live: "LB_ALGORITHM_LEAST_CONNECTIONS"}}}, report.Results[0])
+	assert.Equal(t, StateMissing, report.Results[2].State)
+	assert.Same(t, report, detector.Last())
+	require.Len(t, events, 1)
+	assert.Equal(t, notify.EventDriftDetected, events[0].Type)
+	assert.Contains(t, events[0].Summary, "pool web differs in lb_algorithm; virtualservice shop is missing")
+
+	// The same drift is notified once, new drift again
+	_, err = detector.Check(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+	exec["/virtualservice"] = []interface{}{map[string]interface{}{"name": "shop", "uuid": "vs-1", "pool_ref": "https://controller/api/pool/pool-1#web"}}
+	report, err = detector.Check(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.InSync)
+	assert.Len(t, events, 2)
+
+	// Drift that clears and returns is notified again
+	exec["/pool"][0] = map[string]interface{}{"name": "web", "uuid": "pool-1", "lb_algorithm": "LB_ALGORITHM_ROUND_ROBIN"}
+	report, err = detector.Check(context.Background())
+	require.NoError(t, err)
+	assert.False(t, report.HasDrift())
+	exec["/pool"][0] = map[string]interface{}{"name": "web", "uuid": "pool-1", "lb_algorithm": "LB_ALGORITHM_LEAST_CONNECTIONS"}
+	_, err = detector.Check(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, events, 3)
+}
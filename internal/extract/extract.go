@@ -0,0 +1,286 @@
+// Package extract pulls load balancer entities (object names, addresses, ports, hostnames) and the
+// requested change out of free text such as tickets and emails.
+package extract
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"aviagent/pkg/llm"
+)
+
+// Intents a text can express
+const (
+	IntentInvestigate  = "investigate"   // something is slow, failing or down
+	IntentEnable       = "enable"        // bring an object or server back into service
+	IntentDisable      = "disable"       // take an object or server out of service
+	IntentAddServer    = "add_server"    // add a server to a pool
+	IntentRemoveServer = "remove_server" // remove or decommission a pool server
+	IntentCreate       = "create"        // create a new virtual service, pool or monitor
+	IntentDelete       = "delete"        // delete an object
+	IntentCertificate  = "certificate"   // renew, replace or check a certificate
+	IntentOther        = "other"
+)
+
+// Intents lists the intents in the order the keyword rules check them
+var Intents = []string{IntentRemoveServer, IntentAddServer, IntentCertificate, IntentDisable, IntentEnable, IntentDelete, IntentCreate, IntentInvestigate, IntentOther}
+
+// Object types
+const (
+	TypeVirtualService = "virtual_service"
+	TypePool           = "pool"
+	TypeHealthMonitor  = "health_monitor"
+	TypeServiceEngine  = "service_engine"
+	TypeUnknown        = "unknown"
+)
+
+// maxTextLength limits the text sent to the model
+const maxTextLength = 20000
+
+// Entities are what a text refers to and asks for
+type Entities struct {
+	Intent    string   `json:"intent"`
+	Summary   string   `json:"summary,omitempty"` // one-line restatement of the request, written by the model
+	Objects   []Object `json:"objects"`
+	Addresses []string `json:"addresses"`
+	Ports     []int    `json:"ports"`
+	Hostnames []string `json:"hostnames"`
+	Source    string   `json:"source"` // llm or rules
+}
+
+// Object is a load balancer object named in the text
+type Object struct {
+	Type string `json:"type"` // virtual_service, pool, health_monitor, service_engine or unknown
+	Name string `json:"name"`
+}
+
+// extractPrompt instructs the model extracting entities
+const extractPrompt = `You extract structured data from tickets and emails sent to the team running a VMware Avi load balancer.
+Reply with a single JSON object and nothing else:
+{"intent": "...", "summary": "...", "objects": [{"type": "...", "name": "..."}], "addresses": ["..."], "ports": [443], "hostnames": ["..."]}
+intent is one of: investigate, enable, disable, add_server, remove_server, create, delete, certificate, other.
+summary restates the request in one sentence.
+objects are virtual services, pools, health monitors and service engines named in the text; type is virtual_service, pool, health_monitor, service_engine or unknown.
+addresses are IP addresses, ports are TCP/UDP ports and hostnames are DNS names, exactly as written in the text.
+Only include values that appear in the text; use empty lists when there are none.`
+
+// Extract finds the entities of a text. The model extracts them when completer is set; values it
+// returns that don't appear in the text are dropped, and the addresses, ports and hostnames the
+// rules find are added. When the model fails the rules alone are used.
+func Extract(ctx context.Context, completer llm.Completer, model, text string) *Entities {
+	if len(text) > maxTextLength {
+		text = text[:maxTextLength]
+	}
+	rules := ruleEntities(text)
+	if completer == nil {
+		return rules
+	}
+	reply, err := completer.Complete(ctx, model, extractPrompt, text)
+	if err != nil {
+		return rules
+	}
+	entities, ok := parseReply(reply, text)
+	if !ok {
+		return rules
+	}
+	entities.Addresses = union(entities.Addresses, rules.Addresses)
+	entities.Hostnames = union(entities.Hostnames, rules.Hostnames)
+	for _, port := range rules.Ports {
+		entities.Ports = appendPort(entities.Ports, port)
+	}
+	sort.Ints(entities.Ports)
+	if len(entities.Objects) == 0 {
+		entities.Objects = rules.Objects
+	}
+	return entities
+}
+
+// parseReply reads the JSON object of a model reply and keeps the values found in the text
+func parseReply(reply, text string) (*Entities, bool) {
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return nil, false
+	}
+	var raw struct {
+		Intent    string        `json:"intent"`
+		Summary   string        `json:"summary"`
+		Objects   []Object      `json:"objects"`
+		Addresses []string      `json:"addresses"`
+		Ports     []interface{} `json:"ports"`
+		Hostnames []string      `json:"hostnames"`
+	}
+	if json.Unmarshal([]byte(reply[start:end+1]), &raw) != nil || raw.Intent == "" {
+		return nil, false
+	}
+
+	lower := strings.ToLower(text)
+	entities := &Entities{Intent: IntentOther, Summary: strings.TrimSpace(raw.Summary), Objects: []Object{}, Addresses: []string{}, Ports: []int{}, Hostnames: []string{}, Source: "llm"}
+	for _, intent := range Intents {
+		if strings.EqualFold(strings.TrimSpace(raw.Intent), intent) {
+			entities.Intent = intent
+		}
+	}
+	for _, obj := range raw.Objects {
+		name := strings.TrimSpace(obj.Name)
+		if name == "" || !strings.Contains(lower, strings.ToLower(name)) {
+			continue
+		}
+		switch obj.Type {
+		case TypeVirtualService, TypePool, TypeHealthMonitor, TypeServiceEngine:
+		default:
+			obj.Type = TypeUnknown
+		}
+		entities.Objects = append(entities.Objects, Object{Type: obj.Type, Name: name})
+	}
+	for _, addr := range raw.Addresses {
+		addr = strings.Trim(strings.TrimSpace(addr), "[]")
+		if ip := net.ParseIP(addr); ip != nil && strings.Contains(lower, strings.ToLower(addr)) {
+			entities.Addresses = union(entities.Addresses, []string{ip.String()})
+		}
+	}
+	for _, p := range raw.Ports {
+		port, _ := strconv.Atoi(strings.TrimSpace(strings.Trim(jsonString(p), `"`)))
+		if port > 0 && port <= 65535 && strings.Contains(text, strconv.Itoa(port)) {
+			entities.Ports = appendPort(entities.Ports, port)
+		}
+	}
+	for _, host := range raw.Hostnames {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host != "" && strings.Contains(lower, host) {
+			entities.Hostnames = union(entities.Hostnames, []string{host})
+		}
+	}
+	return entities, true
+}
+
+var (
+	addressPattern  = regexp.MustCompile(`\[?[0-9A-Fa-f:.]*[:.][0-9A-Fa-f:.]+\]?(?::\d{1,5})?`)
+	portPattern     = regexp.MustCompile(`(?i)\bports?\s*:?\s*(\d{1,5})\b`)
+	hostnamePattern = regexp.MustCompile(`(?i)\b(?:[a-z0-9](?:[a-z0-9-]*[a-z0-9])?\.)+[a-z]{2,}\b`)
+	objectPattern   = regexp.MustCompile(`(?i)\b(?:(?:vs|pool|hm|se)[-_][\w.-]*\w|[\w.-]*\w[-_](?:vs|pool|hm|monitor))\b`)
+)
+
+// intentKeywords are the words of each intent, checked in the order of Intents
+var intentKeywords = map[string][]string{
+	IntentRemoveServer: {"remove server", "remove the server", "decommission", "retire server", "remove member", "remove backend"},
+	IntentAddServer:    {"add server", "add the server", "add a server", "add member", "add backend", "new server", "new backend"},
+	IntentCertificate:  {"certificate", "cert ", "tls", "ssl", "expir"},
+	IntentDisable:      {"disable", "drain", "take out of", "maintenance", "take down"},
+	IntentEnable:       {"enable", "bring back", "re-enable", "put back"},
+	IntentDelete:       {"delete", "remove"},
+	IntentCreate:       {"create", "set up", "provision", "new virtual service", "new pool"},
+	IntentInvestigate:  {"slow", "error", "down", "timeout", "latency", "failing", "fails", "502", "503", "504", "unreachable", "investigate", "outage", "degraded"},
+}
+
+// ruleEntities finds addresses, ports, hostnames, object-like names and the intent by pattern
+func ruleEntities(text string) *Entities {
+	entities := &Entities{Intent: IntentOther, Objects: []Object{}, Addresses: []string{}, Ports: []int{}, Hostnames: []string{}, Source: "rules"}
+	lower := strings.ToLower(text)
+	for _, intent := range Intents {
+		for _, keyword := range intentKeywords[intent] {
+			if strings.Contains(lower, keyword) {
+				entities.Intent = intent
+				break
+			}
+		}
+		if entities.Intent != IntentOther {
+			break
+		}
+	}
+
+	for _, match := range addressPattern.FindAllString(text, -1) {
+		host, portText := match, ""
+		if h, p, err := net.SplitHostPort(match); err == nil && net.ParseIP(h) != nil {
+			host, portText = h, p
+		}
+		ip := net.ParseIP(strings.Trim(host, "[]"))
+		if ip == nil {
+			continue
+		}
+		entities.Addresses = union(entities.Addresses, []string{ip.String()})
+		if port, err := strconv.Atoi(portText); err == nil && port > 0 && port <= 65535 {
+			entities.Ports = appendPort(entities.Ports, port)
+		}
+	}
+	for _, match := range portPattern.FindAllStringSubmatch(text, -1) {
+		if port, _ := strconv.Atoi(match[1]); port > 0 && port <= 65535 {
+			entities.Ports = appendPort(entities.Ports, port)
+		}
+	}
+	sort.Ints(entities.Ports)
+	// "remove 10.0.0.5 from the pool" removes a server rather than deleting an object
+	if entities.Intent == IntentDelete && len(entities.Addresses) > 0 {
+		entities.Intent = IntentRemoveServer
+	}
+	for _, match := range hostnamePattern.FindAllString(text, -1) {
+		if net.ParseIP(match) == nil {
+			entities.Hostnames = union(entities.Hostnames, []string{strings.ToLower(match)})
+		}
+	}
+	seen := map[string]bool{}
+	for _, name := range objectPattern.FindAllString(text, -1) {
+		if seen[strings.ToLower(name)] || hostnamePattern.MatchString(name) {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		entities.Objects = append(entities.Objects, Object{Type: objectType(name), Name: name})
+	}
+	return entities
+}
+
+// objectType guesses the type of an object from its name, e.g. shop-vs or pool-web
+func objectType(name string) string {
+	lower := strings.ToLower(name)
+	for _, affix := range []struct{ affix, objType string }{
+		{"vs", TypeVirtualService}, {"pool", TypePool}, {"hm", TypeHealthMonitor}, {"monitor", TypeHealthMonitor}, {"se", TypeServiceEngine},
+	} {
+		if strings.HasPrefix(lower, affix.affix+"-") || strings.HasPrefix(lower, affix.affix+"_") ||
+			strings.HasSuffix(lower, "-"+affix.affix) || strings.HasSuffix(lower, "_"+affix.affix) {
+			return affix.objType
+		}
+	}
+	return TypeUnknown
+}
+
+// union appends the values of b missing from a
+func union(a, b []string) []string {
+	for _, value := range b {
+		found := false
+		for _, existing := range a {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			a = append(a, value)
+		}
+	}
+	return a
+}
+
+// appendPort appends a port once
+func appendPort(ports []int, port int) []int {
+	for _, p := range ports {
+		if p == port {
+			return ports
+		}
+	}
+	return append(ports, port)
+}
+
+// jsonString formats a JSON number or string as text
+func jsonString(v interface{}) string {
+	switch value := v.(type) {
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case string:
+		return value
+	}
+	return ""
+}
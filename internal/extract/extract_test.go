@@ -0,0 +1,52 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeCompleter replies with a canned answer
+type fakeCompleter struct {
+	reply string
+	err   error
+}
+
+func (f fakeCompleter) Complete(ctx context.Context, model, system, prompt string) (string, error) {
+	return f.reply, f.err
+}
+
+func TestExtract(t *testing.T) {
+	ticket := "Hi team, please drain 10.1.1.21:8080 in web-pool before Friday's patching. Customers on shop.example.com shouldn't notice."
+
+	t.Run("rules", func(t *testing.T) {
+		entities := Extract(context.Background(), fakeCompleter{err: errors.New("model unavailable")}, "m", ticket)
+		assert.Equal(t, "rules", entities.Source)
+		assert.Equal(t, IntentDisable, entities.Intent)
+		assert.Equal(t, []string{"10.1.1.21"}, entities.Addresses)
+		assert.Equal(t, []int{8080}, entities.Ports)
+		assert.Equal(t, []string{"shop.example.com"}, entities.Hostnames)
+		assert.Equal(t, []Object{{Type: TypePool, Name: "web-pool"}}, entities.Objects)
+	})
+
+	t.Run("model output checked against the text", func(t *testing.T) {
+		reply := "```json\n" + `{"intent": "disable", "summary": "Disable a web server for patching.",
+			"objects": [{"type": "pool", "name": "web-pool"}, {"type": "virtual_service", "name": "shop-vs"}],
+			"addresses": ["10.1.1.21", "10.9.9.9"], "ports": ["8080", 443], "hostnames": ["shop.example.com"]}` + "\n```"
+		entities := Extract(context.Background(), fakeCompleter{reply: reply}, "m", ticket)
+		assert.Equal(t, "llm", entities.Source)
+		assert.Equal(t, "Disable a web server for patching.", entities.Summary)
+		assert.Equal(t, []Object{{Type: TypePool, Name: "web-pool"}}, entities.Objects, "shop-vs isn't in the text")
+		assert.Equal(t, []string{"10.1.1.21"}, entities.Addresses)
+		assert.Equal(t, []int{8080}, entities.Ports)
+	})
+
+	t.Run("remove server", func(t *testing.T) {
+		entities := Extract(context.Background(), nil, "", "Remove [2001:db8::7]:443 from payments, it's being decommissioned")
+		assert.Equal(t, IntentRemoveServer, entities.Intent)
+		assert.Equal(t, []string{"2001:db8::7"}, entities.Addresses)
+		assert.Equal(t, []int{443}, entities.Ports)
+	})
+}
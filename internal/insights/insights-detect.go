@@ -0,0 +1,73 @@
+package insights
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"aviagent/pkg/avi"
+)
+
+// Detect raises insights from a tool result: certificate expiry findings of a security audit and
+// anomaly penalties in health results
+func Detect(result interface{}) []Insight {
+	var raised []Insight
+	switch r := result.(type) {
+	case *avi.SecurityReport:
+		for _, finding := range r.Findings {
+			if finding.Category != "certificate" || !strings.Contains(finding.Issue, "expire") {
+				continue
+			}
+			raised = append(raised, Insight{
+				ID:       insightID(KindCertExpiry, finding.Issue),
+				Kind:     KindCertExpiry,
+				Severity: finding.Severity,
+				Object:   r.VirtualService,
+				Message:  finding.Issue,
+			})
+		}
+	case []avi.HealthSummary:
+		for _, summary := range r {
+			if summary.AnomalyPenalty > 0 {
+				name := summary.Name
+				if name == "" {
+					name = summary.UUID
+				}
+				raised = append(raised, anomaly(summary.UUID, name, summary.AnomalyPenalty))
+			}
+		}
+	case avi.HealthScoreReport:
+		if r.AnomalyPenalty > 0 {
+			raised = append(raised, anomaly(r.UUID, r.UUID, r.AnomalyPenalty))
+		}
+	}
+	return raised
+}
+
+// anomaly raises the anomalous traffic insight of an object
+func anomaly(uuid, name string, penalty float64) Insight {
+	severity := avi.SeverityLow
+	if penalty >= 10 {
+		severity = avi.SeverityMedium
+	}
+	return Insight{
+		ID:       insightID(KindAnomaly, uuid),
+		Kind:     KindAnomaly,
+		Severity: severity,
+		Object:   name,
+		Message:  fmt.Sprintf("%s has an anomaly penalty of %.0f: traffic deviates from the learned baseline.", name, penalty),
+	}
+}
+
+// insightID derives a short, stable ID from the insight kind and the condition it describes
+func insightID(kind, key string) string {
+	digest := sha256.Sum256([]byte(kind + "\x00" + key))
+	prefix := strings.SplitN(kind, "_", 2)[0]
+	return prefix + "-" + hex.EncodeToString(digest[:4])
+}
+
+// Notice formats an insight for the notices shown with an answer
+func Notice(insight Insight) string {
+	return fmt.Sprintf("Insight %s (%s): %s Acknowledge or snooze it to stop repeating this warning.", insight.ID, insight.Severity, insight.Message)
+}
@@ -0,0 +1,237 @@
+package insights
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"aviagent/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// Insight kinds
+const (
+	KindCertExpiry = "cert_expiry"
+	KindAnomaly    = "anomaly"
+)
+
+// defaultUser holds the acknowledgments of requests without an authenticated operator
+const defaultUser = "default"
+
+// Insight is a warning raised from tool results, such as an expiring certificate or anomalous
+// traffic on a virtual service. The ID is stable for the same condition on the same object.
+type Insight struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"`
+	Severity  string    `json:"severity"`
+	Object    string    `json:"object"`
+	Message   string    `json:"message"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// State is an operator's acknowledgment of an insight
+type State struct {
+	Acknowledged bool      `json:"acknowledged"`
+	SnoozedUntil time.Time `json:"snoozed_until,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Status is an insight together with the requesting operator's state
+type Status struct {
+	Insight
+	State  *State `json:"state,omitempty"`
+	Active bool   `json:"active"` // shown to the operator: neither acknowledged nor snoozed
+}
+
+// Store tracks raised insights and the acknowledgment or snooze state each operator set for
+// them, so a warning isn't repeated in every conversation. State is kept in memory and saved
+// to a JSON file when one is configured.
+type Store struct {
+	mu       sync.Mutex
+	insights map[string]*Insight
+	states   map[string]map[string]State // operator -> insight ID -> state
+	file     string
+	logger   *zap.Logger
+}
+
+// snapshot is the persisted form of the store
+type snapshot struct {
+	Insights map[string]*Insight         `json:"insights"`
+	States   map[string]map[string]State `json:"states"`
+}
+
+// NewStore creates the insight store, loading the state file when one is configured
+func NewStore(cfg config.InsightsConfig, logger *zap.Logger) (*Store, error) {
+	s := &Store{
+		insights: make(map[string]*Insight),
+		states:   make(map[string]map[string]State),
+		file:     cfg.StateFile,
+		logger:   logger,
+	}
+	if s.file == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(s.file)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read insight state %s: %w", s.file, err)
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("failed to parse insight state %s: %w", s.file, err)
+	}
+	if snap.Insights != nil {
+		s.insights = snap.Insights
+	}
+	if snap.States != nil {
+		s.states = snap.States
+	}
+	return s, nil
+}
+
+// Raise records insights and returns those the operator hasn't acknowledged or snoozed
+func (s *Store) Raise(user string, raised []Insight, now time.Time) []Insight {
+	if len(raised) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var pending []Insight
+	for _, insight := range raised {
+		if existing, ok := s.insights[insight.ID]; ok {
+			insight.FirstSeen = existing.FirstSeen
+		} else {
+			insight.FirstSeen = now
+		}
+		insight.LastSeen = now
+		s.insights[insight.ID] = &insight
+
+		if state, ok := s.states[userKey(user)][insight.ID]; !ok || state.active(now) {
+			pending = append(pending, insight)
+		}
+	}
+	s.save()
+	return pending
+}
+
+// Acknowledge stops an insight from being shown to the operator again
+func (s *Store) Acknowledge(user, id string, now time.Time) error {
+	return s.setState(user, id, State{Acknowledged: true, UpdatedAt: now})
+}
+
+// Snooze hides an insight from the operator until the given time
+func (s *Store) Snooze(user, id string, until, now time.Time) error {
+	if !until.After(now) {
+		return fmt.Errorf("snooze must end in the future")
+	}
+	return s.setState(user, id, State{SnoozedUntil: until, UpdatedAt: now})
+}
+
+// Reset clears the operator's acknowledgment or snooze of an insight
+func (s *Store) Reset(user, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.insights[id]; !ok {
+		return fmt.Errorf("insight %s not found", id)
+	}
+	delete(s.states[userKey(user)], id)
+	s.save()
+	return nil
+}
+
+// List returns every known insight with the operator's state, active insights first
+func (s *Store) List(user string, now time.Time) []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]Status, 0, len(s.insights))
+	for id, insight := range s.insights {
+		status := Status{Insight: *insight, Active: true}
+		if state, ok := s.states[userKey(user)][id]; ok {
+			st := state
+			status.State = &st
+			status.Active = state.active(now)
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Active != statuses[j].Active {
+			return statuses[i].Active
+		}
+		return statuses[i].LastSeen.After(statuses[j].LastSeen)
+	})
+	return statuses
+}
+
+// setState stores the operator's state for a known insight
+func (s *Store) setState(user, id string, state State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.insights[id]; !ok {
+		return fmt.Errorf("insight %s not found", id)
+	}
+	key := userKey(user)
+	if s.states[key] == nil {
+		s.states[key] = make(map[string]State)
+	}
+	s.states[key][id] = state
+	s.save()
+	return nil
+}
+
+// save writes the state file; failures are logged because acknowledgments still apply in memory
+func (s *Store) save() {
+	if s.file == "" {
+		return
+	}
+	data, err := json.MarshalIndent(snapshot{Insights: s.insights, States: s.states}, "", "  ")
+	if err == nil {
+		err = os.WriteFile(s.file, data, 0600)
+	}
+	if err != nil {
+		s.logger.Error("Failed to save insight state", zap.String("file", s.file), zap.Error(err))
+	}
+}
+
+// active reports whether the insight is shown despite this state
+func (st State) active(now time.Time) bool {
+	if st.Acknowledged {
+		return false
+	}
+	return !now.Before(st.SnoozedUntil)
+}
+
+// userKey maps an empty operator to the shared default user
+func userKey(user string) string {
+	if user == "" {
+		return defaultUser
+	}
+	return user
+}
+
+// ParseSnooze parses a snooze duration such as "4h", "30m" or "7d"
+func ParseSnooze(value string) (time.Duration, error) {
+	value = strings.TrimSpace(strings.ToLower(value))
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid snooze duration %q (use e.g. 4h or 7d)", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid snooze duration %q (use e.g. 4h or 7d)", value)
+	}
+	return d, nil
+}
@@ -0,0 +1,74 @@
+package insights
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"aviagent/pkg/avi"
+	"aviagent/pkg/llm"
+)
+
+// incidentPrompt instructs the model writing an incident summary from health evidence
+const incidentPrompt = `You are a site reliability engineer writing a short incident summary for a VMware Avi virtual service.
+You are given JSON evidence: the health score breakdown, metric statistics, a timeline of metric spikes, anomalies and pool member state changes, and warnings about evidence that couldn't be read.
+Write 2 to 5 sentences of plain text. Say when things happened (HH:MM UTC) and correlate events that line up in time, for example "latency spiked at 14:02, correlated with pool member 10.0.0.5:80 going down".
+Only state what the evidence supports. If the timeline is empty and the health score is high, say the virtual service looks healthy.`
+
+// IncidentSummary is a human-readable explanation of a virtual service's health and the
+// evidence it was written from
+type IncidentSummary struct {
+	VirtualService string              `json:"virtual_service"`
+	Summary        string              `json:"summary"`
+	Source         string              `json:"source"` // llm, or rules when the model couldn't be used
+	Evidence       *avi.HealthEvidence `json:"evidence"`
+}
+
+// SummarizeIncident has the model explain the health evidence of a virtual service. When the model
+// fails the summary is built from the timeline instead, so the tool always answers.
+func SummarizeIncident(ctx context.Context, completer llm.Completer, model string, ev *avi.HealthEvidence) *IncidentSummary {
+	summary := &IncidentSummary{VirtualService: ev.Name, Evidence: ev}
+	if summary.VirtualService == "" {
+		summary.VirtualService = ev.UUID
+	}
+
+	if completer != nil {
+		evidence, err := json.Marshal(ev)
+		if err == nil {
+			reply, err := completer.Complete(ctx, model, incidentPrompt, string(evidence))
+			if err == nil && strings.TrimSpace(reply) != "" {
+				summary.Summary = strings.TrimSpace(reply)
+				summary.Source = "llm"
+				return summary
+			}
+		}
+	}
+
+	summary.Summary = ruleSummary(summary.VirtualService, ev)
+	summary.Source = "rules"
+	return summary
+}
+
+// ruleSummary lists the health score and timeline of the evidence as sentences
+func ruleSummary(name string, ev *avi.HealthEvidence) string {
+	var sentences []string
+	if ev.Health != nil && ev.Health.Samples > 0 {
+		sentences = append(sentences, fmt.Sprintf("%s has a health score of %.0f (lowest %.0f over %s).",
+			name, ev.Health.HealthScore, ev.Health.LowestScore, ev.TimeRange))
+	}
+	for _, event := range ev.Timeline {
+		if event.Time != "" {
+			sentences = append(sentences, fmt.Sprintf("At %s %s.", event.Time, event.Description))
+		} else {
+			sentences = append(sentences, event.Description+".")
+		}
+	}
+	if len(ev.Timeline) == 0 {
+		sentences = append(sentences, fmt.Sprintf("No spikes, anomalies or pool member failures were found for %s over %s.", name, ev.TimeRange))
+	}
+	if len(ev.Warnings) > 0 {
+		sentences = append(sentences, fmt.Sprintf("Some evidence couldn't be read: %s.", strings.Join(ev.Warnings, "; ")))
+	}
+	return strings.Join(sentences, " ")
+}
@@ -0,0 +1,96 @@
+package insights
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"aviagent/pkg/avi"
+	"aviagent/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestStore_AcknowledgeAndSnooze(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "insights.json")
+	store, err := NewStore(config.InsightsConfig{StateFile: file}, zap.NewNop())
+	require.NoError(t, err)
+
+	raised := Detect(&avi.SecurityReport{
+		VirtualService: "shop",
+		Findings: []avi.SecurityFinding{
+			{Category: "certificate", Severity: avi.SeverityMedium, Issue: "Certificate shop-cert expires soon (2026-11-01)."},
+			{Category: "tls", Severity: avi.SeverityHigh, Issue: "TLS1_1 is enabled."},
+		},
+	})
+	raised = append(raised, Detect([]avi.HealthSummary{{UUID: "vs-1", Name: "api", AnomalyPenalty: 12}})...)
+	require.Len(t, raised, 2)
+	assert.Equal(t, KindCertExpiry, raised[0].Kind)
+	assert.Equal(t, avi.SeverityMedium, raised[1].Severity)
+
+	now := time.Now()
+	assert.Len(t, store.Raise("alice", raised, now), 2)
+
+	// Alice acknowledges the certificate and snoozes the anomaly; Bob still sees both
+	require.NoError(t, store.Acknowledge("alice", raised[0].ID, now))
+	require.NoError(t, store.Snooze("alice", raised[1].ID, now.Add(time.Hour), now))
+	assert.Empty(t, store.Raise("alice", raised, now.Add(time.Minute)))
+	assert.Len(t, store.Raise("bob", raised, now.Add(time.Minute)), 2)
+
+	// The snooze expires, the acknowledgment doesn't
+	pending := store.Raise("alice", raised, now.Add(2*time.Hour))
+	require.Len(t, pending, 1)
+	assert.Equal(t, raised[1].ID, pending[0].ID)
+
+	assert.Error(t, store.Acknowledge("alice", "cert-unknown", now))
+
+	// Acknowledgments survive a restart
+	reloaded, err := NewStore(config.InsightsConfig{StateFile: file}, zap.NewNop())
+	require.NoError(t, err)
+	statuses := reloaded.List("alice", now.Add(time.Minute))
+	require.Len(t, statuses, 2)
+	assert.False(t, statuses[0].Active)
+	assert.False(t, statuses[1].Active)
+	require.NoError(t, reloaded.Reset("alice", raised[0].ID))
+	assert.Len(t, reloaded.Raise("alice", raised[:1], now), 1)
+
+	d, err := ParseSnooze("7d")
+	require.NoError(t, err)
+	assert.Equal(t, 7*24*time.Hour, d)
+	_, err = ParseSnooze("soon")
+	assert.Error(t, err)
+}
+
+type fakeCompleter struct {
+	reply string
+	err   error
+}
+
+func (f fakeCompleter) Complete(ctx context.Context, model, system, prompt string) (string, error) {
+	return f.reply, f.err
+}
+
+func TestSummarizeIncident(t *testing.T) {
+	ev := &avi.HealthEvidence{
+		UUID:      "vs-1",
+		Name:      "shop",
+		TimeRange: "1h",
+		Timeline: []avi.TimelineEvent{
+			{Time: "2026-10-16T14:01:00Z", Source: "pool", Description: "pool member 10.0.0.5:80 is OPER_DOWN"},
+			{Time: "2026-10-16T14:02:00Z", Source: "metric", Description: "l7_server.avg_resp_latency spiked to 100.0 (window average 21.2)"},
+		},
+	}
+
+	summary := SummarizeIncident(context.Background(), fakeCompleter{reply: " Latency spiked at 14:02 after 10.0.0.5 went down. "}, "model", ev)
+	assert.Equal(t, "llm", summary.Source)
+	assert.Equal(t, "Latency spiked at 14:02 after 10.0.0.5 went down.", summary.Summary)
+
+	summary = SummarizeIncident(context.Background(), fakeCompleter{err: errors.New("unavailable")}, "model", ev)
+	assert.Equal(t, "rules", summary.Source)
+	assert.Equal(t, "shop", summary.VirtualService)
+	assert.Contains(t, summary.Summary, "At 2026-10-16T14:01:00Z pool member 10.0.0.5:80 is OPER_DOWN.")
+}
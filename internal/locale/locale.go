@@ -0,0 +1,204 @@
+// Package locale formats numbers, percentages, byte sizes and dates in regional conventions for
+// reports and exports.
+package locale
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Locale holds the formatting conventions of a region. The zero value is the default used when no
+// locale is configured: no digit grouping, a decimal point and ISO 8601 dates.
+type Locale struct {
+	tag            string
+	decimal        string
+	group          string
+	percentSpace   string // between a number and its percent sign
+	dateLayout     string
+	dateTimeLayout string
+}
+
+// Default is the locale of reports without a locale preference
+var Default = Locale{}
+
+// Narrow and regular no-break spaces, used as group separator or before the percent sign
+const (
+	nnbsp = "\u202f"
+	nbsp  = "\u00a0"
+)
+
+// locales are the supported locales by tag; the first locale of a language is used for the
+// language alone, e.g. de for de-DE
+var locales = []Locale{
+	{tag: "en-US", decimal: ".", group: ",", dateLayout: "01/02/2006", dateTimeLayout: "01/02/2006 3:04 PM MST"},
+	{tag: "en-GB", decimal: ".", group: ",", dateLayout: "02/01/2006", dateTimeLayout: "02/01/2006 15:04 MST"},
+	{tag: "de-DE", decimal: ",", group: ".", percentSpace: nbsp, dateLayout: "02.01.2006", dateTimeLayout: "02.01.2006 15:04 MST"},
+	{tag: "de-CH", decimal: ".", group: "\u2019", dateLayout: "02.01.2006", dateTimeLayout: "02.01.2006 15:04 MST"},
+	{tag: "fr-FR", decimal: ",", group: nnbsp, percentSpace: nnbsp, dateLayout: "02/01/2006", dateTimeLayout: "02/01/2006 15:04 MST"},
+	{tag: "es-ES", decimal: ",", group: ".", percentSpace: nbsp, dateLayout: "02/01/2006", dateTimeLayout: "02/01/2006 15:04 MST"},
+	{tag: "it-IT", decimal: ",", group: ".", dateLayout: "02/01/2006", dateTimeLayout: "02/01/2006 15:04 MST"},
+	{tag: "nl-NL", decimal: ",", group: ".", dateLayout: "02-01-2006", dateTimeLayout: "02-01-2006 15:04 MST"},
+	{tag: "pt-BR", decimal: ",", group: ".", dateLayout: "02/01/2006", dateTimeLayout: "02/01/2006 15:04 MST"},
+	{tag: "sv-SE", decimal: ",", group: nbsp, percentSpace: nbsp, dateLayout: "2006-01-02", dateTimeLayout: "2006-01-02 15:04 MST"},
+	{tag: "ja-JP", decimal: ".", group: ",", dateLayout: "2006/01/02", dateTimeLayout: "2006/01/02 15:04 MST"},
+}
+
+// byteUnits are the binary units of byte sizes
+var byteUnits = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+
+// Parse returns the locale of a tag such as de-DE, de_de or de. An empty tag is the default locale.
+func Parse(tag string) (Locale, error) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return Default, nil
+	}
+	normalized := strings.ToLower(strings.ReplaceAll(tag, "_", "-"))
+	for _, l := range locales {
+		if strings.ToLower(l.tag) == normalized {
+			return l, nil
+		}
+	}
+	language, _, _ := strings.Cut(normalized, "-")
+	for _, l := range locales {
+		if strings.HasPrefix(strings.ToLower(l.tag), language+"-") {
+			return l, nil
+		}
+	}
+	return Default, fmt.Errorf("unsupported locale %q (use %s)", tag, strings.Join(Tags(), ", "))
+}
+
+// FromAcceptLanguage returns the first supported locale of an Accept-Language header, by quality
+func FromAcceptLanguage(header string) (Locale, bool) {
+	type weighted struct {
+		tag     string
+		quality float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = parsed
+			}
+		}
+		if tag != "" && tag != "*" && quality > 0 {
+			tags = append(tags, weighted{tag, quality})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].quality > tags[j].quality })
+	for _, t := range tags {
+		if l, err := Parse(t.tag); err == nil {
+			return l, true
+		}
+	}
+	return Default, false
+}
+
+// Tags returns the supported locale tags
+func Tags() []string {
+	tags := make([]string, len(locales))
+	for i, l := range locales {
+		tags[i] = l.tag
+	}
+	return tags
+}
+
+// Tag returns the tag of the locale, empty for the default locale
+func (l Locale) Tag() string {
+	return l.tag
+}
+
+// Number formats a number with the given decimals, or as few as needed when decimals is negative,
+// grouping the digits of its integer part
+func (l Locale) Number(f float64, decimals int) string {
+	return l.format(f, decimals, true)
+}
+
+// Int formats an integer, grouping its digits
+func (l Locale) Int(n int) string {
+	return l.format(float64(n), 0, true)
+}
+
+// Plain formats a number with as few decimals as needed and no digit grouping, for data exports
+// read back by spreadsheets
+func (l Locale) Plain(f float64) string {
+	return l.format(f, -1, false)
+}
+
+// Percent formats a percentage with the given decimals, or as few as needed when decimals is negative
+func (l Locale) Percent(f float64, decimals int) string {
+	return l.Number(f, decimals) + l.percentSpace + "%"
+}
+
+// Bytes formats a byte size in binary units, e.g. 1.5 GiB
+func (l Locale) Bytes(n int64) string {
+	size, unit := float64(n), 0
+	for math.Abs(size) >= 1024 && unit < len(byteUnits)-1 {
+		size /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return l.Int(int(n)) + " " + byteUnits[0]
+	}
+	return l.Number(size, 1) + " " + byteUnits[unit]
+}
+
+// Date formats the date of t
+func (l Locale) Date(t time.Time) string {
+	if l.dateLayout == "" {
+		return t.Format("2006-01-02")
+	}
+	return t.Format(l.dateLayout)
+}
+
+// DateTime formats the date and time of t, with its time zone
+func (l Locale) DateTime(t time.Time) string {
+	if l.dateTimeLayout == "" {
+		return t.Format("2006-01-02 15:04 MST")
+	}
+	return t.Format(l.dateTimeLayout)
+}
+
+// CSVSeparator returns the field separator spreadsheets of the locale expect, a semicolon where
+// the comma is the decimal separator
+func (l Locale) CSVSeparator() rune {
+	if l.decimal == "," {
+		return ';'
+	}
+	return ','
+}
+
+// format writes a number with the locale's decimal separator and, when grouped, group separator
+func (l Locale) format(f float64, decimals int, grouped bool) string {
+	digits := strconv.FormatFloat(math.Abs(f), 'f', decimals, 64)
+	integer, fraction, _ := strings.Cut(digits, ".")
+
+	var b strings.Builder
+	if f < 0 && strings.Trim(digits, "0.") != "" {
+		b.WriteString("-")
+	}
+	if grouped && l.group != "" {
+		for i, digit := range integer {
+			if i > 0 && (len(integer)-i)%3 == 0 {
+				b.WriteString(l.group)
+			}
+			b.WriteRune(digit)
+		}
+	} else {
+		b.WriteString(integer)
+	}
+	if fraction != "" {
+		decimal := l.decimal
+		if decimal == "" {
+			decimal = "."
+		}
+		b.WriteString(decimal)
+		b.WriteString(fraction)
+	}
+	return b.String()
+}
@@ -0,0 +1,44 @@
+package locale
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocaleFormat(t *testing.T) {
+	day := time.Date(2026, 3, 1, 14, 30, 0, 0, time.UTC)
+
+	assert.Equal(t, "1234567.5", Default.Number(1234567.5, 1))
+	assert.Equal(t, "99.95%", Default.Percent(99.95, 2))
+	assert.Equal(t, "2026-03-01", Default.Date(day))
+
+	us, err := Parse("en_us")
+	require.NoError(t, err)
+	assert.Equal(t, "1,234,567.5", us.Number(1234567.5, 1))
+	assert.Equal(t, "-1,000", us.Int(-1000))
+	assert.Equal(t, "03/01/2026 2:30 PM UTC", us.DateTime(day))
+	assert.Equal(t, ',', us.CSVSeparator())
+
+	de, err := Parse("de")
+	require.NoError(t, err)
+	assert.Equal(t, "de-DE", de.Tag())
+	assert.Equal(t, "1.234.567,5", de.Number(1234567.5, 1))
+	assert.Equal(t, "1234567,5", de.Plain(1234567.5))
+	assert.Equal(t, "99,9 %", de.Percent(99.9, -1))
+	assert.Equal(t, "1,5 GiB", de.Bytes(1536<<20))
+	assert.Equal(t, "512 B", de.Bytes(512))
+	assert.Equal(t, "01.03.2026", de.Date(day))
+	assert.Equal(t, ';', de.CSVSeparator())
+
+	_, err = Parse("tlh")
+	assert.Error(t, err)
+
+	l, ok := FromAcceptLanguage("tlh, fr-CH;q=0.9, en;q=0.8")
+	assert.True(t, ok)
+	assert.Equal(t, "fr-FR", l.Tag())
+	_, ok = FromAcceptLanguage("*")
+	assert.False(t, ok)
+}
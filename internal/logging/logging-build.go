@@ -0,0 +1,47 @@
+// Package logging builds the agent's zap loggers: JSON or console output on stderr, sampling of
+// repeated entries, and a redaction layer that scrubs credentials from every entry.
+package logging
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"aviagent/pkg/config"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// New builds a logger for cfg. The returned levels change the level of the logger at runtime.
+func New(cfg config.LogConfig) (*zap.Logger, *Levels, error) {
+	level := zapcore.InfoLevel
+	if cfg.Level != "" {
+		var err error
+		if level, err = zapcore.ParseLevel(cfg.Level); err != nil {
+			return nil, nil, fmt.Errorf("invalid log level %q", cfg.Level)
+		}
+	}
+	levels := NewLevels(level)
+
+	var encoder zapcore.Encoder
+	switch cfg.Format {
+	case "", "json":
+		encoder = zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	case "console":
+		encoder = zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())
+	default:
+		return nil, nil, fmt.Errorf("unsupported log format %q", cfg.Format)
+	}
+
+	// The core writes every level; levelCore filters by the runtime level
+	output := zapcore.Lock(os.Stderr)
+	core := zapcore.NewCore(encoder, output, zapcore.DebugLevel)
+	if cfg.Redact {
+		core = NewRedactingCore(core)
+	}
+	if cfg.Sampling.Initial > 0 {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, cfg.Sampling.Initial, cfg.Sampling.Thereafter)
+	}
+	return zap.New(levels.Wrap(core), zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel), zap.ErrorOutput(output)), levels, nil
+}
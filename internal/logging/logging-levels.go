@@ -0,0 +1,141 @@
+package logging
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// requestIDKey is the field request-scoped loggers carry, see requestid.Logger
+const requestIDKey = "request_id"
+
+// Levels is the runtime level of a logger built by New. Besides the level it holds the chat
+// sessions being debugged: the requests of those sessions are logged at debug level whatever
+// the level, through loggers carrying their request ID.
+type Levels struct {
+	zap.AtomicLevel
+
+	mu       sync.RWMutex
+	sessions map[string]time.Time // session ID -> end of debugging
+	requests map[string]int       // elevated request IDs -> number of holders
+}
+
+// NewLevels creates levels at the given level
+func NewLevels(level zapcore.Level) *Levels {
+	return &Levels{
+		AtomicLevel: zap.NewAtomicLevelAt(level),
+		sessions:    make(map[string]time.Time),
+		requests:    make(map[string]int),
+	}
+}
+
+// DebugSession logs the requests of a chat session at debug level until the given time
+func (l *Levels) DebugSession(id string, until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sessions[id] = until
+}
+
+// StopDebugSession stops debugging a chat session, and reports whether it was debugged
+func (l *Levels) StopDebugSession(id string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.sessions[id]
+	delete(l.sessions, id)
+	return ok
+}
+
+// SessionDebugged reports whether a chat session is being debugged at now
+func (l *Levels) SessionDebugged(id string, now time.Time) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	until, ok := l.sessions[id]
+	return ok && now.Before(until)
+}
+
+// DebuggedSession is a chat session being debugged
+type DebuggedSession struct {
+	ID    string    `json:"id"`
+	Until time.Time `json:"until"`
+}
+
+// DebuggedSessions returns the sessions being debugged at now, by ID. Expired ones are dropped.
+func (l *Levels) DebuggedSessions(now time.Time) []DebuggedSession {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sessions := make([]DebuggedSession, 0, len(l.sessions))
+	for id, until := range l.sessions {
+		if !now.Before(until) {
+			delete(l.sessions, id)
+			continue
+		}
+		sessions = append(sessions, DebuggedSession{ID: id, Until: until})
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+	return sessions
+}
+
+// Elevate logs the request with the ID at debug level until release is called. Only loggers
+// derived after the call are elevated.
+func (l *Levels) Elevate(requestID string) (release func()) {
+	l.mu.Lock()
+	l.requests[requestID]++
+	l.mu.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			if l.requests[requestID]--; l.requests[requestID] <= 0 {
+				delete(l.requests, requestID)
+			}
+		})
+	}
+}
+
+// Wrap filters the entries of core by the levels: the runtime level, or debug for the requests
+// being elevated
+func (l *Levels) Wrap(core zapcore.Core) zapcore.Core {
+	return levelCore{Core: core, levels: l}
+}
+
+// elevates reports whether fields carry the ID of an elevated request
+func (l *Levels) elevates(fields []zapcore.Field) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if len(l.requests) == 0 {
+		return false
+	}
+	for _, field := range fields {
+		if field.Key == requestIDKey && field.Type == zapcore.StringType && l.requests[field.String] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// levelCore filters the entries of a core by the runtime level, and lets every entry of an
+// elevated request through
+type levelCore struct {
+	zapcore.Core
+	levels   *Levels
+	elevated bool
+}
+
+func (c levelCore) Enabled(level zapcore.Level) bool {
+	return c.elevated || c.levels.Enabled(level)
+}
+
+func (c levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return levelCore{Core: c.Core.With(fields), levels: c.levels, elevated: c.elevated || c.levels.elevates(fields)}
+}
+
+func (c levelCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return c.Core.Check(entry, checked)
+	}
+	return checked
+}
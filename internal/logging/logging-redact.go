@@ -0,0 +1,126 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"aviagent/internal/moderation"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// redacted replaces a redacted value
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are field names whose values are never logged
+var sensitiveKeys = map[string]bool{
+	"password":      true,
+	"passwd":        true,
+	"secret":        true,
+	"token":         true,
+	"api_key":       true,
+	"apikey":        true,
+	"authorization": true,
+	"cookie":        true,
+	"set-cookie":    true,
+	"session_id":    true,
+	"sessionid":     true,
+	"csrftoken":     true,
+	"x-csrftoken":   true,
+	"proxy_secret":  true,
+	"signing_key":   true,
+}
+
+// sensitiveHeaders redacts the values of credential headers in JSON, such as a logged http.Header
+var sensitiveHeaders = regexp.MustCompile(`(?i)("(?:authorization|proxy-authorization|cookie|set-cookie|x-csrftoken|x-vault-token|x-alert-token|x-api-key|x-proxy-secret)"\s*:\s*)(\[[^\]]*\]|"(?:[^"\\]|\\.)*")`)
+
+// rules are the credential patterns also redacted from chat answers
+var rules = moderation.DefaultRules()
+
+// sensitiveKey reports whether a field name holds a credential
+func sensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	return sensitiveKeys[key] || strings.HasSuffix(key, "_password") || strings.HasSuffix(key, "_secret") || strings.HasSuffix(key, "_token")
+}
+
+// Redact scrubs passwords, API keys, tokens, session cookies and private keys from text
+func Redact(text string) string {
+	text = sensitiveHeaders.ReplaceAllString(text, `${1}"`+redacted+`"`)
+	for _, rule := range rules {
+		text = rule.Pattern.ReplaceAllString(text, rule.Replacement)
+	}
+	return text
+}
+
+// redactingCore redacts the message and fields of every entry before the wrapped core writes it
+type redactingCore struct {
+	zapcore.Core
+}
+
+// NewRedactingCore wraps core so credentials are scrubbed from everything it logs
+func NewRedactingCore(core zapcore.Core) zapcore.Core {
+	return redactingCore{core}
+}
+
+func (c redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return redactingCore{c.Core.With(redactFields(fields))}
+}
+
+func (c redactingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = Redact(entry.Message)
+	return c.Core.Write(entry, redactFields(fields))
+}
+
+// redactFields returns the fields with credentials redacted
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	redactedFields := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		redactedFields[i] = redactField(field)
+	}
+	return redactedFields
+}
+
+// redactField redacts a field named like a credential, and credentials within its value.
+// Structured values such as maps and headers are redacted in their JSON form.
+func redactField(field zapcore.Field) zapcore.Field {
+	if sensitiveKey(field.Key) && field.Type != zapcore.SkipType {
+		return zap.String(field.Key, redacted)
+	}
+	switch field.Type {
+	case zapcore.StringType:
+		field.String = Redact(field.String)
+	case zapcore.ByteStringType:
+		if data, ok := field.Interface.([]byte); ok {
+			field.Interface = []byte(Redact(string(data)))
+		}
+	case zapcore.ErrorType:
+		if err, ok := field.Interface.(error); ok {
+			if text := Redact(err.Error()); text != err.Error() {
+				return zap.String(field.Key, text)
+			}
+		}
+	case zapcore.StringerType:
+		if stringer, ok := field.Interface.(fmt.Stringer); ok {
+			return zap.String(field.Key, Redact(stringer.String()))
+		}
+	case zapcore.ReflectType:
+		data, err := json.Marshal(field.Interface)
+		if err != nil {
+			return field
+		}
+		if text := Redact(string(data)); text != string(data) && json.Valid([]byte(text)) {
+			return zap.Reflect(field.Key, json.RawMessage(text))
+		}
+	}
+	return field
+}
@@ -0,0 +1,107 @@
+package logging
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"aviagent/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// newObservedLogger returns a redacting logger and the entries it wrote
+func newObservedLogger() (*zap.Logger, *observer.ObservedLogs) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	return zap.New(NewRedactingCore(core)), logs
+}
+
+func TestRedactingCore(t *testing.T) {
+	logger, logs := newObservedLogger()
+	header := http.Header{}
+	header.Set("Authorization", "Bearer sk-live-123")
+	header.Set("Content-Type", "application/json")
+
+	logger.With(zap.String("api_key", "sk-live-123")).Info("Request with password=hunter2",
+		zap.String("avi_password", "hunter2"),
+		zap.String("body_content", `{"token": "abc123", "model": "mistral-small"}`),
+		zap.String("cookie", "sessionid=s3ss10n"),
+		zap.Any("headers", header),
+		zap.Error(errors.New("login failed: sessionid=s3ss10n")),
+		zap.Int("max_tokens", 512),
+		zap.String("model", "mistral-small"))
+
+	require.Equal(t, 1, logs.Len())
+	entry := logs.All()[0]
+	assert.Equal(t, "Request with password=[REDACTED]", entry.Message)
+	fields := entry.ContextMap()
+	assert.Equal(t, redacted, fields["api_key"], "fields added with With are redacted too")
+	assert.Equal(t, redacted, fields["avi_password"])
+	assert.Equal(t, `{"token": "[REDACTED]", "model": "mistral-small"}`, fields["body_content"])
+	assert.Equal(t, redacted, fields["cookie"])
+	assert.Equal(t, "login failed: sessionid=[REDACTED]", fields["error"])
+	assert.EqualValues(t, 512, fields["max_tokens"], "token counts aren't credentials")
+	assert.Equal(t, "mistral-small", fields["model"])
+
+	headers, err := json.Marshal(fields["headers"])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"Authorization": "[REDACTED]", "Content-Type": ["application/json"]}`, string(headers), "headers are still logged as an object")
+}
+
+func TestRedact(t *testing.T) {
+	assert.Equal(t, `{"Cookie":"[REDACTED]","X-Vault-Token": "[REDACTED]"}`, Redact(`{"Cookie":["sessionid=abc; csrftoken=def"],"X-Vault-Token": "s.xyz"}`))
+	assert.Equal(t, "GET /api/models?api_key=[REDACTED]", Redact("GET /api/models?api_key=sk-123"))
+	assert.Equal(t, "list pools", Redact("list pools"))
+}
+
+func TestNew(t *testing.T) {
+	logger, level, err := New(config.LogConfig{Level: "warn", Format: "console", Redact: true, Sampling: config.LogSamplingConfig{Initial: 10, Thereafter: 10}})
+	require.NoError(t, err)
+	assert.NotNil(t, logger)
+	assert.Equal(t, zapcore.WarnLevel, level.Level())
+	assert.False(t, logger.Core().Enabled(zapcore.InfoLevel))
+	level.SetLevel(zapcore.DebugLevel)
+	assert.True(t, logger.Core().Enabled(zapcore.DebugLevel), "the level changes at runtime")
+
+	_, _, err = New(config.LogConfig{Format: "logfmt"})
+	assert.ErrorContains(t, err, `unsupported log format "logfmt"`)
+	_, _, err = New(config.LogConfig{Level: "loud"})
+	assert.ErrorContains(t, err, `invalid log level "loud"`)
+}
+
+func TestLevels(t *testing.T) {
+	observed, logs := observer.New(zapcore.DebugLevel)
+	levels := NewLevels(zapcore.InfoLevel)
+	logger := zap.New(levels.Wrap(observed))
+
+	logger.Debug("dropped")
+	release := levels.Elevate("req-1")
+	elevated := logger.With(zap.String("request_id", "req-1"))
+	other := logger.With(zap.String("request_id", "req-2"))
+	elevated.Debug("kept")
+	other.Debug("dropped too")
+	release()
+	release()
+	logger.With(zap.String("request_id", "req-1")).Debug("dropped after release")
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, "kept", logs.All()[0].Message, "only the elevated request logs at debug level")
+
+	levels.SetLevel(zapcore.DebugLevel)
+	logger.Debug("kept at debug level")
+	assert.Equal(t, 2, logs.Len())
+
+	now := time.Now()
+	levels.DebugSession("s-1", now.Add(time.Hour))
+	levels.DebugSession("s-2", now.Add(-time.Minute))
+	assert.True(t, levels.SessionDebugged("s-1", now))
+	assert.False(t, levels.SessionDebugged("s-2", now), "expired")
+	assert.Equal(t, []DebuggedSession{{ID: "s-1", Until: now.Add(time.Hour)}}, levels.DebuggedSessions(now))
+	assert.True(t, levels.StopDebugSession("s-1"))
+	assert.False(t, levels.StopDebugSession("s-2"), "expired sessions are dropped when listed")
+}
@@ -0,0 +1,206 @@
+// Package memory keeps the deployment memory: durable facts about the environment confirmed by
+// administrators, such as naming conventions, the owners of objects or objects that must be left
+// alone. Unlike session notes they apply to every conversation.
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"aviagent/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// defaultMaxFacts is how many facts are kept when the configuration doesn't say
+const defaultMaxFacts = 100
+
+// maxFactLength bounds a fact, so the facts sent with every question stay small
+const maxFactLength = 500
+
+// Fact is a durable fact about the environment
+type Fact struct {
+	ID        string    `json:"id"`
+	Topic     string    `json:"topic,omitempty"` // groups facts, e.g. naming, owners or exceptions
+	Text      string    `json:"text"`
+	UpdatedBy string    `json:"updated_by,omitempty"`
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
+}
+
+// Store keeps the facts in memory and saves them to a JSON file after every change when one is
+// configured
+type Store struct {
+	mu       sync.Mutex
+	facts    []Fact
+	file     string
+	maxFacts int
+	seq      int
+	logger   *zap.Logger
+}
+
+// NewStore creates the fact store, loading the state file when one is configured
+func NewStore(cfg config.MemoryConfig, logger *zap.Logger) (*Store, error) {
+	s := &Store{
+		facts:    []Fact{},
+		file:     cfg.StateFile,
+		maxFacts: cfg.MaxFacts,
+		logger:   logger,
+	}
+	if s.maxFacts <= 0 {
+		s.maxFacts = defaultMaxFacts
+	}
+	if s.file == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(s.file)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read memory state %s: %w", s.file, err)
+	}
+	if err := json.Unmarshal(data, &s.facts); err != nil {
+		return nil, fmt.Errorf("failed to parse memory state %s: %w", s.file, err)
+	}
+	for _, fact := range s.facts {
+		if n, err := strconv.Atoi(strings.TrimPrefix(fact.ID, "fact-")); err == nil && n > s.seq {
+			s.seq = n
+		}
+	}
+	return s, nil
+}
+
+// checkFact validates the text of a fact
+func checkFact(text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", fmt.Errorf("the fact is empty")
+	}
+	if len(text) > maxFactLength {
+		return "", fmt.Errorf("the fact is longer than %d characters", maxFactLength)
+	}
+	return text, nil
+}
+
+// Add records a fact
+func (s *Store) Add(topic, text, operator string) (Fact, error) {
+	text, err := checkFact(text)
+	if err != nil {
+		return Fact{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.facts) >= s.maxFacts {
+		return Fact{}, fmt.Errorf("the memory already holds %d facts, delete one first", s.maxFacts)
+	}
+	now := time.Now().UTC()
+	s.seq++
+	fact := Fact{
+		ID:        fmt.Sprintf("fact-%d", s.seq),
+		Topic:     strings.TrimSpace(topic),
+		Text:      text,
+		UpdatedBy: operator,
+		Created:   now,
+		Updated:   now,
+	}
+	s.facts = append(s.facts, fact)
+	s.save()
+	return fact, nil
+}
+
+// Update replaces the topic and text of a fact
+func (s *Store) Update(id, topic, text, operator string) (Fact, error) {
+	text, err := checkFact(text)
+	if err != nil {
+		return Fact{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.facts {
+		if s.facts[i].ID == id {
+			fact := &s.facts[i]
+			fact.Topic, fact.Text, fact.UpdatedBy, fact.Updated = strings.TrimSpace(topic), text, operator, time.Now().UTC()
+			s.save()
+			return *fact, nil
+		}
+	}
+	return Fact{}, fmt.Errorf("fact %s not found", id)
+}
+
+// Delete removes a fact and reports whether it existed
+func (s *Store) Delete(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, fact := range s.facts {
+		if fact.ID == id {
+			s.facts = append(s.facts[:i:i], s.facts[i+1:]...)
+			s.save()
+			return true
+		}
+	}
+	return false
+}
+
+// List returns the facts, oldest first
+func (s *Store) List() []Fact {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Fact{}, s.facts...)
+}
+
+// Prompt renders the facts for the model, grouped by topic in the order topics first appear;
+// empty when there are none
+func (s *Store) Prompt() string {
+	facts := s.List()
+	if len(facts) == 0 {
+		return ""
+	}
+	var topics []string
+	byTopic := make(map[string][]string)
+	for _, fact := range facts {
+		if _, ok := byTopic[fact.Topic]; !ok {
+			topics = append(topics, fact.Topic)
+		}
+		byTopic[fact.Topic] = append(byTopic[fact.Topic], fact.Text)
+	}
+
+	var prompt strings.Builder
+	prompt.WriteString("Facts about this environment confirmed by its administrators; follow them unless the user says otherwise:")
+	for _, topic := range topics {
+		prefix := "\n- "
+		if topic != "" {
+			fmt.Fprintf(&prompt, "\n%s:", topic)
+			prefix = "\n  - "
+		}
+		for _, text := range byTopic[topic] {
+			prompt.WriteString(prefix + text)
+		}
+	}
+	return prompt.String()
+}
+
+// save writes the facts to the state file, if one is configured
+func (s *Store) save() {
+	if s.file == "" {
+		return
+	}
+	data, err := json.MarshalIndent(s.facts, "", "  ")
+	if err == nil {
+		tmp := filepath.Join(filepath.Dir(s.file), "."+filepath.Base(s.file)+".tmp")
+		if err = os.WriteFile(tmp, data, 0600); err == nil {
+			err = os.Rename(tmp, s.file)
+		}
+	}
+	if err != nil {
+		s.logger.Error("Failed to save memory state", zap.String("file", s.file), zap.Error(err))
+	}
+}
@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"aviagent/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestStore(t *testing.T) {
+	cfg := config.MemoryConfig{StateFile: filepath.Join(t.TempDir(), "memory.json"), MaxFacts: 3}
+	store, err := NewStore(cfg, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	assert.Empty(t, store.Prompt())
+
+	naming, err := store.Add("naming", "Virtual services are named <app>-<env>-vs", "alice")
+	require.NoError(t, err)
+	assert.Equal(t, "fact-1", naming.ID)
+	_, err = store.Add("", "Never change the legacy-sso-vs virtual service", "alice")
+	require.NoError(t, err)
+	_, err = store.Add("owners", "  ", "alice")
+	assert.ErrorContains(t, err, "empty")
+	_, err = store.Add("owners", strings.Repeat("x", maxFactLength+1), "alice")
+	assert.ErrorContains(t, err, "longer than")
+
+	owners, err := store.Add("owners", "Pools starting with pay- belong to the payments team", "bob")
+	require.NoError(t, err)
+	_, err = store.Add("owners", "one too many", "bob")
+	assert.ErrorContains(t, err, "already holds 3 facts")
+
+	updated, err := store.Update(naming.ID, "naming", "Virtual services are named <app>-<env>-vs, pools <app>-<env>-pool", "bob")
+	require.NoError(t, err)
+	assert.Equal(t, "bob", updated.UpdatedBy)
+	_, err = store.Update("fact-9", "", "missing", "bob")
+	assert.ErrorContains(t, err, "not found")
+
+	assert.Equal(t, "Facts about this environment confirmed by its administrators; follow them unless the user says otherwise:"+
+		"\nnaming:\n  - Virtual services are named <app>-<env>-vs, pools <app>-<env>-pool"+
+		"\n- Never change the legacy-sso-vs virtual service"+
+		"\nowners:\n  - Pools starting with pay- belong to the payments team", store.Prompt())
+
+	// The facts survive a restart and numbering continues after the highest ID
+	assert.True(t, store.Delete(naming.ID))
+	assert.False(t, store.Delete(naming.ID))
+	store, err = NewStore(cfg, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.Len(t, store.List(), 2)
+	assert.Equal(t, owners.ID, store.List()[1].ID)
+	fact, err := store.Add("naming", "Pools are named <app>-<env>-pool", "bob")
+	require.NoError(t, err)
+	assert.Equal(t, "fact-4", fact.ID)
+}
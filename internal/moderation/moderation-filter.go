@@ -0,0 +1,137 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"aviagent/pkg/config"
+	"aviagent/pkg/llm"
+
+	"go.uber.org/zap"
+)
+
+// Moderation actions
+const (
+	ActionRedact = "redact"
+	ActionBlock  = "block"
+)
+
+// defaultBlockMessage replaces an answer that a filter blocked
+const defaultBlockMessage = "This answer was withheld because it matched the answer moderation policy."
+
+// Finding is a match of a moderation rule or policy in an answer
+type Finding struct {
+	Filter string `json:"filter"`
+	Rule   string `json:"rule"`
+	Action string `json:"action"`
+	Count  int    `json:"count,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Filter inspects an answer before it is rendered. It returns the answer, redacted where needed,
+// and what it found; a finding with ActionBlock withholds the whole answer.
+type Filter interface {
+	Name() string
+	Apply(ctx context.Context, text string) (string, []Finding, error)
+}
+
+// Result is the moderated answer
+type Result struct {
+	Text     string    `json:"text"`
+	Blocked  bool      `json:"blocked"`
+	Findings []Finding `json:"findings,omitempty"`
+}
+
+// Moderator runs the configured filters over outbound chat answers in order. The built-in rule
+// filter always runs first so a moderation model only sees answers with secrets already redacted.
+type Moderator struct {
+	filters      []Filter
+	blockMessage string
+	logger       *zap.Logger
+}
+
+// NewModerator builds the filter chain from configuration. The completer is used for the
+// moderation model and may be nil when no model is configured.
+func NewModerator(cfg config.ModerationConfig, completer llm.Completer, logger *zap.Logger) (*Moderator, error) {
+	m := &Moderator{blockMessage: cfg.BlockMessage, logger: logger}
+	if m.blockMessage == "" {
+		m.blockMessage = defaultBlockMessage
+	}
+	if !cfg.Enabled {
+		return m, nil
+	}
+
+	rules := DefaultRules()
+	for _, rc := range cfg.Rules {
+		rule, err := NewRule(rc.Name, rc.Pattern, rc.Action, rc.Replacement)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	m.Use(NewRuleFilter(rules))
+
+	if cfg.Model != "" {
+		if completer == nil {
+			return nil, fmt.Errorf("moderation model %s configured without an LLM client", cfg.Model)
+		}
+		m.Use(NewModelFilter(completer, cfg.Model, cfg.Policy))
+	}
+	return m, nil
+}
+
+// Use appends a filter to the chain
+func (m *Moderator) Use(filter Filter) {
+	m.filters = append(m.filters, filter)
+}
+
+// Moderate runs the answer through every filter and stops at the first block. A filter that
+// fails is logged and skipped so an unavailable moderation model doesn't withhold every answer.
+func (m *Moderator) Moderate(ctx context.Context, text string) Result {
+	result := Result{Text: text}
+	for _, filter := range m.filters {
+		filtered, findings, err := filter.Apply(ctx, result.Text)
+		if err != nil {
+			m.logger.Warn("Moderation filter failed", zap.String("filter", filter.Name()), zap.Error(err))
+			continue
+		}
+		result.Text = filtered
+		result.Findings = append(result.Findings, findings...)
+		for _, finding := range findings {
+			if finding.Action == ActionBlock {
+				result.Blocked = true
+			}
+		}
+		if result.Blocked {
+			result.Text = m.blockMessage
+			break
+		}
+	}
+
+	if len(result.Findings) > 0 {
+		m.logger.Warn("Moderated chat answer",
+			zap.Bool("blocked", result.Blocked),
+			zap.Any("findings", result.Findings))
+	}
+	return result
+}
+
+// Notice describes the moderation of an answer for the operator, empty when nothing matched
+func (r Result) Notice() string {
+	if len(r.Findings) == 0 {
+		return ""
+	}
+	rules := make([]string, 0, len(r.Findings))
+	for _, finding := range r.Findings {
+		if finding.Reason != "" {
+			rules = append(rules, fmt.Sprintf("%s (%s)", finding.Rule, finding.Reason))
+		} else {
+			rules = append(rules, finding.Rule)
+		}
+	}
+	if r.Blocked {
+		return "The answer was blocked by moderation: " + strings.Join(rules, ", ")
+	}
+	return "Sensitive values were redacted from the answer: " + strings.Join(rules, ", ")
+}
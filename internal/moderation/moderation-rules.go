@@ -0,0 +1,147 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"aviagent/pkg/llm"
+)
+
+// redacted replaces a redacted value
+const redacted = "[REDACTED]"
+
+// Rule is a regular expression matched against answers. Redact rules replace each match with the
+// replacement, which may reference capture groups (${1}); block rules withhold the answer.
+type Rule struct {
+	Name        string
+	Pattern     *regexp.Regexp
+	Action      string
+	Replacement string
+}
+
+// NewRule compiles a rule, defaulting to redaction
+func NewRule(name, pattern, action, replacement string) (Rule, error) {
+	if action == "" {
+		action = ActionRedact
+	}
+	if action != ActionRedact && action != ActionBlock {
+		return Rule{}, fmt.Errorf("moderation rule %s: unsupported action %q (use redact or block)", name, action)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Rule{}, fmt.Errorf("moderation rule %s: invalid pattern: %w", name, err)
+	}
+	if replacement == "" {
+		replacement = redacted
+	}
+	return Rule{Name: name, Pattern: re, Action: action, Replacement: replacement}, nil
+}
+
+// DefaultRules redact private keys, credentials and session tokens that tool results pulled from
+// the controller can carry into an answer. Values are matched in JSON ("password": "x") as well
+// as in the Go map format of raw API results (password:x).
+func DefaultRules() []Rule {
+	return []Rule{
+		{
+			Name:        "private_key",
+			Pattern:     regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----[\s\S]*?(?:-----END [A-Z0-9 ]*PRIVATE KEY-----|$)`),
+			Action:      ActionRedact,
+			Replacement: "[REDACTED PRIVATE KEY]",
+		},
+		{
+			Name:        "credential",
+			Pattern:     regexp.MustCompile(`(?i)\b((?:password|passphrase|secret|secret_key|access_key|api_?key|private_?key|auth_?token|token)\b["']?\s*[:=]\s*["']?)([^\s"',}\]\[]+)`),
+			Action:      ActionRedact,
+			Replacement: "${1}" + redacted,
+		},
+		{
+			Name:        "bearer_token",
+			Pattern:     regexp.MustCompile(`(?i)\b(Bearer\s+)[A-Za-z0-9\-._~+/]+=*`),
+			Action:      ActionRedact,
+			Replacement: "${1}" + redacted,
+		},
+		{
+			Name:        "session_cookie",
+			Pattern:     regexp.MustCompile(`(?i)\b((?:avi-)?sessionid|csrftoken)(=|:\s*)([^;\s"',}]+)`),
+			Action:      ActionRedact,
+			Replacement: "${1}${2}" + redacted,
+		},
+	}
+}
+
+// RuleFilter applies regular expression rules in order
+type RuleFilter struct {
+	rules []Rule
+}
+
+// NewRuleFilter creates a filter from rules
+func NewRuleFilter(rules []Rule) *RuleFilter {
+	return &RuleFilter{rules: rules}
+}
+
+// Name identifies the filter in findings
+func (f *RuleFilter) Name() string {
+	return "rules"
+}
+
+// Apply redacts the matches of redact rules and reports block rules that match
+func (f *RuleFilter) Apply(ctx context.Context, text string) (string, []Finding, error) {
+	var findings []Finding
+	for _, rule := range f.rules {
+		matches := rule.Pattern.FindAllStringIndex(text, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		findings = append(findings, Finding{Filter: f.Name(), Rule: rule.Name, Action: rule.Action, Count: len(matches)})
+		if rule.Action == ActionBlock {
+			return text, findings, nil
+		}
+		text = rule.Pattern.ReplaceAllString(text, rule.Replacement)
+	}
+	return text, findings, nil
+}
+
+// moderationPrompt instructs the moderation model; the configured policy is appended
+const moderationPrompt = `You review answers that an assistant for VMware Avi Load Balancer is about to show to an operator.
+Reply with exactly ALLOW when the answer is safe to show.
+Reply with BLOCK: <reason> when the answer discloses credentials, private keys, session tokens or other secrets, or tells the operator to perform an action the policy below disallows.
+Redacted values such as [REDACTED] are safe.`
+
+// ModelFilter asks a moderation model whether an answer may be shown
+type ModelFilter struct {
+	completer llm.Completer
+	model     string
+	policy    string
+}
+
+// NewModelFilter creates a filter that classifies answers with the given model. The policy lists
+// additional disallowed content, such as actions operators must not be told to perform.
+func NewModelFilter(completer llm.Completer, model, policy string) *ModelFilter {
+	return &ModelFilter{completer: completer, model: model, policy: policy}
+}
+
+// Name identifies the filter in findings
+func (f *ModelFilter) Name() string {
+	return "model"
+}
+
+// Apply blocks the answer when the model replies BLOCK
+func (f *ModelFilter) Apply(ctx context.Context, text string) (string, []Finding, error) {
+	system := moderationPrompt
+	if f.policy != "" {
+		system += "\n\nPolicy:\n" + f.policy
+	}
+	reply, err := f.completer.Complete(ctx, f.model, system, text)
+	if err != nil {
+		return text, nil, fmt.Errorf("moderation model %s: %w", f.model, err)
+	}
+
+	verdict := strings.TrimSpace(reply)
+	if !strings.HasPrefix(strings.ToUpper(verdict), "BLOCK") {
+		return text, nil, nil
+	}
+	reason := strings.TrimSpace(strings.TrimLeft(verdict[len("BLOCK"):], ":- "))
+	return text, []Finding{{Filter: f.Name(), Rule: f.model, Action: ActionBlock, Reason: reason}}, nil
+}
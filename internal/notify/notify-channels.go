@@ -0,0 +1,223 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"aviagent/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// sendTimeout is the HTTP timeout of a notification
+const sendTimeout = 15 * time.Second
+
+// ErrUnknownChannel is returned when sending to a channel that isn't configured
+var ErrUnknownChannel = errors.New("notification channel not found")
+
+// ChannelInfo describes a configured channel without its secrets
+type ChannelInfo struct {
+	Name   string   `json:"name"`
+	Host   string   `json:"host"`             // the webhook host, the path may carry a token
+	Events []string `json:"events,omitempty"` // subscribed event types, all when empty
+}
+
+// channel is a configured webhook with its parsed body template
+type channel struct {
+	name        string
+	url         string
+	method      string
+	contentType string
+	headers     map[string]string
+	events      []string
+	tmpl        *template.Template // nil sends the event as JSON
+}
+
+// Notifier sends events to the configured webhook channels
+type Notifier struct {
+	channels map[string]*channel // by lowercase name, as viper lowercases map keys
+	client   *http.Client
+	logger   *zap.Logger
+}
+
+// templateFuncs are available in channel templates
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"env":   os.Getenv,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"truncate": func(n int, s string) string {
+		if runes := []rune(s); len(runes) > n {
+			return string(runes[:n]) + "…"
+		}
+		return s
+	},
+}
+
+// New validates the configured channels and parses their templates. Environment variables
+// (${VAR}) in URLs and header values are expanded, so tokens can stay out of config.yaml.
+func New(cfg config.NotificationsConfig, logger *zap.Logger) (*Notifier, error) {
+	n := &Notifier{
+		channels: make(map[string]*channel),
+		client:   &http.Client{Timeout: sendTimeout},
+		logger:   logger,
+	}
+	for name, cc := range cfg.Channels {
+		ch := &channel{
+			name:        strings.ToLower(name),
+			url:         os.ExpandEnv(cc.URL),
+			method:      strings.ToUpper(cc.Method),
+			contentType: cc.ContentType,
+			headers:     make(map[string]string, len(cc.Headers)),
+			events:      cc.Events,
+		}
+		if parsed, err := url.Parse(ch.url); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return nil, fmt.Errorf("notification channel %s: url must be an http(s) URL", name)
+		}
+		if ch.method == "" {
+			ch.method = http.MethodPost
+		}
+		if ch.contentType == "" {
+			ch.contentType = "application/json"
+		}
+		for key, value := range cc.Headers {
+			ch.headers[key] = os.ExpandEnv(value)
+		}
+		if cc.Template != "" {
+			tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=zero").Parse(cc.Template)
+			if err != nil {
+				return nil, fmt.Errorf("notification channel %s: invalid template: %w", name, err)
+			}
+			ch.tmpl = tmpl
+		}
+		n.channels[ch.name] = ch
+	}
+	return n, nil
+}
+
+// Channels lists the configured channels by name
+func (n *Notifier) Channels() []ChannelInfo {
+	channels := make([]ChannelInfo, 0, len(n.channels))
+	for _, ch := range n.channels {
+		info := ChannelInfo{Name: ch.name, Events: ch.events}
+		if parsed, err := url.Parse(ch.url); err == nil {
+			info.Host = parsed.Host
+		}
+		channels = append(channels, info)
+	}
+	sort.Slice(channels, func(i, j int) bool { return channels[i].Name < channels[j].Name })
+	return channels
+}
+
+// Has reports whether a channel is configured
+func (n *Notifier) Has(name string) bool {
+	_, ok := n.channels[strings.ToLower(name)]
+	return ok
+}
+
+// Send sends an event to one channel, whatever events it subscribes to
+func (n *Notifier) Send(ctx context.Context, name string, event Event) error {
+	ch, ok := n.channels[strings.ToLower(name)]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownChannel, name)
+	}
+	return n.send(ctx, ch, event)
+}
+
+// Publish sends an event to every channel subscribed to its type. A failed channel doesn't stop
+// delivery to the others; the failures are returned together.
+func (n *Notifier) Publish(ctx context.Context, event Event) error {
+	var errs []error
+	for _, ch := range n.channels {
+		if !ch.subscribed(event.Type) {
+			continue
+		}
+		if err := n.send(ctx, ch, event); err != nil {
+			n.logger.Warn("Notification failed",
+				zap.String("channel", ch.name),
+				zap.String("event", event.Type),
+				zap.Error(err))
+			errs = append(errs, fmt.Errorf("channel %s: %w", ch.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// subscribed reports whether the channel receives an event type. "*" matches every event and a
+// trailing ".*" matches a prefix ("report.*").
+func (ch *channel) subscribed(eventType string) bool {
+	if len(ch.events) == 0 {
+		return true
+	}
+	for _, pattern := range ch.events {
+		switch {
+		case pattern == "*", pattern == eventType:
+			return true
+		case strings.HasSuffix(pattern, ".*") && strings.HasPrefix(eventType, strings.TrimSuffix(pattern, "*")):
+			return true
+		}
+	}
+	return false
+}
+
+// render builds the request body of an event
+func (ch *channel) render(event Event) ([]byte, error) {
+	if ch.tmpl == nil {
+		return json.Marshal(event)
+	}
+	var body bytes.Buffer
+	if err := ch.tmpl.Execute(&body, event); err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+	return body.Bytes(), nil
+}
+
+// send renders an event and posts it to a channel, failing on a non-2xx response
+func (n *Notifier) send(ctx context.Context, ch *channel, event Event) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	body, err := ch.render(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, ch.method, ch.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ch.contentType)
+	for key, value := range ch.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		// Don't repeat the URL, its path or query may carry a token
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("%s %s: %w", ch.method, req.URL.Host, urlErr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(detail)))
+	}
+	n.logger.Debug("Notification sent", zap.String("channel", ch.name), zap.String("event", event.Type))
+	return nil
+}
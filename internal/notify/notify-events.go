@@ -0,0 +1,46 @@
+package notify
+
+import "time"
+
+// Event types
+const (
+	EventReportDelivered = "report.delivered"
+	EventReportFailed    = "report.failed"
+	EventAlertReceived   = "alert.received"
+	EventRunbookDone     = "runbook.completed"
+	EventRunbookFailed   = "runbook.failed"
+	EventApprovalPending = "approval.requested"
+	EventDriftDetected   = "drift.detected"
+	EventTest            = "test"
+)
+
+// Severities
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Event is something that happened in the agent that channels are notified of. Channel
+// templates receive it as their data: {{.Title}}, {{.Severity}}, {{json .Data}}, ...
+type Event struct {
+	Type     string      `json:"type"`
+	Title    string      `json:"title"`
+	Summary  string      `json:"summary"`
+	Severity string      `json:"severity"`
+	Source   string      `json:"source"` // what raised the event, e.g. the report job
+	Time     time.Time   `json:"time"`
+	Data     interface{} `json:"data,omitempty"`
+}
+
+// TestEvent is sent by the channel test endpoint
+func TestEvent(now time.Time) Event {
+	return Event{
+		Type:     EventTest,
+		Title:    "Test notification from the Avi LLM Agent",
+		Summary:  "This channel is configured correctly.",
+		Severity: SeverityInfo,
+		Source:   "aviagent",
+		Time:     now,
+	}
+}
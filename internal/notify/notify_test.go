@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aviagent/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNotifier(t *testing.T) {
+	t.Setenv("PAGERDUTY_ROUTING_KEY", "routing-key")
+	t.Setenv("MATTERMOST_TOKEN", "mm-token")
+
+	received := make(map[string]string)
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received[r.URL.Path] = string(body)
+		if r.URL.Path == "/mattermost" {
+			auth = r.Header.Get("Authorization")
+		}
+		if r.URL.Path == "/down" {
+			http.Error(w, "maintenance", http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	notifier, err := New(config.NotificationsConfig{Channels: map[string]config.NotificationChannel{
+		"PagerDuty": {
+			URL:      server.URL + "/pagerduty",
+			Template: `{"routing_key": "{{env "PAGERDUTY_ROUTING_KEY"}}", "payload": {"summary": {{json .Title}}, "severity": "{{.Severity}}"}}`,
+			Events:   []string{"report.*"},
+		},
+		"mattermost": {
+			URL:      server.URL + "/mattermost",
+			Headers:  map[string]string{"Authorization": "Bearer ${MATTERMOST_TOKEN}"},
+			Template: `{"text": "{{upper .Severity}}: {{truncate 5 .Summary}}"}`,
+			Events:   []string{"report.failed"},
+		},
+		"raw":  {URL: server.URL + "/raw"},
+		"down": {URL: server.URL + "/down", Events: []string{"test"}},
+	}}, zap.NewNop())
+	require.NoError(t, err)
+	assert.True(t, notifier.Has("pagerduty"))
+	assert.Len(t, notifier.Channels(), 4)
+	assert.Equal(t, "down", notifier.Channels()[0].Name)
+
+	event := Event{Type: EventReportDelivered, Title: `Certs "weekly"`, Summary: "All good", Severity: SeverityInfo, Time: time.Now()}
+	require.NoError(t, notifier.Publish(context.Background(), event))
+	assert.JSONEq(t, `{"routing_key": "routing-key", "payload": {"summary": "Certs \"weekly\"", "severity": "info"}}`, received["/pagerduty"])
+	assert.NotContains(t, received, "/mattermost")
+	var raw Event
+	require.NoError(t, json.Unmarshal([]byte(received["/raw"]), &raw))
+	assert.Equal(t, EventReportDelivered, raw.Type)
+
+	event.Type, event.Severity, event.Summary = EventReportFailed, SeverityWarning, "destination broken"
+	require.NoError(t, notifier.Publish(context.Background(), event))
+	assert.Equal(t, `{"text": "WARNING: desti…"}`, received["/mattermost"])
+	assert.Equal(t, "Bearer mm-token", auth)
+
+	// The test event reaches the channels subscribed to it, failures are collected
+	err = notifier.Publish(context.Background(), TestEvent(time.Now()))
+	assert.ErrorContains(t, err, "503 Service Unavailable: maintenance")
+	assert.ErrorIs(t, notifier.Send(context.Background(), "opsgenie", event), ErrUnknownChannel)
+}
+
+func TestNew_Validation(t *testing.T) {
+	for name, channel := range map[string]config.NotificationChannel{
+		"url":      {URL: "ftp://example.com/hook"},
+		"empty":    {},
+		"template": {URL: "https://example.com/hook", Template: "{{.Title"},
+	} {
+		_, err := New(config.NotificationsConfig{Channels: map[string]config.NotificationChannel{"c": channel}}, zap.NewNop())
+		assert.Error(t, err, name)
+	}
+}
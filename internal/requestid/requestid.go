@@ -0,0 +1,73 @@
+// Package requestid carries the ID of an API request through the context, so the logs of the
+// request and of the LLM and Avi calls it makes can be correlated and users can quote the ID
+// when reporting a failure.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// Header is the request and response header carrying the ID
+const Header = "X-Request-ID"
+
+// maxLength bounds the IDs accepted from clients, so they can't flood the logs
+const maxLength = 128
+
+type idKey struct{}
+
+// New returns a random request ID
+func New() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// Valid reports whether a client-supplied ID can be used as is: letters, digits and . _ : -
+// only, up to 128 characters
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '.', r == '_', r == ':', r == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// With returns a context carrying the request ID
+func With(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, idKey{}, id)
+}
+
+// From returns the request ID stored in the context, empty when there is none
+func From(ctx context.Context) string {
+	id, _ := ctx.Value(idKey{}).(string)
+	return id
+}
+
+// Logger returns logger with the request ID of the context as a field
+func Logger(ctx context.Context, logger *zap.Logger) *zap.Logger {
+	if id := From(ctx); id != "" {
+		return logger.With(zap.String("request_id", id))
+	}
+	return logger
+}
+
+// SetHeader passes the request ID of the context on to an outgoing request
+func SetHeader(ctx context.Context, req *http.Request) {
+	if id := From(ctx); id != "" {
+		req.Header.Set(Header, id)
+	}
+}
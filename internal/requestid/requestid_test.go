@@ -0,0 +1,42 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestValid(t *testing.T) {
+	assert.True(t, Valid(New()))
+	assert.NotEqual(t, New(), New())
+	assert.True(t, Valid("trace-1234:abc_def.0"))
+	assert.False(t, Valid(""))
+	assert.False(t, Valid("two words"))
+	assert.False(t, Valid("line\nbreak"))
+	assert.False(t, Valid(strings.Repeat("a", 129)))
+}
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	assert.Empty(t, From(ctx))
+
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(core)
+	Logger(ctx, logger).Info("without")
+	ctx = With(ctx, "req-1")
+	assert.Equal(t, "req-1", From(ctx))
+	Logger(ctx, logger).Info("with")
+
+	entries := logs.All()
+	assert.Empty(t, entries[0].ContextMap())
+	assert.Equal(t, map[string]interface{}{"request_id": "req-1"}, entries[1].ContextMap())
+
+	req, _ := http.NewRequest(http.MethodGet, "http://controller/api/pool", nil)
+	SetHeader(ctx, req)
+	assert.Equal(t, "req-1", req.Header.Get(Header))
+}
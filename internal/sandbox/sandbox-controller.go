@@ -0,0 +1,718 @@
+package sandbox
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"mime"
+	"net"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"aviagent/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// Credentials accepted by the sandbox controller
+const (
+	Username = "admin"
+	Password = "sandbox"
+)
+
+//go:embed sandbox-data.json
+var sampleData []byte
+
+// dataset is the layout of the bundled sample data
+type dataset struct {
+	Version    string                              `json:"version"`
+	Objects    map[string][]map[string]interface{} `json:"objects"`
+	Runtime    map[string]map[string]interface{}   `json:"runtime"`
+	Singletons map[string]map[string]interface{}   `json:"singletons"` // objects such as cluster that aren't collections
+	BGPPeers   []map[string]interface{}            `json:"bgp_peers"`
+	Events     []map[string]interface{}            `json:"events"` // timed by minutes_ago, relative to the request
+}
+
+// exportModels are the model names a configuration export groups objects under
+var exportModels = map[string]string{
+	"virtualservice":                "VirtualService",
+	"pool":                          "Pool",
+	"healthmonitor":                 "HealthMonitor",
+	"applicationprofile":            "ApplicationProfile",
+	"applicationpersistenceprofile": "ApplicationPersistenceProfile",
+	"sslprofile":                    "SSLProfile",
+	"sslkeyandcertificate":          "SSLKeyAndCertificate",
+	"wafpolicy":                     "WafPolicy",
+	"serviceenginegroup":            "ServiceEngineGroup",
+	"vrfcontext":                    "VrfContext",
+	"backupconfiguration":           "BackupConfiguration",
+	"role":                          "Role",
+	"tenant":                        "Tenant",
+}
+
+// Controller is an in-process mock Avi controller serving the bundled sample data. Changes made
+// through the API are kept in memory only, so every start begins from the same realistic setup.
+type Controller struct {
+	mu      sync.RWMutex
+	version string
+	objects map[string]map[string]map[string]interface{} // type -> uuid -> object
+	order   map[string][]string                          // type -> uuids in creation order
+	runtime map[string]map[string]interface{}
+	singles map[string]map[string]interface{}
+	peers   []map[string]interface{}
+	events  []map[string]interface{}
+	bundles []map[string]interface{} // tech-support bundles collected since the start
+	seq     int
+	server  *http.Server
+	logger  *zap.Logger
+}
+
+// NewController loads the sample data and starts the mock controller on a loopback TLS listener
+func NewController(logger *zap.Logger) (*Controller, error) {
+	var data dataset
+	if err := json.Unmarshal(sampleData, &data); err != nil {
+		return nil, fmt.Errorf("invalid sandbox sample data: %w", err)
+	}
+
+	c := &Controller{
+		version: data.Version,
+		objects: make(map[string]map[string]map[string]interface{}),
+		order:   make(map[string][]string),
+		runtime: data.Runtime,
+		singles: data.Singletons,
+		peers:   data.BGPPeers,
+		events:  data.Events,
+		logger:  logger,
+	}
+	for objType, list := range data.Objects {
+		for _, obj := range list {
+			uuid, _ := obj["uuid"].(string)
+			c.store(objType, uuid, obj)
+		}
+	}
+
+	cert, err := selfSignedCertificate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the sandbox certificate: %w", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on loopback: %w", err)
+	}
+	c.server = &http.Server{
+		Addr:              listener.Addr().String(),
+		Handler:           http.HandlerFunc(c.handle),
+		TLSConfig:         &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12},
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := c.server.ServeTLS(listener, "", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Sandbox controller stopped", zap.Error(err))
+		}
+	}()
+	logger.Info("Sandbox controller started", zap.String("address", c.Host()))
+	return c, nil
+}
+
+// selfSignedCertificate returns a certificate for the loopback address, valid for a day; the
+// clients of the sandbox don't verify it
+func selfSignedCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "sandbox controller"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// Host returns the host:port the sandbox controller listens on
+func (c *Controller) Host() string {
+	return c.server.Addr
+}
+
+// Close stops the sandbox controller
+func (c *Controller) Close() {
+	c.server.Close()
+}
+
+// Configure points an Avi configuration at the sandbox controller
+func (c *Controller) Configure(cfg *config.AviConfig) {
+	cfg.Host = c.Host()
+	cfg.Username = Username
+	cfg.Password = Password
+	cfg.Tenant = "admin"
+	cfg.Version = c.version
+	cfg.AuthMethod = "session"
+	cfg.Insecure = true // self-signed loopback certificate
+	cfg.PinnedFingerprints = nil
+	cfg.Nodes = nil
+	cfg.AnalyticsHost = ""
+	cfg.LeastPrivilege = false
+}
+
+// store adds or replaces an object
+func (c *Controller) store(objType, uuid string, obj map[string]interface{}) {
+	if c.objects[objType] == nil {
+		c.objects[objType] = make(map[string]map[string]interface{})
+	}
+	if _, exists := c.objects[objType][uuid]; !exists {
+		c.order[objType] = append(c.order[objType], uuid)
+	}
+	c.objects[objType][uuid] = obj
+}
+
+// remove deletes an object
+func (c *Controller) remove(objType, uuid string) bool {
+	if _, ok := c.objects[objType][uuid]; !ok {
+		return false
+	}
+	delete(c.objects[objType], uuid)
+	uuids := c.order[objType]
+	for i, id := range uuids {
+		if id == uuid {
+			c.order[objType] = append(uuids[:i:i], uuids[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// list returns copies of the objects of a type in creation order
+func (c *Controller) list(objType string) []map[string]interface{} {
+	result := []map[string]interface{}{}
+	for _, uuid := range c.order[objType] {
+		result = append(result, copyObject(c.objects[objType][uuid]))
+	}
+	return result
+}
+
+// handle routes a request to the matching part of the controller API
+func (c *Controller) handle(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	switch {
+	case path == "login":
+		http.SetCookie(w, &http.Cookie{Name: "csrftoken", Value: "sandbox-csrf-token", Path: "/"})
+		http.SetCookie(w, &http.Cookie{Name: "sessionid", Value: "sandbox-session", Path: "/"})
+		writeJSON(w, http.StatusOK, map[string]interface{}{"user": map[string]interface{}{"username": Username}})
+		return
+	case path == "logout":
+		writeJSON(w, http.StatusOK, map[string]interface{}{})
+		return
+	case !strings.HasPrefix(path, "api/"):
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(path, "api/"), "/")
+	c.logger.Debug("Sandbox controller request", zap.String("method", r.Method), zap.String("path", path))
+
+	switch {
+	case parts[0] == "initial-data":
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"version": map[string]interface{}{"Version": c.version, "Date": time.Now().UTC().Format(time.RFC3339)},
+		})
+	case parts[0] == "configuration" && len(parts) > 1 && parts[1] == "export":
+		c.handleExport(w)
+	case parts[0] == "techsupport" && r.Method == http.MethodGet:
+		c.handleTechSupport(w, r, parts)
+	case parts[0] == "fileservice" && r.Method == http.MethodGet:
+		c.handleFile(w, r.URL.Query().Get("uri"))
+	case parts[0] == "analytics":
+		c.handleAnalytics(w, r, parts)
+	case strings.HasSuffix(parts[0], "-inventory"):
+		c.handleInventory(w, strings.TrimSuffix(parts[0], "-inventory"), parts[1:])
+	case r.Method == http.MethodGet && c.singles[strings.Join(parts, "/")] != nil:
+		writeJSON(w, http.StatusOK, c.singles[strings.Join(parts, "/")])
+	case len(parts) == 3 && parts[0] == "serviceengine" && parts[2] == "bgp":
+		writeJSON(w, http.StatusOK, c.peers)
+	case len(parts) == 3 && r.Method == http.MethodPost:
+		c.handleAction(w, parts[0], parts[1], parts[2])
+	case len(parts) <= 2:
+		c.handleObject(w, r, parts)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// handleObject serves collection and object CRUD
+func (c *Controller) handleObject(w http.ResponseWriter, r *http.Request, parts []string) {
+	objType := parts[0]
+	uuid := ""
+	if len(parts) == 2 {
+		uuid = parts[1]
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		if uuid != "" {
+			obj, ok := c.objects[objType][uuid]
+			if !ok {
+				writeError(w, http.StatusNotFound, fmt.Sprintf("%s %s not found", objType, uuid))
+				return
+			}
+			writeJSON(w, http.StatusOK, copyObject(obj))
+			return
+		}
+		results := filterObjects(c.list(objType), r.URL.Query().Get("name"), r.URL.Query().Get("username"))
+		writeJSON(w, http.StatusOK, map[string]interface{}{"count": len(results), "results": results})
+
+	case http.MethodPost:
+		if uuid != "" {
+			writeError(w, http.StatusMethodNotAllowed, "POST is not allowed on an object")
+			return
+		}
+		obj, err := decodeObject(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.seq++
+		uuid = fmt.Sprintf("%s-sandbox-%d", objType, c.seq)
+		obj["uuid"] = uuid
+		obj["url"] = fmt.Sprintf("/api/%s/%s", objType, uuid)
+		c.store(objType, uuid, obj)
+		writeJSON(w, http.StatusCreated, copyObject(obj))
+
+	case http.MethodPut, http.MethodPatch:
+		obj, err := decodeObject(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		existing, ok := c.objects[objType][uuid]
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("%s %s not found", objType, uuid))
+			return
+		}
+		if r.Method == http.MethodPut {
+			obj["uuid"] = uuid
+			c.store(objType, uuid, obj)
+			if objType == "serviceengine" {
+				c.placeOffServiceEngine(obj)
+			}
+			writeJSON(w, http.StatusOK, copyObject(obj))
+			return
+		}
+		updated := copyObject(existing)
+		applyPatch(updated, obj)
+		c.store(objType, uuid, updated)
+		if objType == "serviceengine" {
+			c.placeOffServiceEngine(updated)
+		}
+		writeJSON(w, http.StatusOK, copyObject(updated))
+
+	case http.MethodDelete:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if !c.remove(objType, uuid) {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("%s %s not found", objType, uuid))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleAction accepts runtime actions such as scaleout or reboot. Running a backup records a
+// new backup object so the effect is visible to later queries.
+func (c *Controller) handleAction(w http.ResponseWriter, objType, uuid, action string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.objects[objType][uuid]; !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("%s %s not found", objType, uuid))
+		return
+	}
+
+	if objType == "backupconfiguration" && action == "backup" {
+		c.seq++
+		now := time.Now().UTC()
+		fileName := fmt.Sprintf("backup_Default-Scheduler_%s.json", now.Format("20060102_150405"))
+		backupUUID := fmt.Sprintf("backup-sandbox-%d", c.seq)
+		c.store("backup", backupUUID, map[string]interface{}{
+			"uuid":              backupUUID,
+			"backup_config_ref": fmt.Sprintf("/api/backupconfiguration/%s", uuid),
+			"file_name":         fileName,
+			"local_file_url":    "/var/lib/avi/backups/" + fileName,
+			"timestamp":         now.Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status": fmt.Sprintf("%s %s on %s accepted (sandbox)", objType, action, uuid),
+	})
+}
+
+// placeOffServiceEngine migrates the virtual services placed on a service engine that is no longer
+// enabled to another enabled service engine of its group, as the controller does
+func (c *Controller) placeOffServiceEngine(se map[string]interface{}) {
+	if state, _ := se["enable_state"].(string); state == "" || state == "SE_STATE_ENABLED" {
+		return
+	}
+	uuid, _ := se["uuid"].(string)
+	group, _ := se["se_group_ref"].(string)
+	var target map[string]interface{}
+	for _, other := range c.list("serviceengine") {
+		state, _ := other["enable_state"].(string)
+		otherGroup, _ := other["se_group_ref"].(string)
+		if other["uuid"] != uuid && otherGroup == group && (state == "" || state == "SE_STATE_ENABLED") {
+			target = other
+			break
+		}
+	}
+
+	for _, rt := range c.runtime {
+		vips, _ := rt["vip_summary"].([]interface{})
+		for _, v := range vips {
+			vip, _ := v.(map[string]interface{})
+			engines, _ := vip["service_engine"].([]interface{})
+			kept := []interface{}{}
+			moved, hasTarget := false, false
+			for _, e := range engines {
+				engine, _ := e.(map[string]interface{})
+				switch {
+				case engine["uuid"] == uuid:
+					moved = true
+					continue
+				case target != nil && engine["uuid"] == target["uuid"]:
+					hasTarget = true
+				}
+				kept = append(kept, engine)
+			}
+			if moved && target != nil && !hasTarget {
+				kept = append(kept, map[string]interface{}{
+					"uuid":    target["uuid"],
+					"url":     fmt.Sprintf("/api/serviceengine/%s#%s", target["uuid"], target["name"]),
+					"primary": len(kept) == 0,
+				})
+			}
+			vip["service_engine"] = kept
+		}
+	}
+}
+
+// handleInventory serves /<type>-inventory with config, runtime state and health score
+func (c *Controller) handleInventory(w http.ResponseWriter, objType string, rest []string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	inventory := func(obj map[string]interface{}) map[string]interface{} {
+		uuid, _ := obj["uuid"].(string)
+		item := map[string]interface{}{"uuid": uuid, "config": obj}
+		if rt, ok := c.runtime[uuid]; ok {
+			runtime := map[string]interface{}{"oper_status": rt["oper_status"]}
+			if vips, ok := rt["vip_summary"]; ok {
+				runtime["vip_summary"] = vips
+			}
+			item["runtime"] = runtime
+			item["health_score"] = rt["health_score"]
+		} else {
+			item["runtime"] = map[string]interface{}{"oper_status": map[string]interface{}{"state": "OPER_UP"}}
+			item["health_score"] = map[string]interface{}{"health_score": 100.0, "performance_score": 100.0}
+		}
+		return item
+	}
+
+	if len(rest) > 0 && rest[0] != "" {
+		obj, ok := c.objects[objType][rest[0]]
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("%s %s not found", objType, rest[0]))
+			return
+		}
+		writeJSON(w, http.StatusOK, inventory(copyObject(obj)))
+		return
+	}
+
+	results := []map[string]interface{}{}
+	for _, obj := range c.list(objType) {
+		results = append(results, inventory(obj))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"count": len(results), "results": results})
+}
+
+// handleAnalytics serves health score and metrics series built from the sample runtime data
+func (c *Controller) handleAnalytics(w http.ResponseWriter, r *http.Request, parts []string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(parts) == 4 && parts[1] == "healthscore" {
+		score := map[string]interface{}{"health_score": 100.0, "performance_score": 100.0}
+		if rt, ok := c.runtime[parts[3]]; ok {
+			if hs, ok := rt["health_score"].(map[string]interface{}); ok {
+				score = hs
+			}
+		}
+		var points []map[string]interface{}
+		now := time.Now().UTC().Truncate(5 * time.Minute)
+		for i := 5; i >= 0; i-- {
+			point := map[string]interface{}{"timestamp": now.Add(-time.Duration(i) * 5 * time.Minute).Format("2006-01-02T15:04:05+00:00")}
+			for k, v := range score {
+				if k != "reason" {
+					point[k] = v
+				}
+			}
+			points = append(points, point)
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"series": []interface{}{map[string]interface{}{
+				"header": map[string]interface{}{"metric_id": "healthscore.health_score_value", "entity_uuid": parts[3]},
+				"data":   points,
+			}},
+		})
+		return
+	}
+
+	if len(parts) >= 2 && parts[1] == "metrics" {
+		step, _ := strconv.Atoi(r.URL.Query().Get("step"))
+		if step <= 0 {
+			step = 300
+		}
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit <= 0 || limit > 288 {
+			limit = 12
+		}
+		metricIDs := strings.Split(r.URL.Query().Get("metric_id"), ",")
+		if metricIDs[0] == "" {
+			metricIDs = []string{"l4_client.avg_complete_conns"}
+		}
+
+		interval := time.Duration(step) * time.Second
+		now := time.Now().UTC().Truncate(interval)
+		var series []interface{}
+		for n, metricID := range metricIDs {
+			var points []map[string]interface{}
+			for i := limit - 1; i >= 0; i-- {
+				points = append(points, map[string]interface{}{
+					"timestamp": now.Add(-time.Duration(i) * interval).Format("2006-01-02T15:04:05+00:00"),
+					"value":     float64(120 + (i*37+n*53)%90),
+				})
+			}
+			series = append(series, map[string]interface{}{
+				"header": map[string]interface{}{"name": metricID, "entity_uuid": parts[len(parts)-1]},
+				"data":   points,
+			})
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"series": series})
+		return
+	}
+
+	if len(parts) == 2 && parts[1] == "logs" {
+		c.handleEventLogs(w, r)
+		return
+	}
+
+	writeError(w, http.StatusNotFound, "analytics endpoint not available in the sandbox")
+}
+
+// handleEventLogs serves the sample events, newest first, within the start and end of the query.
+// The event_id filter isn't applied: the sample only has virtual service state changes.
+func (c *Controller) handleEventLogs(w http.ResponseWriter, r *http.Request) {
+	now := time.Now().UTC()
+	start, _ := time.Parse(time.RFC3339, r.URL.Query().Get("start"))
+	end, err := time.Parse(time.RFC3339, r.URL.Query().Get("end"))
+	if err != nil {
+		end = now
+	}
+
+	results := []map[string]interface{}{}
+	for i := len(c.events) - 1; i >= 0; i-- {
+		event := c.events[i]
+		minutes, _ := event["minutes_ago"].(float64)
+		at := now.Add(-time.Duration(minutes) * time.Minute)
+		if at.Before(start) || at.After(end) {
+			continue
+		}
+		log := map[string]interface{}{"report_timestamp": at.Format("2006-01-02T15:04:05.000000")}
+		for k, v := range event {
+			if k != "minutes_ago" {
+				log[k] = v
+			}
+		}
+		results = append(results, log)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"count": len(results), "results": results})
+}
+
+// handleExport serves a configuration export grouped by model name
+func (c *Controller) handleExport(w http.ResponseWriter) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	export := map[string]interface{}{
+		"META": map[string]interface{}{"version": map[string]interface{}{"Version": c.version}},
+	}
+	types := make([]string, 0, len(exportModels))
+	for objType := range exportModels {
+		types = append(types, objType)
+	}
+	sort.Strings(types)
+	for _, objType := range types {
+		if objects := c.list(objType); len(objects) > 0 {
+			export[exportModels[objType]] = objects
+		}
+	}
+	writeJSON(w, http.StatusOK, export)
+}
+
+// handleTechSupport lists the tech-support bundles, or collects one for /techsupport/<level>.
+// Collections finish at once.
+func (c *Controller) handleTechSupport(w http.ResponseWriter, r *http.Request, parts []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(parts) == 1 {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"count": len(c.bundles), "results": c.bundles})
+		return
+	}
+	level := parts[1]
+	if uuid := r.URL.Query().Get("uuid"); uuid != "" {
+		if _, ok := c.objects[level][uuid]; !ok {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("%s %s not found", level, uuid))
+			return
+		}
+	}
+	c.seq++
+	now := time.Now().UTC()
+	bundle := map[string]interface{}{
+		"name":       fmt.Sprintf("techsupport_%s_%s_%d.tar.gz", level, now.Format("20060102_150405"), c.seq),
+		"level":      level,
+		"size":       4096,
+		"start_time": now.Format(time.RFC3339),
+	}
+	if caseNumber := r.URL.Query().Get("case_number"); caseNumber != "" {
+		bundle["case_number"] = caseNumber
+	}
+	c.bundles = append(c.bundles, bundle)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "Tech-support collection started", "level": level})
+}
+
+// handleFile serves the controller files of the fileservice API, e.g.
+// uri=controller://tech_support/bundle.tar.gz, as gzip archives of a short text
+func (c *Controller) handleFile(w http.ResponseWriter, uri string) {
+	name, ok := strings.CutPrefix(uri, "controller://")
+	if !ok || name == "" || strings.Contains(name, "..") {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("file %q not found", uri))
+		return
+	}
+	var data bytes.Buffer
+	archive := gzip.NewWriter(&data)
+	fmt.Fprintf(archive, "Sandbox controller file %s, generated %s\n", name, time.Now().UTC().Format(time.RFC3339))
+	archive.Close()
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(name)}))
+	w.Header().Set("Content-Length", strconv.Itoa(data.Len()))
+	w.WriteHeader(http.StatusOK)
+	w.Write(data.Bytes())
+}
+
+// filterObjects applies the name and username query filters of collection requests
+func filterObjects(objects []map[string]interface{}, name, username string) []map[string]interface{} {
+	if name == "" && username == "" {
+		return objects
+	}
+	result := []map[string]interface{}{}
+	for _, obj := range objects {
+		if name != "" && obj["name"] != name {
+			continue
+		}
+		if username != "" && obj["username"] != username {
+			continue
+		}
+		result = append(result, obj)
+	}
+	return result
+}
+
+// applyPatch applies an Avi PATCH body ({"replace": {...}}, {"add": {...}} or {"delete": {...}})
+func applyPatch(obj, patch map[string]interface{}) {
+	if replace, ok := patch["replace"].(map[string]interface{}); ok {
+		for k, v := range replace {
+			obj[k] = v
+		}
+	}
+	if add, ok := patch["add"].(map[string]interface{}); ok {
+		for k, v := range add {
+			if list, ok := v.([]interface{}); ok {
+				existing, _ := obj[k].([]interface{})
+				obj[k] = append(existing, list...)
+			} else {
+				obj[k] = v
+			}
+		}
+	}
+	if del, ok := patch["delete"].(map[string]interface{}); ok {
+		for k := range del {
+			delete(obj, k)
+		}
+	}
+}
+
+// decodeObject reads a JSON object request body
+func decodeObject(r *http.Request) (map[string]interface{}, error) {
+	obj := make(map[string]interface{})
+	if err := json.NewDecoder(r.Body).Decode(&obj); err != nil {
+		return nil, fmt.Errorf("invalid request body: %w", err)
+	}
+	return obj, nil
+}
+
+// copyObject deep-copies an object so callers can't modify the store
+func copyObject(obj map[string]interface{}) map[string]interface{} {
+	raw, _ := json.Marshal(obj)
+	var dup map[string]interface{}
+	json.Unmarshal(raw, &dup)
+	return dup
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeError writes an Avi-style error response
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{"error": message})
+}
@@ -0,0 +1,247 @@
+package sandbox
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"aviagent/pkg/avi"
+	"aviagent/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/alb-sdk/go/models"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestSandboxController(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	controller, err := NewController(logger)
+	require.NoError(t, err)
+	defer controller.Close()
+
+	cfg := &config.AviConfig{Timeout: 10}
+	controller.Configure(cfg)
+	client, err := avi.NewOfficialClient(cfg, logger)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	// Sample data is served through the SDK like a real controller
+	raw, err := client.ExecuteGenericOperation(ctx, "GET", "/virtualservice", nil, nil)
+	require.NoError(t, err)
+	collection := raw.(map[string]interface{})
+	assert.Equal(t, float64(4), collection["count"])
+
+	vs, err := client.GetVirtualService(ctx, "virtualservice-7d1c2f0e-shop", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "shop-web-vs", *vs.(*models.VirtualService).Name)
+
+	// Changes are applied to the in-memory store
+	_, err = client.SetVirtualServiceEnabled(ctx, "virtualservice-e5f7a310-legacy", true)
+	require.NoError(t, err)
+	raw, err = client.ExecuteGenericOperation(ctx, "GET", "/virtualservice/virtualservice-e5f7a310-legacy", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, true, raw.(map[string]interface{})["enabled"])
+
+	// Inventory and health score feed the health tools
+	raw, err = client.GetInventory(ctx, "pool", "pool-f18b3e05-legacy", nil)
+	require.NoError(t, err)
+	summary := avi.SummarizeInventory(raw)
+	require.Len(t, summary, 1)
+	assert.Equal(t, "OPER_DOWN", summary[0].OperState)
+
+	raw, err = client.GetHealthScore(ctx, "virtualservice", "virtualservice-a41f88c2-api", nil)
+	require.NoError(t, err)
+	assert.Equal(t, float64(61), avi.SummarizeHealthScore("virtualservice-a41f88c2-api", raw).HealthScore)
+
+	// Running a backup records a new backup
+	_, err = client.TriggerBackup(ctx, "backupconfiguration-0c7e-default")
+	require.NoError(t, err)
+	raw, err = client.ListBackups(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, float64(3), raw.(map[string]interface{})["count"])
+
+	// Singleton system objects answer the controller system tools
+	info, err := avi.GetControllerInfo(ctx, client)
+	require.NoError(t, err)
+	assert.Equal(t, "31.2.1", info.Version)
+	assert.Equal(t, "CLUSTER_UP_HA_ACTIVE", info.ClusterState)
+	require.Len(t, info.Nodes, 3)
+	assert.Equal(t, "CLUSTER_LEADER", info.Nodes[0].Role)
+
+	upgrade, err := avi.GetUpgradeStatus(ctx, client)
+	require.NoError(t, err)
+	assert.False(t, upgrade.InProgress)
+	assert.Len(t, upgrade.Nodes, 2)
+
+	license, err := avi.GetLicenseSummary(ctx, client)
+	require.NoError(t, err)
+	assert.Equal(t, float64(40), license.TotalCores)
+	assert.Equal(t, float64(36), license.AvailableCores)
+	assert.True(t, license.Licenses[1].Expired)
+
+	// Metrics queries return a series per requested metric ID
+	query, err := avi.BuildMetricsQuery("virtualservice", "virtualservice-a41f88c2-api", []string{"l4_client.avg_bandwidth", "errors"}, "6h")
+	require.NoError(t, err)
+	metrics, err := avi.QueryMetrics(ctx, client, query)
+	require.NoError(t, err)
+	require.Len(t, metrics.Series, 2)
+	assert.Equal(t, "l4_client.avg_bandwidth", metrics.Series[0].MetricID)
+	assert.Len(t, metrics.Series[0].Points, 72)
+
+	// Files are streamed instead of decoded, and generic reads describe them
+	params := map[string]string{"uri": "controller://tech_support/bundle.tar.gz"}
+	download, err := client.Download(ctx, "/fileservice", params)
+	require.NoError(t, err)
+	archive, err := gzip.NewReader(download.Body)
+	require.NoError(t, err)
+	content, err := io.ReadAll(archive)
+	require.NoError(t, err)
+	require.NoError(t, download.Body.Close())
+	assert.Contains(t, string(content), "tech_support/bundle.tar.gz")
+	assert.Equal(t, "bundle.tar.gz", download.Filename)
+	assert.Equal(t, "application/gzip", download.ContentType)
+
+	raw, err = client.ExecuteGenericOperation(ctx, "GET", "/fileservice", nil, params)
+	require.NoError(t, err)
+	file := raw.(*avi.FileResult)
+	assert.Equal(t, "/api/v1/avi/fileservice?download=true&uri=controller%3A%2F%2Ftech_support%2Fbundle.tar.gz", file.DownloadURL)
+	assert.Equal(t, download.ContentLength, file.Size)
+
+	_, err = client.Download(ctx, "/fileservice", map[string]string{"uri": "controller://../etc/passwd"})
+	assert.ErrorContains(t, err, "status 404")
+
+	// Tech-support collections produce a bundle that is downloaded through the proxy
+	result, err := avi.CollectTechSupport(ctx, client, avi.TechSupportRequest{Level: "virtualservice", UUID: "virtualservice-7d1c2f0e-shop", CaseNumber: "01234567"}, time.Second)
+	require.NoError(t, err)
+	bundle := result.(*avi.FileResult)
+	assert.True(t, strings.HasPrefix(bundle.Filename, "techsupport_virtualservice_"), bundle.Filename)
+	assert.Contains(t, bundle.DownloadURL, "controller%3A%2F%2Ftech_support%2Ftechsupport_virtualservice_")
+	bundles, err := avi.ListTechSupportBundles(ctx, client)
+	require.NoError(t, err)
+	require.Len(t, bundles, 1)
+	assert.Equal(t, bundle.DownloadURL, bundles[0].DownloadURL)
+
+	_, err = avi.CollectTechSupport(ctx, client, avi.TechSupportRequest{Level: "pool"}, time.Second)
+	assert.ErrorContains(t, err, "uuid parameter required")
+	_, err = avi.CollectTechSupport(ctx, client, avi.TechSupportRequest{Level: "everything"}, time.Second)
+	assert.ErrorContains(t, err, "unknown tech-support level")
+
+	// Alert rules are previewed, created, listed and removed as an AlertConfig and ActionGroupConfig pair
+	request := avi.AlertRuleRequest{VirtualService: "payments-api-vs", Metric: "error_rate", Threshold: 2, Duration: "5m", Severity: "high"}
+	plan, err := avi.CreateAlertRule(ctx, client, request, true)
+	require.NoError(t, err)
+	assert.Equal(t, "aviagent-payments-api-vs-error-rate", plan.Name)
+	assert.Equal(t, "payments-api-vs l7_client.pct_response_errors > 2 percent for 5m", plan.Summary)
+	rules, err := avi.ListAlertRules(ctx, client)
+	require.NoError(t, err)
+	assert.Empty(t, rules)
+
+	request.Name = "payments-errors"
+	_, err = avi.CreateAlertRule(ctx, client, request, false)
+	require.NoError(t, err)
+	_, err = avi.CreateAlertRule(ctx, client, request, false)
+	assert.ErrorContains(t, err, "already exists")
+	rules, err = avi.ListAlertRules(ctx, client)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	assert.Equal(t, avi.AlertRule{Name: "aviagent-payments-errors", UUID: rules[0].UUID, VirtualService: "payments-api-vs",
+		Metric: "l7_client.pct_response_errors", Comparator: ">", Threshold: 2, Duration: 300, Severity: "high", Enabled: true,
+		Summary: plan.Summary}, rules[0])
+
+	require.NoError(t, avi.DeleteAlertRule(ctx, client, "payments-errors"))
+	rules, err = avi.ListAlertRules(ctx, client)
+	require.NoError(t, err)
+	assert.Empty(t, rules)
+	raw, err = client.ExecuteGenericOperation(ctx, "GET", "/actiongroupconfig", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, float64(0), raw.(map[string]interface{})["count"])
+
+	// The sample outages show in the SLA report
+	sla, err := avi.GetAvailability(ctx, client, "7d", 99.9, nil, time.Now())
+	require.NoError(t, err)
+	require.Len(t, sla.VirtualServices, 4)
+	worst := sla.VirtualServices[0]
+	assert.Equal(t, "payments-api-vs", worst.Name)
+	assert.Equal(t, 2, worst.Outages)
+	assert.InDelta(t, 48*60, worst.DowntimeSeconds, 60)
+	assert.True(t, sla.VirtualServices[3].Disabled)
+
+	// Service engine maintenance migrates the virtual services to the other service engine
+	step, err := avi.ServiceEngineMaintenance(ctx, client, "Avi-se-tpbqk", avi.MaintenancePlan, time.Second)
+	require.NoError(t, err)
+	require.Len(t, step.VirtualServices, 2)
+	assert.Equal(t, avi.MaintenanceDisable, step.NextStep)
+	_, err = avi.ServiceEngineMaintenance(ctx, client, "Avi-se-tpbqk", avi.MaintenanceVerify, time.Second)
+	assert.ErrorContains(t, err, "disable it first")
+	for _, name := range []string{avi.MaintenanceDisable, avi.MaintenanceWait, avi.MaintenanceVerify} {
+		step, err = avi.ServiceEngineMaintenance(ctx, client, "Avi-se-tpbqk", name, time.Second)
+		require.NoError(t, err, name)
+	}
+	assert.Equal(t, avi.MaintenanceEnable, step.NextStep)
+	for _, vs := range step.VirtualServices {
+		assert.Equal(t, []string{"Avi-se-xmvrz"}, vs.ServiceEngines, vs.Name)
+	}
+	_, err = avi.ServiceEngineMaintenance(ctx, client, "Avi-se-xmvrz", avi.MaintenanceDisable, time.Second)
+	assert.ErrorContains(t, err, "no other enabled service engine")
+	step, err = avi.ServiceEngineMaintenance(ctx, client, "Avi-se-tpbqk", avi.MaintenanceEnable, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "SE_STATE_ENABLED", step.EnableState)
+}
+
+func TestAnalyticsHost(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	leader, err := NewController(logger)
+	require.NoError(t, err)
+	defer leader.Close()
+	follower, err := NewController(logger)
+	require.NoError(t, err)
+	defer follower.Close()
+
+	// The follower is reached through a proxy counting analytics queries, failing them on demand
+	var analytics atomic.Int32
+	var failing atomic.Bool
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "https", Host: follower.Host()})
+	proxy.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	front := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/analytics/") {
+			if failing.Load() {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			analytics.Add(1)
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	defer front.Close()
+
+	cfg := &config.AviConfig{Timeout: 10}
+	leader.Configure(cfg)
+	cfg.AnalyticsHost = front.Listener.Addr().String()
+	client, err := avi.NewOfficialClient(cfg, logger)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	raw, err := client.GetHealthScore(ctx, "virtualservice", "virtualservice-a41f88c2-api", nil)
+	require.NoError(t, err)
+	assert.Equal(t, float64(61), avi.SummarizeHealthScore("virtualservice-a41f88c2-api", raw).HealthScore)
+	assert.Equal(t, int32(1), analytics.Load())
+	_, err = client.ExecuteGenericOperation(ctx, "GET", "/virtualservice", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), analytics.Load())
+
+	// A failing follower falls back to the leader
+	failing.Store(true)
+	_, err = client.GetHealthScore(ctx, "virtualservice", "virtualservice-a41f88c2-api", nil)
+	assert.NoError(t, err)
+}
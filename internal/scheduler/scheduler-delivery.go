@@ -0,0 +1,109 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"aviagent/internal/notify"
+	"aviagent/pkg/config"
+)
+
+// Destination types
+const (
+	DestinationWebhook = "webhook"
+	DestinationSlack   = "slack"
+	DestinationEmail   = "email"
+
+	DestinationNotification = "notification" // a channel of the notifications section
+)
+
+// deliver sends a report to a destination
+func (s *Scheduler) deliver(ctx context.Context, dest config.ReportDestination, report *Report) error {
+	switch dest.Type {
+	case DestinationWebhook:
+		return s.postJSON(ctx, dest.URL, dest.Headers, report)
+	case DestinationSlack:
+		message := map[string]string{"text": fmt.Sprintf("*%s*\n```%s```", report.Title, strings.TrimRight(report.Text, "\n"))}
+		return s.postJSON(ctx, dest.URL, nil, message)
+	case DestinationEmail:
+		return sendEmail(s.cfg.SMTP, dest.To, report)
+	case DestinationNotification:
+		return s.notifier.Send(ctx, dest.Channel, reportEvent(report))
+	}
+	return fmt.Errorf("unknown destination type %q", dest.Type)
+}
+
+// postJSON posts a JSON body and fails on a non-2xx response
+func (s *Scheduler) postJSON(ctx context.Context, url string, headers map[string]string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", url, resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// sendEmail mails a report as plain text through the configured SMTP server
+func sendEmail(cfg config.SMTPConfig, to []string, report *Report) error {
+	if cfg.Host == "" || cfg.From == "" {
+		return fmt.Errorf("email destinations need scheduler.smtp.host and scheduler.smtp.from")
+	}
+	if len(to) == 0 {
+		return fmt.Errorf("email destination has no recipients")
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", report.Title)
+	fmt.Fprintf(&msg, "Date: %s\r\n", report.Generated.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(report.Text, "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	return smtp.SendMail(addr, auth, cfg.From, to, msg.Bytes())
+}
+
+// reportEvent is the notification of a delivered report
+func reportEvent(report *Report) notify.Event {
+	return notify.Event{
+		Type:     notify.EventReportDelivered,
+		Title:    report.Title,
+		Summary:  report.Text,
+		Severity: notify.SeverityInfo,
+		Source:   report.Job,
+		Time:     report.Generated,
+		Data:     report.Data,
+	}
+}
@@ -0,0 +1,318 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"aviagent/internal/locale"
+	"aviagent/internal/notify"
+	"aviagent/pkg/avi"
+	"aviagent/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// Run limits
+const (
+	jobTimeout      = 2 * time.Minute  // reading and delivering one report
+	deliveryTimeout = 30 * time.Second // HTTP timeout of webhook and Slack deliveries
+)
+
+// ErrJobNotFound is returned when running a job that isn't configured
+var ErrJobNotFound = errors.New("report job not found")
+
+// JobStatus is a scheduled job with its next and last run
+type JobStatus struct {
+	Name         string    `json:"name"`
+	Schedule     string    `json:"schedule"`
+	Report       string    `json:"report"`
+	Destinations []string  `json:"destinations"`
+	NextRun      time.Time `json:"next_run"`
+	LastRun      time.Time `json:"last_run,omitempty"`
+	LastError    string    `json:"last_error,omitempty"`
+}
+
+// job is a configured report job with its parsed schedule and locale
+type job struct {
+	config.ReportJob
+	schedule Schedule
+	locale   locale.Locale
+	status   JobStatus
+	last     *Report // last report built, shown in the UI
+}
+
+// Scheduler runs report jobs on their cron schedules and delivers the rendered reports
+type Scheduler struct {
+	cfg      config.SchedulerConfig
+	exec     avi.GenericExecutor
+	notifier *notify.Notifier // notification destinations and failure events
+	logger   *zap.Logger
+	client   *http.Client
+	location *time.Location
+	now      func() time.Time
+
+	destinations map[string]config.ReportDestination // by lowercase name, as viper lowercases map keys
+
+	mu   sync.Mutex
+	jobs []*job
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New validates the configured jobs and destinations and creates a scheduler. Call Start to run it.
+// Failed runs are published to the notification channels subscribed to report.failed.
+func New(cfg config.SchedulerConfig, exec avi.GenericExecutor, notifier *notify.Notifier, logger *zap.Logger) (*Scheduler, error) {
+	location := time.UTC
+	if cfg.Timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(cfg.Timezone); err != nil {
+			return nil, fmt.Errorf("invalid scheduler timezone: %w", err)
+		}
+	}
+
+	s := &Scheduler{
+		cfg:      cfg,
+		exec:     exec,
+		notifier: notifier,
+		logger:   logger,
+		client:   &http.Client{Timeout: deliveryTimeout},
+		location: location,
+		now:      time.Now,
+
+		destinations: make(map[string]config.ReportDestination),
+	}
+	for name, dest := range cfg.Destinations {
+		s.destinations[strings.ToLower(name)] = dest
+	}
+
+	names := make(map[string]bool)
+	for _, jc := range cfg.Jobs {
+		if jc.Name == "" || names[jc.Name] {
+			return nil, fmt.Errorf("report jobs need a unique name (got %q)", jc.Name)
+		}
+		names[jc.Name] = true
+		schedule, err := ParseSchedule(jc.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("job %s: %w", jc.Name, err)
+		}
+		if _, ok := reportBuilders[jc.Report]; !ok {
+			return nil, fmt.Errorf("job %s: unknown report %q (use %s, %s, %s, %s or %s)", jc.Name, jc.Report, ReportHealthSummary, ReportCertExpiry, ReportCapacity, ReportSLA, ReportChangeDigest)
+		}
+		tag := jc.Locale
+		if tag == "" {
+			tag = cfg.Locale
+		}
+		loc, err := locale.Parse(tag)
+		if err != nil {
+			return nil, fmt.Errorf("job %s: %w", jc.Name, err)
+		}
+		if len(jc.Destinations) == 0 {
+			return nil, fmt.Errorf("job %s: no destinations", jc.Name)
+		}
+		for _, name := range jc.Destinations {
+			dest, ok := s.destinations[strings.ToLower(name)]
+			if !ok {
+				return nil, fmt.Errorf("job %s: unknown destination %q", jc.Name, name)
+			}
+			switch dest.Type {
+			case DestinationWebhook, DestinationSlack:
+				if dest.URL == "" {
+					return nil, fmt.Errorf("destination %s: url is required", name)
+				}
+			case DestinationEmail:
+				if len(dest.To) == 0 {
+					return nil, fmt.Errorf("destination %s: to is required", name)
+				}
+			case DestinationNotification:
+				if !notifier.Has(dest.Channel) {
+					return nil, fmt.Errorf("destination %s: unknown notification channel %q", name, dest.Channel)
+				}
+			default:
+				return nil, fmt.Errorf("destination %s: unknown type %q (use webhook, slack, email or notification)", name, dest.Type)
+			}
+		}
+		s.jobs = append(s.jobs, &job{
+			ReportJob: jc,
+			schedule:  schedule,
+			locale:    loc,
+			status:    JobStatus{Name: jc.Name, Schedule: jc.Schedule, Report: jc.Report, Destinations: jc.Destinations},
+		})
+	}
+	return s, nil
+}
+
+// Start runs the jobs in the background until Stop is called
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+
+	now := s.now().In(s.location)
+	s.mu.Lock()
+	for _, j := range s.jobs {
+		j.status.NextRun = j.schedule.Next(now)
+	}
+	s.mu.Unlock()
+
+	go s.loop(ctx)
+	s.logger.Info("Report scheduler started", zap.Int("jobs", len(s.jobs)), zap.String("timezone", s.location.String()))
+}
+
+// Stop stops the scheduler and waits for a running job to finish
+func (s *Scheduler) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+}
+
+// loop sleeps until the next job is due, runs the due jobs and schedules their next run
+func (s *Scheduler) loop(ctx context.Context) {
+	defer close(s.done)
+	for {
+		next, ok := s.nextRun()
+		if !ok {
+			return
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		now := s.now().In(s.location)
+		for _, j := range s.dueJobs(now) {
+			if _, err := s.run(ctx, j); err != nil {
+				s.logger.Error("Scheduled report failed", zap.String("job", j.Name), zap.Error(err))
+			}
+		}
+	}
+}
+
+// nextRun returns the earliest next run of all jobs
+func (s *Scheduler) nextRun() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next time.Time
+	for _, j := range s.jobs {
+		if !j.status.NextRun.IsZero() && (next.IsZero() || j.status.NextRun.Before(next)) {
+			next = j.status.NextRun
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// dueJobs returns the jobs due at now and advances their next run
+func (s *Scheduler) dueJobs(now time.Time) []*job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []*job
+	for _, j := range s.jobs {
+		if !j.status.NextRun.IsZero() && !j.status.NextRun.After(now) {
+			due = append(due, j)
+			j.status.NextRun = j.schedule.Next(now)
+		}
+	}
+	return due
+}
+
+// Jobs returns the configured jobs with their next and last run, by name
+func (s *Scheduler) Jobs() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := make([]JobStatus, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j.status)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Name < jobs[j].Name })
+	return jobs
+}
+
+// Latest returns the most recent report of a kind, such as the change digest pinned in the UI, or
+// nil when no job has built one yet
+func (s *Scheduler) Latest(kind string) *Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var latest *Report
+	for _, j := range s.jobs {
+		if j.last != nil && j.last.Kind == kind && (latest == nil || j.last.Generated.After(latest.Generated)) {
+			latest = j.last
+		}
+	}
+	return latest
+}
+
+// Run runs a job now, outside its schedule, and returns the delivered report
+func (s *Scheduler) Run(ctx context.Context, name string) (*Report, error) {
+	for _, j := range s.jobs {
+		if j.Name == name {
+			return s.run(ctx, j)
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrJobNotFound, name)
+}
+
+// run builds a job's report and delivers it to every destination. A failed destination doesn't
+// stop delivery to the others.
+func (s *Scheduler) run(ctx context.Context, j *job) (*Report, error) {
+	ctx, cancel := context.WithTimeout(ctx, jobTimeout)
+	defer cancel()
+
+	now := s.now().In(s.location)
+	report, err := reportBuilders[j.Report](ctx, s.exec, j.ReportJob, j.locale, now)
+	if err == nil {
+		report.Job = j.Name
+		report.Kind = j.Report
+		report.Generated = now
+
+		var errs []error
+		for _, name := range j.Destinations {
+			if derr := s.deliver(ctx, s.destinations[strings.ToLower(name)], report); derr != nil {
+				errs = append(errs, fmt.Errorf("destination %s: %w", name, derr))
+			}
+		}
+		err = errors.Join(errs...)
+	}
+
+	s.mu.Lock()
+	j.status.LastRun = now
+	if report != nil {
+		j.last = report
+	}
+	j.status.LastError = ""
+	if err != nil {
+		j.status.LastError = err.Error()
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		failure := notify.Event{
+			Type:     notify.EventReportFailed,
+			Title:    fmt.Sprintf("Scheduled report %s failed", j.Name),
+			Summary:  err.Error(),
+			Severity: notify.SeverityWarning,
+			Source:   j.Name,
+			Time:     now,
+		}
+		if nerr := s.notifier.Publish(ctx, failure); nerr != nil {
+			s.logger.Warn("Could not notify of the failed report", zap.String("job", j.Name), zap.Error(nerr))
+		}
+		return report, err
+	}
+	s.logger.Info("Delivered scheduled report", zap.String("job", j.Name), zap.String("report", j.Report))
+	return report, nil
+}
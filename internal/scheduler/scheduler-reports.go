@@ -0,0 +1,210 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"aviagent/internal/locale"
+	"aviagent/pkg/avi"
+	"aviagent/pkg/config"
+)
+
+// Predefined reports
+const (
+	ReportHealthSummary = "health_summary"
+	ReportCertExpiry    = "cert_expiry"
+	ReportCapacity      = "capacity"
+	ReportSLA           = "sla"
+	ReportChangeDigest  = "change_digest"
+)
+
+// defaultExpiryWindow is the cert_expiry window in days when a job doesn't set one
+const defaultExpiryWindow = 30
+
+// Report is a report rendered for delivery: plain text for chat and email, data for webhooks
+type Report struct {
+	Job       string      `json:"job"`
+	Kind      string      `json:"report"`
+	Title     string      `json:"title"`
+	Generated time.Time   `json:"generated"`
+	Text      string      `json:"text"`
+	Data      interface{} `json:"data"`
+}
+
+// reportBuilder reads the data of a report and renders its text in the job's locale
+type reportBuilder func(ctx context.Context, exec avi.GenericExecutor, job config.ReportJob, loc locale.Locale, now time.Time) (*Report, error)
+
+// reportBuilders are the predefined reports jobs can run
+var reportBuilders = map[string]reportBuilder{
+	ReportHealthSummary: buildHealthSummary,
+	ReportCertExpiry:    buildCertExpiry,
+	ReportCapacity:      buildCapacity,
+	ReportSLA:           buildSLA,
+	ReportChangeDigest:  buildChangeDigest,
+}
+
+// buildHealthSummary counts virtual services by state and lists those needing attention
+func buildHealthSummary(ctx context.Context, exec avi.GenericExecutor, job config.ReportJob, loc locale.Locale, now time.Time) (*Report, error) {
+	overview, err := avi.GetHealthOverview(ctx, exec)
+	if err != nil {
+		return nil, err
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "%s virtual services: %s up, %s down, %s degraded.\n",
+		loc.Int(overview.VirtualServices), loc.Int(overview.Up), loc.Int(overview.Down), loc.Int(overview.Degraded))
+	if len(overview.Worst) > 0 {
+		text.WriteString("\nNeeds attention:\n")
+		for _, vs := range overview.Worst {
+			fmt.Fprintf(&text, "- %s: %s, health %s", vs.Name, vs.OperState, loc.Number(vs.HealthScore, 0))
+			if len(vs.Reasons) > 0 {
+				fmt.Fprintf(&text, " (%s)", vs.Reasons[0])
+			}
+			text.WriteString("\n")
+		}
+	}
+	return &Report{Title: "Virtual service health summary", Text: text.String(), Data: overview}, nil
+}
+
+// buildCertExpiry lists certificates that have expired or expire within the job's window
+func buildCertExpiry(ctx context.Context, exec avi.GenericExecutor, job config.ReportJob, loc locale.Locale, now time.Time) (*Report, error) {
+	within := job.WithinDays
+	if within <= 0 {
+		within = defaultExpiryWindow
+	}
+	expiry, err := avi.GetCertificateExpiry(ctx, exec, within, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var text strings.Builder
+	if len(expiry.Expiring) == 0 {
+		fmt.Fprintf(&text, "None of the %s certificates expire within %s days.\n", loc.Int(expiry.Checked), loc.Int(within))
+	} else {
+		fmt.Fprintf(&text, "%s of %s certificates have expired or expire within %s days:\n",
+			loc.Int(len(expiry.Expiring)), loc.Int(expiry.Checked), loc.Int(within))
+		for _, cert := range expiry.Expiring {
+			// Without a locale the controller's timestamp is shown as is
+			expires := cert.NotAfter
+			if loc.Tag() != "" {
+				expires = loc.Date(cert.Expires.In(now.Location()))
+			}
+			if cert.DaysLeft < 0 {
+				fmt.Fprintf(&text, "- %s: expired %s (%s days ago)\n", cert.Name, expires, loc.Int(-cert.DaysLeft))
+			} else {
+				fmt.Fprintf(&text, "- %s: expires %s (in %s days)\n", cert.Name, expires, loc.Int(cert.DaysLeft))
+			}
+		}
+	}
+	return &Report{Title: "Certificate expiry report", Text: text.String(), Data: expiry}, nil
+}
+
+// buildCapacity reports license usage and service engines per SE group
+func buildCapacity(ctx context.Context, exec avi.GenericExecutor, job config.ReportJob, loc locale.Locale, now time.Time) (*Report, error) {
+	capacity, err := avi.GetCapacity(ctx, exec)
+	if err != nil {
+		return nil, err
+	}
+
+	var text strings.Builder
+	if license := capacity.License; license != nil {
+		fmt.Fprintf(&text, "License: %s of %s cores used (%s).\n",
+			loc.Number(license.UsedCores, 0), loc.Number(license.TotalCores, 0), loc.Percent(license.UsagePercent, 0))
+	}
+	fmt.Fprintf(&text, "%s virtual services on %s service engines.\n", loc.Int(capacity.VirtualServices), loc.Int(capacity.ServiceEngines))
+	if len(capacity.SEGroups) > 0 {
+		text.WriteString("\nService engine groups:\n")
+		for _, group := range capacity.SEGroups {
+			fmt.Fprintf(&text, "- %s: %s of %s service engines (%s)\n", group.Name, loc.Int(group.ServiceEngines), loc.Int(group.MaxSE), loc.Percent(group.UsagePercent, 0))
+		}
+	}
+	for _, warning := range capacity.Warnings {
+		fmt.Fprintf(&text, "\nWarning: %s\n", warning)
+	}
+	return &Report{Title: "Capacity report", Text: text.String(), Data: capacity}, nil
+}
+
+// buildSLA reports the availability of the virtual services over the job's period against its
+// target, listing those that missed it
+func buildSLA(ctx context.Context, exec avi.GenericExecutor, job config.ReportJob, loc locale.Locale, now time.Time) (*Report, error) {
+	report, err := avi.GetAvailability(ctx, exec, job.Period, job.Target, nil, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Availability over %s: %s (target %s). %s virtual services missed the target.\n",
+		report.Period, loc.Percent(report.Availability, 3), loc.Percent(report.Target, -1), loc.Int(report.Breaches))
+	for _, vs := range report.VirtualServices {
+		if !vs.Breached {
+			continue
+		}
+		fmt.Fprintf(&text, "- %s: %s, down %s in %s outages (longest %s)\n", vs.Name, loc.Percent(vs.Availability, 3),
+			avi.FormatDuration(vs.DowntimeSeconds), loc.Int(vs.Outages), avi.FormatDuration(vs.LongestOutageSeconds))
+	}
+	for _, warning := range report.Warnings {
+		fmt.Fprintf(&text, "\nWarning: %s\n", warning)
+	}
+	return &Report{Title: "Virtual service SLA report", Text: text.String(), Data: report}, nil
+}
+
+// changeVerbs describe the actions of the configuration audit trail
+var changeVerbs = map[string]string{"create": "created", "update": "updated", "delete": "deleted"}
+
+// buildChangeDigest summarizes the controller's configuration audit trail over the job's period,
+// the last 24 hours by default: how many changes each user made and what each one changed
+func buildChangeDigest(ctx context.Context, exec avi.GenericExecutor, job config.ReportJob, loc locale.Locale, now time.Time) (*Report, error) {
+	period := job.Period
+	if period == "" {
+		period = "24h"
+	}
+	digest, err := avi.GetConfigChanges(ctx, exec, period, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var text strings.Builder
+	if len(digest.Changes) == 0 {
+		fmt.Fprintf(&text, "No configuration changes in the last %s.\n", digest.Period)
+		return &Report{Title: "Configuration change digest", Text: text.String(), Data: digest}, nil
+	}
+	users := make([]string, 0, len(digest.ByUser))
+	for user := range digest.ByUser {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if digest.ByUser[users[i]] != digest.ByUser[users[j]] {
+			return digest.ByUser[users[i]] > digest.ByUser[users[j]]
+		}
+		return users[i] < users[j]
+	})
+	byUser := make([]string, len(users))
+	for i, user := range users {
+		byUser[i] = fmt.Sprintf("%s (%s)", user, loc.Int(digest.ByUser[user]))
+	}
+	fmt.Fprintf(&text, "%s configuration changes to %s objects in the last %s, by %s.\n\n",
+		loc.Int(len(digest.Changes)), loc.Int(digest.Objects), digest.Period, strings.Join(byUser, ", "))
+
+	for _, change := range digest.Changes {
+		at := change.Time
+		if t, err := time.Parse(time.RFC3339, change.Time); err == nil {
+			at = t.In(now.Location()).Format("15:04")
+			if now.Sub(t) >= 24*time.Hour {
+				at = loc.Date(t.In(now.Location())) + " " + at
+			}
+		}
+		user := change.User
+		if user == "" {
+			user = "unknown user"
+		}
+		fmt.Fprintf(&text, "- %s %s %s %s %s", at, user, changeVerbs[change.Action], change.ObjectType, change.ObjectName)
+		if len(change.Fields) > 0 {
+			fmt.Fprintf(&text, " (%s)", strings.Join(change.Fields, ", "))
+		}
+		text.WriteString("\n")
+	}
+	return &Report{Title: "Configuration change digest", Text: text.String(), Data: digest}, nil
+}
@@ -0,0 +1,201 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aviagent/internal/notify"
+	"aviagent/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSchedule_Next(t *testing.T) {
+	// Thursday
+	from := time.Date(2026, 10, 15, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2026, 10, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 8 * * *", time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)},
+		{"0 8 * * 1-5", time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)},
+		{"30 6 1 * *", time.Date(2026, 11, 1, 6, 30, 0, 0, time.UTC)},
+		{"0 0 1 * 1", time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)}, // day of month or day of week
+		{"@weekly", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"@every 6h", time.Date(2026, 10, 15, 16, 30, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		schedule, err := ParseSchedule(tt.expr)
+		require.NoError(t, err, tt.expr)
+		assert.Equal(t, tt.want, schedule.Next(from), tt.expr)
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "*/0 * * * *", "a * * * *", "@every 10s"} {
+		_, err := ParseSchedule(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+// fakeExecutor serves canned collections by endpoint
+type fakeExecutor map[string]map[string]interface{}
+
+func (f fakeExecutor) ExecuteGenericOperation(ctx context.Context, method, endpoint string, body interface{}, params map[string]string) (interface{}, error) {
+	obj, ok := f[endpoint]
+	if !ok {
+		return nil, fmt.Errorf("not found: %s", endpoint)
+	}
+	return obj, nil
+}
+
+func TestScheduler_Run(t *testing.T) {
+	var webhook Report
+	var slack map[string]string
+	var delivered, failed notify.Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/webhook":
+			assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&webhook))
+		case "/slack":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&slack))
+		case "/notify":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&delivered))
+		case "/failures":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&failed))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	exec := fakeExecutor{
+		"/sslkeyandcertificate": {"results": []interface{}{
+			map[string]interface{}{"name": "shop-cert", "uuid": "c-1", "certificate": map[string]interface{}{"not_after": "2026-11-01 00:00:00"}},
+			map[string]interface{}{"name": "old-cert", "uuid": "c-2", "certificate": map[string]interface{}{"not_after": "2026-10-01 00:00:00"}},
+			map[string]interface{}{"name": "new-cert", "uuid": "c-3", "certificate": map[string]interface{}{"not_after": "2028-01-01 00:00:00"}},
+		}},
+	}
+	notifier, err := notify.New(config.NotificationsConfig{Channels: map[string]config.NotificationChannel{
+		"pager":    {URL: server.URL + "/notify", Events: []string{"incident.*"}},
+		"failures": {URL: server.URL + "/failures", Events: []string{notify.EventReportFailed}},
+	}}, zap.NewNop())
+	require.NoError(t, err)
+	cfg := config.SchedulerConfig{
+		Destinations: map[string]config.ReportDestination{
+			"pager":    {Type: DestinationNotification, Channel: "pager"},
+			"ops-hook": {Type: DestinationWebhook, URL: server.URL + "/webhook", Headers: map[string]string{"Authorization": "Bearer token"}},
+			"ops-chat": {Type: DestinationSlack, URL: server.URL + "/slack"},
+			"broken":   {Type: DestinationWebhook, URL: server.URL + "/fail"},
+		},
+		Jobs: []config.ReportJob{
+			{Name: "certs", Schedule: "0 8 * * 1", Report: ReportCertExpiry, Destinations: []string{"OPS-HOOK", "ops-chat", "pager"}},
+			{Name: "certs-broken", Schedule: "@daily", Report: ReportCertExpiry, Destinations: []string{"broken", "ops-chat"}},
+			{Name: "certs-de", Schedule: "@daily", Report: ReportCertExpiry, Destinations: []string{"ops-chat"}, Locale: "de-DE"},
+		},
+	}
+	s, err := New(cfg, exec, notifier, zap.NewNop())
+	require.NoError(t, err)
+	s.now = func() time.Time { return time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC) }
+
+	report, err := s.Run(context.Background(), "certs")
+	require.NoError(t, err)
+	assert.Equal(t, "certs", webhook.Job)
+	assert.Equal(t, ReportCertExpiry, webhook.Kind)
+	assert.Contains(t, report.Text, "2 of 3 certificates")
+	assert.Contains(t, report.Text, "old-cert: expired 2026-10-01 00:00:00 (15 days ago)")
+	assert.Contains(t, slack["text"], "*Certificate expiry report*")
+	assert.Contains(t, slack["text"], "shop-cert: expires 2026-11-01 00:00:00 (in 15 days)")
+	assert.Equal(t, notify.EventReportDelivered, delivered.Type)
+	assert.Equal(t, report.Text, delivered.Summary)
+	assert.Empty(t, failed.Type)
+
+	// A failing destination is reported without stopping the others
+	slack = nil
+	_, err = s.Run(context.Background(), "certs-broken")
+	assert.ErrorContains(t, err, "destination broken")
+	assert.NotNil(t, slack)
+	assert.Equal(t, err.Error(), s.Jobs()[1].LastError)
+	assert.Equal(t, notify.EventReportFailed, failed.Type)
+	assert.Equal(t, "certs-broken", failed.Source)
+
+	// Dates follow the job's locale
+	report, err = s.Run(context.Background(), "certs-de")
+	require.NoError(t, err)
+	assert.Contains(t, report.Text, "old-cert: expired 01.10.2026 (15 days ago)")
+
+	_, err = s.Run(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
+
+func TestNew_Validation(t *testing.T) {
+	destinations := map[string]config.ReportDestination{
+		"hook":  {Type: DestinationWebhook, URL: "http://example.com"},
+		"pager": {Type: DestinationNotification, Channel: "pagerduty"},
+	}
+	tests := map[string]config.ReportJob{
+		"schedule":    {Name: "a", Schedule: "every day", Report: ReportCapacity, Destinations: []string{"hook"}},
+		"report":      {Name: "a", Schedule: "@daily", Report: "uptime", Destinations: []string{"hook"}},
+		"destination": {Name: "a", Schedule: "@daily", Report: ReportCapacity, Destinations: []string{"mail"}},
+		"name":        {Schedule: "@daily", Report: ReportCapacity, Destinations: []string{"hook"}},
+		"channel":     {Name: "a", Schedule: "@daily", Report: ReportCapacity, Destinations: []string{"pager"}},
+		"locale":      {Name: "a", Schedule: "@daily", Report: ReportCapacity, Destinations: []string{"hook"}, Locale: "xx-YY"},
+	}
+	for name, job := range tests {
+		_, err := New(config.SchedulerConfig{Destinations: destinations, Jobs: []config.ReportJob{job}}, fakeExecutor{}, &notify.Notifier{}, zap.NewNop())
+		assert.Error(t, err, name)
+	}
+	_, err := New(config.SchedulerConfig{Timezone: "Mars/Olympus"}, fakeExecutor{}, &notify.Notifier{}, zap.NewNop())
+	assert.Error(t, err)
+}
+
+func TestChangeDigest(t *testing.T) {
+	exec := fakeExecutor{
+		"/analytics/logs": {"results": []interface{}{
+			map[string]interface{}{
+				"event_id": "CONFIG_UPDATE", "obj_type": "VIRTUALSERVICE", "obj_name": "shop", "report_timestamp": "2026-10-16T07:15:00",
+				"event_details": map[string]interface{}{"config_update_details": map[string]interface{}{
+					"user":              "alice",
+					"old_resource_data": `{"enabled": true}`,
+					"new_resource_data": `{"enabled": false}`,
+				}},
+			},
+			map[string]interface{}{"event_id": "CONFIG_DELETE", "obj_type": "POOL", "obj_name": "old-pool", "report_timestamp": "2026-10-15T10:00:00"},
+		}},
+	}
+	var delivered notify.Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&delivered))
+	}))
+	defer server.Close()
+	notifier, err := notify.New(config.NotificationsConfig{Channels: map[string]config.NotificationChannel{
+		"ops": {URL: server.URL},
+	}}, zap.NewNop())
+	require.NoError(t, err)
+
+	s, err := New(config.SchedulerConfig{
+		Destinations: map[string]config.ReportDestination{"ops": {Type: DestinationNotification, Channel: "ops"}},
+		Jobs:         []config.ReportJob{{Name: "daily-changes", Schedule: "0 8 * * *", Report: ReportChangeDigest, Destinations: []string{"ops"}}},
+	}, exec, notifier, zap.NewNop())
+	require.NoError(t, err)
+	s.now = func() time.Time { return time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC) }
+	assert.Nil(t, s.Latest(ReportChangeDigest), "no digest before the first run")
+
+	report, err := s.Run(context.Background(), "daily-changes")
+	require.NoError(t, err)
+	assert.Equal(t, "Configuration change digest", report.Title)
+	assert.Contains(t, report.Text, "2 configuration changes to 2 objects in the last 24h, by alice (1), unknown (1).")
+	assert.Contains(t, report.Text, "- 10:00 unknown user deleted pool old-pool\n- 07:15 alice updated virtualservice shop (enabled)\n")
+	assert.Equal(t, report.Text, delivered.Summary, "the digest is delivered as a notification")
+	assert.Same(t, report, s.Latest(ReportChangeDigest), "and kept for the UI")
+	assert.Nil(t, s.Latest(ReportSLA))
+}
@@ -0,0 +1,270 @@
+// Package style enforces the deployment's answer style rules on chat answers after they are
+// written, rather than relying on the model to follow them from the prompt.
+package style
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"aviagent/pkg/config"
+)
+
+// Style rules, named in corrections
+const (
+	RuleNoEmojis      = "no_emojis"
+	RuleIncludeUUIDs  = "include_uuids"
+	RuleIncludeTenant = "include_tenant"
+)
+
+// Object is a controller object named in the tool results of a turn
+type Object struct {
+	Name string
+	UUID string
+}
+
+// Answer is what the answer template is rendered with
+type Answer struct {
+	Answer  string   // the answer, with the rules applied
+	Tenant  string   // the tenant the agent works in
+	Objects []Object // the objects the tool results named
+}
+
+// Correction is a rule an answer broke and how many times it was corrected
+type Correction struct {
+	Rule  string `json:"rule"`
+	Count int    `json:"count"`
+}
+
+// Guide applies the style rules of the deployment to answers. A nil Guide leaves answers as
+// they are.
+type Guide struct {
+	cfg      config.StyleConfig
+	tenant   string
+	template *template.Template
+}
+
+// NewGuide compiles the style rules; tenant is the tenant answers are about
+func NewGuide(cfg config.StyleConfig, tenant string) (*Guide, error) {
+	g := &Guide{cfg: cfg, tenant: tenant}
+	if cfg.Template != "" {
+		tmpl, err := template.New("answer").Option("missingkey=error").Parse(cfg.Template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid style.template: %w", err)
+		}
+		g.template = tmpl
+	}
+	return g, nil
+}
+
+// Apply corrects an answer that breaks the style rules and renders it into the answer template.
+// Code blocks, such as the tool results added to answers, are left as they are.
+func (g *Guide) Apply(answer string, objects []Object) (string, []Correction) {
+	if g == nil {
+		return answer, nil
+	}
+	var corrections []Correction
+	correct := func(rule string, count int) {
+		if count > 0 {
+			corrections = append(corrections, Correction{Rule: rule, Count: count})
+		}
+	}
+
+	if g.cfg.NoEmojis {
+		var removed int
+		answer = mapProse(answer, func(part string) string {
+			part, n := removeEmojis(part)
+			removed += n
+			return part
+		})
+		correct(RuleNoEmojis, removed)
+	}
+
+	if g.cfg.IncludeUUIDs {
+		added := 0
+		// Longer names first, so a name that is part of another isn't annotated in its place
+		sorted := append([]Object(nil), objects...)
+		sort.SliceStable(sorted, func(i, j int) bool { return len(sorted[i].Name) > len(sorted[j].Name) })
+		for _, object := range sorted {
+			if object.UUID == "" || len(object.Name) < 3 || strings.Contains(prose(answer), object.UUID) {
+				continue
+			}
+			annotated := false
+			answer = mapProse(answer, func(part string) string {
+				if annotated {
+					return part
+				}
+				part, annotated = annotateName(part, object)
+				return part
+			})
+			if annotated {
+				added++
+			}
+		}
+		correct(RuleIncludeUUIDs, added)
+	}
+
+	if g.template != nil {
+		var rendered strings.Builder
+		if err := g.template.Execute(&rendered, Answer{Answer: answer, Tenant: g.tenant, Objects: objects}); err == nil {
+			answer = rendered.String()
+		}
+	}
+
+	if g.cfg.IncludeTenant && g.tenant != "" && !mentionsTenant(answer, g.tenant) {
+		answer = strings.TrimRight(answer, "\n") + "\n\nTenant: " + g.tenant
+		correct(RuleIncludeTenant, 1)
+	}
+	return answer, corrections
+}
+
+// mapProse applies f to the parts of a Markdown text outside fenced code blocks
+func mapProse(text string, f func(string) string) string {
+	parts := strings.Split(text, "```")
+	for i := 0; i < len(parts); i += 2 {
+		parts[i] = f(parts[i])
+	}
+	return strings.Join(parts, "```")
+}
+
+// prose returns the parts of a Markdown text outside fenced code blocks
+func prose(text string) string {
+	var parts []string
+	mapProse(text, func(part string) string {
+		parts = append(parts, part)
+		return part
+	})
+	return strings.Join(parts, "\n")
+}
+
+// emojiRanges are the code points removed as emojis
+var emojiRanges = []struct{ first, last rune }{
+	{0x1F000, 0x1FAFF}, // emoticons, pictographs, flags and symbols
+	{0x2600, 0x27BF},   // miscellaneous symbols and dingbats
+	{0x2B05, 0x2B55},   // arrows, stars and circles of the emoji set
+	{0x231A, 0x23FA},   // watches, hourglasses and media controls
+}
+
+// isEmojiJoiner reports whether a rune combines emojis: the zero width joiner, the emoji
+// presentation selector and the keycap
+func isEmojiJoiner(r rune) bool {
+	return r == 0x200D || r == 0xFE0F || r == 0x20E3
+}
+
+// isEmoji reports whether a rune is an emoji
+func isEmoji(r rune) bool {
+	for _, emojis := range emojiRanges {
+		if r >= emojis.first && r <= emojis.last {
+			return true
+		}
+	}
+	return false
+}
+
+// removeEmojis removes emojis, with the space that followed them, and returns how many there were
+func removeEmojis(text string) (string, int) {
+	var out strings.Builder
+	count := 0
+	skipSpace := false
+	for len(text) > 0 {
+		r, size := utf8.DecodeRuneInString(text)
+		text = text[size:]
+		switch {
+		case isEmoji(r):
+			count++
+			skipSpace = true
+			continue
+		case isEmojiJoiner(r):
+			continue
+		case r == ' ' && skipSpace:
+			skipSpace = false
+			continue
+		}
+		skipSpace = false
+		out.WriteRune(r)
+	}
+	if count == 0 {
+		return out.String(), 0
+	}
+	return trailingSpace.ReplaceAllString(out.String(), ""), count
+}
+
+// trailingSpace is the space left at the end of a line by a removed emoji
+var trailingSpace = regexp.MustCompile(`(?m)[ \t]+$`)
+
+// nameChar reports whether a byte can be part of an object name
+func nameChar(b byte) bool {
+	return b == '-' || b == '_' || b == '.' || (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// annotateName adds the UUID of an object after the first mention of its name, past closing
+// Markdown emphasis or code marks
+func annotateName(text string, object Object) (string, bool) {
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], object.Name)
+		if i < 0 {
+			return text, false
+		}
+		start, end := offset+i, offset+i+len(object.Name)
+		// A trailing dot ends the sentence rather than the name
+		if (start > 0 && nameChar(text[start-1])) || (end < len(text) && nameChar(text[end]) && !sentenceEnd(text, end)) {
+			offset = end
+			continue
+		}
+		for end < len(text) && strings.ContainsRune("`*_", rune(text[end])) {
+			end++
+		}
+		return text[:end] + " (" + object.UUID + ")" + text[end:], true
+	}
+}
+
+// sentenceEnd reports whether the dot at i ends a sentence
+func sentenceEnd(text string, i int) bool {
+	return text[i] == '.' && (i+1 == len(text) || text[i+1] == ' ' || text[i+1] == '\n')
+}
+
+// mentionsTenant reports whether an answer names the tenant
+func mentionsTenant(answer, tenant string) bool {
+	pattern := regexp.MustCompile(`(?i)(^|[^\w-])` + regexp.QuoteMeta(tenant) + `($|[^\w-])`)
+	return pattern.MatchString(answer)
+}
+
+// Objects returns the named objects of a tool result, sorted by name: objects with a name and a
+// uuid, at any depth
+func Objects(result interface{}) []Object {
+	var value interface{}
+	if data, err := json.Marshal(result); err != nil || json.Unmarshal(data, &value) != nil {
+		return nil
+	}
+	var objects []Object
+	seen := map[string]bool{}
+	var walk func(value interface{}, depth int)
+	walk = func(value interface{}, depth int) {
+		if depth > 6 {
+			return
+		}
+		switch value := value.(type) {
+		case map[string]interface{}:
+			name, _ := value["name"].(string)
+			uuid, _ := value["uuid"].(string)
+			if name != "" && uuid != "" && !seen[uuid] {
+				seen[uuid] = true
+				objects = append(objects, Object{Name: name, UUID: uuid})
+			}
+			for _, field := range value {
+				walk(field, depth+1)
+			}
+		case []interface{}:
+			for _, item := range value {
+				walk(item, depth+1)
+			}
+		}
+	}
+	walk(value, 0)
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Name < objects[j].Name })
+	return objects
+}
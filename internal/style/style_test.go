@@ -0,0 +1,58 @@
+package style
+
+import (
+	"testing"
+
+	"aviagent/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuideApply(t *testing.T) {
+	guide, err := NewGuide(config.StyleConfig{NoEmojis: true, IncludeUUIDs: true, IncludeTenant: true}, "prod")
+	require.NoError(t, err)
+	objects := []Object{
+		{Name: "web-pool", UUID: "pool-1"},
+		{Name: "web", UUID: "virtualservice-2"},
+		{Name: "db-pool", UUID: "pool-3"},
+	}
+
+	answer := "✅ Pool **web-pool** is up, and web serves it 🚀\n- ⚠️ Check web-pool again.\n\nAPI Result:\n```json\n{\"name\": \"web\", \"note\": \"🚀\"}\n```"
+	styled, corrections := guide.Apply(answer, objects)
+	assert.Equal(t, "Pool **web-pool** (pool-1) is up, and web (virtualservice-2) serves it\n- Check web-pool again.\n\nAPI Result:\n```json\n{\"name\": \"web\", \"note\": \"🚀\"}\n```\n\nTenant: prod", styled)
+	assert.Equal(t, []Correction{{Rule: RuleNoEmojis, Count: 3}, {Rule: RuleIncludeUUIDs, Count: 2}, {Rule: RuleIncludeTenant, Count: 1}}, corrections)
+
+	// An answer following the rules is left as it is
+	styled, corrections = guide.Apply("Pool web-pool (pool-1) in tenant prod is up.", objects)
+	assert.Equal(t, "Pool web-pool (pool-1) in tenant prod is up.", styled)
+	assert.Empty(t, corrections)
+
+	// The template scaffolds every answer, and can state the tenant itself
+	guide, err = NewGuide(config.StyleConfig{IncludeTenant: true, Template: "{{.Answer}}\n\n_Tenant {{.Tenant}}, {{len .Objects}} objects_"}, "prod")
+	require.NoError(t, err)
+	styled, corrections = guide.Apply("All pools are up.", objects)
+	assert.Equal(t, "All pools are up.\n\n_Tenant prod, 3 objects_", styled)
+	assert.Empty(t, corrections)
+
+	_, err = NewGuide(config.StyleConfig{Template: "{{.Answer"}, "admin")
+	assert.Error(t, err)
+
+	var none *Guide
+	styled, corrections = none.Apply("🚀", nil)
+	assert.Equal(t, "🚀", styled)
+	assert.Empty(t, corrections)
+}
+
+func TestObjects(t *testing.T) {
+	result := map[string]interface{}{
+		"count": 2,
+		"results": []interface{}{
+			map[string]interface{}{"name": "web-pool", "uuid": "pool-1", "servers": []interface{}{map[string]interface{}{"ip": "10.0.0.1"}}},
+			map[string]interface{}{"name": "db-pool", "uuid": "pool-3"},
+			map[string]interface{}{"name": "web-pool", "uuid": "pool-1"},
+		},
+	}
+	assert.Equal(t, []Object{{Name: "db-pool", UUID: "pool-3"}, {Name: "web-pool", UUID: "pool-1"}}, Objects(result))
+	assert.Empty(t, Objects("no objects"))
+}
@@ -0,0 +1,212 @@
+// Package terraform writes Avi objects as configuration of the vmware/avi Terraform provider, so
+// teams that manage the controller as code can author changes with the agent and apply them
+// through their pipeline instead of writing to the controller directly.
+package terraform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"aviagent/pkg/avi"
+)
+
+// ProviderSource is the registry address of the Avi provider
+const ProviderSource = "vmware/avi"
+
+// omittedFields are assigned by the controller, or set by the provider configuration: the tenant
+var omittedFields = map[string]bool{"uuid": true, "url": true, "_last_modified": true, "tenant_ref": true}
+
+// labelInvalid matches what a resource label can't hold
+var labelInvalid = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// Configuration is Avi objects as Terraform configuration
+type Configuration struct {
+	HCL         string   `json:"hcl"`
+	Resources   []string `json:"resources"`              // addresses of the objects, e.g. avi_pool.web_pool
+	DataSources []string `json:"data_sources,omitempty"` // objects referred to that are looked up by name
+}
+
+// generator holds the addresses given to the objects of a configuration
+type generator struct {
+	resources map[avi.ObjectRef]string
+	data      map[avi.ObjectRef]string
+	dataRefs  []avi.ObjectRef // in the order they are first referred to
+	labels    map[string]bool // by resource or data source type
+}
+
+// Generate writes objects as resources of the Avi provider, one per object in the order given,
+// dependency order for the objects of avi.ParseConfiguration and avi.CollectObjects. A reference
+// to another of the objects is its resource ID; a reference to an object by name that isn't one
+// of them is looked up with a data source, and one by UUID alone is kept as it is. Fields the
+// controller assigns and the tenant, which the provider configuration sets, are left out.
+func Generate(objects []avi.ConfigObject) (*Configuration, error) {
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("no objects to generate")
+	}
+	g := &generator{resources: make(map[avi.ObjectRef]string), data: make(map[avi.ObjectRef]string), labels: make(map[string]bool)}
+	config := &Configuration{}
+	for _, obj := range objects {
+		if obj.Type == "" || obj.Name == "" || obj.Data == nil {
+			return nil, fmt.Errorf("every object needs a type, a name and its fields")
+		}
+		ref := avi.ObjectRef{Type: strings.ToLower(obj.Type), Name: obj.Name}
+		if _, ok := g.resources[ref]; ok {
+			return nil, fmt.Errorf("%s %s is given twice", ref.Type, ref.Name)
+		}
+		g.resources[ref] = g.address("avi_"+ref.Type, ref.Name)
+		config.Resources = append(config.Resources, g.resources[ref])
+	}
+
+	var resources bytes.Buffer
+	for i, obj := range objects {
+		address := config.Resources[i]
+		resourceType, label, _ := strings.Cut(address, ".")
+		fmt.Fprintf(&resources, "\nresource %q %q {\n", resourceType, label)
+		g.writeBody(&resources, "  ", obj.Data)
+		resources.WriteString("}\n")
+	}
+
+	var hcl bytes.Buffer
+	fmt.Fprintf(&hcl, "terraform {\n  required_providers {\n    avi = {\n      source = %q\n    }\n  }\n}\n", ProviderSource)
+	for _, ref := range g.dataRefs {
+		address := g.data[ref]
+		dataType, label, _ := strings.Cut(address, ".")
+		fmt.Fprintf(&hcl, "\ndata %q %q {\n  name = %s\n}\n", dataType, label, hclString(ref.Name))
+		config.DataSources = append(config.DataSources, "data."+address)
+	}
+	hcl.Write(resources.Bytes())
+	config.HCL = hcl.String()
+	return config, nil
+}
+
+// address returns a new address of the type for an object name: the name as a label, made
+// unique with a number when another object of the type has it
+func (g *generator) address(blockType, name string) string {
+	label := strings.Trim(labelInvalid.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if label == "" || (label[0] >= '0' && label[0] <= '9') {
+		label = "_" + label
+	}
+	unique := label
+	for n := 2; g.labels[blockType+"."+unique]; n++ {
+		unique = fmt.Sprintf("%s_%d", label, n)
+	}
+	g.labels[blockType+"."+unique] = true
+	return blockType + "." + unique
+}
+
+// writeBody writes the fields of an object: the attributes first, aligned as terraform fmt
+// aligns them, then the nested objects as blocks
+func (g *generator) writeBody(w *bytes.Buffer, indent string, object map[string]interface{}) {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		if !omittedFields[key] && !strings.HasPrefix(key, "_") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	type attribute struct{ key, value string }
+	var attributes []attribute
+	var blocks []string
+	width := 0
+	for _, key := range keys {
+		value, isBlock := g.expression(key, object[key])
+		if isBlock {
+			blocks = append(blocks, key)
+			continue
+		}
+		if value == "" {
+			continue
+		}
+		attributes = append(attributes, attribute{key, value})
+		width = max(width, len(key))
+	}
+	for _, attr := range attributes {
+		fmt.Fprintf(w, "%s%-*s = %s\n", indent, width, attr.key, attr.value)
+	}
+	for _, key := range blocks {
+		items, ok := object[key].([]interface{})
+		if !ok {
+			items = []interface{}{object[key]}
+		}
+		for _, item := range items {
+			nested, _ := item.(map[string]interface{})
+			if len(nested) == 0 {
+				fmt.Fprintf(w, "%s%s {}\n", indent, key)
+				continue
+			}
+			fmt.Fprintf(w, "%s%s {\n", indent, key)
+			g.writeBody(w, indent+"  ", nested)
+			fmt.Fprintf(w, "%s}\n", indent)
+		}
+	}
+}
+
+// expression returns the HCL of an attribute value, or reports a nested object or a list of
+// them, written as blocks. Empty values return no expression and are left out.
+func (g *generator) expression(key string, value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return "", true
+	case []interface{}:
+		if len(v) == 0 {
+			return "", false
+		}
+		if _, ok := v[0].(map[string]interface{}); ok {
+			return "", true
+		}
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			if expr, _ := g.expression(key, item); expr != "" {
+				items = append(items, expr)
+			}
+		}
+		return "[" + strings.Join(items, ", ") + "]", false
+	case string:
+		if avi.IsRefField(key) {
+			return g.reference(v), false
+		}
+		return hclString(v), false
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), false
+	case int:
+		return strconv.Itoa(v), false
+	case bool:
+		return strconv.FormatBool(v), false
+	}
+	return "", false
+}
+
+// reference returns the ID of the object a reference names: the resource generated for it, or a
+// data source looking it up by name
+func (g *generator) reference(value string) string {
+	ref, ok := avi.ParseRef(value)
+	if !ok {
+		return hclString(value)
+	}
+	if address, ok := g.resources[ref]; ok {
+		return address + ".id"
+	}
+	address, ok := g.data[ref]
+	if !ok {
+		address = g.address("avi_"+ref.Type, ref.Name)
+		g.data[ref] = address
+		g.dataRefs = append(g.dataRefs, ref)
+	}
+	return "data." + address + ".id"
+}
+
+// hclString quotes a string for HCL, escaping the sequences that would start a template
+func hclString(s string) string {
+	var quoted bytes.Buffer
+	encoder := json.NewEncoder(&quoted)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(s)
+	text := strings.TrimSuffix(quoted.String(), "\n")
+	return strings.NewReplacer("${", "$${", "%{", "%%{").Replace(text)
+}
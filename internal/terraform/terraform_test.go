@@ -0,0 +1,94 @@
+package terraform
+
+import (
+	"testing"
+
+	"aviagent/pkg/avi"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	objects, err := avi.ParseConfiguration(map[string]interface{}{
+		"virtualservice": []interface{}{map[string]interface{}{
+			"name":                    "web",
+			"uuid":                    "vs-1",
+			"tenant_ref":              "https://controller/api/tenant/admin#admin",
+			"pool_ref":                "https://controller/api/pool/pool-1#web-pool",
+			"application_profile_ref": "https://controller/api/applicationprofile/ap-1#System-HTTP",
+			"vsvip_ref":               "https://controller/api/vsvip/vsvip-1",
+			"enabled":                 true,
+			"services":                []interface{}{map[string]interface{}{"port": 443.0, "enable_ssl": true}, map[string]interface{}{"port": 80.0}},
+			"description":             "Shop ${env}",
+		}},
+		"pool": []interface{}{map[string]interface{}{
+			"name":                "web-pool",
+			"lb_algorithm":        "LB_ALGORITHM_ROUND_ROBIN",
+			"health_monitor_refs": []interface{}{"/api/healthmonitor?name=System-HTTP"},
+			"servers":             []interface{}{map[string]interface{}{"ip": map[string]interface{}{"addr": "10.0.0.1", "type": "V4"}}},
+			"placement_networks":  []interface{}{},
+		}},
+	})
+	require.NoError(t, err)
+
+	config, err := Generate(objects)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"avi_pool.web_pool", "avi_virtualservice.web"}, config.Resources)
+	assert.Equal(t, []string{"data.avi_healthmonitor.system_http", "data.avi_applicationprofile.system_http"}, config.DataSources)
+	assert.Equal(t, `terraform {
+  required_providers {
+    avi = {
+      source = "vmware/avi"
+    }
+  }
+}
+
+data "avi_healthmonitor" "system_http" {
+  name = "System-HTTP"
+}
+
+data "avi_applicationprofile" "system_http" {
+  name = "System-HTTP"
+}
+
+resource "avi_pool" "web_pool" {
+  health_monitor_refs = [data.avi_healthmonitor.system_http.id]
+  lb_algorithm        = "LB_ALGORITHM_ROUND_ROBIN"
+  name                = "web-pool"
+  servers {
+    ip {
+      addr = "10.0.0.1"
+      type = "V4"
+    }
+  }
+}
+
+resource "avi_virtualservice" "web" {
+  application_profile_ref = data.avi_applicationprofile.system_http.id
+  description             = "Shop $${env}"
+  enabled                 = true
+  name                    = "web"
+  pool_ref                = avi_pool.web_pool.id
+  vsvip_ref               = "https://controller/api/vsvip/vsvip-1"
+  services {
+    enable_ssl = true
+    port       = 443
+  }
+  services {
+    port = 80
+  }
+}
+`, config.HCL)
+
+	_, err = Generate([]avi.ConfigObject{{Type: "pool", Name: "a", Data: map[string]interface{}{}}, {Type: "Pool", Name: "a", Data: map[string]interface{}{}}})
+	assert.EqualError(t, err, "pool a is given twice")
+}
+
+func TestAddress(t *testing.T) {
+	g := &generator{labels: make(map[string]bool)}
+	assert.Equal(t, "avi_pool.web_pool", g.address("avi_pool", "Web-Pool"))
+	assert.Equal(t, "avi_pool.web_pool_2", g.address("avi_pool", "web.pool"))
+	assert.Equal(t, "avi_pool._10_0_0_1", g.address("avi_pool", "10.0.0.1"))
+	assert.Equal(t, "avi_vsvip.web_pool", g.address("avi_vsvip", "web pool"))
+}
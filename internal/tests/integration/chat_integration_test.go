@@ -0,0 +1,254 @@
+//go:build integration
+
+// Package integration exercises a running agent stack: the agent in training mode (the bundled
+// mock controller) with the mock LLM or a small Ollama model. Start it with
+// docker-compose.integration.yml, or run `make test-integration` which starts and stops it.
+package integration
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// intranetVS and maintenanceSE are a virtual service and a service engine of the sandbox sample data
+const (
+	intranetVS    = "virtualservice-c93b0e54-intranet"
+	maintenanceSE = "Avi-se-xmvrz"
+)
+
+var client = &http.Client{Timeout: 2 * time.Minute}
+
+// agentURL is the agent under test, INTEGRATION_AGENT_URL or the compose stack's published port
+func agentURL() string {
+	if url := os.Getenv("INTEGRATION_AGENT_URL"); url != "" {
+		return strings.TrimRight(url, "/")
+	}
+	return "http://localhost:18080"
+}
+
+// call sends a request to the agent and decodes the JSON response
+func call(t *testing.T, method, path string, body interface{}, out interface{}) int {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, agentURL()+path, reader)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Remote-User", "integration")
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	if out != nil {
+		require.NoError(t, json.Unmarshal(data, out), string(data))
+	}
+	return resp.StatusCode
+}
+
+type chatResponse struct {
+	Message   string   `json:"message"`
+	Session   string   `json:"session"`
+	Approvals []string `json:"approvals"`
+	ToolCalls []struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+		InvocationID string `json:"invocation_id"`
+	} `json:"tool_calls"`
+}
+
+// chat sends a chat message and returns the answer
+func chat(t *testing.T, session, message string) chatResponse {
+	t.Helper()
+	var resp chatResponse
+	status := call(t, http.MethodPost, "/api/chat", map[string]string{
+		"message": message,
+		"model":   os.Getenv("INTEGRATION_MODEL"),
+		"session": session,
+	}, &resp)
+	require.Equal(t, http.StatusOK, status, resp.Message)
+	return resp
+}
+
+func TestMain(m *testing.M) {
+	// Wait for the stack to become healthy
+	deadline := time.Now().Add(2 * time.Minute)
+	for {
+		resp, err := http.Get(agentURL() + "/api/health")
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			fmt.Fprintf(os.Stderr, "agent at %s isn't healthy: %v\n", agentURL(), err)
+			os.Exit(1)
+		}
+		time.Sleep(2 * time.Second)
+	}
+	os.Exit(m.Run())
+}
+
+func TestHealthAndCapabilities(t *testing.T) {
+	var health map[string]interface{}
+	require.Equal(t, http.StatusOK, call(t, http.MethodGet, "/api/health", nil, &health))
+	assert.Equal(t, "healthy", health["avi_status"])
+	assert.Equal(t, "healthy", health["llm_status"])
+	assert.Equal(t, true, health["sandbox"])
+
+	var caps struct {
+		Controller struct {
+			Sandbox bool `json:"sandbox"`
+		} `json:"controller"`
+		Tools []struct {
+			Name string `json:"name"`
+		} `json:"tools"`
+	}
+	require.Equal(t, http.StatusOK, call(t, http.MethodGet, "/api/capabilities", nil, &caps))
+	assert.True(t, caps.Controller.Sandbox)
+	assert.NotEmpty(t, caps.Tools)
+}
+
+// TestChatListVirtualServices runs the chat → tool → controller → answer loop
+func TestChatListVirtualServices(t *testing.T) {
+	resp := chat(t, "", "List all virtual services")
+	require.NotEmpty(t, resp.ToolCalls)
+	assert.Equal(t, "list_virtual_services", resp.ToolCalls[0].Function.Name)
+	assert.Contains(t, resp.Message, "shop-web-vs")
+	assert.NotEmpty(t, resp.Session)
+
+	// The executed call is recorded and can be inspected
+	var invocation map[string]interface{}
+	require.Equal(t, http.StatusOK, call(t, http.MethodGet, "/api/tools/invocations/"+resp.ToolCalls[0].InvocationID, nil, &invocation))
+	assert.Equal(t, "list_virtual_services", invocation["tool"])
+
+	// The message is kept in the session history
+	var history map[string]interface{}
+	require.Equal(t, http.StatusOK, call(t, http.MethodGet, "/api/chat/history?session="+resp.Session, nil, &history))
+}
+
+// TestChangeNeedsConfirmation changes a virtual service through the chat and checks that
+// re-running the change is only done once confirmed and is audited
+func TestChangeNeedsConfirmation(t *testing.T) {
+	resp := chat(t, "", "Disable virtual service "+intranetVS)
+	require.NotEmpty(t, resp.ToolCalls)
+	assert.Equal(t, "disable_virtual_service", resp.ToolCalls[0].Function.Name)
+	id := resp.ToolCalls[0].InvocationID
+
+	var rerun map[string]interface{}
+	assert.Equal(t, http.StatusConflict, call(t, http.MethodPost, "/api/tools/invocations/"+id+"/rerun", nil, &rerun))
+	assert.Equal(t, http.StatusOK, call(t, http.MethodPost, "/api/tools/invocations/"+id+"/rerun?confirm=true", nil, &rerun))
+
+	var records []map[string]interface{}
+	require.Equal(t, http.StatusOK, call(t, http.MethodGet, "/api/audit/export?format=json", nil, &records))
+	var audited int
+	for _, record := range records {
+		if record["tool"] == "disable_virtual_service" && record["operator"] == "integration" {
+			audited++
+		}
+	}
+	assert.GreaterOrEqual(t, audited, 2)
+
+	// Leave the sample data as it was
+	chat(t, "", "Enable virtual service "+intranetVS)
+}
+
+// TestChangeAwaitsApproval checks that a maintenance step needing approval isn't run by the chat
+// and only runs once approved through the re-run endpoint
+func TestChangeAwaitsApproval(t *testing.T) {
+	resp := chat(t, "", "Start maintenance: disable service engine "+maintenanceSE)
+	require.NotEmpty(t, resp.ToolCalls)
+	assert.Equal(t, "service_engine_maintenance", resp.ToolCalls[0].Function.Name)
+	id := resp.ToolCalls[0].InvocationID
+	assert.Equal(t, []string{id}, resp.Approvals)
+	assert.Contains(t, resp.Message, "Awaiting approval")
+
+	var rerun map[string]interface{}
+	assert.Equal(t, http.StatusConflict, call(t, http.MethodPost, "/api/tools/invocations/"+id+"/rerun", nil, &rerun))
+	require.Equal(t, http.StatusOK, call(t, http.MethodPost, "/api/tools/invocations/"+id+"/rerun?confirm=true", nil, &rerun))
+	report, _ := rerun["result"].(map[string]interface{})
+	assert.Equal(t, "SE_STATE_DISABLED", report["enable_state"])
+
+	// Leave the sample data as it was, enabling needs approval too
+	resp = chat(t, "", "Finish maintenance: enable service engine "+maintenanceSE)
+	require.Len(t, resp.Approvals, 1)
+	require.Equal(t, http.StatusOK, call(t, http.MethodPost, "/api/tools/invocations/"+resp.Approvals[0]+"/rerun?confirm=true", nil, &rerun))
+}
+
+// TestChatCompletionsStream reads an OpenAI-compatible chat completion as server-sent events
+func TestChatCompletionsStream(t *testing.T) {
+	data, err := json.Marshal(map[string]interface{}{
+		"model":    os.Getenv("INTEGRATION_MODEL"),
+		"stream":   true,
+		"messages": []map[string]string{{"role": "user", "content": "List all virtual services"}},
+	})
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, agentURL()+"/v1/chat/completions", bytes.NewReader(data))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Remote-User", "integration")
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	var content strings.Builder
+	var finished, done bool
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		payload, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		if payload == "[DONE]" {
+			done = true
+			break
+		}
+		var chunk struct {
+			Object  string `json:"object"`
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+				FinishReason *string `json:"finish_reason"`
+			} `json:"choices"`
+			Usage *struct {
+				TotalTokens int `json:"total_tokens"`
+			} `json:"usage"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(payload), &chunk), payload)
+		assert.Equal(t, "chat.completion.chunk", chunk.Object)
+		require.Len(t, chunk.Choices, 1)
+		content.WriteString(chunk.Choices[0].Delta.Content)
+		if chunk.Choices[0].FinishReason != nil {
+			finished = true
+			assert.Equal(t, "stop", *chunk.Choices[0].FinishReason)
+			assert.NotNil(t, chunk.Usage, "the usage comes with the finish chunk")
+		}
+	}
+	require.NoError(t, scanner.Err())
+	assert.True(t, finished, "a chunk has the finish reason")
+	assert.True(t, done, "the stream ends with [DONE]")
+	assert.Contains(t, content.String(), "shop-web-vs")
+}
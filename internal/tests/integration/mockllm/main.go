@@ -0,0 +1,113 @@
+// Command mockllm is a deterministic stand-in for Ollama used by the integration test stack. It
+// answers /api/chat by picking a tool from keywords in the last user message, so the full
+// chat → tool → controller → answer loop can be exercised without downloading a model.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// model is the only model the mock serves
+const model = "mock"
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+}
+
+func main() {
+	addr := flag.String("addr", ":11434", "listen address")
+	flag.Parse()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/tags", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"models": []map[string]interface{}{{"name": model, "modified_at": time.Now().UTC()}},
+		})
+	})
+	mux.HandleFunc("/api/chat", func(w http.ResponseWriter, r *http.Request) {
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var query string
+		for _, msg := range req.Messages {
+			if msg.Role == "user" {
+				query = msg.Content
+			}
+		}
+		writeJSON(w, map[string]interface{}{
+			"model":             req.Model,
+			"created_at":        time.Now().UTC().Format(time.RFC3339),
+			"message":           chatMessage{Role: "assistant", Content: reply(query)},
+			"done":              true,
+			"prompt_eval_count": len(strings.Fields(query)),
+			"eval_count":        16,
+		})
+	})
+
+	log.Printf("mock LLM listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+// reply returns a tool call for the operations the integration tests ask for, or a plain answer
+func reply(query string) string {
+	q := strings.ToLower(query)
+	var call map[string]interface{}
+	switch {
+	case strings.Contains(q, "maintenance"):
+		step := "plan"
+		if strings.Contains(q, "disable") {
+			step = "disable"
+		} else if strings.Contains(q, "enable") {
+			step = "enable"
+		}
+		call = map[string]interface{}{"tool": "service_engine_maintenance", "parameters": map[string]interface{}{"service_engine": serviceEngineIn(query), "step": step}}
+	case strings.Contains(q, "disable"):
+		call = map[string]interface{}{"tool": "disable_virtual_service", "parameters": map[string]interface{}{"uuid": uuidIn(q)}}
+	case strings.Contains(q, "enable"):
+		call = map[string]interface{}{"tool": "enable_virtual_service", "parameters": map[string]interface{}{"uuid": uuidIn(q)}}
+	case strings.Contains(q, "virtual service"):
+		call = map[string]interface{}{"tool": "list_virtual_services", "parameters": map[string]interface{}{}}
+	default:
+		call = map[string]interface{}{"message": "I can list, enable and disable virtual services and put service engines in maintenance."}
+	}
+	data, _ := json.Marshal(call)
+	return string(data)
+}
+
+// uuidIn returns the first virtual service UUID in the query
+func uuidIn(q string) string {
+	for _, word := range strings.Fields(q) {
+		if strings.HasPrefix(word, "virtualservice-") {
+			return strings.Trim(word, ".,?!")
+		}
+	}
+	return ""
+}
+
+// serviceEngineIn returns the first service engine name in the query, keeping its case
+func serviceEngineIn(query string) string {
+	for _, word := range strings.Fields(query) {
+		if strings.HasPrefix(strings.ToLower(word), "avi-se-") {
+			return strings.Trim(word, ".,?!")
+		}
+	}
+	return ""
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
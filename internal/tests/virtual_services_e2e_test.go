@@ -12,8 +12,8 @@ import (
 	"testing"
 	"time"
 
-	"aviagent/internal/config"
-	"aviagent/internal/llm"
+	"aviagent/pkg/config"
+	"aviagent/pkg/llm"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -0,0 +1,206 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"aviagent/pkg/audit"
+	"aviagent/pkg/avi"
+	"aviagent/pkg/llm"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// objectTypes maps fragments of tool names to the Avi object type their uuid argument refers to
+var objectTypes = []struct {
+	fragment string
+	object   string
+}{
+	{"virtual_service", "virtualservice"},
+	{"vs_", "virtualservice"},
+	{"health_monitor", "healthmonitor"},
+	{"service_engine", "serviceengine"},
+	{"pool", "pool"},
+}
+
+// objectLink returns the path of the object a tool call acted on, served through the Avi API
+// proxy, or "" when the call doesn't name a single object
+func objectLink(tool string, args map[string]interface{}) string {
+	uuid, _ := args["uuid"].(string)
+	if uuid == "" {
+		return ""
+	}
+	for _, t := range objectTypes {
+		if strings.Contains(tool, t.fragment) {
+			return avi.ProxyPrefix + "/" + t.object + "/" + uuid
+		}
+	}
+	return ""
+}
+
+// toJSON renders a value as JSON for data attributes read by the message actions
+func toJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "null"
+	}
+	return string(data)
+}
+
+// formatResult renders a tool result as indented JSON for an answer, or with %v when it can't
+// be encoded
+func formatResult(result interface{}) string {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("%v", result)
+	}
+	return string(data)
+}
+
+// lookupInvocation returns the recorded tool call named by the :id parameter. Calls recorded for
+// an operator can only be read or re-run by that operator.
+func (s *Server) lookupInvocation(c *gin.Context) (ToolInvocation, bool) {
+	invocation, ok := s.sessions.Invocation(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, errorResponse{Error: "tool invocation not found"})
+		return invocation, false
+	}
+	if invocation.Operator != "" && invocation.Operator != s.operator(c) {
+		c.JSON(http.StatusForbidden, errorResponse{Error: "tool invocation belongs to another operator"})
+		return invocation, false
+	}
+	return invocation, true
+}
+
+// rerunInvocation executes a recorded tool call again under the permission checks and auditing
+// of a chat tool call. The new call is recorded too, so its result can be re-run in turn. A
+// successful change comes with its receipt; a change matching an approval rule is queued for a
+// second person instead, with an *approvalQueuedError.
+func (s *Server) rerunInvocation(ctx context.Context, invocation ToolInvocation) (llm.ToolCall, interface{}, *audit.Receipt, error) {
+	toolCall := llm.ToolCall{
+		Type:     "function",
+		Function: llm.ToolCallFunction{Name: invocation.Tool},
+		Args:     invocation.Args,
+	}
+	actor := audit.ActorFrom(ctx)
+	toolCall.InvocationID = s.sessions.RecordInvocation(actor.Session, actor.Operator, toolCall)
+	if err := s.approvalQueueError(ctx, toolCall); err != nil {
+		return toolCall, nil, nil, err
+	}
+
+	mutating := s.tools.IsMutating(toolCall.Function.Name, toolCall.Args)
+	var entry audit.Entry
+	if mutating {
+		entry = newAuditEntry(ctx, toolCall)
+		s.beginChange(ctx, &entry, toolCall)
+	}
+	result, err := s.executeToolCall(ctx, toolCall)
+	var receipt *audit.Receipt
+	if mutating {
+		receipt = s.recordChange(ctx, entry, toolCall, result, err)
+	}
+	if err != nil {
+		s.logger.Error("Tool re-run failed",
+			zap.String("tool", toolCall.Function.Name),
+			zap.String("invocation", invocation.ID),
+			zap.Error(err))
+	}
+	return toolCall, result, receipt, err
+}
+
+// handleGetInvocation returns a recorded tool call
+func (s *Server) handleGetInvocation(c *gin.Context) {
+	invocation, ok := s.lookupInvocation(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, invocation)
+}
+
+// rerunResponse is the result of a re-run tool call
+type rerunResponse struct {
+	InvocationID string         `json:"invocation_id"`
+	Tool         string         `json:"tool"`
+	Result       interface{}    `json:"result"`
+	Receipt      *audit.Receipt `json:"receipt,omitempty"` // calls that changed configuration
+}
+
+// rerunErrorResponse is a failed re-run, with the error the model would have been given
+type rerunErrorResponse struct {
+	errorResponse
+	ToolError    llm.ToolError `json:"tool_error"`
+	InvocationID string        `json:"invocation_id"`
+}
+
+// handleRerunInvocation re-runs a recorded tool call and returns its result. Calls that change
+// configuration are only re-run with ?confirm=true.
+func (s *Server) handleRerunInvocation(c *gin.Context) {
+	invocation, ok := s.lookupInvocation(c)
+	if !ok {
+		return
+	}
+	if s.tools.IsMutating(invocation.Tool, invocation.Args) && c.Query("confirm") != "true" {
+		c.JSON(http.StatusConflict, errorResponse{Error: fmt.Sprintf("%s changes configuration; re-run it with ?confirm=true", invocation.Tool)})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+	defer cancel()
+	ctx = s.withActor(ctx, c, invocation.Session, "")
+
+	toolCall, result, receipt, err := s.rerunInvocation(ctx, invocation)
+	var queued *approvalQueuedError
+	if errors.As(err, &queued) {
+		c.JSON(http.StatusAccepted, queued.request)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, rerunErrorResponse{
+			errorResponse: errorResponse{Error: err.Error()},
+			ToolError:     llm.ToolErrorFrom(toolCall.Function.Name, err),
+			InvocationID:  toolCall.InvocationID,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, rerunResponse{InvocationID: toolCall.InvocationID, Tool: toolCall.Function.Name, Result: result, Receipt: receipt})
+}
+
+// handleHTMXRerunInvocation re-runs a recorded tool call and renders its result as an assistant
+// message. The UI asks for confirmation before re-running calls that change configuration.
+func (s *Server) handleHTMXRerunInvocation(c *gin.Context) {
+	invocation, ok := s.sessions.Invocation(c.Param("id"))
+	if !ok {
+		c.HTML(http.StatusNotFound, "chat.html", gin.H{"error": "Tool invocation not found"})
+		return
+	}
+	if invocation.Operator != "" && invocation.Operator != s.operator(c) {
+		c.HTML(http.StatusForbidden, "chat.html", gin.H{"error": "Tool invocation belongs to another operator"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+	defer cancel()
+	ctx = s.withActor(ctx, c, invocation.Session, "")
+
+	toolCall, result, receipt, err := s.rerunInvocation(ctx, invocation)
+	if err != nil {
+		c.HTML(http.StatusOK, "chat.html", gin.H{"error": fmt.Sprintf("Re-running %s failed: %v", invocation.Tool, err)})
+		return
+	}
+	var receipts []audit.Receipt
+	if receipt != nil {
+		receipts = append(receipts, *receipt)
+	}
+	c.HTML(http.StatusOK, "chat.html", gin.H{
+		"assistantMessage": fmt.Sprintf("Re-ran %s.%s", toolCall.Function.Name, s.renderResult(ctx, toolCall, s.defaultModel(), result)),
+		"toolCalls":        []llm.ToolCall{toolCall},
+		"receipts":         receipts,
+		"timestamp":        time.Now().Format("15:04:05"),
+	})
+}
@@ -0,0 +1,128 @@
+package web
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// defaultActiveWithin is how recently a session must have been used to be listed as active
+const defaultActiveWithin = time.Hour
+
+// adminAuthMiddleware requires the admin token as a bearer token
+func (s *Server) adminAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.config.Admin.Token)) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse{Error: "invalid admin token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// handleAdminFlushCache drops the inventory snapshots of simulations and the clock skew
+// measurement, so the next questions read the controller again. The controller sessions don't
+// cache API responses, so there is nothing of theirs to drop.
+func (s *Server) handleAdminFlushCache(c *gin.Context) {
+	inventories := 0
+	if s.simulations != nil {
+		inventories = s.simulations.flush()
+	}
+	s.clockSkew.Reset()
+
+	s.logger.Info("Flushed caches",
+		zap.String("operator", s.operator(c)),
+		zap.Int("simulation_inventories", inventories))
+	c.JSON(http.StatusOK, gin.H{
+		"simulation_inventories": inventories,
+		"clock_skew":             s.clockSkew.Enabled(),
+	})
+}
+
+// handleAdminSessions lists the chat sessions active within ?within= (a duration, 1h by default)
+// and those waiting for the provider
+func (s *Server) handleAdminSessions(c *gin.Context) {
+	within := defaultActiveWithin
+	if value := c.Query("within"); value != "" {
+		var err error
+		if within, err = time.ParseDuration(value); err != nil || within <= 0 {
+			c.JSON(http.StatusBadRequest, errorResponse{Error: "within must be a positive duration such as 15m or 2h"})
+			return
+		}
+	}
+
+	now := time.Now()
+	sessions := s.sessions.Active(now.Add(-within))
+	debugged := map[string]bool{}
+	if s.logLevels != nil {
+		for _, session := range s.logLevels.DebuggedSessions(now) {
+			debugged[session.ID] = true
+		}
+	}
+	type adminSession struct {
+		ActiveSession
+		Debugged bool `json:"debugged"` // logged at debug level, see /api/v1/debug/sessions
+	}
+	listed := make([]adminSession, 0, len(sessions))
+	for _, session := range sessions {
+		listed = append(listed, adminSession{ActiveSession: session, Debugged: debugged[session.ID]})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"within":   within.String(),
+		"total":    len(listed),
+		"sessions": listed,
+	})
+}
+
+// handleAdminConfig returns the provider and models in use and the effective configuration,
+// secrets masked
+func (s *Server) handleAdminConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"provider":      s.providerCapabilities(),
+		"configuration": s.config.Masked(),
+	})
+}
+
+// adminTool is a tool and whether it is offered to the model
+type adminTool struct {
+	Name     string `json:"name"`
+	Mutating bool   `json:"mutating"`
+	Allowed  bool   `json:"allowed"` // the Avi account's role permits it
+	Enabled  bool   `json:"enabled"` // the tools configuration offers it
+}
+
+// handleAdminTools lists every tool, whether the tools configuration enables it and whether the
+// Avi account's role allows it
+func (s *Server) handleAdminTools(c *gin.Context) {
+	tools := []adminTool{}
+	allowed := 0
+	for _, tool := range s.tools.All() {
+		name := tool.Function.Name
+		entry := adminTool{
+			Name:     name,
+			Mutating: s.tools.IsMutating(name, nil),
+			Allowed:  s.permissions == nil || s.permissions.AllowsTool(name),
+			Enabled:  s.tools.Enabled(name),
+		}
+		if entry.Allowed {
+			allowed++
+		}
+		tools = append(tools, entry)
+	}
+
+	response := gin.H{
+		"least_privilege": s.permissions != nil,
+		"allowed":         allowed,
+		"tools":           tools,
+	}
+	if s.permissions != nil {
+		response["role"] = s.permissions.Role
+	}
+	c.JSON(http.StatusOK, response)
+}
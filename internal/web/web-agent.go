@@ -0,0 +1,223 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"aviagent/internal/insights"
+	"aviagent/internal/requestid"
+	"aviagent/internal/style"
+	"aviagent/pkg/agent/chat"
+	"aviagent/pkg/audit"
+	"aviagent/pkg/llm"
+
+	"go.uber.org/zap"
+)
+
+// chatRuntime runs the chat turns of the server through chat.Run
+type chatRuntime struct {
+	s *Server
+}
+
+func (r chatRuntime) Logger(ctx context.Context) *zap.Logger {
+	return requestid.Logger(ctx, r.s.logger)
+}
+
+func (r chatRuntime) Prepare(ctx context.Context, sessionID, model string, history []llm.ChatMessage) []llm.ChatMessage {
+	history = r.s.fitHistory(ctx, sessionID, model, history)
+	return r.s.withMemory(withNotes(r.s.sessions.Notes(sessionID), history))
+}
+
+func (r chatRuntime) Elevate(ctx context.Context, sessionID string) func() {
+	return r.s.elevateSession(ctx, sessionID)
+}
+
+func (r chatRuntime) SetStatus(sessionID, status string) {
+	r.s.sessions.SetStatus(sessionID, status)
+}
+
+// Answer takes an answer to the question of a creation wizard as completing its tool call
+// instead of sending it to a model
+func (r chatRuntime) Answer(ctx context.Context, sessionID, message, model string, history []llm.ChatMessage) (*llm.LLMResponse, error) {
+	if response, answered := r.s.answerWizard(sessionID, model, message); answered {
+		return response, nil
+	}
+	return r.s.queryModels(ctx, message, model, history)
+}
+
+func (r chatRuntime) ClockSkewWarning(ctx context.Context) string {
+	return r.s.clockSkew.Warning(ctx)
+}
+
+func (r chatRuntime) StartWizard(ctx context.Context, sessionID string, toolCall llm.ToolCall) (string, bool) {
+	return r.s.startWizard(ctx, sessionID, toolCall)
+}
+
+func (r chatRuntime) RecordInvocation(sessionID, operator string, toolCall llm.ToolCall) string {
+	return r.s.sessions.RecordInvocation(sessionID, operator, toolCall)
+}
+
+func (r chatRuntime) IsMutating(toolCall llm.ToolCall) bool {
+	return r.s.tools.IsMutating(toolCall.Function.Name, toolCall.Args)
+}
+
+func (r chatRuntime) QueueApproval(ctx context.Context, toolCall llm.ToolCall) (string, string, bool) {
+	request := r.s.queueApproval(ctx, toolCall)
+	if request == nil {
+		return "", "", false
+	}
+	return request.Rule, request.ID, true
+}
+
+func (r chatRuntime) NeedsApproval(toolCall llm.ToolCall) bool {
+	return r.s.needsApproval(toolCall)
+}
+
+// Execute audits a change with the object as it was before and after it
+func (r chatRuntime) Execute(ctx context.Context, toolCall llm.ToolCall, mutating bool) (interface{}, *audit.Receipt, error) {
+	if !mutating {
+		result, err := r.s.executeToolCall(ctx, toolCall)
+		return result, nil, err
+	}
+	entry := newAuditEntry(ctx, toolCall)
+	r.s.beginChange(ctx, &entry, toolCall)
+	result, err := r.s.executeToolCall(ctx, toolCall)
+	return result, r.s.recordChange(ctx, entry, toolCall, result, err), err
+}
+
+func (r chatRuntime) Advise(ctx context.Context, model string, toolCall llm.ToolCall, toolErr llm.ToolError, err error) string {
+	return r.s.adviseToolError(ctx, model, toolCall, toolErr, err)
+}
+
+func (r chatRuntime) Render(ctx context.Context, toolCall llm.ToolCall, model string, result interface{}) string {
+	return r.s.renderResult(ctx, toolCall, model, result)
+}
+
+// Finish adds the insights the results raise that the operator hasn't acknowledged or snoozed,
+// corrects what breaks the deployment style rules, then redacts or blocks secrets and
+// disallowed content before the answer is rendered
+func (r chatRuntime) Finish(ctx context.Context, response *llm.LLMResponse, rendered []int, results []interface{}) {
+	s := r.s
+	var raised []insights.Insight
+	var objects []style.Object // named in the results, for the style guide
+	for _, result := range results {
+		raised = append(raised, insights.Detect(result)...)
+		if s.config.Style.IncludeUUIDs {
+			objects = append(objects, style.Objects(result)...)
+		}
+	}
+	for _, insight := range s.insights.Raise(audit.ActorFrom(ctx).Operator, raised, time.Now()) {
+		response.Notices = append(response.Notices, insights.Notice(insight))
+	}
+
+	var corrections []style.Correction
+	response.Message, corrections = s.styleGuide.Apply(response.Message, objects)
+	if len(corrections) > 0 {
+		r.Logger(ctx).Info("Corrected answer style", zap.Any("corrections", corrections))
+	}
+	moderated := s.moderator.Moderate(ctx, response.Message)
+	response.Message = moderated.Text
+	if notice := moderated.Notice(); notice != "" {
+		response.Notices = append(response.Notices, notice)
+	}
+
+	// The typed results are read back from the moderated message, so they are redacted as it is;
+	// a blocked answer has none to read
+	if _, blocks := extractToolResults(response.Message); len(blocks) == len(rendered) {
+		for j, index := range rendered {
+			response.ToolResults[index].Data = blocks[j].data
+			response.ToolResults[index].Summary = blocks[j].summary
+		}
+	}
+}
+
+// Chat runs a chat turn of a session outside HTTP, with the same model and intent routing, tool
+// execution, auditing and moderation as /api/v1/chat. The session is created when it doesn't
+// exist; the operator recorded in the audit trail is the actor already in the context, if any.
+func (s *Server) Chat(ctx context.Context, sessionID, model, message string) (*chat.Result, error) {
+	if command, argument, ok := s.routeIntent(ctx, message); ok {
+		model = valueOr(model, s.config.LLM.DefaultModel)
+		session := s.sessions.GetOrCreate(sessionID, model)
+		actor := audit.ActorFrom(ctx)
+		actor.Session = session.ID
+		ctx = audit.WithActor(ctx, actor)
+		response := s.runSlashCommand(ctx, command, argument)
+		s.sessions.AppendExchange(session.ID, model, message, response.Message, toolCallNames(response.ToolCalls))
+		return &chat.Result{LLMResponse: response, Session: session.ID, SessionUsage: s.sessions.Usage(session.ID)}, nil
+	}
+
+	model, route := s.routeModel(message, model)
+	if model == "" {
+		model = s.config.LLM.DefaultModel
+	}
+
+	validateCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	validModel, err := s.validateModel(validateCtx, model)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate model: %w", err)
+	}
+	if !validModel {
+		return nil, fmt.Errorf("model '%s' is not available", model)
+	}
+
+	session := s.sessions.GetOrCreate(sessionID, model)
+	actor := audit.ActorFrom(ctx)
+	actor.Session = session.ID
+	actor.Model = model
+	ctx = audit.WithActor(ctx, actor)
+	ctx, _ = s.withSeed(ctx, session.ID, nil)
+
+	response, err := chat.Run(ctx, chatRuntime{s}, message, model, s.sessions.History(session.ID))
+	if err != nil {
+		return nil, err
+	}
+	usage := s.sessions.RecordUsage(session.ID, answeringModel(response, model), response.Usage)
+	s.sessions.AppendExchange(session.ID, model, message, response.Message, toolCallNames(response.ToolCalls))
+	return &chat.Result{LLMResponse: response, Session: session.ID, SessionUsage: usage, Route: route}, nil
+}
+
+// SaveSession writes a session, with its messages and usage, to a JSON file
+func (s *Server) SaveSession(id, path string) error {
+	session, ok := s.sessions.Export(id)
+	if !ok {
+		return fmt.Errorf("session %s not found", id)
+	}
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write session file: %w", err)
+	}
+	return nil
+}
+
+// LoadSession restores a session saved with SaveSession and returns its ID
+func (s *Server) LoadSession(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read session file: %w", err)
+	}
+	var session ChatSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return "", fmt.Errorf("invalid session file %s: %w", path, err)
+	}
+	if session.ID == "" {
+		return "", fmt.Errorf("invalid session file %s: no session id", path)
+	}
+	s.sessions.Import(&session)
+	return session.ID, nil
+}
+
+// SessionUsage returns the accumulated token usage of a session
+func (s *Server) SessionUsage(id string) (chat.SessionUsage, error) {
+	session, ok := s.sessions.Export(id)
+	if !ok {
+		return chat.SessionUsage{}, fmt.Errorf("session %s not found", id)
+	}
+	return session.Usage, nil
+}
@@ -0,0 +1,152 @@
+package web
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"aviagent/internal/alerts"
+	"aviagent/internal/notify"
+	"aviagent/pkg/llm"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// maxAlertBody limits the size of an alert webhook body
+const maxAlertBody = 1 << 20
+
+// alertAcceptedResponse acknowledges an alert webhook
+type alertAcceptedResponse struct {
+	ID       string `json:"id"`
+	Severity string `json:"severity"`
+}
+
+// alertsResponse lists the received alerts
+type alertsResponse struct {
+	Enabled bool           `json:"enabled"` // alerts.enabled
+	Alerts  []alerts.Alert `json:"alerts"`
+}
+
+// handleAviAlert receives an alert posted by the Avi controller's alert action, stores it and
+// processes it in the background: summary, notification and chat session message. The session
+// is alerts.session unless the webhook URL passes ?session= (a session ID or "latest").
+func (s *Server) handleAviAlert(c *gin.Context) {
+	if s.alerts == nil {
+		c.JSON(http.StatusNotFound, errorResponse{Error: "the alert receiver is disabled (alerts.enabled)"})
+		return
+	}
+	token := c.GetHeader("X-Alert-Token")
+	if token == "" {
+		token = c.Query("token")
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.config.Alerts.Token)) != 1 {
+		c.JSON(http.StatusUnauthorized, errorResponse{Error: "invalid alert token"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxAlertBody))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	alert, err := alerts.Parse(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	stored := s.alerts.Add(alert, time.Now().UTC())
+	s.logger.Info("Received Avi alert",
+		zap.String("id", stored.ID),
+		zap.String("alert", stored.Name),
+		zap.String("object", stored.Object),
+		zap.String("severity", stored.Severity))
+
+	go s.processAlert(stored, c.DefaultQuery("session", s.config.Alerts.Session))
+	c.JSON(http.StatusAccepted, alertAcceptedResponse{ID: stored.ID, Severity: stored.Severity})
+}
+
+// processAlert explains a received alert, publishes it to the notification channels and posts
+// it to a chat session. The summary is written by the model when alerts.summarize is set.
+func (s *Server) processAlert(alert *alerts.Alert, session string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	var completer llm.Completer
+	if s.config.Alerts.Summarize {
+		completer = s.llmClient
+	}
+	summary, source := alerts.Summarize(ctx, completer, s.alertModel(), alert)
+	if err := s.alerts.SetSummary(alert.ID, summary, source); err != nil {
+		s.logger.Debug("Alert dropped before its summary was saved", zap.String("id", alert.ID))
+	}
+
+	if s.config.Alerts.Notify {
+		event := notify.Event{
+			Type:     notify.EventAlertReceived,
+			Title:    alert.Title(),
+			Summary:  summary,
+			Severity: alert.Severity,
+			Source:   alert.Object,
+			Time:     alert.Received,
+			Data:     alert,
+		}
+		// Publish logs the channels that failed
+		_ = s.notifier.Publish(ctx, event)
+	}
+
+	if session == "latest" {
+		session, _ = s.sessions.Latest()
+	}
+	if session != "" {
+		notice := fmt.Sprintf("Avi alert %s (%s): %s", alert.Title(), alert.Severity, summary)
+		if !s.sessions.AppendNotice(session, notice) {
+			s.logger.Warn("Alert not posted, chat session not found", zap.String("id", alert.ID), zap.String("session", session))
+		}
+	}
+}
+
+// alertModel is the model explaining alerts: alerts.model, or the provider's default model
+func (s *Server) alertModel() string {
+	if s.config.Alerts.Model != "" {
+		return s.config.Alerts.Model
+	}
+	return s.defaultModel()
+}
+
+// defaultModel is the default model of the configured provider
+func (s *Server) defaultModel() string {
+	if s.config.Provider == "mistral" {
+		return s.config.Mistral.DefaultModel
+	}
+	return s.config.LLM.DefaultModel
+}
+
+// handleListAlerts lists the received alerts, most recent first (?limit=, 50 by default)
+func (s *Server) handleListAlerts(c *gin.Context) {
+	if s.alerts == nil {
+		c.JSON(http.StatusOK, alertsResponse{Enabled: false, Alerts: []alerts.Alert{}})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 0 {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: "limit must be a positive number"})
+		return
+	}
+	c.JSON(http.StatusOK, alertsResponse{Enabled: true, Alerts: s.alerts.List(limit)})
+}
+
+// handleGetAlert returns a received alert with its summary
+func (s *Server) handleGetAlert(c *gin.Context) {
+	if s.alerts != nil {
+		if alert, ok := s.alerts.Get(c.Param("id")); ok {
+			c.JSON(http.StatusOK, alert)
+			return
+		}
+	}
+	c.JSON(http.StatusNotFound, errorResponse{Error: fmt.Sprintf("alert %s not found", c.Param("id"))})
+}
@@ -0,0 +1,278 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"aviagent/internal/alerts"
+	"aviagent/internal/approvals"
+	"aviagent/internal/blueprint"
+	"aviagent/internal/drift"
+	"aviagent/internal/logging"
+	"aviagent/internal/memory"
+	"aviagent/internal/scheduler"
+	"aviagent/internal/workflow"
+	"aviagent/pkg/audit"
+	"aviagent/pkg/avi"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiVersion is the version of the JSON API: its path below /api and the version of its OpenAPI
+// document. Changes breaking the request and response types of a version need a new one.
+const apiVersion = "v1"
+
+// anyMethod registers a route for every method, see gin.RouterGroup.Any
+const anyMethod = "ANY"
+
+// errorResponse is the body of a failed API request
+type errorResponse struct {
+	Error     string `json:"error"`
+	RequestID string `json:"request_id,omitempty"` // the X-Request-ID of the request, to find it in the logs
+}
+
+// messageResponse confirms a request that has nothing else to return
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
+// apiRoute is an endpoint of the JSON API and its description in the OpenAPI document. Request
+// and Response are values of the body types, read by reflection.
+type apiRoute struct {
+	Method   string // or anyMethod
+	Path     string // below /api/v1, in gin syntax
+	Tag      string
+	Summary  string
+	Query    []apiParam
+	Request  interface{} // nil without a JSON body
+	Response interface{} // nil for any JSON value, apiOneOf for several shapes
+	Status   int         // of a successful response, 200 when 0
+	File     string      // media type of a file answered instead of JSON
+	Listed   bool        // polled list tagged with ETags, see etagMiddleware
+	Handler  gin.HandlerFunc
+}
+
+// apiParam is a query parameter of a route
+type apiParam struct {
+	Name        string
+	Type        string // JSON schema type, string when empty
+	Description string
+}
+
+// apiOneOf is a response having one of several shapes
+type apiOneOf []interface{}
+
+// apiRoutes returns the endpoints of the JSON API
+func (s *Server) apiRoutes() []apiRoute {
+	session := apiParam{Name: "session", Description: "chat session ID"}
+	routes := []apiRoute{
+		{Method: http.MethodPost, Path: "/chat", Tag: "chat", Summary: "Ask a question, running the Avi tools it needs",
+			Request: chatRequest{}, Response: chatResponse{}, Handler: s.handleChat},
+		{Method: http.MethodPost, Path: "/extract", Tag: "chat", Summary: "Extract the Avi objects named in a text, such as a ticket",
+			Request: extractRequest{}, Response: extractResponse{}, Handler: s.handleExtract},
+		{Method: http.MethodGet, Path: "/chat/history", Tag: "chat", Summary: "List the sessions, or with session the messages of one",
+			Query:    []apiParam{session, {Name: "offset", Type: "integer"}, {Name: "before", Type: "integer", Description: "load the messages older than this one"}, {Name: "limit", Type: "integer"}},
+			Response: apiOneOf{SessionPage{}, MessagePage{}}, Listed: true, Handler: s.handleChatHistory},
+		{Method: http.MethodGet, Path: "/chat/status", Tag: "chat", Summary: "Provider status of the question a session waits for",
+			Query: []apiParam{session}, Response: chatStatusResponse{}, Handler: s.handleChatStatus},
+		{Method: http.MethodDelete, Path: "/chat/history", Tag: "chat", Summary: "Clear the history of a session, or of every session",
+			Query: []apiParam{session}, Response: messageResponse{}, Handler: s.handleClearHistory},
+		{Method: http.MethodGet, Path: "/chat/notes", Tag: "chat", Summary: "List the notes kept for a session",
+			Query: []apiParam{session}, Response: notesResponse{}, Listed: true, Handler: s.handleListNotes},
+		{Method: http.MethodPost, Path: "/chat/notes", Tag: "chat", Summary: "Keep a note for a session",
+			Request: addNoteRequest{}, Response: SessionNote{}, Handler: s.handleAddNote},
+		{Method: http.MethodDelete, Path: "/chat/notes/:id", Tag: "chat", Summary: "Remove a note of a session",
+			Query: []apiParam{session}, Response: messageResponse{}, Handler: s.handleDeleteNote},
+
+		{Method: http.MethodGet, Path: "/tools/invocations/:id", Tag: "tools", Summary: "Get a recorded tool call",
+			Response: ToolInvocation{}, Handler: s.handleGetInvocation},
+		{Method: http.MethodPost, Path: "/tools/invocations/:id/rerun", Tag: "tools", Summary: "Re-run a recorded tool call",
+			Query:    []apiParam{{Name: "confirm", Type: "boolean", Description: "required to re-run a call changing configuration"}},
+			Response: rerunResponse{}, Handler: s.handleRerunInvocation},
+
+		{Method: http.MethodGet, Path: "/models", Tag: "models", Summary: "List the models of the provider",
+			Response: modelsResponse{}, Listed: true, Handler: s.handleGetModels},
+		{Method: http.MethodPost, Path: "/models/validate", Tag: "models", Summary: "Check that the provider serves a model",
+			Request: validateModelRequest{}, Response: validateModelResponse{}, Handler: s.handleValidateModel},
+
+		{Method: http.MethodGet, Path: "/health", Tag: "status", Summary: "Health of the agent, the controller and the provider",
+			Response: healthResponse{}, Handler: s.handleHealth},
+		{Method: http.MethodGet, Path: "/capabilities", Tag: "status", Summary: "Provider, tools and safety settings in effect",
+			Response: Capabilities{}, Listed: true, Handler: s.handleCapabilities},
+		{Method: http.MethodGet, Path: "/openapi.json", Tag: "status", Summary: "This document",
+			Handler: s.handleOpenAPI},
+
+		{Method: http.MethodGet, Path: "/audit/export", Tag: "audit", Summary: "Export the audit trail of a time range",
+			Query: []apiParam{{Name: "from", Description: "RFC 3339 time or date"}, {Name: "to", Description: "RFC 3339 time or date"}, {Name: "format", Description: "csv or json"}},
+			File:  "text/csv", Handler: s.handleAuditExport},
+		{Method: http.MethodGet, Path: "/audit/verify", Tag: "audit", Summary: "Check the hash chain of the audit trail",
+			Response: auditVerifyResponse{}, Handler: s.handleAuditVerify},
+		{Method: http.MethodGet, Path: "/receipts/:id", Tag: "audit", Summary: "Get the receipt of a change made by a tool call",
+			Response: audit.Receipt{}, Handler: s.handleGetReceipt},
+
+		{Method: http.MethodGet, Path: "/configuration/export", Tag: "configuration", Summary: "Download a configuration export of the controller",
+			Query: []apiParam{{Name: "full_system", Type: "boolean"}}, File: "application/json", Handler: s.handleConfigExport},
+		{Method: http.MethodPost, Path: "/config/apply", Tag: "configuration", Summary: "Create or update the objects of a configuration export",
+			Query:   []apiParam{{Name: "dry_run", Type: "boolean", Description: "only validate the objects"}},
+			Request: map[string]interface{}{}, Response: apiOneOf{appliedConfiguration{}, approvals.Request{}}, Handler: s.handleConfigApply},
+
+		{Method: http.MethodGet, Path: "/blueprints", Tag: "blueprints", Summary: "List the blueprints of common application patterns with their parameters",
+			Response: blueprintsResponse{}, Listed: true, Handler: s.handleListBlueprints},
+		{Method: http.MethodGet, Path: "/blueprints/:name", Tag: "blueprints", Summary: "Get a blueprint with the objects it creates",
+			Response: blueprint.Blueprint{}, Handler: s.handleGetBlueprint},
+		{Method: http.MethodPost, Path: "/blueprints/:name/apply", Tag: "blueprints", Summary: "Create the objects of a blueprint from its parameters",
+			Query:   []apiParam{{Name: "dry_run", Type: "boolean", Description: "only expand and validate the objects"}},
+			Request: blueprintRequest{}, Response: apiOneOf{appliedBlueprint{}, approvals.Request{}}, Handler: s.handleApplyBlueprint},
+
+		{Method: http.MethodGet, Path: "/ansible/playbook", Tag: "ansible", Summary: "Download existing objects as an Ansible playbook of the vmware.alb modules",
+			Query: []apiParam{{Name: "objects", Description: "comma-separated type/name, e.g. virtualservice/web"},
+				{Name: "include_references", Type: "boolean", Description: "also write the objects they refer to, true by default"}},
+			File: "application/yaml", Handler: s.handleAnsiblePlaybook},
+		{Method: http.MethodPost, Path: "/ansible/playbook", Tag: "ansible", Summary: "Download a proposed change in export layout as an Ansible playbook",
+			Request: map[string]interface{}{}, File: "application/yaml", Handler: s.handleProposedPlaybook},
+
+		{Method: http.MethodGet, Path: "/insights", Tag: "insights", Summary: "List the insights raised, with the operator's acknowledgments",
+			Response: insightsResponse{}, Listed: true, Handler: s.handleListInsights},
+		{Method: http.MethodPost, Path: "/insights/:id/ack", Tag: "insights", Summary: "Acknowledge an insight",
+			Response: insightStateResponse{}, Handler: s.handleAcknowledgeInsight},
+		{Method: http.MethodPost, Path: "/insights/:id/snooze", Tag: "insights", Summary: "Snooze an insight",
+			Query:    []apiParam{{Name: "for", Description: "duration such as 4h or 7d, 24h by default"}},
+			Response: insightSnoozeResponse{}, Handler: s.handleSnoozeInsight},
+		{Method: http.MethodDelete, Path: "/insights/:id/ack", Tag: "insights", Summary: "Clear the acknowledgment or snooze of an insight",
+			Response: insightStateResponse{}, Handler: s.handleResetInsight},
+
+		{Method: http.MethodGet, Path: "/reports/jobs", Tag: "reports", Summary: "List the scheduled report jobs",
+			Response: reportJobsResponse{}, Listed: true, Handler: s.handleListReportJobs},
+		{Method: http.MethodPost, Path: "/reports/jobs/:name/run", Tag: "reports", Summary: "Run a report job now",
+			Response: scheduler.Report{}, Handler: s.handleRunReportJob},
+		{Method: http.MethodGet, Path: "/reports/sla", Tag: "reports", Summary: "Availability of the virtual services over a period",
+			Query: []apiParam{{Name: "period", Description: "30d by default"}, {Name: "target", Type: "number", Description: "percentage, 99.9 by default"},
+				{Name: "vs", Description: "comma-separated names or UUIDs"}, {Name: "format", Description: "json or csv"}, {Name: "locale", Description: "of CSV numbers"}},
+			Response: avi.AvailabilityReport{}, Handler: s.handleSLAReport},
+		{Method: http.MethodGet, Path: "/reports/digest", Tag: "reports", Summary: "Latest digest of configuration changes",
+			Response: scheduler.Report{}, Handler: s.handleChangeDigest},
+
+		{Method: http.MethodGet, Path: "/drift", Tag: "drift", Summary: "Drift between the declared configuration files and the controller",
+			Query:    []apiParam{{Name: "refresh", Type: "boolean", Description: "check now instead of returning the last check"}},
+			Response: drift.Report{}, Listed: true, Handler: s.handleDrift},
+
+		{Method: http.MethodGet, Path: "/notifications/channels", Tag: "notifications", Summary: "List the notification channels",
+			Response: channelsResponse{}, Listed: true, Handler: s.handleListNotificationChannels},
+		{Method: http.MethodPost, Path: "/notifications/channels/:name/test", Tag: "notifications", Summary: "Send a test event to a channel",
+			Response: messageResponse{}, Handler: s.handleTestNotificationChannel},
+
+		{Method: http.MethodPost, Path: "/hooks/avi-alert", Tag: "alerts", Summary: "Receive an alert from the controller's alert action",
+			Query:   []apiParam{{Name: "token", Description: "alert token, when the X-Alert-Token header can't be set"}, session},
+			Request: map[string]interface{}{}, Response: alertAcceptedResponse{}, Status: http.StatusAccepted, Handler: s.handleAviAlert},
+		{Method: http.MethodGet, Path: "/alerts", Tag: "alerts", Summary: "List the alerts received, most recent first",
+			Query: []apiParam{{Name: "limit", Type: "integer"}}, Response: alertsResponse{}, Listed: true, Handler: s.handleListAlerts},
+		{Method: http.MethodGet, Path: "/alerts/:id", Tag: "alerts", Summary: "Get a received alert with its summary",
+			Response: alerts.Alert{}, Handler: s.handleGetAlert},
+
+		{Method: http.MethodGet, Path: "/runbooks", Tag: "runbooks", Summary: "List the runbooks external systems can trigger",
+			Response: runbooksResponse{}, Listed: true, Handler: s.handleListRunbooks},
+		{Method: http.MethodPost, Path: "/runbooks/:name/trigger", Tag: "runbooks", Summary: "Run a runbook as a chat session",
+			Query: []apiParam{{Name: "token", Description: "runbook token, when the X-Runbook-Token header can't be set"},
+				{Name: "wait", Type: "boolean", Description: "answer with the outcome once the run ends"}},
+			Request: runbookRequest{}, Response: runbookOutcome{}, Status: http.StatusAccepted, Handler: s.handleTriggerRunbook},
+
+		{Method: http.MethodGet, Path: "/workflows", Tag: "workflows", Summary: "List the multi-step runs",
+			Query:    []apiParam{{Name: "resumable", Type: "boolean"}},
+			Response: workflowsResponse{}, Listed: true, Handler: s.handleListWorkflows},
+		{Method: http.MethodGet, Path: "/workflows/:id", Tag: "workflows", Summary: "Get the progress of a multi-step run",
+			Response: workflow.Run{}, Handler: s.handleGetWorkflow},
+
+		{Method: http.MethodGet, Path: "/approvals", Tag: "approvals", Summary: "List the changes queued for a second person's approval, most recent first",
+			Query:    []apiParam{{Name: "state", Description: "pending, approved, rejected, executed or failed"}},
+			Response: approvalsResponse{}, Listed: true, Handler: s.handleListApprovals},
+		{Method: http.MethodGet, Path: "/approvals/:id", Tag: "approvals", Summary: "Get a queued change",
+			Response: approvals.Request{}, Handler: s.handleGetApproval},
+		{Method: http.MethodPost, Path: "/approvals/:id/approve", Tag: "approvals", Summary: "Approve a queued change and make it",
+			Request: approvalDecision{}, Response: approvalOutcome{}, Handler: s.handleApproveApproval},
+		{Method: http.MethodPost, Path: "/approvals/:id/reject", Tag: "approvals", Summary: "Reject a queued change",
+			Request: approvalDecision{}, Response: approvals.Request{}, Handler: s.handleRejectApproval},
+
+		{Method: http.MethodGet, Path: "/changes", Tag: "changes", Summary: "List the changes made through the agent with their field-level diffs, most recent first",
+			Query: []apiParam{{Name: "object", Description: "name or UUID of the changed object"}, {Name: "operator"}, {Name: "tool"},
+				{Name: "since", Description: "today, a time range such as 24h or 7d, a date or an RFC 3339 time"}, {Name: "limit", Type: "integer"}},
+			Response: changesResponse{}, Listed: true, Handler: s.handleListChanges},
+		{Method: http.MethodGet, Path: "/changes/:id", Tag: "changes", Summary: "Get a change with the object before and after it",
+			Response: changeView{}, Handler: s.handleGetChange},
+		{Method: http.MethodPost, Path: "/changes/:id/rollback", Tag: "changes", Summary: "Roll a change back, restoring the object as it was before it",
+			Request: rollbackRequest{}, Response: rerunResponse{}, Handler: s.handleRollbackChange},
+
+		{Method: http.MethodPost, Path: "/plans", Tag: "plans", Summary: "Have the model plan the tool calls of a request, run once approved",
+			Request: planRequest{}, Response: planView{}, Status: http.StatusCreated, Handler: s.handleCreatePlan},
+		{Method: http.MethodGet, Path: "/plans/:id", Tag: "plans", Summary: "Get a plan with the outcome of its steps",
+			Response: planView{}, Handler: s.handleGetPlan},
+		{Method: http.MethodPost, Path: "/plans/:id/approve", Tag: "plans", Summary: "Approve a plan and run its steps in order",
+			Response: planView{}, Handler: s.handleApprovePlan},
+		{Method: http.MethodPost, Path: "/plans/:id/reject", Tag: "plans", Summary: "Reject a plan without running it",
+			Response: planView{}, Handler: s.handleRejectPlan},
+
+		{Method: http.MethodGet, Path: "/memory", Tag: "memory", Summary: "List the facts of the deployment memory",
+			Response: factsResponse{}, Listed: true, Handler: s.handleListMemory},
+		{Method: http.MethodPost, Path: "/memory", Tag: "memory", Summary: "Add a fact to the deployment memory",
+			Request: memoryRequest{}, Response: memory.Fact{}, Status: http.StatusCreated, Handler: s.handleAddMemory},
+		{Method: http.MethodPut, Path: "/memory/:id", Tag: "memory", Summary: "Change a fact of the deployment memory",
+			Request: memoryRequest{}, Response: memory.Fact{}, Handler: s.handleUpdateMemory},
+		{Method: http.MethodDelete, Path: "/memory/:id", Tag: "memory", Summary: "Remove a fact from the deployment memory",
+			Response: messageResponse{}, Handler: s.handleDeleteMemory},
+	}
+
+	// Runtime log level, and chat sessions logged at debug level whatever the level; prompt
+	// rendering is under /admin
+	if s.config.Server.DebugEndpoints {
+		routes = append(routes,
+			apiRoute{Method: http.MethodGet, Path: "/debug/log-level", Tag: "debug", Summary: "Get the log level",
+				Response: logLevelResponse{}, Handler: s.handleGetLogLevel},
+			apiRoute{Method: http.MethodPut, Path: "/debug/log-level", Tag: "debug", Summary: "Change the log level until the next change or restart",
+				Request: logLevelRequest{}, Response: logLevelResponse{}, Handler: s.handleSetLogLevel},
+			apiRoute{Method: http.MethodGet, Path: "/debug/sessions", Tag: "debug", Summary: "List the chat sessions logged at debug level",
+				Response: debugSessionsResponse{}, Handler: s.handleListDebugSessions},
+			apiRoute{Method: http.MethodPut, Path: "/debug/sessions/:id", Tag: "debug", Summary: "Log a chat session at debug level",
+				Query:    []apiParam{{Name: "for", Description: "duration, 1h by default, up to 24h"}},
+				Response: logging.DebuggedSession{}, Handler: s.handleDebugSession},
+			apiRoute{Method: http.MethodDelete, Path: "/debug/sessions/:id", Tag: "debug", Summary: "Stop logging a chat session at debug level",
+				Response: messageResponse{}, Handler: s.handleStopDebugSession},
+		)
+	}
+
+	// Avi API proxy (for direct API access)
+	return append(routes, apiRoute{Method: anyMethod, Path: "/avi/*path", Tag: "avi", Summary: "Call the Avi API; files are streamed, and any GET with download=true",
+		Query: []apiParam{{Name: "download", Type: "boolean"}}, Handler: s.handleAviProxy})
+}
+
+// registerAPI adds the routes of the JSON API to a group. Lists polled by the UI are tagged, so
+// unchanged ones are answered with 304 Not Modified.
+func (s *Server) registerAPI(group *gin.RouterGroup) {
+	list := etagMiddleware()
+	for _, route := range s.apiRoutes() {
+		handlers := []gin.HandlerFunc{route.Handler}
+		if route.Listed {
+			handlers = []gin.HandlerFunc{list, route.Handler}
+		}
+		if route.Method == anyMethod {
+			group.Any(route.Path, handlers...)
+		} else {
+			group.Handle(route.Method, route.Path, handlers...)
+		}
+	}
+}
+
+// deprecatedAPIMiddleware marks the responses of the unversioned /api paths as deprecated and
+// links the versioned path replacing them
+func deprecatedAPIMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		successor := "/api/" + apiVersion + strings.TrimPrefix(c.Request.URL.Path, "/api")
+		c.Header("Deprecation", "true")
+		c.Header("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, successor))
+		c.Next()
+	}
+}
+
+// handleOpenAPI serves the OpenAPI document of the JSON API
+func (s *Server) handleOpenAPI(c *gin.Context) {
+	c.JSON(http.StatusOK, openAPIDocument(s.apiRoutes()))
+}
@@ -0,0 +1,304 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"aviagent/internal/approvals"
+	"aviagent/internal/notify"
+	"aviagent/internal/requestid"
+	"aviagent/pkg/audit"
+	"aviagent/pkg/config"
+	"aviagent/pkg/llm"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// errUnauthenticatedApprover refuses approval decisions when anyone may name themselves the operator
+var errUnauthenticatedApprover = fmt.Errorf("approval decisions need authenticated operators: avi_users.mode passthrough, or avi_users.trusted_proxies or avi_users.proxy_secret")
+
+// approvalQueuedError reports a change held for a second person's approval instead of made
+type approvalQueuedError struct {
+	request approvals.Request
+}
+
+func (e *approvalQueuedError) Error() string {
+	return fmt.Sprintf("%s needs the approval of a second person (rule %s): queued as %s, to be approved with POST /api/v1/approvals/%s/approve",
+		e.request.Tool, e.request.Rule, e.request.ID, e.request.ID)
+}
+
+// changeAction returns the kind of change a tool call makes, as approval rules name it: delete,
+// create or update, from the method of a generic operation, otherwise the verb of the tool
+func changeAction(toolCall llm.ToolCall) string {
+	if toolCall.Function.Name == "execute_generic_operation" {
+		method, _ := llm.ArgString(toolCall.Args, "method")
+		switch strings.ToUpper(method) {
+		case http.MethodDelete:
+			return "delete"
+		case http.MethodPost:
+			return "create"
+		case http.MethodPut, http.MethodPatch:
+			return "update"
+		}
+	}
+	verb, _, _ := strings.Cut(toolCall.Function.Name, "_")
+	return verb
+}
+
+// changeTenant returns the tenant a tool call changes: its tenant argument, or the agent's tenant
+func (s *Server) changeTenant(toolCall llm.ToolCall) string {
+	if tenant, ok := llm.ArgString(toolCall.Args, "tenant"); ok && tenant != "" {
+		return tenant
+	}
+	return valueOr(s.config.Avi.Tenant, "admin")
+}
+
+// approvalRule returns the first approval rule a tool call changing configuration matches
+func (s *Server) approvalRule(toolCall llm.ToolCall) (config.ApprovalRule, bool) {
+	if s.approvals == nil || !s.tools.IsMutating(toolCall.Function.Name, toolCall.Args) {
+		return config.ApprovalRule{}, false
+	}
+	action, tenant := changeAction(toolCall), s.changeTenant(toolCall)
+	for _, rule := range s.config.Approvals.Rules {
+		if len(rule.Actions) > 0 && !containsFold(rule.Actions, action) {
+			continue
+		}
+		if len(rule.Tenants) > 0 && !containsFold(rule.Tenants, tenant) {
+			continue
+		}
+		if len(rule.Tools) > 0 && !matchesTool(rule.Tools, toolCall.Function.Name) {
+			continue
+		}
+		return rule, true
+	}
+	return config.ApprovalRule{}, false
+}
+
+// matchesTool reports whether a tool name matches one of the patterns of a rule
+func matchesTool(patterns []string, tool string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, tool); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// queueApproval holds a tool call matching an approval rule until a second person approves it,
+// whichever frontend asked for it, and tells the subscribed notification channels. It returns
+// nil for the calls that need no approval.
+func (s *Server) queueApproval(ctx context.Context, toolCall llm.ToolCall) *approvals.Request {
+	rule, ok := s.approvalRule(toolCall)
+	if !ok {
+		return nil
+	}
+	actor := audit.ActorFrom(ctx)
+	request := s.approvals.Submit(approvals.Request{
+		Tool:      toolCall.Function.Name,
+		Arguments: toolCall.Args,
+		Rule:      rule.Name,
+		Tenant:    s.changeTenant(toolCall),
+		Operator:  actor.Operator,
+		Session:   actor.Session,
+		Model:     actor.Model,
+	})
+	requestid.Logger(ctx, s.logger).Info("Change queued for approval",
+		zap.String("approval", request.ID),
+		zap.String("tool", request.Tool),
+		zap.String("rule", request.Rule),
+		zap.String("operator", request.Operator))
+
+	event := notify.Event{
+		Type:     notify.EventApprovalPending,
+		Title:    fmt.Sprintf("%s waits for approval", request.Tool),
+		Summary:  fmt.Sprintf("%s asked for %s in tenant %s, which the %s rule holds for a second person: approve or reject %s.", valueOr(request.Operator, "An operator"), request.Tool, request.Tenant, request.Rule, request.ID),
+		Severity: notify.SeverityWarning,
+		Source:   "approval/" + request.ID,
+		Time:     request.Created,
+		Data:     request,
+	}
+	// Publish logs the channels that failed; the chat doesn't wait for them
+	go func() { _ = s.notifier.Publish(context.WithoutCancel(ctx), event) }()
+	return &request
+}
+
+// approvalQueueError returns the error of a tool call held for approval, nil when it isn't
+func (s *Server) approvalQueueError(ctx context.Context, toolCall llm.ToolCall) error {
+	if request := s.queueApproval(ctx, toolCall); request != nil {
+		return &approvalQueuedError{request: *request}
+	}
+	return nil
+}
+
+// approver returns the requesting operator when they may decide queued changes, and answers the
+// request otherwise. The approver is resolved as changes are attributed, and must be
+// authenticated: a header anyone may send can't name a second person. In passthrough mode the
+// credentials must open a controller session.
+func (s *Server) approver(c *gin.Context) (string, bool) {
+	if s.approvals == nil {
+		c.JSON(http.StatusNotFound, errorResponse{Error: "two-person approval is disabled (approvals.rules)"})
+		return "", false
+	}
+	if !s.operatorAuthenticated() {
+		c.JSON(http.StatusForbidden, errorResponse{Error: errUnauthenticatedApprover.Error()})
+		return "", false
+	}
+	operator := s.operator(c)
+	if operator != "" && containsFold(s.config.Approvals.Approvers, operator) {
+		if s.aviUsers != nil && s.config.AviUsers.Mode == aviUsersPassthrough {
+			if _, err := s.aviUsers.client(c.Request.Context()); err != nil {
+				c.JSON(http.StatusForbidden, errorResponse{Error: err.Error()})
+				return "", false
+			}
+		}
+		return operator, true
+	}
+	c.JSON(http.StatusForbidden, errorResponse{Error: "only the operators listed in approvals.approvers can decide queued changes"})
+	return "", false
+}
+
+// recordDecision adds an approval decision to the audit trail
+func (s *Server) recordDecision(ctx context.Context, request approvals.Request) {
+	entry := newAuditEntry(ctx, llm.ToolCall{Function: llm.ToolCallFunction{Name: request.Tool}, Args: request.Arguments})
+	entry.Action, entry.Approval, entry.Outcome = audit.ActionApproval, request.ID, audit.OutcomeRejected
+	entry.Session, entry.Model = request.Session, request.Model
+	if request.State != approvals.StateRejected {
+		entry.Outcome = audit.OutcomeApproved
+	}
+	if _, err := s.auditLog.Record(entry); err != nil {
+		s.logger.Error("Failed to record approval decision",
+			zap.String("approval", request.ID),
+			zap.Error(err))
+	}
+}
+
+// approvalsResponse lists the queued changes
+type approvalsResponse struct {
+	Approvals []approvals.Request `json:"approvals"`
+}
+
+// approvalOutcome is an approved change with its result
+type approvalOutcome struct {
+	approvals.Request
+	Result      interface{}    `json:"result,omitempty"`
+	ChangeError *llm.ToolError `json:"tool_error,omitempty"`
+	Receipt     *audit.Receipt `json:"change_receipt,omitempty"`
+}
+
+// approvalDecision is the optional body of an approval or rejection
+type approvalDecision struct {
+	Reason string `json:"reason"`
+}
+
+// handleListApprovals lists the queued changes, ?state=pending for those waiting for an approver
+func (s *Server) handleListApprovals(c *gin.Context) {
+	if s.approvals == nil {
+		c.JSON(http.StatusNotFound, errorResponse{Error: "two-person approval is disabled (approvals.rules)"})
+		return
+	}
+	c.JSON(http.StatusOK, approvalsResponse{Approvals: s.approvals.List(c.Query("state"))})
+}
+
+// handleGetApproval returns a queued change
+func (s *Server) handleGetApproval(c *gin.Context) {
+	if s.approvals == nil {
+		c.JSON(http.StatusNotFound, errorResponse{Error: "two-person approval is disabled (approvals.rules)"})
+		return
+	}
+	request, ok := s.approvals.Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, errorResponse{Error: fmt.Sprintf("approval request %s not found", c.Param("id"))})
+		return
+	}
+	c.JSON(http.StatusOK, request)
+}
+
+// decide approves or rejects a queued change for an approver and records the decision
+func (s *Server) decide(c *gin.Context, approve bool) (context.Context, approvals.Request, bool) {
+	approver, ok := s.approver(c)
+	if !ok {
+		return nil, approvals.Request{}, false
+	}
+	var decision approvalDecision
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&decision); err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return nil, approvals.Request{}, false
+		}
+	}
+	request, err := s.approvals.Decide(c.Param("id"), approver, approve, decision.Reason)
+	if err != nil {
+		status := http.StatusConflict
+		if _, found := s.approvals.Get(c.Param("id")); !found {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, errorResponse{Error: err.Error()})
+		return nil, approvals.Request{}, false
+	}
+	ctx := s.withActor(c.Request.Context(), c, request.Session, request.Model)
+	s.recordDecision(ctx, request)
+	requestid.Logger(ctx, s.logger).Info("Approval decided",
+		zap.String("approval", request.ID),
+		zap.String("state", request.State),
+		zap.String("approver", approver))
+	return ctx, request, true
+}
+
+// handleApproveApproval approves a queued change and makes it, audited as made by the operator
+// who asked for it under the approval
+func (s *Server) handleApproveApproval(c *gin.Context) {
+	ctx, request, ok := s.decide(c, true)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+	actor := audit.ActorFrom(ctx)
+	actor.Operator = request.Operator
+	ctx = audit.WithActor(ctx, actor)
+
+	toolCall := llm.ToolCall{Type: "function", Function: llm.ToolCallFunction{Name: request.Tool}, Args: request.Arguments}
+	toolCall.InvocationID = s.sessions.RecordInvocation(request.Session, request.Operator, toolCall)
+	entry := newAuditEntry(ctx, toolCall)
+	entry.Approval = request.ID
+	s.beginChange(ctx, &entry, toolCall)
+	result, err := s.executeToolCall(ctx, toolCall)
+	receipt := s.recordChange(ctx, entry, toolCall, result, err)
+	receiptID := ""
+	if receipt != nil {
+		receiptID = receipt.ID
+	}
+	if finished, finishErr := s.approvals.Finish(request.ID, receiptID, err); finishErr == nil {
+		request = finished
+	}
+
+	outcome := approvalOutcome{Request: request, Result: result, Receipt: receipt}
+	if err != nil {
+		toolErr := llm.ToolErrorFrom(toolCall.Function.Name, err)
+		outcome.ChangeError = &toolErr
+		s.sessions.AppendNotice(request.Session, fmt.Sprintf("%s approved %s (%s), but it failed: %v", request.Approver, request.Tool, request.ID, err))
+		c.JSON(http.StatusBadGateway, outcome)
+		return
+	}
+	s.sessions.AppendNotice(request.Session, fmt.Sprintf("%s approved %s (%s), and it was made.", request.Approver, request.Tool, request.ID))
+	c.JSON(http.StatusOK, outcome)
+}
+
+// handleRejectApproval rejects a queued change, which is never made
+func (s *Server) handleRejectApproval(c *gin.Context) {
+	_, request, ok := s.decide(c, false)
+	if !ok {
+		return
+	}
+	notice := fmt.Sprintf("%s rejected %s (%s), it wasn't made", request.Approver, request.Tool, request.ID)
+	if request.Reason != "" {
+		notice += ": " + request.Reason
+	}
+	s.sessions.AppendNotice(request.Session, notice+".")
+	c.JSON(http.StatusOK, request)
+}
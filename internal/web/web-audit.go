@@ -0,0 +1,151 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"aviagent/pkg/audit"
+	"aviagent/pkg/llm"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// withActor attaches the operator, session and model of a chat request to the context
+func (s *Server) withActor(ctx context.Context, c *gin.Context, sessionID, model string) context.Context {
+	return audit.WithActor(ctx, audit.Actor{
+		Operator:   s.operator(c),
+		Session:    sessionID,
+		RemoteAddr: c.ClientIP(),
+		Model:      model,
+	})
+}
+
+// newAuditEntry starts an audit entry for a mutating tool call. It is built before the call
+// runs because tool handlers may consume arguments such as the uuid.
+func newAuditEntry(ctx context.Context, toolCall llm.ToolCall) audit.Entry {
+	actor := audit.ActorFrom(ctx)
+	entry := audit.Entry{
+		Action:     audit.ActionMutation,
+		Operator:   actor.Operator,
+		Session:    actor.Session,
+		RemoteAddr: actor.RemoteAddr,
+		Model:      actor.Model,
+		Tool:       toolCall.Function.Name,
+	}
+	for _, key := range []string{"uuid", "name", "virtual_service", "service_engine", "id", "endpoint"} {
+		if target, ok := toolCall.Args[key].(string); ok && target != "" {
+			entry.Target = target
+			break
+		}
+	}
+	if args, err := json.Marshal(toolCall.Args); err == nil {
+		entry.Arguments = string(args)
+	}
+	return entry
+}
+
+// recordAudit completes an audit entry with the outcome of the tool call and stores it. It
+// returns the recorded entry, and false when it couldn't be stored.
+func (s *Server) recordAudit(entry audit.Entry, callErr error) (audit.Entry, bool) {
+	entry.Outcome = audit.OutcomeSuccess
+	if callErr != nil {
+		entry.Outcome = audit.OutcomeFailure
+		entry.Error = callErr.Error()
+	}
+	recorded, err := s.auditLog.Record(entry)
+	if err != nil {
+		s.logger.Error("Failed to record audit entry",
+			zap.String("tool", entry.Tool),
+			zap.Error(err))
+		return recorded, false
+	}
+	return recorded, true
+}
+
+// handleAuditExport exports the audit trail for a time range as CSV or JSON. The response
+// carries the SHA-256 of the body and, when a signing key is configured, its HMAC-SHA256.
+func (s *Server) handleAuditExport(c *gin.Context) {
+	from, err := parseAuditTime(c.Query("from"), false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid from: %v", err)})
+		return
+	}
+	to, err := parseAuditTime(c.Query("to"), true)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid to: %v", err)})
+		return
+	}
+	if !from.IsZero() && !to.IsZero() && !to.After(from) {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: "to must be after from"})
+		return
+	}
+
+	format := c.DefaultQuery("format", audit.FormatCSV)
+	export, err := audit.NewExport(s.auditLog.Query(from, to), format, s.config.Audit.SigningKey)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	filename := fmt.Sprintf("aviagent-audit-%s.%s", time.Now().UTC().Format("20060102T150405Z"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Header("X-Audit-Entries", fmt.Sprintf("%d", export.Entries))
+	c.Header("X-Audit-SHA256", export.SHA256)
+	if export.Signature != "" {
+		c.Header("X-Audit-Signature", export.Signature)
+		c.Header("X-Audit-Signature-Algorithm", "HMAC-SHA256")
+	}
+
+	s.logger.Info("Audit trail exported",
+		zap.String("operator", s.operator(c)),
+		zap.String("format", format),
+		zap.Int("entries", export.Entries))
+	c.Data(http.StatusOK, export.ContentType, export.Data)
+}
+
+// auditVerifyResponse is the outcome of an audit trail check, answered with 409 Conflict when
+// the hash chain is broken
+type auditVerifyResponse struct {
+	AppendOnly bool   `json:"append_only"`
+	Records    int    `json:"records"`
+	Valid      bool   `json:"valid"`
+	Error      string `json:"error,omitempty"`
+}
+
+// handleAuditVerify checks the hash chain of the audit trail (append-only mode)
+func (s *Server) handleAuditVerify(c *gin.Context) {
+	status := auditVerifyResponse{
+		AppendOnly: s.auditLog.AppendOnly(),
+		Records:    s.auditLog.Len(),
+	}
+	if err := s.auditLog.Verify(); err != nil {
+		status.Error = err.Error()
+		c.JSON(http.StatusConflict, status)
+		return
+	}
+	status.Valid = true
+	c.JSON(http.StatusOK, status)
+}
+
+// parseAuditTime accepts RFC 3339 timestamps or plain dates; empty means unbounded. A plain
+// date used as the end of a range includes the whole day.
+func parseAuditTime(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("use RFC 3339 (2026-01-02T15:04:05Z) or a date (2026-01-02)")
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
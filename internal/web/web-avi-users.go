@@ -0,0 +1,630 @@
+package web
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+
+	"aviagent/pkg/avi"
+	"aviagent/pkg/config"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Avi user modes
+const (
+	aviUsersMap         = "map"         // operators are mapped to accounts in avi_users.accounts
+	aviUsersPassthrough = "passthrough" // the HTTP Basic credentials of the request are the operator's account
+)
+
+// aviUser is the Avi account of the operator of a request
+type aviUser struct {
+	operator string
+	username string
+	password string
+	err      error // why the operator can't reach the controller, e.g. no account is mapped
+}
+
+// account identifies the credentials of the operator's account
+func (u aviUser) account() string {
+	secret := sha256.Sum256([]byte(u.username + "\x00" + u.password))
+	return hex.EncodeToString(secret[:])
+}
+
+// operatorMismatchError refuses a passthrough request whose operator header names another user
+// than its HTTP Basic credentials
+type operatorMismatchError struct {
+	operator string
+	username string
+}
+
+func (e *operatorMismatchError) Error() string {
+	return fmt.Sprintf("operator %s doesn't match the credentials of %s", e.operator, e.username)
+}
+
+type aviUserKey struct{}
+
+// withAviUser returns a context whose controller calls use the operator's account
+func withAviUser(ctx context.Context, user aviUser) context.Context {
+	return context.WithValue(ctx, aviUserKey{}, user)
+}
+
+// userSession is the controller session of an operator
+type userSession struct {
+	secret [sha256.Size]byte // digest of the credentials the session was opened with
+	client AviClientInterface
+}
+
+// userClients is the Avi client of a server mapping operators to their own Avi accounts: each call
+// is made with a session of the account in the request context, so the controller's audit log
+// names the operator. Calls without an operator, such as scheduled reports, use the shared client
+// of the agent's account.
+type userClients struct {
+	shared   AviClientInterface
+	base     config.AviConfig // the controller settings the sessions are opened with
+	cfg      config.AviUsersConfig
+	accounts map[string]config.AviUserAccount // by lowercased operator, for map mode
+	proxy    trustedProxy                     // the only one allowed to name the operator in map mode
+	logger   *zap.Logger
+	connect  func(cfg *config.AviConfig, logger *zap.Logger) (AviClientInterface, error)
+
+	mu       sync.Mutex
+	sessions map[string]*userSession // by operator
+}
+
+// newUserClients creates the Avi client of operators, falling back to shared. The configuration
+// is validated, so its trusted proxies parse.
+func newUserClients(shared AviClientInterface, base config.AviConfig, cfg config.AviUsersConfig, logger *zap.Logger) *userClients {
+	accounts := make(map[string]config.AviUserAccount, len(cfg.Accounts))
+	for _, account := range cfg.Accounts {
+		accounts[strings.ToLower(account.Operator)] = account
+	}
+	return &userClients{
+		shared:   shared,
+		base:     base,
+		cfg:      cfg,
+		accounts: accounts,
+		proxy:    newTrustedProxy(cfg),
+		logger:   logger,
+		connect: func(cfg *config.AviConfig, logger *zap.Logger) (AviClientInterface, error) {
+			return avi.NewOfficialClient(cfg, logger)
+		},
+		sessions: make(map[string]*userSession),
+	}
+}
+
+// trustedProxy is the reverse proxy allowed to name the operator of a request, by its addresses
+// and the secret it sends, whichever avi_users configures
+type trustedProxy struct {
+	networks     []netip.Prefix
+	secret       string
+	secretHeader string
+}
+
+// newTrustedProxy returns the trusted proxy of avi_users. The configuration is validated, so its
+// trusted proxies parse.
+func newTrustedProxy(cfg config.AviUsersConfig) trustedProxy {
+	networks, _ := cfg.TrustedNetworks()
+	return trustedProxy{networks: networks, secret: cfg.ProxySecret, secretHeader: cfg.ProxySecretHeader}
+}
+
+// configured reports whether avi_users names a trusted proxy; without one nothing is trusted to
+// name the operator, and the operator header is taken as sent
+func (p trustedProxy) configured() bool {
+	return len(p.networks) > 0 || p.secret != ""
+}
+
+// trusts reports whether a request comes from the trusted proxy: from one of the trusted
+// addresses and with the proxy secret, whichever are configured
+func (p trustedProxy) trusts(c *gin.Context) bool {
+	if len(p.networks) > 0 {
+		addrPort, err := netip.ParseAddrPort(c.Request.RemoteAddr)
+		if err != nil {
+			return false
+		}
+		addr, trusted := addrPort.Addr().Unmap(), false
+		for _, network := range p.networks {
+			if network.Contains(addr) {
+				trusted = true
+				break
+			}
+		}
+		if !trusted {
+			return false
+		}
+	}
+	if p.secret != "" {
+		sent := c.GetHeader(p.secretHeader)
+		return subtle.ConstantTimeCompare([]byte(sent), []byte(p.secret)) == 1
+	}
+	return true
+}
+
+// operatorAuthenticated reports whether the operator of a request is established rather than taken
+// from a header anyone may send: by the HTTP Basic credentials in avi_users passthrough mode, or
+// by the trusted proxy of avi_users
+func (s *Server) operatorAuthenticated() bool {
+	return s.config.AviUsers.Mode == aviUsersPassthrough || newTrustedProxy(s.config.AviUsers).configured()
+}
+
+// operator returns the operator a request is attributed to: the HTTP Basic user in avi_users
+// passthrough mode, otherwise the operator header, believed only from the trusted proxy once
+// avi_users configures one. It is empty when the request names no operator it can be trusted for.
+func (s *Server) operator(c *gin.Context) string {
+	if s.config.AviUsers.Mode == aviUsersPassthrough {
+		username, _, _ := c.Request.BasicAuth()
+		return username
+	}
+	operator := c.GetHeader(s.config.Audit.OperatorHeader)
+	if proxy := newTrustedProxy(s.config.AviUsers); operator != "" && proxy.configured() && !proxy.trusts(c) {
+		return ""
+	}
+	return operator
+}
+
+// userFor returns the Avi account of the operator of a request. Requests without an operator
+// use the agent's account (ok is false), as do operators without credentials when the fallback
+// is shared. In map mode an operator named by anything but the trusted proxy is refused; in
+// passthrough mode the operator is the HTTP Basic user, and a request whose operator header names
+// someone else is refused.
+func (u *userClients) userFor(c *gin.Context, operatorHeader string) (aviUser, bool) {
+	operator := c.GetHeader(operatorHeader)
+	switch u.cfg.Mode {
+	case aviUsersPassthrough:
+		if username, password, ok := c.Request.BasicAuth(); ok && username != "" {
+			if operator != "" && !strings.EqualFold(operator, username) {
+				u.logger.Warn("Refused an operator header naming another user than the credentials",
+					zap.String("operator", operator), zap.String("username", username), zap.String("remote_addr", c.Request.RemoteAddr))
+				return aviUser{operator: username, err: &operatorMismatchError{operator: operator, username: username}}, true
+			}
+			return aviUser{operator: username, username: username, password: password}, true
+		}
+	case aviUsersMap:
+		if operator != "" && !u.proxy.trusts(c) {
+			u.logger.Warn("Refused an operator not named by the trusted proxy",
+				zap.String("operator", operator), zap.String("remote_addr", c.Request.RemoteAddr))
+			return aviUser{operator: operator, err: fmt.Errorf("operator %s wasn't named by the trusted proxy", operator)}, true
+		}
+		if account, ok := u.accounts[strings.ToLower(operator)]; ok && operator != "" {
+			username := account.Username
+			if username == "" {
+				username = operator
+			}
+			return aviUser{operator: operator, username: username, password: account.Password}, true
+		}
+	}
+	if operator == "" || u.cfg.Fallback == "shared" {
+		return aviUser{}, false
+	}
+	return aviUser{operator: operator, err: fmt.Errorf("no Avi account is mapped to operator %s", operator)}, true
+}
+
+// client returns the client of the operator in the context, opening a session on first use or
+// when the operator's credentials changed. The login runs without holding the lock, so a slow
+// controller doesn't hold up other operators, and the previous session is only replaced once the
+// new one is open.
+func (u *userClients) client(ctx context.Context) (AviClientInterface, error) {
+	user, ok := ctx.Value(aviUserKey{}).(aviUser)
+	if !ok {
+		return u.shared, nil
+	}
+	if user.err != nil {
+		return nil, user.err
+	}
+	secret := sha256.Sum256([]byte(user.username + "\x00" + user.password))
+
+	u.mu.Lock()
+	session, ok := u.sessions[user.operator]
+	u.mu.Unlock()
+	if ok && session.secret == secret {
+		return session.client, nil
+	}
+
+	cfg := u.base
+	cfg.Username, cfg.Password = user.username, user.password
+	client, err := u.connect(&cfg, u.logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to log in to the Avi controller as %s: %w", user.username, err)
+	}
+
+	u.mu.Lock()
+	current, ok := u.sessions[user.operator]
+	if ok && current.secret == secret {
+		// Another request of the operator logged in meanwhile
+		u.mu.Unlock()
+		client.Close()
+		return current.client, nil
+	}
+	u.sessions[user.operator] = &userSession{secret: secret, client: client}
+	u.mu.Unlock()
+	if ok {
+		current.client.Close()
+	}
+	u.logger.Info("Opened Avi session for operator", zap.String("operator", user.operator), zap.String("username", user.username))
+	return client, nil
+}
+
+// closeSessions logs the operators out of the controller
+func (u *userClients) closeSessions() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	var firstErr error
+	for operator, session := range u.sessions {
+		if err := session.client.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(u.sessions, operator)
+	}
+	return firstErr
+}
+
+// Close logs the operators and the agent's account out of the controller
+func (u *userClients) Close() error {
+	err := u.closeSessions()
+	if sharedErr := u.shared.Close(); err == nil {
+		err = sharedErr
+	}
+	return err
+}
+
+// aviUserMiddleware puts the Avi account of the request's operator in the request context. A
+// request naming another operator than its credentials is refused.
+func (s *Server) aviUserMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if user, ok := s.aviUsers.userFor(c, s.config.Audit.OperatorHeader); ok {
+			var mismatch *operatorMismatchError
+			if errors.As(user.err, &mismatch) {
+				c.AbortWithStatusJSON(http.StatusForbidden, errorResponse{Error: user.err.Error()})
+				return
+			}
+			c.Request = c.Request.WithContext(withAviUser(c.Request.Context(), user))
+		}
+		c.Next()
+	}
+}
+
+// The AviClientInterface methods make each call with the client of the operator in the context
+
+func (u *userClients) ListVirtualServices(ctx context.Context, params map[string]string) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.ListVirtualServices(ctx, params)
+}
+
+func (u *userClients) GetVirtualService(ctx context.Context, uuid string, params map[string]string) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetVirtualService(ctx, uuid, params)
+}
+
+func (u *userClients) CreateVirtualService(ctx context.Context, data map[string]interface{}) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.CreateVirtualService(ctx, data)
+}
+
+func (u *userClients) UpdateVirtualService(ctx context.Context, uuid string, data map[string]interface{}) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.UpdateVirtualService(ctx, uuid, data)
+}
+
+func (u *userClients) DeleteVirtualService(ctx context.Context, uuid string) error {
+	client, err := u.client(ctx)
+	if err != nil {
+		return err
+	}
+	return client.DeleteVirtualService(ctx, uuid)
+}
+
+func (u *userClients) SetVirtualServiceEnabled(ctx context.Context, uuid string, enabled bool) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.SetVirtualServiceEnabled(ctx, uuid, enabled)
+}
+
+func (u *userClients) VirtualServiceAction(ctx context.Context, uuid, action string, body map[string]interface{}) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.VirtualServiceAction(ctx, uuid, action, body)
+}
+
+func (u *userClients) ListPools(ctx context.Context, params map[string]string) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.ListPools(ctx, params)
+}
+
+func (u *userClients) GetPool(ctx context.Context, uuid string, params map[string]string) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetPool(ctx, uuid, params)
+}
+
+func (u *userClients) CreatePool(ctx context.Context, data map[string]interface{}) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.CreatePool(ctx, data)
+}
+
+func (u *userClients) UpdatePool(ctx context.Context, uuid string, data map[string]interface{}) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.UpdatePool(ctx, uuid, data)
+}
+
+func (u *userClients) DeletePool(ctx context.Context, uuid string) error {
+	client, err := u.client(ctx)
+	if err != nil {
+		return err
+	}
+	return client.DeletePool(ctx, uuid)
+}
+
+func (u *userClients) ScaleOutPool(ctx context.Context, uuid string, params map[string]interface{}) error {
+	client, err := u.client(ctx)
+	if err != nil {
+		return err
+	}
+	return client.ScaleOutPool(ctx, uuid, params)
+}
+
+func (u *userClients) ScaleInPool(ctx context.Context, uuid string, params map[string]interface{}) error {
+	client, err := u.client(ctx)
+	if err != nil {
+		return err
+	}
+	return client.ScaleInPool(ctx, uuid, params)
+}
+
+func (u *userClients) SetPoolServerEnabled(ctx context.Context, uuid, ip string, port int, enabled bool) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.SetPoolServerEnabled(ctx, uuid, ip, port, enabled)
+}
+
+func (u *userClients) ListHealthMonitors(ctx context.Context, params map[string]string) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.ListHealthMonitors(ctx, params)
+}
+
+func (u *userClients) GetHealthMonitor(ctx context.Context, uuid string, params map[string]string) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetHealthMonitor(ctx, uuid, params)
+}
+
+func (u *userClients) CreateHealthMonitor(ctx context.Context, data map[string]interface{}) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.CreateHealthMonitor(ctx, data)
+}
+
+func (u *userClients) UpdateHealthMonitor(ctx context.Context, uuid string, data map[string]interface{}) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.UpdateHealthMonitor(ctx, uuid, data)
+}
+
+func (u *userClients) DeleteHealthMonitor(ctx context.Context, uuid string) error {
+	client, err := u.client(ctx)
+	if err != nil {
+		return err
+	}
+	return client.DeleteHealthMonitor(ctx, uuid)
+}
+
+func (u *userClients) ListApplicationProfiles(ctx context.Context, params map[string]string) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.ListApplicationProfiles(ctx, params)
+}
+
+func (u *userClients) GetApplicationProfile(ctx context.Context, uuid string, params map[string]string) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetApplicationProfile(ctx, uuid, params)
+}
+
+func (u *userClients) CreateApplicationProfile(ctx context.Context, data map[string]interface{}) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.CreateApplicationProfile(ctx, data)
+}
+
+func (u *userClients) AttachApplicationProfile(ctx context.Context, vsUUID, profileUUID string) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.AttachApplicationProfile(ctx, vsUUID, profileUUID)
+}
+
+func (u *userClients) ListPersistenceProfiles(ctx context.Context, params map[string]string) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.ListPersistenceProfiles(ctx, params)
+}
+
+func (u *userClients) GetPersistenceProfile(ctx context.Context, uuid string, params map[string]string) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetPersistenceProfile(ctx, uuid, params)
+}
+
+func (u *userClients) CreatePersistenceProfile(ctx context.Context, data map[string]interface{}) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.CreatePersistenceProfile(ctx, data)
+}
+
+func (u *userClients) AttachPersistenceProfile(ctx context.Context, vsUUID, poolUUID, profileUUID string) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.AttachPersistenceProfile(ctx, vsUUID, poolUUID, profileUUID)
+}
+
+func (u *userClients) ListServiceEngines(ctx context.Context, params map[string]string) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.ListServiceEngines(ctx, params)
+}
+
+func (u *userClients) GetServiceEngine(ctx context.Context, uuid string, params map[string]string) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetServiceEngine(ctx, uuid, params)
+}
+
+func (u *userClients) ServiceEngineAction(ctx context.Context, uuid, action string, body map[string]interface{}) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.ServiceEngineAction(ctx, uuid, action, body)
+}
+
+func (u *userClients) ListVRFContexts(ctx context.Context, params map[string]string) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.ListVRFContexts(ctx, params)
+}
+
+func (u *userClients) GetVRFContext(ctx context.Context, uuid string, params map[string]string) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetVRFContext(ctx, uuid, params)
+}
+
+func (u *userClients) GetBGPPeerStatus(ctx context.Context, seUUID string, params map[string]string) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetBGPPeerStatus(ctx, seUUID, params)
+}
+
+func (u *userClients) ListBackups(ctx context.Context, params map[string]string) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.ListBackups(ctx, params)
+}
+
+func (u *userClients) TriggerBackup(ctx context.Context, configUUID string) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.TriggerBackup(ctx, configUUID)
+}
+
+func (u *userClients) ExportConfiguration(ctx context.Context, params map[string]string) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.ExportConfiguration(ctx, params)
+}
+
+func (u *userClients) GetAnalytics(ctx context.Context, resourceType, uuid string, params map[string]string) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetAnalytics(ctx, resourceType, uuid, params)
+}
+
+func (u *userClients) GetInventory(ctx context.Context, resourceType, uuid string, params map[string]string) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetInventory(ctx, resourceType, uuid, params)
+}
+
+func (u *userClients) GetHealthScore(ctx context.Context, resourceType, uuid string, params map[string]string) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetHealthScore(ctx, resourceType, uuid, params)
+}
+
+func (u *userClients) ExecuteGenericOperation(ctx context.Context, method, endpoint string, body interface{}, params map[string]string) (interface{}, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.ExecuteGenericOperation(ctx, method, endpoint, body, params)
+}
+
+func (u *userClients) Download(ctx context.Context, endpoint string, params map[string]string) (*avi.Download, error) {
+	client, err := u.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.Download(ctx, endpoint, params)
+}
@@ -0,0 +1,116 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"aviagent/pkg/audit"
+	"aviagent/pkg/avi"
+	"aviagent/pkg/llm"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// configExportParams returns the query parameters of a configuration export
+func configExportParams(fullSystem bool) map[string]string {
+	params := map[string]string{"include_name": "true"}
+	if fullSystem {
+		params["full_system"] = "true"
+	}
+	return params
+}
+
+// handleConfigExport downloads a full configuration export from the controller
+func (s *Server) handleConfigExport(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Minute)
+	defer cancel()
+
+	export, err := s.aviClient.ExportConfiguration(ctx, configExportParams(c.Query("full_system") == "true"))
+	if err != nil {
+		s.logger.Error("Configuration export failed", zap.Error(err))
+		c.JSON(http.StatusBadGateway, errorResponse{Error: err.Error()})
+		return
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse{Error: fmt.Sprintf("failed to encode export: %v", err)})
+		return
+	}
+
+	s.logger.Info("Configuration export downloaded",
+		zap.String("operator", s.operator(c)),
+		zap.Int("bytes", len(data)))
+	filename := fmt.Sprintf("avi-config-%s.json", time.Now().UTC().Format("20060102T150405Z"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "application/json", data)
+}
+
+// handleConfigApply applies an uploaded configuration (a full export or a partial object set)
+// and reports the outcome per object. Pass dry_run=true to only validate it.
+func (s *Server) handleConfigApply(c *gin.Context) {
+	var configuration map[string]interface{}
+	if err := c.ShouldBindJSON(&configuration); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid configuration JSON: %v", err)})
+		return
+	}
+	objects, err := avi.ParseConfiguration(configuration)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	dryRun := c.Query("dry_run") == "true"
+	if err := s.permissions.AllowsApply(objects, dryRun); err != nil {
+		c.JSON(http.StatusForbidden, errorResponse{Error: err.Error()})
+		return
+	}
+	toolCall := llm.ToolCall{
+		Type:     "function",
+		Function: llm.ToolCallFunction{Name: "apply_configuration"},
+		Args:     map[string]interface{}{"configuration": configuration, "dry_run": dryRun},
+	}
+	if request := s.queueApproval(s.withActor(c.Request.Context(), c, "", ""), toolCall); request != nil {
+		c.JSON(http.StatusAccepted, request)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Minute)
+	defer cancel()
+
+	report, err := s.applyConfiguration(ctx, objects, dryRun, s.operator(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+
+	if !dryRun {
+		entry := audit.Entry{
+			Action:     audit.ActionMutation,
+			Operator:   s.operator(c),
+			RemoteAddr: c.ClientIP(),
+			Tool:       "apply_configuration",
+			Target:     fmt.Sprintf("%d objects", report.Total),
+		}
+		var applyErr error
+		if report.Failed > 0 {
+			applyErr = fmt.Errorf("%d of %d objects failed to apply", report.Failed, report.Total)
+		}
+		s.recordAudit(entry, applyErr)
+	}
+
+	s.logger.Info("Configuration applied",
+		zap.Bool("dry_run", dryRun),
+		zap.Int("succeeded", report.Succeeded),
+		zap.Int("failed", report.Failed))
+
+	status := http.StatusOK
+	if report.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	c.JSON(status, report)
+}
@@ -0,0 +1,241 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"aviagent/internal/blueprint"
+	"aviagent/pkg/audit"
+	"aviagent/pkg/avi"
+	"aviagent/pkg/llm"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// blueprintSummary describes a blueprint with its parameters, without its objects
+type blueprintSummary struct {
+	Name        string                `json:"name"`
+	Title       string                `json:"title"`
+	Description string                `json:"description,omitempty"`
+	Parameters  []blueprint.Parameter `json:"parameters"`
+	Objects     []string              `json:"objects"` // object types created, in apply order
+	Source      string                `json:"source"`
+}
+
+// blueprintsResponse lists the blueprints
+type blueprintsResponse struct {
+	Blueprints []blueprintSummary `json:"blueprints"`
+}
+
+// blueprintRequest is the body of a blueprint apply
+type blueprintRequest struct {
+	Parameters map[string]interface{} `json:"parameters"`
+}
+
+// appliedBlueprint is a blueprint expanded into its objects, with the outcome of applying them
+type appliedBlueprint struct {
+	Blueprint string             `json:"blueprint"`
+	Objects   []avi.ConfigObject `json:"objects"`
+	*appliedConfiguration
+}
+
+// blueprintConflictError refuses a blueprint whose objects already exist, as applying it would
+// change them instead of creating new ones
+type blueprintConflictError struct {
+	existing []string
+}
+
+func (e *blueprintConflictError) Error() string {
+	return fmt.Sprintf("%s already exist on the controller; choose another name", strings.Join(e.existing, ", "))
+}
+
+// summarizeBlueprint describes a blueprint for listings
+func summarizeBlueprint(bp blueprint.Blueprint) blueprintSummary {
+	summary := blueprintSummary{Name: bp.Name, Title: bp.Title, Description: bp.Description, Parameters: bp.Parameters, Source: bp.Source}
+	if summary.Parameters == nil {
+		summary.Parameters = []blueprint.Parameter{}
+	}
+	var types []string
+	seen := make(map[string]bool)
+	if objects, err := avi.ParseConfiguration(bp.Objects); err == nil {
+		for _, obj := range objects {
+			if !seen[obj.Type] {
+				seen[obj.Type] = true
+				types = append(types, obj.Type)
+			}
+		}
+	}
+	summary.Objects = types
+	return summary
+}
+
+// listBlueprints describes the blueprints of the library
+func (s *Server) listBlueprints() []blueprintSummary {
+	summaries := []blueprintSummary{}
+	for _, bp := range s.blueprints.List() {
+		summaries = append(summaries, summarizeBlueprint(bp))
+	}
+	return summaries
+}
+
+// expandBlueprint returns the objects of a blueprint for the parameter values, in apply order
+func (s *Server) expandBlueprint(name string, values map[string]interface{}) (*appliedBlueprint, error) {
+	bp, err := s.blueprints.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	configuration, err := bp.Expand(values)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := avi.ParseConfiguration(configuration)
+	if err != nil {
+		return nil, fmt.Errorf("blueprint %s: %w", bp.Name, err)
+	}
+	return &appliedBlueprint{Blueprint: bp.Name, Objects: objects}, nil
+}
+
+// applyBlueprint expands a blueprint with the parameter values and creates its objects
+func (s *Server) applyBlueprint(ctx context.Context, name string, values map[string]interface{}, dryRun bool, operator string) (*appliedBlueprint, error) {
+	applied, err := s.expandBlueprint(name, values)
+	if err != nil {
+		return nil, err
+	}
+	return applied, s.createBlueprintObjects(ctx, applied, dryRun, operator)
+}
+
+// createBlueprintObjects creates the objects of an expanded blueprint in dependency order,
+// recorded as a workflow run like a configuration apply. A dry run only validates them. The
+// objects must not exist yet.
+func (s *Server) createBlueprintObjects(ctx context.Context, applied *appliedBlueprint, dryRun bool, operator string) error {
+	var existing []string
+	for _, obj := range applied.Objects {
+		found, err := s.objectExists(ctx, obj)
+		if err != nil {
+			return err
+		}
+		if found {
+			existing = append(existing, obj.Type+" "+obj.Name)
+		}
+	}
+	if len(existing) > 0 {
+		return &blueprintConflictError{existing: existing}
+	}
+
+	var err error
+	applied.appliedConfiguration, err = s.applyConfiguration(ctx, applied.Objects, dryRun, operator)
+	return err
+}
+
+// objectExists reports whether the controller has an object of the type and name
+func (s *Server) objectExists(ctx context.Context, obj avi.ConfigObject) (bool, error) {
+	raw, err := s.aviClient.ExecuteGenericOperation(ctx, http.MethodGet, "/"+obj.Type, nil, map[string]string{"name": obj.Name})
+	if err != nil {
+		return false, fmt.Errorf("failed to look up %s %s: %w", obj.Type, obj.Name, err)
+	}
+	collection, _ := raw.(map[string]interface{})
+	results, _ := collection["results"].([]interface{})
+	for _, item := range results {
+		if existing, _ := item.(map[string]interface{}); existing["name"] == obj.Name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// handleListBlueprints lists the blueprints with their parameters
+func (s *Server) handleListBlueprints(c *gin.Context) {
+	c.JSON(http.StatusOK, blueprintsResponse{Blueprints: s.listBlueprints()})
+}
+
+// handleGetBlueprint returns a blueprint with the objects it creates
+func (s *Server) handleGetBlueprint(c *gin.Context) {
+	bp, err := s.blueprints.Get(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, errorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, bp)
+}
+
+// handleApplyBlueprint creates the objects of a blueprint for the parameters of the body. Pass
+// dry_run=true to only expand and validate them.
+func (s *Server) handleApplyBlueprint(c *gin.Context) {
+	var req blueprintRequest
+	body, err := io.ReadAll(c.Request.Body)
+	if err == nil && len(strings.TrimSpace(string(body))) > 0 {
+		err = json.Unmarshal(body, &req)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid blueprint request: %v", err)})
+		return
+	}
+
+	applied, err := s.expandBlueprint(c.Param("name"), req.Parameters)
+	if errors.Is(err, blueprint.ErrNotFound) {
+		c.JSON(http.StatusNotFound, errorResponse{Error: err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	dryRun := c.Query("dry_run") == "true"
+	if err := s.permissions.AllowsApply(applied.Objects, dryRun); err != nil {
+		c.JSON(http.StatusForbidden, errorResponse{Error: err.Error()})
+		return
+	}
+	toolCall := llm.ToolCall{
+		Type:     "function",
+		Function: llm.ToolCallFunction{Name: "apply_blueprint"},
+		Args:     map[string]interface{}{"blueprint": c.Param("name"), "parameters": req.Parameters, "dry_run": dryRun},
+	}
+	if request := s.queueApproval(s.withActor(c.Request.Context(), c, "", ""), toolCall); request != nil {
+		c.JSON(http.StatusAccepted, request)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Minute)
+	defer cancel()
+	operator := s.operator(c)
+	var conflict *blueprintConflictError
+	if err := s.createBlueprintObjects(ctx, applied, dryRun, operator); errors.As(err, &conflict) {
+		c.JSON(http.StatusConflict, errorResponse{Error: err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusBadGateway, errorResponse{Error: err.Error()})
+		return
+	}
+
+	if !dryRun {
+		var applyErr error
+		if applied.Failed > 0 {
+			applyErr = fmt.Errorf("%d of %d objects failed to apply", applied.Failed, applied.Total)
+		}
+		s.recordAudit(audit.Entry{
+			Action:     audit.ActionMutation,
+			Operator:   operator,
+			RemoteAddr: c.ClientIP(),
+			Tool:       "apply_blueprint",
+			// the virtual service, applied last, names the application
+			Target: fmt.Sprintf("%s %s", applied.Blueprint, applied.Objects[len(applied.Objects)-1].Name),
+		}, applyErr)
+	}
+	s.logger.Info("Blueprint applied",
+		zap.String("blueprint", applied.Blueprint),
+		zap.Bool("dry_run", dryRun),
+		zap.Int("succeeded", applied.Succeeded),
+		zap.Int("failed", applied.Failed))
+
+	status := http.StatusOK
+	if applied.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	c.JSON(status, applied)
+}
@@ -0,0 +1,156 @@
+package web
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Safety modes reported by /api/v1/capabilities
+const (
+	safetyReadOnly = "read-only" // the Avi account's role permits no tool that changes configuration
+	safetyDirect   = "direct"    // changes are applied as soon as the model calls a write tool
+)
+
+// Capabilities describes what this deployment is configured to do, for frontends and automation
+type Capabilities struct {
+	Provider   ProviderCapabilities   `json:"provider"`
+	Controller ControllerCapabilities `json:"controller"`
+	Tools      []ToolCapability       `json:"tools"`
+	Safety     SafetyCapabilities     `json:"safety"`
+	Features   map[string]bool        `json:"features"`
+}
+
+// ProviderCapabilities is the configured LLM provider and its models
+type ProviderCapabilities struct {
+	Name         string   `json:"name"`
+	DefaultModel string   `json:"default_model"`
+	Models       []string `json:"models"`
+	JSONMode     bool     `json:"json_mode"`
+}
+
+// ControllerCapabilities is the Avi controller the agent talks to
+type ControllerCapabilities struct {
+	Host          string   `json:"host"`
+	Nodes         []string `json:"nodes,omitempty"`
+	AnalyticsHost string   `json:"analytics_host,omitempty"` // node serving metric and log queries
+	Version       string   `json:"version"`
+	Tenant        string   `json:"tenant"`
+	Sandbox       bool     `json:"sandbox"` // the bundled mock controller with sample data
+	Role          string   `json:"role,omitempty"`
+}
+
+// ToolCapability is a tool offered to the model
+type ToolCapability struct {
+	Name     string `json:"name"`
+	Mutating bool   `json:"mutating"`
+}
+
+// SafetyCapabilities describes how changes to the controller are guarded
+type SafetyCapabilities struct {
+	Mode              string `json:"mode"` // "read-only" or "direct"
+	LeastPrivilege    bool   `json:"least_privilege"`
+	RerunConfirmation bool   `json:"rerun_confirmation"` // re-running a write tool call needs ?confirm=true
+	Moderation        bool   `json:"moderation"`
+	AuditAppendOnly   bool   `json:"audit_append_only"`
+}
+
+// providerCapabilities returns the configured LLM provider and its models
+func (s *Server) providerCapabilities() ProviderCapabilities {
+	provider := ProviderCapabilities{Name: s.config.Provider}
+	switch s.config.Provider {
+	case "mistral":
+		provider.DefaultModel = s.config.Mistral.DefaultModel
+		provider.Models = s.config.Mistral.Models
+		provider.JSONMode = s.config.Mistral.JSONMode
+	default:
+		provider.DefaultModel = s.config.LLM.DefaultModel
+		provider.Models = s.config.LLM.Models
+		provider.JSONMode = s.config.LLM.JSONMode
+	}
+	return provider
+}
+
+// capabilities collects the deployment's configuration. Tools the Avi account's role doesn't
+// permit and tools the tools configuration disables are left out, as they are never offered to
+// the model.
+func (s *Server) capabilities() Capabilities {
+	controller := ControllerCapabilities{
+		Host:          s.config.Avi.Host,
+		Nodes:         s.config.Avi.Nodes,
+		AnalyticsHost: s.config.Avi.AnalyticsHost,
+		Version:       s.config.Avi.Version,
+		Tenant:        s.config.Avi.Tenant,
+		Sandbox:       s.sandbox != nil,
+	}
+	if s.permissions != nil {
+		controller.Role = s.permissions.Role
+	}
+
+	tools := []ToolCapability{}
+	mode := safetyReadOnly
+	for _, tool := range s.availableTools() {
+		mutating := s.tools.IsMutating(tool.Function.Name, nil)
+		if mutating {
+			mode = safetyDirect
+		}
+		tools = append(tools, ToolCapability{Name: tool.Function.Name, Mutating: mutating})
+	}
+
+	return Capabilities{
+		Provider:   s.providerCapabilities(),
+		Controller: controller,
+		Tools:      tools,
+		Safety: SafetyCapabilities{
+			Mode:              mode,
+			LeastPrivilege:    s.permissions != nil,
+			RerunConfirmation: true,
+			Moderation:        s.config.Moderation.Enabled || s.config.Moderation.Model != "",
+			AuditAppendOnly:   s.config.Audit.AppendOnly,
+		},
+		Features: map[string]bool{
+			"ui":                 s.uiUnavailable == "",
+			"debug_endpoints":    s.config.Server.DebugEndpoints,
+			"ticket_integration": s.config.Server.TicketURL != "",
+			"model_routing":      s.config.Routing.Enabled,
+			"intent_routing":     s.config.Routing.Intents,
+			"approvals":          s.approvals != nil,
+			"audit_persistence":  s.config.Audit.File != "",
+			"audit_signing":      s.config.Audit.SigningKey != "",
+			"insights_persisted": s.config.Insights.StateFile != "",
+			"scheduled_reports":  s.scheduler != nil,
+			"notifications":      len(s.notifier.Channels()) > 0,
+			"alert_receiver":     s.alerts != nil,
+			"drift_detection":    s.drift != nil,
+			"openai_api":         true,
+			"clock_skew_check":   s.clockSkew.Enabled(),
+			"sandbox":            s.sandbox != nil,
+		},
+	}
+}
+
+// logCapabilities logs a startup banner summarizing the deployment's capabilities
+func (s *Server) logCapabilities() {
+	caps := s.capabilities()
+	var enabled []string
+	for name, on := range caps.Features {
+		if on {
+			enabled = append(enabled, name)
+		}
+	}
+	sort.Strings(enabled)
+	s.logger.Info("Agent capabilities",
+		zap.String("provider", caps.Provider.Name),
+		zap.String("default_model", caps.Provider.DefaultModel),
+		zap.String("controller", caps.Controller.Host),
+		zap.Int("tools", len(caps.Tools)),
+		zap.String("safety_mode", caps.Safety.Mode),
+		zap.Strings("features", enabled))
+}
+
+// handleCapabilities describes the enabled provider, controller, tools, safety mode and features
+func (s *Server) handleCapabilities(c *gin.Context) {
+	c.JSON(http.StatusOK, s.capabilities())
+}
@@ -0,0 +1,395 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"aviagent/internal/changes"
+	"aviagent/internal/requestid"
+	"aviagent/pkg/audit"
+	"aviagent/pkg/avi"
+	"aviagent/pkg/llm"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// operationalTools act on an object without changing its configuration, so there is nothing a
+// rollback could restore: they are left out of the change history
+var operationalTools = map[string]bool{
+	"scale_out_virtual_service":  true,
+	"scale_in_virtual_service":   true,
+	"migrate_virtual_service":    true,
+	"switchover_virtual_service": true,
+	"reboot_service_engine":      true,
+	"service_engine_maintenance": true,
+	"trigger_backup":             true,
+}
+
+// changeLink returns the path of the object a mutating tool call changes: the object its uuid
+// argument names, the object endpoint a generic update or deletion goes to, or the object of
+// the change a rollback restores
+func (s *Server) changeLink(ctx context.Context, toolCall llm.ToolCall) string {
+	switch toolCall.Function.Name {
+	case "execute_generic_operation":
+		method, _ := llm.ArgString(toolCall.Args, "method")
+		endpoint, _ := llm.ArgString(toolCall.Args, "endpoint")
+		if strings.EqualFold(method, http.MethodPost) || strings.EqualFold(method, http.MethodGet) {
+			return ""
+		}
+		path, _, err := avi.NormalizeEndpoint(endpoint, nil)
+		if err != nil {
+			return ""
+		}
+		if objectType, uuid, ok := strings.Cut(strings.TrimPrefix(path, "/"), "/"); ok && objectType != "" && uuid != "" && !strings.Contains(uuid, "/") {
+			return avi.ProxyPrefix + path
+		}
+		return ""
+	case "rollback_change":
+		if change, err := s.rollbackTarget(ctx, toolCall.Args); err == nil {
+			return change.URL
+		}
+		return ""
+	}
+	return objectLink(toolCall.Function.Name, toolCall.Args)
+}
+
+// recordHistory adds a successful write to the change history, with the object as it was read
+// before and after the change. Writes of objects that couldn't be read are left out, as they
+// couldn't be rolled back.
+func (s *Server) recordHistory(entry audit.Entry, toolCall llm.ToolCall, result interface{}, before, after json.RawMessage, receipt string) {
+	if s.changes == nil || len(before) == 0 && len(after) == 0 || operationalTools[toolCall.Function.Name] {
+		return
+	}
+	change := changes.Change{
+		Tool:     toolCall.Function.Name,
+		Action:   changes.ActionUpdate,
+		Operator: entry.Operator,
+		Session:  entry.Session,
+		Receipt:  receipt,
+		Before:   before,
+		After:    after,
+		Time:     entry.Timestamp,
+	}
+	rollback, _ := result.(*rolledBackChange)
+	switch {
+	case changeAction(toolCall) == "delete" || rollback != nil && rollback.Restored == restoredDeleted:
+		change.Action = changes.ActionDelete
+	case len(before) == 0:
+		change.Action = changes.ActionCreate
+	}
+	if rollback != nil {
+		change.RollbackOf = rollback.Change
+	}
+	if entry.Before != nil {
+		change.URL = entry.Before.URL
+	}
+	if entry.After != nil {
+		change.URL, change.Version = entry.After.URL, entry.After.Version
+	}
+
+	var named struct {
+		Name string `json:"name"`
+	}
+	if len(after) > 0 {
+		_ = json.Unmarshal(after, &named)
+	} else {
+		_ = json.Unmarshal(before, &named)
+	}
+	change.Name = named.Name
+	s.changes.Record(change)
+}
+
+// How a rollback restored an object
+const (
+	restoredUpdated   = "updated"   // put back as it was before an update
+	restoredRecreated = "recreated" // created again after a deletion
+	restoredDeleted   = "deleted"   // deleted after a creation
+)
+
+// rolledBackChange is the result of rollback_change
+type rolledBackChange struct {
+	Change   string      `json:"rolled_back"` // ID of the change rolled back
+	Tool     string      `json:"tool"`        // the tool that made it
+	URL      string      `json:"url"`
+	Restored string      `json:"restored"`
+	Object   interface{} `json:"object,omitempty"`
+}
+
+// rollbackTarget returns the change a rollback_change call names, or the caller's last change
+// that wasn't rolled back when it names none
+func (s *Server) rollbackTarget(ctx context.Context, args map[string]interface{}) (changes.Change, error) {
+	if s.changes == nil {
+		return changes.Change{}, fmt.Errorf("the change history is unavailable")
+	}
+	if id, _ := llm.ArgString(args, "id"); id != "" {
+		change, ok := s.changes.Get(id)
+		if !ok {
+			return changes.Change{}, fmt.Errorf("change %s not found in the change history", id)
+		}
+		return change, nil
+	}
+	actor := audit.ActorFrom(ctx)
+	change, ok := s.changes.Latest(actor.Operator, actor.Session)
+	if !ok {
+		return changes.Change{}, fmt.Errorf("there is no change of yours left to roll back; only changes made through the agent are recorded")
+	}
+	return change, nil
+}
+
+// rollbackChange restores the object of a recorded change as it was before it: an updated
+// object is put back and a created one deleted, unless it changed again since, which force
+// overrides, and a deleted object is created again unless an object took its place. Refusals
+// are *rollbackRefusedError.
+func (s *Server) rollbackChange(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	change, err := s.rollbackTarget(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	if change.RolledBack != nil {
+		return nil, &rollbackRefusedError{fmt.Sprintf("change %s was already rolled back at %s", change.ID, change.RolledBack.Format(time.RFC3339))}
+	}
+	var object map[string]interface{}
+	if change.Action != changes.ActionCreate {
+		if err := json.Unmarshal(change.Before, &object); err != nil {
+			return nil, fmt.Errorf("change %s has no readable snapshot of the object: %w", change.ID, err)
+		}
+		// The controller sets the version of the restored object
+		delete(object, "_last_modified")
+	}
+
+	endpoint := strings.TrimPrefix(change.URL, avi.ProxyPrefix)
+	current, readErr := s.aviClient.ExecuteGenericOperation(ctx, http.MethodGet, endpoint, nil, nil)
+	method, restored := http.MethodPut, restoredUpdated
+	if change.Action == changes.ActionDelete {
+		if readErr == nil {
+			return nil, &rollbackRefusedError{fmt.Sprintf("%s was deleted by %s but exists again; compare it with the snapshot before restoring it by hand", change.URL, change.ID)}
+		}
+		objectType, _, _ := strings.Cut(strings.TrimPrefix(endpoint, "/"), "/")
+		method, endpoint, restored = http.MethodPost, "/"+objectType, restoredRecreated
+	} else {
+		if change.Action == changes.ActionCreate {
+			method, restored = http.MethodDelete, restoredDeleted
+		}
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read %s to roll back %s: %w", change.URL, change.ID, readErr)
+		}
+		force, _ := llm.ArgBool(args, "force")
+		if version := readObjectState(current).LastModified; !force && change.Version != "" && version != change.Version {
+			return nil, &rollbackRefusedError{fmt.Sprintf("%s changed after %s (version %s, now %s); rolling back would undo the later changes too, roll back with force to do so",
+				change.URL, change.ID, change.Version, version)}
+		}
+	}
+	if err := s.permissions.AllowsOperation(method, endpoint); err != nil {
+		return nil, err
+	}
+
+	var body interface{}
+	if object != nil {
+		body = object
+	}
+	result, err := s.aviClient.ExecuteGenericOperation(ctx, method, endpoint, body, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to roll back %s: %w", change.ID, err)
+	}
+	operator := audit.ActorFrom(ctx).Operator
+	if err := s.changes.MarkRolledBack(change.ID, operator); err != nil {
+		requestid.Logger(ctx, s.logger).Warn("Rolled back change not marked in the history",
+			zap.String("change", change.ID),
+			zap.Error(err))
+	}
+	requestid.Logger(ctx, s.logger).Info("Change rolled back",
+		zap.String("change", change.ID),
+		zap.String("object", change.URL),
+		zap.String("operator", operator))
+	return &rolledBackChange{Change: change.ID, Tool: change.Tool, URL: change.URL, Restored: restored, Object: result}, nil
+}
+
+// Changes listed when the request doesn't say how many
+const (
+	defaultChangesListed = 20  // by show_recent_changes, for the model
+	defaultChangesLimit  = 100 // by GET /api/v1/changes
+)
+
+// changeView is a change with the fields it set, changed or removed
+type changeView struct {
+	changes.Change
+	Diff []changes.FieldChange `json:"diff"`
+}
+
+// viewChange adds its diff to a change; the objects are left out for lists
+func viewChange(change changes.Change, objects bool) changeView {
+	view := changeView{Change: change, Diff: changes.Diff(change.Before, change.After)}
+	if !objects {
+		view.Before, view.After = nil, nil
+	}
+	return view
+}
+
+// changesResponse lists changes
+type changesResponse struct {
+	Changes []changeView `json:"changes"`
+}
+
+// parseSince reads when listed changes start: today, a time range ending now such as 2h or 7d,
+// a date or an RFC 3339 time. Empty is the zero time, listing every change.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case "":
+		return time.Time{}, nil
+	case "today":
+		year, month, day := now.Date()
+		return time.Date(year, month, day, 0, 0, 0, 0, now.Location()), nil
+	}
+	if since, err := parseAuditTime(strings.ToUpper(value), false); err == nil {
+		return since, nil
+	}
+	length, err := time.ParseDuration(value)
+	if days, found := strings.CutSuffix(value, "d"); found {
+		var n int
+		n, err = strconv.Atoi(days)
+		length = time.Duration(n) * 24 * time.Hour
+	}
+	if err != nil || length <= 0 {
+		return time.Time{}, fmt.Errorf("invalid since %q: use today, a time range such as 24h or 7d, a date (2026-01-02) or an RFC 3339 time", value)
+	}
+	return now.Add(-length), nil
+}
+
+// listChanges returns the changes of the history a filter selects, with their diffs
+func (s *Server) listChanges(filter changes.Filter) changesResponse {
+	response := changesResponse{Changes: []changeView{}}
+	for _, change := range s.changes.List(filter) {
+		response.Changes = append(response.Changes, viewChange(change, false))
+	}
+	return response
+}
+
+// showRecentChanges lists the changes made through the agent for the model
+func (s *Server) showRecentChanges(args map[string]interface{}, now time.Time) (interface{}, error) {
+	if s.changes == nil {
+		return nil, fmt.Errorf("the change history is unavailable")
+	}
+	filter := changes.Filter{Limit: defaultChangesListed}
+	filter.Object, _ = llm.ArgString(args, "object")
+	filter.Operator, _ = llm.ArgString(args, "operator")
+	if limit, ok := llm.ArgInt(args, "limit"); ok && limit > 0 {
+		filter.Limit = limit
+	}
+	since, _ := llm.ArgString(args, "since")
+	var err error
+	if filter.Since, err = parseSince(since, now); err != nil {
+		return nil, err
+	}
+	return s.listChanges(filter), nil
+}
+
+// handleListChanges lists the changes made through the agent with their diffs, most recent
+// first
+func (s *Server) handleListChanges(c *gin.Context) {
+	if s.changes == nil {
+		c.JSON(http.StatusNotFound, errorResponse{Error: "the change history is unavailable"})
+		return
+	}
+	since, err := parseSince(c.Query("since"), time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	filter := changes.Filter{
+		Object:   c.Query("object"),
+		Operator: c.Query("operator"),
+		Tool:     c.Query("tool"),
+		Since:    since,
+		Limit:    queryInt(c, "limit"),
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultChangesLimit
+	}
+	c.JSON(http.StatusOK, s.listChanges(filter))
+}
+
+// handleGetChange returns a change with the object before and after it
+func (s *Server) handleGetChange(c *gin.Context) {
+	if s.changes == nil {
+		c.JSON(http.StatusNotFound, errorResponse{Error: "the change history is unavailable"})
+		return
+	}
+	change, ok := s.changes.Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, errorResponse{Error: fmt.Sprintf("change %s not found", c.Param("id"))})
+		return
+	}
+	c.JSON(http.StatusOK, viewChange(change, true))
+}
+
+// rollbackRefusedError is a rollback refused because the object moved on since the change
+type rollbackRefusedError struct {
+	reason string
+}
+
+func (e *rollbackRefusedError) Error() string {
+	return e.reason
+}
+
+// rollbackRequest is the optional body of POST /api/v1/changes/:id/rollback
+type rollbackRequest struct {
+	Session string `json:"session"` // chat session the rollback is recorded in
+	Force   bool   `json:"force"`   // roll back even when the object changed again since
+}
+
+// handleRollbackChange rolls a recorded change back, under the approval rules, auditing and
+// receipts of a tool call
+func (s *Server) handleRollbackChange(c *gin.Context) {
+	var request rollbackRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+	}
+	if s.changes == nil {
+		c.JSON(http.StatusNotFound, errorResponse{Error: "the change history is unavailable"})
+		return
+	}
+	if _, ok := s.changes.Get(c.Param("id")); !ok {
+		c.JSON(http.StatusNotFound, errorResponse{Error: fmt.Sprintf("change %s not found", c.Param("id"))})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+	defer cancel()
+	ctx = s.withActor(ctx, c, request.Session, "")
+	args := map[string]interface{}{"id": c.Param("id")}
+	if request.Force {
+		args["force"] = true
+	}
+
+	toolCall, result, receipt, err := s.rerunInvocation(ctx, ToolInvocation{Tool: "rollback_change", Args: args})
+	var queued *approvalQueuedError
+	if errors.As(err, &queued) {
+		c.JSON(http.StatusAccepted, queued.request)
+		return
+	}
+	if err != nil {
+		status := http.StatusBadGateway
+		var refused *rollbackRefusedError
+		if errors.As(err, &refused) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, rerunErrorResponse{
+			errorResponse: errorResponse{Error: err.Error()},
+			ToolError:     llm.ToolErrorFrom(toolCall.Function.Name, err),
+			InvocationID:  toolCall.InvocationID,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, rerunResponse{InvocationID: toolCall.InvocationID, Tool: toolCall.Function.Name, Result: result, Receipt: receipt})
+}
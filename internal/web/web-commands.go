@@ -0,0 +1,220 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"aviagent/internal/requestid"
+	"aviagent/pkg/audit"
+	"aviagent/pkg/llm"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// slashCommand is a chat command that runs a read-only tool directly, without the model, for
+// operators who want a fast and predictable answer
+type slashCommand struct {
+	name        string // the command without its slash, with its verb: "pool get"
+	usage       string // how its argument is written, shown by /help
+	description string
+	tool        string
+	argument    string // tool argument the command's argument is passed as, "" when it takes none
+	required    bool   // whether the command's argument must be given
+	lookup      string // list tool resolving a name to the UUID the tool takes
+}
+
+// slashCommands are the chat commands, in the order /help lists them
+var slashCommands = []slashCommand{
+	{name: "vs list", usage: "[name]", description: "list the virtual services", tool: "list_virtual_services", argument: "name"},
+	{name: "vs get", usage: "<name|uuid>", description: "show a virtual service", tool: "get_virtual_service", argument: "uuid", required: true, lookup: "list_virtual_services"},
+	{name: "vs health", usage: "[name]", description: "health scores of the virtual services", tool: "get_virtual_service_health", argument: "name"},
+	{name: "pool list", usage: "[name]", description: "list the pools", tool: "list_pools", argument: "name"},
+	{name: "pool get", usage: "<name|uuid>", description: "show a pool", tool: "get_pool", argument: "uuid", required: true, lookup: "list_pools"},
+	{name: "pool health", usage: "[name]", description: "health scores of the pools", tool: "get_pool_health", argument: "name"},
+	{name: "se list", usage: "[name]", description: "list the service engines", tool: "list_service_engines", argument: "name"},
+	{name: "se get", usage: "<name|uuid>", description: "show a service engine", tool: "get_service_engine", argument: "uuid", required: true, lookup: "list_service_engines"},
+	{name: "se health", usage: "[name]", description: "health scores of the service engines", tool: "get_service_engine_health", argument: "name"},
+	{name: "health", description: "health scores of every virtual service", tool: "get_virtual_service_health"},
+	{name: "controller", description: "controller version and cluster state", tool: "get_controller_info"},
+	{name: "help", description: "this help"},
+}
+
+// parseSlashCommand reads a chat message as a slash command. Messages that don't start with the
+// name of a command, "/vs" or "/pool" say, are questions for the model; a known name with an
+// unknown verb is returned as /help.
+func parseSlashCommand(message string) (slashCommand, string, bool) {
+	message = strings.TrimSpace(message)
+	if !strings.HasPrefix(message, "/") {
+		return slashCommand{}, "", false
+	}
+	words := strings.Fields(message[1:])
+	if len(words) == 0 {
+		return slashCommand{}, "", false
+	}
+	known := false
+	for _, command := range slashCommands {
+		name := strings.Fields(command.name)
+		if !strings.EqualFold(name[0], words[0]) {
+			continue
+		}
+		known = true
+		if len(name) == 1 {
+			return command, strings.Join(words[1:], " "), true
+		}
+		if len(words) > 1 && strings.EqualFold(name[1], words[1]) {
+			return command, strings.Join(words[2:], " "), true
+		}
+	}
+	if known {
+		return slashCommands[len(slashCommands)-1], "", true
+	}
+	return slashCommand{}, "", false
+}
+
+// slashCommandHelp lists the chat commands
+func slashCommandHelp() string {
+	var help strings.Builder
+	help.WriteString("Commands, run without the model:")
+	for _, command := range slashCommands {
+		usage := "/" + command.name
+		if command.usage != "" {
+			usage += " " + command.usage
+		}
+		fmt.Fprintf(&help, "\n- `%s`: %s", usage, command.description)
+	}
+	return help.String()
+}
+
+// runSlashCommand answers a slash command by running its tool, the way chat.Run runs
+// a tool call of the model. The result is reduced as for the model but never summarized, so no
+// model is asked; the answer is moderated as any other.
+func (s *Server) runSlashCommand(ctx context.Context, command slashCommand, argument string) *llm.LLMResponse {
+	response := &llm.LLMResponse{}
+	if command.tool == "" {
+		response.Message = slashCommandHelp()
+		return response
+	}
+	if command.required && argument == "" {
+		response.Message = fmt.Sprintf("Usage: `/%s %s`", command.name, command.usage)
+		return response
+	}
+
+	args := map[string]interface{}{}
+	if argument != "" {
+		args[command.argument] = argument
+		if command.lookup != "" {
+			// A name no object has is passed on as a UUID
+			args[command.argument], _ = s.lookupUUID(ctx, command.lookup, argument)
+		}
+	}
+	actor := audit.ActorFrom(ctx)
+	toolCall := llm.ToolCall{Type: "function", Function: llm.ToolCallFunction{Name: command.tool}, Args: args}
+	toolCall.InvocationID = s.sessions.RecordInvocation(actor.Session, actor.Operator, toolCall)
+	response.ToolCalls = []llm.ToolCall{toolCall}
+
+	result, err := s.executeToolCall(ctx, toolCall)
+	if err != nil {
+		requestid.Logger(ctx, s.logger).Error("Slash command failed",
+			zap.String("command", command.name),
+			zap.Error(err))
+		toolErr := llm.ToolErrorFrom(toolCall.Function.Name, err)
+		response.ToolErrors = append(response.ToolErrors, toolErr)
+		response.Message = fmt.Sprintf("Tool error (%s): %s", toolErr.Tool, toolErr.Message)
+		toolResult := newToolResult(toolCall, llm.ToolResultError)
+		toolResult.Error = toolErr.Message
+		response.ToolResults = append(response.ToolResults, toolResult)
+		return response
+	}
+
+	response.Message = fmt.Sprintf("`/%s` ran %s.", strings.TrimSpace(command.name+" "+argument), toolCall.Function.Name)
+	response.ToolResults = append(response.ToolResults, newToolResult(toolCall, llm.ToolResultOK))
+	if result != nil {
+		response.Message += fmt.Sprintf("\n\n%s\n```json\n%s\n```", toolResultMarker, s.reduceResult(toolCall, result))
+	}
+	moderated := s.moderator.Moderate(ctx, response.Message)
+	response.Message = moderated.Text
+	if notice := moderated.Notice(); notice != "" {
+		response.Notices = append(response.Notices, notice)
+	}
+	if _, blocks := extractToolResults(response.Message); result != nil && len(blocks) == 1 {
+		response.ToolResults[0].Data = blocks[0].data
+	}
+	return response
+}
+
+// lookupUUID resolves the name of an object to its UUID with a list tool. A name that matches
+// no object is returned as it is, with false.
+func (s *Server) lookupUUID(ctx context.Context, listTool, name string) (string, bool) {
+	result, err := s.executeToolCall(ctx, llm.ToolCall{Function: llm.ToolCallFunction{Name: listTool},
+		Args: map[string]interface{}{"name": name, "fields": "name,uuid"}})
+	if err != nil {
+		return name, false
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return name, false
+	}
+	var list struct {
+		Results []struct {
+			UUID string `json:"uuid"`
+		} `json:"results"`
+	}
+	if json.Unmarshal(data, &list) != nil || len(list.Results) == 0 || list.Results[0].UUID == "" {
+		return name, false
+	}
+	return list.Results[0].UUID, true
+}
+
+// handleChatCommand answers a slash command sent to the chat API, recording it in the session
+// as any other exchange
+func (s *Server) handleChatCommand(c *gin.Context, request chatRequest, command slashCommand, argument string) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	model := valueOr(request.Model, s.config.LLM.DefaultModel)
+	session := s.sessions.GetOrCreate(request.Session, model)
+	ctx = s.withActor(ctx, c, session.ID, model)
+	response := s.runSlashCommand(ctx, command, argument)
+	s.sessions.AppendExchange(session.ID, model, request.Message, response.Message, toolCallNames(response.ToolCalls))
+
+	response.Message, _ = extractToolResults(response.Message)
+	c.JSON(http.StatusOK, chatResponse{
+		LLMResponse:  response,
+		Session:      session.ID,
+		SessionUsage: s.sessions.Usage(session.ID),
+	})
+}
+
+// handleHTMXChatCommand answers a slash command sent from the web UI
+func (s *Server) handleHTMXChatCommand(c *gin.Context, message, model, sessionID string, command slashCommand, argument string) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+	defer cancel()
+
+	model = valueOr(model, s.config.LLM.DefaultModel)
+	session := s.sessions.GetOrCreate(sessionID, model)
+	ctx = s.withActor(ctx, c, session.ID, model)
+	response := s.runSlashCommand(ctx, command, argument)
+	s.sessions.AppendExchange(session.ID, model, message, response.Message, toolCallNames(response.ToolCalls))
+
+	c.HTML(http.StatusOK, "chat.html", gin.H{
+		"userMessage":      message,
+		"assistantMessage": response.Message,
+		"toolCalls":        response.ToolCalls,
+		"notices":          response.Notices,
+		"timestamp":        time.Now().Format("15:04:05"),
+		"sessionUsage":     s.sessions.Usage(session.ID),
+	})
+}
+
+// valueOr returns a value, or the fallback when it is empty
+func valueOr(value, fallback string) string {
+	if value != "" {
+		return value
+	}
+	return fallback
+}
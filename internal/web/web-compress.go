@@ -0,0 +1,248 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"aviagent/pkg/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// compressibleTypes are the media types worth compressing; images and fonts are compressed already
+var compressibleTypes = map[string]bool{
+	"application/javascript": true,
+	"application/json":       true,
+	"application/xml":        true,
+	"image/svg+xml":          true,
+	"text/css":               true,
+	"text/csv":               true,
+	"text/html":              true,
+	"text/javascript":        true,
+	"text/plain":             true,
+	"text/xml":               true,
+}
+
+// compressionMiddleware gzips text responses for clients accepting it. Responses are buffered
+// until they reach the minimum size, so small ones are sent as is. Server-sent events and
+// partial content are never compressed.
+func compressionMiddleware(cfg config.CompressionConfig) gin.HandlerFunc {
+	writers := sync.Pool{New: func() interface{} {
+		gz, _ := gzip.NewWriterLevel(nil, cfg.Level)
+		return gz
+	}}
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodHead || !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+		w := &compressWriter{ResponseWriter: c.Writer, minSize: cfg.MinSize, pool: &writers}
+		c.Writer = w
+		defer func() {
+			w.finish()
+			c.Writer = w.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header accepts gzip
+func acceptsGzip(header string) bool {
+	for _, coding := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(coding), ";")
+		if strings.EqualFold(strings.TrimSpace(name), "gzip") {
+			return strings.ReplaceAll(strings.TrimSpace(params), " ", "") != "q=0"
+		}
+	}
+	return false
+}
+
+// compressWriter gzips a response once it is known to be compressible and large enough
+type compressWriter struct {
+	gin.ResponseWriter
+	minSize int
+	pool    *sync.Pool
+
+	decided bool   // whether the response is compressible was checked
+	plain   bool   // the response is sent as is
+	buf     []byte // start of a compressible response smaller than minSize
+	gz      *gzip.Writer
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.decided = true
+		w.plain = !w.compressible()
+	}
+	switch {
+	case w.plain:
+		return w.ResponseWriter.Write(data)
+	case w.gz != nil:
+		return w.gz.Write(data)
+	}
+	w.buf = append(w.buf, data...)
+	if len(w.buf) >= w.minSize {
+		if err := w.startGzip(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Written reports a buffered response as written, so handlers don't write a second one
+func (w *compressWriter) Written() bool {
+	return w.ResponseWriter.Written() || len(w.buf) > 0
+}
+
+// Flush sends what was written so far, compressed when the response is compressible
+func (w *compressWriter) Flush() {
+	if len(w.buf) > 0 && w.gz == nil {
+		if err := w.startGzip(); err != nil {
+			return
+		}
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// compressible reports whether the response about to be written should be compressed
+func (w *compressWriter) compressible() bool {
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" || w.Status() == http.StatusPartialContent {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil || !compressibleTypes[mediaType] {
+		return false
+	}
+	// Caches must keep compressed and plain copies apart, even of a response sent as is
+	header.Add("Vary", "Accept-Encoding")
+	return true
+}
+
+// startGzip switches the response to gzip and compresses the buffered start
+func (w *compressWriter) startGzip() error {
+	header := w.Header()
+	header.Del("Content-Length")
+	header.Set("Content-Encoding", "gzip")
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		// The compressed bytes differ from those the strong tag identifies
+		header.Set("ETag", "W/"+etag)
+	}
+	w.gz = w.pool.Get().(*gzip.Writer)
+	w.gz.Reset(w.ResponseWriter)
+	buf := w.buf
+	w.buf = nil
+	_, err := w.gz.Write(buf)
+	return err
+}
+
+// finish completes the response: the end of the gzip stream, or a small response as is
+func (w *compressWriter) finish() {
+	if w.gz != nil {
+		w.gz.Close()
+		w.pool.Put(w.gz)
+		w.gz = nil
+		return
+	}
+	if len(w.buf) > 0 {
+		buf := w.buf
+		w.buf = nil
+		w.ResponseWriter.Write(buf)
+	}
+}
+
+// staticCacheMiddleware lets browsers cache the UI's static assets for maxAge seconds; after
+// that, or with a maxAge of 0, they revalidate them with If-Modified-Since or If-None-Match
+func staticCacheMiddleware(maxAge int) gin.HandlerFunc {
+	value := "no-cache"
+	if maxAge > 0 {
+		value = fmt.Sprintf("public, max-age=%d", maxAge)
+	}
+	return func(c *gin.Context) {
+		if strings.HasPrefix(c.Request.URL.Path, "/static/") {
+			c.Header("Cache-Control", value)
+		}
+		c.Next()
+	}
+}
+
+// etagMiddleware tags successful GET responses with a hash of their body and answers 304 Not
+// Modified when the client already has it, so polling a list that didn't change costs a header.
+// The response is buffered; use it for JSON lists, not streams.
+func etagMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+		w := &bufferWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		status := w.Status()
+		if status != http.StatusOK {
+			w.ResponseWriter.Write(w.body.Bytes())
+			return
+		}
+		sum := sha256.Sum256(w.body.Bytes())
+		etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+		header := w.Header()
+		header.Set("ETag", etag)
+		if header.Get("Cache-Control") == "" {
+			header.Set("Cache-Control", "no-cache")
+		}
+		if etagMatches(c.GetHeader("If-None-Match"), etag) {
+			header.Del("Content-Length")
+			header.Del("Content-Type")
+			w.ResponseWriter.WriteHeader(http.StatusNotModified)
+			w.ResponseWriter.WriteHeaderNow()
+			return
+		}
+		w.ResponseWriter.Write(w.body.Bytes())
+	}
+}
+
+// etagMatches reports whether an If-None-Match header lists the tag, comparing weakly as
+// compression may have made it a weak tag
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferWriter holds a response body back until the handler is done
+type bufferWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bufferWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *bufferWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *bufferWriter) Written() bool {
+	return w.ResponseWriter.Written() || w.body.Len() > 0
+}
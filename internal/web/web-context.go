@@ -0,0 +1,156 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"aviagent/internal/requestid"
+	"aviagent/pkg/llm"
+
+	"go.uber.org/zap"
+)
+
+// summaryPrompt instructs the model condensing the older part of a conversation
+const summaryPrompt = `You condense the earlier part of a conversation between an operator and an assistant managing a VMware Avi load balancer.
+You are given the summary so far, if any, followed by the messages to add to it.
+Write a single summary of at most 250 words in plain text: what the operator asked, what was found and what was changed, keeping object names, UUIDs, tenants and the outcome of every change.
+Leave out greetings and repetition, and don't invent anything the messages don't say.`
+
+// toolResultMarker starts the tool results added to an answer
+const toolResultMarker = "API Result:"
+
+// HistorySummary is the summary of the leading messages of a session, sent to the model in their
+// place once the history outgrows the context budget
+type HistorySummary struct {
+	Text     string `json:"text"`
+	Messages int    `json:"messages"` // number of leading messages it covers
+}
+
+// Summary returns the summary kept for a session
+func (s *SessionStore) Summary(id string) (HistorySummary, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.sessions[id]
+	if !ok || session.Summary == nil {
+		return HistorySummary{}, false
+	}
+	return *session.Summary, true
+}
+
+// SetSummary keeps the summary of a session's leading messages, so later turns only add the
+// messages that follow them
+func (s *SessionStore) SetSummary(id string, summary HistorySummary) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if session, ok := s.sessions[id]; ok {
+		session.Summary = &summary
+	}
+}
+
+// approxTokens estimates the tokens of a text at four characters per token, which is close
+// enough for English and JSON with the tokenizers of the supported models
+func approxTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// historyTokens estimates the tokens of a list of messages
+func historyTokens(history []llm.ChatMessage) int {
+	tokens := 0
+	for _, msg := range history {
+		tokens += approxTokens(msg.Content) + 4 // role and message framing
+	}
+	return tokens
+}
+
+// fitHistory keeps the history sent to the model within context.max_tokens. The most recent
+// messages are sent as they are, as many as fit in three quarters of the budget and at least
+// context.keep_recent, along with the latest tool results when they are older; the rest is
+// replaced by a summary the model writes, kept with the session so later turns only extend it.
+// The kept messages are sent even when they alone exceed the budget. The system prompt, session
+// notes and deployment memory are added to what this returns, so they are never summarized away.
+func (s *Server) fitHistory(ctx context.Context, sessionID, model string, history []llm.ChatMessage) []llm.ChatMessage {
+	budget := s.config.Context.MaxTokens
+	if budget <= 0 || historyTokens(history) <= budget {
+		return history
+	}
+
+	split := recentSplit(history, budget*3/4, s.config.Context.KeepRecent)
+	pinned, pin := latestToolResult(history[:split])
+	if pin && !hasToolResult(history[split:]) {
+		split = recentSplit(history, budget*3/4-historyTokens([]llm.ChatMessage{pinned}), s.config.Context.KeepRecent)
+	} else {
+		pin = false
+	}
+	if split == 0 {
+		return history
+	}
+
+	fitted := []llm.ChatMessage{{Role: "system", Content: s.summarize(ctx, sessionID, model, history[:split])}}
+	if pin {
+		fitted = append(fitted, pinned)
+	}
+	return append(fitted, history[split:]...)
+}
+
+// recentSplit returns the index of the first of the recent messages fitting in allowance tokens,
+// keeping at least keep of them
+func recentSplit(history []llm.ChatMessage, allowance, keep int) int {
+	split := len(history)
+	for split > 0 && (len(history)-split < keep || historyTokens(history[split-1:]) <= allowance) {
+		split--
+	}
+	return split
+}
+
+// summarize returns the system message standing for the older messages of a session: their
+// summary, extended with the messages the kept summary doesn't cover yet. When the model fails,
+// the messages are left out and the message says so.
+func (s *Server) summarize(ctx context.Context, sessionID, model string, older []llm.ChatMessage) string {
+	summary, ok := s.sessions.Summary(sessionID)
+	if !ok || summary.Messages > len(older) {
+		summary = HistorySummary{}
+	}
+	if summary.Messages < len(older) {
+		var prompt strings.Builder
+		if summary.Text != "" {
+			fmt.Fprintf(&prompt, "Summary so far:\n%s\n\n", summary.Text)
+		}
+		prompt.WriteString("Messages to add:")
+		for _, msg := range older[summary.Messages:] {
+			fmt.Fprintf(&prompt, "\n\n%s: %s", msg.Role, msg.Content)
+		}
+
+		reply, err := s.llmClient.Complete(ctx, model, summaryPrompt, prompt.String())
+		if err != nil || strings.TrimSpace(reply) == "" {
+			requestid.Logger(ctx, s.logger).Warn("Failed to summarize the conversation history; older messages are left out",
+				zap.String("session", sessionID),
+				zap.Int("messages", len(older)),
+				zap.Error(err))
+			return fmt.Sprintf("The %d earlier messages of this conversation were left out to stay within the context budget. Ask the operator when they matter.", len(older))
+		}
+		summary = HistorySummary{Text: strings.TrimSpace(reply), Messages: len(older)}
+		if sessionID != "" {
+			s.sessions.SetSummary(sessionID, summary)
+		}
+	}
+	return fmt.Sprintf("Summary of the %d earlier messages of this conversation:\n%s", summary.Messages, summary.Text)
+}
+
+// latestToolResult returns the latest answer carrying tool results
+func latestToolResult(history []llm.ChatMessage) (llm.ChatMessage, bool) {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == "assistant" && strings.Contains(history[i].Content, toolResultMarker) {
+			return history[i], true
+		}
+	}
+	return llm.ChatMessage{}, false
+}
+
+// hasToolResult reports whether an answer of the history carries tool results
+func hasToolResult(history []llm.ChatMessage) bool {
+	_, ok := latestToolResult(history)
+	return ok
+}
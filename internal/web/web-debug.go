@@ -0,0 +1,206 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"aviagent/internal/logging"
+	"aviagent/internal/requestid"
+	"aviagent/pkg/llm"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// promptDebugRequest is the body of POST /admin/debug/prompt
+type promptDebugRequest struct {
+	Query   string `json:"query" binding:"required"`
+	Model   string `json:"model"`
+	Session string `json:"session"`
+	Seed    *int   `json:"seed"`
+}
+
+// promptDebugResponse is the request a chat query would send to the provider
+type promptDebugResponse struct {
+	Provider      string          `json:"provider"`
+	Model         string          `json:"model"`
+	Session       string          `json:"session"`
+	Route         *llm.ModelRoute `json:"route"`
+	HistoryLength int             `json:"history_length"`
+	ToolCount     int             `json:"tool_count"`
+	Request       interface{}     `json:"request"` // in the provider's format
+}
+
+// handlePromptDebug renders the request a chat query would send to the LLM provider (system
+// prompt, session history, query and tool definitions) without sending it
+func (s *Server) handlePromptDebug(c *gin.Context) {
+	var request promptDebugRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	// Resolve the model the same way a chat request would
+	var route *llm.ModelRoute
+	request.Model, route = s.routeModel(request.Query, request.Model)
+	if request.Model == "" {
+		request.Model = s.config.LLM.DefaultModel
+	}
+
+	history := s.sessions.History(request.Session)
+	tools, convertedHistory := s.providerInputs(s.config.Provider, history)
+	ctx, _ := s.withSeed(c.Request.Context(), request.Session, request.Seed)
+	rendered, err := s.llmClient.RenderPrompt(ctx, request.Query, request.Model, tools, convertedHistory)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+
+	s.logger.Info("Rendered prompt for debugging",
+		zap.String("operator", s.operator(c)),
+		zap.String("session", request.Session),
+		zap.String("model", request.Model))
+
+	c.JSON(http.StatusOK, promptDebugResponse{
+		Provider:      s.config.Provider,
+		Model:         request.Model,
+		Session:       request.Session,
+		Route:         route,
+		HistoryLength: len(history),
+		ToolCount:     len(s.availableTools()),
+		Request:       rendered,
+	})
+}
+
+// Debugging a chat session lasts an hour unless asked otherwise, a day at most
+const (
+	defaultSessionDebug = time.Hour
+	maxSessionDebug     = 24 * time.Hour
+)
+
+// SetLogLevels lets the debug endpoints change the log level of a logger built by logging.New
+// at runtime. Servers created by a configuration reload take them over.
+func (s *Server) SetLogLevels(levels *logging.Levels) {
+	s.logLevels = levels
+}
+
+// previousLogLevels returns the log levels of the server a reload replaces
+func previousLogLevels(previous *Server) *logging.Levels {
+	if previous == nil {
+		return nil
+	}
+	return previous.logLevels
+}
+
+// elevateSession logs the request in ctx at debug level when its chat session is being
+// debugged. Call the returned function once the request is answered.
+func (s *Server) elevateSession(ctx context.Context, sessionID string) func() {
+	id := requestid.From(ctx)
+	if s.logLevels == nil || sessionID == "" || id == "" || !s.logLevels.SessionDebugged(sessionID, time.Now()) {
+		return func() {}
+	}
+	return s.logLevels.Elevate(id)
+}
+
+// logLevelsAvailable answers 404 when the logger's level can't be changed at runtime
+func (s *Server) logLevelsAvailable(c *gin.Context) bool {
+	if s.logLevels == nil {
+		c.JSON(http.StatusNotFound, errorResponse{Error: "the log level can't be changed at runtime"})
+		return false
+	}
+	return true
+}
+
+// logLevelRequest is the body of PUT /api/v1/debug/log-level
+type logLevelRequest struct {
+	Level string `json:"level" binding:"required"` // debug, info, warn or error
+}
+
+// logLevelResponse is the log level in effect and the configured one
+type logLevelResponse struct {
+	Level      string `json:"level"`
+	Configured string `json:"configured"` // log.level
+}
+
+// debugSessionsResponse lists the chat sessions being debugged
+type debugSessionsResponse struct {
+	Sessions []logging.DebuggedSession `json:"sessions"`
+}
+
+// handleGetLogLevel returns the current log level
+func (s *Server) handleGetLogLevel(c *gin.Context) {
+	if !s.logLevelsAvailable(c) {
+		return
+	}
+	c.JSON(http.StatusOK, logLevelResponse{Level: s.logLevels.Level().String(), Configured: s.config.Log.Level})
+}
+
+// handleSetLogLevel changes the log level until the next change, configuration reload or restart
+func (s *Server) handleSetLogLevel(c *gin.Context) {
+	if !s.logLevelsAvailable(c) {
+		return
+	}
+	var request logLevelRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	level, err := zapcore.ParseLevel(request.Level)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("unsupported log level %q. Use 'debug', 'info', 'warn' or 'error'", request.Level)})
+		return
+	}
+	previous := s.logLevels.Level()
+	s.logLevels.SetLevel(level)
+	s.logger.Warn("Log level changed",
+		zap.String("operator", s.operator(c)),
+		zap.String("from", previous.String()),
+		zap.String("to", level.String()))
+	c.JSON(http.StatusOK, logLevelResponse{Level: level.String(), Configured: s.config.Log.Level})
+}
+
+// handleListDebugSessions lists the chat sessions being debugged
+func (s *Server) handleListDebugSessions(c *gin.Context) {
+	if !s.logLevelsAvailable(c) {
+		return
+	}
+	c.JSON(http.StatusOK, debugSessionsResponse{Sessions: s.logLevels.DebuggedSessions(time.Now())})
+}
+
+// handleDebugSession logs the requests of a chat session at debug level for ?for= (1h by default)
+func (s *Server) handleDebugSession(c *gin.Context) {
+	if !s.logLevelsAvailable(c) {
+		return
+	}
+	duration := defaultSessionDebug
+	if value := c.Query("for"); value != "" {
+		var err error
+		if duration, err = time.ParseDuration(value); err != nil || duration <= 0 || duration > maxSessionDebug {
+			c.JSON(http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid duration %q, use e.g. 30m or 2h, up to 24h", value)})
+			return
+		}
+	}
+	id := c.Param("id")
+	until := time.Now().Add(duration)
+	s.logLevels.DebugSession(id, until)
+	s.logger.Warn("Debug logging enabled for a chat session",
+		zap.String("operator", s.operator(c)),
+		zap.String("session", id),
+		zap.Time("until", until))
+	c.JSON(http.StatusOK, logging.DebuggedSession{ID: id, Until: until})
+}
+
+// handleStopDebugSession stops debugging a chat session
+func (s *Server) handleStopDebugSession(c *gin.Context) {
+	if !s.logLevelsAvailable(c) {
+		return
+	}
+	if !s.logLevels.StopDebugSession(c.Param("id")) {
+		c.JSON(http.StatusNotFound, errorResponse{Error: "session is not being debugged"})
+		return
+	}
+	c.JSON(http.StatusOK, messageResponse{Message: "session debugging stopped"})
+}
@@ -0,0 +1,61 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"aviagent/internal/drift"
+
+	"github.com/gin-gonic/gin"
+)
+
+// driftTimeout bounds a drift check run on request
+const driftTimeout = 2 * time.Minute
+
+// checkDrift compares the declared configuration with the controller now. The report lists the
+// objects that aren't in sync, or the objects named object when given.
+func (s *Server) checkDrift(ctx context.Context, object string) (*drift.Report, error) {
+	if s.drift == nil {
+		return nil, errDriftDisabled
+	}
+	ctx, cancel := context.WithTimeout(ctx, driftTimeout)
+	defer cancel()
+	report, err := s.drift.Check(ctx)
+	if err != nil {
+		return nil, err
+	}
+	filtered := *report
+	filtered.Results = []drift.ObjectDrift{}
+	for _, result := range report.Results {
+		if object != "" && strings.EqualFold(result.Name, object) || object == "" && result.State != drift.StateInSync {
+			filtered.Results = append(filtered.Results, result)
+		}
+	}
+	return &filtered, nil
+}
+
+// errDriftDisabled is returned when drift detection isn't configured
+var errDriftDisabled = errors.New("drift detection is disabled (drift.enabled)")
+
+// handleDrift returns the report of the last drift check, checking first when there was none
+// yet or refresh is set
+func (s *Server) handleDrift(c *gin.Context) {
+	if s.drift == nil {
+		c.JSON(http.StatusNotFound, errorResponse{Error: errDriftDisabled.Error()})
+		return
+	}
+	report := s.drift.Last()
+	if report == nil || c.Query("refresh") == "true" {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), driftTimeout)
+		defer cancel()
+		var err error
+		if report, err = s.drift.Check(ctx); err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse{Error: err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,57 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"aviagent/internal/requestid"
+	"aviagent/pkg/avi"
+	"aviagent/pkg/llm"
+
+	"go.uber.org/zap"
+)
+
+// errorAdvicePrompt instructs the model explaining a failed tool call
+const errorAdvicePrompt = `You explain why a VMware Avi load balancer API call an assistant made failed, for the operator who asked for it.
+You get the tool, the arguments it was called with and the error, with the controller's status, message and the field it names when the controller answered it.
+In at most 60 words of plain text, say what is wrong with the call and how to fix it, naming the argument or field and the value it needs, e.g. "pool_ref must be a full /api/pool/<uuid> URL". When the assistant could fix it with another tool call, such as looking an object up by name, end by offering that as a question.
+Don't invent fields, objects or values the call and the error don't show.`
+
+// failedCall is what the model explaining a failed tool call reads
+type failedCall struct {
+	Tool      string                 `json:"tool"`
+	Arguments map[string]interface{} `json:"arguments"`
+	Error     string                 `json:"error"`
+	Invalid   []llm.ArgumentError    `json:"invalid_arguments,omitempty"`
+	Status    int                    `json:"controller_status,omitempty"`
+	Code      string                 `json:"code,omitempty"`
+	Field     string                 `json:"field,omitempty"`
+}
+
+// adviseToolError has the model explain a failed tool call from its error and the arguments it
+// was called with, and suggest a fix, with results.explain_errors. It returns an empty string
+// when the model has nothing to add or fails, leaving the error as it is.
+func (s *Server) adviseToolError(ctx context.Context, model string, toolCall llm.ToolCall, toolErr llm.ToolError, err error) string {
+	if !s.config.Results.ExplainErrors || s.llmClient == nil || toolErr.Panic {
+		return ""
+	}
+	call := failedCall{Tool: toolCall.Function.Name, Arguments: toolCall.Args, Error: toolErr.Message,
+		Invalid: toolErr.Invalid, Code: toolErr.Code, Field: toolErr.Field}
+	if aviErr, ok := avi.AsAviError(err); ok {
+		call.Status = aviErr.Status
+		call.Error = aviErr.Message
+	}
+	prompt, marshalErr := json.Marshal(call)
+	if marshalErr != nil {
+		return ""
+	}
+	advice, adviceErr := s.llmClient.Complete(ctx, model, errorAdvicePrompt, string(prompt))
+	if adviceErr != nil {
+		requestid.Logger(ctx, s.logger).Warn("Failed to explain a tool error",
+			zap.String("tool", toolCall.Function.Name),
+			zap.Error(adviceErr))
+		return ""
+	}
+	return strings.TrimSpace(advice)
+}
@@ -0,0 +1,133 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"aviagent/internal/extract"
+	"aviagent/pkg/avi"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// extractRequest is the body of POST /api/v1/extract
+type extractRequest struct {
+	Text  string `json:"text" binding:"required"`
+	Model string `json:"model"` // model extracting the entities, the provider's default model when empty
+}
+
+// extractResponse is the entities of a text, the inventory objects they refer to and the tool
+// calls they suggest
+type extractResponse struct {
+	Entities    *extract.Entities    `json:"entities"`
+	Matches     []avi.InventoryMatch `json:"matches"`
+	Suggestions []extractSuggestion  `json:"suggestions"`
+	Warning     string               `json:"warning,omitempty"`
+}
+
+// extractSuggestion is a tool call pre-filled from a text, to be reviewed before it is run
+type extractSuggestion struct {
+	Tool   string                 `json:"tool"`
+	Args   map[string]interface{} `json:"args"`
+	Reason string                 `json:"reason"`
+}
+
+// handleExtract extracts entities from pasted text, such as a ticket or an email, and maps them
+// to inventory objects
+func (s *Server) handleExtract(c *gin.Context) {
+	var req extractRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Text) == "" {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: "text is required"})
+		return
+	}
+	model := req.Model
+	if model == "" {
+		model = s.defaultModel()
+	}
+
+	ctx := c.Request.Context()
+	response := extractResponse{Entities: extract.Extract(ctx, s.llmClient, model, req.Text), Matches: []avi.InventoryMatch{}, Suggestions: []extractSuggestion{}}
+	inv, err := s.simulations.inventory(ctx, s.aviClient, false)
+	if err != nil {
+		s.logger.Warn("Extracted entities not matched to the inventory", zap.Error(err))
+		response.Warning = "the inventory could not be read, entities are not matched to objects: " + err.Error()
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	names := make([]string, len(response.Entities.Objects))
+	for i, obj := range response.Entities.Objects {
+		names[i] = obj.Name
+	}
+	response.Matches = inv.Match(req.Text, names, response.Entities.Addresses)
+	response.Suggestions = suggestTools(response.Entities, response.Matches)
+	c.JSON(http.StatusOK, response)
+}
+
+// suggestTools pre-fills the tool calls of the intent for the matched objects. Changes that take
+// capacity away are suggested as simulations first.
+func suggestTools(entities *extract.Entities, matches []avi.InventoryMatch) []extractSuggestion {
+	suggestions := []extractSuggestion{}
+	seen := map[string]bool{}
+	suggest := func(tool string, args map[string]interface{}, reason string) {
+		// A virtual service can match by name and by VIP; fmt prints maps in key order
+		if key := tool + fmt.Sprint(args); !seen[key] {
+			seen[key] = true
+			suggestions = append(suggestions, extractSuggestion{Tool: tool, Args: args, Reason: reason})
+		}
+	}
+	port := ""
+	if len(entities.Ports) == 1 {
+		port = ":" + strconv.Itoa(entities.Ports[0])
+	}
+
+	for _, match := range matches {
+		switch {
+		case match.Type == "pool" && match.MatchedBy == "server":
+			server := match.Value + port
+			if strings.Contains(match.Value, ":") && port != "" {
+				server = "[" + match.Value + "]" + port
+			}
+			switch entities.Intent {
+			case extract.IntentDisable:
+				suggest("disable_pool_server", map[string]interface{}{"uuid": match.UUID, "server": server}, "disable server "+server+" of pool "+match.Name)
+			case extract.IntentEnable:
+				suggest("enable_pool_server", map[string]interface{}{"uuid": match.UUID, "server": server}, "enable server "+server+" of pool "+match.Name)
+			case extract.IntentRemoveServer:
+				suggest("simulate_change", map[string]interface{}{"action": avi.SimulateRemoveServer, "target": match.Name, "server": server}, "see what removing "+server+" from pool "+match.Name+" leaves")
+			case extract.IntentInvestigate:
+				suggest("get_pool_health", map[string]interface{}{"uuid": match.UUID}, "health of pool "+match.Name+", which has server "+match.Value)
+			}
+
+		case match.Type == "virtual_service":
+			args := map[string]interface{}{"uuid": match.UUID}
+			switch entities.Intent {
+			case extract.IntentInvestigate:
+				suggest("explain_vs_health", args, "incident summary of "+match.Name)
+			case extract.IntentCertificate:
+				suggest("security_audit", args, "certificate and TLS audit of "+match.Name)
+			case extract.IntentDisable:
+				suggest("simulate_change", map[string]interface{}{"action": avi.SimulateDisableVS, "target": match.Name}, "see what disabling "+match.Name+" affects")
+			case extract.IntentEnable:
+				suggest("enable_virtual_service", args, "enable "+match.Name)
+			}
+
+		case match.Type == "pool" && match.MatchedBy == "name":
+			switch entities.Intent {
+			case extract.IntentInvestigate:
+				suggest("get_pool_health", map[string]interface{}{"uuid": match.UUID}, "health of pool "+match.Name)
+			case extract.IntentDelete:
+				suggest("simulate_change", map[string]interface{}{"action": avi.SimulateDeletePool, "target": match.Name}, "see what references pool "+match.Name)
+			}
+
+		case match.Type == "health_monitor" && entities.Intent == extract.IntentDelete:
+			suggest("simulate_change", map[string]interface{}{"action": avi.SimulateDeleteHealthMonitor, "target": match.Name}, "see which pools health monitor "+match.Name+" probes")
+
+		case match.Type == "service_engine" && entities.Intent == extract.IntentDisable:
+			suggest("simulate_change", map[string]interface{}{"action": avi.SimulateDisableSE, "target": match.Name}, "see where the virtual services of "+match.Name+" go")
+		}
+	}
+	return suggestions
+}
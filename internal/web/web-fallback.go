@@ -0,0 +1,189 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"aviagent/internal/requestid"
+	"aviagent/pkg/config"
+	"aviagent/pkg/llm"
+	"aviagent/pkg/mistral"
+
+	"go.uber.org/zap"
+)
+
+// modelAttempt is a model of the fallback chain with the client of its provider
+type modelAttempt struct {
+	provider string
+	model    string
+	client   LLMClient
+}
+
+// newFallbackClients creates the clients of the providers that fallback models use besides the
+// configured one, taken over from the previous server when their configuration didn't change
+func newFallbackClients(cfg *config.Config, logger *zap.Logger, previous *Server) (map[string]LLMClient, error) {
+	clients := map[string]LLMClient{}
+	for _, fallback := range cfg.Fallback.Models {
+		provider := fallback.Provider
+		if provider == "" || provider == cfg.Provider || clients[provider] != nil {
+			continue
+		}
+		if previous != nil && previous.fallbackClients[provider] != nil &&
+			reflect.DeepEqual(previous.config.LLM, cfg.LLM) && reflect.DeepEqual(previous.config.Mistral, cfg.Mistral) {
+			clients[provider] = previous.fallbackClients[provider]
+			continue
+		}
+		switch provider {
+		case "ollama":
+			client, err := llm.NewClient(&cfg.LLM, logger)
+			if err != nil {
+				return nil, fmt.Errorf("failed to initialize the Ollama fallback client: %w", err)
+			}
+			clients[provider] = client
+		case "mistral":
+			client, err := mistral.NewClient(&cfg.Mistral, cfg.Mistral.APIKey, logger)
+			if err != nil {
+				return nil, fmt.Errorf("failed to initialize the Mistral AI fallback client: %w", err)
+			}
+			clients[provider] = client
+		}
+		logger.Info("Initialized fallback LLM client", zap.String("provider", provider))
+	}
+	return clients, nil
+}
+
+// fallbackChain returns the model of a question followed by the fallback models
+func (s *Server) fallbackChain(model string) []modelAttempt {
+	chain := []modelAttempt{{provider: s.config.Provider, model: model, client: s.llmClient}}
+	for _, fallback := range s.config.Fallback.Models {
+		provider, client := s.config.Provider, s.llmClient
+		if fallback.Provider != "" && fallback.Provider != s.config.Provider {
+			provider, client = fallback.Provider, s.fallbackClients[fallback.Provider]
+		}
+		if client == nil || (provider == s.config.Provider && fallback.Model == model) {
+			continue
+		}
+		chain = append(chain, modelAttempt{provider: provider, model: fallback.Model, client: client})
+	}
+	return chain
+}
+
+// validateModel checks that the model of a question is available. When the provider can't be
+// asked and fallback models are configured, the question goes ahead so they can answer it.
+func (s *Server) validateModel(ctx context.Context, model string) (bool, error) {
+	valid, err := s.llmClient.ValidateModel(ctx, model)
+	if err != nil && len(s.fallbackChain(model)) > 1 {
+		requestid.Logger(ctx, s.logger).Warn("Failed to validate model, leaving it to the fallback models",
+			zap.String("model", model),
+			zap.Error(err))
+		return true, nil
+	}
+	return valid, err
+}
+
+// queryModels sends a question to its model and, when the model fails, doesn't answer within
+// fallback.timeout or answers with malformed tool calls, to each fallback model in turn. The
+// answer names the model that gave it and the models that failed before. A question cancelled
+// by its caller isn't passed on.
+func (s *Server) queryModels(ctx context.Context, message, model string, history []llm.ChatMessage) (*llm.LLMResponse, error) {
+	logger := requestid.Logger(ctx, s.logger)
+	chain := s.fallbackChain(model)
+	var failures []llm.ModelFailure
+	var lastErr error
+	for i, attempt := range chain {
+		last := i == len(chain)-1
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if s.config.Fallback.Timeout > 0 && !last {
+			attemptCtx, cancel = context.WithTimeout(ctx, time.Duration(s.config.Fallback.Timeout)*time.Second)
+		}
+		tools, convertedHistory := s.providerInputs(attempt.provider, history)
+		response, err := attempt.client.ProcessNaturalLanguageQuery(attemptCtx, message, attempt.model, tools, convertedHistory)
+		if err != nil && ctx.Err() == nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("no answer within %ds", s.config.Fallback.Timeout)
+		}
+		cancel()
+		if err == nil && !last {
+			// The last model's tool calls are run anyway, and their errors shown to it
+			if reason := malformedToolCalls(response.ToolCalls, s.availableTools()); reason != "" {
+				err = errors.New(reason)
+			}
+		}
+
+		if err == nil {
+			if response.Model == "" {
+				response.Model = attempt.model
+			}
+			response.Provider = attempt.provider
+			response.Fallbacks = failures
+			if len(failures) > 0 {
+				failed := make([]string, len(failures))
+				for j, failure := range failures {
+					failed[j] = failure.Model
+				}
+				response.Notices = append(response.Notices, fmt.Sprintf("Answered by the fallback model %s after %s failed",
+					attempt.model, strings.Join(failed, ", ")))
+				logger.Info("Question answered by a fallback model",
+					zap.String("model", attempt.model),
+					zap.String("provider", attempt.provider),
+					zap.Int("failed", len(failures)))
+			}
+			return response, nil
+		}
+		lastErr = providerError(attempt.provider, err)
+		if ctx.Err() != nil {
+			break
+		}
+		failures = append(failures, llm.ModelFailure{Provider: attempt.provider, Model: attempt.model, Reason: err.Error()})
+		if !last {
+			logger.Warn("Model failed, asking the next fallback model",
+				zap.String("model", attempt.model),
+				zap.String("provider", attempt.provider),
+				zap.String("next", chain[i+1].model),
+				zap.Error(err))
+		}
+	}
+	return nil, lastErr
+}
+
+// providerError names the provider in the error of a failed question
+func providerError(provider string, err error) error {
+	switch provider {
+	case "ollama":
+		return fmt.Errorf("Ollama LLM processing failed: %w", err)
+	case "mistral":
+		return fmt.Errorf("Mistral AI processing failed: %w", err)
+	}
+	return fmt.Errorf("LLM processing failed: %w", err)
+}
+
+// malformedToolCalls describes the first tool call of an answer that names a tool that wasn't
+// offered or whose arguments aren't a JSON object, or returns "" when they are all well-formed
+func malformedToolCalls(calls []llm.ToolCall, offered []llm.Tool) string {
+	names := make(map[string]bool, len(offered))
+	for _, tool := range offered {
+		names[tool.Function.Name] = true
+	}
+	for _, call := range calls {
+		if !names[call.Function.Name] {
+			return fmt.Sprintf("malformed tool call: unknown tool %q", call.Function.Name)
+		}
+		arguments := strings.TrimSpace(call.Function.Arguments)
+		if call.Args == nil && arguments != "" && llm.ParseToolArguments(arguments) == nil {
+			return fmt.Sprintf("malformed tool call: the arguments of %s aren't a JSON object", call.Function.Name)
+		}
+	}
+	return ""
+}
+
+// answeringModel is the model the usage of an answer is priced for: the fallback model that gave
+// it, or the model of the question
+func answeringModel(response *llm.LLMResponse, model string) string {
+	if len(response.Fallbacks) > 0 {
+		return response.Model
+	}
+	return model
+}
@@ -0,0 +1,96 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"aviagent/pkg/avi"
+	"aviagent/pkg/config"
+	"aviagent/pkg/llm"
+)
+
+// checkGenericOperation applies tools.generic_operation to a generic operation the model asked
+// for: its method and endpoint must be allowed and its body small enough. The endpoint is
+// normalized as the client would, so an allowed prefix can't be bypassed with /api or a query
+// string. The Avi account's role is checked too, as the tool isn't tied to one object type.
+func (s *Server) checkGenericOperation(method, endpoint string, body interface{}) error {
+	limits := s.config.Tools.GenericOperation
+	methods := limits.Methods
+	if len(methods) == 0 {
+		methods = []string{"GET"}
+	}
+	if !containsFold(methods, method) {
+		return fmt.Errorf("method %s is not allowed for generic operations in this deployment (allowed: %s); use a specific tool instead",
+			strings.ToUpper(method), strings.Join(methods, ", "))
+	}
+
+	path, _, err := avi.NormalizeEndpoint(endpoint, nil)
+	if err != nil {
+		return err
+	}
+	if len(limits.Endpoints) > 0 && !endpointAllowed(limits.Endpoints, path) {
+		return fmt.Errorf("endpoint %s is not allowed for generic operations in this deployment (allowed: %s)",
+			path, strings.Join(limits.Endpoints, ", "))
+	}
+
+	if body != nil && limits.MaxBodyBytes > 0 {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("body can't be encoded as JSON: %w", err)
+		}
+		if len(data) > limits.MaxBodyBytes {
+			return fmt.Errorf("body of %d bytes exceeds the %d bytes allowed for generic operations", len(data), limits.MaxBodyBytes)
+		}
+	}
+	return s.permissions.AllowsOperation(method, path)
+}
+
+// runCustomTool calls the API endpoint of a custom tool of the tools configuration with the
+// arguments the model passed. The Avi account's role is checked as for a generic operation, but
+// not tools.generic_operation: the operator who defined the tool chose its method and endpoint.
+func (s *Server) runCustomTool(ctx context.Context, tool config.CustomToolConfig, args map[string]interface{}) (interface{}, error) {
+	method, endpoint, body, params, err := llm.CustomToolRequest(tool, args)
+	if err != nil {
+		return nil, err
+	}
+	path, _, err := avi.NormalizeEndpoint(endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.permissions.AllowsOperation(method, path); err != nil {
+		return nil, err
+	}
+	var payload interface{}
+	if body != nil {
+		payload = body
+	}
+	return s.aviClient.ExecuteGenericOperation(ctx, method, endpoint, payload, params)
+}
+
+// endpointAllowed reports whether a normalized endpoint is one of the allowed prefixes or below
+// one, compared segment by segment so /pool doesn't allow /poolgroup
+func endpointAllowed(allowed []string, path string) bool {
+	for _, prefix := range allowed {
+		prefix, _, err := avi.NormalizeEndpoint(prefix, nil)
+		if err != nil {
+			continue
+		}
+		prefix = strings.TrimSuffix(prefix, "/")
+		if prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold reports whether a list holds a value, compared without case
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
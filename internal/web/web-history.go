@@ -0,0 +1,81 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+
+	"aviagent/pkg/llm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// toolCallNames returns the names of the tools called for an answer
+func toolCallNames(calls []llm.ToolCall) []string {
+	names := make([]string, 0, len(calls))
+	for _, call := range calls {
+		names = append(names, call.Function.Name)
+	}
+	return names
+}
+
+// queryInt reads an integer query parameter, 0 when it is missing or invalid
+func queryInt(c *gin.Context, key string) int {
+	value, _ := strconv.Atoi(c.Query(key))
+	return value
+}
+
+// handleChatHistory returns a page of sessions, or with ?session= a page of that session's
+// messages (?before= loads older messages)
+func (s *Server) handleChatHistory(c *gin.Context) {
+	if id := c.Query("session"); id != "" {
+		page, ok := s.sessions.Messages(id, queryInt(c, "before"), queryInt(c, "limit"))
+		if !ok {
+			c.JSON(http.StatusNotFound, errorResponse{Error: "session not found"})
+			return
+		}
+		c.JSON(http.StatusOK, page)
+		return
+	}
+	c.JSON(http.StatusOK, s.sessions.Sessions(queryInt(c, "offset"), queryInt(c, "limit")))
+}
+
+// handleHTMXHistory renders a page of the session list; the last item lazy-loads the next page
+// when it scrolls into view
+func (s *Server) handleHTMXHistory(c *gin.Context) {
+	c.HTML(http.StatusOK, "history.html", s.sessions.Sessions(queryInt(c, "offset"), queryInt(c, "limit")))
+}
+
+// handleHTMXSessionMessages renders a page of a session's messages; the first item lazy-loads
+// the older messages when it scrolls into view
+func (s *Server) handleHTMXSessionMessages(c *gin.Context) {
+	page, ok := s.sessions.Messages(c.Param("session"), queryInt(c, "before"), queryInt(c, "limit"))
+	if !ok {
+		c.HTML(http.StatusNotFound, "chat.html", gin.H{"error": "Session not found"})
+		return
+	}
+	c.HTML(http.StatusOK, "history-messages.html", page)
+}
+
+// chatStatusResponse is the provider status of the question a session is waiting for
+type chatStatusResponse struct {
+	Session string `json:"session"`
+	Status  string `json:"status"` // such as a rate limit retry, empty when there is none
+}
+
+// handleClearHistory clears the history of ?session=, or of every session
+func (s *Server) handleClearHistory(c *gin.Context) {
+	s.sessions.Clear(c.Query("session"))
+	c.JSON(http.StatusOK, messageResponse{Message: "History cleared"})
+}
+
+// handleChatStatus returns the provider status of the question ?session= is waiting for, such as
+// a rate limit retry, empty when there is none
+func (s *Server) handleChatStatus(c *gin.Context) {
+	id := c.Query("session")
+	c.JSON(http.StatusOK, chatStatusResponse{Session: id, Status: s.sessions.Status(id)})
+}
+
+// handleHTMXChatStatus renders the provider status shown under the loading indicator
+func (s *Server) handleHTMXChatStatus(c *gin.Context) {
+	c.String(http.StatusOK, s.sessions.Status(c.Query("session")))
+}
@@ -0,0 +1,152 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"aviagent/internal/ansible"
+	"aviagent/pkg/avi"
+	"aviagent/pkg/llm"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ansiblePlaybook is the result of generate_ansible_playbook: the playbook, with a link to
+// download it when it was written from existing objects
+type ansiblePlaybook struct {
+	*ansible.Playbook
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+// iacObjects returns the objects a tool call writes as infrastructure as code: a proposed
+// change given in export layout as configuration, or existing objects read from the controller,
+// with the objects they refer to unless include_references is false
+func (s *Server) iacObjects(ctx context.Context, args map[string]interface{}) ([]avi.ConfigObject, error) {
+	if configuration, ok := args["configuration"]; ok && configuration != nil {
+		return avi.ParseConfiguration(configuration)
+	}
+	refs, err := iacRefs(args)
+	if err != nil {
+		return nil, err
+	}
+	follow, ok := llm.ArgBool(args, "include_references")
+	return avi.CollectObjects(ctx, s.aviClient, refs, follow || !ok)
+}
+
+// iacRefs returns the existing objects a tool call names in its objects argument
+func iacRefs(args map[string]interface{}) ([]avi.ObjectRef, error) {
+	list, _ := args["objects"].([]interface{})
+	refs := make([]avi.ObjectRef, 0, len(list))
+	for _, item := range list {
+		object, _ := item.(map[string]interface{})
+		objType, _ := llm.ArgString(object, "type")
+		name, _ := llm.ArgString(object, "name")
+		if objType == "" || name == "" {
+			return nil, fmt.Errorf("every object needs a type and a name, e.g. {\"type\": \"virtualservice\", \"name\": \"web\"}")
+		}
+		refs = append(refs, avi.ObjectRef{Type: objType, Name: name})
+	}
+	if len(refs) == 0 {
+		return nil, fmt.Errorf("objects or configuration parameter required")
+	}
+	return refs, nil
+}
+
+// generateAnsiblePlaybook runs generate_ansible_playbook. A playbook of existing objects links
+// to GET /ansible/playbook, which writes it again from the controller as a file.
+func (s *Server) generateAnsiblePlaybook(ctx context.Context, args map[string]interface{}) (*ansiblePlaybook, error) {
+	objects, err := s.iacObjects(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	playbook, err := ansible.Generate(objects)
+	if err != nil {
+		return nil, err
+	}
+	result := &ansiblePlaybook{Playbook: playbook}
+	if configuration, ok := args["configuration"]; !ok || configuration == nil {
+		refs, _ := iacRefs(args)
+		names := make([]string, len(refs))
+		for i, ref := range refs {
+			names[i] = ref.Type + "/" + ref.Name
+		}
+		query := url.Values{"objects": {strings.Join(names, ",")}}
+		if follow, ok := llm.ArgBool(args, "include_references"); ok && !follow {
+			query.Set("include_references", "false")
+		}
+		result.DownloadURL = "/api/" + apiVersion + "/ansible/playbook?" + query.Encode()
+	}
+	return result, nil
+}
+
+// handleAnsiblePlaybook downloads existing objects, named type/name in the comma-separated
+// objects parameter, as a playbook, with the objects they refer to unless include_references
+// is false
+func (s *Server) handleAnsiblePlaybook(c *gin.Context) {
+	var refs []avi.ObjectRef
+	for _, item := range strings.Split(c.Query("objects"), ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		objType, name, _ := strings.Cut(item, "/")
+		if objType == "" || name == "" {
+			c.JSON(http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid object %q: name it type/name, e.g. virtualservice/web", item)})
+			return
+		}
+		refs = append(refs, avi.ObjectRef{Type: objType, Name: name})
+	}
+	if len(refs) == 0 {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: "objects parameter required, e.g. objects=virtualservice/web"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Minute)
+	defer cancel()
+	objects, err := avi.CollectObjects(ctx, s.aviClient, refs, c.Query("include_references") != "false")
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, avi.ErrObjectNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, errorResponse{Error: err.Error()})
+		return
+	}
+	s.sendPlaybook(c, objects)
+}
+
+// handleProposedPlaybook downloads a proposed change, given in export layout as apply takes it,
+// as a playbook
+func (s *Server) handleProposedPlaybook(c *gin.Context) {
+	var configuration map[string]interface{}
+	if err := c.ShouldBindJSON(&configuration); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid configuration JSON: %v", err)})
+		return
+	}
+	objects, err := avi.ParseConfiguration(configuration)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	s.sendPlaybook(c, objects)
+}
+
+// sendPlaybook answers objects as a playbook file
+func (s *Server) sendPlaybook(c *gin.Context, objects []avi.ConfigObject) {
+	playbook, err := ansible.Generate(objects)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	s.logger.Info("Ansible playbook downloaded",
+		zap.String("operator", s.operator(c)),
+		zap.Int("tasks", len(playbook.Tasks)))
+	filename := fmt.Sprintf("avi-playbook-%s.yml", time.Now().UTC().Format("20060102T150405Z"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "application/yaml", []byte(playbook.YAML))
+}
@@ -0,0 +1,74 @@
+package web
+
+import (
+	"net/http"
+	"time"
+
+	"aviagent/internal/insights"
+
+	"github.com/gin-gonic/gin"
+)
+
+// insightsResponse lists the raised insights for an operator
+type insightsResponse struct {
+	Operator string            `json:"operator"`
+	Insights []insights.Status `json:"insights"`
+}
+
+// insightStateResponse is whether an operator acknowledged an insight
+type insightStateResponse struct {
+	InsightID    string `json:"insight_id"`
+	Acknowledged bool   `json:"acknowledged"`
+}
+
+// insightSnoozeResponse is when an insight snoozed by an operator shows again
+type insightSnoozeResponse struct {
+	InsightID    string `json:"insight_id"`
+	SnoozedUntil string `json:"snoozed_until"`
+}
+
+// handleListInsights lists the raised insights with the requesting operator's acknowledgment state
+func (s *Server) handleListInsights(c *gin.Context) {
+	operator := s.operator(c)
+	c.JSON(http.StatusOK, insightsResponse{
+		Operator: operator,
+		Insights: s.insights.List(operator, time.Now()),
+	})
+}
+
+// handleAcknowledgeInsight stops an insight from being repeated to the requesting operator
+func (s *Server) handleAcknowledgeInsight(c *gin.Context) {
+	id := c.Param("id")
+	if err := s.insights.Acknowledge(s.operator(c), id, time.Now()); err != nil {
+		c.JSON(http.StatusNotFound, errorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, insightStateResponse{InsightID: id, Acknowledged: true})
+}
+
+// handleSnoozeInsight hides an insight from the requesting operator for ?for= (e.g. 4h or 7d, default 24h)
+func (s *Server) handleSnoozeInsight(c *gin.Context) {
+	id := c.Param("id")
+	duration, err := insights.ParseSnooze(c.DefaultQuery("for", "24h"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	now := time.Now()
+	until := now.Add(duration)
+	if err := s.insights.Snooze(s.operator(c), id, until, now); err != nil {
+		c.JSON(http.StatusNotFound, errorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, insightSnoozeResponse{InsightID: id, SnoozedUntil: until.Format(time.RFC3339)})
+}
+
+// handleResetInsight clears the requesting operator's acknowledgment or snooze of an insight
+func (s *Server) handleResetInsight(c *gin.Context) {
+	id := c.Param("id")
+	if err := s.insights.Reset(s.operator(c), id); err != nil {
+		c.JSON(http.StatusNotFound, errorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, insightStateResponse{InsightID: id, Acknowledged: false})
+}
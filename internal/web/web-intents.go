@@ -0,0 +1,119 @@
+package web
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"aviagent/internal/requestid"
+
+	"go.uber.org/zap"
+)
+
+// readIntent is an obvious read query answered by a slash command instead of the model
+type readIntent struct {
+	pattern *regexp.Regexp // matched against the whole query; the object is its first group, a name its second
+	verb    string         // command run for the object, or the whole command when the pattern names no object
+}
+
+// Objects of the read intents: virtual services, pools and service engines
+const (
+	intentObjects = `(virtual ?services?|vs|vses|pools?|service ?engines?|ses?)`
+	intentName    = `([\w][\w.:-]*)`
+)
+
+// readIntents are the read queries answered without the model. They only match a whole query,
+// so a query asking for anything more (a filter, a comparison, a change) goes to the model.
+var readIntents = []readIntent{
+	{regexp.MustCompile(`^(?:list|show(?: me)?|get|display|what are)(?: all)?(?: of)?(?: the| my| our)? ` + intentObjects + `$`), "list"},
+	{regexp.MustCompile(`^(?:which|what) ` + intentObjects + ` (?:are there|exist|do (?:i|we) have)$`), "list"},
+	{regexp.MustCompile(`^(?:show|get|describe|display)(?: me)?(?: the)?(?: details of| config(?:uration)? of)?(?: the)? ` + intentObjects + ` ` + intentName + `$`), "get"},
+	{regexp.MustCompile(`^(?:(?:show|get|what is|what's|how is)(?: me)? )?(?:the )?health(?: scores?)?(?: of| for)?(?: all| every)?(?: the| my| our)? ` + intentObjects + `$`), "health"},
+	{regexp.MustCompile(`^(?:how healthy|how) (?:are|is)(?: all| every)?(?: the| my| our)? ` + intentObjects + `$`), "health"},
+	{regexp.MustCompile(`^(?:what is |what's |show(?: me)? )?(?:the )?controller (?:version|info)$`), "controller"},
+	{regexp.MustCompile(`^what version (?:is|does) the controller(?: run| running)?$`), "controller"},
+}
+
+// intentFillers are the words of politeness a query may start with, left out before matching
+var intentFillers = []string{"please ", "can you ", "could you ", "would you "}
+
+// matchIntent returns the slash command name and argument of an obvious read query. Names keep
+// their case, as the controller's names are case sensitive.
+func matchIntent(query string) (string, string, bool) {
+	query = strings.Join(strings.Fields(query), " ")
+	query = strings.TrimRight(query, "?.! ")
+	query = strings.TrimSuffix(query, " please")
+	for _, filler := range intentFillers {
+		if len(query) > len(filler) && strings.EqualFold(query[:len(filler)], filler) {
+			query = query[len(filler):]
+			break
+		}
+	}
+	lower := strings.ToLower(query)
+	if len(lower) != len(query) {
+		query = lower
+	}
+	for _, intent := range readIntents {
+		match := intent.pattern.FindStringSubmatchIndex(lower)
+		if match == nil {
+			continue
+		}
+		if len(match) < 4 {
+			return intent.verb, "", true
+		}
+		name := intentObject(lower[match[2]:match[3]]) + " " + intent.verb
+		if len(match) < 6 {
+			return name, "", true
+		}
+		return name, query[match[4]:match[5]], true
+	}
+	return "", "", false
+}
+
+// intentObject returns the slash command object of the way a query names it
+func intentObject(object string) string {
+	switch {
+	case strings.HasPrefix(object, "pool"):
+		return "pool"
+	case strings.HasPrefix(object, "service") || strings.HasPrefix(object, "se"):
+		return "se"
+	}
+	return "vs"
+}
+
+// routeIntent answers an obvious read query with its slash command when routing.intents is set.
+// A query naming an object the controller doesn't know, or whose tool is disabled, is left to the
+// model, which can ask what was meant.
+func (s *Server) routeIntent(ctx context.Context, query string) (slashCommand, string, bool) {
+	if !s.config.Routing.Intents {
+		return slashCommand{}, "", false
+	}
+	name, argument, ok := matchIntent(query)
+	if !ok {
+		return slashCommand{}, "", false
+	}
+	command, _, ok := parseSlashCommand("/" + name)
+	if !ok || !s.tools.Enabled(command.tool) {
+		return slashCommand{}, "", false
+	}
+	if command.lookup != "" {
+		uuid, found := s.lookupUUID(ctx, command.lookup, argument)
+		if !found {
+			return slashCommand{}, "", false
+		}
+		command.lookup, argument = "", uuid
+	}
+	requestid.Logger(ctx, s.logger).Info("Routed read query to a tool",
+		zap.String("command", command.name),
+		zap.String("tool", command.tool))
+	return command, argument, true
+}
+
+// chatCommand returns the slash command a chat message runs: the command it starts with, or the
+// command of an obvious read query
+func (s *Server) chatCommand(ctx context.Context, message string) (slashCommand, string, bool) {
+	if command, argument, ok := parseSlashCommand(message); ok {
+		return command, argument, true
+	}
+	return s.routeIntent(ctx, message)
+}
@@ -0,0 +1,120 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+
+	"aviagent/internal/memory"
+	"aviagent/pkg/llm"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// withMemory returns the history sent to the model with the deployment memory in front of it,
+// as a system message
+func (s *Server) withMemory(history []llm.ChatMessage) []llm.ChatMessage {
+	if s.memory == nil {
+		return history
+	}
+	prompt := s.memory.Prompt()
+	if prompt == "" {
+		return history
+	}
+	return append([]llm.ChatMessage{{Role: "system", Content: prompt}}, history...)
+}
+
+// memoryAdmin returns the requesting operator when they may edit the deployment memory, and
+// answers the request otherwise
+func (s *Server) memoryAdmin(c *gin.Context) (string, bool) {
+	if s.memory == nil {
+		c.JSON(http.StatusNotFound, errorResponse{Error: "the deployment memory is disabled (memory.enabled)"})
+		return "", false
+	}
+	operator := s.operator(c)
+	for _, admin := range s.config.Memory.Admins {
+		if operator != "" && strings.EqualFold(admin, operator) {
+			return operator, true
+		}
+	}
+	c.JSON(http.StatusForbidden, errorResponse{Error: "only the operators listed in memory.admins can edit the deployment memory"})
+	return "", false
+}
+
+// handleListMemory returns the facts of the deployment memory
+func (s *Server) handleListMemory(c *gin.Context) {
+	if s.memory == nil {
+		c.JSON(http.StatusNotFound, errorResponse{Error: "the deployment memory is disabled (memory.enabled)"})
+		return
+	}
+	c.JSON(http.StatusOK, factsResponse{Facts: s.memory.List()})
+}
+
+// factsResponse lists the facts of the deployment memory
+type factsResponse struct {
+	Facts []memory.Fact `json:"facts"`
+}
+
+// memoryRequest is the body of a fact added or changed through the API
+type memoryRequest struct {
+	Topic string `json:"topic"`
+	Text  string `json:"text" binding:"required"`
+}
+
+// handleAddMemory adds a fact to the deployment memory
+func (s *Server) handleAddMemory(c *gin.Context) {
+	operator, ok := s.memoryAdmin(c)
+	if !ok {
+		return
+	}
+	var request memoryRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	fact, err := s.memory.Add(request.Topic, request.Text, operator)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	s.logger.Info("Deployment memory fact added", zap.String("id", fact.ID), zap.String("operator", operator))
+	c.JSON(http.StatusCreated, fact)
+}
+
+// handleUpdateMemory replaces the topic and text of a fact
+func (s *Server) handleUpdateMemory(c *gin.Context) {
+	operator, ok := s.memoryAdmin(c)
+	if !ok {
+		return
+	}
+	var request memoryRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	fact, err := s.memory.Update(c.Param("id"), request.Topic, request.Text, operator)
+	if err != nil {
+		status := http.StatusBadRequest
+		if strings.HasSuffix(err.Error(), "not found") {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, errorResponse{Error: err.Error()})
+		return
+	}
+	s.logger.Info("Deployment memory fact changed", zap.String("id", fact.ID), zap.String("operator", operator))
+	c.JSON(http.StatusOK, fact)
+}
+
+// handleDeleteMemory removes a fact from the deployment memory
+func (s *Server) handleDeleteMemory(c *gin.Context) {
+	operator, ok := s.memoryAdmin(c)
+	if !ok {
+		return
+	}
+	if !s.memory.Delete(c.Param("id")) {
+		c.JSON(http.StatusNotFound, errorResponse{Error: "fact not found"})
+		return
+	}
+	s.logger.Info("Deployment memory fact deleted", zap.String("id", c.Param("id")), zap.String("operator", operator))
+	c.JSON(http.StatusOK, messageResponse{Message: "Fact deleted"})
+}
@@ -0,0 +1,150 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"aviagent/pkg/llm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Session notes limits, so the notes sent with every turn stay small
+const (
+	maxSessionNotes = 50
+	maxNoteLength   = 500
+)
+
+// SessionNote is a fact kept for a chat session, such as "the change window is 22:00-23:00",
+// stored by the remember tool or through the API and sent to the model with every turn
+type SessionNote struct {
+	ID      string    `json:"id"`
+	Text    string    `json:"text"`
+	Created time.Time `json:"created"`
+}
+
+// AddNote keeps a note for an existing session. A note already kept is returned as is.
+func (s *SessionStore) AddNote(id, text string) (SessionNote, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return SessionNote{}, fmt.Errorf("the note is empty")
+	}
+	if len(text) > maxNoteLength {
+		return SessionNote{}, fmt.Errorf("the note is longer than %d characters", maxNoteLength)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return SessionNote{}, fmt.Errorf("notes are kept per chat session and this request has none")
+	}
+	for _, note := range session.Notes {
+		if strings.EqualFold(note.Text, text) {
+			return note, nil
+		}
+	}
+	if len(session.Notes) >= maxSessionNotes {
+		return SessionNote{}, fmt.Errorf("the session already has %d notes, delete one first", maxSessionNotes)
+	}
+	next := 1
+	if len(session.Notes) > 0 {
+		fmt.Sscanf(session.Notes[len(session.Notes)-1].ID, "note_%d", &next)
+		next++
+	}
+	note := SessionNote{ID: fmt.Sprintf("note_%d", next), Text: text, Created: time.Now()}
+	session.Notes = append(session.Notes, note)
+	return note, nil
+}
+
+// Notes returns the notes of a session, oldest first
+func (s *SessionStore) Notes(id string) []SessionNote {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return []SessionNote{}
+	}
+	return append([]SessionNote{}, session.Notes...)
+}
+
+// DeleteNote removes a note of a session and reports whether it existed
+func (s *SessionStore) DeleteNote(id, noteID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return false
+	}
+	for i, note := range session.Notes {
+		if note.ID == noteID {
+			session.Notes = append(session.Notes[:i:i], session.Notes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// withNotes returns the history sent to the model with the session notes in front of it, as a
+// system message, so they are taken into account however long ago they were written
+func withNotes(notes []SessionNote, history []llm.ChatMessage) []llm.ChatMessage {
+	if len(notes) == 0 {
+		return history
+	}
+	var content strings.Builder
+	content.WriteString("Notes kept for this session (use them, update them with remember when they change):")
+	for _, note := range notes {
+		fmt.Fprintf(&content, "\n- %s", note.Text)
+	}
+	return append([]llm.ChatMessage{{Role: "system", Content: content.String()}}, history...)
+}
+
+// notesResponse is the notes of a session
+type notesResponse struct {
+	Session string        `json:"session"`
+	Notes   []SessionNote `json:"notes"`
+}
+
+// addNoteRequest is the body of POST /api/v1/chat/notes
+type addNoteRequest struct {
+	Session string `json:"session" binding:"required"`
+	Text    string `json:"text" binding:"required"`
+}
+
+// handleListNotes returns the notes of ?session=
+func (s *Server) handleListNotes(c *gin.Context) {
+	c.JSON(http.StatusOK, notesResponse{Session: c.Query("session"), Notes: s.sessions.Notes(c.Query("session"))})
+}
+
+// handleAddNote keeps a note for a session
+func (s *Server) handleAddNote(c *gin.Context) {
+	var request addNoteRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	if _, ok := s.sessions.Export(request.Session); !ok {
+		c.JSON(http.StatusNotFound, errorResponse{Error: "session not found"})
+		return
+	}
+	note, err := s.sessions.AddNote(request.Session, request.Text)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, note)
+}
+
+// handleDeleteNote removes a note of ?session=
+func (s *Server) handleDeleteNote(c *gin.Context) {
+	if !s.sessions.DeleteNote(c.Query("session"), c.Param("id")) {
+		c.JSON(http.StatusNotFound, errorResponse{Error: "note not found"})
+		return
+	}
+	c.JSON(http.StatusOK, messageResponse{Message: "Note deleted"})
+}
@@ -0,0 +1,243 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"aviagent/pkg/agent/chat"
+	"aviagent/pkg/llm"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// openAIRequest is the body of an OpenAI chat completions request. Tools sent by the client are
+// ignored: the Avi tools are registered and executed by the agent.
+type openAIRequest struct {
+	Model    string          `json:"model"`
+	Messages []openAIMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Seed     *int            `json:"seed"`
+}
+
+// openAIMessage is a chat message in the OpenAI wire format. Content is a string, or an array
+// of content parts of which the text parts are used.
+type openAIMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// openAIResponse is a chat completion, or a chunk of one when streaming
+type openAIResponse struct {
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Choices []openAIChoice `json:"choices"`
+	Usage   *openAIUsage   `json:"usage,omitempty"`
+}
+
+type openAIChoice struct {
+	Index        int              `json:"index"`
+	Message      *openAIReplyText `json:"message,omitempty"`
+	Delta        *openAIReplyText `json:"delta,omitempty"`
+	FinishReason *string          `json:"finish_reason"`
+}
+
+type openAIReplyText struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// handleChatCompletions implements POST /v1/chat/completions so OpenAI-compatible clients can
+// use the agent. The request is stateless like the OpenAI API: the conversation is the messages
+// sent, the last of which must be from the user. Tool calls run server-side before the answer,
+// so a streamed answer arrives as a single content chunk.
+func (s *Server) handleChatCompletions(c *gin.Context) {
+	var request openAIRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	history, message, err := openAIConversation(request.Messages)
+	if err != nil {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	model, _ := s.routeModel(message, request.Model)
+	if model == "" {
+		model = s.config.LLM.DefaultModel
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	validModel, err := s.validateModel(ctx, model)
+	if err != nil {
+		s.logger.Error("Failed to validate model", zap.Error(err))
+		openAIError(c, http.StatusInternalServerError, "server_error", "Failed to validate model")
+		return
+	}
+	if !validModel {
+		openAIError(c, http.StatusNotFound, "invalid_request_error", fmt.Sprintf("Model '%s' is not available", model))
+		return
+	}
+
+	// No session is kept, so nothing can wait for the next request; the ID ties the recorded
+	// tool calls and audit entries of this request
+	id := fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano())
+	ctx = s.withActor(ctx, c, id, model)
+	ctx = chat.WithHooks(ctx, chat.Hooks{Unattended: true})
+	ctx, _ = s.withSeed(ctx, "", request.Seed)
+	response, err := chat.Run(ctx, chatRuntime{s}, message, model, history)
+	if err != nil {
+		s.logger.Error("Failed to process chat completion", zap.Error(err))
+		openAIError(c, http.StatusInternalServerError, "server_error", "Failed to process message")
+		return
+	}
+
+	content := response.Message
+	for _, notice := range response.Notices {
+		content += "\n\n" + notice
+	}
+	for _, download := range response.Downloads {
+		content += fmt.Sprintf("\n\nDownload %s: %s", download.Filename, download.URL)
+	}
+	for _, receipt := range response.Receipts {
+		content += fmt.Sprintf("\n\nReceipt %s (%s): %s", receipt.ID, receipt.Tool, receipt.URL)
+	}
+
+	stop := "stop"
+	completion := openAIResponse{
+		ID:      id,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Usage: &openAIUsage{
+			PromptTokens:     response.Usage.PromptTokens,
+			CompletionTokens: response.Usage.CompletionTokens,
+			TotalTokens:      response.Usage.TotalTokens,
+		},
+	}
+	if !request.Stream {
+		completion.Choices = []openAIChoice{{Message: &openAIReplyText{Role: "assistant", Content: content}, FinishReason: &stop}}
+		c.JSON(http.StatusOK, completion)
+		return
+	}
+
+	// Server-sent events: the answer, then the finish chunk with the usage, then [DONE]
+	completion.Object = "chat.completion.chunk"
+	usage := completion.Usage
+	completion.Usage = nil
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+	completion.Choices = []openAIChoice{{Delta: &openAIReplyText{Role: "assistant", Content: content}}}
+	writeOpenAIEvent(c, completion)
+	completion.Choices = []openAIChoice{{Delta: &openAIReplyText{}, FinishReason: &stop}}
+	completion.Usage = usage
+	writeOpenAIEvent(c, completion)
+	fmt.Fprint(c.Writer, "data: [DONE]\n\n")
+	c.Writer.Flush()
+}
+
+// handleOpenAIModels implements GET /v1/models, listing the models chat completions accept
+func (s *Server) handleOpenAIModels(c *gin.Context) {
+	type model struct {
+		ID      string `json:"id"`
+		Object  string `json:"object"`
+		OwnedBy string `json:"owned_by"`
+	}
+	models := []model{}
+	for _, name := range s.llmClient.GetAvailableModels() {
+		models = append(models, model{ID: name, Object: "model", OwnedBy: s.config.Provider})
+	}
+	if s.modelRouter.Enabled() {
+		models = append(models, model{ID: llm.AutoModel, Object: "model", OwnedBy: "aviagent"})
+	}
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": models})
+}
+
+// openAIConversation splits OpenAI messages into the history and the user message to answer.
+// System messages from the client are kept in the history, after the agent's own system prompt.
+func openAIConversation(messages []openAIMessage) ([]llm.ChatMessage, string, error) {
+	if len(messages) == 0 {
+		return nil, "", fmt.Errorf("messages must not be empty")
+	}
+	history := make([]llm.ChatMessage, 0, len(messages)-1)
+	for i, message := range messages {
+		content, err := openAIContent(message.Content)
+		if err != nil {
+			return nil, "", fmt.Errorf("messages[%d]: %w", i, err)
+		}
+		switch message.Role {
+		case "system", "developer":
+			history = append(history, llm.ChatMessage{Role: "system", Content: content})
+		case "user", "assistant":
+			history = append(history, llm.ChatMessage{Role: message.Role, Content: content})
+		case "tool", "function":
+			// Results of client-side tools; the agent's tools run server-side
+			continue
+		default:
+			return nil, "", fmt.Errorf("messages[%d]: unsupported role %q", i, message.Role)
+		}
+	}
+
+	if len(history) == 0 {
+		return nil, "", fmt.Errorf("the last message must be a non-empty user message")
+	}
+	last := history[len(history)-1]
+	if last.Role != "user" || strings.TrimSpace(last.Content) == "" {
+		return nil, "", fmt.Errorf("the last message must be a non-empty user message")
+	}
+	return history[:len(history)-1], last.Content, nil
+}
+
+// openAIContent reads message content given as a string or as content parts
+func openAIContent(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", fmt.Errorf("content must be a string or an array of content parts")
+	}
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part.Type == "text" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+// writeOpenAIEvent writes a server-sent event carrying a completion chunk
+func writeOpenAIEvent(c *gin.Context, chunk openAIResponse) {
+	data, _ := json.Marshal(chunk)
+	fmt.Fprintf(c.Writer, "data: %s\n\n", data)
+	c.Writer.Flush()
+}
+
+// openAIError responds with an error in the OpenAI format
+func openAIError(c *gin.Context, status int, kind, message string) {
+	c.JSON(status, gin.H{"error": gin.H{"message": message, "type": kind, "code": nil}})
+}
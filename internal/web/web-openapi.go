@@ -0,0 +1,243 @@
+package web
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// openAPIDocument describes the routes as an OpenAPI 3 document. The schemas are read from the
+// request and response types by their JSON tags, so the document can't drift from the handlers.
+func openAPIDocument(routes []apiRoute) map[string]interface{} {
+	schemas := openAPISchemas{components: map[string]interface{}{}, names: map[reflect.Type]string{}}
+	errorSchema := schemas.of(reflect.TypeOf(errorResponse{}))
+
+	paths := map[string]interface{}{}
+	for _, route := range routes {
+		path, params := openAPIPath(route.Path)
+		for _, param := range route.Query {
+			schema := map[string]interface{}{"type": "string"}
+			if param.Type != "" {
+				schema["type"] = param.Type
+			}
+			query := map[string]interface{}{"name": param.Name, "in": "query", "schema": schema}
+			if param.Description != "" {
+				query["description"] = param.Description
+			}
+			params = append(params, query)
+		}
+
+		operation := map[string]interface{}{
+			"tags":      []string{route.Tag},
+			"summary":   route.Summary,
+			"responses": openAPIResponses(route, schemas.response(route.Response), errorSchema),
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+		if route.Request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  map[string]interface{}{"application/json": map[string]interface{}{"schema": schemas.of(reflect.TypeOf(route.Request))}},
+			}
+		}
+
+		item, _ := paths[path].(map[string]interface{})
+		if item == nil {
+			item = map[string]interface{}{}
+			paths[path] = item
+		}
+		methods := []string{route.Method}
+		if route.Method == anyMethod {
+			methods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+		}
+		for _, method := range methods {
+			item[strings.ToLower(method)] = operation
+		}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "VMware Avi LLM Agent API",
+			"version":     apiVersion,
+			"description": "Failed requests are answered with an error object. The unversioned /api paths are deprecated aliases of these.",
+		},
+		"servers":    []interface{}{map[string]interface{}{"url": "/api/" + apiVersion}},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas.components},
+	}
+}
+
+// openAPIPath converts a gin path to an OpenAPI one, and returns its path parameters
+func openAPIPath(path string) (string, []interface{}) {
+	var params []interface{}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if segment == "" || (segment[0] != ':' && segment[0] != '*') {
+			continue
+		}
+		name := segment[1:]
+		segments[i] = "{" + name + "}"
+		params = append(params, map[string]interface{}{
+			"name": name, "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"},
+		})
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// openAPIResponses describes the success response of a route and its errors
+func openAPIResponses(route apiRoute, schema, errorSchema map[string]interface{}) map[string]interface{} {
+	mediaType := "application/json"
+	if route.File != "" {
+		mediaType = route.File
+		schema = map[string]interface{}{"type": "string", "format": "binary"}
+	}
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return map[string]interface{}{
+		strconv.Itoa(status): map[string]interface{}{
+			"description": http.StatusText(status),
+			"content":     map[string]interface{}{mediaType: map[string]interface{}{"schema": schema}},
+		},
+		"default": map[string]interface{}{
+			"description": "Error",
+			"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": errorSchema}},
+		},
+	}
+}
+
+// openAPISchemas collects the schemas of the named struct types, referenced from the operations
+type openAPISchemas struct {
+	components map[string]interface{}
+	names      map[reflect.Type]string
+}
+
+// response returns the schema of a route's response value
+func (s openAPISchemas) response(value interface{}) map[string]interface{} {
+	switch value := value.(type) {
+	case nil:
+		return map[string]interface{}{}
+	case apiOneOf:
+		shapes := make([]interface{}, 0, len(value))
+		for _, shape := range value {
+			shapes = append(shapes, s.of(reflect.TypeOf(shape)))
+		}
+		return map[string]interface{}{"oneOf": shapes}
+	}
+	return s.of(reflect.TypeOf(value))
+}
+
+// timeType is encoded as an RFC 3339 string
+var timeType = reflect.TypeOf(time.Time{})
+
+// of returns the schema of a type: a reference for named structs, whose schema is added to the
+// components
+func (s openAPISchemas) of(t reflect.Type) map[string]interface{} {
+	if t == nil {
+		return map[string]interface{}{}
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		name, ok := s.names[t]
+		if !ok {
+			name = s.name(t)
+			s.names[t] = name
+			s.components[name] = map[string]interface{}{} // placeholder for recursive types
+			s.components[name] = s.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return s.object(t)
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": s.of(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.of(t.Elem())}
+	}
+	return map[string]interface{}{} // any JSON value
+}
+
+// object returns the schema of a struct's JSON fields; those of embedded structs are promoted
+// as encoding/json does
+func (s openAPISchemas) object(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+	s.fields(t, properties, &required)
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (s openAPISchemas) fields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		embedded := field.Type
+		for embedded.Kind() == reflect.Pointer {
+			embedded = embedded.Elem()
+		}
+		if field.Anonymous && name == "" && embedded.Kind() == reflect.Struct {
+			s.fields(embedded, properties, required)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = s.of(field.Type)
+		if strings.Contains(field.Tag.Get("binding"), "required") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// name returns the component name of a type: its name capitalized, qualified with its package
+// when another package has a type of that name
+func (s openAPISchemas) name(t reflect.Type) string {
+	name := capitalize(t.Name())
+	if _, taken := s.components[name]; taken {
+		pkg := t.PkgPath()
+		name = capitalize(pkg[strings.LastIndex(pkg, "/")+1:]) + name
+	}
+	return name
+}
+
+// capitalize upper-cases the first letter of a name
+func capitalize(name string) string {
+	for i, r := range name {
+		return string(unicode.ToUpper(r)) + name[i+len(string(r)):]
+	}
+	return name
+}
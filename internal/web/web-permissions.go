@@ -0,0 +1,52 @@
+package web
+
+import (
+	"context"
+	"time"
+
+	"aviagent/pkg/avi"
+	"aviagent/pkg/llm"
+
+	"go.uber.org/zap"
+)
+
+// loadPermissions reads the Avi account's role so tools it can't perform are never offered.
+// When the role can't be read every tool stays available.
+func (s *Server) loadPermissions() {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	perms, err := avi.LoadPermissions(ctx, s.aviClient, s.config.Avi.Username, s.config.Avi.Tenant)
+	if err != nil {
+		s.logger.Warn("Could not read Avi account permissions, all tools stay enabled", zap.Error(err))
+		return
+	}
+	s.permissions = perms
+
+	var disabled []string
+	for _, name := range s.tools.Names() {
+		if !perms.AllowsTool(name) {
+			disabled = append(disabled, name)
+		}
+	}
+	s.logger.Info("Derived allowed tools from Avi account role",
+		zap.String("role", perms.Role),
+		zap.Bool("superuser", perms.Superuser),
+		zap.Strings("disabled_tools", disabled))
+}
+
+// availableTools returns the tool definitions the deployment offers that the Avi account is
+// permitted to use
+func (s *Server) availableTools() []llm.Tool {
+	tools := s.tools.Definitions()
+	if s.permissions == nil {
+		return tools
+	}
+	allowed := make([]llm.Tool, 0, len(tools))
+	for _, tool := range tools {
+		if s.permissions.AllowsTool(tool.Function.Name) {
+			allowed = append(allowed, tool)
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,421 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"aviagent/internal/requestid"
+	"aviagent/internal/workflow"
+	"aviagent/pkg/audit"
+	"aviagent/pkg/llm"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// workflowPlan is the kind of the workflow runs recording the plans of tool calls the operator
+// approves before they run
+const workflowPlan = "plan"
+
+// maxPlanSteps limits the tool calls of a plan
+const maxPlanSteps = 10
+
+// planTimeout bounds the run of an approved plan, all steps included
+const planTimeout = 5 * time.Minute
+
+// planPrompt instructs the model to plan the tool calls of a request instead of making them
+const planPrompt = `You plan how to carry out an operator's request on a VMware Avi load balancer with the tools below, without running them. The operator reviews the plan, then each step is run in order.
+Answer with a JSON object only: {"summary": "<one sentence>", "steps": [{"tool": "<tool name>", "arguments": {...}, "reason": "<why this step>"}]}
+Use only the tools listed, with arguments matching their parameters, and at most %d steps. A step can't use the results of earlier steps, so only plan steps whose arguments are known now. When the request can't be planned this way, answer with no steps and say why in the summary.
+
+Tools:
+%s`
+
+// planStep is a tool call of a plan with the reason the model gave for it
+type planStep struct {
+	Tool      string                 `json:"tool"`
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+	Reason    string                 `json:"reason"`
+}
+
+// planInput is the input recorded with a plan's workflow run, to run it once approved
+type planInput struct {
+	Query   string     `json:"query"`
+	Summary string     `json:"summary,omitempty"`
+	Session string     `json:"session"`
+	Model   string     `json:"model"`
+	Steps   []planStep `json:"steps"`
+}
+
+// planRequest is the body of a plan request
+type planRequest struct {
+	Message string `json:"message" binding:"required"`
+	Model   string `json:"model"`
+	Session string `json:"session"`
+}
+
+// planStepView is a step of a plan with its outcome
+type planStepView struct {
+	planStep
+	Mutating bool        `json:"mutating"` // whether the step changes configuration
+	Status   string      `json:"status"`   // pending, done or failed
+	Result   interface{} `json:"result,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// planView is a plan, its state and the outcome of its steps
+type planView struct {
+	ID       string          `json:"id"` // the workflow run recording it
+	Query    string          `json:"query"`
+	Summary  string          `json:"summary,omitempty"`
+	Session  string          `json:"session"`
+	Model    string          `json:"model"`
+	Operator string          `json:"operator,omitempty"`
+	State    string          `json:"state"` // proposed, running, completed, failed, interrupted or aborted (rejected)
+	Steps    []planStepView  `json:"steps"`
+	Receipts []audit.Receipt `json:"receipts,omitempty"` // changes made by the steps run by this request
+	Created  time.Time       `json:"created"`
+	Updated  time.Time       `json:"updated"`
+}
+
+// viewPlan describes a plan's workflow run
+func (s *Server) viewPlan(run workflow.Run) planView {
+	var input planInput
+	_ = json.Unmarshal(run.Input, &input)
+	view := planView{
+		ID:       run.ID,
+		Query:    input.Query,
+		Summary:  input.Summary,
+		Session:  input.Session,
+		Model:    input.Model,
+		Operator: run.Operator,
+		State:    run.State,
+		Steps:    make([]planStepView, len(input.Steps)),
+		Created:  run.Created,
+		Updated:  run.Updated,
+	}
+	for i, step := range input.Steps {
+		view.Steps[i] = planStepView{planStep: step, Mutating: s.tools.IsMutating(step.Tool, step.Arguments), Status: workflow.StepPending}
+		if i >= len(run.Steps) {
+			continue
+		}
+		view.Steps[i].Status, view.Steps[i].Error = run.Steps[i].Status, run.Steps[i].Error
+		if detail := run.Steps[i].Detail; detail != "" {
+			var result interface{}
+			if err := json.Unmarshal([]byte(detail), &result); err != nil {
+				result = detail
+			}
+			view.Steps[i].Result = result
+		}
+	}
+	return view
+}
+
+// planMessage describes a plan in the chat session it was made for
+func planMessage(view planView) string {
+	var message strings.Builder
+	fmt.Fprintf(&message, "Plan %s (%s)", view.ID, view.State)
+	if view.Summary != "" {
+		fmt.Fprintf(&message, ": %s", view.Summary)
+	}
+	for i, step := range view.Steps {
+		arguments, _ := json.Marshal(step.Arguments)
+		fmt.Fprintf(&message, "\n%d. %s %s - %s", i+1, step.Tool, arguments, step.Reason)
+		if step.Status != workflow.StepPending {
+			fmt.Fprintf(&message, " [%s]", step.Status)
+		}
+		if step.Error != "" {
+			fmt.Fprintf(&message, ": %s", step.Error)
+		}
+	}
+	return message.String()
+}
+
+// proposePlan has the model plan the tool calls of a request and records the plan, proposed
+// until the operator approves it. Plans naming a tool that isn't offered or arguments that don't
+// match its parameters are refused, as the model's tool calls would be.
+func (s *Server) proposePlan(ctx context.Context, query, model, sessionID string) (planView, error) {
+	offered := s.availableTools()
+	catalog := make([]llm.Function, len(offered))
+	for i, tool := range offered {
+		catalog[i] = tool.Function
+	}
+	tools, err := json.Marshal(catalog)
+	if err != nil {
+		return planView{}, fmt.Errorf("failed to describe the tools: %w", err)
+	}
+	reply, err := s.llmClient.Complete(ctx, model, fmt.Sprintf(planPrompt, maxPlanSteps, tools), "Request: "+query)
+	if err != nil {
+		return planView{}, fmt.Errorf("failed to plan the request: %w", err)
+	}
+	summary, steps, err := s.parsePlan(reply, offered)
+	if err != nil {
+		return planView{}, err
+	}
+
+	names := make([]string, len(steps))
+	for i, step := range steps {
+		names[i] = step.Tool
+	}
+	input := planInput{Query: query, Summary: summary, Session: sessionID, Model: model, Steps: steps}
+	run, err := s.workflows.Propose(workflowPlan, fmt.Sprintf("%d steps", len(steps)), audit.ActorFrom(ctx).Operator, input, names)
+	if err != nil {
+		return planView{}, err
+	}
+	requestid.Logger(ctx, s.logger).Info("Plan proposed",
+		zap.String("workflow", run.ID),
+		zap.Strings("steps", names))
+	return s.viewPlan(run), nil
+}
+
+// parsePlan reads the plan the model answered with, checking each step like a tool call
+func (s *Server) parsePlan(reply string, offered []llm.Tool) (string, []planStep, error) {
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return "", nil, errors.New("the model didn't answer with a plan")
+	}
+	var plan struct {
+		Summary string     `json:"summary"`
+		Steps   []planStep `json:"steps"`
+	}
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &plan); err != nil {
+		return "", nil, fmt.Errorf("the model didn't answer with a plan: %w", err)
+	}
+	switch {
+	case len(plan.Steps) == 0:
+		return "", nil, fmt.Errorf("the model proposed no steps: %s", valueOr(plan.Summary, "no reason given"))
+	case len(plan.Steps) > maxPlanSteps:
+		return "", nil, fmt.Errorf("the model proposed %d steps, more than the %d a plan may have", len(plan.Steps), maxPlanSteps)
+	}
+
+	names := make(map[string]bool, len(offered))
+	for _, tool := range offered {
+		names[tool.Function.Name] = true
+	}
+	for i, step := range plan.Steps {
+		if !names[step.Tool] {
+			return "", nil, fmt.Errorf("step %d: unknown tool %q", i+1, step.Tool)
+		}
+		if err := s.tools.ValidateArgs(step.Tool, step.Arguments); err != nil {
+			return "", nil, fmt.Errorf("step %d: %w", i+1, err)
+		}
+	}
+	return plan.Summary, plan.Steps, nil
+}
+
+// runPlan runs the pending steps of an approved plan in order, under the permission checks and
+// auditing of a chat tool call, and stops at the first step that fails. Each step's result is
+// recorded reduced and moderated as for the model, and the outcome is added to the plan's session.
+func (s *Server) runPlan(ctx context.Context, runID string) planView {
+	run, _ := s.workflows.Get(runID)
+	var input planInput
+	_ = json.Unmarshal(run.Input, &input)
+	actor := audit.ActorFrom(ctx)
+	actor.Session, actor.Model = input.Session, input.Model
+	ctx = audit.WithActor(ctx, actor)
+	logger := requestid.Logger(ctx, s.logger)
+
+	var receipts []audit.Receipt
+	for i := run.NextStep(); i >= 0 && i < len(input.Steps); i++ {
+		step := input.Steps[i]
+		toolCall, result, receipt, err := s.rerunInvocation(ctx, ToolInvocation{Session: input.Session, Operator: actor.Operator, Tool: step.Tool, Args: step.Arguments})
+		if receipt != nil {
+			receipts = append(receipts, *receipt)
+		}
+		if err != nil {
+			s.workflows.SetStep(runID, i, workflow.StepFailed, "", err)
+			if ctx.Err() != nil {
+				s.workflows.Interrupt(runID)
+			}
+			break
+		}
+		detail := ""
+		if result != nil {
+			detail = s.moderator.Moderate(ctx, s.reduceResult(toolCall, result)).Text
+		}
+		s.workflows.SetStep(runID, i, workflow.StepDone, detail, nil)
+	}
+
+	finished, _ := s.workflows.Get(runID)
+	view := s.viewPlan(finished)
+	view.Receipts = receipts
+	logger.Info("Plan run",
+		zap.String("workflow", runID),
+		zap.String("state", view.State))
+	s.sessions.AppendExchange(input.Session, input.Model, "Run plan "+runID, planMessage(view), input.toolNames())
+	return view
+}
+
+// toolNames returns the tools of a plan's steps
+func (p planInput) toolNames() []string {
+	names := make([]string, len(p.Steps))
+	for i, step := range p.Steps {
+		names[i] = step.Tool
+	}
+	return names
+}
+
+// lookupPlan returns the plan named by the :id parameter. Plans proposed for an operator can only
+// be approved or rejected by that operator.
+func (s *Server) lookupPlan(c *gin.Context) (workflow.Run, int, error) {
+	run, ok := s.workflows.Get(c.Param("id"))
+	if !ok || run.Kind != workflowPlan {
+		return run, http.StatusNotFound, fmt.Errorf("plan %s not found", c.Param("id"))
+	}
+	if run.Operator != "" && run.Operator != s.operator(c) {
+		return run, http.StatusForbidden, errors.New("plan belongs to another operator")
+	}
+	return run, http.StatusOK, nil
+}
+
+// approvePlan starts the run of a proposed plan and runs its steps
+func (s *Server) approvePlan(c *gin.Context) (planView, int, error) {
+	run, status, err := s.lookupPlan(c)
+	if err != nil {
+		return planView{}, status, err
+	}
+	if _, err := s.workflows.Approve(run.ID); err != nil {
+		return planView{}, http.StatusConflict, err
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), planTimeout)
+	defer cancel()
+	ctx = s.withActor(ctx, c, "", "")
+	return s.runPlan(ctx, run.ID), http.StatusOK, nil
+}
+
+// rejectPlan ends a proposed plan without running it
+func (s *Server) rejectPlan(c *gin.Context) (planView, int, error) {
+	run, status, err := s.lookupPlan(c)
+	if err != nil {
+		return planView{}, status, err
+	}
+	if run.State != workflow.StateProposed {
+		return planView{}, http.StatusConflict, fmt.Errorf("plan %s is %s, not awaiting approval", run.ID, run.State)
+	}
+	s.workflows.Abort(run.ID)
+	run, _ = s.workflows.Get(run.ID)
+	return s.viewPlan(run), http.StatusOK, nil
+}
+
+// handleCreatePlan has the model plan the tool calls of a request without running them
+func (s *Server) handleCreatePlan(c *gin.Context) {
+	var request planRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	model, _ := s.routeModel(request.Message, request.Model)
+	model = valueOr(model, s.config.LLM.DefaultModel)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+	defer cancel()
+	session := s.sessions.GetOrCreate(request.Session, model)
+	ctx = s.withActor(ctx, c, session.ID, model)
+	view, err := s.proposePlan(ctx, request.Message, model, session.ID)
+	if err != nil {
+		requestid.Logger(ctx, s.logger).Warn("Failed to plan request", zap.Error(err))
+		c.JSON(http.StatusBadGateway, errorResponse{Error: err.Error(), RequestID: requestid.From(ctx)})
+		return
+	}
+	s.sessions.AppendExchange(session.ID, model, request.Message, planMessage(view), nil)
+	c.JSON(http.StatusCreated, view)
+}
+
+// handleGetPlan returns a plan with the outcome of its steps
+func (s *Server) handleGetPlan(c *gin.Context) {
+	run, status, err := s.lookupPlan(c)
+	if err != nil {
+		c.JSON(status, errorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, s.viewPlan(run))
+}
+
+// handleApprovePlan runs a proposed plan step by step and returns the outcome of each step
+func (s *Server) handleApprovePlan(c *gin.Context) {
+	view, status, err := s.approvePlan(c)
+	if err != nil {
+		c.JSON(status, errorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, view)
+}
+
+// handleRejectPlan ends a proposed plan without running it
+func (s *Server) handleRejectPlan(c *gin.Context) {
+	view, status, err := s.rejectPlan(c)
+	if err != nil {
+		c.JSON(status, errorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, view)
+}
+
+// handleHTMXApprovePlan runs a proposed plan from the web UI and renders the outcome of its steps
+func (s *Server) handleHTMXApprovePlan(c *gin.Context) {
+	view, status, err := s.approvePlan(c)
+	if err != nil {
+		c.HTML(status, "chat.html", gin.H{"error": err.Error()})
+		return
+	}
+	done := 0
+	for _, step := range view.Steps {
+		if step.Status == workflow.StepDone {
+			done++
+		}
+	}
+	message := fmt.Sprintf("Ran %d of the %d steps of plan %s (%s).", done, len(view.Steps), view.ID, view.State)
+	for _, step := range view.Steps {
+		if step.Result != nil {
+			message += fmt.Sprintf("\n\n%s\n```json\n%s\n```", toolResultMarker, formatResult(step.Result))
+		}
+	}
+	c.HTML(http.StatusOK, "chat.html", gin.H{
+		"assistantMessage": message,
+		"plan":             view,
+		"receipts":         view.Receipts,
+		"timestamp":        time.Now().Format("15:04:05"),
+	})
+}
+
+// handleHTMXRejectPlan ends a proposed plan from the web UI
+func (s *Server) handleHTMXRejectPlan(c *gin.Context) {
+	view, status, err := s.rejectPlan(c)
+	if err != nil {
+		c.HTML(status, "chat.html", gin.H{"error": err.Error()})
+		return
+	}
+	c.HTML(http.StatusOK, "chat.html", gin.H{
+		"assistantMessage": fmt.Sprintf("Rejected plan %s, nothing was run.", view.ID),
+		"timestamp":        time.Now().Format("15:04:05"),
+	})
+}
+
+// handleHTMXChatPlan has the model plan a question from the web UI, shown for approval
+func (s *Server) handleHTMXChatPlan(c *gin.Context, message, model, sessionID string) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+	defer cancel()
+
+	model = valueOr(model, s.config.LLM.DefaultModel)
+	session := s.sessions.GetOrCreate(sessionID, model)
+	ctx = s.withActor(ctx, c, session.ID, model)
+	view, err := s.proposePlan(ctx, message, model, session.ID)
+	if err != nil {
+		c.HTML(http.StatusOK, "chat.html", gin.H{"error": "Failed to plan the request: " + err.Error(), "requestID": requestid.From(ctx)})
+		return
+	}
+	s.sessions.AppendExchange(session.ID, model, message, planMessage(view), nil)
+	c.HTML(http.StatusOK, "chat.html", gin.H{
+		"userMessage":      message,
+		"assistantMessage": strings.TrimSpace(view.Summary + fmt.Sprintf("\n\nApprove plan %s to run its %d steps in order.", view.ID, len(view.Steps))),
+		"model":            model,
+		"plan":             view,
+		"timestamp":        time.Now().Format("15:04:05"),
+		"sessionUsage":     s.sessions.Usage(session.ID),
+	})
+}
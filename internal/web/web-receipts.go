@@ -0,0 +1,128 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"aviagent/pkg/audit"
+	"aviagent/pkg/avi"
+	"aviagent/pkg/llm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// objectState is the part of a controller object a receipt refers to
+type objectState struct {
+	URL          string `json:"url"`
+	LastModified string `json:"_last_modified"`
+}
+
+// readObjectState decodes the reference fields of a controller object or tool result
+func readObjectState(value interface{}) objectState {
+	var state objectState
+	if data, err := json.Marshal(value); err == nil {
+		_ = json.Unmarshal(data, &state)
+	}
+	return state
+}
+
+// objectRef reads the current version of the object at a proxy path, with the object itself for
+// the change history, or returns nil when the tool call doesn't name a single object. The path is
+// kept when the object can't be read.
+func (s *Server) objectRef(ctx context.Context, link string) *audit.ObjectRef {
+	if link == "" {
+		return nil
+	}
+	ref := &audit.ObjectRef{URL: link}
+	if object, err := s.aviClient.ExecuteGenericOperation(ctx, http.MethodGet, strings.TrimPrefix(link, avi.ProxyPrefix), nil, nil); err == nil {
+		ref.Version = readObjectState(object).LastModified
+		ref.Snapshot, _ = json.Marshal(object)
+	}
+	return ref
+}
+
+// proxyLink converts the controller URL of an object (https://controller/api/pool/pool-1#web)
+// to its path through the Avi API proxy
+func proxyLink(controllerURL string) string {
+	_, path, ok := strings.Cut(controllerURL, "/api/")
+	if !ok {
+		return ""
+	}
+	path, _, _ = strings.Cut(path, "#")
+	if objectType, uuid, ok := strings.Cut(path, "/"); ok && objectType != "" && uuid != "" && !strings.Contains(uuid, "/") {
+		return avi.ProxyPrefix + "/" + objectType + "/" + uuid
+	}
+	return ""
+}
+
+// beginChange reads the object a mutating tool call is about to change into its audit entry
+func (s *Server) beginChange(ctx context.Context, entry *audit.Entry, toolCall llm.ToolCall) {
+	entry.Before = s.objectRef(ctx, s.changeLink(ctx, toolCall))
+}
+
+// recordChange stores the audit entry of a mutating tool call and returns the receipt of the
+// change, or nil when the call failed or couldn't be audited. The object after the change is
+// read from the result when it carries its version, and from the controller otherwise. The
+// write is added to the change history with the object as it was before and after.
+func (s *Server) recordChange(ctx context.Context, entry audit.Entry, toolCall llm.ToolCall, result interface{}, callErr error) *audit.Receipt {
+	if callErr == nil && changeAction(toolCall) != "delete" {
+		state := readObjectState(result)
+		link := proxyLink(state.URL)
+		if link == "" && entry.Before != nil {
+			link = entry.Before.URL
+		}
+		if link != "" && state.LastModified != "" {
+			entry.After = &audit.ObjectRef{URL: link, Version: state.LastModified}
+			entry.After.Snapshot, _ = json.Marshal(result)
+		} else {
+			entry.After = s.objectRef(ctx, link)
+		}
+	}
+	// The objects themselves go to the change history, not the audit trail
+	var before, after json.RawMessage
+	if entry.Before != nil {
+		ref := *entry.Before
+		before, ref.Snapshot = ref.Snapshot, nil
+		entry.Before = &ref
+	}
+	if entry.After != nil {
+		ref := *entry.After
+		after, ref.Snapshot = ref.Snapshot, nil
+		entry.After = &ref
+	}
+
+	recorded, ok := s.recordAudit(entry, callErr)
+	if !ok || callErr != nil {
+		return nil
+	}
+	receipt := s.receipt(recorded)
+	s.recordHistory(recorded, toolCall, result, before, after, receipt.ID)
+	return &receipt
+}
+
+// receipt returns the receipt of a recorded change with its links: to itself, and to the audit
+// export of the second the change was recorded in
+func (s *Server) receipt(entry audit.Entry) audit.Receipt {
+	receipt := audit.NewReceipt(entry)
+	receipt.URL = "/api/" + apiVersion + "/receipts/" + receipt.ID
+	from := entry.Timestamp.Truncate(time.Second)
+	receipt.AuditURL = fmt.Sprintf("/api/%s/audit/export?format=%s&from=%s&to=%s", apiVersion, audit.FormatJSON,
+		url.QueryEscape(from.Format(time.RFC3339)), url.QueryEscape(from.Add(time.Second).Format(time.RFC3339)))
+	return receipt
+}
+
+// handleGetReceipt returns the receipt of a change. Like the audit trail, receipts can be read
+// by every operator, so they can be linked from change tickets.
+func (s *Server) handleGetReceipt(c *gin.Context) {
+	entry, ok := s.auditLog.Change(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, errorResponse{Error: "receipt not found"})
+		return
+	}
+	c.JSON(http.StatusOK, s.receipt(entry))
+}
@@ -0,0 +1,40 @@
+package web
+
+import (
+	"aviagent/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// Reload creates a server for a changed configuration that continues this one: chat sessions,
+// tool invocations, insights, received alerts and the sandbox controller carry over, and the Avi
+// and LLM clients are only re-created when their configuration changed. Scheduled reports move to
+// the new server right away. The caller sends new requests to the returned server and retires
+// this one once the requests it is answering are done. On error this server keeps running.
+func (s *Server) Reload(cfg *config.Config) (*Server, error) {
+	next, err := newServer(cfg, s.logger, s)
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Info("Configuration reloaded",
+		zap.Bool("avi_client_recreated", next.sharedAviClient() != s.sharedAviClient()),
+		zap.Bool("llm_client_recreated", next.llmClient != s.llmClient))
+	return next, nil
+}
+
+// Retire releases what a server replaced by next doesn't share with it: the Avi sessions of
+// re-created clients and a sandbox controller that is no longer used
+func (s *Server) Retire(next *Server) error {
+	if s.sandbox != nil && s.sandbox != next.sandbox {
+		defer s.sandbox.Close()
+	}
+	if s.aviUsers != nil && s.aviUsers != next.aviUsers {
+		if err := s.aviUsers.closeSessions(); err != nil {
+			s.logger.Warn("Failed to close operator Avi sessions", zap.Error(err))
+		}
+	}
+	if shared := s.sharedAviClient(); shared != nil && shared != next.sharedAviClient() {
+		return shared.Close()
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"aviagent/internal/locale"
+	"aviagent/internal/notify"
+	"aviagent/internal/scheduler"
+	"aviagent/pkg/avi"
+
+	"github.com/gin-gonic/gin"
+)
+
+// reportJobsResponse lists the scheduled report jobs
+type reportJobsResponse struct {
+	Enabled bool                  `json:"enabled"` // scheduler.enabled
+	Jobs    []scheduler.JobStatus `json:"jobs"`
+}
+
+// reportErrorResponse is a report job that failed, with what it built before failing
+type reportErrorResponse struct {
+	errorResponse
+	Report *scheduler.Report `json:"report"`
+}
+
+// channelsResponse lists the notification channels
+type channelsResponse struct {
+	Channels []notify.ChannelInfo `json:"channels"`
+}
+
+// handleListReportJobs lists the scheduled report jobs with their next and last run
+func (s *Server) handleListReportJobs(c *gin.Context) {
+	jobs := []scheduler.JobStatus{}
+	if s.scheduler != nil {
+		jobs = s.scheduler.Jobs()
+	}
+	c.JSON(http.StatusOK, reportJobsResponse{Enabled: s.scheduler != nil, Jobs: jobs})
+}
+
+// handleRunReportJob runs a report job now and delivers it, e.g. to test its destinations
+func (s *Server) handleRunReportJob(c *gin.Context) {
+	if s.scheduler == nil {
+		c.JSON(http.StatusNotFound, errorResponse{Error: "scheduled reports are disabled (scheduler.enabled)"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Minute)
+	defer cancel()
+	report, err := s.scheduler.Run(ctx, c.Param("name"))
+	if errors.Is(err, scheduler.ErrJobNotFound) {
+		c.JSON(http.StatusNotFound, errorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, reportErrorResponse{errorResponse: errorResponse{Error: err.Error()}, Report: report})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// latestDigest returns the change digest last built by a change_digest job, or nil
+func (s *Server) latestDigest() *scheduler.Report {
+	if s.scheduler == nil {
+		return nil
+	}
+	return s.scheduler.Latest(scheduler.ReportChangeDigest)
+}
+
+// handleChangeDigest returns the latest digest of controller configuration changes
+func (s *Server) handleChangeDigest(c *gin.Context) {
+	digest := s.latestDigest()
+	if digest == nil {
+		c.JSON(http.StatusNotFound, errorResponse{Error: "no change digest yet: schedule a job with report change_digest"})
+		return
+	}
+	c.JSON(http.StatusOK, digest)
+}
+
+// handleHTMXChangeDigest renders the latest change digest pinned in the sidebar, nothing when
+// there is none
+func (s *Server) handleHTMXChangeDigest(c *gin.Context) {
+	c.HTML(http.StatusOK, "digest.html", s.latestDigest())
+}
+
+// slaCSVHeader is the column layout of SLA report CSV exports
+var slaCSVHeader = []string{"name", "uuid", "state", "availability", "target", "breached", "downtime_seconds", "outages",
+	"longest_outage_seconds", "budget_remaining_seconds", "health_average", "health_lowest", "degraded_percent"}
+
+// handleSLAReport exports the availability of the virtual services over a period as JSON or CSV,
+// e.g. for monthly SLA reviews. Query: period (default 30d), target (default 99.9), vs (comma
+// separated names or UUIDs), format and, for CSV, locale (the Accept-Language header by default).
+func (s *Server) handleSLAReport(c *gin.Context) {
+	var target float64
+	if value := c.Query("target"); value != "" {
+		var err error
+		if target, err = strconv.ParseFloat(value, 64); err != nil || target <= 0 || target > 100 {
+			c.JSON(http.StatusBadRequest, errorResponse{Error: "target must be a percentage between 0 and 100"})
+			return
+		}
+	}
+	var names []string
+	if value := c.Query("vs"); value != "" {
+		names = strings.Split(value, ",")
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("unsupported format %q, use csv or json", format)})
+		return
+	}
+	loc, err := requestLocale(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Minute)
+	defer cancel()
+	report, err := avi.GetAvailability(ctx, s.aviClient, c.Query("period"), target, names, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadGateway, errorResponse{Error: err.Error()})
+		return
+	}
+	if format == "json" {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = loc.CSVSeparator()
+	w.Write(slaCSVHeader)
+	for _, vs := range report.VirtualServices {
+		if vs.Disabled {
+			w.Write([]string{vs.Name, vs.UUID, "disabled", "", "", "", "", "", "", "", "", "", ""})
+			continue
+		}
+		w.Write([]string{vs.Name, vs.UUID, vs.State, loc.Plain(vs.Availability), loc.Plain(report.Target),
+			strconv.FormatBool(vs.Breached), strconv.FormatInt(vs.DowntimeSeconds, 10), strconv.Itoa(vs.Outages),
+			strconv.FormatInt(vs.LongestOutageSeconds, 10), strconv.FormatInt(vs.BudgetRemainingSeconds, 10),
+			loc.Plain(vs.HealthAverage), loc.Plain(vs.HealthLowest), loc.Plain(vs.DegradedPercent)})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+	filename := fmt.Sprintf("aviagent-sla-%s-%s.csv", report.Period, time.Now().UTC().Format("20060102"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+// requestLocale returns the locale of an export: the locale query parameter, else the first
+// supported language of the Accept-Language header, else the default locale
+func requestLocale(c *gin.Context) (locale.Locale, error) {
+	if tag := c.Query("locale"); tag != "" {
+		return locale.Parse(tag)
+	}
+	loc, _ := locale.FromAcceptLanguage(c.GetHeader("Accept-Language"))
+	return loc, nil
+}
+
+// handleListNotificationChannels lists the notification channels, without their URLs and headers
+func (s *Server) handleListNotificationChannels(c *gin.Context) {
+	c.JSON(http.StatusOK, channelsResponse{Channels: s.notifier.Channels()})
+}
+
+// handleTestNotificationChannel sends a test event to a channel to check its URL and template
+func (s *Server) handleTestNotificationChannel(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+	err := s.notifier.Send(ctx, c.Param("name"), notify.TestEvent(time.Now().UTC()))
+	if errors.Is(err, notify.ErrUnknownChannel) {
+		c.JSON(http.StatusNotFound, errorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, errorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, messageResponse{Message: "test notification sent"})
+}
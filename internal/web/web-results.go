@@ -0,0 +1,197 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"aviagent/internal/requestid"
+	"aviagent/pkg/llm"
+
+	"go.uber.org/zap"
+)
+
+// resultSummaryPrompt instructs the model summarizing a tool result too large to add as it is
+const resultSummaryPrompt = `You summarize the JSON result of a VMware Avi load balancer API call for an assistant that answers the operator's question with it.
+Write at most 200 words in plain text. Keep every object name, UUID, state, count and error, and the "_truncated" totals; group similar objects rather than listing them one by one.
+Don't invent anything the result doesn't say.`
+
+// identityFields are kept by every projection, so projected objects can still be named
+var identityFields = []string{"name", "uuid"}
+
+// renderResult returns the text a tool result is added to an answer with. The result is reduced
+// first: to the fields the call asked for (results.fields for the items of the list tools when it
+// asked for none), then its long lists are cut to results.max_items and results.max_bytes. With
+// results.summarize, a result still larger than results.summarize_above is replaced by a summary
+// the model writes; when the model fails the result is added as JSON.
+func (s *Server) renderResult(ctx context.Context, toolCall llm.ToolCall, model string, result interface{}) string {
+	text := s.reduceResult(toolCall, result)
+
+	if s.config.Results.Summarize && len(text) > s.config.Results.SummarizeAbove {
+		summary, err := s.llmClient.Complete(ctx, model, resultSummaryPrompt, fmt.Sprintf("Result of %s:\n%s", toolCall.Function.Name, text))
+		if err == nil && strings.TrimSpace(summary) != "" {
+			return fmt.Sprintf("\n\n%s\nSummary of the %d-byte result of %s: %s", toolResultMarker, len(text), toolCall.Function.Name, strings.TrimSpace(summary))
+		}
+		requestid.Logger(ctx, s.logger).Warn("Failed to summarize a tool result, adding it as it is",
+			zap.String("tool", toolCall.Function.Name),
+			zap.Int("bytes", len(text)),
+			zap.Error(err))
+	}
+	return fmt.Sprintf("\n\n%s\n```json\n%s\n```", toolResultMarker, text)
+}
+
+// reduceResult projects a tool result and cuts its long lists, returning it as JSON
+func (s *Server) reduceResult(toolCall llm.ToolCall, result interface{}) string {
+	maxItems, maxBytes := s.config.Results.MaxItems, s.config.Results.MaxBytes
+	if maxItems <= 0 {
+		maxItems = maxResultItems
+	}
+	if maxBytes <= 0 {
+		maxBytes = maxResultBytes
+	}
+	return formatResult(truncateResult(toolCall, projectResult(toolCall, result, s.config.Results.Fields), maxItems, maxBytes))
+}
+
+// projectResult keeps the fields the call asked for in its fields argument of a result object,
+// or of the objects of a list or controller collection. Without requested fields, the objects
+// listed by the list tools are projected to defaults, when set. Names and UUIDs are always kept;
+// results with nothing to project are returned as they are.
+func projectResult(toolCall llm.ToolCall, result interface{}, defaults []string) interface{} {
+	var fields []string
+	if requested, ok := llm.ArgString(toolCall.Args, "fields"); ok {
+		for _, field := range strings.Split(requested, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				fields = append(fields, field)
+			}
+		}
+	}
+	listsOnly := false
+	if len(fields) == 0 {
+		if len(defaults) == 0 || !llm.PagedTools[toolCall.Function.Name] {
+			return result
+		}
+		fields, listsOnly = defaults, true
+	}
+	keep := make(map[string]bool, len(fields)+len(identityFields))
+	for _, field := range fields {
+		keep[field] = true
+	}
+	for _, field := range identityFields {
+		keep[field] = true
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return result
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return result
+	}
+	switch value := value.(type) {
+	case []interface{}:
+		return projectItems(value, keep)
+	case map[string]interface{}:
+		// A controller collection lists its objects under results
+		if list, ok := value["results"].([]interface{}); ok {
+			value["results"] = projectItems(list, keep)
+			return value
+		}
+		if !listsOnly {
+			return projectObject(value, keep)
+		}
+	}
+	return result
+}
+
+// projectItems projects the objects of a list
+func projectItems(items []interface{}, keep map[string]bool) []interface{} {
+	projected := make([]interface{}, len(items))
+	for i, item := range items {
+		if object, ok := item.(map[string]interface{}); ok {
+			projected[i] = projectObject(object, keep)
+		} else {
+			projected[i] = item
+		}
+	}
+	return projected
+}
+
+// projectObject returns the kept fields of an object
+func projectObject(object map[string]interface{}, keep map[string]bool) map[string]interface{} {
+	projected := make(map[string]interface{}, len(keep))
+	for key, field := range object {
+		if keep[key] {
+			projected[key] = field
+		}
+	}
+	return projected
+}
+
+// newToolResult starts the typed result of a tool call
+func newToolResult(toolCall llm.ToolCall, status string) llm.ToolResult {
+	return llm.ToolResult{Tool: toolCall.Function.Name, InvocationID: toolCall.InvocationID, Arguments: toolArgs(toolCall), Status: status}
+}
+
+// toolArgs returns the arguments of a tool call, parsed from the call when it wasn't
+func toolArgs(toolCall llm.ToolCall) map[string]interface{} {
+	if toolCall.Args != nil {
+		return toolCall.Args
+	}
+	return llm.ParseToolArguments(toolCall.Function.Arguments)
+}
+
+// resultBlock is a tool result read back from an answer: its data, or the summary added instead
+type resultBlock struct {
+	data    interface{}
+	summary string
+}
+
+// resultBlockEnds start what chat.Run adds to an answer after a summarized result
+var resultBlockEnds = []string{"\n\n" + toolResultMarker + "\n", "\n\nTool error (", "\n\nAwaiting approval (", "\n\nAwaiting input ("}
+
+// extractToolResults removes the tool results renderResult added to an answer, returning the
+// answer without them and the results in order. Results that aren't valid JSON any more (cut
+// by a redaction, say) are returned as text.
+func extractToolResults(message string) (string, []resultBlock) {
+	start := "\n\n" + toolResultMarker + "\n"
+	var text strings.Builder
+	var blocks []resultBlock
+	for {
+		i := strings.Index(message, start)
+		if i < 0 {
+			break
+		}
+		text.WriteString(message[:i])
+		rest := message[i+len(start):]
+		var block resultBlock
+		if body, ok := strings.CutPrefix(rest, "```json\n"); ok {
+			end := strings.Index(body, "\n```")
+			if end < 0 {
+				end = len(body)
+			}
+			var data interface{}
+			if err := json.Unmarshal([]byte(body[:end]), &data); err != nil {
+				data = body[:end]
+			}
+			block.data = data
+			message = strings.TrimPrefix(body[end:], "\n```")
+		} else {
+			end := len(rest)
+			for _, next := range resultBlockEnds {
+				if j := strings.Index(rest, next); j >= 0 && j < end {
+					end = j
+				}
+			}
+			block.summary = strings.TrimSpace(rest[:end])
+			if _, summary, ok := strings.Cut(block.summary, ": "); ok && strings.HasPrefix(block.summary, "Summary of the ") {
+				block.summary = summary
+			}
+			message = rest[end:]
+		}
+		blocks = append(blocks, block)
+	}
+	text.WriteString(message)
+	return text.String(), blocks
+}
@@ -0,0 +1,301 @@
+package web
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"aviagent/internal/notify"
+	"aviagent/internal/workflow"
+	"aviagent/pkg/agent/chat"
+	"aviagent/pkg/audit"
+	"aviagent/pkg/config"
+	"aviagent/pkg/llm"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// workflowRunbook is the kind of the workflow runs recording runbook triggers
+const workflowRunbook = "runbook"
+
+// runbookTimeout bounds a runbook run, all steps included
+const runbookTimeout = 10 * time.Minute
+
+// maxRunbookBody limits the size of a runbook trigger body
+const maxRunbookBody = 64 << 10
+
+// runbookRequest is the body of a runbook trigger
+type runbookRequest struct {
+	Parameters map[string]string `json:"parameters"`
+	Session    string            `json:"session,omitempty"` // chat session the steps are sent to, a new one when empty
+}
+
+// runbookInput is the input recorded with a runbook run, to resume it
+type runbookInput struct {
+	Parameters map[string]string `json:"parameters"`
+	Session    string            `json:"session"`
+	Model      string            `json:"model"`
+}
+
+// runbookStep is the answer to a step of a runbook
+type runbookStep struct {
+	Message   string          `json:"message"`
+	Answer    string          `json:"answer,omitempty"`
+	ToolCalls []string        `json:"tool_calls,omitempty"`
+	Receipts  []audit.Receipt `json:"receipts,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// runbookOutcome is the outcome of a runbook run, returned to triggers that wait for it and sent
+// to the notification channels
+type runbookOutcome struct {
+	Runbook  string        `json:"runbook"`
+	Workflow string        `json:"workflow"` // the run recording the steps, see /api/v1/workflows/{id}
+	Session  string        `json:"session"`
+	State    string        `json:"state"`            // state of the workflow run
+	Steps    []runbookStep `json:"steps,omitempty"`  // the steps run by this trigger
+	Answer   string        `json:"answer,omitempty"` // the answer to the last step
+	Error    string        `json:"error,omitempty"`
+}
+
+// runbookInfo describes a configured runbook
+type runbookInfo struct {
+	Name         string   `json:"name"`
+	Description  string   `json:"description,omitempty"`
+	Parameters   []string `json:"parameters"`
+	Steps        int      `json:"steps"`
+	AllowChanges bool     `json:"allow_changes"`
+}
+
+// runbooksResponse lists the configured runbooks
+type runbooksResponse struct {
+	Runbooks []runbookInfo `json:"runbooks"`
+}
+
+// handleListRunbooks lists the configured runbooks with the parameters their triggers pass
+func (s *Server) handleListRunbooks(c *gin.Context) {
+	runbooks := make([]runbookInfo, 0, len(s.config.Runbooks.Playbooks))
+	for _, runbook := range s.config.Runbooks.Playbooks {
+		runbooks = append(runbooks, runbookInfo{
+			Name:         runbook.Name,
+			Description:  runbook.Description,
+			Parameters:   append([]string{}, runbook.Parameters...),
+			Steps:        len(runbook.Steps),
+			AllowChanges: runbook.AllowChanges,
+		})
+	}
+	c.JSON(http.StatusOK, runbooksResponse{Runbooks: runbooks})
+}
+
+// handleTriggerRunbook runs a runbook for an external system such as monitoring or CI: its steps
+// are sent as chat messages to a session and recorded as a workflow run. The outcome is sent to
+// the notification channels; with ?wait=true it is also returned, otherwise the trigger is
+// answered once the run has started.
+func (s *Server) handleTriggerRunbook(c *gin.Context) {
+	runbook, ok := s.config.Runbooks.Playbook(c.Param("name"))
+	if !ok {
+		c.JSON(http.StatusNotFound, errorResponse{Error: fmt.Sprintf("runbook %s not found", c.Param("name"))})
+		return
+	}
+	token := c.GetHeader("X-Runbook-Token")
+	if token == "" {
+		token = c.Query("token")
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.config.Runbooks.Token)) != 1 {
+		c.JSON(http.StatusUnauthorized, errorResponse{Error: "invalid runbook token"})
+		return
+	}
+
+	var req runbookRequest
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxRunbookBody))
+	if err == nil && len(strings.TrimSpace(string(body))) > 0 {
+		err = json.Unmarshal(body, &req)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid runbook trigger: %v", err)})
+		return
+	}
+	messages, err := renderRunbook(runbook, req.Parameters)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	model := runbook.Model
+	if model == "" {
+		model = s.defaultModel()
+	}
+	session := s.sessions.GetOrCreate(req.Session, model)
+	input := runbookInput{Parameters: req.Parameters, Session: session.ID, Model: model}
+	run, err := s.workflows.Start(workflowRunbook, runbook.Name, "runbook:"+runbook.Name, input, messages)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+	s.logger.Info("Runbook triggered",
+		zap.String("runbook", runbook.Name),
+		zap.String("workflow", run.ID),
+		zap.String("session", session.ID))
+
+	remoteAddr := c.ClientIP()
+	if c.Query("wait") != "true" {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), runbookTimeout)
+			defer cancel()
+			s.runRunbook(ctx, runbook, run.ID, remoteAddr)
+		}()
+		c.JSON(http.StatusAccepted, runbookOutcome{Runbook: runbook.Name, Workflow: run.ID, Session: session.ID, State: run.State})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), runbookTimeout)
+	defer cancel()
+	outcome := s.runRunbook(ctx, runbook, run.ID, remoteAddr)
+	if outcome.State != workflow.StateCompleted {
+		c.JSON(http.StatusBadGateway, outcome)
+		return
+	}
+	c.JSON(http.StatusOK, outcome)
+}
+
+// renderRunbook renders the steps of a runbook with the parameters of a trigger, which must pass
+// every parameter the runbook declares
+func renderRunbook(runbook config.Runbook, parameters map[string]string) ([]string, error) {
+	var missing []string
+	for _, name := range runbook.Parameters {
+		if _, ok := parameters[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("runbook %s requires the parameters %s", runbook.Name, strings.Join(missing, ", "))
+	}
+	if parameters == nil {
+		parameters = map[string]string{}
+	}
+
+	messages := make([]string, len(runbook.Steps))
+	for i, step := range runbook.Steps {
+		tmpl, err := template.New(runbook.Name).Option("missingkey=error").Parse(step)
+		if err != nil {
+			return nil, fmt.Errorf("runbook %s: invalid step %d: %w", runbook.Name, i+1, err)
+		}
+		var message strings.Builder
+		if err := tmpl.Execute(&message, parameters); err != nil {
+			return nil, fmt.Errorf("runbook %s: step %d: %w", runbook.Name, i+1, err)
+		}
+		messages[i] = strings.TrimSpace(message.String())
+	}
+	return messages, nil
+}
+
+// runRunbook sends the pending steps of a runbook run to its chat session, one turn per step,
+// and stops at the first step that fails. Tool calls changing configuration are run only when
+// the runbook allows changes; they are audited under the run's operator. The outcome is sent to
+// the notification channels.
+func (s *Server) runRunbook(ctx context.Context, runbook config.Runbook, runID, remoteAddr string) runbookOutcome {
+	run, _ := s.workflows.Get(runID)
+	var input runbookInput
+	_ = json.Unmarshal(run.Input, &input)
+	outcome := runbookOutcome{Runbook: runbook.Name, Workflow: runID, Session: input.Session}
+
+	ctx = audit.WithActor(ctx, audit.Actor{Operator: run.Operator, RemoteAddr: remoteAddr})
+	ctx = chat.WithHooks(ctx, chat.Hooks{Confirm: func(llm.ToolCall) bool { return runbook.AllowChanges }, Unattended: true})
+	for i := run.NextStep(); i >= 0 && i < len(run.Steps); i++ {
+		step := runbookStep{Message: run.Steps[i].Name}
+		result, err := s.Chat(ctx, input.Session, input.Model, step.Message)
+		if err != nil {
+			step.Error = err.Error()
+			outcome.Steps = append(outcome.Steps, step)
+			outcome.Error = fmt.Sprintf("step %d: %v", i+1, err)
+			if ctx.Err() != nil {
+				s.workflows.Interrupt(runID)
+			} else {
+				s.workflows.SetStep(runID, i, workflow.StepFailed, "", err)
+			}
+			break
+		}
+		step.Answer, step.ToolCalls, step.Receipts = result.Message, toolCallNames(result.ToolCalls), result.Receipts
+		outcome.Steps = append(outcome.Steps, step)
+		outcome.Answer = result.Message
+		s.workflows.SetStep(runID, i, workflow.StepDone, result.Message, nil)
+	}
+
+	if finished, ok := s.workflows.Get(runID); ok {
+		outcome.State = finished.State
+	}
+	s.logger.Info("Runbook finished",
+		zap.String("runbook", runbook.Name),
+		zap.String("workflow", runID),
+		zap.String("state", outcome.State),
+		zap.String("error", outcome.Error))
+
+	// The run's context may have expired, the outcome is still sent
+	notifyCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	s.notifyRunbook(notifyCtx, runbook, outcome)
+	return outcome
+}
+
+// notifyRunbook sends the outcome of a runbook run to the runbook's channels, or publishes it to
+// the channels subscribed to runbook events when it names none
+func (s *Server) notifyRunbook(ctx context.Context, runbook config.Runbook, outcome runbookOutcome) {
+	event := notify.Event{
+		Type:     notify.EventRunbookDone,
+		Title:    fmt.Sprintf("Runbook %s completed", runbook.Name),
+		Summary:  outcome.Answer,
+		Severity: notify.SeverityInfo,
+		Source:   "runbook/" + runbook.Name,
+		Time:     time.Now().UTC(),
+		Data:     outcome,
+	}
+	if outcome.State != workflow.StateCompleted {
+		event.Type = notify.EventRunbookFailed
+		event.Title = fmt.Sprintf("Runbook %s %s", runbook.Name, outcome.State)
+		event.Summary = outcome.Error
+		event.Severity = notify.SeverityWarning
+	}
+
+	if len(runbook.Channels) == 0 {
+		// Publish logs the channels that failed
+		_ = s.notifier.Publish(ctx, event)
+		return
+	}
+	for _, channel := range runbook.Channels {
+		if err := s.notifier.Send(ctx, channel, event); err != nil {
+			s.logger.Warn("Notification failed",
+				zap.String("channel", channel),
+				zap.String("event", event.Type),
+				zap.Error(err))
+		}
+	}
+}
+
+// resumeRunbook runs the remaining steps of a runbook run in the background and returns the run
+// as resumed
+func (s *Server) resumeRunbook(ctx context.Context, run workflow.Run) (interface{}, error) {
+	runbook, ok := s.config.Runbooks.Playbook(run.Subject)
+	if !ok {
+		return nil, fmt.Errorf("runbook %s of workflow run %s is no longer configured", run.Subject, run.ID)
+	}
+	resumed, err := s.workflows.Resume(run.ID)
+	if err != nil {
+		return nil, err
+	}
+	remoteAddr := audit.ActorFrom(ctx).RemoteAddr
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), runbookTimeout)
+		defer cancel()
+		s.runRunbook(ctx, runbook, run.ID, remoteAddr)
+	}()
+	return summarizeWorkflow(resumed), nil
+}
@@ -2,18 +2,41 @@ package web
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"html/template"
 	"io"
+	"math/rand"
+	"mime"
 	"net/http"
-	"os"
+	"reflect"
+	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
-	"aviagent/internal/avi"
-	"aviagent/internal/config"
-	"aviagent/internal/llm"
-	"aviagent/internal/mistral"
+	"aviagent/internal/alerts"
+	"aviagent/internal/approvals"
+	"aviagent/internal/blueprint"
+	"aviagent/internal/changes"
+	"aviagent/internal/drift"
+	"aviagent/internal/insights"
+	"aviagent/internal/logging"
+	"aviagent/internal/memory"
+	"aviagent/internal/moderation"
+	"aviagent/internal/notify"
+	"aviagent/internal/requestid"
+	"aviagent/internal/sandbox"
+	"aviagent/internal/scheduler"
+	"aviagent/internal/style"
+	"aviagent/internal/terraform"
+	"aviagent/internal/workflow"
+	"aviagent/pkg/agent/chat"
+	"aviagent/pkg/audit"
+	"aviagent/pkg/avi"
+	"aviagent/pkg/config"
+	"aviagent/pkg/llm"
+	"aviagent/pkg/mistral"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -24,6 +47,8 @@ type LLMClient interface {
 	GetAvailableModels() []string
 	ValidateModel(ctx context.Context, modelName string) (bool, error)
 	ProcessNaturalLanguageQuery(ctx context.Context, query, model string, tools interface{}, conversationHistory interface{}) (*llm.LLMResponse, error)
+	RenderPrompt(ctx context.Context, query, model string, tools interface{}, conversationHistory interface{}) (interface{}, error)
+	llm.Completer
 }
 
 // convertMistralToolCalls converts Mistral ToolCalls to LLM ToolCalls
@@ -60,17 +85,43 @@ type AviClientInterface interface {
 	CreateVirtualService(ctx context.Context, data map[string]interface{}) (interface{}, error)
 	UpdateVirtualService(ctx context.Context, uuid string, data map[string]interface{}) (interface{}, error)
 	DeleteVirtualService(ctx context.Context, uuid string) error
+	SetVirtualServiceEnabled(ctx context.Context, uuid string, enabled bool) (interface{}, error)
+	VirtualServiceAction(ctx context.Context, uuid, action string, body map[string]interface{}) (interface{}, error)
 	ListPools(ctx context.Context, params map[string]string) (interface{}, error)
 	GetPool(ctx context.Context, uuid string, params map[string]string) (interface{}, error)
 	CreatePool(ctx context.Context, data map[string]interface{}) (interface{}, error)
+	UpdatePool(ctx context.Context, uuid string, data map[string]interface{}) (interface{}, error)
+	DeletePool(ctx context.Context, uuid string) error
 	ScaleOutPool(ctx context.Context, uuid string, params map[string]interface{}) error
 	ScaleInPool(ctx context.Context, uuid string, params map[string]interface{}) error
+	SetPoolServerEnabled(ctx context.Context, uuid, ip string, port int, enabled bool) (interface{}, error)
 	ListHealthMonitors(ctx context.Context, params map[string]string) (interface{}, error)
 	GetHealthMonitor(ctx context.Context, uuid string, params map[string]string) (interface{}, error)
+	CreateHealthMonitor(ctx context.Context, data map[string]interface{}) (interface{}, error)
+	UpdateHealthMonitor(ctx context.Context, uuid string, data map[string]interface{}) (interface{}, error)
+	DeleteHealthMonitor(ctx context.Context, uuid string) error
+	ListApplicationProfiles(ctx context.Context, params map[string]string) (interface{}, error)
+	GetApplicationProfile(ctx context.Context, uuid string, params map[string]string) (interface{}, error)
+	CreateApplicationProfile(ctx context.Context, data map[string]interface{}) (interface{}, error)
+	AttachApplicationProfile(ctx context.Context, vsUUID, profileUUID string) (interface{}, error)
+	ListPersistenceProfiles(ctx context.Context, params map[string]string) (interface{}, error)
+	GetPersistenceProfile(ctx context.Context, uuid string, params map[string]string) (interface{}, error)
+	CreatePersistenceProfile(ctx context.Context, data map[string]interface{}) (interface{}, error)
+	AttachPersistenceProfile(ctx context.Context, vsUUID, poolUUID, profileUUID string) (interface{}, error)
 	ListServiceEngines(ctx context.Context, params map[string]string) (interface{}, error)
 	GetServiceEngine(ctx context.Context, uuid string, params map[string]string) (interface{}, error)
+	ServiceEngineAction(ctx context.Context, uuid, action string, body map[string]interface{}) (interface{}, error)
+	ListVRFContexts(ctx context.Context, params map[string]string) (interface{}, error)
+	GetVRFContext(ctx context.Context, uuid string, params map[string]string) (interface{}, error)
+	GetBGPPeerStatus(ctx context.Context, seUUID string, params map[string]string) (interface{}, error)
+	ListBackups(ctx context.Context, params map[string]string) (interface{}, error)
+	TriggerBackup(ctx context.Context, configUUID string) (interface{}, error)
+	ExportConfiguration(ctx context.Context, params map[string]string) (interface{}, error)
 	GetAnalytics(ctx context.Context, resourceType, uuid string, params map[string]string) (interface{}, error)
+	GetInventory(ctx context.Context, resourceType, uuid string, params map[string]string) (interface{}, error)
+	GetHealthScore(ctx context.Context, resourceType, uuid string, params map[string]string) (interface{}, error)
 	ExecuteGenericOperation(ctx context.Context, method, endpoint string, body interface{}, params map[string]string) (interface{}, error)
+	Download(ctx context.Context, endpoint string, params map[string]string) (*avi.Download, error)
 	Close() error
 }
 
@@ -79,8 +130,32 @@ type Server struct {
 	config        *config.Config
 	logger        *zap.Logger
 	aviClient     AviClientInterface
+	aviUsers      *userClients // operators' own Avi accounts, nil when everyone uses the agent's account
 	llmClient      LLMClient
 	mistralClient *mistral.Client
+	fallbackClients map[string]LLMClient // clients of the other providers of fallback models, by provider
+	sessions      *SessionStore
+	modelRouter   *llm.ModelRouter
+	tools         *llm.Tools // the tools offered to the model, built-in ones when nil
+	clockSkew     *avi.ClockSkewChecker
+	auditLog      *audit.Log
+	permissions   *avi.Permissions
+	moderator     *moderation.Moderator
+	styleGuide    *style.Guide
+	insights      *insights.Store
+	scheduler     *scheduler.Scheduler // scheduled reports, nil when disabled
+	notifier      *notify.Notifier     // outbound webhook channels
+	alerts        *alerts.Store        // received controller alerts, nil when the receiver is disabled
+	workflows     *workflow.Store      // progress of multi-step runs, for resuming interrupted ones
+	memory        *memory.Store        // durable facts about the environment, nil when disabled
+	approvals     *approvals.Store     // changes waiting for a second person, nil without approval rules
+	changes       *changes.Store       // objects before and after each write, for diffs and rollbacks
+	drift         *drift.Detector      // compares the declared configuration files with the controller, nil when disabled
+	blueprints    *blueprint.Library   // application patterns apply_blueprint creates
+	logLevels     *logging.Levels      // runtime log level and debugged sessions, nil until SetLogLevels
+	simulations   *simulationInventories // inventory snapshots of "what if" simulations
+	sandbox       *sandbox.Controller // training mode controller, nil when connected to a real controller
+	uiUnavailable string              // why the web UI isn't served, empty when it is
 	router        *gin.Engine
 }
 
@@ -95,26 +170,95 @@ type ChatMessage struct {
 }
 
 // ChatSession represents a chat session
+
 type ChatSession struct {
-	ID       string        `json:"id"`
-	Model    string        `json:"model"`
-	Messages []ChatMessage `json:"messages"`
-	Created  time.Time     `json:"created"`
+	ID       string            `json:"id"`
+	Model    string            `json:"model"`
+	Messages []ChatMessage     `json:"messages"`
+	Created  time.Time         `json:"created"`
+	Usage    chat.SessionUsage `json:"usage"`
+	Seed     *int              `json:"seed,omitempty"`    // sampling seed applied to every turn of the session
+	Notes    []SessionNote     `json:"notes,omitempty"`   // facts kept for the session and sent with every turn
+	Summary  *HistorySummary   `json:"summary,omitempty"` // summary of the older messages, sent in their place
+}
+
+// chatRequest is the body of POST /api/v1/chat
+type chatRequest struct {
+	Message string `json:"message" binding:"required"`
+	Model   string `json:"model"`
+	Session string `json:"session"`
+	Seed    *int   `json:"seed"` // kept for the rest of the session, a negative value clears it
+}
+
+// chatResponse is the /api/v1/chat response: the LLM response plus session accounting
+type chatResponse struct {
+	*llm.LLMResponse
+	Session      string            `json:"session"`
+	SessionUsage chat.SessionUsage `json:"session_usage"`
+	Route        *llm.ModelRoute   `json:"route,omitempty"`
+	Seed         *int              `json:"seed,omitempty"`
 }
 
 // NewServer creates a new web server
 func NewServer(cfg *config.Config, logger *zap.Logger) (*Server, error) {
+	return newServer(cfg, logger, nil)
+}
+
+// newServer creates a web server. When it replaces a previous server after a configuration
+// reload, the components holding state, and the clients whose configuration didn't change, are
+// taken over from it.
+func newServer(cfg *config.Config, logger *zap.Logger, previous *Server) (*Server, error) {
+	// In training mode the agent talks to the bundled mock controller instead of the configured one
+	var sandboxController *sandbox.Controller
+	if cfg.Sandbox.Enabled {
+		if previous != nil && previous.sandbox != nil {
+			sandboxController = previous.sandbox
+		} else {
+			var err error
+			sandboxController, err = sandbox.NewController(logger)
+			if err != nil {
+				return nil, fmt.Errorf("failed to start sandbox controller: %w", err)
+			}
+			logger.Warn("Training mode enabled, using the sandbox controller with sample data")
+		}
+		sandboxController.Configure(&cfg.Avi)
+	}
+
 	// Initialize Avi client using official SDK
-	aviClient, err := avi.NewOfficialClient(&cfg.Avi, logger)
-	if err != nil {
-		return nil, fmt.Errorf("failed to initialize Avi client: %w", err)
+	var aviClient AviClientInterface
+	if previous != nil && reflect.DeepEqual(previous.config.Avi, cfg.Avi) {
+		aviClient = previous.sharedAviClient()
+	} else {
+		officialClient, err := avi.NewOfficialClient(&cfg.Avi, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize Avi client: %w", err)
+		}
+		aviClient = officialClient
+	}
+
+	// Operators mapped to their own Avi accounts call the controller with their own sessions
+	sharedClient := aviClient
+	var aviUsers *userClients
+	if cfg.AviUsers.Mode != "" && cfg.Sandbox.Enabled {
+		logger.Warn("Training mode uses the sandbox account, avi_users is ignored")
+	} else if cfg.AviUsers.Mode != "" {
+		if previous != nil && previous.aviUsers != nil && previous.aviUsers.shared == aviClient && reflect.DeepEqual(previous.config.AviUsers, cfg.AviUsers) {
+			aviUsers = previous.aviUsers
+		} else {
+			aviUsers = newUserClients(aviClient, cfg.Avi, cfg.AviUsers, logger)
+		}
+		aviClient = aviUsers
 	}
 
 	// Initialize the appropriate LLM client based on provider
 	var llmClient LLMClient
 	var mistralClient *mistral.Client
+	var err error
 
-	if cfg.Provider == "ollama" {
+	if previous != nil && previous.config.Provider == cfg.Provider && reflect.DeepEqual(previous.config.LLM, cfg.LLM) && reflect.DeepEqual(previous.config.Mistral, cfg.Mistral) {
+		llmClient = previous.llmClient
+		mistralClient = previous.mistralClient
+	} else if cfg.Provider == "ollama" {
 		// Initialize Ollama client
 		ollamaClient, err := llm.NewClient(&cfg.LLM, logger)
 		if err != nil {
@@ -134,16 +278,175 @@ func NewServer(cfg *config.Config, logger *zap.Logger) (*Server, error) {
 		return nil, fmt.Errorf("unsupported LLM provider: %s", cfg.Provider)
 	}
 
+	fallbackClients, err := newFallbackClients(cfg, logger, previous)
+	if err != nil {
+		return nil, err
+	}
+
+	var auditLog *audit.Log
+	if previous != nil && reflect.DeepEqual(previous.config.Audit, cfg.Audit) {
+		auditLog = previous.auditLog
+	} else if auditLog, err = audit.NewLog(cfg.Audit, logger); err != nil {
+		return nil, fmt.Errorf("failed to initialize audit log: %w", err)
+	}
+
+	moderator, err := moderation.NewModerator(cfg.Moderation, llmClient, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize answer moderation: %w", err)
+	}
+
+	styleGuide, err := style.NewGuide(cfg.Style, cfg.Avi.Tenant)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize the answer style guide: %w", err)
+	}
+
+	var insightStore *insights.Store
+	if previous != nil && reflect.DeepEqual(previous.config.Insights, cfg.Insights) {
+		insightStore = previous.insights
+	} else if insightStore, err = insights.NewStore(cfg.Insights, logger); err != nil {
+		return nil, fmt.Errorf("failed to initialize insight tracking: %w", err)
+	}
+
+	notifier, err := notify.New(cfg.Notifications, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize notification channels: %w", err)
+	}
+
+	var alertStore *alerts.Store
+	if cfg.Alerts.Enabled {
+		if previous != nil && previous.alerts != nil && reflect.DeepEqual(previous.config.Alerts, cfg.Alerts) {
+			alertStore = previous.alerts
+		} else if alertStore, err = alerts.NewStore(cfg.Alerts, logger); err != nil {
+			return nil, fmt.Errorf("failed to initialize the alert receiver: %w", err)
+		}
+	}
+
+	var workflowStore *workflow.Store
+	if previous != nil && reflect.DeepEqual(previous.config.Workflows, cfg.Workflows) {
+		workflowStore = previous.workflows
+	} else if workflowStore, err = workflow.NewStore(cfg.Workflows, logger); err != nil {
+		return nil, fmt.Errorf("failed to initialize workflow tracking: %w", err)
+	}
+
+	var memoryStore *memory.Store
+	if cfg.Memory.Enabled {
+		if previous != nil && previous.memory != nil && previous.config.Memory.StateFile == cfg.Memory.StateFile && previous.config.Memory.MaxFacts == cfg.Memory.MaxFacts {
+			memoryStore = previous.memory
+		} else if memoryStore, err = memory.NewStore(cfg.Memory, logger); err != nil {
+			return nil, fmt.Errorf("failed to initialize the deployment memory: %w", err)
+		}
+	}
+
+	var approvalStore *approvals.Store
+	if len(cfg.Approvals.Rules) > 0 {
+		if previous != nil && previous.approvals != nil && previous.config.Approvals.StateFile == cfg.Approvals.StateFile && previous.config.Approvals.MaxRequests == cfg.Approvals.MaxRequests {
+			approvalStore = previous.approvals
+		} else if approvalStore, err = approvals.NewStore(cfg.Approvals, logger); err != nil {
+			return nil, fmt.Errorf("failed to initialize the approval queue: %w", err)
+		}
+	}
+
+	var changeStore *changes.Store
+	if previous != nil && previous.changes != nil && previous.config.Changes == cfg.Changes {
+		changeStore = previous.changes
+	} else if changeStore, err = changes.NewStore(cfg.Changes, logger); err != nil {
+		return nil, fmt.Errorf("failed to initialize the change history: %w", err)
+	}
+
+	blueprints, err := blueprint.Load(cfg.Blueprints.Directory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load blueprints: %w", err)
+	}
+
+	var driftDetector *drift.Detector
+	if cfg.Drift.Enabled {
+		if driftDetector, err = drift.New(cfg.Drift, aviClient, notifier, logger); err != nil {
+			return nil, fmt.Errorf("failed to initialize drift detection: %w", err)
+		}
+	}
+
+	var reportScheduler *scheduler.Scheduler
+	if cfg.Scheduler.Enabled {
+		reportScheduler, err = scheduler.New(cfg.Scheduler, aviClient, notifier, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize report scheduler: %w", err)
+		}
+	}
+
+	sessions := NewSessionStore(cfg.Pricing)
+	clockSkew := avi.NewClockSkewChecker(&cfg.Avi, logger)
+	simulations := newSimulationInventories(time.Duration(cfg.Simulation.InventoryTTL) * time.Second)
+	if previous != nil {
+		sessions = previous.sessions
+		sessions.setPricing(cfg.Pricing)
+		if previous.sharedAviClient() == sharedClient {
+			clockSkew = previous.clockSkew
+			if previous.config.Simulation == cfg.Simulation {
+				simulations = previous.simulations
+			}
+		}
+	}
+
+	tools, err := llm.NewTools(cfg.Tools)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tools configuration: %w", err)
+	}
+
 	server := &Server{
 		config:        cfg,
 		logger:        logger,
 		aviClient:     aviClient,
+		aviUsers:      aviUsers,
 		llmClient:      llmClient,
 		mistralClient: mistralClient,
+		fallbackClients: fallbackClients,
+		sessions:      sessions,
+		modelRouter:   llm.NewModelRouter(cfg.Routing),
+		tools:         tools,
+		clockSkew:     clockSkew,
+		auditLog:      auditLog,
+		moderator:     moderator,
+		styleGuide:    styleGuide,
+		insights:      insightStore,
+		scheduler:     reportScheduler,
+		notifier:      notifier,
+		alerts:        alertStore,
+		workflows:     workflowStore,
+		memory:        memoryStore,
+		approvals:     approvalStore,
+		changes:       changeStore,
+		drift:         driftDetector,
+		blueprints:    blueprints,
+		logLevels:     previousLogLevels(previous),
+		simulations:   simulations,
+		sandbox:       sandboxController,
+	}
+
+	if cfg.Avi.LeastPrivilege {
+		if previous != nil && previous.sharedAviClient() == sharedClient && previous.permissions != nil {
+			server.permissions = previous.permissions
+		} else {
+			server.loadPermissions()
+		}
 	}
 
 	// Initialize router
 	server.setupRouter()
+	server.logCapabilities()
+
+	// Reports run from one scheduler at a time
+	if previous != nil && previous.scheduler != nil {
+		previous.scheduler.Stop()
+	}
+	if reportScheduler != nil {
+		reportScheduler.Start()
+	}
+	if previous != nil && previous.drift != nil {
+		previous.drift.Stop()
+	}
+	if driftDetector != nil {
+		driftDetector.Start()
+	}
 
 	return server, nil
 }
@@ -160,59 +463,26 @@ func (s *Server) setupRouter() {
 	s.router = gin.New()
 
 	// Add middleware
-	s.router.Use(gin.Logger())
+	s.router.Use(requestIDMiddleware())
+	if s.config.Log.Access.Enabled {
+		s.router.Use(accessLogMiddleware(s.config.Log.Access, s.logger))
+	}
 	s.router.Use(gin.Recovery())
+	if s.config.Server.Compression.Enabled {
+		s.router.Use(compressionMiddleware(s.config.Server.Compression))
+	}
 	s.router.Use(s.corsMiddleware())
-
-	// Set up template functions
-	s.router.SetFuncMap(template.FuncMap{
-		"now": time.Now,
-		"split": strings.Split,
-		"hasPrefix": strings.HasPrefix,
-		"hasSuffix": strings.HasSuffix,
-		"substr": func(s string, start int, length int) string {
-			if start < 0 {
-				start = 0
-			}
-			if start >= len(s) {
-				return ""
-			}
-			end := start + length
-			if end > len(s) {
-				end = len(s)
-			}
-			return s[start:end]
-		},
-		"sub": func(s string, start int, length int) string {
-			if start < 0 {
-				start = 0
-			}
-			if start >= len(s) {
-				return ""
-			}
-			end := start + length
-			if end > len(s) {
-				end = len(s)
-			}
-			return s[start:end]
-		},
-	})
-
-	// Load HTML templates
-	// In Docker: working directory is /web, so templates are at templates/*
-	// In local dev: working directory is project root, so templates are at web/templates/*
-	templatePath := "templates/*"
-	if _, err := os.Stat("web/templates"); err == nil {
-		templatePath = "web/templates/*"
+	s.router.Use(s.securityHeadersMiddleware())
+	s.router.Use(staticCacheMiddleware(s.config.Server.StaticMaxAge))
+	if s.aviUsers != nil {
+		s.router.Use(s.aviUserMiddleware())
 	}
-	s.router.LoadHTMLGlob(templatePath)
 
-	// Serve static files
-	staticPath := "static"
-	if _, err := os.Stat("web/static"); err == nil {
-		staticPath = "web/static"
+	// Load the web UI; without it the JSON API is still served
+	s.uiUnavailable = s.loadUI(templateFuncs())
+	if s.uiUnavailable != "" {
+		s.logger.Warn("Web UI not served, the JSON API is still available", zap.String("reason", s.uiUnavailable))
 	}
-	s.router.Static("/static", staticPath)
 
 	// Routes
 	s.setupRoutes()
@@ -220,34 +490,57 @@ func (s *Server) setupRouter() {
 
 // setupRoutes sets up all the routes
 func (s *Server) setupRoutes() {
-	// Main page
-	s.router.GET("/", s.handleIndex)
-
-	// API routes
-	api := s.router.Group("/api")
-	{
-		// Chat endpoints
-		api.POST("/chat", s.handleChat)
-		api.GET("/chat/history", s.handleChatHistory)
-		api.DELETE("/chat/history", s.handleClearHistory)
+	// Main page, or a 503 page for every UI route when the UI isn't served
+	if s.uiUnavailable != "" {
+		s.router.GET("/", s.handleUIUnavailable)
+		s.router.Any("/htmx/*path", s.handleUIUnavailable)
+		s.router.GET("/static/*path", s.handleUIUnavailable)
+	} else {
+		s.router.GET("/", s.handleIndex)
+	}
 
-		// Model management
-		api.GET("/models", s.handleGetModels)
-		api.POST("/models/validate", s.handleValidateModel)
+	// The JSON API, also served under the unversioned /api paths it had before for existing clients
+	s.registerAPI(s.router.Group("/api/" + apiVersion))
+	s.registerAPI(s.router.Group("/api", deprecatedAPIMiddleware()))
+
+	// Operating the agent itself, behind the admin token
+	if s.config.Admin.Token != "" {
+		admin := s.router.Group("/admin", s.adminAuthMiddleware())
+		{
+			admin.POST("/cache/flush", s.handleAdminFlushCache)
+			admin.GET("/sessions", s.handleAdminSessions)
+			admin.GET("/config", s.handleAdminConfig)
+			admin.GET("/tools", s.handleAdminTools)
+			// The rendered prompt holds the system prompt and a session's history
+			if s.config.Server.DebugEndpoints {
+				admin.POST("/debug/prompt", s.handlePromptDebug)
+			}
+		}
+	}
 
-		// Health check
-		api.GET("/health", s.handleHealth)
+	// OpenAI-compatible API, with the Avi tools executed server-side
+	openai := s.router.Group("/v1")
+	{
+		openai.POST("/chat/completions", s.handleChatCompletions)
+		openai.GET("/models", s.handleOpenAIModels)
+	}
 
-		// Avi API proxy (for direct API access)
-		api.Any("/avi/*path", s.handleAviProxy)
+	if s.uiUnavailable != "" {
+		return
 	}
 
 	// HTMX specific routes
 	htmx := s.router.Group("/htmx")
 	{
 		htmx.POST("/chat", s.handleHTMXChat)
+		htmx.GET("/chat/status", s.handleHTMXChatStatus)
 		htmx.GET("/models", s.handleHTMXModels)
 		htmx.GET("/history", s.handleHTMXHistory)
+		htmx.GET("/history/:session", s.handleHTMXSessionMessages)
+		htmx.GET("/digest", s.handleHTMXChangeDigest)
+		htmx.POST("/tools/invocations/:id/rerun", s.handleHTMXRerunInvocation)
+		htmx.POST("/plans/:id/approve", s.handleHTMXApprovePlan)
+		htmx.POST("/plans/:id/reject", s.handleHTMXRejectPlan)
 	}
 }
 
@@ -268,22 +561,30 @@ func (s *Server) handleIndex(c *gin.Context) {
 		"title":        "VMware Avi LLM Agent",
 		"models":       models,
 		"defaultModel": s.config.LLM.DefaultModel,
+		"autoModel":    s.modelRouter.Enabled(),
+		"sessionID":    newSessionID(),
+		"currency":     s.config.Pricing.Currency,
+		"sandbox":      s.sandbox != nil,
+		"ticketURL":    s.config.Server.TicketURL,
 	})
 }
 
 // handleChat handles chat API requests
 func (s *Server) handleChat(c *gin.Context) {
-	var request struct {
-		Message string `json:"message" binding:"required"`
-		Model   string `json:"model"`
-		Session string `json:"session"`
-	}
-
+	var request chatRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	if command, argument, ok := s.chatCommand(c.Request.Context(), request.Message); ok {
+		s.handleChatCommand(c, request, command, argument)
 		return
 	}
 
+	// Pick the model from the routing policy when none was requested explicitly
+	var route *llm.ModelRoute
+	request.Model, route = s.routeModel(request.Message, request.Model)
+
 	// Set default model if not specified
 	if request.Model == "" {
 		request.Model = s.config.LLM.DefaultModel
@@ -293,33 +594,51 @@ func (s *Server) handleChat(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
 	defer cancel()
 
-	validModel, err := s.llmClient.ValidateModel(ctx, request.Model)
+	validModel, err := s.validateModel(ctx, request.Model)
 	if err != nil {
 		s.logger.Error("Failed to validate model", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate model"})
+		c.JSON(http.StatusInternalServerError, errorResponse{Error: "Failed to validate model"})
 		return
 	}
 
 	if !validModel {
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Model '%s' is not available", request.Model)})
+		c.JSON(http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("Model '%s' is not available", request.Model)})
 		return
 	}
 
 	// Process the chat message
-	response, err := s.processChatMessage(ctx, request.Message, request.Model, nil)
+	session := s.sessions.GetOrCreate(request.Session, request.Model)
+	ctx = s.withActor(ctx, c, session.ID, request.Model)
+	if request.Seed != nil {
+		s.sessions.SetSeed(session.ID, *request.Seed)
+	}
+	ctx, seed := s.withSeed(ctx, session.ID, nil)
+	response, err := chat.Run(ctx, chatRuntime{s}, request.Message, request.Model, s.sessions.History(session.ID))
 	if err != nil {
-		s.logger.Error("Failed to process chat message", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process message"})
+		requestid.Logger(ctx, s.logger).Error("Failed to process chat message", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, errorResponse{Error: "Failed to process message", RequestID: requestid.From(ctx)})
 		return
 	}
 
-	c.JSON(http.StatusOK, response)
+	usage := s.sessions.RecordUsage(session.ID, answeringModel(response, request.Model), response.Usage)
+	s.sessions.AppendExchange(session.ID, request.Model, request.Message, response.Message, toolCallNames(response.ToolCalls))
+
+	// The history keeps the results for the model; API clients get them in tool_results
+	response.Message, _ = extractToolResults(response.Message)
+	c.JSON(http.StatusOK, chatResponse{
+		LLMResponse:  response,
+		Session:      session.ID,
+		SessionUsage: usage,
+		Route:        route,
+		Seed:         seed,
+	})
 }
 
 // handleHTMXChat handles HTMX chat requests
 func (s *Server) handleHTMXChat(c *gin.Context) {
 	message := c.PostForm("message")
 	model := c.PostForm("model")
+	sessionID := c.PostForm("session")
 
 	if message == "" {
 		c.HTML(http.StatusBadRequest, "chat.html", gin.H{
@@ -327,41 +646,116 @@ func (s *Server) handleHTMXChat(c *gin.Context) {
 		})
 		return
 	}
+	if command, argument, ok := s.chatCommand(c.Request.Context(), message); ok {
+		s.handleHTMXChatCommand(c, message, model, sessionID, command, argument)
+		return
+	}
+
+	var route *llm.ModelRoute
+	model, route = s.routeModel(message, model)
 
 	if model == "" {
 		model = s.config.LLM.DefaultModel
 	}
+	if c.PostForm("mode") == "plan" {
+		s.handleHTMXChatPlan(c, message, model, sessionID)
+		return
+	}
 
 	// Process the chat message
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
 	defer cancel()
 
-	response, err := s.processChatMessage(ctx, message, model, nil)
+	session := s.sessions.GetOrCreate(sessionID, model)
+	ctx = s.withActor(ctx, c, session.ID, model)
+	if value := c.PostForm("seed"); value != "" {
+		seed, err := strconv.Atoi(value)
+		if err != nil {
+			c.HTML(http.StatusBadRequest, "chat.html", gin.H{"error": "Seed must be an integer"})
+			return
+		}
+		s.sessions.SetSeed(session.ID, seed)
+	}
+	ctx, _ = s.withSeed(ctx, session.ID, nil)
+	response, err := chat.Run(ctx, chatRuntime{s}, message, model, s.sessions.History(session.ID))
 	if err != nil {
-		s.logger.Error("Failed to process chat message", zap.Error(err))
+		requestid.Logger(ctx, s.logger).Error("Failed to process chat message", zap.Error(err))
 		c.HTML(http.StatusInternalServerError, "chat.html", gin.H{
-			"error": "Failed to process message: " + err.Error(),
+			"error":     "Failed to process message: " + err.Error(),
+			"requestID": requestid.From(ctx),
 		})
 		return
 	}
 
+	usage := s.sessions.RecordUsage(session.ID, answeringModel(response, model), response.Usage)
+	s.sessions.AppendExchange(session.ID, model, message, response.Message, toolCallNames(response.ToolCalls))
+
 	// Render the response as HTML
 	c.HTML(http.StatusOK, "chat.html", gin.H{
 		"userMessage":      message,
 		"assistantMessage": response.Message,
 		"model":           response.Model,
 		"toolCalls":       response.ToolCalls,
+		"notices":         response.Notices,
+		"downloads":       response.Downloads,
+		"receipts":        response.Receipts,
+		"approvals":       approvalSet(response.Approvals),
+		"route":           route,
 		"timestamp":       time.Now().Format("15:04:05"),
+		"sessionUsage":    usage,
 	})
 }
 
-// processChatMessage processes a chat message and returns a response
-func (s *Server) processChatMessage(ctx context.Context, message, model string, history []llm.ChatMessage) (*llm.LLMResponse, error) {
+// withSeed attaches the sampling seed to the context: the override when given, otherwise the
+// session's seed. It returns the seed in effect, nil when sampling is left to the provider.
+func (s *Server) withSeed(ctx context.Context, sessionID string, override *int) (context.Context, *int) {
+	seed, ok := s.sessions.Seed(sessionID)
+	if override != nil {
+		seed, ok = *override, *override >= 0
+	}
+	if !ok {
+		return ctx, nil
+	}
+	return llm.WithSeed(ctx, seed), &seed
+}
+
+// routeModel applies the routing policy when the model is empty or "auto"
+func (s *Server) routeModel(message, model string) (string, *llm.ModelRoute) {
+	if model != "" && model != llm.AutoModel {
+		return model, nil
+	}
+	if !s.modelRouter.Enabled() {
+		if model == llm.AutoModel {
+			return "", nil
+		}
+		return model, nil
+	}
+
+	route := s.modelRouter.Route(message)
+	s.logger.Info("Routed query to model",
+		zap.String("model", route.Model),
+		zap.String("complexity", route.Complexity),
+		zap.String("reason", route.Reason))
+	return route.Model, &route
+}
+
+// operationWait is how long a chat turn waits for a long-running controller operation, such as
+// a tech-support collection: half the server's write timeout, so the answer is still sent when
+// the operation takes longer
+func (s *Server) operationWait() time.Duration {
+	if s.config.Server.WriteTimeout <= 0 {
+		return 5 * time.Minute
+	}
+	return max(time.Duration(s.config.Server.WriteTimeout)*time.Second/2, 10*time.Second)
+}
+
+// providerInputs converts the available tools and the conversation history to the types of an
+// LLM provider
+func (s *Server) providerInputs(provider string, history []llm.ChatMessage) (tools interface{}, convertedHistory interface{}) {
 	// Convert history to the appropriate type based on provider
-	var convertedHistory interface{}
-	if s.config.Provider == "ollama" {
+	if provider == "ollama" {
 		convertedHistory = history
-	} else if s.config.Provider == "mistral" {
+	} else if provider == "mistral" {
 		// Convert llm.ChatMessage to mistral.ChatMessage
 		if history == nil {
 			history = []llm.ChatMessage{}
@@ -377,12 +771,11 @@ func (s *Server) processChatMessage(ctx context.Context, message, model string,
 	}
 
 	// Get tool definitions
-	var tools interface{}
-	if s.config.Provider == "ollama" {
-		tools = llm.GetAviToolDefinitions()
-	} else if s.config.Provider == "mistral" {
+	if provider == "ollama" {
+		tools = s.availableTools()
+	} else if provider == "mistral" {
 		// Convert llm.Tool to mistral.Tool
-		ollamaTools := llm.GetAviToolDefinitions()
+		ollamaTools := s.availableTools()
 		mistralTools := make([]mistral.Tool, len(ollamaTools))
 		for i, tool := range ollamaTools {
 			mistralTools[i] = mistral.Tool{
@@ -396,62 +789,84 @@ func (s *Server) processChatMessage(ctx context.Context, message, model string,
 		}
 		tools = mistralTools
 	}
+	return tools, convertedHistory
+}
 
-	// Process the message with the appropriate LLM client
-	var err error
-	llmResponse, err := s.llmClient.ProcessNaturalLanguageQuery(ctx, message, model, tools, convertedHistory)
-	if err != nil {
-		if s.config.Provider == "ollama" {
-			return nil, fmt.Errorf("Ollama LLM processing failed: %w", err)
-		} else if s.config.Provider == "mistral" {
-			return nil, fmt.Errorf("Mistral AI processing failed: %w", err)
+// executeToolCall executes a tool call against the Avi API. A panic in the tool, such as a bad
+// type assertion on its arguments, is logged with its stack and returned as a *llm.ToolError,
+// so one bad call fails alone instead of the whole chat request. Arguments that don't match the
+// tool's schema are refused before anything is sent to the controller, with a *llm.ToolError
+// listing them so the model can correct its call. Errors the controller answers are returned as
+// a *llm.ToolError too, with the kind of error and an explanation of it.
+func (s *Server) executeToolCall(ctx context.Context, toolCall llm.ToolCall) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger := requestid.Logger(ctx, s.logger)
+			// Only the argument names are logged: values can carry credentials, such as the
+			// objects of a configuration apply
+			logger.Error("Tool call panicked",
+				zap.String("tool", toolCall.Function.Name),
+				zap.Strings("arg_keys", argKeys(toolCall.Args)),
+				zap.Any("panic", r),
+				zap.ByteString("stack", debug.Stack()))
+			result = nil
+			err = &llm.ToolError{Tool: toolCall.Function.Name, Message: fmt.Sprint(r), Panic: true}
 		}
-		return nil, fmt.Errorf("LLM processing failed: %w", err)
+	}()
+	if err := s.tools.ValidateArgs(toolCall.Function.Name, toolCall.Args); err != nil {
+		return nil, err
 	}
+	result, err = s.dispatchToolCall(ctx, toolCall)
+	if aviErr, ok := avi.AsAviError(err); ok {
+		return nil, controllerToolError(toolCall.Function.Name, aviErr, err)
+	}
+	return result, err
+}
 
-	// If there are tool calls, execute them
-	if len(llmResponse.ToolCalls) > 0 {
-		for _, toolCall := range llmResponse.ToolCalls {
-			result, err := s.executeToolCall(ctx, toolCall)
-			if err != nil {
-				s.logger.Error("Tool call failed", 
-					zap.String("tool", toolCall.Function.Name),
-					zap.Error(err))
-				// Continue with other tool calls even if one fails
-				continue
-			}
-
-			// Add the result to the response message
-			if result != nil {
-				llmResponse.Message += fmt.Sprintf("\n\nAPI Result:\n```json\n%v\n```", result)
-			}
-		}
+// controllerToolError reports an error the controller answered with its explanation first, so
+// the chat says what went wrong and what to do rather than only the controller's message
+func controllerToolError(tool string, aviErr *avi.AviError, err error) *llm.ToolError {
+	message := err.Error()
+	if explanation := aviErr.Explanation(); explanation != "" {
+		message = fmt.Sprintf("%s (controller answered %d: %s)", explanation, aviErr.Status, aviErr.Message)
 	}
+	return &llm.ToolError{Tool: tool, Message: message, Code: aviErr.Code, Field: aviErr.Field, Cause: err}
+}
 
-	return llmResponse, nil
+// argKeys returns the sorted argument names of a tool call
+func argKeys(args map[string]interface{}) []string {
+	keys := make([]string, 0, len(args))
+	for key := range args {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
-// executeToolCall executes a tool call against the Avi API
-func (s *Server) executeToolCall(ctx context.Context, toolCall llm.ToolCall) (interface{}, error) {
+// dispatchToolCall runs the operation of a tool call
+func (s *Server) dispatchToolCall(ctx context.Context, toolCall llm.ToolCall) (interface{}, error) {
+	if !s.tools.Enabled(toolCall.Function.Name) {
+		return nil, fmt.Errorf("tool %s is disabled in this deployment", toolCall.Function.Name)
+	}
+	if avi.IsAdminTool(toolCall.Function.Name) && !s.permissions.IsAdmin() {
+		return nil, fmt.Errorf("tool %s requires the Avi account to hold the %s role (enable avi.least_privilege so the role is verified)", toolCall.Function.Name, avi.AdminRole)
+	}
+	if !s.permissions.AllowsTool(toolCall.Function.Name) {
+		return nil, fmt.Errorf("tool %s is not permitted by the Avi account's role (%s)", toolCall.Function.Name, s.permissions.Role)
+	}
+
 	switch toolCall.Function.Name {
 	case "list_virtual_services":
-		params := make(map[string]string)
-		if toolCall.Args != nil {
-			for key, value := range toolCall.Args {
-				if str, ok := value.(string); ok {
-					params[key] = str
-				}
-			}
-		}
+		params := llm.StringParams(toolCall.Args)
 		return s.aviClient.ListVirtualServices(ctx, params)
 
 	case "get_virtual_service":
-		uuid, ok := toolCall.Args["uuid"].(string)
+		uuid, ok := llm.ArgString(toolCall.Args, "uuid")
 		if !ok {
 			return nil, fmt.Errorf("uuid parameter required")
 		}
 		params := make(map[string]string)
-		if fields, ok := toolCall.Args["fields"].(string); ok {
+		if fields, ok := llm.ArgString(toolCall.Args, "fields"); ok {
 			params["fields"] = fields
 		}
 		return s.aviClient.GetVirtualService(ctx, uuid, params)
@@ -460,7 +875,7 @@ func (s *Server) executeToolCall(ctx context.Context, toolCall llm.ToolCall) (in
 		return s.aviClient.CreateVirtualService(ctx, toolCall.Args)
 
 	case "update_virtual_service":
-		uuid, ok := toolCall.Args["uuid"].(string)
+		uuid, ok := llm.ArgString(toolCall.Args, "uuid")
 		if !ok {
 			return nil, fmt.Errorf("uuid parameter required")
 		}
@@ -468,121 +883,536 @@ func (s *Server) executeToolCall(ctx context.Context, toolCall llm.ToolCall) (in
 		return s.aviClient.UpdateVirtualService(ctx, uuid, toolCall.Args)
 
 	case "delete_virtual_service":
-		uuid, ok := toolCall.Args["uuid"].(string)
+		uuid, ok := llm.ArgString(toolCall.Args, "uuid")
 		if !ok {
 			return nil, fmt.Errorf("uuid parameter required")
 		}
 		return nil, s.aviClient.DeleteVirtualService(ctx, uuid)
 
-	case "list_pools":
-		params := make(map[string]string)
-		if toolCall.Args != nil {
-			for key, value := range toolCall.Args {
-				if str, ok := value.(string); ok {
-					params[key] = str
-				}
-			}
+	case "enable_virtual_service", "disable_virtual_service":
+		uuid, ok := llm.ArgString(toolCall.Args, "uuid")
+		if !ok {
+			return nil, fmt.Errorf("uuid parameter required")
+		}
+		return s.aviClient.SetVirtualServiceEnabled(ctx, uuid, toolCall.Function.Name == "enable_virtual_service")
+
+	case "scale_out_virtual_service", "scale_in_virtual_service", "migrate_virtual_service", "switchover_virtual_service":
+		uuid, ok := llm.ArgString(toolCall.Args, "uuid")
+		if !ok {
+			return nil, fmt.Errorf("uuid parameter required")
 		}
+		action := map[string]string{
+			"scale_out_virtual_service":  avi.VSActionScaleOut,
+			"scale_in_virtual_service":   avi.VSActionScaleIn,
+			"migrate_virtual_service":    avi.VSActionMigrate,
+			"switchover_virtual_service": avi.VSActionSwitchover,
+		}[toolCall.Function.Name]
+		body, err := avi.VirtualServiceActionBody(action, toolCall.Args)
+		if err != nil {
+			return nil, err
+		}
+		return s.aviClient.VirtualServiceAction(ctx, uuid, action, body)
+
+	case "list_pools":
+		params := llm.StringParams(toolCall.Args)
 		return s.aviClient.ListPools(ctx, params)
 
 	case "get_pool":
-		uuid, ok := toolCall.Args["uuid"].(string)
+		uuid, ok := llm.ArgString(toolCall.Args, "uuid")
 		if !ok {
 			return nil, fmt.Errorf("uuid parameter required")
 		}
 		params := make(map[string]string)
-		if fields, ok := toolCall.Args["fields"].(string); ok {
+		if fields, ok := llm.ArgString(toolCall.Args, "fields"); ok {
 			params["fields"] = fields
 		}
 		return s.aviClient.GetPool(ctx, uuid, params)
 
 	case "create_pool":
+		avi.NormalizeServerIPs(toolCall.Args)
 		return s.aviClient.CreatePool(ctx, toolCall.Args)
 
+	case "update_pool":
+		uuid, ok := llm.ArgString(toolCall.Args, "uuid")
+		if !ok {
+			return nil, fmt.Errorf("uuid parameter required")
+		}
+		delete(toolCall.Args, "uuid") // Remove UUID from the data
+		avi.NormalizeServerIPs(toolCall.Args)
+		return s.aviClient.UpdatePool(ctx, uuid, toolCall.Args)
+
+	case "delete_pool":
+		uuid, ok := llm.ArgString(toolCall.Args, "uuid")
+		if !ok {
+			return nil, fmt.Errorf("uuid parameter required")
+		}
+		return nil, s.aviClient.DeletePool(ctx, uuid)
+
 	case "scale_out_pool":
-		uuid, ok := toolCall.Args["uuid"].(string)
+		uuid, ok := llm.ArgString(toolCall.Args, "uuid")
 		if !ok {
 			return nil, fmt.Errorf("uuid parameter required")
 		}
 		delete(toolCall.Args, "uuid") // Remove UUID from the parameters
+		avi.NormalizeServerIPs(toolCall.Args)
 		return nil, s.aviClient.ScaleOutPool(ctx, uuid, toolCall.Args)
 
 	case "scale_in_pool":
-		uuid, ok := toolCall.Args["uuid"].(string)
+		uuid, ok := llm.ArgString(toolCall.Args, "uuid")
 		if !ok {
 			return nil, fmt.Errorf("uuid parameter required")
 		}
 		delete(toolCall.Args, "uuid") // Remove UUID from the parameters
+		avi.NormalizeServerIPs(toolCall.Args)
 		return nil, s.aviClient.ScaleInPool(ctx, uuid, toolCall.Args)
 
-	case "list_health_monitors":
-		params := make(map[string]string)
-		if toolCall.Args != nil {
-			for key, value := range toolCall.Args {
-				if str, ok := value.(string); ok {
-					params[key] = str
-				}
-			}
+	case "enable_pool_server", "disable_pool_server":
+		uuid, ok := llm.ArgString(toolCall.Args, "uuid")
+		if !ok {
+			return nil, fmt.Errorf("uuid parameter required")
+		}
+		server, ok := llm.ArgString(toolCall.Args, "server")
+		if !ok {
+			return nil, fmt.Errorf("server parameter required")
 		}
+		ip, port, err := avi.ParseServerAddress(server)
+		if err != nil {
+			return nil, err
+		}
+		return s.aviClient.SetPoolServerEnabled(ctx, uuid, ip, port, toolCall.Function.Name == "enable_pool_server")
+
+	case "list_health_monitors":
+		params := llm.StringParams(toolCall.Args)
 		return s.aviClient.ListHealthMonitors(ctx, params)
 
 	case "get_health_monitor":
-		uuid, ok := toolCall.Args["uuid"].(string)
+		uuid, ok := llm.ArgString(toolCall.Args, "uuid")
 		if !ok {
 			return nil, fmt.Errorf("uuid parameter required")
 		}
 		params := make(map[string]string)
-		if fields, ok := toolCall.Args["fields"].(string); ok {
+		if fields, ok := llm.ArgString(toolCall.Args, "fields"); ok {
 			params["fields"] = fields
 		}
 		return s.aviClient.GetHealthMonitor(ctx, uuid, params)
 
-	case "list_service_engines":
-		params := make(map[string]string)
-		if toolCall.Args != nil {
-			for key, value := range toolCall.Args {
-				if str, ok := value.(string); ok {
-					params[key] = str
-				}
-			}
+	case "create_health_monitor":
+		return s.aviClient.CreateHealthMonitor(ctx, toolCall.Args)
+
+	case "update_health_monitor":
+		uuid, ok := llm.ArgString(toolCall.Args, "uuid")
+		if !ok {
+			return nil, fmt.Errorf("uuid parameter required")
+		}
+		delete(toolCall.Args, "uuid") // Remove UUID from the data
+		return s.aviClient.UpdateHealthMonitor(ctx, uuid, toolCall.Args)
+
+	case "delete_health_monitor":
+		uuid, ok := llm.ArgString(toolCall.Args, "uuid")
+		if !ok {
+			return nil, fmt.Errorf("uuid parameter required")
+		}
+		return nil, s.aviClient.DeleteHealthMonitor(ctx, uuid)
+
+	case "list_application_profiles":
+		params := llm.StringParams(toolCall.Args)
+		return s.aviClient.ListApplicationProfiles(ctx, params)
+
+	case "get_application_profile":
+		uuid, ok := llm.ArgString(toolCall.Args, "uuid")
+		if !ok {
+			return nil, fmt.Errorf("uuid parameter required")
+		}
+		return s.aviClient.GetApplicationProfile(ctx, uuid, nil)
+
+	case "create_application_profile":
+		return s.aviClient.CreateApplicationProfile(ctx, toolCall.Args)
+
+	case "attach_application_profile":
+		uuid, ok := llm.ArgString(toolCall.Args, "uuid")
+		if !ok {
+			return nil, fmt.Errorf("uuid parameter required")
 		}
+		profileUUID, ok := llm.ArgString(toolCall.Args, "profile_uuid")
+		if !ok {
+			return nil, fmt.Errorf("profile_uuid parameter required")
+		}
+		return s.aviClient.AttachApplicationProfile(ctx, uuid, profileUUID)
+
+	case "list_persistence_profiles":
+		params := llm.StringParams(toolCall.Args)
+		return s.aviClient.ListPersistenceProfiles(ctx, params)
+
+	case "get_persistence_profile":
+		uuid, ok := llm.ArgString(toolCall.Args, "uuid")
+		if !ok {
+			return nil, fmt.Errorf("uuid parameter required")
+		}
+		return s.aviClient.GetPersistenceProfile(ctx, uuid, nil)
+
+	case "create_persistence_profile":
+		return s.aviClient.CreatePersistenceProfile(ctx, toolCall.Args)
+
+	case "attach_persistence_profile":
+		uuid, ok := llm.ArgString(toolCall.Args, "uuid")
+		if !ok {
+			return nil, fmt.Errorf("uuid parameter required")
+		}
+		profileUUID, ok := llm.ArgString(toolCall.Args, "profile_uuid")
+		if !ok {
+			return nil, fmt.Errorf("profile_uuid parameter required")
+		}
+		poolUUID, _ := llm.ArgString(toolCall.Args, "pool_uuid")
+		return s.aviClient.AttachPersistenceProfile(ctx, uuid, poolUUID, profileUUID)
+
+	case "list_service_engines":
+		params := llm.StringParams(toolCall.Args)
 		return s.aviClient.ListServiceEngines(ctx, params)
 
 	case "get_service_engine":
-		uuid, ok := toolCall.Args["uuid"].(string)
+		uuid, ok := llm.ArgString(toolCall.Args, "uuid")
 		if !ok {
 			return nil, fmt.Errorf("uuid parameter required")
 		}
 		params := make(map[string]string)
-		if fields, ok := toolCall.Args["fields"].(string); ok {
+		if fields, ok := llm.ArgString(toolCall.Args, "fields"); ok {
 			params["fields"] = fields
 		}
 		return s.aviClient.GetServiceEngine(ctx, uuid, params)
 
+	case "reboot_service_engine":
+		uuid, ok := llm.ArgString(toolCall.Args, "uuid")
+		if !ok {
+			return nil, fmt.Errorf("uuid parameter required")
+		}
+		return s.aviClient.ServiceEngineAction(ctx, uuid, avi.SEActionReboot, map[string]interface{}{})
+
+	case "service_engine_maintenance":
+		se, ok := llm.ArgString(toolCall.Args, "service_engine")
+		if !ok {
+			return nil, fmt.Errorf("service_engine parameter required")
+		}
+		step, ok := llm.ArgString(toolCall.Args, "step")
+		if !ok {
+			return nil, fmt.Errorf("step parameter required")
+		}
+		return s.serviceEngineMaintenance(ctx, se, step, audit.ActorFrom(ctx).Operator)
+
+	case "remember":
+		text, ok := llm.ArgString(toolCall.Args, "note")
+		if !ok || strings.TrimSpace(text) == "" {
+			return nil, fmt.Errorf("note parameter required")
+		}
+		return s.sessions.AddNote(audit.ActorFrom(ctx).Session, text)
+
+	case "recall":
+		return map[string]interface{}{"notes": s.sessions.Notes(audit.ActorFrom(ctx).Session)}, nil
+
+	case "list_workflows":
+		resumable, _ := llm.ArgBool(toolCall.Args, "resumable")
+		return s.listWorkflows(resumable), nil
+
+	case "resume_workflow":
+		id, ok := llm.ArgString(toolCall.Args, "id")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("id parameter required")
+		}
+		return s.resumeWorkflow(ctx, id, audit.ActorFrom(ctx).Operator)
+
+	case "show_recent_changes":
+		return s.showRecentChanges(toolCall.Args, time.Now())
+
+	case "rollback_change":
+		return s.rollbackChange(ctx, toolCall.Args)
+
+	case "list_blueprints":
+		return s.listBlueprints(), nil
+
+	case "apply_blueprint":
+		name, ok := llm.ArgString(toolCall.Args, "blueprint")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("blueprint parameter required")
+		}
+		values, _ := toolCall.Args["parameters"].(map[string]interface{})
+		dryRun, _ := llm.ArgBool(toolCall.Args, "dry_run")
+		return s.applyBlueprint(ctx, name, values, dryRun, audit.ActorFrom(ctx).Operator)
+
+	case "check_drift":
+		object, _ := llm.ArgString(toolCall.Args, "object")
+		return s.checkDrift(ctx, object)
+
+	case "generate_terraform":
+		objects, err := s.iacObjects(ctx, toolCall.Args)
+		if err != nil {
+			return nil, err
+		}
+		return terraform.Generate(objects)
+
+	case "generate_ansible_playbook":
+		return s.generateAnsiblePlaybook(ctx, toolCall.Args)
+
+	case "list_vrf_contexts":
+		params := make(map[string]string)
+		if name, ok := llm.ArgString(toolCall.Args, "name"); ok && name != "" {
+			params["name"] = name
+		}
+		return s.aviClient.ListVRFContexts(ctx, params)
+
+	case "get_vrf_routing":
+		uuid, ok := llm.ArgString(toolCall.Args, "uuid")
+		if !ok {
+			return nil, fmt.Errorf("uuid parameter required")
+		}
+		vrf, err := s.aviClient.GetVRFContext(ctx, uuid, nil)
+		if err != nil {
+			return nil, err
+		}
+		return avi.SummarizeRouting(vrf)
+
+	case "get_bgp_peer_status":
+		uuid, ok := llm.ArgString(toolCall.Args, "uuid")
+		if !ok {
+			return nil, fmt.Errorf("uuid parameter required")
+		}
+		return s.aviClient.GetBGPPeerStatus(ctx, uuid, nil)
+
+	case "get_virtual_service_health", "get_pool_health", "get_service_engine_health":
+		resourceType := map[string]string{
+			"get_virtual_service_health": "virtualservice",
+			"get_pool_health":            "pool",
+			"get_service_engine_health":  "serviceengine",
+		}[toolCall.Function.Name]
+		uuid, _ := llm.ArgString(toolCall.Args, "uuid")
+		params := map[string]string{"include_name": "true"}
+		if name, ok := llm.ArgString(toolCall.Args, "name"); ok && name != "" {
+			params["name"] = name
+		}
+		inventory, err := s.aviClient.GetInventory(ctx, resourceType, uuid, params)
+		if err != nil {
+			return nil, err
+		}
+		return avi.SummarizeInventory(inventory), nil
+
+	case "get_virtual_service_health_score":
+		uuid, ok := llm.ArgString(toolCall.Args, "uuid")
+		if !ok {
+			return nil, fmt.Errorf("uuid parameter required")
+		}
+		params := map[string]string{"step": "300", "limit": "12"}
+		if step, ok := llm.ArgInt(toolCall.Args, "step"); ok && step > 0 {
+			params["step"] = fmt.Sprintf("%d", step)
+		}
+		if limit, ok := llm.ArgInt(toolCall.Args, "limit"); ok && limit > 0 {
+			params["limit"] = fmt.Sprintf("%d", limit)
+		}
+		series, err := s.aviClient.GetHealthScore(ctx, "virtualservice", uuid, params)
+		if err != nil {
+			return nil, err
+		}
+		return avi.SummarizeHealthScore(uuid, series), nil
+
+	case "simulate_change":
+		return s.simulateChange(ctx, toolCall.Args)
+
+	case "security_audit":
+		uuid, ok := llm.ArgString(toolCall.Args, "uuid")
+		if !ok {
+			return nil, fmt.Errorf("uuid parameter required")
+		}
+		return avi.RunSecurityAudit(ctx, s.aviClient, uuid)
+
+	case "get_controller_info":
+		return avi.GetControllerInfo(ctx, s.aviClient)
+
+	case "get_upgrade_status":
+		return avi.GetUpgradeStatus(ctx, s.aviClient)
+
+	case "get_license_usage":
+		return avi.GetLicenseSummary(ctx, s.aviClient)
+
+	case "list_tenants":
+		return avi.ListTenants(ctx, s.aviClient)
+
+	case "create_tenant":
+		name, _ := llm.ArgString(toolCall.Args, "name")
+		description, _ := llm.ArgString(toolCall.Args, "description")
+		local := true
+		if value, ok := llm.ArgBool(toolCall.Args, "local"); ok {
+			local = value
+		}
+		return avi.CreateTenant(ctx, s.aviClient, name, description, local)
+
+	case "list_users":
+		tenant, _ := llm.ArgString(toolCall.Args, "tenant")
+		return avi.ListUsers(ctx, s.aviClient, tenant)
+
+	case "list_roles":
+		return avi.ListRoles(ctx, s.aviClient)
+
+	case "explain_vs_health":
+		uuid, ok := llm.ArgString(toolCall.Args, "uuid")
+		if !ok || uuid == "" {
+			return nil, fmt.Errorf("uuid parameter required")
+		}
+		timeRange, _ := llm.ArgString(toolCall.Args, "time_range")
+		evidence, err := avi.CollectHealthEvidence(ctx, s.aviClient, uuid, timeRange)
+		if err != nil {
+			return nil, err
+		}
+		model := audit.ActorFrom(ctx).Model
+		if model == "" {
+			model = s.config.LLM.DefaultModel
+		}
+		return insights.SummarizeIncident(ctx, s.llmClient, model, evidence), nil
+
+	case "acknowledge_insight":
+		id, ok := llm.ArgString(toolCall.Args, "insight_id")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("insight_id parameter required")
+		}
+		operator := audit.ActorFrom(ctx).Operator
+		now := time.Now()
+		if snooze, _ := llm.ArgString(toolCall.Args, "snooze"); snooze != "" {
+			duration, err := insights.ParseSnooze(snooze)
+			if err != nil {
+				return nil, err
+			}
+			if err := s.insights.Snooze(operator, id, now.Add(duration), now); err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{"insight_id": id, "snoozed_until": now.Add(duration).Format(time.RFC3339)}, nil
+		}
+		if err := s.insights.Acknowledge(operator, id, now); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"insight_id": id, "acknowledged": true}, nil
+
+	case "list_backups":
+		return s.aviClient.ListBackups(ctx, map[string]string{"sort": "-timestamp"})
+
+	case "trigger_backup":
+		configUUID, _ := llm.ArgString(toolCall.Args, "backup_configuration")
+		configUUID, err := avi.ResolveBackupConfiguration(ctx, s.aviClient, configUUID)
+		if err != nil {
+			return nil, err
+		}
+		return s.aviClient.TriggerBackup(ctx, configUUID)
+
+	case "export_configuration":
+		fullSystem, _ := llm.ArgBool(toolCall.Args, "full_system")
+		export, err := s.aviClient.ExportConfiguration(ctx, configExportParams(fullSystem))
+		if err != nil {
+			return nil, err
+		}
+		summary, err := avi.SummarizeConfigExport(export)
+		if err != nil {
+			return nil, err
+		}
+		summary.DownloadURL = "/api/" + apiVersion + "/configuration/export"
+		if fullSystem {
+			summary.DownloadURL += "?full_system=true"
+		}
+		return summary, nil
+
+	case "apply_configuration":
+		objects, err := avi.ParseConfiguration(toolCall.Args["configuration"])
+		if err != nil {
+			return nil, err
+		}
+		dryRun, _ := llm.ArgBool(toolCall.Args, "dry_run")
+		return s.applyConfiguration(ctx, objects, dryRun, audit.ActorFrom(ctx).Operator)
+
 	case "get_analytics":
-		resourceType, ok := toolCall.Args["resource_type"].(string)
+		resourceType, ok := llm.ArgString(toolCall.Args, "resource_type")
 		if !ok {
 			return nil, fmt.Errorf("resource_type parameter required")
 		}
-		uuid, ok := toolCall.Args["uuid"].(string)
+		uuid, ok := llm.ArgString(toolCall.Args, "uuid")
 		if !ok {
 			return nil, fmt.Errorf("uuid parameter required")
 		}
-		params := make(map[string]string)
-		if metric, ok := toolCall.Args["metric"].(string); ok {
-			params["metric"] = metric
+		var metrics []string
+		if list, ok := toolCall.Args["metrics"].([]interface{}); ok {
+			for _, item := range list {
+				if metric, ok := item.(string); ok {
+					metrics = append(metrics, metric)
+				}
+			}
+		}
+		if metric, ok := llm.ArgString(toolCall.Args, "metric"); ok && metric != "" {
+			metrics = append(metrics, metric)
+		}
+		timeRange, _ := llm.ArgString(toolCall.Args, "time_range")
+		query, err := avi.BuildMetricsQuery(resourceType, uuid, metrics, timeRange)
+		if err != nil {
+			return nil, err
+		}
+		return avi.QueryMetrics(ctx, s.aviClient, query)
+
+	case "get_top_virtual_services":
+		by, _ := llm.ArgString(toolCall.Args, "by")
+		count, _ := llm.ArgInt(toolCall.Args, "count")
+		timeRange, _ := llm.ArgString(toolCall.Args, "time_range")
+		return avi.TopVirtualServices(ctx, s.aviClient, by, count, timeRange)
+
+	case "create_alert_rule":
+		req := avi.AlertRuleRequest{}
+		req.VirtualService, _ = llm.ArgString(toolCall.Args, "virtual_service")
+		req.Metric, _ = llm.ArgString(toolCall.Args, "metric")
+		req.Comparator, _ = llm.ArgString(toolCall.Args, "comparator")
+		req.Duration, _ = llm.ArgString(toolCall.Args, "duration")
+		req.Severity, _ = llm.ArgString(toolCall.Args, "severity")
+		req.Name, _ = llm.ArgString(toolCall.Args, "name")
+		req.EmailConfig, _ = llm.ArgString(toolCall.Args, "email_config")
+		req.SyslogConfig, _ = llm.ArgString(toolCall.Args, "syslog_config")
+		threshold, ok := llm.ArgString(toolCall.Args, "threshold")
+		if !ok {
+			return nil, fmt.Errorf("threshold parameter required")
+		}
+		value, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(threshold), "%"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid threshold %q", threshold)
+		}
+		req.Threshold = value
+		preview, _ := llm.ArgBool(toolCall.Args, "preview")
+		return avi.CreateAlertRule(ctx, s.aviClient, req, preview)
+
+	case "list_alert_rules":
+		return avi.ListAlertRules(ctx, s.aviClient)
+
+	case "delete_alert_rule":
+		name, ok := llm.ArgString(toolCall.Args, "name")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("name parameter required")
 		}
-		if timeRange, ok := toolCall.Args["time_range"].(string); ok {
-			params["time_range"] = timeRange
+		return nil, avi.DeleteAlertRule(ctx, s.aviClient, name)
+
+	case "get_sla_report":
+		period, _ := llm.ArgString(toolCall.Args, "period")
+		var target float64
+		if value, ok := llm.ArgString(toolCall.Args, "target"); ok {
+			target, _ = strconv.ParseFloat(value, 64)
+		}
+		var names []string
+		if list, ok := toolCall.Args["virtual_services"].([]interface{}); ok {
+			for _, item := range list {
+				if name, ok := item.(string); ok {
+					names = append(names, name)
+				}
+			}
 		}
-		return s.aviClient.GetAnalytics(ctx, resourceType, uuid, params)
+		return avi.GetAvailability(ctx, s.aviClient, period, target, names, time.Now())
+
+	case "list_metrics":
+		resourceType, _ := llm.ArgString(toolCall.Args, "resource_type")
+		return avi.MetricCatalog(resourceType), nil
 
 	case "execute_generic_operation":
-		method, ok := toolCall.Args["method"].(string)
+		method, ok := llm.ArgString(toolCall.Args, "method")
 		if !ok {
 			return nil, fmt.Errorf("method parameter required")
 		}
-		endpoint, ok := toolCall.Args["endpoint"].(string)
+		endpoint, ok := llm.ArgString(toolCall.Args, "endpoint")
 		if !ok {
 			return nil, fmt.Errorf("endpoint parameter required")
 		}
@@ -592,22 +1422,67 @@ func (s *Server) executeToolCall(ctx context.Context, toolCall llm.ToolCall) (in
 			body = b
 		}
 
-		params := make(map[string]string)
-		if p, exists := toolCall.Args["parameters"].(map[string]interface{}); exists {
-			for key, value := range p {
-				if str, ok := value.(string); ok {
-					params[key] = str
-				}
-			}
+		method = strings.ToUpper(method)
+		if err := s.checkGenericOperation(method, endpoint, body); err != nil {
+			return nil, err
 		}
 
+		parameters, _ := toolCall.Args["parameters"].(map[string]interface{})
+		params := llm.StringParams(parameters)
+
 		return s.aviClient.ExecuteGenericOperation(ctx, method, endpoint, body, params)
 
+	case "generate_tech_support":
+		level, ok := llm.ArgString(toolCall.Args, "level")
+		if !ok || level == "" {
+			return nil, fmt.Errorf("level parameter required")
+		}
+		request := avi.TechSupportRequest{Level: level}
+		request.UUID, _ = llm.ArgString(toolCall.Args, "uuid")
+		request.CaseNumber, _ = llm.ArgString(toolCall.Args, "case_number")
+		request.Description, _ = llm.ArgString(toolCall.Args, "description")
+		return avi.CollectTechSupport(ctx, s.aviClient, request, s.operationWait())
+
+	case "list_tech_support":
+		return avi.ListTechSupportBundles(ctx, s.aviClient)
+
+	case "download_file":
+		endpoint, ok := llm.ArgString(toolCall.Args, "endpoint")
+		if !ok {
+			return nil, fmt.Errorf("endpoint parameter required")
+		}
+		parameters, _ := toolCall.Args["parameters"].(map[string]interface{})
+		params := llm.StringParams(parameters)
+		endpoint, params, err := avi.NormalizeEndpoint(endpoint, params)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.permissions.AllowsOperation("GET", endpoint); err != nil {
+			return nil, err
+		}
+		// Check the file is there, the operator downloads it through the proxy
+		download, err := s.aviClient.Download(ctx, endpoint, params)
+		if err != nil {
+			return nil, err
+		}
+		download.Body.Close()
+		return download.Result(endpoint, params), nil
+
 	default:
+		if tool, ok := s.tools.Custom(toolCall.Function.Name); ok {
+			return s.runCustomTool(ctx, tool, toolCall.Args)
+		}
 		return nil, fmt.Errorf("unknown tool: %s", toolCall.Function.Name)
 	}
 }
 
+// modelsResponse lists the models of the provider
+type modelsResponse struct {
+	Models   []string `json:"models"`
+	Default  string   `json:"default"`
+	Provider string   `json:"provider"`
+}
+
 // handleGetModels returns available models
 func (s *Server) handleGetModels(c *gin.Context) {
 	var models []string
@@ -622,10 +1497,10 @@ func (s *Server) handleGetModels(c *gin.Context) {
 		defaultModel = s.config.Mistral.DefaultModel
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"models": models,
-		"default": defaultModel,
-		"provider": s.config.Provider,
+	c.JSON(http.StatusOK, modelsResponse{
+		Models:   models,
+		Default:  defaultModel,
+		Provider: s.config.Provider,
 	})
 }
 
@@ -650,14 +1525,21 @@ func (s *Server) handleHTMXModels(c *gin.Context) {
 	})
 }
 
+// validateModelRequest is the body of POST /api/v1/models/validate
+type validateModelRequest struct {
+	Model string `json:"model" binding:"required"`
+}
+
+// validateModelResponse tells whether the provider serves a model
+type validateModelResponse struct {
+	Valid bool `json:"valid"`
+}
+
 // handleValidateModel validates a model
 func (s *Server) handleValidateModel(c *gin.Context) {
-	var request struct {
-		Model string `json:"model" binding:"required"`
-	}
-
+	var request validateModelRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
 		return
 	}
 
@@ -675,41 +1557,45 @@ func (s *Server) handleValidateModel(c *gin.Context) {
 	}
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, errorResponse{Error: err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"valid": valid})
+	c.JSON(http.StatusOK, validateModelResponse{Valid: valid})
 }
 
-// handleChatHistory returns chat history (placeholder implementation)
-func (s *Server) handleChatHistory(c *gin.Context) {
-	// For now, return empty history
-	// In a real implementation, you'd store and retrieve chat sessions
-	c.JSON(http.StatusOK, gin.H{"sessions": []ChatSession{}})
-}
-
-// handleHTMXHistory returns history for HTMX (placeholder)
-func (s *Server) handleHTMXHistory(c *gin.Context) {
-	c.HTML(http.StatusOK, "history.html", gin.H{
-		"sessions": []ChatSession{},
-	})
-}
-
-// handleClearHistory clears chat history (placeholder)
-func (s *Server) handleClearHistory(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "History cleared"})
+// healthResponse is the state of the agent and of its connections to the controller and the
+// LLM provider
+type healthResponse struct {
+	Status           string   `json:"status"`
+	Timestamp        string   `json:"timestamp"`
+	Provider         string   `json:"provider"`
+	Version          string   `json:"version"`
+	BuildDate        string   `json:"build_date"`
+	AppName          string   `json:"app_name"`
+	Sandbox          bool     `json:"sandbox"`
+	UIEnabled        bool     `json:"ui_enabled"`
+	UIError          string   `json:"ui_error,omitempty"`
+	AviStatus        string   `json:"avi_status"`
+	AviError         string   `json:"avi_error,omitempty"`
+	ClockSkewSeconds *float64 `json:"clock_skew_seconds,omitempty"` // when avi.clock_skew_threshold is set
+	ClockSkewError   string   `json:"clock_skew_error,omitempty"`
+	LLMStatus        string   `json:"llm_status"`
+	LLMError         string   `json:"llm_error,omitempty"`
 }
 
 // handleHealth returns health status
 func (s *Server) handleHealth(c *gin.Context) {
-	status := gin.H{
-		"status": "healthy",
-		"timestamp": time.Now().UTC().Format(time.RFC3339),
-		"provider": s.config.Provider,
-		"version": "1.0.0",
-		"build_date": "2026-01-01",
-		"app_name": "VMware Avi LLM Agent",
+	status := healthResponse{
+		Status:    "healthy",
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Provider:  s.config.Provider,
+		Version:   "1.0.0",
+		BuildDate: "2026-01-01",
+		AppName:   "VMware Avi LLM Agent",
+		Sandbox:   s.sandbox != nil,
+		UIEnabled: s.uiUnavailable == "",
+		UIError:   s.uiUnavailable,
 	}
 
 	// Check Avi connection
@@ -717,34 +1603,45 @@ func (s *Server) handleHealth(c *gin.Context) {
 	defer cancel()
 
 	if _, err := s.aviClient.ListVirtualServices(ctx, map[string]string{"limit_by": "1"}); err != nil {
-		status["avi_status"] = "unhealthy"
-		status["avi_error"] = err.Error()
+		status.AviStatus = "unhealthy"
+		status.AviError = err.Error()
 	} else {
-		status["avi_status"] = "healthy"
+		status.AviStatus = "healthy"
+	}
+
+	// Report controller clock skew
+	if s.clockSkew.Enabled() {
+		if skew, err := s.clockSkew.Skew(ctx); err != nil {
+			status.ClockSkewError = err.Error()
+		} else {
+			seconds := skew.Round(time.Second).Seconds()
+			status.ClockSkewSeconds = &seconds
+		}
 	}
 
 	// Check LLM connection based on provider
 	if s.config.Provider == "ollama" {
 		ollamaClient := s.llmClient.(*llm.Client)
 		if _, err := ollamaClient.ListModels(ctx); err != nil {
-			status["llm_status"] = "unhealthy"
-			status["llm_error"] = err.Error()
+			status.LLMStatus = "unhealthy"
+			status.LLMError = err.Error()
 		} else {
-			status["llm_status"] = "healthy"
+			status.LLMStatus = "healthy"
 		}
 	} else if s.config.Provider == "mistral" {
 		if _, err := s.mistralClient.ListModels(ctx); err != nil {
-			status["llm_status"] = "unhealthy"
-			status["llm_error"] = err.Error()
+			status.LLMStatus = "unhealthy"
+			status.LLMError = err.Error()
 		} else {
-			status["llm_status"] = "healthy"
+			status.LLMStatus = "healthy"
 		}
 	}
 
 	c.JSON(http.StatusOK, status)
 }
 
-// handleAviProxy provides direct access to Avi API (for advanced users)
+// handleAviProxy provides direct access to Avi API (for advanced users). GET responses that are
+// files, or any GET with ?download=true, are streamed to the client as downloads.
 func (s *Server) handleAviProxy(c *gin.Context) {
 	path := c.Param("path")
 	method := c.Request.Method
@@ -756,12 +1653,24 @@ func (s *Server) handleAviProxy(c *gin.Context) {
 			params[key] = values[0]
 		}
 	}
+	download := params["download"] == "true"
+	delete(params, "download")
+
+	if _, _, err := avi.NormalizeEndpoint(path, params); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	if method == http.MethodGet {
+		s.proxyAviGet(c, path, params, download)
+		return
+	}
 
 	// Get request body for POST/PUT/PATCH
 	var body interface{}
 	if method == "POST" || method == "PUT" || method == "PATCH" {
 		if err := c.ShouldBindJSON(&body); err != nil && err != io.EOF {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			c.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
 			return
 		}
 	}
@@ -769,20 +1678,77 @@ func (s *Server) handleAviProxy(c *gin.Context) {
 	// Execute the operation with context
 	result, err := s.aviClient.ExecuteGenericOperation(c.Request.Context(), method, path, body, params)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, errorResponse{Error: err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, result)
 }
 
-// corsMiddleware adds CORS headers
+// proxyAviGet answers a proxied GET: API answers are returned as JSON, files are streamed as
+// they arrive from the controller with their length, so the browser shows download progress.
+// The transfer stops when the client goes away.
+func (s *Server) proxyAviGet(c *gin.Context, path string, params map[string]string, download bool) {
+	file, err := s.aviClient.Download(c.Request.Context(), path, params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+	defer file.Body.Close()
+
+	if !download && !avi.IsFile(file.ContentType) {
+		data, err := io.ReadAll(file.Body)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, errorResponse{Error: err.Error()})
+			return
+		}
+		var result interface{}
+		if len(data) > 0 && json.Unmarshal(data, &result) != nil {
+			result = string(data)
+		}
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
+	// Bundles can take longer than server.write_timeout to transfer
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		s.logger.Debug("Could not lift the write deadline of a download", zap.Error(err))
+	}
+	contentType := file.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	s.logger.Info("Streaming Avi file download",
+		zap.String("endpoint", path),
+		zap.String("filename", file.Filename),
+		zap.Int64("size", file.ContentLength))
+	c.DataFromReader(http.StatusOK, file.ContentLength, contentType, file.Body, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": file.Filename}),
+	})
+}
+
+// corsMiddleware allows the configured origins to call the API from a browser; requests from
+// other origins get no CORS headers, so browsers only let the UI's own pages read the responses
 func (s *Server) corsMiddleware() gin.HandlerFunc {
+	allowed := make(map[string]bool, len(s.config.Server.CORSOrigins))
+	for _, origin := range s.config.Server.CORSOrigins {
+		allowed[strings.TrimSuffix(origin, "/")] = true
+	}
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
+		if origin := c.GetHeader("Origin"); origin != "" && (allowed[origin] || allowed["*"]) {
+			header := c.Writer.Header()
+			header.Add("Vary", "Origin")
+			if allowed[origin] {
+				header.Set("Access-Control-Allow-Origin", origin)
+				header.Set("Access-Control-Allow-Credentials", "true")
+			} else {
+				// Browsers don't send credentials to a wildcard origin
+				header.Set("Access-Control-Allow-Origin", "*")
+			}
+			header.Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, "+requestid.Header)
+			header.Set("Access-Control-Expose-Headers", requestid.Header)
+			header.Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
+		}
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -793,8 +1759,111 @@ func (s *Server) corsMiddleware() gin.HandlerFunc {
 	}
 }
 
+// requestIDMiddleware gives every request an ID, the client's X-Request-ID when it is usable or a
+// new one. The ID is returned in the response header and carried by the request context, so the
+// logs of the request and of its LLM and Avi calls can be found from the ID a user quotes.
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+		c.Header(requestid.Header, id)
+		c.Request = c.Request.WithContext(requestid.With(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// accessLogMiddleware logs every request with its request ID. Successful requests are sampled at
+// the configured rate; client errors and slow requests are logged as warnings and server errors
+// as errors, always.
+func accessLogMiddleware(cfg config.AccessLogConfig, logger *zap.Logger) gin.HandlerFunc {
+	skip := make(map[string]bool, len(cfg.SkipPaths))
+	for _, path := range cfg.SkipPaths {
+		skip[path] = true
+	}
+	slow := time.Duration(cfg.SlowThreshold) * time.Millisecond
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		if skip[c.Request.URL.Path] {
+			return
+		}
+		latency := time.Since(start)
+		status := c.Writer.Status()
+		isSlow := slow > 0 && latency >= slow
+		if status < 400 && !isSlow && rand.Float64() >= cfg.SampleRate {
+			return
+		}
+
+		fields := []zap.Field{
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+			zap.String("query", c.Request.URL.RawQuery),
+			zap.Int("status", status),
+			zap.Duration("latency", latency),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Int("bytes", c.Writer.Size()),
+			zap.String("user_agent", c.Request.UserAgent()),
+		}
+		if errs := c.Errors.ByType(gin.ErrorTypePrivate).String(); errs != "" {
+			fields = append(fields, zap.String("errors", errs))
+		}
+		requestLogger := requestid.Logger(c.Request.Context(), logger)
+		switch {
+		case status >= 500:
+			requestLogger.Error("HTTP request", fields...)
+		case status >= 400:
+			requestLogger.Warn("HTTP request", fields...)
+		case isSlow:
+			requestLogger.Warn("Slow HTTP request", fields...)
+		default:
+			requestLogger.Info("HTTP request", fields...)
+		}
+	}
+}
+
+// securityHeadersMiddleware adds the configured security headers to every response
+func (s *Server) securityHeadersMiddleware() gin.HandlerFunc {
+	headers := s.config.Server.SecurityHeaders
+	hsts := ""
+	if headers.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(headers.HSTSMaxAge)
+	}
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		if headers.ContentSecurityPolicy != "" {
+			header.Set("Content-Security-Policy", headers.ContentSecurityPolicy)
+		}
+		if headers.FrameOptions != "" {
+			header.Set("X-Frame-Options", headers.FrameOptions)
+		}
+		// Browsers ignore HSTS over plain HTTP; behind a TLS-terminating proxy it's sent for https requests
+		if hsts != "" && (c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https") {
+			header.Set("Strict-Transport-Security", hsts)
+		}
+		c.Next()
+	}
+}
+
+// sharedAviClient returns the client of the agent's own Avi account
+func (s *Server) sharedAviClient() AviClientInterface {
+	if s.aviUsers != nil {
+		return s.aviUsers.shared
+	}
+	return s.aviClient
+}
+
 // Close closes the server and performs cleanup
 func (s *Server) Close() error {
+	if s.scheduler != nil {
+		s.scheduler.Stop()
+	}
+	if s.drift != nil {
+		s.drift.Stop()
+	}
 	if s.aviClient != nil {
 		return s.aviClient.Close()
 	}
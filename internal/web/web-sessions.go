@@ -0,0 +1,472 @@
+package web
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"aviagent/pkg/agent/chat"
+	"aviagent/pkg/config"
+	"aviagent/pkg/llm"
+)
+
+// History page sizes
+const (
+	defaultSessionPageSize = 20
+	defaultMessagePageSize = 50
+	maxHistoryPageSize     = 200
+)
+
+// SessionSummary is a session as listed in the history view
+type SessionSummary struct {
+	ID           string    `json:"id"`
+	Model        string    `json:"model"`
+	Created      time.Time `json:"created"`
+	LastActivity time.Time `json:"last_activity"`
+	MessageCount int       `json:"message_count"`
+	Preview      string    `json:"preview,omitempty"` // first user message
+}
+
+// SessionPage is a page of sessions, most recently active first
+type SessionPage struct {
+	Sessions   []SessionSummary `json:"sessions"`
+	Total      int              `json:"total"`
+	NextOffset int              `json:"next_offset,omitempty"` // offset of the next page, 0 when this is the last
+}
+
+// ActiveSession is a recently active session
+type ActiveSession struct {
+	SessionSummary
+	Status string `json:"status,omitempty"` // provider status of the question being answered, such as a rate limit retry
+}
+
+// MessagePage is a page of a session's messages in chronological order. Pages are read backwards
+// from the newest message, so Before of the next page loads the messages older than this one.
+type MessagePage struct {
+	Session  string        `json:"session"`
+	Messages []ChatMessage `json:"messages"`
+	Total    int           `json:"total"`
+	Before   int           `json:"before,omitempty"` // index to request older messages with, 0 when there are none
+}
+
+// ToolInvocation is a tool call executed for a chat session, kept so it can be re-run
+type ToolInvocation struct {
+	ID        string                 `json:"id"`
+	Session   string                 `json:"session"`
+	Operator  string                 `json:"operator,omitempty"`
+	Tool      string                 `json:"tool"`
+	Args      map[string]interface{} `json:"args,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
+// SessionStore keeps chat sessions in memory
+type SessionStore struct {
+	mu          sync.RWMutex
+	sessions    map[string]*ChatSession
+	invocations map[string]ToolInvocation
+	statuses    map[string]string          // provider status of the question being answered, per session
+	wizards     map[string]*creationWizard // creations waiting for the operator to answer, per session
+	pricing     config.PricingConfig
+}
+
+// NewSessionStore creates a new in-memory session store
+func NewSessionStore(pricing config.PricingConfig) *SessionStore {
+	return &SessionStore{
+		sessions:    make(map[string]*ChatSession),
+		invocations: make(map[string]ToolInvocation),
+		statuses:    make(map[string]string),
+		wizards:     make(map[string]*creationWizard),
+		pricing:     pricing,
+	}
+}
+
+// setPricing replaces the token pricing applied to later usage after a configuration reload
+func (s *SessionStore) setPricing(pricing config.PricingConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pricing = pricing
+}
+
+// newSessionID generates a new chat session identifier
+func newSessionID() string {
+	return fmt.Sprintf("session_%d", time.Now().UnixNano())
+}
+
+// GetOrCreate returns the session with the given ID, creating it if it doesn't exist
+func (s *SessionStore) GetOrCreate(id, model string) *ChatSession {
+	if id == "" {
+		id = newSessionID()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		session = &ChatSession{
+			ID:      id,
+			Model:   model,
+			Created: time.Now(),
+			Usage: chat.SessionUsage{
+				Currency: s.pricing.Currency,
+				Priced:   true,
+			},
+		}
+		s.sessions[id] = session
+	}
+	return session
+}
+
+// History returns the conversation history of a session in the form sent to the LLM
+func (s *SessionStore) History(id string) []llm.ChatMessage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil
+	}
+	history := make([]llm.ChatMessage, 0, len(session.Messages))
+	for _, msg := range session.Messages {
+		history = append(history, llm.ChatMessage{Role: msg.Role, Content: msg.Content})
+	}
+	return history
+}
+
+// AppendExchange records a user message and the assistant's answer in the session
+func (s *SessionStore) AppendExchange(id, model, userMessage, answer string, toolCalls []string) {
+	session := s.GetOrCreate(id, model)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	session.Messages = append(session.Messages,
+		ChatMessage{ID: fmt.Sprintf("msg_%d", now.UnixNano()), Role: "user", Content: userMessage, Timestamp: now},
+		ChatMessage{ID: fmt.Sprintf("msg_%d", now.UnixNano()+1), Role: "assistant", Content: answer, Timestamp: now, Model: model, ToolCalls: toolCalls},
+	)
+}
+
+// AppendNotice adds a message from the agent, such as a received alert, to an existing session so
+// it is shown in the conversation and sent to the model with the next question
+func (s *SessionStore) AppendNotice(id, content string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return false
+	}
+	now := time.Now()
+	session.Messages = append(session.Messages,
+		ChatMessage{ID: fmt.Sprintf("msg_%d", now.UnixNano()), Role: "assistant", Content: content, Timestamp: now})
+	return true
+}
+
+// SetStatus records the provider status of the question a session is waiting for, such as a rate
+// limit retry; an empty status clears it
+func (s *SessionStore) SetStatus(id, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if status == "" {
+		delete(s.statuses, id)
+		return
+	}
+	s.statuses[id] = status
+}
+
+// Status returns the provider status of the question a session is waiting for
+func (s *SessionStore) Status(id string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.statuses[id]
+}
+
+// SetWizard keeps the creation a session waits for the operator to complete; nil ends it
+func (s *SessionStore) SetWizard(id string, wizard *creationWizard) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if wizard == nil {
+		delete(s.wizards, id)
+		return
+	}
+	s.wizards[id] = wizard
+}
+
+// Wizard returns the creation a session waits for the operator to complete
+func (s *SessionStore) Wizard(id string) (*creationWizard, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	wizard, ok := s.wizards[id]
+	return wizard, ok
+}
+
+// Export returns a copy of a session
+func (s *SessionStore) Export(id string) (*ChatSession, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, false
+	}
+	copied := *session
+	copied.Messages = append([]ChatMessage(nil), session.Messages...)
+	copied.Notes = append([]SessionNote(nil), session.Notes...)
+	return &copied, true
+}
+
+// Import adds a session, such as one saved to a file, replacing a session with the same ID
+func (s *SessionStore) Import(session *ChatSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if session.Created.IsZero() {
+		session.Created = time.Now()
+	}
+	s.sessions[session.ID] = session
+}
+
+// Latest returns the ID of the most recently active session with messages
+func (s *SessionStore) Latest() (string, bool) {
+	page := s.Sessions(0, 1)
+	if len(page.Sessions) == 0 {
+		return "", false
+	}
+	return page.Sessions[0].ID, true
+}
+
+// RecordInvocation keeps a tool call of a session before it is executed and returns its ID. The
+// arguments are copied, as some tools remove the arguments they consume.
+func (s *SessionStore) RecordInvocation(sessionID, operator string, toolCall llm.ToolCall) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	invocation := ToolInvocation{
+		ID:        fmt.Sprintf("inv_%d", now.UnixNano()),
+		Session:   sessionID,
+		Operator:  operator,
+		Tool:      toolCall.Function.Name,
+		Args:      copyArgs(toolCall.Args),
+		Timestamp: now,
+	}
+	for s.invocations[invocation.ID].ID != "" {
+		invocation.ID += "0"
+	}
+	s.invocations[invocation.ID] = invocation
+	return invocation.ID
+}
+
+// Invocation returns a recorded tool call, with its own copy of the arguments
+func (s *SessionStore) Invocation(id string) (ToolInvocation, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	invocation, ok := s.invocations[id]
+	invocation.Args = copyArgs(invocation.Args)
+	return invocation, ok
+}
+
+// copyArgs returns a shallow copy of tool call arguments
+func copyArgs(args map[string]interface{}) map[string]interface{} {
+	if args == nil {
+		return nil
+	}
+	copied := make(map[string]interface{}, len(args))
+	for key, value := range args {
+		copied[key] = value
+	}
+	return copied
+}
+
+// Sessions returns a page of sessions, most recently active first
+func (s *SessionStore) Sessions(offset, limit int) SessionPage {
+	limit = pageSize(limit, defaultSessionPageSize)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	summaries := make([]SessionSummary, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		if len(session.Messages) == 0 {
+			continue
+		}
+		summaries = append(summaries, summarize(session))
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].LastActivity.After(summaries[j].LastActivity)
+	})
+
+	page := SessionPage{Sessions: []SessionSummary{}, Total: len(summaries)}
+	if offset < 0 {
+		offset = 0
+	}
+	if offset < len(summaries) {
+		end := offset + limit
+		if end > len(summaries) {
+			end = len(summaries)
+		}
+		page.Sessions = summaries[offset:end]
+		if end < len(summaries) {
+			page.NextOffset = end
+		}
+	}
+	return page
+}
+
+// summarize lists a session in the history view; its last activity is its creation until it has messages
+func summarize(session *ChatSession) SessionSummary {
+	summary := SessionSummary{
+		ID:           session.ID,
+		Model:        session.Model,
+		Created:      session.Created,
+		LastActivity: session.Created,
+		MessageCount: len(session.Messages),
+	}
+	if len(session.Messages) > 0 {
+		summary.LastActivity = session.Messages[len(session.Messages)-1].Timestamp
+	}
+	for _, msg := range session.Messages {
+		if msg.Role == "user" {
+			summary.Preview = msg.Content
+			break
+		}
+	}
+	return summary
+}
+
+// Active returns the sessions active since the given time and those waiting for the provider,
+// most recently active first
+func (s *SessionStore) Active(since time.Time) []ActiveSession {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	active := []ActiveSession{}
+	for id, session := range s.sessions {
+		summary := summarize(session)
+		status := s.statuses[id]
+		if summary.LastActivity.Before(since) && status == "" {
+			continue
+		}
+		active = append(active, ActiveSession{SessionSummary: summary, Status: status})
+	}
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].LastActivity.After(active[j].LastActivity)
+	})
+	return active
+}
+
+// Messages returns the messages of a session that precede index before (all messages when
+// before is 0), at most limit of them
+func (s *SessionStore) Messages(id string, before, limit int) (MessagePage, bool) {
+	limit = pageSize(limit, defaultMessagePageSize)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return MessagePage{}, false
+	}
+	total := len(session.Messages)
+	end := total
+	if before > 0 && before < total {
+		end = before
+	}
+	start := end - limit
+	if start < 0 {
+		start = 0
+	}
+	messages := make([]ChatMessage, end-start)
+	copy(messages, session.Messages[start:end])
+	return MessagePage{Session: id, Messages: messages, Total: total, Before: start}, true
+}
+
+// Clear removes a session and its tool invocations, or every session when id is empty
+func (s *SessionStore) Clear(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id == "" {
+		s.sessions = make(map[string]*ChatSession)
+		s.invocations = make(map[string]ToolInvocation)
+		s.wizards = make(map[string]*creationWizard)
+		return
+	}
+	delete(s.sessions, id)
+	delete(s.wizards, id)
+	for invocationID, invocation := range s.invocations {
+		if invocation.Session == id {
+			delete(s.invocations, invocationID)
+		}
+	}
+}
+
+// pageSize applies the default and maximum page size
+func pageSize(limit, fallback int) int {
+	if limit <= 0 {
+		return fallback
+	}
+	if limit > maxHistoryPageSize {
+		return maxHistoryPageSize
+	}
+	return limit
+}
+
+// SetSeed sets the sampling seed used for the rest of the session; a negative seed clears it
+func (s *SessionStore) SetSeed(id string, seed int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if session, ok := s.sessions[id]; ok {
+		if seed < 0 {
+			session.Seed = nil
+		} else {
+			session.Seed = &seed
+		}
+	}
+}
+
+// Seed returns the sampling seed of a session, if one is set
+func (s *SessionStore) Seed(id string) (int, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if session, ok := s.sessions[id]; ok && session.Seed != nil {
+		return *session.Seed, true
+	}
+	return 0, false
+}
+
+// RecordUsage adds the usage of a single LLM call to the session and returns the new totals
+func (s *SessionStore) RecordUsage(id, model string, usage llm.Usage) chat.SessionUsage {
+	session := s.GetOrCreate(id, model)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session.Usage.PromptTokens += usage.PromptTokens
+	session.Usage.CompletionTokens += usage.CompletionTokens
+	session.Usage.TotalTokens += usage.TotalTokens
+
+	if cost, ok := s.pricing.EstimateCost(model, usage.PromptTokens, usage.CompletionTokens); ok {
+		session.Usage.EstimatedCost += cost
+	} else {
+		session.Usage.Priced = false
+	}
+
+	return session.Usage
+}
+
+// Usage returns the token usage of a session, empty for an unknown session
+func (s *SessionStore) Usage(id string) chat.SessionUsage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if session, ok := s.sessions[id]; ok {
+		return session.Usage
+	}
+	return chat.SessionUsage{}
+}
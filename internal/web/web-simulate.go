@@ -0,0 +1,122 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"aviagent/pkg/audit"
+	"aviagent/pkg/avi"
+	"aviagent/pkg/llm"
+)
+
+// simulationInventories caches the inventory snapshots "what if" simulations are computed from,
+// by Avi account, so follow-up questions about the same change don't read the controller again
+type simulationInventories struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	byAccount map[string]*avi.Inventory
+}
+
+// newSimulationInventories creates a cache keeping snapshots for ttl
+func newSimulationInventories(ttl time.Duration) *simulationInventories {
+	return &simulationInventories{ttl: ttl, byAccount: make(map[string]*avi.Inventory)}
+}
+
+// inventory returns the snapshot of the Avi account of the request, reading it when it is older
+// than the TTL or refresh is set
+func (c *simulationInventories) inventory(ctx context.Context, exec avi.GenericExecutor, refresh bool) (*avi.Inventory, error) {
+	// Operators with their own Avi account may see other objects than the agent's account, so
+	// snapshots are kept by the credentials the request reaches the controller with. Operators
+	// refused an account get no snapshot, not the agent's.
+	account := "agent"
+	if user, ok := ctx.Value(aviUserKey{}).(aviUser); ok {
+		if user.err != nil {
+			return nil, user.err
+		}
+		account = "user:" + user.account()
+	}
+
+	c.mu.Lock()
+	cached := c.byAccount[account]
+	c.mu.Unlock()
+	if cached != nil && !refresh && time.Since(cached.Taken) < c.ttl {
+		return cached, nil
+	}
+
+	inv, err := avi.LoadInventory(ctx, exec)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.byAccount[account] = inv
+	c.mu.Unlock()
+	return inv, nil
+}
+
+// flush drops the snapshots, and returns how many were dropped
+func (c *simulationInventories) flush() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	flushed := len(c.byAccount)
+	c.byAccount = make(map[string]*avi.Inventory)
+	return flushed
+}
+
+// simulationPrompt instructs the model reviewing a simulated change
+const simulationPrompt = `You are a VMware Avi Load Balancer engineer reviewing a proposed change before it is made.
+You are given JSON computed from the controller inventory: the remaining capacity of the affected pools, the virtual services that degrade or go down, the health monitors affected, blockers the controller would reject the change for, and risks.
+Write 2 to 4 sentences of plain text reasoning about the operational impact: whether the change looks safe, what to check or do first, and a safer alternative when there is one.
+The figures are exact: don't recompute or contradict them, and only state what they support.`
+
+// simulateChange runs the simulate_change tool: it evaluates a proposed change against the
+// cached inventory without calling the controller's write APIs, then has the model reason about
+// the computed impact. The computation answers on its own when the model can't be used.
+func (s *Server) simulateChange(ctx context.Context, args map[string]interface{}) (*avi.SimulationReport, error) {
+	action, ok := llm.ArgString(args, "action")
+	if !ok || action == "" {
+		return nil, fmt.Errorf("action parameter required")
+	}
+	change := avi.SimulatedChange{Action: action}
+	change.Target, _ = llm.ArgString(args, "target")
+	change.Server, _ = llm.ArgString(args, "server")
+	if change.Target == "" && change.Action != avi.SimulateRemoveServer && change.Action != avi.SimulateDisableServer {
+		return nil, fmt.Errorf("target parameter required")
+	}
+	refresh, _ := llm.ArgBool(args, "refresh")
+
+	inv, err := s.simulations.inventory(ctx, s.aviClient, refresh)
+	if err != nil {
+		return nil, err
+	}
+	report, err := inv.Simulate(change)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.llmClient != nil {
+		model := audit.ActorFrom(ctx).Model
+		if model == "" {
+			model = s.config.LLM.DefaultModel
+		}
+		report.Assessment = assessSimulation(ctx, s.llmClient, model, report)
+	}
+	return report, nil
+}
+
+// assessSimulation has the model reason about a simulated change, empty when it fails
+func assessSimulation(ctx context.Context, completer llm.Completer, model string, report *avi.SimulationReport) string {
+	computed, err := json.Marshal(report)
+	if err != nil {
+		return ""
+	}
+	reply, err := completer.Complete(ctx, model, simulationPrompt, string(computed))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(reply)
+}
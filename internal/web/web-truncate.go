@@ -0,0 +1,120 @@
+package web
+
+import (
+	"encoding/json"
+
+	"aviagent/pkg/llm"
+)
+
+// Tool results are added to the answer, which the model reads again with every later question, so
+// long lists are cut. Every cut list, and every controller page of a longer collection, is
+// described in a "_truncated" field so the model doesn't take the items shown for the whole list.
+const (
+	maxResultItems = 50       // items kept of a list in a tool result, unless results.max_items says otherwise
+	maxResultBytes = 48 << 10 // JSON size above which the lists of a result are cut further, unless results.max_bytes says otherwise
+	truncatedKey   = "_truncated"
+)
+
+// truncation describes a list of a tool result that holds fewer items than there are
+type truncation struct {
+	Shown   int                    `json:"shown"`
+	Omitted int                    `json:"omitted"`
+	Total   int                    `json:"total"`
+	Next    map[string]interface{} `json:"next,omitempty"` // arguments of the call reading the following page
+	Hint    string                 `json:"hint,omitempty"`
+}
+
+// truncateResult returns a tool result with its top-level lists cut to maxItems, or fewer when
+// the result would exceed maxBytes, and marked. Results with nothing to mark are returned as they
+// are.
+func truncateResult(toolCall llm.ToolCall, result interface{}, maxItems, maxBytes int) interface{} {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return result
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return result
+	}
+
+	object, ok := value.(map[string]interface{})
+	if list, isList := value.([]interface{}); isList {
+		object, ok = map[string]interface{}{"results": list}, true
+	}
+	if !ok {
+		return result
+	}
+
+	// The lists and how many items they have in all; a controller collection reports its size in count
+	lists := map[string][]interface{}{}
+	totals := map[string]int{}
+	for key, field := range object {
+		if list, ok := field.([]interface{}); ok {
+			lists[key] = list
+			totals[key] = len(list)
+		}
+	}
+	if count, ok := object["count"].(float64); ok && lists["results"] != nil && int(count) > totals["results"] {
+		totals["results"] = int(count)
+	}
+
+	for limit := maxItems; ; limit /= 2 {
+		markers := map[string]truncation{}
+		cut := make(map[string]interface{}, len(object)+1)
+		for key, field := range object {
+			cut[key] = field
+		}
+		for key, list := range lists {
+			shown := min(len(list), limit)
+			if shown == totals[key] {
+				continue
+			}
+			cut[key] = list[:shown]
+			markers[key] = newTruncation(toolCall, key, shown, len(list), totals[key])
+		}
+		if len(markers) == 0 {
+			return result
+		}
+		cut[truncatedKey] = markers
+		if limit <= 1 || encodedSize(cut) <= maxBytes {
+			return cut
+		}
+	}
+}
+
+// newTruncation describes the list key of a result showing shown of the listed items, out of
+// total. Collections of the paged list tools name the page holding the items that follow.
+func newTruncation(toolCall llm.ToolCall, key string, shown, listed, total int) truncation {
+	t := truncation{Shown: shown, Omitted: total - shown, Total: total}
+	if key == "results" && llm.PagedTools[toolCall.Function.Name] && shown > 0 {
+		page, ok := llm.ArgInt(toolCall.Args, "page")
+		if !ok || page < 1 {
+			page = 1
+		}
+		pageSize, ok := llm.ArgInt(toolCall.Args, "page_size")
+		if !ok || pageSize < 1 {
+			pageSize = listed
+		}
+		// The next page must start right after the items shown
+		if offset := (page-1)*pageSize + shown; offset < total && offset%shown == 0 {
+			t.Next = make(map[string]interface{}, len(toolCall.Args)+2)
+			for name, arg := range toolCall.Args {
+				t.Next[name] = arg
+			}
+			t.Next["page"] = offset/shown + 1
+			t.Next["page_size"] = shown
+			return t
+		}
+	}
+	t.Hint = "narrow the request with a filter to see the omitted items"
+	return t
+}
+
+// encodedSize returns the JSON size of a value
+func encodedSize(value interface{}) int {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
@@ -0,0 +1,100 @@
+package web
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"aviagent/pkg/llm"
+	webassets "aviagent/web"
+
+	"github.com/gin-gonic/gin"
+)
+
+// uiUnavailablePage is served by the UI routes when the web UI isn't served. It is inline as the
+// templates may be what is missing.
+const uiUnavailablePage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Web UI unavailable - VMware Avi LLM Agent</title>
+</head>
+<body style="font-family: sans-serif; max-width: 40rem; margin: 4rem auto; line-height: 1.5;">
+    <h1>Web UI unavailable</h1>
+    <p>The web interface of the VMware Avi LLM Agent is not served: %s.</p>
+    <p>The JSON API is available under <code>/api/v1</code>, for example <code>POST /api/v1/chat</code> and <code>GET /api/v1/health</code>.</p>
+</body>
+</html>
+`
+
+// loadUI loads the templates and static assets of the web UI, embedded in the binary or read from
+// server.ui_dir. It returns why the UI can't be served, or "" when it loaded.
+func (s *Server) loadUI(funcs template.FuncMap) string {
+	if !s.config.Server.UIEnabled {
+		return "it is disabled (server.ui_enabled is false)"
+	}
+
+	assets, source := fs.FS(webassets.Assets), "the embedded assets"
+	if dir := s.config.Server.UIDir; dir != "" {
+		assets, source = os.DirFS(dir), dir
+	}
+	templates, err := template.New("").Funcs(funcs).ParseFS(assets, "templates/*.html")
+	if err != nil {
+		return fmt.Sprintf("its templates could not be loaded from %s (%v)", source, err)
+	}
+	if info, err := fs.Stat(assets, "static"); err != nil || !info.IsDir() {
+		return fmt.Sprintf("its static assets were not found in %s", source)
+	}
+	static, err := fs.Sub(assets, "static")
+	if err != nil {
+		return fmt.Sprintf("its static assets could not be loaded from %s (%v)", source, err)
+	}
+
+	s.router.SetHTMLTemplate(templates)
+	// Embedded files have no modification time, so browsers revalidate them by ETag
+	s.router.Group("/static", etagMiddleware()).StaticFS("/", http.FS(static))
+	return ""
+}
+
+// templateFuncs returns the functions the UI templates use
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"now": time.Now,
+		"formatCost": func(cost float64) string {
+			return fmt.Sprintf("%.4f", cost)
+		},
+		"split": strings.Split,
+		"toJSON": toJSON,
+		"objectLink": objectLink,
+		"isMutating": llm.IsMutatingTool,
+		"hasPrefix": strings.HasPrefix,
+		"hasSuffix": strings.HasSuffix,
+		"substr": func(s string, start int, length int) string {
+			if start < 0 {
+				start = 0
+			}
+			if start >= len(s) {
+				return ""
+			}
+			end := start + length
+			if end > len(s) {
+				end = len(s)
+			}
+			return s[start:end]
+		},
+		"sub": func(a, b int) int {
+			return a - b
+		},
+	}
+}
+
+// handleUIUnavailable answers the UI routes with a 503 page saying why the UI isn't served
+func (s *Server) handleUIUnavailable(c *gin.Context) {
+	page := fmt.Sprintf(uiUnavailablePage, html.EscapeString(s.uiUnavailable))
+	c.Data(http.StatusServiceUnavailable, "text/html; charset=utf-8", []byte(page))
+}
@@ -0,0 +1,191 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"aviagent/internal/blueprint"
+	"aviagent/pkg/agent/chat"
+	"aviagent/pkg/llm"
+)
+
+// typePorts is the type of a wizard answer listing the ports of a virtual service
+const typePorts = "ports"
+
+// wizardField is an argument a creation wizard asks the operator for when a create tool call
+// lacks it
+type wizardField struct {
+	Arg      string
+	Question string
+	Type     string   // blueprint parameter type the answer is checked against, or typePorts
+	Values   []string // allowed values, also accepted without Prefix
+	Prefix   string
+	Ref      string // object type the answer names; the argument is a reference to it
+}
+
+// creationFields are the arguments each create tool needs before it is sent to the controller,
+// in the order they are asked for. The schemas only require some of them, but the controller
+// refuses the objects without the others, such as a virtual service without a VIP.
+var creationFields = map[string][]wizardField{
+	"create_virtual_service": {
+		{Arg: "name", Question: "What should the virtual service be called?", Type: blueprint.TypeName},
+		{Arg: "vsvip_ref", Question: "Which VS VIP should it listen on? Give the name of an existing VS VIP.", Type: blueprint.TypeName, Ref: "vsvip"},
+		{Arg: "services", Question: "Which ports should it listen on, e.g. 443 or 80, 443?", Type: typePorts},
+	},
+	"create_pool": {
+		{Arg: "name", Question: "What should the pool be called?", Type: blueprint.TypeName},
+		{Arg: "servers", Question: "Which backend servers should it balance? List them as ip or ip:port, separated by commas.", Type: blueprint.TypeServers},
+	},
+	"create_health_monitor": {
+		{Arg: "name", Question: "What should the health monitor be called?", Type: blueprint.TypeName},
+		{Arg: "type", Question: "Which type of health monitor: HTTP, HTTPS, TCP, UDP, DNS or PING?", Type: blueprint.TypeString,
+			Prefix: "HEALTH_MONITOR_", Values: []string{"HEALTH_MONITOR_HTTP", "HEALTH_MONITOR_HTTPS", "HEALTH_MONITOR_TCP", "HEALTH_MONITOR_UDP", "HEALTH_MONITOR_DNS", "HEALTH_MONITOR_PING"}},
+	},
+	"create_application_profile": {
+		{Arg: "name", Question: "What should the application profile be called?", Type: blueprint.TypeName},
+	},
+	"create_persistence_profile": {
+		{Arg: "name", Question: "What should the persistence profile be called?", Type: blueprint.TypeName},
+		{Arg: "persistence_type", Question: "Which persistence: HTTP cookie, client IP address, custom HTTP header or app cookie?", Type: blueprint.TypeString,
+			Prefix: "PERSISTENCE_TYPE_", Values: []string{"PERSISTENCE_TYPE_HTTP_COOKIE", "PERSISTENCE_TYPE_CLIENT_IP_ADDRESS", "PERSISTENCE_TYPE_CUSTOM_HTTP_HEADER", "PERSISTENCE_TYPE_APP_COOKIE"}},
+	},
+	"create_tenant": {
+		{Arg: "name", Question: "What should the tenant be called?", Type: blueprint.TypeName},
+	},
+}
+
+// cancelAnswers end a creation wizard without running its tool call
+var cancelAnswers = map[string]bool{"cancel": true, "stop": true, "abort": true, "never mind": true, "nevermind": true}
+
+// creationWizard is a create tool call held until the operator has given the arguments it lacks
+type creationWizard struct {
+	Call    llm.ToolCall
+	Missing []wizardField // still to ask, in order
+}
+
+// question is the question the operator is asked next
+func (w *creationWizard) question() string {
+	return fmt.Sprintf("%s (%d left; reply cancel to stop.)", w.Missing[0].Question, len(w.Missing))
+}
+
+// missingFields returns the arguments a create tool call lacks
+func missingFields(toolCall llm.ToolCall, args map[string]interface{}) []wizardField {
+	var missing []wizardField
+	for _, field := range creationFields[toolCall.Function.Name] {
+		switch value := args[field.Arg].(type) {
+		case nil:
+			missing = append(missing, field)
+		case string:
+			if strings.TrimSpace(value) == "" {
+				missing = append(missing, field)
+			}
+		case []interface{}:
+			if len(value) == 0 {
+				missing = append(missing, field)
+			}
+		}
+	}
+	return missing
+}
+
+// startWizard holds a create tool call lacking arguments the controller needs, returning the
+// first question to ask the operator. Calls are run as they are when the session already waits
+// for an answer or nobody is there to answer.
+func (s *Server) startWizard(ctx context.Context, sessionID string, toolCall llm.ToolCall) (string, bool) {
+	if sessionID == "" || chat.HooksFrom(ctx).Unattended {
+		return "", false
+	}
+	if _, waiting := s.sessions.Wizard(sessionID); waiting {
+		return "", false
+	}
+	args := make(map[string]interface{})
+	for key, value := range toolArgs(toolCall) {
+		args[key] = value
+	}
+	missing := missingFields(toolCall, args)
+	if len(missing) == 0 {
+		return "", false
+	}
+	toolCall.Args = args
+	toolCall.InvocationID = ""
+	wizard := &creationWizard{Call: toolCall, Missing: missing}
+	s.sessions.SetWizard(sessionID, wizard)
+	return wizard.question(), true
+}
+
+// answerWizard takes a message as the answer to the question of the session's creation wizard.
+// The answer is checked before the next question is asked; once nothing is missing, the
+// response holds the completed tool call, for chat.Run to run as the model's.
+func (s *Server) answerWizard(sessionID, model, message string) (*llm.LLMResponse, bool) {
+	wizard, ok := s.sessions.Wizard(sessionID)
+	if !ok {
+		return nil, false
+	}
+	response := &llm.LLMResponse{Model: model}
+	tool := wizard.Call.Function.Name
+	answer := strings.TrimSpace(message)
+	if cancelAnswers[strings.ToLower(strings.TrimRight(answer, ".!"))] {
+		s.sessions.SetWizard(sessionID, nil)
+		response.Message = fmt.Sprintf("Cancelled %s: nothing was changed.", tool)
+		return response, true
+	}
+
+	field := wizard.Missing[0]
+	value, err := field.parse(answer)
+	if err != nil {
+		response.Message = fmt.Sprintf("That doesn't work for %s: %v. %s", field.Arg, err, wizard.question())
+		return response, true
+	}
+
+	// The stored wizard is replaced rather than changed, as it may be read concurrently
+	call := wizard.Call
+	call.Args = make(map[string]interface{}, len(wizard.Call.Args)+1)
+	for key, arg := range wizard.Call.Args {
+		call.Args[key] = arg
+	}
+	call.Args[field.Arg] = value
+	next := &creationWizard{Call: call, Missing: wizard.Missing[1:]}
+	if len(next.Missing) > 0 {
+		s.sessions.SetWizard(sessionID, next)
+		response.Message = next.question()
+		return response, true
+	}
+	s.sessions.SetWizard(sessionID, nil)
+	response.Message = fmt.Sprintf("That's everything %s needs.", tool)
+	response.ToolCalls = []llm.ToolCall{call}
+	return response, true
+}
+
+// parse checks an answer and returns it as the argument of the tool call
+func (f wizardField) parse(answer string) (interface{}, error) {
+	if answer == "" {
+		return nil, fmt.Errorf("the answer is empty")
+	}
+	if f.Type == typePorts {
+		var services []interface{}
+		for _, entry := range strings.FieldsFunc(answer, func(r rune) bool { return r == ',' || r == ' ' }) {
+			port, err := blueprint.Parameter{Name: "the port", Type: blueprint.TypePort}.Convert(entry)
+			if err != nil {
+				return nil, err
+			}
+			services = append(services, map[string]interface{}{"port": port})
+		}
+		return services, nil
+	}
+	if len(f.Values) > 0 {
+		choice := strings.ToUpper(strings.Join(strings.FieldsFunc(answer, func(r rune) bool { return r == ' ' || r == '-' || r == '_' }), "_"))
+		if !strings.HasPrefix(choice, f.Prefix) {
+			choice = f.Prefix + choice
+		}
+		answer = choice
+	}
+	value, err := blueprint.Parameter{Name: "the answer", Type: f.Type, Values: f.Values}.Convert(answer)
+	if err != nil {
+		return nil, err
+	}
+	if f.Ref != "" {
+		return fmt.Sprintf("/api/%s?name=%s", f.Ref, value), nil
+	}
+	return value, nil
+}
@@ -0,0 +1,286 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"aviagent/internal/workflow"
+	"aviagent/pkg/avi"
+	"aviagent/pkg/llm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Workflow run kinds, named after the tools that start them
+const (
+	workflowApply       = "apply_configuration"
+	workflowMaintenance = "service_engine_maintenance"
+)
+
+// maintenanceRunSteps are the recorded maintenance steps; planning changes nothing
+var maintenanceRunSteps = []string{avi.MaintenanceDisable, avi.MaintenanceWait, avi.MaintenanceVerify, avi.MaintenanceEnable}
+
+// appliedConfiguration is a configuration apply report with the run recording its progress
+type appliedConfiguration struct {
+	*avi.ApplyReport
+	Workflow string `json:"workflow,omitempty"`
+}
+
+// maintenanceStep is a maintenance step report with the run recording the maintenance
+type maintenanceStep struct {
+	*avi.MaintenanceReport
+	Workflow string `json:"workflow,omitempty"`
+}
+
+// workflowSummary describes a run without its input
+type workflowSummary struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"`
+	Subject   string    `json:"subject"`
+	Operator  string    `json:"operator,omitempty"`
+	State     string    `json:"state"`
+	Completed int       `json:"completed_steps"`
+	Steps     int       `json:"steps"`
+	NextStep  string    `json:"next_step,omitempty"`
+	Resumable bool      `json:"resumable"`
+	Updated   time.Time `json:"updated"`
+}
+
+// summarizeWorkflow describes a run for listings
+func summarizeWorkflow(run workflow.Run) workflowSummary {
+	summary := workflowSummary{
+		ID:        run.ID,
+		Kind:      run.Kind,
+		Subject:   run.Subject,
+		Operator:  run.Operator,
+		State:     run.State,
+		Steps:     len(run.Steps),
+		Resumable: run.Resumable(),
+		Updated:   run.Updated,
+	}
+	for _, step := range run.Steps {
+		if step.Status == workflow.StepDone || step.Status == workflow.StepSkipped {
+			summary.Completed++
+		}
+	}
+	if next := run.NextStep(); next >= 0 {
+		summary.NextStep = run.Steps[next].Name
+	}
+	return summary
+}
+
+// listWorkflows summarizes the recorded runs, only those that can be resumed when resumable is set
+func (s *Server) listWorkflows(resumable bool) []workflowSummary {
+	summaries := []workflowSummary{}
+	for _, run := range s.workflows.List() {
+		if !resumable || run.Resumable() {
+			summaries = append(summaries, summarizeWorkflow(run))
+		}
+	}
+	return summaries
+}
+
+// applyConfiguration applies objects, recording each object as a step of a run unless it is a
+// dry run, so an apply cut short can be resumed without applying the finished objects again
+func (s *Server) applyConfiguration(ctx context.Context, objects []avi.ConfigObject, dryRun bool, operator string) (*appliedConfiguration, error) {
+	if err := s.permissions.AllowsApply(objects, dryRun); err != nil {
+		return nil, err
+	}
+	if dryRun {
+		return &appliedConfiguration{ApplyReport: avi.ApplyConfiguration(ctx, s.aviClient, objects, true, nil)}, nil
+	}
+	steps := make([]string, len(objects))
+	indexes := make([]int, len(objects))
+	for i, obj := range objects {
+		steps[i] = obj.Type + "/" + obj.Name
+		indexes[i] = i
+	}
+	run, err := s.workflows.Start(workflowApply, fmt.Sprintf("%d objects", len(objects)), operator, objects, steps)
+	if err != nil {
+		return nil, err
+	}
+	return s.runApply(ctx, run.ID, objects, indexes), nil
+}
+
+// runApply applies the objects at indexes as steps of a run
+func (s *Server) runApply(ctx context.Context, runID string, objects []avi.ConfigObject, indexes []int) *appliedConfiguration {
+	pending := make([]avi.ConfigObject, len(indexes))
+	for i, index := range indexes {
+		pending[i] = objects[index]
+	}
+	report := avi.ApplyConfiguration(ctx, s.aviClient, pending, false, func(i int, result avi.ApplyResult) {
+		if result.Error != "" {
+			s.workflows.SetStep(runID, indexes[i], workflow.StepFailed, result.Status, errors.New(result.Error))
+			return
+		}
+		s.workflows.SetStep(runID, indexes[i], workflow.StepDone, result.Status, nil)
+	})
+	if ctx.Err() != nil {
+		s.workflows.Interrupt(runID)
+	}
+	return &appliedConfiguration{ApplyReport: report, Workflow: runID}
+}
+
+// approvalSteps are the maintenance steps that change the state of the service engine
+var approvalSteps = map[string]bool{avi.MaintenanceDisable: true, avi.MaintenanceEnable: true}
+
+// needsApproval reports whether a tool call the model proposed waits for the operator's approval
+// when the frontend has no Confirm hook: the generic operations and custom tools that change
+// configuration, the maintenance steps that disable or enable a service engine, run directly or by resuming a
+// maintenance run, and rollbacks. The operator approves by re-running the recorded call with
+// ?confirm=true. A generic operation tools.generic_operation refuses is run, so the model is told
+// right away.
+func (s *Server) needsApproval(toolCall llm.ToolCall) bool {
+	switch toolCall.Function.Name {
+	case "execute_generic_operation":
+		method, _ := llm.ArgString(toolCall.Args, "method")
+		endpoint, _ := llm.ArgString(toolCall.Args, "endpoint")
+		return s.tools.IsMutating(toolCall.Function.Name, toolCall.Args) &&
+			s.checkGenericOperation(method, endpoint, toolCall.Args["body"]) == nil
+	case "service_engine_maintenance":
+		step, _ := llm.ArgString(toolCall.Args, "step")
+		return approvalSteps[step]
+	case "resume_workflow":
+		id, _ := llm.ArgString(toolCall.Args, "id")
+		run, ok := s.workflows.Get(id)
+		if !ok || run.Kind != workflowMaintenance {
+			return false
+		}
+		next := run.NextStep()
+		return next >= 0 && approvalSteps[run.Steps[next].Name]
+	case "rollback_change":
+		return true
+	}
+	if _, ok := s.tools.Custom(toolCall.Function.Name); ok {
+		return s.tools.IsMutating(toolCall.Function.Name, toolCall.Args)
+	}
+	return false
+}
+
+// approvalSet indexes the invocation IDs awaiting approval for the chat template
+func approvalSet(ids []string) map[string]bool {
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+// serviceEngineMaintenance runs a maintenance step and records it in the maintenance run of the
+// service engine, started by the first step that changes something
+func (s *Server) serviceEngineMaintenance(ctx context.Context, se, step, operator string) (interface{}, error) {
+	index := -1
+	for i, name := range maintenanceRunSteps {
+		if name == step {
+			index = i
+		}
+	}
+	if index < 0 {
+		return avi.ServiceEngineMaintenance(ctx, s.aviClient, se, step, s.operationWait())
+	}
+
+	run, ok := s.workflows.Latest(workflowMaintenance, se)
+	var err error
+	switch {
+	case !ok:
+		run, err = s.workflows.Start(workflowMaintenance, se, operator, map[string]string{"service_engine": se}, maintenanceRunSteps)
+	case run.Resumable():
+		run, err = s.workflows.Resume(run.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := avi.ServiceEngineMaintenance(ctx, s.aviClient, se, step, s.operationWait())
+	if err != nil {
+		s.workflows.SetStep(run.ID, index, workflow.StepFailed, "", err)
+		switch {
+		case errors.Is(err, avi.ErrMaintenanceAborted):
+			s.workflows.Abort(run.ID)
+		case ctx.Err() != nil:
+			s.workflows.Interrupt(run.ID)
+		}
+		return nil, err
+	}
+	// Earlier steps the operator skipped were done by other means
+	for i := 0; i < index; i++ {
+		if status := run.Steps[i].Status; status != workflow.StepDone && status != workflow.StepSkipped {
+			s.workflows.SetStep(run.ID, i, workflow.StepSkipped, "", nil)
+		}
+	}
+	// A wait that ran out of time leaves the step to be run again
+	status := workflow.StepDone
+	if report.InProgress {
+		status = workflow.StepPending
+	}
+	s.workflows.SetStep(run.ID, index, status, report.Message, nil)
+	return &maintenanceStep{MaintenanceReport: report, Workflow: run.ID}, nil
+}
+
+// resumeWorkflow continues a failed or interrupted run from its first unfinished step: the
+// objects of a configuration apply that weren't applied, or the next maintenance step
+func (s *Server) resumeWorkflow(ctx context.Context, id, operator string) (interface{}, error) {
+	run, ok := s.workflows.Get(id)
+	if !ok {
+		return nil, fmt.Errorf("workflow run %s not found", id)
+	}
+	if !run.Resumable() {
+		return nil, fmt.Errorf("workflow run %s is %s and can't be resumed", id, run.State)
+	}
+
+	switch run.Kind {
+	case workflowApply:
+		var objects []avi.ConfigObject
+		if err := json.Unmarshal(run.Input, &objects); err != nil || len(objects) != len(run.Steps) {
+			return nil, fmt.Errorf("workflow run %s has no usable configuration to resume", id)
+		}
+		var indexes []int
+		for i, step := range run.Steps {
+			if step.Status != workflow.StepDone && step.Status != workflow.StepSkipped {
+				indexes = append(indexes, i)
+			}
+		}
+		if _, err := s.workflows.Resume(id); err != nil {
+			return nil, err
+		}
+		return s.runApply(ctx, id, objects, indexes), nil
+
+	case workflowMaintenance:
+		return s.serviceEngineMaintenance(ctx, run.Subject, run.Steps[run.NextStep()].Name, operator)
+
+	case workflowRunbook:
+		return s.resumeRunbook(ctx, run)
+
+	case workflowPlan:
+		if _, err := s.workflows.Resume(id); err != nil {
+			return nil, err
+		}
+		return s.runPlan(ctx, id), nil
+	}
+	return nil, fmt.Errorf("workflow run %s of kind %s can't be resumed", id, run.Kind)
+}
+
+// workflowsResponse lists the recorded multi-step runs
+type workflowsResponse struct {
+	Workflows []workflowSummary `json:"workflows"`
+}
+
+// handleListWorkflows lists the recorded multi-step runs, ?resumable=true for those that can be resumed
+func (s *Server) handleListWorkflows(c *gin.Context) {
+	c.JSON(http.StatusOK, workflowsResponse{Workflows: s.listWorkflows(c.Query("resumable") == "true")})
+}
+
+// handleGetWorkflow returns a run with the state of each step
+func (s *Server) handleGetWorkflow(c *gin.Context) {
+	run, ok := s.workflows.Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, errorResponse{Error: fmt.Sprintf("workflow run %s not found", c.Param("id"))})
+		return
+	}
+	run.Input = nil
+	c.JSON(http.StatusOK, run)
+}
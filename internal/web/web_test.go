@@ -0,0 +1,2317 @@
+package web
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"aviagent/internal/approvals"
+	"aviagent/internal/blueprint"
+	"aviagent/internal/changes"
+	"aviagent/internal/drift"
+	"aviagent/internal/logging"
+	"aviagent/internal/memory"
+	"aviagent/internal/moderation"
+	"aviagent/internal/notify"
+	"aviagent/internal/requestid"
+	"aviagent/internal/terraform"
+	"aviagent/internal/workflow"
+	"aviagent/pkg/agent/chat"
+	"aviagent/pkg/audit"
+	"aviagent/pkg/avi"
+	"aviagent/pkg/config"
+	"aviagent/pkg/llm"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newMiddlewareRouter returns a router with the CORS and security header middleware of a server
+// configured with server, answering 200 on GET and POST /api/ping
+func newMiddlewareRouter(server config.ServerConfig) *gin.Engine {
+	s := &Server{config: &config.Config{Server: server}}
+	router := gin.New()
+	router.Use(s.corsMiddleware(), s.securityHeadersMiddleware())
+	router.GET("/api/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+	router.POST("/api/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+	return router
+}
+
+// serve sends a request with the given headers through the router
+func serve(router http.Handler, method, path string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	router := newMiddlewareRouter(config.ServerConfig{CORSOrigins: []string{"https://ops.example.com/"}})
+
+	t.Run("allowed origin", func(t *testing.T) {
+		w := serve(router, "GET", "/api/ping", map[string]string{"Origin": "https://ops.example.com"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "https://ops.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+		assert.Equal(t, "Origin", w.Header().Get("Vary"))
+	})
+
+	t.Run("disallowed origin", func(t *testing.T) {
+		w := serve(router, "GET", "/api/ping", map[string]string{"Origin": "https://evil.example.com"})
+		assert.Equal(t, http.StatusOK, w.Code, "the browser enforces the policy")
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+	})
+
+	t.Run("wildcard without credentials", func(t *testing.T) {
+		router := newMiddlewareRouter(config.ServerConfig{CORSOrigins: []string{"*"}})
+		w := serve(router, "GET", "/api/ping", map[string]string{"Origin": "https://any.example.com"})
+		assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+	})
+
+	t.Run("preflight", func(t *testing.T) {
+		w := serve(router, "OPTIONS", "/api/ping", map[string]string{
+			"Origin":                        "https://ops.example.com",
+			"Access-Control-Request-Method": "POST",
+		})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "https://ops.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), "POST")
+
+		w = serve(router, "OPTIONS", "/api/ping", map[string]string{"Origin": "https://evil.example.com"})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("no origins configured", func(t *testing.T) {
+		w := serve(newMiddlewareRouter(config.ServerConfig{}), "GET", "/api/ping", map[string]string{"Origin": "https://ops.example.com"})
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	})
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	router := gin.New()
+	router.Use(requestIDMiddleware())
+	router.GET("/api/ping", func(c *gin.Context) { c.String(http.StatusOK, requestid.From(c.Request.Context())) })
+
+	w := serve(router, "GET", "/api/ping", map[string]string{requestid.Header: "ticket-4711"})
+	assert.Equal(t, "ticket-4711", w.Header().Get(requestid.Header), "the client's ID is kept")
+	assert.Equal(t, "ticket-4711", w.Body.String(), "and carried by the request context")
+
+	w = serve(router, "GET", "/api/ping", map[string]string{requestid.Header: "bad id\r\nX-Injected: 1"})
+	id := w.Header().Get(requestid.Header)
+	assert.True(t, requestid.Valid(id))
+	assert.NotContains(t, id, "bad", "unusable IDs are replaced")
+	assert.Equal(t, id, w.Body.String())
+}
+
+func TestAccessLogMiddleware(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	router := gin.New()
+	router.Use(requestIDMiddleware(), accessLogMiddleware(config.AccessLogConfig{
+		SampleRate:    0,
+		SlowThreshold: 50,
+		SkipPaths:     []string{"/api/health"},
+	}, zap.New(core)))
+	router.GET("/api/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+	router.GET("/api/health", func(c *gin.Context) { c.Status(http.StatusServiceUnavailable) })
+	router.GET("/api/slow", func(c *gin.Context) {
+		time.Sleep(60 * time.Millisecond)
+		c.Status(http.StatusOK)
+	})
+	router.GET("/api/fail", func(c *gin.Context) { c.Status(http.StatusBadGateway) })
+
+	serve(router, "GET", "/api/ping", nil)
+	serve(router, "GET", "/api/health", nil)
+	assert.Zero(t, logs.Len(), "successful requests are sampled out and skipped paths never logged")
+
+	serve(router, "GET", "/api/slow", nil)
+	serve(router, "GET", "/api/fail?page=2", map[string]string{requestid.Header: "ticket-4711"})
+	entries := logs.TakeAll()
+	require.Len(t, entries, 2)
+	assert.Equal(t, zapcore.WarnLevel, entries[0].Level)
+	assert.Equal(t, "Slow HTTP request", entries[0].Message)
+	assert.Equal(t, zapcore.ErrorLevel, entries[1].Level)
+	fields := entries[1].ContextMap()
+	assert.Equal(t, "/api/fail", fields["path"])
+	assert.Equal(t, "page=2", fields["query"])
+	assert.EqualValues(t, http.StatusBadGateway, fields["status"])
+	assert.Equal(t, "ticket-4711", fields["request_id"])
+}
+
+func TestSecurityHeadersMiddleware(t *testing.T) {
+	router := newMiddlewareRouter(config.ServerConfig{SecurityHeaders: config.SecurityHeadersConfig{
+		ContentSecurityPolicy: "default-src 'self'",
+		HSTSMaxAge:            600,
+		FrameOptions:          "DENY",
+	}})
+
+	w := serve(router, "GET", "/api/ping", nil)
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "default-src 'self'", w.Header().Get("Content-Security-Policy"))
+	assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+	assert.Empty(t, w.Header().Get("Strict-Transport-Security"), "no HSTS over plain HTTP")
+
+	w = serve(router, "GET", "/api/ping", map[string]string{"X-Forwarded-Proto": "https"})
+	assert.Equal(t, "max-age=600", w.Header().Get("Strict-Transport-Security"))
+
+	req := httptest.NewRequest("GET", "/api/ping", nil)
+	req.TLS = &tls.ConnectionState{}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "max-age=600", w.Header().Get("Strict-Transport-Security"))
+
+	// Empty values omit their header
+	w = serve(newMiddlewareRouter(config.ServerConfig{}), "GET", "/api/ping", map[string]string{"X-Forwarded-Proto": "https"})
+	assert.Empty(t, w.Header().Get("Content-Security-Policy"))
+	assert.Empty(t, w.Header().Get("X-Frame-Options"))
+	assert.Empty(t, w.Header().Get("Strict-Transport-Security"))
+}
+
+// fakeAviClient is a controller session; calls other than Close aren't implemented
+type fakeAviClient struct {
+	AviClientInterface
+	username string
+	closed   atomic.Bool
+}
+
+func (f *fakeAviClient) Close() error {
+	f.closed.Store(true)
+	return nil
+}
+
+// newTestUserClients returns operator clients whose logins open fake sessions, or fail for the
+// password "wrong"
+func newTestUserClients(cfg config.AviUsersConfig) *userClients {
+	u := newUserClients(&fakeAviClient{username: "agent"}, config.AviConfig{Host: "controller.example.com"}, cfg, zap.NewNop())
+	u.connect = func(cfg *config.AviConfig, logger *zap.Logger) (AviClientInterface, error) {
+		if cfg.Password == "wrong" {
+			return nil, errors.New("invalid credentials")
+		}
+		return &fakeAviClient{username: cfg.Username}, nil
+	}
+	return u
+}
+
+// userForRequest resolves the Avi account of a request from remoteAddr with the given headers
+func userForRequest(u *userClients, remoteAddr string, headers map[string]string) (aviUser, bool) {
+	req := httptest.NewRequest("GET", "/api/chat", nil)
+	req.RemoteAddr = remoteAddr
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = req
+	return u.userFor(c, "X-Remote-User")
+}
+
+func TestUserForMap(t *testing.T) {
+	accounts := []config.AviUserAccount{
+		{Operator: "Alice.Smith@example.com", Username: "asmith", Password: "one"},
+		{Operator: "bob", Password: "two"},
+	}
+
+	t.Run("trusted proxies", func(t *testing.T) {
+		u := newTestUserClients(config.AviUsersConfig{Mode: aviUsersMap, Fallback: "deny", Accounts: accounts,
+			TrustedProxies: []string{"10.0.0.5", "192.168.10.0/24"}})
+
+		user, ok := userForRequest(u, "10.0.0.5:40000", map[string]string{"X-Remote-User": "alice.smith@example.com"})
+		require.True(t, ok)
+		require.NoError(t, user.err)
+		assert.Equal(t, aviUser{operator: "alice.smith@example.com", username: "asmith", password: "one"}, user, "operators match case-insensitively")
+
+		user, ok = userForRequest(u, "192.168.10.20:40000", map[string]string{"X-Remote-User": "bob"})
+		require.True(t, ok)
+		assert.Equal(t, "bob", user.username, "the operator name is the default username")
+
+		user, ok = userForRequest(u, "10.0.0.6:40000", map[string]string{"X-Remote-User": "bob"})
+		require.True(t, ok)
+		assert.ErrorContains(t, user.err, "wasn't named by the trusted proxy")
+		assert.Empty(t, user.username)
+
+		_, ok = userForRequest(u, "10.0.0.6:40000", nil)
+		assert.False(t, ok, "requests without an operator use the agent's account")
+
+		user, ok = userForRequest(u, "10.0.0.5:40000", map[string]string{"X-Remote-User": "carol"})
+		require.True(t, ok)
+		assert.ErrorContains(t, user.err, "no Avi account is mapped to operator carol")
+	})
+
+	t.Run("proxy secret", func(t *testing.T) {
+		u := newTestUserClients(config.AviUsersConfig{Mode: aviUsersMap, Fallback: "shared", Accounts: accounts,
+			ProxySecret: "s3cret", ProxySecretHeader: "X-Proxy-Secret"})
+
+		user, ok := userForRequest(u, "203.0.113.9:40000", map[string]string{"X-Remote-User": "bob", "X-Proxy-Secret": "s3cret"})
+		require.True(t, ok)
+		assert.Equal(t, "bob", user.username)
+
+		user, ok = userForRequest(u, "203.0.113.9:40000", map[string]string{"X-Remote-User": "bob", "X-Proxy-Secret": "guess"})
+		require.True(t, ok, "a forged operator is refused even with the shared fallback")
+		assert.Error(t, user.err)
+
+		_, ok = userForRequest(u, "203.0.113.9:40000", map[string]string{"X-Remote-User": "carol", "X-Proxy-Secret": "s3cret"})
+		assert.False(t, ok, "operators without an account use the agent's account with the shared fallback")
+	})
+
+	t.Run("address and secret", func(t *testing.T) {
+		u := newTestUserClients(config.AviUsersConfig{Mode: aviUsersMap, Fallback: "deny", Accounts: accounts,
+			TrustedProxies: []string{"10.0.0.0/8"}, ProxySecret: "s3cret", ProxySecretHeader: "X-Proxy-Secret"})
+
+		user, _ := userForRequest(u, "10.1.2.3:40000", map[string]string{"X-Remote-User": "bob", "X-Proxy-Secret": "s3cret"})
+		assert.NoError(t, user.err)
+		user, _ = userForRequest(u, "10.1.2.3:40000", map[string]string{"X-Remote-User": "bob"})
+		assert.Error(t, user.err, "both checks apply")
+		user, _ = userForRequest(u, "[::ffff:10.1.2.3]:40000", map[string]string{"X-Remote-User": "bob", "X-Proxy-Secret": "s3cret"})
+		assert.NoError(t, user.err, "IPv4-mapped addresses match IPv4 networks")
+	})
+}
+
+func TestUserForPassthrough(t *testing.T) {
+	u := newTestUserClients(config.AviUsersConfig{Mode: aviUsersPassthrough, Fallback: "deny"})
+
+	req := httptest.NewRequest("GET", "/api/chat", nil)
+	req.SetBasicAuth("asmith", "one")
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = req
+	user, ok := u.userFor(c, "X-Remote-User")
+	require.True(t, ok)
+	assert.Equal(t, aviUser{operator: "asmith", username: "asmith", password: "one"}, user)
+
+	// The session is the credentials' user's, whatever the operator header says
+	req.Header.Set("X-Remote-User", "ASmith")
+	user, ok = u.userFor(c, "X-Remote-User")
+	require.True(t, ok)
+	assert.Equal(t, aviUser{operator: "asmith", username: "asmith", password: "one"}, user)
+	req.Header.Set("X-Remote-User", "bob")
+	user, ok = u.userFor(c, "X-Remote-User")
+	require.True(t, ok)
+	assert.Equal(t, "asmith", user.operator)
+	assert.ErrorContains(t, user.err, "operator bob doesn't match the credentials of asmith")
+
+	cfg := &config.Config{AviUsers: config.AviUsersConfig{Mode: aviUsersPassthrough}}
+	cfg.Audit.OperatorHeader = "X-Remote-User"
+	s := &Server{config: cfg, aviUsers: u}
+	router := gin.New()
+	router.Use(s.aviUserMiddleware())
+	router.GET("/api/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+	credentials := "Basic " + base64.StdEncoding.EncodeToString([]byte("asmith:one"))
+	assert.Equal(t, http.StatusOK, serve(router, "GET", "/api/ping", map[string]string{"Authorization": credentials, "X-Remote-User": "asmith"}).Code)
+	assert.Equal(t, http.StatusForbidden, serve(router, "GET", "/api/ping", map[string]string{"Authorization": credentials, "X-Remote-User": "bob"}).Code)
+
+	user, ok = userForRequest(u, "10.0.0.5:40000", map[string]string{"X-Remote-User": "bob"})
+	require.True(t, ok)
+	assert.ErrorContains(t, user.err, "no Avi account is mapped to operator bob")
+}
+
+func TestUserClientsSessions(t *testing.T) {
+	u := newTestUserClients(config.AviUsersConfig{Mode: aviUsersMap})
+
+	client, err := u.client(context.Background())
+	require.NoError(t, err)
+	assert.Same(t, u.shared, client, "calls without an operator use the agent's account")
+
+	_, err = u.client(withAviUser(context.Background(), aviUser{operator: "carol", err: errors.New("no Avi account is mapped to operator carol")}))
+	assert.ErrorContains(t, err, "carol")
+
+	alice := withAviUser(context.Background(), aviUser{operator: "alice", username: "asmith", password: "one"})
+	first, err := u.client(alice)
+	require.NoError(t, err)
+	assert.Equal(t, "asmith", first.(*fakeAviClient).username)
+	again, err := u.client(alice)
+	require.NoError(t, err)
+	assert.Same(t, first, again, "the session is reused")
+
+	// A failed login with new credentials keeps the open session
+	_, err = u.client(withAviUser(context.Background(), aviUser{operator: "alice", username: "asmith", password: "wrong"}))
+	assert.ErrorContains(t, err, "failed to log in to the Avi controller as asmith")
+	assert.False(t, first.(*fakeAviClient).closed.Load())
+	again, err = u.client(alice)
+	require.NoError(t, err)
+	assert.Same(t, first, again)
+
+	// Changed credentials replace the session once the new login succeeds
+	rotated, err := u.client(withAviUser(context.Background(), aviUser{operator: "alice", username: "asmith", password: "two"}))
+	require.NoError(t, err)
+	assert.NotSame(t, first, rotated)
+	assert.True(t, first.(*fakeAviClient).closed.Load())
+
+	require.NoError(t, u.Close())
+	assert.True(t, rotated.(*fakeAviClient).closed.Load())
+	assert.True(t, u.shared.(*fakeAviClient).closed.Load())
+}
+
+func TestUserClientsLoginOutsideLock(t *testing.T) {
+	u := newTestUserClients(config.AviUsersConfig{Mode: aviUsersMap})
+	bob := withAviUser(context.Background(), aviUser{operator: "bob", username: "bob", password: "two"})
+	_, err := u.client(bob)
+	require.NoError(t, err)
+
+	// Alice's login hangs on the controller
+	release := make(chan struct{})
+	started := make(chan struct{})
+	connect := u.connect
+	u.connect = func(cfg *config.AviConfig, logger *zap.Logger) (AviClientInterface, error) {
+		if cfg.Username == "asmith" {
+			close(started)
+			<-release
+		}
+		return connect(cfg, logger)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := u.client(withAviUser(context.Background(), aviUser{operator: "alice", username: "asmith", password: "one"}))
+		done <- err
+	}()
+	<-started
+
+	answered := make(chan struct{})
+	go func() {
+		u.client(bob)
+		close(answered)
+	}()
+	select {
+	case <-answered:
+	case <-time.After(time.Second):
+		t.Fatal("another operator's call waited for the login")
+	}
+	close(release)
+	assert.NoError(t, <-done)
+}
+
+func TestNeedsApproval(t *testing.T) {
+	store, err := workflow.NewStore(config.WorkflowsConfig{}, zap.NewNop())
+	require.NoError(t, err)
+	s := &Server{workflows: store}
+	call := func(name string, args map[string]interface{}) llm.ToolCall {
+		return llm.ToolCall{Function: llm.ToolCallFunction{Name: name}, Args: args}
+	}
+
+	assert.False(t, s.needsApproval(call("service_engine_maintenance", map[string]interface{}{"service_engine": "se-a", "step": "plan"})))
+	assert.True(t, s.needsApproval(call("service_engine_maintenance", map[string]interface{}{"service_engine": "se-a", "step": "disable"})))
+	assert.False(t, s.needsApproval(call("service_engine_maintenance", map[string]interface{}{"service_engine": "se-a", "step": "wait"})))
+	assert.True(t, s.needsApproval(call("service_engine_maintenance", map[string]interface{}{"service_engine": "se-a", "step": "enable"})))
+	assert.False(t, s.needsApproval(call("list_virtual_services", nil)))
+
+	// Resuming a maintenance run needs approval when its next step does
+	run, err := store.Start(workflowMaintenance, "se-a", "alice", nil, maintenanceRunSteps)
+	require.NoError(t, err)
+	resume := call("resume_workflow", map[string]interface{}{"id": run.ID})
+	assert.True(t, s.needsApproval(resume), "next step disable")
+	store.SetStep(run.ID, 0, workflow.StepDone, "", nil)
+	assert.False(t, s.needsApproval(resume), "next step wait")
+	assert.False(t, s.needsApproval(call("resume_workflow", map[string]interface{}{"id": "missing"})))
+}
+
+// genericController records the generic operations it is sent
+type genericController struct {
+	AviClientInterface
+	calls []string
+}
+
+func (g *genericController) ExecuteGenericOperation(ctx context.Context, method, endpoint string, body interface{}, params map[string]string) (interface{}, error) {
+	g.calls = append(g.calls, method+" "+endpoint)
+	return map[string]interface{}{}, nil
+}
+
+func TestGenericOperationGuardrails(t *testing.T) {
+	controller := &genericController{}
+	cfg := &config.Config{}
+	cfg.Tools.GenericOperation = config.GenericOperationConfig{Methods: []string{"GET"}, MaxBodyBytes: 64}
+	s := &Server{config: cfg, logger: zap.NewNop(), aviClient: controller}
+	generic := func(args map[string]interface{}) llm.ToolCall {
+		return llm.ToolCall{Function: llm.ToolCallFunction{Name: "execute_generic_operation"}, Args: args}
+	}
+	run := func(args map[string]interface{}) error {
+		_, err := s.executeToolCall(context.Background(), generic(args))
+		return err
+	}
+
+	// Read-only by default
+	assert.NoError(t, run(map[string]interface{}{"method": "get", "endpoint": "/api/cluster"}))
+	err := run(map[string]interface{}{"method": "DELETE", "endpoint": "/virtualservice/vs-1"})
+	assert.ErrorContains(t, err, "method DELETE is not allowed for generic operations in this deployment (allowed: GET)")
+	assert.False(t, s.needsApproval(generic(map[string]interface{}{"method": "DELETE", "endpoint": "/virtualservice/vs-1"})),
+		"a refused call isn't held for approval")
+
+	cfg.Tools.GenericOperation.Methods = []string{"GET", "PUT"}
+	cfg.Tools.GenericOperation.Endpoints = []string{"/pool", "/api/healthmonitor/"}
+	assert.NoError(t, run(map[string]interface{}{"method": "PUT", "endpoint": "pool/pool-1", "body": map[string]interface{}{"enabled": true}}))
+	assert.NoError(t, run(map[string]interface{}{"method": "GET", "endpoint": "/healthmonitor?name=hm"}))
+	assert.ErrorContains(t, run(map[string]interface{}{"method": "GET", "endpoint": "/poolgroup"}), "endpoint /poolgroup is not allowed")
+	assert.ErrorContains(t, run(map[string]interface{}{"method": "GET", "endpoint": "/api/pool/../user"}), "must not leave /api")
+	assert.ErrorContains(t, run(map[string]interface{}{"method": "PUT", "endpoint": "/pool/pool-1", "body": map[string]interface{}{"description": strings.Repeat("x", 64)}}),
+		"exceeds the 64 bytes allowed")
+	assert.Equal(t, []string{"GET /api/cluster", "PUT pool/pool-1", "GET /healthmonitor?name=hm"}, controller.calls)
+
+	// Allowed changes wait for the operator's approval in the web UI and API
+	assert.True(t, s.needsApproval(generic(map[string]interface{}{"method": "PUT", "endpoint": "/pool/pool-1"})))
+	assert.False(t, s.needsApproval(generic(map[string]interface{}{"method": "GET", "endpoint": "/pool"})))
+
+	// The role is checked against the object type of the endpoint
+	s.permissions = &avi.Permissions{Role: "Application-Operator", Access: map[string]string{"PERMISSION_POOL": avi.AccessRead}}
+	assert.NoError(t, run(map[string]interface{}{"method": "GET", "endpoint": "/pool"}))
+	assert.ErrorContains(t, run(map[string]interface{}{"method": "PUT", "endpoint": "/pool/pool-1"}), "no write access to PERMISSION_POOL")
+	assert.True(t, s.permissions.AllowsTool("execute_generic_operation"))
+}
+
+func TestCustomTool(t *testing.T) {
+	controller := &genericController{}
+	cfg := &config.Config{}
+	cfg.Tools.GenericOperation = config.GenericOperationConfig{Methods: []string{"GET"}, Endpoints: []string{"/cluster"}}
+	cfg.Tools.Custom = []config.CustomToolConfig{{
+		Name:        "scale_out_vs",
+		Description: "Scale out a virtual service",
+		Method:      "POST",
+		Endpoint:    "/virtualservice/{uuid}/scaleout",
+		Parameters: map[string]interface{}{
+			"properties": map[string]interface{}{"uuid": map[string]interface{}{"type": "string"}},
+			"required":   []interface{}{"uuid"},
+		},
+	}}
+	tools, err := llm.NewTools(cfg.Tools)
+	require.NoError(t, err)
+	s := &Server{config: cfg, logger: zap.NewNop(), aviClient: controller, tools: tools}
+	scale := llm.ToolCall{Function: llm.ToolCallFunction{Name: "scale_out_vs"}, Args: map[string]interface{}{"uuid": "vs-1"}}
+
+	// Not held to the generic operation limits, but a change to approve
+	_, err = s.executeToolCall(context.Background(), scale)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"POST /virtualservice/vs-1/scaleout"}, controller.calls)
+	assert.True(t, s.needsApproval(scale))
+
+	// The role is checked against the object type of the endpoint
+	s.permissions = &avi.Permissions{Role: "Application-Operator", Access: map[string]string{"PERMISSION_VIRTUALSERVICE": avi.AccessRead}}
+	_, err = s.executeToolCall(context.Background(), scale)
+	assert.ErrorContains(t, err, "no write access to PERMISSION_VIRTUALSERVICE")
+	assert.Len(t, controller.calls, 1)
+}
+
+// emptyController answers every read with an empty collection
+type emptyController struct{}
+
+func (emptyController) ExecuteGenericOperation(ctx context.Context, method, endpoint string, body interface{}, params map[string]string) (interface{}, error) {
+	return map[string]interface{}{"results": []interface{}{}}, nil
+}
+
+func TestSimulationInventories(t *testing.T) {
+	cache := newSimulationInventories(time.Minute)
+	agent := context.Background()
+	alice := withAviUser(agent, aviUser{operator: "alice", username: "asmith", password: "one"})
+
+	shared, err := cache.inventory(agent, emptyController{}, false)
+	require.NoError(t, err)
+	again, err := cache.inventory(agent, emptyController{}, false)
+	require.NoError(t, err)
+	assert.Same(t, shared, again, "the snapshot is reused within the TTL")
+	refreshed, err := cache.inventory(agent, emptyController{}, true)
+	require.NoError(t, err)
+	assert.NotSame(t, shared, refreshed)
+
+	own, err := cache.inventory(alice, emptyController{}, false)
+	require.NoError(t, err)
+	assert.NotSame(t, refreshed, own, "operators don't see the agent's snapshot")
+	rotated, err := cache.inventory(withAviUser(agent, aviUser{operator: "alice", username: "asmith", password: "two"}), emptyController{}, false)
+	require.NoError(t, err)
+	assert.NotSame(t, own, rotated, "snapshots are kept by credentials, not by username")
+
+	_, err = cache.inventory(withAviUser(agent, aviUser{operator: "mallory", err: errors.New("no Avi account is mapped to operator mallory")}), emptyController{}, false)
+	assert.ErrorContains(t, err, "mallory", "a refused operator gets no snapshot")
+}
+
+// fakeCompleter answers every prompt with reply, or fails with err
+type fakeCompleter struct {
+	reply string
+	err   error
+}
+
+func (f fakeCompleter) Complete(ctx context.Context, model, system, prompt string) (string, error) {
+	return f.reply, f.err
+}
+
+func TestAssessSimulation(t *testing.T) {
+	report := &avi.SimulationReport{Change: "remove_server", Summary: "Removing 10.0.0.5 leaves pool web at 50% capacity."}
+	assert.Equal(t, "Safe outside peak hours.", assessSimulation(context.Background(), fakeCompleter{reply: " Safe outside peak hours.\n"}, "llama3.2", report))
+	assert.Empty(t, assessSimulation(context.Background(), fakeCompleter{err: errors.New("model unavailable")}, "llama3.2", report))
+}
+
+func TestSessionNotes(t *testing.T) {
+	s := &Server{sessions: NewSessionStore(config.PricingConfig{})}
+	session := s.sessions.GetOrCreate("", "llama3.2")
+	ctx := audit.WithActor(context.Background(), audit.Actor{Session: session.ID})
+	remember := func(ctx context.Context, note string) (interface{}, error) {
+		return s.dispatchToolCall(ctx, llm.ToolCall{Function: llm.ToolCallFunction{Name: "remember"}, Args: map[string]interface{}{"note": note}})
+	}
+
+	result, err := remember(ctx, "The change window is 22:00-23:00")
+	require.NoError(t, err)
+	assert.Equal(t, "note_1", result.(SessionNote).ID)
+	_, err = remember(ctx, "We are troubleshooting shop-web-vs")
+	require.NoError(t, err)
+	result, err = remember(ctx, "the change window is 22:00-23:00")
+	require.NoError(t, err)
+	assert.Equal(t, "note_1", result.(SessionNote).ID, "a note already kept isn't repeated")
+
+	_, err = remember(ctx, " ")
+	assert.ErrorContains(t, err, "note parameter required")
+	_, err = remember(audit.WithActor(context.Background(), audit.Actor{Session: "chatcmpl-1"}), "kept nowhere")
+	assert.ErrorContains(t, err, "has none")
+
+	result, err = s.dispatchToolCall(ctx, llm.ToolCall{Function: llm.ToolCallFunction{Name: "recall"}})
+	require.NoError(t, err)
+	assert.Len(t, result.(map[string]interface{})["notes"], 2)
+
+	// The notes lead the history sent to the model
+	history := withNotes(s.sessions.Notes(session.ID), []llm.ChatMessage{{Role: "user", Content: "hello"}})
+	require.Len(t, history, 2)
+	assert.Equal(t, "system", history[0].Role)
+	assert.Contains(t, history[0].Content, "- The change window is 22:00-23:00\n- We are troubleshooting shop-web-vs")
+	assert.Len(t, withNotes(nil, history[1:]), 1)
+
+	assert.True(t, s.sessions.DeleteNote(session.ID, "note_1"))
+	assert.False(t, s.sessions.DeleteNote(session.ID, "note_1"))
+	note, err := s.sessions.AddNote(session.ID, "Rollback owner is the network team")
+	require.NoError(t, err)
+	assert.Equal(t, "note_3", note.ID, "IDs aren't reused")
+	exported, _ := s.sessions.Export(session.ID)
+	assert.Len(t, exported.Notes, 2)
+}
+
+func TestDeploymentMemory(t *testing.T) {
+	store, err := memory.NewStore(config.MemoryConfig{}, zap.NewNop())
+	require.NoError(t, err)
+	cfg := &config.Config{Audit: config.AuditConfig{OperatorHeader: "X-Remote-User"}, Memory: config.MemoryConfig{Enabled: true, Admins: []string{"Alice"}}}
+	s := &Server{config: cfg, memory: store, logger: zap.NewNop()}
+	router := gin.New()
+	router.GET("/api/memory", s.handleListMemory)
+	router.POST("/api/memory", s.handleAddMemory)
+	router.DELETE("/api/memory/:id", s.handleDeleteMemory)
+	post := func(operator, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/memory", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Remote-User", operator)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusForbidden, post("bob", `{"text": "Never change legacy-sso-vs"}`).Code)
+	assert.Equal(t, http.StatusForbidden, post("", `{"text": "Never change legacy-sso-vs"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post("alice", `{"topic": "exceptions"}`).Code)
+	w := post("alice", `{"topic": "exceptions", "text": "Never change legacy-sso-vs"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"updated_by":"alice"`)
+	assert.Equal(t, http.StatusOK, serve(router, "GET", "/api/memory", nil).Code)
+
+	// The facts lead the history, before the session notes
+	history := s.withMemory(withNotes([]SessionNote{{ID: "note_1", Text: "The change window is 22:00-23:00"}}, nil))
+	require.Len(t, history, 2)
+	assert.Contains(t, history[0].Content, "exceptions:\n  - Never change legacy-sso-vs")
+	assert.Contains(t, history[1].Content, "change window")
+
+	assert.Equal(t, http.StatusForbidden, serve(router, "DELETE", "/api/memory/fact-1", map[string]string{"X-Remote-User": "bob"}).Code)
+	assert.Equal(t, http.StatusOK, serve(router, "DELETE", "/api/memory/fact-1", map[string]string{"X-Remote-User": "alice"}).Code)
+	assert.Len(t, s.withMemory(nil), 0)
+
+	s.memory = nil
+	assert.Equal(t, http.StatusNotFound, serve(router, "GET", "/api/memory", nil).Code)
+}
+
+func TestRuntimeLogLevel(t *testing.T) {
+	cfg := &config.Config{Audit: config.AuditConfig{OperatorHeader: "X-Remote-User"}, Log: config.LogConfig{Level: "info"}}
+	s := &Server{config: cfg, logger: zap.NewNop()}
+	router := gin.New()
+	router.GET("/api/debug/log-level", s.handleGetLogLevel)
+	router.PUT("/api/debug/log-level", s.handleSetLogLevel)
+	router.PUT("/api/debug/sessions/:id", s.handleDebugSession)
+	router.DELETE("/api/debug/sessions/:id", s.handleStopDebugSession)
+	put := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusNotFound, serve(router, "GET", "/api/debug/log-level", nil).Code, "not built by logging.New")
+
+	levels := logging.NewLevels(zapcore.InfoLevel)
+	s.SetLogLevels(levels)
+	w := put("/api/debug/log-level", `{"level": "debug"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, zapcore.DebugLevel, levels.Level())
+	assert.Equal(t, http.StatusBadRequest, put("/api/debug/log-level", `{"level": "verbose"}`).Code)
+	assert.Contains(t, serve(router, "GET", "/api/debug/log-level", nil).Body.String(), `"level":"debug"`)
+
+	// Only the requests of a debugged session are elevated
+	assert.Equal(t, http.StatusBadRequest, put("/api/debug/sessions/session_1?for=48h", "").Code)
+	require.Equal(t, http.StatusOK, put("/api/debug/sessions/session_1?for=30m", "").Code)
+	assert.True(t, levels.SessionDebugged("session_1", time.Now()))
+	levels.SetLevel(zapcore.InfoLevel)
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(levels.Wrap(core))
+	ctx := requestid.With(context.Background(), "req-1")
+	release := s.elevateSession(ctx, "session_2")
+	requestid.Logger(ctx, logger).Debug("other session")
+	release()
+	release = s.elevateSession(ctx, "session_1")
+	requestid.Logger(ctx, logger).Debug("debugged session")
+	release()
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, "debugged session", logs.All()[0].Message)
+	assert.Same(t, levels, previousLogLevels(s), "a reloaded server keeps the levels")
+
+	assert.Equal(t, http.StatusOK, serve(router, "DELETE", "/api/debug/sessions/session_1", nil).Code)
+	assert.Equal(t, http.StatusNotFound, serve(router, "DELETE", "/api/debug/sessions/session_1", nil).Code)
+}
+
+func TestCompressionMiddleware(t *testing.T) {
+	large := strings.Repeat(`{"name": "pool"}`, 200)
+	router := gin.New()
+	router.Use(compressionMiddleware(config.CompressionConfig{Enabled: true, MinSize: 1024, Level: 5}))
+	router.GET("/large", func(c *gin.Context) { c.Data(http.StatusOK, "application/json", []byte(large)) })
+	router.GET("/small", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"ok": true}) })
+	router.GET("/image", func(c *gin.Context) { c.Data(http.StatusOK, "image/png", []byte(large)) })
+	router.GET("/page", func(c *gin.Context) {
+		// Written in small pieces like a template
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		for i := 0; i < 200; i++ {
+			c.Writer.WriteString("<li>pool</li>")
+		}
+	})
+	gzipped := map[string]string{"Accept-Encoding": "br, gzip;q=0.8"}
+
+	w := serve(router, "GET", "/large", gzipped)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	reader, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, large, string(body))
+
+	w = serve(router, "GET", "/page", gzipped)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Less(t, w.Body.Len(), 200*len("<li>pool</li>"))
+
+	w = serve(router, "GET", "/small", gzipped)
+	assert.Empty(t, w.Header().Get("Content-Encoding"), "small responses are sent as is")
+	assert.Equal(t, `{"ok":true}`, w.Body.String())
+
+	assert.Empty(t, serve(router, "GET", "/image", gzipped).Header().Get("Content-Encoding"), "images are compressed already")
+	assert.Empty(t, serve(router, "GET", "/large", nil).Header().Get("Content-Encoding"))
+	assert.Empty(t, serve(router, "GET", "/large", map[string]string{"Accept-Encoding": "gzip;q=0"}).Header().Get("Content-Encoding"))
+}
+
+func TestETagMiddleware(t *testing.T) {
+	items := []string{"pool-a"}
+	router := gin.New()
+	router.Use(staticCacheMiddleware(600))
+	router.GET("/api/items", etagMiddleware(), func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"items": items}) })
+	router.GET("/static/app.js", func(c *gin.Context) { c.String(http.StatusOK, "app") })
+
+	w := serve(router, "GET", "/api/items", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	assert.Equal(t, "no-cache", w.Header().Get("Cache-Control"), "lists are revalidated")
+	assert.Equal(t, `{"items":["pool-a"]}`, w.Body.String())
+
+	w = serve(router, "GET", "/api/items", map[string]string{"If-None-Match": "W/" + etag})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	items = append(items, "pool-b")
+	w = serve(router, "GET", "/api/items", map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusOK, w.Code, "a changed list is sent again")
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+
+	assert.Equal(t, "public, max-age=600", serve(router, "GET", "/static/app.js", nil).Header().Get("Cache-Control"))
+}
+
+func TestAdminAPI(t *testing.T) {
+	cfg := &config.Config{Provider: "mistral", Admin: config.AdminConfig{Token: "admin-token"}}
+	cfg.Server.DebugEndpoints = true
+	cfg.Mistral.DefaultModel = "mistral-small-latest"
+	cfg.Mistral.APIKey = "sk-live"
+	cfg.Avi.Password = "avi-secret"
+	cfg.Scheduler.Destinations = map[string]config.ReportDestination{
+		"ops": {Type: "slack", URL: "https://hooks.slack.com/services/T0/B0/hook-id", Headers: map[string]string{"Authorization": "Bearer hook"}},
+	}
+	shared := &fakeAviClient{username: "agent"}
+	s := &Server{config: cfg, logger: zap.NewNop(), aviClient: shared, sessions: NewSessionStore(config.PricingConfig{}),
+		simulations: newSimulationInventories(time.Minute)}
+	s.simulations.byAccount["agent"] = &avi.Inventory{}
+	s.router = gin.New()
+	s.setupRoutes()
+	auth := map[string]string{"Authorization": "Bearer admin-token"}
+
+	assert.Equal(t, http.StatusUnauthorized, serve(s.router, "GET", "/admin/config", nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(s.router, "GET", "/admin/config", map[string]string{"Authorization": "Bearer wrong"}).Code)
+
+	w := serve(s.router, "GET", "/admin/config", auth)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	body := w.Body.String()
+	assert.Contains(t, body, `"default_model":"mistral-small-latest"`)
+	assert.Contains(t, body, `"url":"https://hooks.slack.com/***"`)
+	for _, secret := range []string{"sk-live", "avi-secret", "admin-token", "hook-id", "Bearer hook"} {
+		assert.NotContains(t, body, secret)
+	}
+
+	w = serve(s.router, "POST", "/admin/cache/flush", auth)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"simulation_inventories":1`)
+	assert.NotContains(t, w.Body.String(), "avi_responses", "the controller sessions cache no responses")
+
+	s.sessions.AppendExchange("session_recent", "mistral-small-latest", "list pools", "2 pools", nil)
+	s.sessions.GetOrCreate("session_old", "mistral-small-latest").Created = time.Now().Add(-2 * time.Hour)
+	w = serve(s.router, "GET", "/admin/sessions", auth)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"id":"session_recent"`)
+	assert.NotContains(t, w.Body.String(), "session_old")
+	assert.Contains(t, serve(s.router, "GET", "/admin/sessions?within=3h", auth).Body.String(), "session_old")
+	assert.Equal(t, http.StatusBadRequest, serve(s.router, "GET", "/admin/sessions?within=soon", auth).Code)
+
+	// The rendered prompt is only served to the admin token
+	assert.Equal(t, http.StatusUnauthorized, serve(s.router, "POST", "/admin/debug/prompt", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(s.router, "POST", "/admin/debug/prompt", auth).Code, "the query is required")
+	assert.Equal(t, http.StatusNotFound, serve(s.router, "POST", "/api/v1/debug/prompt", nil).Code)
+	assert.Equal(t, http.StatusNotFound, serve(s.router, "POST", "/api/debug/prompt", nil).Code)
+
+	w = serve(s.router, "GET", "/admin/tools", auth)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"least_privilege":false`)
+	assert.Contains(t, w.Body.String(), `"name":"list_virtual_services","mutating":false,"allowed":true`)
+
+	// Tools disabled by the configuration are listed, but never offered or run
+	tools, err := llm.NewTools(config.ToolsConfig{Disabled: []string{"execute_generic_operation"}})
+	require.NoError(t, err)
+	s.tools = tools
+	w = serve(s.router, "GET", "/admin/tools", auth)
+	assert.Contains(t, w.Body.String(), `"name":"execute_generic_operation","mutating":true,"allowed":true,"enabled":false`)
+	for _, tool := range s.availableTools() {
+		assert.NotEqual(t, "execute_generic_operation", tool.Function.Name)
+	}
+	_, err = s.executeToolCall(context.Background(), llm.ToolCall{Function: llm.ToolCallFunction{Name: "execute_generic_operation"},
+		Args: map[string]interface{}{"method": "DELETE", "path": "/virtualservice/vs-1"}})
+	assert.EqualError(t, err, "tool execute_generic_operation is disabled in this deployment")
+
+	// Without a token the API isn't served
+	cfg.Admin.Token = ""
+	s.router = gin.New()
+	s.setupRoutes()
+	assert.Equal(t, http.StatusNotFound, serve(s.router, "GET", "/admin/config", auth).Code)
+}
+
+func TestTruncateResult(t *testing.T) {
+	page := func(n int) []map[string]interface{} {
+		results := make([]map[string]interface{}, n)
+		for i := range results {
+			results[i] = map[string]interface{}{"name": fmt.Sprintf("pool-%d", i)}
+		}
+		return results
+	}
+	call := func(name string, args map[string]interface{}) llm.ToolCall {
+		return llm.ToolCall{Function: llm.ToolCallFunction{Name: name}, Args: args}
+	}
+	markers := func(result interface{}) map[string]truncation {
+		object, ok := result.(map[string]interface{})
+		require.True(t, ok)
+		return object[truncatedKey].(map[string]truncation)
+	}
+
+	// A complete list is left as it is
+	complete := &avi.APIResponse{Count: 2, Results: page(2)}
+	assert.Same(t, complete, truncateResult(call("list_pools", nil), complete, maxResultItems, maxResultBytes))
+
+	// A controller page of a longer collection names the next page
+	marker := markers(truncateResult(call("list_pools", map[string]interface{}{"name": "web"}), &avi.APIResponse{Count: 2000, Results: page(25)}, maxResultItems, maxResultBytes))["results"]
+	assert.Equal(t, truncation{Shown: 25, Omitted: 1975, Total: 2000, Next: map[string]interface{}{"name": "web", "page": 2, "page_size": 25}}, marker)
+
+	// Long lists are cut, and the next page starts after the items shown
+	result := truncateResult(call("list_pools", map[string]interface{}{"page": 2, "page_size": 200}), &avi.APIResponse{Count: 2000, Results: page(200)}, maxResultItems, maxResultBytes)
+	assert.Len(t, result.(map[string]interface{})["results"], maxResultItems)
+	marker = markers(result)["results"]
+	assert.Equal(t, 1950, marker.Omitted)
+	assert.Equal(t, map[string]interface{}{"page": 6, "page_size": maxResultItems}, marker.Next)
+
+	// Lists of other tools are cut with a hint, plain lists under results
+	result = truncateResult(call("get_config_changes", nil), page(120), maxResultItems, maxResultBytes)
+	marker = markers(result)["results"]
+	assert.Equal(t, 70, marker.Omitted)
+	assert.Nil(t, marker.Next)
+	assert.NotEmpty(t, marker.Hint)
+	formatted := formatResult(result)
+	assert.Contains(t, formatted, `"_truncated"`)
+	assert.Contains(t, formatted, `"omitted": 70`)
+}
+
+func TestRenderResult(t *testing.T) {
+	pools := make([]map[string]interface{}, 30)
+	for i := range pools {
+		pools[i] = map[string]interface{}{"name": fmt.Sprintf("pool-%d", i), "uuid": fmt.Sprintf("pool-uuid-%d", i),
+			"lb_algorithm": "LB_ALGORITHM_ROUND_ROBIN", "_last_modified": "1700000000000000", "servers": []interface{}{map[string]interface{}{"port": 80}}}
+	}
+	call := func(name string, args map[string]interface{}) llm.ToolCall {
+		return llm.ToolCall{Function: llm.ToolCallFunction{Name: name}, Args: args}
+	}
+	client := &fakeLLMClient{fakeCompleter: fakeCompleter{reply: "30 pools, all round robin."}}
+	cfg := &config.Config{Results: config.ResultsConfig{MaxItems: 10, MaxBytes: 48 << 10, Fields: []string{"lb_algorithm"}}}
+	s := &Server{config: cfg, logger: zap.NewNop(), llmClient: client}
+	ctx := context.Background()
+
+	// The items of the list tools are projected to results.fields and cut to results.max_items
+	text := s.renderResult(ctx, call("list_pools", nil), "llama3.2", &avi.APIResponse{Count: 30, Results: pools})
+	assert.True(t, strings.HasPrefix(text, "\n\nAPI Result:\n```json\n"))
+	assert.Contains(t, text, `"lb_algorithm": "LB_ALGORITHM_ROUND_ROBIN"`)
+	assert.Contains(t, text, `"uuid": "pool-uuid-9"`)
+	assert.NotContains(t, text, "pool-uuid-10")
+	assert.NotContains(t, text, "_last_modified")
+	assert.Contains(t, text, `"omitted": 20`)
+
+	// The fields the call asks for win, and project single objects too
+	text = s.renderResult(ctx, call("list_pools", map[string]interface{}{"fields": "servers"}), "llama3.2", &avi.APIResponse{Count: 1, Results: pools[:1]})
+	assert.Contains(t, text, `"servers"`)
+	assert.NotContains(t, text, "lb_algorithm")
+	text = s.renderResult(ctx, call("get_pool", map[string]interface{}{"uuid": "pool-uuid-0", "fields": "lb_algorithm"}), "llama3.2", pools[0])
+	assert.Contains(t, text, `"lb_algorithm"`)
+	assert.NotContains(t, text, "servers")
+
+	// Other tools' results are left as they are without requested fields
+	text = s.renderResult(ctx, call("get_pool", map[string]interface{}{"uuid": "pool-uuid-0"}), "llama3.2", pools[0])
+	assert.Contains(t, text, "_last_modified")
+
+	// Results still too large are summarized by the model, or added as JSON when it fails
+	cfg.Results.Summarize, cfg.Results.SummarizeAbove = true, 512
+	text = s.renderResult(ctx, call("list_pools", nil), "llama3.2", &avi.APIResponse{Count: 30, Results: pools})
+	assert.Regexp(t, `^\n\nAPI Result:\nSummary of the \d+-byte result of list_pools: 30 pools, all round robin\.$`, text)
+	client.fakeCompleter = fakeCompleter{err: errors.New("model unavailable")}
+	text = s.renderResult(ctx, call("list_pools", nil), "llama3.2", &avi.APIResponse{Count: 30, Results: pools})
+	assert.Contains(t, text, "```json")
+}
+
+func TestExtractToolResults(t *testing.T) {
+	s := &Server{config: &config.Config{}, logger: zap.NewNop()}
+	ctx := context.Background()
+	pool := map[string]interface{}{"name": "web-pool", "uuid": "pool-1", "servers": []interface{}{map[string]interface{}{"port": 80}}}
+	message := "Pool web-pool has one server." +
+		s.renderResult(ctx, llm.ToolCall{Function: llm.ToolCallFunction{Name: "get_pool"}}, "llama3.2", pool) +
+		"\n\nTool error (get_virtual_service): uuid is required" +
+		"\n\nAPI Result:\nSummary of the 90000-byte result of list_pools: 300 pools: all up." +
+		"\n\nAwaiting approval (delete_pool): not run yet."
+
+	text, blocks := extractToolResults(message)
+	assert.Equal(t, "Pool web-pool has one server.\n\nTool error (get_virtual_service): uuid is required\n\nAwaiting approval (delete_pool): not run yet.", text)
+	require.Len(t, blocks, 2)
+	assert.Equal(t, map[string]interface{}{"name": "web-pool", "uuid": "pool-1", "servers": []interface{}{map[string]interface{}{"port": float64(80)}}}, blocks[0].data)
+	assert.Equal(t, resultBlock{summary: "300 pools: all up."}, blocks[1])
+
+	// A result a redaction left invalid is kept as text
+	_, blocks = extractToolResults("\n\nAPI Result:\n```json\n{\"password\": [REDACTED]}\n```")
+	require.Len(t, blocks, 1)
+	assert.Equal(t, `{"password": [REDACTED]}`, blocks[0].data)
+
+	text, blocks = extractToolResults("No tools were needed.")
+	assert.Equal(t, "No tools were needed.", text)
+	assert.Empty(t, blocks)
+
+	// Typed results carry the arguments of the call, parsed when the model gave them as text
+	call := llm.ToolCall{Function: llm.ToolCallFunction{Name: "get_pool", Arguments: `{"uuid": "pool-1"}`}, InvocationID: "inv_1"}
+	assert.Equal(t, llm.ToolResult{Tool: "get_pool", InvocationID: "inv_1", Arguments: map[string]interface{}{"uuid": "pool-1"}, Status: llm.ToolResultOK},
+		newToolResult(call, llm.ToolResultOK))
+}
+
+func TestLoadUI(t *testing.T) {
+	// The embedded assets are served whatever the working directory, here the package's
+	s := &Server{config: &config.Config{Server: config.ServerConfig{UIEnabled: true}}, router: gin.New()}
+	require.Empty(t, s.loadUI(templateFuncs()))
+	w := serve(s.router, "GET", "/static/css/style.css", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, w.Header().Get("ETag"), "embedded files are revalidated by ETag")
+	assert.Equal(t, http.StatusNotFound, serve(s.router, "GET", "/static/missing.js", nil).Code)
+
+	// A development directory replaces them
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "templates"), 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "static"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "templates", "index.html"), []byte(`{{define "index.html"}}dev{{end}}`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "static", "app.js"), []byte("dev()"), 0o644))
+	s = &Server{config: &config.Config{Server: config.ServerConfig{UIEnabled: true, UIDir: dir}}, router: gin.New()}
+	require.Empty(t, s.loadUI(templateFuncs()))
+	assert.Equal(t, "dev()", serve(s.router, "GET", "/static/app.js", nil).Body.String())
+
+	s = &Server{config: &config.Config{Server: config.ServerConfig{UIEnabled: true, UIDir: t.TempDir()}}, router: gin.New()}
+	assert.Contains(t, s.loadUI(templateFuncs()), "its templates could not be loaded")
+}
+
+func TestVersionedAPI(t *testing.T) {
+	s := &Server{config: &config.Config{Provider: "mistral"}, logger: zap.NewNop(), sessions: NewSessionStore(config.PricingConfig{})}
+	s.router = gin.New()
+	s.setupRoutes()
+
+	w := serve(s.router, "GET", "/api/v1/chat/status?session=s1", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"session":"s1","status":""}`, w.Body.String())
+	assert.Empty(t, w.Header().Get("Deprecation"))
+
+	// The unversioned paths still answer, marked deprecated
+	w = serve(s.router, "GET", "/api/chat/status?session=s1", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "true", w.Header().Get("Deprecation"))
+	assert.Equal(t, `</api/v1/chat/status>; rel="successor-version"`, w.Header().Get("Link"))
+
+	w = serve(s.router, "GET", "/api/v1/openapi.json", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var document struct {
+		OpenAPI    string                                       `json:"openapi"`
+		Paths      map[string]map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]interface{} `json:"properties"`
+				Required   []string               `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &document))
+	assert.Equal(t, "3.0.3", document.OpenAPI)
+
+	chat := document.Paths["/chat"]["post"]
+	require.NotNil(t, chat)
+	assert.Contains(t, fmt.Sprint(chat["requestBody"]), "#/components/schemas/ChatRequest")
+	assert.Contains(t, fmt.Sprint(chat["responses"]), "#/components/schemas/ChatResponse")
+	assert.Equal(t, []string{"message"}, document.Components.Schemas["ChatRequest"].Required)
+	response := document.Components.Schemas["ChatResponse"].Properties
+	assert.Contains(t, response, "message", "fields of the embedded LLM response are promoted")
+	assert.Contains(t, response, "session_usage")
+
+	assert.Contains(t, fmt.Sprint(document.Paths["/tools/invocations/{id}"]["get"]["parameters"]), "in:path")
+	assert.Contains(t, document.Paths["/avi/{path}"], "delete")
+	assert.NotContains(t, document.Paths, "/debug/prompt", "debug endpoints are disabled")
+	assert.Contains(t, fmt.Sprint(document.Paths["/memory"]["post"]["responses"]), "201")
+}
+
+// fakeLLMClient answers every query with its number and records the history it was sent
+type fakeLLMClient struct {
+	fakeCompleter
+	histories [][]llm.ChatMessage
+}
+
+func (f *fakeLLMClient) GetAvailableModels() []string { return []string{"llama3.2"} }
+
+func (f *fakeLLMClient) ValidateModel(ctx context.Context, modelName string) (bool, error) {
+	return modelName == "llama3.2", nil
+}
+
+func (f *fakeLLMClient) ProcessNaturalLanguageQuery(ctx context.Context, query, model string, tools interface{}, conversationHistory interface{}) (*llm.LLMResponse, error) {
+	history, _ := conversationHistory.([]llm.ChatMessage)
+	f.histories = append(f.histories, history)
+	return &llm.LLMResponse{Message: fmt.Sprintf("answer %d", len(f.histories)), Model: model}, nil
+}
+
+func (f *fakeLLMClient) RenderPrompt(ctx context.Context, query, model string, tools interface{}, conversationHistory interface{}) (interface{}, error) {
+	return nil, nil
+}
+
+func TestChatSessionHistory(t *testing.T) {
+	client := &fakeLLMClient{}
+	cfg := &config.Config{Provider: "ollama"}
+	cfg.LLM.DefaultModel = "llama3.2"
+	s := &Server{config: cfg, logger: zap.NewNop(), llmClient: client, sessions: NewSessionStore(config.PricingConfig{}),
+		moderator: &moderation.Moderator{}}
+	router := gin.New()
+	router.POST("/api/v1/chat", s.handleChat)
+	chat := func(body string) chatResponse {
+		req := httptest.NewRequest("POST", "/api/v1/chat", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response chatResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	first := chat(`{"message": "which pools are down?"}`)
+	require.NotEmpty(t, first.Session)
+	assert.Empty(t, client.histories[0])
+
+	// A follow-up in the same session is sent the earlier exchange
+	chat(`{"message": "and their servers?", "session": "` + first.Session + `"}`)
+	assert.Equal(t, []llm.ChatMessage{
+		{Role: "user", Content: "which pools are down?"},
+		{Role: "assistant", Content: "answer 1"},
+	}, client.histories[1])
+
+	// Another session starts afresh
+	chat(`{"message": "list the virtual services"}`)
+	assert.Empty(t, client.histories[2])
+}
+
+// poolController is a controller with the pools web-pool and db-pool
+type poolController struct {
+	AviClientInterface
+}
+
+func (poolController) ListPools(ctx context.Context, params map[string]string) (interface{}, error) {
+	var pools []map[string]interface{}
+	for _, name := range []string{"web-pool", "db-pool"} {
+		if params["name"] == "" || params["name"] == name {
+			pools = append(pools, map[string]interface{}{"name": name, "uuid": "pool-" + name})
+		}
+	}
+	return &avi.APIResponse{Count: len(pools), Results: pools}, nil
+}
+
+func (poolController) GetPool(ctx context.Context, uuid string, params map[string]string) (interface{}, error) {
+	if !strings.HasPrefix(uuid, "pool-") {
+		return nil, fmt.Errorf("pool %s not found", uuid)
+	}
+	return map[string]interface{}{"uuid": uuid, "lb_algorithm": "LB_ALGORITHM_ROUND_ROBIN"}, nil
+}
+
+func TestSlashCommands(t *testing.T) {
+	client := &fakeLLMClient{}
+	cfg := &config.Config{Provider: "ollama"}
+	cfg.LLM.DefaultModel = "llama3.2"
+	s := &Server{config: cfg, logger: zap.NewNop(), llmClient: client, aviClient: poolController{},
+		sessions: NewSessionStore(config.PricingConfig{}), moderator: &moderation.Moderator{}}
+	router := gin.New()
+	router.POST("/api/v1/chat", s.handleChat)
+	chat := func(message string) chatResponse {
+		body, _ := json.Marshal(chatRequest{Message: message})
+		req := httptest.NewRequest("POST", "/api/v1/chat", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response chatResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	// Names are resolved to the UUID the tool takes
+	response := chat("/pool get db-pool")
+	require.Len(t, response.ToolResults, 1)
+	assert.Equal(t, "get_pool", response.ToolResults[0].Tool)
+	assert.Equal(t, map[string]interface{}{"uuid": "pool-db-pool"}, response.ToolResults[0].Arguments)
+	assert.Equal(t, map[string]interface{}{"uuid": "pool-db-pool", "lb_algorithm": "LB_ALGORITHM_ROUND_ROBIN"}, response.ToolResults[0].Data)
+	assert.Equal(t, "`/pool get db-pool` ran get_pool.", response.Message)
+	require.Len(t, response.ToolCalls, 1)
+	assert.NotEmpty(t, response.ToolCalls[0].InvocationID)
+
+	response = chat("/POOL list")
+	assert.Equal(t, llm.ToolResultOK, response.ToolResults[0].Status)
+	assert.EqualValues(t, 2, response.ToolResults[0].Data.(map[string]interface{})["count"])
+
+	// Failures are reported as tool errors, usage mistakes with the usage
+	response = chat("/pool get missing")
+	assert.Equal(t, "pool missing not found", response.ToolResults[0].Error)
+	assert.Equal(t, "Tool error (get_pool): pool missing not found", response.Message)
+	assert.Equal(t, "Usage: `/pool get <name|uuid>`", chat("/pool get").Message)
+	assert.Contains(t, chat("/pool delete web-pool").Message, "`/vs get <name|uuid>`: show a virtual service")
+
+	// The model was never asked; commands are kept in the session history
+	assert.Empty(t, client.histories)
+	session := chat("/pool list web-pool").Session
+	history := s.sessions.History(session)
+	require.Len(t, history, 2)
+	assert.Equal(t, "/pool list web-pool", history[0].Content)
+	assert.Contains(t, history[1].Content, `"pool-web-pool"`)
+
+	// Other messages starting with a slash are questions
+	chat("/api/pool returns 404, why?")
+	assert.Len(t, client.histories, 1)
+}
+
+func TestMatchIntent(t *testing.T) {
+	tests := []struct {
+		query    string
+		command  string
+		argument string
+	}{
+		{"list virtual services", "vs list", ""},
+		{"Please show me all the pools.", "pool list", ""},
+		{"what service engines do we have?", "se list", ""},
+		{"show pool Web-Pool", "pool get", "Web-Pool"},
+		{"can you describe the virtual service shop-vs", "vs get", "shop-vs"},
+		{"health of the pools", "pool health", ""},
+		{"How healthy are my virtual services?", "vs health", ""},
+		{"what version does the controller run", "controller", ""},
+		{"list virtual services in tenant shop", "", ""},
+		{"why is pool web-pool down?", "", ""},
+		{"delete pool web-pool", "", ""},
+		{"show pools then scale out web-pool", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			command, argument, ok := matchIntent(tt.query)
+			assert.Equal(t, tt.command != "", ok)
+			assert.Equal(t, tt.command, command)
+			assert.Equal(t, tt.argument, argument)
+		})
+	}
+}
+
+func TestRouteIntent(t *testing.T) {
+	client := &fakeLLMClient{}
+	cfg := &config.Config{Provider: "ollama", Routing: config.RoutingConfig{Intents: true}}
+	cfg.LLM.DefaultModel = "llama3.2"
+	s := &Server{config: cfg, logger: zap.NewNop(), llmClient: client, aviClient: poolController{},
+		sessions: NewSessionStore(config.PricingConfig{}), moderator: &moderation.Moderator{}}
+	ctx := context.Background()
+
+	command, argument, ok := s.chatCommand(ctx, "show me the pool web-pool")
+	require.True(t, ok)
+	assert.Equal(t, "get_pool", command.tool)
+	assert.Equal(t, "pool-web-pool", argument)
+
+	// The terminal chat routes read queries too
+	result, err := s.Chat(ctx, "", "", "list pools")
+	require.NoError(t, err)
+	assert.Empty(t, client.histories)
+	assert.Equal(t, llm.ToolResultOK, result.ToolResults[0].Status)
+	assert.Contains(t, result.Message, `"pool-db-pool"`)
+
+	// An object the controller doesn't know is left to the model, as are disabled tools
+	_, _, ok = s.chatCommand(ctx, "show pool status")
+	assert.False(t, ok)
+	s.tools, err = llm.NewTools(config.ToolsConfig{Disabled: []string{"list_pools"}})
+	require.NoError(t, err)
+	_, _, ok = s.chatCommand(ctx, "list pools")
+	assert.False(t, ok)
+
+	cfg.Routing.Intents = false
+	_, _, ok = s.chatCommand(ctx, "list virtual services")
+	assert.False(t, ok)
+}
+
+func TestPlans(t *testing.T) {
+	workflows, err := workflow.NewStore(config.WorkflowsConfig{MaxRuns: 10}, zap.NewNop())
+	require.NoError(t, err)
+	client := &fakeLLMClient{}
+	cfg := &config.Config{Provider: "ollama"}
+	cfg.LLM.DefaultModel = "llama3.2"
+	cfg.Audit.OperatorHeader = "X-Operator"
+	s := &Server{config: cfg, logger: zap.NewNop(), llmClient: client, aviClient: poolController{},
+		sessions: NewSessionStore(config.PricingConfig{}), moderator: &moderation.Moderator{}, workflows: workflows}
+	s.router = gin.New()
+	s.setupRoutes()
+	post := func(path, operator, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Operator", operator)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	client.reply = "Here is the plan:\n" + `{"summary": "Check the pools", "steps": [
+		{"tool": "list_pools", "arguments": {"name": "web-pool"}, "reason": "find the pool"},
+		{"tool": "get_pool", "arguments": {"uuid": "pool-web-pool"}, "reason": "read its configuration"},
+		{"tool": "get_pool", "arguments": {"uuid": "gone"}, "reason": "read the old pool"},
+		{"tool": "get_pool", "arguments": {"uuid": "pool-db-pool"}, "reason": "compare"}]}`
+	w := post("/api/v1/plans", "alice", `{"message": "compare web-pool with its old pool"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var plan planView
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &plan))
+	assert.Equal(t, workflow.StateProposed, plan.State)
+	assert.Equal(t, "Check the pools", plan.Summary)
+	require.Len(t, plan.Steps, 4)
+	assert.Equal(t, "find the pool", plan.Steps[0].Reason)
+	assert.Equal(t, workflow.StepPending, plan.Steps[0].Status)
+	history := s.sessions.History(plan.Session)
+	require.Len(t, history, 2)
+	assert.Contains(t, history[1].Content, "1. list_pools {\"name\":\"web-pool\"} - find the pool")
+
+	// Only the operator who asked for the plan approves it; its steps then run in order until one fails
+	assert.Equal(t, http.StatusForbidden, post("/api/v1/plans/"+plan.ID+"/approve", "bob", "").Code)
+	w = post("/api/v1/plans/"+plan.ID+"/approve", "alice", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &plan))
+	assert.Equal(t, workflow.StateFailed, plan.State)
+	assert.Equal(t, []string{workflow.StepDone, workflow.StepDone, workflow.StepFailed, workflow.StepPending},
+		[]string{plan.Steps[0].Status, plan.Steps[1].Status, plan.Steps[2].Status, plan.Steps[3].Status})
+	assert.Contains(t, plan.Steps[2].Error, "pool gone not found")
+	assert.Equal(t, "LB_ALGORITHM_ROUND_ROBIN", plan.Steps[1].Result.(map[string]interface{})["lb_algorithm"])
+	assert.Equal(t, http.StatusConflict, post("/api/v1/plans/"+plan.ID+"/approve", "alice", "").Code)
+	history = s.sessions.History(plan.Session)
+	assert.Contains(t, history[len(history)-1].Content, "[failed]: pool gone not found")
+
+	// A rejected plan runs nothing
+	w = post("/api/v1/plans", "", `{"message": "check the pools"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &plan))
+	w = post("/api/v1/plans/"+plan.ID+"/reject", "", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &plan))
+	assert.Equal(t, workflow.StateAborted, plan.State)
+	assert.Equal(t, http.StatusConflict, post("/api/v1/plans/"+plan.ID+"/approve", "", "").Code)
+
+	// Plans are checked like tool calls
+	client.reply = `{"summary": "x", "steps": [{"tool": "drop_everything", "reason": "why not"}]}`
+	w = post("/api/v1/plans", "", `{"message": "clean up"}`)
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Contains(t, w.Body.String(), `unknown tool \"drop_everything\"`)
+	client.reply = `{"summary": "I can't tell which pool is meant", "steps": []}`
+	w = post("/api/v1/plans", "", `{"message": "fix it"}`)
+	assert.Contains(t, w.Body.String(), "can't tell which pool")
+	client.reply = "I'd rather not"
+	assert.Equal(t, http.StatusBadGateway, post("/api/v1/plans", "", `{"message": "fix it"}`).Code)
+}
+
+// versionedPools is a controller whose pools change version with every update
+type versionedPools struct {
+	AviClientInterface
+	version int
+}
+
+func (v *versionedPools) ExecuteGenericOperation(ctx context.Context, method, endpoint string, body interface{}, params map[string]string) (interface{}, error) {
+	return map[string]interface{}{"url": "https://controller/api" + endpoint, "_last_modified": fmt.Sprint(v.version)}, nil
+}
+
+func (v *versionedPools) UpdatePool(ctx context.Context, uuid string, data map[string]interface{}) (interface{}, error) {
+	v.version++
+	return map[string]interface{}{"uuid": uuid, "name": data["name"]}, nil
+}
+
+func (v *versionedPools) GetPool(ctx context.Context, uuid string, params map[string]string) (interface{}, error) {
+	return v.ExecuteGenericOperation(ctx, http.MethodGet, "/pool/"+uuid, nil, params)
+}
+
+func (v *versionedPools) CreatePool(ctx context.Context, data map[string]interface{}) (interface{}, error) {
+	return map[string]interface{}{"url": "https://controller/api/pool/pool-new#" + fmt.Sprint(data["name"]), "_last_modified": "7"}, nil
+}
+
+func TestActionReceipts(t *testing.T) {
+	auditLog, err := audit.NewLog(config.AuditConfig{}, zap.NewNop())
+	require.NoError(t, err)
+	cfg := &config.Config{Audit: config.AuditConfig{OperatorHeader: "X-Operator"}}
+	s := &Server{config: cfg, logger: zap.NewNop(), aviClient: &versionedPools{version: 1}, auditLog: auditLog,
+		sessions: NewSessionStore(config.PricingConfig{})}
+	s.router = gin.New()
+	s.setupRoutes()
+	operator := map[string]string{"X-Operator": "alice"}
+	rerun := func(tool string, args map[string]interface{}) rerunResponse {
+		id := s.sessions.RecordInvocation("", "alice", llm.ToolCall{Function: llm.ToolCallFunction{Name: tool}, Args: args})
+		w := serve(s.router, "POST", "/api/v1/tools/invocations/"+id+"/rerun?confirm=true", operator)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response rerunResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	// An update is read from the controller before and after the change
+	update := rerun("update_pool", map[string]interface{}{"uuid": "pool-1", "name": "web"})
+	receipt := update.Receipt
+	require.NotNil(t, receipt)
+	assert.True(t, strings.HasPrefix(receipt.ID, "rcpt_"))
+	assert.Equal(t, "update_pool", receipt.Tool)
+	assert.Equal(t, "pool-1", receipt.Object)
+	assert.Equal(t, "alice", receipt.Operator)
+	assert.Equal(t, &audit.ObjectRef{URL: "/api/v1/avi/pool/pool-1", Version: "1"}, receipt.Before)
+	assert.Equal(t, &audit.ObjectRef{URL: "/api/v1/avi/pool/pool-1", Version: "2"}, receipt.After)
+	assert.Equal(t, "/api/v1/receipts/"+receipt.ID, receipt.URL)
+	assert.Contains(t, receipt.AuditURL, "/api/v1/audit/export?format=json&from=")
+
+	// A creation has no object before, and the one after is read from the result
+	created := rerun("create_pool", map[string]interface{}{"name": "api"}).Receipt
+	require.NotNil(t, created)
+	assert.Nil(t, created.Before)
+	assert.Equal(t, &audit.ObjectRef{URL: "/api/v1/avi/pool/pool-new", Version: "7"}, created.After)
+
+	// Reads don't get receipts
+	assert.Nil(t, rerun("get_pool", map[string]interface{}{"uuid": "pool-1"}).Receipt)
+
+	// Receipts are retrieved from the audit trail, by any operator
+	w := serve(s.router, "GET", receipt.URL, map[string]string{"X-Operator": "bob"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var stored audit.Receipt
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stored))
+	assert.Equal(t, *receipt, stored)
+	assert.Equal(t, http.StatusNotFound, serve(s.router, "GET", "/api/v1/receipts/rcpt_missing", nil).Code)
+	assert.Equal(t, http.StatusNotFound, serve(s.router, "GET", "/api/v1/receipts/"+receipt.AuditID, nil).Code)
+}
+
+func (v *versionedPools) DeletePool(ctx context.Context, uuid string) error {
+	v.version++
+	return nil
+}
+
+func TestApprovals(t *testing.T) {
+	auditLog, err := audit.NewLog(config.AuditConfig{}, zap.NewNop())
+	require.NoError(t, err)
+	events := make(chan notify.Event, 2)
+	channel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event notify.Event
+		_ = json.NewDecoder(r.Body).Decode(&event)
+		events <- event
+	}))
+	defer channel.Close()
+	notifier, err := notify.New(config.NotificationsConfig{Channels: map[string]config.NotificationChannel{
+		"ops": {URL: channel.URL, Events: []string{"approval.*"}},
+	}}, zap.NewNop())
+	require.NoError(t, err)
+	cfg := &config.Config{Audit: config.AuditConfig{OperatorHeader: "X-Operator"}, Approvals: config.ApprovalsConfig{
+		Approvers: []string{"carol", "alice"},
+		Rules:     []config.ApprovalRule{{Name: "deletes", Actions: []string{"delete"}}, {Name: "prod", Tenants: []string{"prod"}}},
+	}}
+	cfg.AviUsers.TrustedProxies = []string{"192.0.2.1"} // httptest requests come from 192.0.2.1
+	store, err := approvals.NewStore(cfg.Approvals, zap.NewNop())
+	require.NoError(t, err)
+	pools := &versionedPools{version: 1}
+	s := &Server{config: cfg, logger: zap.NewNop(), aviClient: pools, auditLog: auditLog, approvals: store,
+		notifier: notifier, sessions: NewSessionStore(config.PricingConfig{})}
+	s.router = gin.New()
+	s.setupRoutes()
+	rerun := func(tool string, args map[string]interface{}) *httptest.ResponseRecorder {
+		id := s.sessions.RecordInvocation("", "alice", llm.ToolCall{Function: llm.ToolCallFunction{Name: tool}, Args: args})
+		return serve(s.router, "POST", "/api/v1/tools/invocations/"+id+"/rerun?confirm=true", map[string]string{"X-Operator": "alice"})
+	}
+
+	// Deletes and changes in the prod tenant are queued; other changes are made
+	assert.Equal(t, http.StatusOK, rerun("update_pool", map[string]interface{}{"uuid": "pool-1", "name": "web"}).Code)
+	w := rerun("delete_pool", map[string]interface{}{"uuid": "pool-1"})
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var deletion approvals.Request
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &deletion))
+	assert.Equal(t, approvals.StatePending, deletion.State)
+	assert.Equal(t, "deletes", deletion.Rule)
+	assert.Equal(t, "alice", deletion.Operator)
+	assert.Equal(t, 2, pools.version, "the delete waits")
+	w = rerun("execute_generic_operation", map[string]interface{}{"method": "PUT", "endpoint": "/pool/pool-1", "tenant": "prod"})
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var prod approvals.Request
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &prod))
+	assert.Equal(t, "prod", prod.Rule)
+
+	select {
+	case event := <-events:
+		assert.Equal(t, notify.EventApprovalPending, event.Type)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no approval.requested event")
+	}
+
+	w = serve(s.router, "GET", "/api/v1/approvals?state=pending", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var pending approvalsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pending))
+	require.Len(t, pending.Approvals, 2)
+	assert.Equal(t, prod.ID, pending.Approvals[0].ID)
+
+	// Only an approver other than the operator who asked decides, named by the trusted proxy
+	assert.Equal(t, http.StatusForbidden, serve(s.router, "POST", "/api/v1/approvals/"+deletion.ID+"/approve", map[string]string{"X-Operator": "bob"}).Code)
+	spoofed := httptest.NewRequest("POST", "/api/v1/approvals/"+deletion.ID+"/approve", nil)
+	spoofed.RemoteAddr = "203.0.113.9:4242"
+	spoofed.Header.Set("X-Operator", "carol")
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, spoofed)
+	assert.Equal(t, http.StatusForbidden, w.Code, "an approver named by anyone but the proxy")
+	cfg.AviUsers.TrustedProxies = nil
+	assert.Equal(t, http.StatusForbidden, serve(s.router, "POST", "/api/v1/approvals/"+deletion.ID+"/approve", map[string]string{"X-Operator": "carol"}).Code,
+		"without a trusted proxy nobody is authenticated")
+	cfg.AviUsers.TrustedProxies = []string{"192.0.2.1"}
+	assert.Equal(t, http.StatusConflict, serve(s.router, "POST", "/api/v1/approvals/"+deletion.ID+"/approve", map[string]string{"X-Operator": "alice"}).Code)
+	assert.Equal(t, http.StatusNotFound, serve(s.router, "POST", "/api/v1/approvals/approval-9/approve", map[string]string{"X-Operator": "carol"}).Code)
+	w = serve(s.router, "POST", "/api/v1/approvals/"+deletion.ID+"/approve", map[string]string{"X-Operator": "carol"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var outcome approvalOutcome
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &outcome))
+	assert.Equal(t, approvals.StateExecuted, outcome.State)
+	assert.Equal(t, "carol", outcome.Approver)
+	require.NotNil(t, outcome.Receipt)
+	assert.Equal(t, outcome.Receipt.ID, outcome.Request.Receipt)
+	assert.Equal(t, "alice", outcome.Receipt.Operator)
+	assert.Equal(t, 3, pools.version)
+	assert.Equal(t, http.StatusConflict, serve(s.router, "POST", "/api/v1/approvals/"+deletion.ID+"/approve", map[string]string{"X-Operator": "carol"}).Code)
+
+	req := httptest.NewRequest("POST", "/api/v1/approvals/"+prod.ID+"/reject", strings.NewReader(`{"reason": "change freeze"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Operator", "carol")
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &prod))
+	assert.Equal(t, approvals.StateRejected, prod.State)
+	assert.Equal(t, "change freeze", prod.Reason)
+
+	// Both decisions are in the audit trail, and the delete was made under its approval
+	var decisions []string
+	for _, entry := range auditLog.Query(time.Time{}, time.Time{}) {
+		switch {
+		case entry.Action == audit.ActionApproval:
+			decisions = append(decisions, entry.Approval+" "+entry.Operator+" "+entry.Outcome)
+		case entry.Tool == "delete_pool":
+			assert.Equal(t, deletion.ID, entry.Approval)
+		}
+	}
+	assert.Equal(t, []string{deletion.ID + " carol approved", prod.ID + " carol rejected"}, decisions)
+}
+
+// poolObjects keeps pools the way the controller does, with a new version on every write
+type poolObjects struct {
+	AviClientInterface
+	pools   map[string]map[string]interface{}
+	version int
+}
+
+func (p *poolObjects) write(uuid string, object map[string]interface{}) map[string]interface{} {
+	p.version++
+	stored := map[string]interface{}{}
+	for key, value := range object {
+		stored[key] = value
+	}
+	stored["uuid"], stored["url"], stored["_last_modified"] = uuid, "https://controller/api/pool/"+uuid, fmt.Sprint(p.version)
+	p.pools[uuid] = stored
+	return stored
+}
+
+func (p *poolObjects) ExecuteGenericOperation(ctx context.Context, method, endpoint string, body interface{}, params map[string]string) (interface{}, error) {
+	switch method {
+	case http.MethodGet:
+		if endpoint == "/pool" {
+			results := []interface{}{}
+			for _, pool := range p.pools {
+				if pool["name"] == params["name"] {
+					results = append(results, pool)
+				}
+			}
+			return map[string]interface{}{"count": len(results), "results": results}, nil
+		}
+		if pool, ok := p.pools[strings.TrimPrefix(endpoint, "/pool/")]; ok {
+			return pool, nil
+		}
+		return nil, fmt.Errorf("%s not found", endpoint)
+	case http.MethodPut:
+		return p.write(strings.TrimPrefix(endpoint, "/pool/"), body.(map[string]interface{})), nil
+	case http.MethodPost:
+		object := body.(map[string]interface{})
+		return p.write(object["uuid"].(string), object), nil
+	case http.MethodDelete:
+		delete(p.pools, strings.TrimPrefix(endpoint, "/pool/"))
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unexpected %s %s", method, endpoint)
+}
+
+func (p *poolObjects) UpdatePool(ctx context.Context, uuid string, data map[string]interface{}) (interface{}, error) {
+	object := map[string]interface{}{}
+	for key, value := range p.pools[uuid] {
+		object[key] = value
+	}
+	for key, value := range data {
+		object[key] = value
+	}
+	return p.write(uuid, object), nil
+}
+
+func (p *poolObjects) DeletePool(ctx context.Context, uuid string) error {
+	delete(p.pools, uuid)
+	return nil
+}
+
+func (p *poolObjects) CreatePool(ctx context.Context, data map[string]interface{}) (interface{}, error) {
+	return p.write(fmt.Sprintf("pool-%d", len(p.pools)+1), data), nil
+}
+
+func TestChangeRollback(t *testing.T) {
+	auditLog, err := audit.NewLog(config.AuditConfig{}, zap.NewNop())
+	require.NoError(t, err)
+	cfg := &config.Config{Audit: config.AuditConfig{OperatorHeader: "X-Operator"}}
+	history, err := changes.NewStore(cfg.Changes, zap.NewNop())
+	require.NoError(t, err)
+	pools := &poolObjects{pools: map[string]map[string]interface{}{}}
+	pools.write("pool-1", map[string]interface{}{"name": "web", "lb_algorithm": "LB_ALGORITHM_ROUND_ROBIN"})
+	pools.write("pool-2", map[string]interface{}{"name": "api"})
+	s := &Server{config: cfg, logger: zap.NewNop(), aviClient: pools, auditLog: auditLog, changes: history,
+		sessions: NewSessionStore(config.PricingConfig{})}
+	s.router = gin.New()
+	s.setupRoutes()
+	rerun := func(operator, tool string, args map[string]interface{}) *httptest.ResponseRecorder {
+		id := s.sessions.RecordInvocation("", operator, llm.ToolCall{Function: llm.ToolCallFunction{Name: tool}, Args: args})
+		return serve(s.router, "POST", "/api/v1/tools/invocations/"+id+"/rerun?confirm=true", map[string]string{"X-Operator": operator})
+	}
+	rollback := func(id, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/changes/"+id+"/rollback", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Operator", "alice")
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Updates and deletions are recorded with the object as it was before
+	require.Equal(t, http.StatusOK, rerun("alice", "update_pool", map[string]interface{}{"uuid": "pool-1", "lb_algorithm": "LB_ALGORITHM_LEAST_CONNECTIONS"}).Code)
+	require.Equal(t, http.StatusOK, rerun("bob", "delete_pool", map[string]interface{}{"uuid": "pool-2"}).Code)
+	require.Equal(t, http.StatusOK, rerun("alice", "update_pool", map[string]interface{}{"uuid": "pool-1", "name": "web-1"}).Code)
+	recorded := history.List(changes.Filter{})
+	require.Len(t, recorded, 3)
+	first, deletion := recorded[2], recorded[1]
+	assert.Equal(t, "web", first.Name)
+	assert.Equal(t, "/api/v1/avi/pool/pool-1", first.URL)
+	assert.Equal(t, "3", first.Version)
+	assert.NotEmpty(t, first.Receipt)
+	assert.Contains(t, string(first.Before), "LB_ALGORITHM_ROUND_ROBIN")
+	assert.Equal(t, changes.ActionDelete, deletion.Action)
+	assert.Equal(t, "bob", deletion.Operator)
+
+	// An object changed again since is only rolled back with force
+	assert.Equal(t, http.StatusNotFound, rollback("chg-9", "").Code)
+	w := rollback(first.ID, "")
+	require.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "changed after "+first.ID)
+	w = rollback(first.ID, `{"force": true}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response rerunResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.NotNil(t, response.Receipt)
+	assert.Equal(t, "alice", response.Receipt.Operator)
+	assert.Equal(t, "web", pools.pools["pool-1"]["name"])
+	assert.Equal(t, "LB_ALGORITHM_ROUND_ROBIN", pools.pools["pool-1"]["lb_algorithm"])
+	assert.Equal(t, http.StatusConflict, rollback(first.ID, `{"force": true}`).Code, "already rolled back")
+	rolledBack, _ := history.Get(first.ID)
+	assert.Equal(t, "alice", rolledBack.RolledBackBy)
+	assert.Equal(t, first.ID, history.List(changes.Filter{})[0].RollbackOf)
+
+	// Undoing the last change without naming it restores the operator's own last change
+	w = rerun("bob", "rollback_change", map[string]interface{}{})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"restored":"recreated"`)
+	assert.Equal(t, "api", pools.pools["pool-2"]["name"])
+	w = rerun("bob", "rollback_change", map[string]interface{}{})
+	require.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Contains(t, w.Body.String(), "no change of yours left")
+}
+
+func TestChangeHistory(t *testing.T) {
+	auditLog, err := audit.NewLog(config.AuditConfig{}, zap.NewNop())
+	require.NoError(t, err)
+	cfg := &config.Config{Audit: config.AuditConfig{OperatorHeader: "X-Operator"}}
+	history, err := changes.NewStore(cfg.Changes, zap.NewNop())
+	require.NoError(t, err)
+	pools := &poolObjects{pools: map[string]map[string]interface{}{}}
+	pools.write("pool-1", map[string]interface{}{"name": "web", "servers": []interface{}{
+		map[string]interface{}{"ip": "10.0.0.1", "enabled": true}, map[string]interface{}{"ip": "10.0.0.2", "enabled": true}}})
+	s := &Server{config: cfg, logger: zap.NewNop(), aviClient: pools, auditLog: auditLog, changes: history,
+		sessions: NewSessionStore(config.PricingConfig{})}
+	s.router = gin.New()
+	s.setupRoutes()
+	rerun := func(tool string, args map[string]interface{}) *httptest.ResponseRecorder {
+		id := s.sessions.RecordInvocation("", "alice", llm.ToolCall{Function: llm.ToolCallFunction{Name: tool}, Args: args})
+		return serve(s.router, "POST", "/api/v1/tools/invocations/"+id+"/rerun?confirm=true", map[string]string{"X-Operator": "alice"})
+	}
+
+	// Every write is recorded with the object before and after it, creations too
+	require.Equal(t, http.StatusOK, rerun("update_pool", map[string]interface{}{"uuid": "pool-1", "servers": []interface{}{
+		map[string]interface{}{"ip": "10.0.0.1", "enabled": true}, map[string]interface{}{"ip": "10.0.0.2", "enabled": false}}}).Code)
+	require.Equal(t, http.StatusOK, rerun("create_pool", map[string]interface{}{"name": "api"}).Code)
+
+	w := serve(s.router, "GET", "/api/v1/changes?object=web&since=today", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var listed changesResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	require.Len(t, listed.Changes, 1)
+	update := listed.Changes[0]
+	assert.Equal(t, changes.ActionUpdate, update.Action)
+	assert.Equal(t, "alice", update.Operator)
+	assert.Equal(t, []changes.FieldChange{{Field: "servers[1].enabled", Before: true, After: false}}, update.Diff)
+	assert.Empty(t, update.Before, "lists leave the objects out")
+	assert.Equal(t, http.StatusBadRequest, serve(s.router, "GET", "/api/v1/changes?since=yesterday-ish", nil).Code)
+
+	w = serve(s.router, "GET", "/api/v1/changes/"+update.ID, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var view changeView
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &view))
+	assert.Contains(t, string(view.Before), `"enabled":true`)
+	assert.Contains(t, string(view.After), `"enabled":false`)
+	assert.Equal(t, http.StatusNotFound, serve(s.router, "GET", "/api/v1/changes/chg-9", nil).Code)
+
+	// The model asks for the recent changes of an object
+	result, err := s.executeToolCall(context.Background(), llm.ToolCall{Function: llm.ToolCallFunction{Name: "show_recent_changes"},
+		Args: map[string]interface{}{"object": "api", "since": "24h"}})
+	require.NoError(t, err)
+	created := result.(changesResponse).Changes
+	require.Len(t, created, 1)
+	assert.Equal(t, changes.ActionCreate, created[0].Action)
+	assert.Equal(t, []changes.FieldChange{{Field: "name", After: "api"}}, created[0].Diff)
+	_, err = s.executeToolCall(context.Background(), llm.ToolCall{Function: llm.ToolCallFunction{Name: "show_recent_changes"},
+		Args: map[string]interface{}{"since": "last tuesday"}})
+	assert.ErrorContains(t, err, "invalid since")
+
+	// Rolling a creation back deletes the object
+	w = serve(s.router, "POST", "/api/v1/changes/"+created[0].ID+"/rollback", map[string]string{"X-Operator": "alice"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotContains(t, pools.pools, "pool-2")
+	assert.Equal(t, changes.ActionDelete, history.List(changes.Filter{})[0].Action)
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 3, 4, 15, 30, 0, 0, time.UTC)
+	for value, want := range map[string]time.Time{
+		"":                     {},
+		"today":                time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC),
+		"2h":                   now.Add(-2 * time.Hour),
+		"7d":                   now.AddDate(0, 0, -7),
+		"2026-03-01":           time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		"2026-03-01T10:00:00Z": time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC),
+	} {
+		since, err := parseSince(value, now)
+		require.NoError(t, err, value)
+		assert.True(t, want.Equal(since), "%s: %s", value, since)
+	}
+	for _, value := range []string{"yesterday", "-2h", "0d"} {
+		_, err := parseSince(value, now)
+		assert.ErrorContains(t, err, "invalid since", value)
+	}
+}
+
+// summarizingClient summarizes conversations, recording the prompts it is given
+type summarizingClient struct {
+	LLMClient
+	prompts []string
+	err     error
+}
+
+func (f *summarizingClient) Complete(ctx context.Context, model, system, prompt string) (string, error) {
+	f.prompts = append(f.prompts, prompt)
+	return fmt.Sprintf("summary %d", len(f.prompts)), f.err
+}
+
+func TestDrift(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pools.yaml"),
+		[]byte("pool:\n  - name: web\n    enabled: true\n  - name: api\n    enabled: true\n  - name: db\n"), 0o644))
+	pools := &poolObjects{pools: map[string]map[string]interface{}{}}
+	pools.write("pool-1", map[string]interface{}{"name": "web", "enabled": false})
+	pools.write("pool-2", map[string]interface{}{"name": "api", "enabled": true})
+	cfg := &config.Config{Drift: config.DriftConfig{Enabled: true, Directory: dir}}
+	detector, err := drift.New(cfg.Drift, pools, nil, zap.NewNop())
+	require.NoError(t, err)
+	s := &Server{config: cfg, logger: zap.NewNop(), aviClient: pools, drift: detector, sessions: NewSessionStore(config.PricingConfig{})}
+	s.router = gin.New()
+	s.setupRoutes()
+
+	// The first request checks; the report lists every declared object
+	w := serve(s.router, "GET", "/api/v1/drift", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var report drift.Report
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, 3, report.Objects)
+	assert.Equal(t, []string{drift.StateDrifted, drift.StateInSync, drift.StateMissing},
+		[]string{report.Results[0].State, report.Results[1].State, report.Results[2].State})
+	assert.Equal(t, []drift.FieldDrift{{Field: "enabled", Declared: true, Live: false}}, report.Results[0].Fields)
+
+	// The tool lists the objects out of sync, or the one asked for
+	pools.write("pool-1", map[string]interface{}{"name": "web", "enabled": true})
+	checked, err := s.checkDrift(context.Background(), "")
+	require.NoError(t, err)
+	require.Len(t, checked.Results, 1)
+	assert.Equal(t, "db", checked.Results[0].Name)
+	checked, err = s.checkDrift(context.Background(), "WEB")
+	require.NoError(t, err)
+	require.Len(t, checked.Results, 1)
+	assert.Equal(t, drift.StateInSync, checked.Results[0].State)
+	assert.Equal(t, 2, detector.Last().InSync)
+
+	w = serve(s.router, "GET", "/api/v1/drift", nil)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, 2, report.InSync)
+
+	s.drift = nil
+	assert.Equal(t, http.StatusNotFound, serve(s.router, "GET", "/api/v1/drift?refresh=true", nil).Code)
+	_, err = s.checkDrift(context.Background(), "")
+	assert.ErrorContains(t, err, "drift.enabled")
+}
+
+// typedObjects is a controller of objects of any type, looked up by name
+type typedObjects struct {
+	AviClientInterface
+	objects map[string][]map[string]interface{}
+}
+
+func (o *typedObjects) ExecuteGenericOperation(ctx context.Context, method, endpoint string, body interface{}, params map[string]string) (interface{}, error) {
+	objType := strings.TrimPrefix(endpoint, "/")
+	switch method {
+	case http.MethodGet:
+		results := []interface{}{}
+		for _, object := range o.objects[objType] {
+			if object["name"] == params["name"] {
+				results = append(results, object)
+			}
+		}
+		return map[string]interface{}{"count": len(results), "results": results}, nil
+	case http.MethodPost:
+		object := body.(map[string]interface{})
+		object["uuid"] = fmt.Sprintf("%s-%d", objType, len(o.objects[objType])+1)
+		o.objects[objType] = append(o.objects[objType], object)
+		return object, nil
+	}
+	return nil, fmt.Errorf("unexpected %s %s", method, endpoint)
+}
+
+func TestBlueprints(t *testing.T) {
+	library, err := blueprint.Load("")
+	require.NoError(t, err)
+	controller := &typedObjects{objects: map[string][]map[string]interface{}{}}
+	workflows, err := workflow.NewStore(config.WorkflowsConfig{}, zap.NewNop())
+	require.NoError(t, err)
+	auditLog, err := audit.NewLog(config.AuditConfig{}, zap.NewNop())
+	require.NoError(t, err)
+	s := &Server{config: &config.Config{}, logger: zap.NewNop(), aviClient: controller, auditLog: auditLog, workflows: workflows,
+		blueprints: library, sessions: NewSessionStore(config.PricingConfig{})}
+	s.router = gin.New()
+	s.setupRoutes()
+	apply := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(s.router, "GET", "/api/v1/blueprints", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var listed blueprintsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	require.Len(t, listed.Blueprints, 3)
+	assert.Equal(t, "https-app", listed.Blueprints[1].Name)
+	assert.Equal(t, []string{"healthmonitor", "httppolicyset", "pool", "vsvip", "virtualservice"}, listed.Blueprints[1].Objects)
+	assert.Equal(t, http.StatusNotFound, serve(s.router, "GET", "/api/v1/blueprints/ftp-app", nil).Code)
+
+	// Missing and invalid parameters are reported before anything is created
+	w = apply("/api/v1/blueprints/https-app/apply", `{"parameters": {"name": "foo", "vip": "10.1.1.500"}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "missing servers; vip must be an IP address")
+
+	// A dry run expands and validates without creating
+	params := `{"parameters": {"name": "foo", "vip": "10.1.1.50", "servers": ["10.0.0.1", "10.0.0.2"]}}`
+	w = apply("/api/v1/blueprints/https-app/apply?dry_run=true", params)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var applied struct {
+		avi.ApplyReport
+		Blueprint string `json:"blueprint"`
+		Workflow  string `json:"workflow"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &applied))
+	assert.Equal(t, "https-app", applied.Blueprint)
+	assert.True(t, applied.DryRun)
+	assert.Equal(t, 5, applied.Succeeded)
+	assert.Empty(t, controller.objects)
+
+	// Applying creates the objects in dependency order, once
+	w = apply("/api/v1/blueprints/https-app/apply", params)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &applied))
+	assert.NotEmpty(t, applied.Workflow)
+	assert.Equal(t, avi.ApplyCreated, applied.Results[4].Status)
+	assert.Equal(t, "foo", applied.Results[4].Name)
+	require.Len(t, controller.objects["virtualservice"], 1)
+	assert.Equal(t, "/api/pool?name=foo-pool", controller.objects["virtualservice"][0]["pool_ref"])
+	assert.Len(t, controller.objects["pool"][0]["servers"], 2)
+
+	w = apply("/api/v1/blueprints/https-app/apply", params)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "healthmonitor foo-hm, httppolicyset foo-redirect")
+
+	// The model applies blueprints with the same validation
+	_, err = s.applyBlueprint(context.Background(), "l4-tcp-app", map[string]interface{}{"name": "db", "vip": "10.1.1.51", "servers": "10.0.0.5"}, false, "")
+	var paramErr *blueprint.ParameterError
+	require.ErrorAs(t, err, &paramErr)
+	assert.Equal(t, "port", paramErr.Missing[0].Name)
+	result, err := s.applyBlueprint(context.Background(), "l4-tcp-app", map[string]interface{}{"name": "db", "vip": "10.1.1.51", "port": 5432.0, "servers": "10.0.0.5"}, false, "")
+	require.NoError(t, err)
+	assert.Equal(t, 4, result.Succeeded)
+	assert.Equal(t, 5432, controller.objects["pool"][1]["default_server_port"])
+
+	// Applies through the API are held by approval rules like the tools they share a name with
+	s.config.Audit.OperatorHeader = "X-Operator"
+	s.config.AviUsers.TrustedProxies = []string{"192.0.2.1"}
+	s.config.Approvals = config.ApprovalsConfig{Approvers: []string{"carol"}, Rules: []config.ApprovalRule{{Name: "applies", Tools: []string{"apply_*"}}}}
+	s.approvals, err = approvals.NewStore(s.config.Approvals, zap.NewNop())
+	require.NoError(t, err)
+	s.notifier, err = notify.New(config.NotificationsConfig{}, zap.NewNop())
+	require.NoError(t, err)
+	params = `{"parameters": {"name": "bar", "vip": "10.1.1.52", "servers": ["10.0.0.3"]}}`
+	w = apply("/api/v1/blueprints/https-app/apply", params)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var queued approvals.Request
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &queued))
+	assert.Equal(t, "apply_blueprint", queued.Tool)
+	assert.Equal(t, "applies", queued.Rule)
+	assert.Len(t, controller.objects["virtualservice"], 2, "nothing is created until approved")
+	w = apply("/api/v1/config/apply", `{"Pool": [{"name": "baz-pool"}]}`)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	assert.Len(t, controller.objects["pool"], 2)
+	w = apply("/api/v1/config/apply?dry_run=true", `{"Pool": [{"name": "baz-pool"}]}`)
+	assert.Equal(t, http.StatusOK, w.Code, "dry runs aren't held")
+
+	w = serve(s.router, "POST", "/api/v1/approvals/"+queued.ID+"/approve", map[string]string{"X-Operator": "carol"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Len(t, controller.objects["virtualservice"], 3)
+}
+
+func TestGenerateTerraform(t *testing.T) {
+	controller := &typedObjects{objects: map[string][]map[string]interface{}{
+		"pool":          {{"name": "web-pool", "uuid": "pool-1", "health_monitor_refs": []interface{}{"https://controller/api/healthmonitor/hm-1#web-hm"}}},
+		"healthmonitor": {{"name": "web-hm", "uuid": "hm-1", "type": "HEALTH_MONITOR_HTTP"}},
+	}}
+	s := &Server{config: &config.Config{}, logger: zap.NewNop(), aviClient: controller}
+	generate := func(args map[string]interface{}) (*terraform.Configuration, error) {
+		result, err := s.executeToolCall(context.Background(), llm.ToolCall{Function: llm.ToolCallFunction{Name: "generate_terraform"}, Args: args})
+		if err != nil {
+			return nil, err
+		}
+		return result.(*terraform.Configuration), nil
+	}
+
+	// Existing objects are read with the objects they refer to, unless told otherwise
+	pool := []interface{}{map[string]interface{}{"type": "pool", "name": "web-pool"}}
+	config, err := generate(map[string]interface{}{"objects": pool})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"avi_healthmonitor.web_hm", "avi_pool.web_pool"}, config.Resources)
+	assert.Contains(t, config.HCL, "health_monitor_refs = [avi_healthmonitor.web_hm.id]")
+	config, err = generate(map[string]interface{}{"objects": pool, "include_references": false})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"data.avi_healthmonitor.web_hm"}, config.DataSources)
+
+	// A proposed change is written without reading the controller
+	config, err = generate(map[string]interface{}{"configuration": map[string]interface{}{"Pool": []interface{}{map[string]interface{}{"name": "api-pool"}}}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"avi_pool.api_pool"}, config.Resources)
+
+	_, err = generate(map[string]interface{}{"objects": []interface{}{map[string]interface{}{"name": "web-pool"}}})
+	assert.ErrorContains(t, err, "objects[0].type is required")
+	_, err = generate(map[string]interface{}{})
+	assert.EqualError(t, err, "objects or configuration parameter required")
+}
+
+func TestAnsiblePlaybook(t *testing.T) {
+	controller := &typedObjects{objects: map[string][]map[string]interface{}{
+		"pool":          {{"name": "web-pool", "uuid": "pool-1", "health_monitor_refs": []interface{}{"https://controller/api/healthmonitor/hm-1#web-hm"}}},
+		"healthmonitor": {{"name": "web-hm", "uuid": "hm-1", "type": "HEALTH_MONITOR_HTTP"}},
+	}}
+	s := &Server{config: &config.Config{}, logger: zap.NewNop(), aviClient: controller}
+
+	// The tool links to the download of the same objects
+	result, err := s.executeToolCall(context.Background(), llm.ToolCall{Function: llm.ToolCallFunction{Name: "generate_ansible_playbook"},
+		Args: map[string]interface{}{"objects": []interface{}{map[string]interface{}{"type": "pool", "name": "web-pool"}}, "include_references": false}})
+	require.NoError(t, err)
+	playbook := result.(*ansiblePlaybook)
+	assert.Equal(t, []string{"pool web-pool"}, playbook.Tasks)
+	assert.Contains(t, playbook.YAML, "- /api/healthmonitor?name=web-hm")
+	assert.Equal(t, "/api/v1/ansible/playbook?include_references=false&objects=pool%2Fweb-pool", playbook.DownloadURL)
+
+	router := gin.New()
+	router.GET("/api/v1/ansible/playbook", s.handleAnsiblePlaybook)
+	router.POST("/api/v1/ansible/playbook", s.handleProposedPlaybook)
+	w := serve(router, "GET", "/api/v1/ansible/playbook?objects=pool/web-pool", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "application/yaml", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment; filename=\"avi-playbook-")
+	assert.Contains(t, w.Body.String(), "- name: healthmonitor web-hm\n      vmware.alb.avi_healthmonitor:")
+	assert.Equal(t, http.StatusNotFound, serve(router, "GET", "/api/v1/ansible/playbook?objects=pool/api-pool", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(router, "GET", "/api/v1/ansible/playbook?objects=web-pool", nil).Code)
+
+	// A proposed change is written without reading the controller
+	req := httptest.NewRequest("POST", "/api/v1/ansible/playbook", strings.NewReader(`{"Pool": [{"name": "api-pool"}]}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "vmware.alb.avi_pool:")
+	req = httptest.NewRequest("POST", "/api/v1/ansible/playbook", strings.NewReader(`{"Pool": [{}]}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// refusingPools answers pool creation with a controller error
+type refusingPools struct {
+	versionedPools
+	err error
+}
+
+func (p *refusingPools) CreatePool(ctx context.Context, data map[string]interface{}) (interface{}, error) {
+	return nil, p.err
+}
+
+func TestControllerToolErrors(t *testing.T) {
+	controller := &refusingPools{err: fmt.Errorf("failed to create pool: %w", avi.ParseError(http.StatusConflict, []byte(`{"error": "Pool with this Name, Tenant ref already exist."}`)))}
+	s := &Server{config: &config.Config{}, logger: zap.NewNop(), aviClient: controller}
+	create := llm.ToolCall{Function: llm.ToolCallFunction{Name: "create_pool"}, Args: map[string]interface{}{"name": "web-pool"}}
+
+	// The explanation comes first, with the kind of error for clients
+	_, err := s.executeToolCall(context.Background(), create)
+	toolErr := llm.ToolErrorFrom("create_pool", err)
+	assert.Equal(t, avi.ErrorDuplicateName, toolErr.Code)
+	assert.Equal(t, "An object of this type with that name already exists in the tenant: choose another name, or update the existing object instead of creating one. (controller answered 409: Pool with this Name, Tenant ref already exist.)", toolErr.Message)
+	var aviErr *avi.AviError
+	assert.ErrorAs(t, err, &aviErr)
+
+	// Errors without an explanation keep their message
+	controller.err = avi.ParseError(http.StatusInternalServerError, []byte("upstream timed out"))
+	_, err = s.executeToolCall(context.Background(), create)
+	toolErr = llm.ToolErrorFrom("create_pool", err)
+	assert.Equal(t, avi.ErrorController, toolErr.Code)
+	assert.Equal(t, "request failed with status 500: upstream timed out", toolErr.Message)
+}
+
+// advisingLLMClient creates a pool and records the failed calls it is asked to explain
+type advisingLLMClient struct {
+	poolCreatingLLMClient
+	prompts []string
+}
+
+func (f *advisingLLMClient) Complete(ctx context.Context, model, system, prompt string) (string, error) {
+	f.prompts = append(f.prompts, prompt)
+	return " Another pool is already named api: pick another name, or update it instead. Want me to show it?\n", nil
+}
+
+func TestToolErrorAdvice(t *testing.T) {
+	auditLog, err := audit.NewLog(config.AuditConfig{}, zap.NewNop())
+	require.NoError(t, err)
+	client := &advisingLLMClient{}
+	controller := &refusingPools{err: avi.ParseError(http.StatusConflict, []byte(`{"error": "Pool with this Name, Tenant ref already exist."}`))}
+	cfg := &config.Config{Provider: "ollama", Results: config.ResultsConfig{ExplainErrors: true}}
+	cfg.LLM.DefaultModel = "llama3.2"
+	s := &Server{config: cfg, logger: zap.NewNop(), llmClient: client, aviClient: controller, auditLog: auditLog,
+		sessions: NewSessionStore(config.PricingConfig{}), moderator: &moderation.Moderator{}}
+	ctx := chat.WithHooks(context.Background(), chat.Hooks{Unattended: true})
+
+	// The model reads the attempted call with the structured error, and its advice follows the error
+	result, err := s.Chat(ctx, "", "", "create a pool api")
+	require.NoError(t, err)
+	require.Len(t, client.prompts, 1)
+	assert.JSONEq(t, `{"tool": "create_pool", "arguments": {"name": "api"}, "error": "Pool with this Name, Tenant ref already exist.",
+		"controller_status": 409, "code": "duplicate_name"}`, client.prompts[0])
+	require.Len(t, result.ToolErrors, 1)
+	assert.Equal(t, "Another pool is already named api: pick another name, or update it instead. Want me to show it?", result.ToolErrors[0].Advice)
+	assert.Contains(t, result.Message, "already exist.)\nAnother pool is already named api")
+
+	// Without results.explain_errors the error is reported as it is
+	cfg.Results.ExplainErrors = false
+	result, err = s.Chat(ctx, "", "", "create a pool api")
+	require.NoError(t, err)
+	assert.Len(t, client.prompts, 1)
+	assert.Empty(t, result.ToolErrors[0].Advice)
+}
+
+// poolCreatingLLMClient asks for a pool without saying which servers it balances
+type poolCreatingLLMClient struct {
+	fakeLLMClient
+}
+
+func (f *poolCreatingLLMClient) ProcessNaturalLanguageQuery(ctx context.Context, query, model string, tools interface{}, conversationHistory interface{}) (*llm.LLMResponse, error) {
+	f.histories = append(f.histories, nil)
+	return &llm.LLMResponse{Message: "Creating the pool.", Model: model, ToolCalls: []llm.ToolCall{
+		{Function: llm.ToolCallFunction{Name: "create_pool"}, Args: map[string]interface{}{"name": "api"}},
+	}}, nil
+}
+
+// createdPools records the pools created
+type createdPools struct {
+	versionedPools
+	created []map[string]interface{}
+}
+
+func (p *createdPools) CreatePool(ctx context.Context, data map[string]interface{}) (interface{}, error) {
+	p.created = append(p.created, data)
+	return p.versionedPools.CreatePool(ctx, data)
+}
+
+func TestCreationWizard(t *testing.T) {
+	auditLog, err := audit.NewLog(config.AuditConfig{}, zap.NewNop())
+	require.NoError(t, err)
+	client := &poolCreatingLLMClient{}
+	controller := &createdPools{}
+	cfg := &config.Config{Provider: "ollama"}
+	cfg.LLM.DefaultModel = "llama3.2"
+	s := &Server{config: cfg, logger: zap.NewNop(), llmClient: client, aviClient: controller, auditLog: auditLog,
+		sessions: NewSessionStore(config.PricingConfig{}), moderator: &moderation.Moderator{}}
+	ctx := context.Background()
+
+	// A create call lacking servers asks for them instead of running
+	result, err := s.Chat(ctx, "", "", "create a pool api")
+	require.NoError(t, err)
+	session := result.Session
+	assert.Contains(t, result.Message, "Awaiting input (create_pool): not run yet. Which backend servers should it balance?")
+	require.Len(t, result.ToolResults, 1)
+	assert.Equal(t, llm.ToolResultInput, result.ToolResults[0].Status)
+	assert.Empty(t, controller.created)
+
+	// Answers are checked without asking the model, and the question repeated until one fits
+	result, err = s.Chat(ctx, session, "", "10.0.0.1:8080, 10.0.0.300")
+	require.NoError(t, err)
+	assert.Contains(t, result.Message, `That doesn't work for servers: the answer: "10.0.0.300" is not ip or ip:port. Which backend servers`)
+	assert.Empty(t, controller.created)
+
+	// The completed call runs as the model's would, with a receipt
+	result, err = s.Chat(ctx, session, "", "10.0.0.1:8080, 10.0.0.2")
+	require.NoError(t, err)
+	assert.Len(t, client.histories, 1)
+	require.Len(t, controller.created, 1)
+	assert.Equal(t, "api", controller.created[0]["name"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"ip": map[string]interface{}{"addr": "10.0.0.1", "type": "V4"}, "port": 8080},
+		map[string]interface{}{"ip": map[string]interface{}{"addr": "10.0.0.2", "type": "V4"}},
+	}, controller.created[0]["servers"])
+	assert.True(t, strings.HasPrefix(result.Message, "That's everything create_pool needs."))
+	require.Len(t, result.ToolResults, 1)
+	assert.Equal(t, llm.ToolResultOK, result.ToolResults[0].Status)
+	assert.Len(t, result.Receipts, 1)
+	assert.Len(t, s.sessions.History(session), 6)
+
+	// The operator can cancel, and the next message goes to the model again
+	_, err = s.Chat(ctx, session, "", "create another pool")
+	require.NoError(t, err)
+	result, err = s.Chat(ctx, session, "", "Cancel.")
+	require.NoError(t, err)
+	assert.Equal(t, "Cancelled create_pool: nothing was changed.", result.Message)
+	_, waiting := s.sessions.Wizard(session)
+	assert.False(t, waiting)
+	assert.Len(t, controller.created, 1)
+
+	// Frontends nobody answers run the call as it is
+	result, err = s.Chat(chat.WithHooks(ctx, chat.Hooks{Unattended: true}), session, "", "create a pool api")
+	require.NoError(t, err)
+	assert.Len(t, controller.created, 2)
+	assert.Equal(t, llm.ToolResultOK, result.ToolResults[0].Status)
+
+	// Answers are converted to the arguments the tools take
+	vs := llm.ToolCall{Function: llm.ToolCallFunction{Name: "create_virtual_service"}}
+	missing := missingFields(vs, map[string]interface{}{"name": "web", "services": []interface{}{}})
+	require.Len(t, missing, 2)
+	for _, c := range []struct {
+		field  wizardField
+		answer string
+		want   interface{}
+	}{
+		{missing[0], "web-vip", "/api/vsvip?name=web-vip"},
+		{missing[1], "80, 443", []interface{}{map[string]interface{}{"port": 80}, map[string]interface{}{"port": 443}}},
+		{creationFields["create_health_monitor"][1], "http", "HEALTH_MONITOR_HTTP"},
+		{creationFields["create_persistence_profile"][1], "client ip address", "PERSISTENCE_TYPE_CLIENT_IP_ADDRESS"},
+	} {
+		value, err := c.field.parse(c.answer)
+		require.NoError(t, err, c.field.Arg)
+		assert.Equal(t, c.want, value, c.field.Arg)
+	}
+	_, err = creationFields["create_health_monitor"][1].parse("smtp")
+	assert.ErrorContains(t, err, "the answer must be one of HEALTH_MONITOR_HTTP")
+	_, err = missing[1].parse("80, http")
+	assert.ErrorContains(t, err, "the port must be a whole number")
+}
+
+func TestFitHistory(t *testing.T) {
+	client := &summarizingClient{}
+	cfg := &config.Config{Context: config.ContextConfig{MaxTokens: 100, KeepRecent: 2}}
+	s := &Server{config: cfg, logger: zap.NewNop(), llmClient: client, sessions: NewSessionStore(config.PricingConfig{})}
+	session := s.sessions.GetOrCreate("", "llama3.2")
+	message := func(role string, i int) llm.ChatMessage {
+		return llm.ChatMessage{Role: role, Content: fmt.Sprintf("%s message %d %s", role, i, strings.Repeat("x", 60))}
+	}
+	var history []llm.ChatMessage
+	for i := 0; i < 10; i += 2 {
+		history = append(history, message("user", i), message("assistant", i+1))
+	}
+	history[1].Content = "API Result: pool-1 is down"
+
+	// A short history is sent as it is
+	assert.Equal(t, history[:3], s.fitHistory(context.Background(), session.ID, "llama3.2", history[:3]))
+
+	// The recent messages that fit are kept, the latest tool result too, and the rest summarized
+	fitted := s.fitHistory(context.Background(), session.ID, "llama3.2", history)
+	require.Len(t, fitted, 4)
+	assert.Equal(t, llm.ChatMessage{Role: "system", Content: "Summary of the 8 earlier messages of this conversation:\nsummary 1"}, fitted[0])
+	assert.Equal(t, history[1], fitted[1])
+	assert.Equal(t, history[8:], fitted[2:])
+	assert.Contains(t, client.prompts[0], "user message 0")
+	assert.LessOrEqual(t, historyTokens(fitted), 100)
+
+	// Later turns only add the new older messages to the kept summary
+	history = append(history, message("user", 10), message("assistant", 11))
+	fitted = s.fitHistory(context.Background(), session.ID, "llama3.2", history)
+	assert.Equal(t, "Summary of the 10 earlier messages of this conversation:\nsummary 2", fitted[0].Content)
+	assert.Contains(t, client.prompts[1], "Summary so far:\nsummary 1")
+	assert.NotContains(t, client.prompts[1], "user message 0")
+	assert.Contains(t, client.prompts[1], "user message 8")
+	summary, ok := s.sessions.Summary(session.ID)
+	require.True(t, ok)
+	assert.Equal(t, HistorySummary{Text: "summary 2", Messages: 10}, summary)
+
+	// Without a summary the older messages are left out
+	client.err = errors.New("model unavailable")
+	fitted = s.fitHistory(context.Background(), "", "llama3.2", history)
+	assert.Contains(t, fitted[0].Content, "The 10 earlier messages of this conversation were left out")
+
+	// No budget sends everything
+	cfg.Context.MaxTokens = 0
+	assert.Equal(t, history, s.fitHistory(context.Background(), session.ID, "llama3.2", history))
+}
+
+func TestRunbooks(t *testing.T) {
+	events := make(chan notify.Event, 1)
+	channel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event notify.Event
+		_ = json.NewDecoder(r.Body).Decode(&event)
+		events <- event
+	}))
+	defer channel.Close()
+	notifier, err := notify.New(config.NotificationsConfig{Channels: map[string]config.NotificationChannel{
+		"ops": {URL: channel.URL, Events: []string{"runbook.*"}},
+	}}, zap.NewNop())
+	require.NoError(t, err)
+	workflows, err := workflow.NewStore(config.WorkflowsConfig{MaxRuns: 10}, zap.NewNop())
+	require.NoError(t, err)
+
+	client := &fakeLLMClient{}
+	cfg := &config.Config{Provider: "ollama", Runbooks: config.RunbooksConfig{Token: "s3cret", Playbooks: []config.Runbook{{
+		Name:       "pool-check",
+		Parameters: []string{"pool"},
+		Steps:      []string{"Is pool {{.pool}} up?", "Which servers of {{.pool}} are down?"},
+	}}}}
+	cfg.LLM.DefaultModel = "llama3.2"
+	s := &Server{config: cfg, logger: zap.NewNop(), llmClient: client, sessions: NewSessionStore(config.PricingConfig{}),
+		moderator: &moderation.Moderator{}, workflows: workflows, notifier: notifier}
+	s.router = gin.New()
+	s.setupRoutes()
+	trigger := func(path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("X-Runbook-Token", token)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusNotFound, trigger("/api/v1/runbooks/missing/trigger", "s3cret", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, trigger("/api/v1/runbooks/pool-check/trigger", "wrong", "").Code)
+	w := trigger("/api/v1/runbooks/pool-check/trigger", "s3cret", `{"parameters": {}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "requires the parameters pool")
+
+	// The steps are sent in order to one session, and the outcome returned and published
+	w = trigger("/api/v1/runbooks/pool-check/trigger?wait=true", "s3cret", `{"parameters": {"pool": "web-pool"}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var outcome runbookOutcome
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &outcome))
+	assert.Equal(t, workflow.StateCompleted, outcome.State)
+	assert.Equal(t, "answer 2", outcome.Answer)
+	require.Len(t, outcome.Steps, 2)
+	assert.Equal(t, "Is pool web-pool up?", outcome.Steps[0].Message)
+	assert.Equal(t, []llm.ChatMessage{
+		{Role: "user", Content: "Is pool web-pool up?"},
+		{Role: "assistant", Content: "answer 1"},
+	}, client.histories[1])
+
+	run, ok := workflows.Get(outcome.Workflow)
+	require.True(t, ok)
+	assert.Equal(t, workflowRunbook, run.Kind)
+	assert.Equal(t, "runbook:pool-check", run.Operator)
+	assert.Equal(t, "answer 1", run.Steps[0].Detail)
+
+	select {
+	case event := <-events:
+		assert.Equal(t, notify.EventRunbookDone, event.Type)
+		assert.Equal(t, "answer 2", event.Summary)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the outcome wasn't published")
+	}
+
+	// Without waiting the trigger is answered once the run has started
+	w = trigger("/api/v1/runbooks/pool-check/trigger?token=s3cret", "", `{"parameters": {"pool": "db-pool"}, "session": "`+outcome.Session+`"}`)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &outcome))
+	assert.Equal(t, workflow.StateRunning, outcome.State)
+	select {
+	case event := <-events:
+		assert.Equal(t, notify.EventRunbookDone, event.Type)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the outcome wasn't published")
+	}
+	assert.Len(t, s.sessions.History(outcome.Session), 8)
+}
+
+// flakyLLMClient fails with some models and answers with malformed tool calls with others,
+// recording the models it was asked
+type flakyLLMClient struct {
+	fakeLLMClient
+	asked []string
+}
+
+func (f *flakyLLMClient) ProcessNaturalLanguageQuery(ctx context.Context, query, model string, tools interface{}, conversationHistory interface{}) (*llm.LLMResponse, error) {
+	f.asked = append(f.asked, model)
+	switch model {
+	case "down":
+		return nil, errors.New("connection refused")
+	case "slow":
+		<-ctx.Done()
+		return nil, ctx.Err()
+	case "garbled":
+		return &llm.LLMResponse{Model: model, ToolCalls: []llm.ToolCall{{Function: llm.ToolCallFunction{Name: "list_pools", Arguments: "{pool"}}}}, nil
+	}
+	return &llm.LLMResponse{Message: "answer from " + model, Model: model, Usage: llm.Usage{TotalTokens: 10}}, nil
+}
+
+func TestModelFallback(t *testing.T) {
+	client := &flakyLLMClient{}
+	cfg := &config.Config{Provider: "ollama", Fallback: config.FallbackConfig{Timeout: 1, Models: []config.FallbackModel{
+		{Model: "slow"}, {Model: "garbled"}, {Model: "llama3.2"}, {Model: "mistral-small", Provider: "mistral"},
+	}}}
+	s := &Server{config: cfg, logger: zap.NewNop(), llmClient: client, sessions: NewSessionStore(config.PricingConfig{})}
+
+	// A failure, a timeout and malformed tool calls pass the question on, in order
+	response, err := s.queryModels(context.Background(), "which pools are down?", "down", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"down", "slow", "garbled", "llama3.2"}, client.asked, "the Mistral model has no client")
+	assert.Equal(t, "answer from llama3.2", response.Message)
+	assert.Equal(t, "llama3.2", response.Model)
+	assert.Equal(t, "ollama", response.Provider)
+	require.Len(t, response.Fallbacks, 3)
+	assert.Equal(t, llm.ModelFailure{Provider: "ollama", Model: "down", Reason: "connection refused"}, response.Fallbacks[0])
+	assert.Equal(t, "no answer within 1s", response.Fallbacks[1].Reason)
+	assert.Contains(t, response.Fallbacks[2].Reason, "malformed tool call")
+	assert.Equal(t, []string{"Answered by the fallback model llama3.2 after down, slow, garbled failed"}, response.Notices)
+	assert.Equal(t, "llama3.2", answeringModel(response, "down"))
+
+	// A model answering first isn't passed on, and another provider's fallback model is asked
+	// through its client
+	mistral := &flakyLLMClient{}
+	s.fallbackClients = map[string]LLMClient{"mistral": mistral}
+	client.asked = nil
+	response, err = s.queryModels(context.Background(), "which pools are down?", "llama3.2", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"llama3.2"}, client.asked)
+	assert.Empty(t, response.Fallbacks)
+	assert.Empty(t, response.Notices)
+
+	cfg.Fallback.Models = []config.FallbackModel{{Model: "down"}, {Model: "mistral-small", Provider: "mistral"}}
+	response, err = s.queryModels(context.Background(), "which pools are down?", "garbled", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "mistral", response.Provider)
+	assert.Equal(t, []string{"mistral-small"}, mistral.asked)
+
+	// When every model fails the last error is returned
+	cfg.Fallback.Models = []config.FallbackModel{{Model: "down"}}
+	_, err = s.queryModels(context.Background(), "which pools are down?", "slow", nil)
+	assert.ErrorContains(t, err, "Ollama LLM processing failed: connection refused")
+}
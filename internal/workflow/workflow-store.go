@@ -0,0 +1,325 @@
+// Package workflow records the progress of multi-step operations, such as a configuration apply
+// or a service engine maintenance, so an interrupted run resumes from its last completed step.
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"aviagent/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// Run states
+const (
+	StateRunning     = "running"
+	StateCompleted   = "completed"
+	StateFailed      = "failed"      // a step failed, the remaining steps can be resumed
+	StateInterrupted = "interrupted" // the agent stopped or the request was cancelled during a step
+	StateAborted     = "aborted"     // stopped and rolled back, not resumable
+	StateProposed    = "proposed"    // waiting for the operator's approval, no step has run
+)
+
+// Step states
+const (
+	StepPending = "pending"
+	StepDone    = "done"
+	StepSkipped = "skipped" // run by other means before the run was recorded
+	StepFailed  = "failed"
+)
+
+// defaultMaxRuns is how many runs are kept when the configuration doesn't say
+const defaultMaxRuns = 100
+
+// Step is one step of a run
+type Step struct {
+	Name     string     `json:"name"`
+	Status   string     `json:"status"`
+	Detail   string     `json:"detail,omitempty"`
+	Error    string     `json:"error,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+}
+
+// Run is a multi-step operation and the state of each of its steps
+type Run struct {
+	ID       string          `json:"id"`
+	Kind     string          `json:"kind"`    // tool that started the run, e.g. apply_configuration
+	Subject  string          `json:"subject"` // what the run works on, e.g. a service engine name
+	Operator string          `json:"operator,omitempty"`
+	State    string          `json:"state"`
+	Input    json.RawMessage `json:"input,omitempty"` // what the run needs to resume
+	Steps    []Step          `json:"steps"`
+	Created  time.Time       `json:"created"`
+	Updated  time.Time       `json:"updated"`
+}
+
+// NextStep returns the index of the first step that isn't done or skipped, or -1 when all are
+func (r Run) NextStep() int {
+	for i, step := range r.Steps {
+		if step.Status != StepDone && step.Status != StepSkipped {
+			return i
+		}
+	}
+	return -1
+}
+
+// Resumable reports whether the run stopped before its last step
+func (r Run) Resumable() bool {
+	return (r.State == StateFailed || r.State == StateInterrupted) && r.NextStep() >= 0
+}
+
+// clone copies a run so callers can read it while the store updates its steps
+func (r *Run) clone() Run {
+	c := *r
+	c.Steps = append([]Step(nil), r.Steps...)
+	return c
+}
+
+// Store keeps the runs in memory and saves them to a JSON file after every step when one is
+// configured. Runs that were still running when the agent stopped are loaded as interrupted.
+type Store struct {
+	mu      sync.Mutex
+	runs    map[string]*Run
+	file    string
+	maxRuns int
+	seq     int
+	logger  *zap.Logger
+}
+
+// NewStore creates the run store, loading the state file when one is configured
+func NewStore(cfg config.WorkflowsConfig, logger *zap.Logger) (*Store, error) {
+	s := &Store{
+		runs:    make(map[string]*Run),
+		file:    cfg.StateFile,
+		maxRuns: cfg.MaxRuns,
+		logger:  logger,
+	}
+	if s.maxRuns <= 0 {
+		s.maxRuns = defaultMaxRuns
+	}
+	if s.file == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(s.file)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workflow state %s: %w", s.file, err)
+	}
+	var runs []*Run
+	if err := json.Unmarshal(data, &runs); err != nil {
+		return nil, fmt.Errorf("failed to parse workflow state %s: %w", s.file, err)
+	}
+	for _, run := range runs {
+		if run.State == StateRunning {
+			run.State = StateInterrupted
+			logger.Warn("Workflow run was interrupted, it can be resumed",
+				zap.String("id", run.ID), zap.String("kind", run.Kind), zap.String("subject", run.Subject))
+		}
+		s.runs[run.ID] = run
+	}
+	s.seq = len(runs)
+	return s, nil
+}
+
+// Start records a new run with its steps pending
+func (s *Store) Start(kind, subject, operator string, input interface{}, steps []string) (Run, error) {
+	return s.start(kind, subject, operator, input, steps, StateRunning)
+}
+
+// Propose records a run that only starts once approved, such as a plan of tool calls
+func (s *Store) Propose(kind, subject, operator string, input interface{}, steps []string) (Run, error) {
+	return s.start(kind, subject, operator, input, steps, StateProposed)
+}
+
+// start records a new run in a state with its steps pending
+func (s *Store) start(kind, subject, operator string, input interface{}, steps []string, state string) (Run, error) {
+	raw, err := json.Marshal(input)
+	if err != nil {
+		return Run{}, fmt.Errorf("failed to record workflow input: %w", err)
+	}
+	now := time.Now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	run := &Run{
+		ID:       fmt.Sprintf("wf-%s-%d", now.Format("20060102150405"), s.seq),
+		Kind:     kind,
+		Subject:  subject,
+		Operator: operator,
+		State:    state,
+		Input:    raw,
+		Steps:    make([]Step, len(steps)),
+		Created:  now,
+		Updated:  now,
+	}
+	for i, name := range steps {
+		run.Steps[i] = Step{Name: name, Status: StepPending}
+	}
+	s.runs[run.ID] = run
+	s.prune()
+	s.save()
+	return run.clone(), nil
+}
+
+// Resume marks a stopped run as running again
+func (s *Store) Resume(id string) (Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.runs[id]
+	if !ok {
+		return Run{}, fmt.Errorf("workflow run %s not found", id)
+	}
+	if !run.Resumable() {
+		return Run{}, fmt.Errorf("workflow run %s is %s and can't be resumed", id, run.State)
+	}
+	run.State = StateRunning
+	run.Updated = time.Now().UTC()
+	s.save()
+	return run.clone(), nil
+}
+
+// Approve starts a proposed run
+func (s *Store) Approve(id string) (Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.runs[id]
+	if !ok {
+		return Run{}, fmt.Errorf("workflow run %s not found", id)
+	}
+	if run.State != StateProposed {
+		return Run{}, fmt.Errorf("workflow run %s is %s, not awaiting approval", id, run.State)
+	}
+	run.State = StateRunning
+	run.Updated = time.Now().UTC()
+	s.save()
+	return run.clone(), nil
+}
+
+// SetStep records the outcome of a step. A failed step fails the run; the run completes when
+// its last step is done.
+func (s *Store) SetStep(id string, index int, status, detail string, stepErr error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.runs[id]
+	if !ok || index < 0 || index >= len(run.Steps) {
+		return
+	}
+	now := time.Now().UTC()
+	step := &run.Steps[index]
+	step.Status, step.Detail, step.Error, step.Finished = status, detail, "", &now
+	if stepErr != nil {
+		step.Error = stepErr.Error()
+		run.State = StateFailed
+	} else if run.NextStep() < 0 {
+		run.State = StateCompleted
+	}
+	run.Updated = now
+	s.save()
+}
+
+// Interrupt marks a run that stopped before finishing, e.g. because its request was cancelled
+func (s *Store) Interrupt(id string) {
+	s.finish(id, StateInterrupted)
+}
+
+// Abort ends a run that was rolled back, so it isn't offered for resuming
+func (s *Store) Abort(id string) {
+	s.finish(id, StateAborted)
+}
+
+// finish sets the final state of a run that hasn't completed
+func (s *Store) finish(id, state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if run, ok := s.runs[id]; ok && run.State != StateCompleted {
+		run.State = state
+		run.Updated = time.Now().UTC()
+		s.save()
+	}
+}
+
+// Get returns a run
+func (s *Store) Get(id string) (Run, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.runs[id]
+	if !ok {
+		return Run{}, false
+	}
+	return run.clone(), true
+}
+
+// Latest returns the most recent run of a kind on a subject that hasn't ended
+func (s *Store) Latest(kind, subject string) (Run, bool) {
+	for _, run := range s.List() {
+		if run.Kind == kind && run.Subject == subject && run.State != StateCompleted && run.State != StateAborted {
+			return run, true
+		}
+	}
+	return Run{}, false
+}
+
+// List returns the runs, most recently updated first
+func (s *Store) List() []Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	runs := make([]Run, 0, len(s.runs))
+	for _, run := range s.runs {
+		runs = append(runs, run.clone())
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Updated.After(runs[j].Updated) })
+	return runs
+}
+
+// prune drops the oldest finished runs beyond the limit
+func (s *Store) prune() {
+	if len(s.runs) <= s.maxRuns {
+		return
+	}
+	finished := make([]*Run, 0, len(s.runs))
+	for _, run := range s.runs {
+		if run.State == StateCompleted || run.State == StateAborted {
+			finished = append(finished, run)
+		}
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].Updated.Before(finished[j].Updated) })
+	for _, run := range finished {
+		if len(s.runs) <= s.maxRuns {
+			break
+		}
+		delete(s.runs, run.ID)
+	}
+}
+
+// save writes the state file through a temporary file, so a crash leaves the previous state;
+// failures are logged because the runs still continue in memory
+func (s *Store) save() {
+	if s.file == "" {
+		return
+	}
+	runs := make([]*Run, 0, len(s.runs))
+	for _, run := range s.runs {
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Created.Before(runs[j].Created) })
+
+	data, err := json.MarshalIndent(runs, "", "  ")
+	if err == nil {
+		tmp := filepath.Join(filepath.Dir(s.file), "."+filepath.Base(s.file)+".tmp")
+		if err = os.WriteFile(tmp, data, 0600); err == nil {
+			err = os.Rename(tmp, s.file)
+		}
+	}
+	if err != nil {
+		s.logger.Error("Failed to save workflow state", zap.String("file", s.file), zap.Error(err))
+	}
+}
@@ -0,0 +1,84 @@
+package workflow
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"aviagent/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestStoreResume(t *testing.T) {
+	cfg := config.WorkflowsConfig{StateFile: filepath.Join(t.TempDir(), "workflows.json")}
+	store, err := NewStore(cfg, zaptest.NewLogger(t))
+	require.NoError(t, err)
+
+	run, err := store.Start("apply_configuration", "3 objects", "alice", []string{"a", "b", "c"}, []string{"pool/a", "pool/b", "pool/c"})
+	require.NoError(t, err)
+	store.SetStep(run.ID, 0, StepDone, "created", nil)
+
+	// A run still running when the agent stops is loaded as interrupted
+	store, err = NewStore(cfg, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	run, ok := store.Get(run.ID)
+	require.True(t, ok)
+	assert.Equal(t, StateInterrupted, run.State)
+	assert.True(t, run.Resumable())
+	assert.Equal(t, 1, run.NextStep())
+	assert.JSONEq(t, `["a","b","c"]`, string(run.Input))
+
+	_, err = store.Resume(run.ID)
+	require.NoError(t, err)
+	store.SetStep(run.ID, 1, StepFailed, "", errors.New("409 conflict"))
+	run, _ = store.Get(run.ID)
+	assert.Equal(t, StateFailed, run.State)
+	assert.Equal(t, "409 conflict", run.Steps[1].Error)
+
+	_, err = store.Resume(run.ID)
+	require.NoError(t, err)
+	store.SetStep(run.ID, 1, StepDone, "updated", nil)
+	store.SetStep(run.ID, 2, StepDone, "created", nil)
+	run, _ = store.Get(run.ID)
+	assert.Equal(t, StateCompleted, run.State)
+	assert.False(t, run.Resumable())
+	_, err = store.Resume(run.ID)
+	assert.Error(t, err)
+
+	_, ok = store.Latest("apply_configuration", "3 objects")
+	assert.False(t, ok)
+}
+
+func TestStoreApprove(t *testing.T) {
+	cfg := config.WorkflowsConfig{StateFile: filepath.Join(t.TempDir(), "workflows.json")}
+	store, err := NewStore(cfg, zaptest.NewLogger(t))
+	require.NoError(t, err)
+
+	run, err := store.Propose("plan", "drain web-pool", "alice", nil, []string{"list_pools", "disable_pool_server"})
+	require.NoError(t, err)
+	assert.Equal(t, StateProposed, run.State)
+	assert.False(t, run.Resumable())
+	_, err = store.Resume(run.ID)
+	assert.Error(t, err)
+
+	// A proposed run stays proposed across restarts
+	store, err = NewStore(cfg, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	run, _ = store.Get(run.ID)
+	assert.Equal(t, StateProposed, run.State)
+
+	run, err = store.Approve(run.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StateRunning, run.State)
+	_, err = store.Approve(run.ID)
+	assert.EqualError(t, err, "workflow run "+run.ID+" is running, not awaiting approval")
+
+	rejected, err := store.Propose("plan", "delete web-vs", "alice", nil, []string{"delete_virtual_service"})
+	require.NoError(t, err)
+	store.Abort(rejected.ID)
+	_, err = store.Approve(rejected.ID)
+	assert.Error(t, err)
+}
@@ -0,0 +1,82 @@
+// Package agent embeds the Avi agent in another Go program: it answers questions about the
+// controller and runs the tools they need with the same model and intent routing, tool
+// execution, auditing and moderation as the server's /api/v1/chat, without the HTTP server.
+//
+// The packages under pkg/ are the agent's stable API: the Avi client (pkg/avi), the LLM
+// providers and tool registry (pkg/llm, pkg/mistral), the configuration (pkg/config), the
+// audit trail (pkg/audit) and the chat turn the agent runs (pkg/agent/chat). Everything under
+// internal/ may change between releases.
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"aviagent/internal/web"
+	"aviagent/pkg/agent/chat"
+	"aviagent/pkg/audit"
+	"aviagent/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// Result is the answer to a question: the model's message, the tool calls it made with their
+// results and errors, the receipts of the changes, and the usage of the session
+type Result = chat.Result
+
+// SessionUsage is the tokens and estimated cost of a session
+type SessionUsage = chat.SessionUsage
+
+// Hooks follow and guard the tool calls of an answer; see WithHooks
+type Hooks = chat.Hooks
+
+// Agent answers questions against the controller and the LLM provider of its configuration
+type Agent struct {
+	server *web.Server
+}
+
+// New creates an agent from a configuration, usually read with config.Load. Scheduled reports
+// and the alert receiver belong to the server and are not started; the configuration passed is
+// not modified. A nil logger discards the logs.
+func New(cfg *config.Config, logger *zap.Logger) (*Agent, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("configuration required")
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	embedded := *cfg
+	embedded.Scheduler.Enabled = false
+	embedded.Alerts.Enabled = false
+	server, err := web.NewServer(&embedded, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize agent: %w", err)
+	}
+	return &Agent{server: server}, nil
+}
+
+// Ask answers a question in a session, created when it doesn't exist; an empty session starts
+// a new one, returned in the result to continue it. An empty model uses the configured default.
+// Tool calls that change configuration run unless the hooks of the context decline them.
+func (a *Agent) Ask(ctx context.Context, session, model, question string) (*Result, error) {
+	return a.server.Chat(ctx, session, model, question)
+}
+
+// Close logs out of the controller and stops the agent's background work
+func (a *Agent) Close() error {
+	return a.server.Close()
+}
+
+// WithHooks returns a context whose questions report their tool calls to the hooks, such as
+// Confirm to approve each change
+func WithHooks(ctx context.Context, hooks Hooks) context.Context {
+	return chat.WithHooks(ctx, hooks)
+}
+
+// WithOperator returns a context whose questions are recorded in the audit trail as asked by
+// the operator
+func WithOperator(ctx context.Context, operator string) context.Context {
+	actor := audit.ActorFrom(ctx)
+	actor.Operator = operator
+	return audit.WithActor(ctx, actor)
+}
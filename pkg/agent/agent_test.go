@@ -0,0 +1,103 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"aviagent/pkg/audit"
+	"aviagent/pkg/config"
+	"aviagent/pkg/llm"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	_, err := New(nil, nil)
+	assert.EqualError(t, err, "configuration required")
+}
+
+func TestWithOperator(t *testing.T) {
+	ctx := audit.WithActor(context.Background(), audit.Actor{Session: "s-1", RemoteAddr: "batch"})
+	assert.Equal(t, audit.Actor{Operator: "alice", Session: "s-1", RemoteAddr: "batch"}, audit.ActorFrom(WithOperator(ctx, "alice")))
+}
+
+func TestAsk(t *testing.T) {
+	// The model calls the tool named by the question, and the tools it was offered are recorded
+	var mu sync.Mutex
+	var offered [][]string
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			json.NewEncoder(w).Encode(llm.ModelsResponse{Models: []llm.Model{{Name: "llama3"}}})
+		case "/api/chat":
+			var req llm.ChatRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			var names []string
+			for _, tool := range req.Tools {
+				names = append(names, tool.Function.Name)
+			}
+			mu.Lock()
+			offered = append(offered, names)
+			mu.Unlock()
+			tool := req.Messages[len(req.Messages)-1].Content
+			json.NewEncoder(w).Encode(llm.ChatResponse{
+				Model:   req.Model,
+				Message: llm.ChatMessage{Role: "assistant", Content: fmt.Sprintf(`{"tool": %q, "parameters": {"uuid": "pool-1"}}`, tool)},
+				Done:    true,
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ollama.Close()
+
+	// The controller only logs in: the tool calls are declined or disabled before reaching it
+	controller := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "csrftoken", Value: "token"})
+		w.Write([]byte(`{}`))
+	}))
+	defer controller.Close()
+
+	newAgent := func(disabled ...string) *Agent {
+		cfg := &config.Config{Provider: "ollama"}
+		cfg.LLM = config.LLMConfig{OllamaHost: ollama.URL, DefaultModel: "llama3", Timeout: 10}
+		cfg.Avi = config.AviConfig{Host: strings.TrimPrefix(controller.URL, "https://"), Username: "admin", Password: "secret",
+			Tenant: "admin", Version: "22.1.3", Timeout: 10, Insecure: true}
+		cfg.Tools.Disabled = disabled
+		a, err := New(cfg, nil)
+		require.NoError(t, err)
+		t.Cleanup(func() { a.Close() })
+		return a
+	}
+	// Two agents in one process offer the tools of their own configuration
+	restricted := newAgent("get_pool")
+	full := newAgent()
+	declined := WithHooks(context.Background(), Hooks{Confirm: func(llm.ToolCall) bool { return false }})
+
+	result, err := full.Ask(declined, "", "", "delete_pool")
+	require.NoError(t, err)
+	require.Len(t, result.ToolErrors, 1)
+	assert.Equal(t, "declined by the operator", result.ToolErrors[0].Message)
+
+	result, err = restricted.Ask(context.Background(), "", "", "get_pool")
+	require.NoError(t, err)
+	assert.NotEmpty(t, result.Session)
+	require.Len(t, result.ToolErrors, 1)
+	assert.Equal(t, "tool get_pool is disabled in this deployment", result.ToolErrors[0].Message)
+
+	_, err = full.Ask(declined, "", "", "delete_pool")
+	require.NoError(t, err)
+
+	require.Len(t, offered, 3)
+	assert.Contains(t, offered[0], "get_pool")
+	assert.NotContains(t, offered[1], "get_pool")
+	assert.Contains(t, offered[1], "delete_pool")
+	assert.Contains(t, offered[2], "get_pool", "the other agent's configuration doesn't leak")
+}
@@ -0,0 +1,194 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+
+	"aviagent/pkg/audit"
+	"aviagent/pkg/avi"
+	"aviagent/pkg/llm"
+
+	"go.uber.org/zap"
+)
+
+// Runtime is what a chat turn runs against: the sessions, models, tools and safeguards of a
+// deployment. Run decides which tool calls run and how their outcomes are reported; the
+// runtime does the work.
+type Runtime interface {
+	// Logger returns the logger of the request in ctx
+	Logger(ctx context.Context) *zap.Logger
+	// Prepare returns the history sent to the model: fitted to its context, with the session
+	// notes and the deployment memory in front
+	Prepare(ctx context.Context, sessionID, model string, history []llm.ChatMessage) []llm.ChatMessage
+	// Elevate logs the turn at debug level when its session is debugged; the returned function
+	// is called once the turn is answered
+	Elevate(ctx context.Context, sessionID string) func()
+	// SetStatus records a provider delay of a session, cleared with an empty status
+	SetStatus(sessionID, status string)
+	// Answer answers a message: as the answer to the question of the session's creation
+	// wizard, otherwise with the model and its fallbacks
+	Answer(ctx context.Context, sessionID, message, model string, history []llm.ChatMessage) (*llm.LLMResponse, error)
+	// ClockSkewWarning returns the notice shown when the controller clock is off, if it is
+	ClockSkewWarning(ctx context.Context) string
+	// StartWizard holds a create call lacking arguments, returning the question asking for them
+	StartWizard(ctx context.Context, sessionID string, toolCall llm.ToolCall) (string, bool)
+	// IsMutating reports whether a tool call changes configuration, custom tools included
+	IsMutating(toolCall llm.ToolCall) bool
+	// RecordInvocation keeps a tool call so it can be re-run, returning its ID
+	RecordInvocation(sessionID, operator string, toolCall llm.ToolCall) string
+	// QueueApproval holds a change matching an approval rule for a second person, returning
+	// the rule and the ID of the approval
+	QueueApproval(ctx context.Context, toolCall llm.ToolCall) (rule, id string, queued bool)
+	// NeedsApproval reports whether a change waits for the operator to re-run it when the
+	// frontend has no Confirm hook
+	NeedsApproval(toolCall llm.ToolCall) bool
+	// Execute runs a tool call; a change is audited and its receipt returned
+	Execute(ctx context.Context, toolCall llm.ToolCall, mutating bool) (interface{}, *audit.Receipt, error)
+	// Advise explains a failed tool call and suggests a fix, empty when it has nothing to add
+	Advise(ctx context.Context, model string, toolCall llm.ToolCall, toolErr llm.ToolError, err error) string
+	// Render returns a tool result as added to the answer
+	Render(ctx context.Context, toolCall llm.ToolCall, model string, result interface{}) string
+	// Finish checks the answer before it is sent: insights the results raise, the style guide
+	// and moderation. results are the rendered tool results, response.ToolResults[rendered[i]]
+	// being the one of results[i].
+	Finish(ctx context.Context, response *llm.LLMResponse, rendered []int, results []interface{})
+}
+
+// Run answers a chat message with the history of its session, the one of the actor in ctx,
+// and runs the tool calls of the answer. Changes matching an approval rule are queued, changes
+// the Confirm hook declines are skipped, and, without a Confirm hook, changes the runtime holds
+// wait for the operator to re-run them. A failed tool call is reported in the answer, with
+// advice on fixing it, and the other calls still run.
+func Run(ctx context.Context, rt Runtime, message, model string, history []llm.ChatMessage) (*llm.LLMResponse, error) {
+	// Provider delays are shown while the question is answered: polled by the web UI per
+	// session, pushed to other frontends through their hooks
+	actor := audit.ActorFrom(ctx)
+	sessionID := actor.Session
+	history = rt.Prepare(ctx, sessionID, model, history)
+	defer rt.Elevate(ctx, sessionID)()
+	logger := rt.Logger(ctx)
+	hooks := HooksFrom(ctx)
+	ctx = llm.WithStatus(ctx, func(status string) {
+		if sessionID != "" {
+			rt.SetStatus(sessionID, status)
+		}
+		if hooks.Status != nil {
+			hooks.Status(status)
+		}
+	})
+	defer rt.SetStatus(sessionID, "")
+
+	llmResponse, err := rt.Answer(ctx, sessionID, message, model, history)
+	if err != nil {
+		return nil, err
+	}
+
+	var rendered []int        // tool results whose data was added to the message, in order
+	var results []interface{} // the results of rendered
+	skewChecked := false
+	for i, toolCall := range llmResponse.ToolCalls {
+		// Warn once when controller clock skew would shift a time-range query
+		if llm.IsTimeRangeTool(toolCall.Function.Name) && !skewChecked {
+			skewChecked = true
+			if warning := rt.ClockSkewWarning(ctx); warning != "" {
+				llmResponse.Notices = append(llmResponse.Notices, warning)
+			}
+		}
+
+		// A create call lacking arguments the controller needs asks the operator for them
+		// rather than sending an object it would refuse
+		if question, ok := rt.StartWizard(ctx, sessionID, toolCall); ok {
+			llmResponse.ToolResults = append(llmResponse.ToolResults, newToolResult(toolCall, llm.ToolResultInput))
+			llmResponse.Message += fmt.Sprintf("\n\nAwaiting input (%s): not run yet. %s", toolCall.Function.Name, question)
+			logger.Info("Tool call awaiting input", zap.String("tool", toolCall.Function.Name))
+			continue
+		}
+
+		// Keep the call so the UI can offer to re-run it
+		llmResponse.ToolCalls[i].InvocationID = rt.RecordInvocation(actor.Session, actor.Operator, toolCall)
+		mutating := rt.IsMutating(toolCall)
+
+		var result interface{}
+		var receipt *audit.Receipt
+		if rule, id, queued := rt.QueueApproval(ctx, llmResponse.ToolCalls[i]); queued {
+			// Changes matching an approval rule wait for a second person, whatever the frontend
+			llmResponse.ToolResults = append(llmResponse.ToolResults, newToolResult(llmResponse.ToolCalls[i], llm.ToolResultPending))
+			llmResponse.Message += fmt.Sprintf("\n\nAwaiting approval (%s): not run yet. The %s rule needs a second person to approve it: queued as %s, approved with POST /api/v1/approvals/%s/approve.",
+				toolCall.Function.Name, rule, id, id)
+			continue
+		} else if mutating && hooks.Confirm != nil && !hooks.Confirm(toolCall) {
+			err = ErrDeclined
+		} else if hooks.Confirm == nil && rt.NeedsApproval(toolCall) {
+			// The web UI and API approve through the re-run endpoint
+			id := llmResponse.ToolCalls[i].InvocationID
+			llmResponse.Approvals = append(llmResponse.Approvals, id)
+			llmResponse.ToolResults = append(llmResponse.ToolResults, newToolResult(llmResponse.ToolCalls[i], llm.ToolResultPending))
+			llmResponse.Message += fmt.Sprintf("\n\nAwaiting approval (%s): not run yet. Approve it with the re-run button or POST /api/v1/tools/invocations/%s/rerun?confirm=true.",
+				toolCall.Function.Name, id)
+			logger.Info("Tool call awaiting approval", zap.String("tool", toolCall.Function.Name), zap.String("invocation", id))
+			continue
+		} else {
+			if hooks.ToolStarted != nil {
+				hooks.ToolStarted(toolCall)
+			}
+			result, receipt, err = rt.Execute(ctx, toolCall, mutating)
+			if hooks.ToolFinished != nil {
+				hooks.ToolFinished(toolCall, result, err)
+			}
+		}
+		if err == ErrDeclined {
+			logger.Info("Tool call declined", zap.String("tool", toolCall.Function.Name))
+		} else if err != nil {
+			logger.Error("Tool call failed",
+				zap.String("tool", toolCall.Function.Name),
+				zap.Error(err))
+		}
+		if err != nil {
+			// Report the failure in the answer, so it is in the history the model sees next,
+			// and continue with the other tool calls
+			toolErr := llm.ToolErrorFrom(toolCall.Function.Name, err)
+			if err != ErrDeclined {
+				toolErr.Advice = rt.Advise(ctx, model, toolCall, toolErr, err)
+			}
+			llmResponse.ToolErrors = append(llmResponse.ToolErrors, toolErr)
+			llmResponse.Message += fmt.Sprintf("\n\nTool error (%s): %s", toolErr.Tool, toolErr.Message)
+			if toolErr.Advice != "" {
+				llmResponse.Message += "\n" + toolErr.Advice
+			}
+			toolResult := newToolResult(llmResponse.ToolCalls[i], llm.ToolResultError)
+			if err == ErrDeclined {
+				toolResult.Status = llm.ToolResultDeclined
+			}
+			toolResult.Error = toolErr.Message
+			llmResponse.ToolResults = append(llmResponse.ToolResults, toolResult)
+			continue
+		}
+
+		if receipt != nil {
+			llmResponse.Receipts = append(llmResponse.Receipts, *receipt)
+		}
+		if file, ok := result.(*avi.FileResult); ok {
+			llmResponse.Downloads = append(llmResponse.Downloads, llm.Download{Filename: file.Filename, URL: file.DownloadURL, Size: file.Size})
+		}
+
+		// Add the result to the response message
+		llmResponse.ToolResults = append(llmResponse.ToolResults, newToolResult(llmResponse.ToolCalls[i], llm.ToolResultOK))
+		if result != nil {
+			rendered = append(rendered, len(llmResponse.ToolResults)-1)
+			results = append(results, result)
+			llmResponse.Message += rt.Render(ctx, toolCall, model, result)
+		}
+	}
+
+	rt.Finish(ctx, llmResponse, rendered, results)
+	return llmResponse, nil
+}
+
+// newToolResult starts the typed result of a tool call
+func newToolResult(toolCall llm.ToolCall, status string) llm.ToolResult {
+	args := toolCall.Args
+	if args == nil {
+		args = llm.ParseToolArguments(toolCall.Function.Arguments)
+	}
+	return llm.ToolResult{Tool: toolCall.Function.Name, InvocationID: toolCall.InvocationID, Arguments: args, Status: status}
+}
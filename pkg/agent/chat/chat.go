@@ -0,0 +1,64 @@
+// Package chat runs a chat turn of the agent: it asks the model, runs the tool calls of its
+// answer through the approvals, hooks and auditing of the deployment, and reports each outcome
+// in the answer. The server's /api/v1/chat, the OpenAI-compatible API, the terminal chat and
+// pkg/agent all answer through Run.
+package chat
+
+import (
+	"context"
+	"fmt"
+
+	"aviagent/pkg/llm"
+)
+
+// Hooks lets a frontend other than the web UI, such as the terminal chat, follow and guard the
+// tool calls of an answer
+type Hooks struct {
+	// Confirm is asked before a tool call that changes configuration; when it returns false the
+	// call is skipped and reported as a tool error
+	Confirm func(toolCall llm.ToolCall) bool
+	// ToolStarted and ToolFinished are called around every tool call, ToolFinished with the
+	// result as returned by the tool
+	ToolStarted  func(toolCall llm.ToolCall)
+	ToolFinished func(toolCall llm.ToolCall, result interface{}, err error)
+	// Status is told about provider delays as they happen, such as a rate limit retry
+	Status func(status string)
+	// Unattended is set by frontends whose questions nobody answers, such as runbooks: create
+	// calls lacking arguments run as they are instead of asking the operator for them
+	Unattended bool
+}
+
+type hooksKey struct{}
+
+// WithHooks returns a context whose chat turns report to the hooks
+func WithHooks(ctx context.Context, hooks Hooks) context.Context {
+	return context.WithValue(ctx, hooksKey{}, hooks)
+}
+
+// HooksFrom returns the hooks of a chat turn, empty for web requests
+func HooksFrom(ctx context.Context) Hooks {
+	hooks, _ := ctx.Value(hooksKey{}).(Hooks)
+	return hooks
+}
+
+// ErrDeclined is the error of a tool call the operator declined to run
+var ErrDeclined = fmt.Errorf("declined by the operator")
+
+// SessionUsage holds the accumulated token usage and estimated cost of a chat session
+type SessionUsage struct {
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	EstimatedCost    float64 `json:"estimated_cost"`
+	Currency         string  `json:"currency,omitempty"`
+	Priced           bool    `json:"priced"` // false when a model used in the session has no pricing configured
+}
+
+// Result is the answer to a chat turn: the model's message, the tool calls it made with their
+// results and errors, the receipts of the changes, and the usage of the session
+type Result struct {
+	*llm.LLMResponse
+	Session      string
+	SessionUsage SessionUsage
+	Route        *llm.ModelRoute
+}
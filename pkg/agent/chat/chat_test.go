@@ -0,0 +1,109 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"aviagent/pkg/audit"
+	"aviagent/pkg/llm"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeRuntime answers with its tool calls, queues the ones named in queue and fails the ones
+// named in fail
+type fakeRuntime struct {
+	calls    []llm.ToolCall
+	queue    map[string]bool
+	fail     map[string]bool
+	executed []string
+	status   []string
+}
+
+func (f *fakeRuntime) Logger(context.Context) *zap.Logger { return zap.NewNop() }
+func (f *fakeRuntime) Prepare(_ context.Context, _, _ string, history []llm.ChatMessage) []llm.ChatMessage {
+	return history
+}
+func (f *fakeRuntime) Elevate(context.Context, string) func() { return func() {} }
+func (f *fakeRuntime) SetStatus(_, status string)             { f.status = append(f.status, status) }
+func (f *fakeRuntime) Answer(_ context.Context, _, message, model string, _ []llm.ChatMessage) (*llm.LLMResponse, error) {
+	return &llm.LLMResponse{Model: model, Message: "answer to " + message, ToolCalls: f.calls}, nil
+}
+func (f *fakeRuntime) ClockSkewWarning(context.Context) string { return "" }
+func (f *fakeRuntime) StartWizard(context.Context, string, llm.ToolCall) (string, bool) {
+	return "", false
+}
+func (f *fakeRuntime) RecordInvocation(_, _ string, toolCall llm.ToolCall) string {
+	return "inv-" + toolCall.Function.Name
+}
+func (f *fakeRuntime) IsMutating(toolCall llm.ToolCall) bool {
+	return llm.IsMutatingTool(toolCall.Function.Name, toolCall.Args)
+}
+func (f *fakeRuntime) QueueApproval(_ context.Context, toolCall llm.ToolCall) (string, string, bool) {
+	return "prod", "approval-1", f.queue[toolCall.Function.Name]
+}
+func (f *fakeRuntime) NeedsApproval(llm.ToolCall) bool { return false }
+func (f *fakeRuntime) Execute(_ context.Context, toolCall llm.ToolCall, mutating bool) (interface{}, *audit.Receipt, error) {
+	f.executed = append(f.executed, toolCall.Function.Name)
+	if f.fail[toolCall.Function.Name] {
+		return nil, nil, fmt.Errorf("pool not found")
+	}
+	if mutating {
+		return map[string]interface{}{"name": "web"}, &audit.Receipt{ID: "receipt-1"}, nil
+	}
+	return map[string]interface{}{"count": 1}, nil, nil
+}
+func (f *fakeRuntime) Advise(context.Context, string, llm.ToolCall, llm.ToolError, error) string {
+	return "Check the pool name."
+}
+func (f *fakeRuntime) Render(_ context.Context, toolCall llm.ToolCall, _ string, _ interface{}) string {
+	return "\n\n[" + toolCall.Function.Name + "]"
+}
+func (f *fakeRuntime) Finish(context.Context, *llm.LLMResponse, []int, []interface{}) {}
+
+func TestRun(t *testing.T) {
+	call := func(name string, args map[string]interface{}) llm.ToolCall {
+		return llm.ToolCall{Function: llm.ToolCallFunction{Name: name}, Args: args}
+	}
+	rt := &fakeRuntime{
+		calls: []llm.ToolCall{
+			call("list_pools", nil),
+			call("delete_pool", map[string]interface{}{"uuid": "pool-1"}),
+			call("update_pool", map[string]interface{}{"uuid": "pool-2"}),
+			call("get_pool", map[string]interface{}{"uuid": "pool-3"}),
+			call("create_pool", map[string]interface{}{"name": "web"}),
+		},
+		queue: map[string]bool{"delete_pool": true},
+		fail:  map[string]bool{"get_pool": true},
+	}
+	var finished []string
+	ctx := audit.WithActor(context.Background(), audit.Actor{Session: "s-1", Operator: "alice"})
+	ctx = WithHooks(ctx, Hooks{
+		Confirm: func(toolCall llm.ToolCall) bool { return toolCall.Function.Name != "update_pool" },
+		ToolFinished: func(toolCall llm.ToolCall, _ interface{}, _ error) {
+			finished = append(finished, toolCall.Function.Name)
+		},
+	})
+
+	response, err := Run(ctx, rt, "tidy the pools", "model-a", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"list_pools", "get_pool", "create_pool"}, rt.executed, "queued and declined changes don't run")
+	assert.Equal(t, rt.executed, finished)
+	assert.Equal(t, []string{""}, rt.status, "the status is cleared")
+
+	statuses := make([]string, len(response.ToolResults))
+	for i, result := range response.ToolResults {
+		statuses[i] = result.Status
+	}
+	assert.Equal(t, []string{llm.ToolResultOK, llm.ToolResultPending, llm.ToolResultDeclined, llm.ToolResultError, llm.ToolResultOK}, statuses)
+	assert.Equal(t, "inv-list_pools", response.ToolCalls[0].InvocationID)
+	assert.Contains(t, response.Message, "The prod rule needs a second person to approve it: queued as approval-1")
+	assert.Contains(t, response.Message, "Tool error (update_pool): "+ErrDeclined.Error())
+	assert.Contains(t, response.Message, "Tool error (get_pool): pool not found\nCheck the pool name.")
+	assert.Contains(t, response.Message, "[create_pool]")
+	require.Len(t, response.Receipts, 1)
+	assert.Equal(t, "receipt-1", response.Receipts[0].ID)
+}
@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// genesisHash is the previous hash of the first record of a chain
+var genesisHash = strings.Repeat("0", 64)
+
+// ChainError describes where a hash chain stops verifying
+type ChainError struct {
+	Index  int    `json:"index"`
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("audit chain broken at record %d (%s): %s", e.Index, e.ID, e.Reason)
+}
+
+// hashEntry returns the SHA-256 of the entry with its own hash left out
+func hashEntry(entry Entry) (string, error) {
+	entry.Hash = ""
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+	digest := sha256.Sum256(data)
+	return hex.EncodeToString(digest[:]), nil
+}
+
+// chain links an entry to the previous hash and seals it
+func chain(entry Entry, prevHash string) (Entry, error) {
+	entry.PrevHash = prevHash
+	hash, err := hashEntry(entry)
+	if err != nil {
+		return entry, err
+	}
+	entry.Hash = hash
+	return entry, nil
+}
+
+// VerifyChain checks that every record links to the previous one and that no record was
+// modified. Records written before append-only mode was enabled (no hash) must all come first.
+func VerifyChain(entries []Entry) error {
+	prev := ""
+	for i, entry := range entries {
+		if entry.Hash == "" {
+			if prev != "" {
+				return &ChainError{Index: i, ID: entry.ID, Reason: "unhashed record after the chain started"}
+			}
+			continue
+		}
+		expectedPrev := prev
+		if expectedPrev == "" {
+			expectedPrev = genesisHash
+		}
+		if entry.PrevHash != expectedPrev {
+			return &ChainError{Index: i, ID: entry.ID, Reason: "previous hash does not match, a record was removed or reordered"}
+		}
+		hash, err := hashEntry(entry)
+		if err != nil {
+			return err
+		}
+		if hash != entry.Hash {
+			return &ChainError{Index: i, ID: entry.ID, Reason: "record content does not match its hash"}
+		}
+		prev = entry.Hash
+	}
+	return nil
+}
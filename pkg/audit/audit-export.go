@@ -0,0 +1,120 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Export formats
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// csvHeader is the column layout of CSV exports
+var csvHeader = []string{"id", "timestamp", "action", "operator", "session", "remote_addr", "model", "tool", "target", "arguments", "outcome", "error", "prev_hash", "hash"}
+
+// Export is a rendered audit export together with its integrity data. SHA256 is the digest of
+// Data; Signature is the HMAC-SHA256 of Data when a signing key is configured.
+type Export struct {
+	Data        []byte
+	ContentType string
+	SHA256      string
+	Signature   string
+	Entries     int
+}
+
+// NewExport renders entries in the given format and computes their integrity hash and signature
+func NewExport(entries []Entry, format, signingKey string) (*Export, error) {
+	var data []byte
+	var contentType string
+	var err error
+
+	switch format {
+	case "", FormatCSV:
+		data, err = renderCSV(entries)
+		contentType = "text/csv; charset=utf-8"
+	case FormatJSON:
+		if entries == nil {
+			entries = []Entry{}
+		}
+		data, err = json.MarshalIndent(entries, "", "  ")
+		contentType = "application/json"
+	default:
+		return nil, fmt.Errorf("unsupported export format %q, use csv or json", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(data)
+	export := &Export{
+		Data:        data,
+		ContentType: contentType,
+		SHA256:      hex.EncodeToString(digest[:]),
+		Entries:     len(entries),
+	}
+	if signingKey != "" {
+		export.Signature = Sign(data, signingKey)
+	}
+	return export, nil
+}
+
+// Sign returns the hex HMAC-SHA256 of data with the signing key
+func Sign(data []byte, signingKey string) string {
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the HMAC-SHA256 of data with the signing key
+func Verify(data []byte, signature, signingKey string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write(data)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// renderCSV writes entries as CSV with a header row
+func renderCSV(entries []Entry) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(csvHeader); err != nil {
+		return nil, fmt.Errorf("failed to write CSV header: %w", err)
+	}
+	for _, e := range entries {
+		record := []string{
+			e.ID,
+			e.Timestamp.UTC().Format(time.RFC3339),
+			e.Action,
+			e.Operator,
+			e.Session,
+			e.RemoteAddr,
+			e.Model,
+			e.Tool,
+			e.Target,
+			e.Arguments,
+			e.Outcome,
+			e.Error,
+			e.PrevHash,
+			e.Hash,
+		}
+		if err := w.Write(record); err != nil {
+			return nil, fmt.Errorf("failed to write CSV record: %w", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return buf.Bytes(), nil
+}
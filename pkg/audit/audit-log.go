@@ -0,0 +1,233 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"aviagent/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// Audit entry actions
+const (
+	ActionMutation = "mutation"
+	ActionApproval = "approval"
+)
+
+// Audit entry outcomes
+const (
+	OutcomeSuccess  = "success"
+	OutcomeFailure  = "failure"
+	OutcomeApproved = "approved" // of an approval decision
+	OutcomeRejected = "rejected" // of an approval decision
+)
+
+// Entry is a single record in the audit trail
+type Entry struct {
+	ID         string     `json:"id"`
+	Timestamp  time.Time  `json:"timestamp"`
+	Action     string     `json:"action"`
+	Operator   string     `json:"operator"`
+	Session    string     `json:"session,omitempty"`
+	RemoteAddr string     `json:"remote_addr,omitempty"`
+	Model      string     `json:"model,omitempty"`
+	Tool       string     `json:"tool"`
+	Target     string     `json:"target,omitempty"`
+	Arguments  string     `json:"arguments,omitempty"`
+	Outcome    string     `json:"outcome"`
+	Error      string     `json:"error,omitempty"`
+	Approval   string     `json:"approval,omitempty"`  // approval request decided, or that the change was approved by
+	Before     *ObjectRef `json:"before,omitempty"`    // the changed object before a successful mutation
+	After      *ObjectRef `json:"after,omitempty"`     // the changed object after a successful mutation
+	PrevHash   string     `json:"prev_hash,omitempty"` // append-only mode: hash of the previous record
+	Hash       string     `json:"hash,omitempty"`      // append-only mode: hash of this record including PrevHash
+}
+
+// Log is the audit trail of changes made through the agent. Entries are kept in memory
+// and appended to a JSONL file when one is configured. In append-only mode every record
+// carries the hash of the previous one, so removing or editing a record breaks the chain.
+type Log struct {
+	mu         sync.RWMutex
+	entries    []Entry
+	file       string
+	seq        int
+	appendOnly bool
+	lastHash   string
+	logger     *zap.Logger
+}
+
+// NewLog creates the audit log, loading previously persisted entries from the configured file
+func NewLog(cfg config.AuditConfig, logger *zap.Logger) (*Log, error) {
+	l := &Log{
+		file:       cfg.File,
+		appendOnly: cfg.AppendOnly,
+		logger:     logger,
+	}
+	if cfg.File == "" {
+		return l, nil
+	}
+
+	f, err := os.Open(cfg.File)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit file: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid audit entry in %s: %w", cfg.File, err)
+		}
+		l.entries = append(l.entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit file: %w", err)
+	}
+	l.seq = len(l.entries)
+
+	if l.appendOnly {
+		if err := VerifyChain(l.entries); err != nil {
+			logger.Error("Audit trail failed hash chain verification", zap.Error(err))
+		}
+		for _, entry := range l.entries {
+			if entry.Hash != "" {
+				l.lastHash = entry.Hash
+			}
+		}
+	}
+	return l, nil
+}
+
+// Record adds an entry to the audit trail, filling in its ID and timestamp
+func (l *Log) Record(entry Entry) (Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now().UTC()
+	}
+	entry.ID = fmt.Sprintf("audit_%d_%d", entry.Timestamp.UnixNano(), l.seq)
+	entry.Timestamp = entry.Timestamp.UTC()
+	if entry.Operator == "" {
+		entry.Operator = "anonymous"
+	}
+
+	if l.appendOnly {
+		prev := l.lastHash
+		if prev == "" {
+			prev = genesisHash
+		}
+		var err error
+		if entry, err = chain(entry, prev); err != nil {
+			return entry, err
+		}
+	}
+
+	if l.file != "" {
+		if err := l.appendToFile(entry); err != nil {
+			return entry, err
+		}
+	}
+	l.entries = append(l.entries, entry)
+	if entry.Hash != "" {
+		l.lastHash = entry.Hash
+	}
+
+	l.logger.Info("Audit entry recorded",
+		zap.String("action", entry.Action),
+		zap.String("operator", entry.Operator),
+		zap.String("tool", entry.Tool),
+		zap.String("target", entry.Target),
+		zap.String("outcome", entry.Outcome))
+	return entry, nil
+}
+
+// appendToFile writes one entry as a JSON line
+func (l *Log) appendToFile(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+	f, err := os.OpenFile(l.file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return nil
+}
+
+// Query returns the entries recorded in [from, to). A zero bound is open.
+func (l *Log) Query(from, to time.Time) []Entry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var result []Entry
+	for _, entry := range l.entries {
+		if !from.IsZero() && entry.Timestamp.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !entry.Timestamp.Before(to) {
+			continue
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
+// Verify checks the hash chain of the whole audit trail
+func (l *Log) Verify() error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return VerifyChain(l.entries)
+}
+
+// AppendOnly reports whether records are hash-chained
+func (l *Log) AppendOnly() bool {
+	return l.appendOnly
+}
+
+// Len returns the number of records in the audit trail
+func (l *Log) Len() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.entries)
+}
+
+// Actor identifies who is driving a chat request
+type Actor struct {
+	Operator   string
+	Session    string
+	RemoteAddr string
+	Model      string
+}
+
+type actorKey struct{}
+
+// WithActor returns a context carrying the actor of a request
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor stored in the context, if any
+func ActorFrom(ctx context.Context) Actor {
+	actor, _ := ctx.Value(actorKey{}).(Actor)
+	return actor
+}
@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// receiptPrefix replaces the "audit_" prefix of an entry ID in the ID of its receipt
+const receiptPrefix = "rcpt_"
+
+// ObjectRef points to a controller object at one version
+type ObjectRef struct {
+	URL      string          `json:"url"`
+	Version  string          `json:"version,omitempty"` // the object's _last_modified
+	Snapshot json.RawMessage `json:"-"`                 // the object as read, kept in the change history rather than the audit trail
+}
+
+// Receipt is the record of an executed change that change tickets can reference. It is read
+// from the audit entry of the change, so it is available for as long as the entry is kept.
+type Receipt struct {
+	ID        string     `json:"id"`
+	Tool      string     `json:"tool"`
+	Object    string     `json:"object,omitempty"` // uuid or name of the changed object
+	Before    *ObjectRef `json:"before,omitempty"` // the object before the change; none for a creation
+	After     *ObjectRef `json:"after,omitempty"`  // the object after the change; none for a deletion
+	Operator  string     `json:"operator"`
+	Session   string     `json:"session,omitempty"`
+	Timestamp time.Time  `json:"timestamp"`
+	AuditID   string     `json:"audit_id"`
+	AuditURL  string     `json:"audit_url,omitempty"` // export of the audit trail holding the entry
+	URL       string     `json:"url,omitempty"`
+}
+
+// NewReceipt returns the receipt of a recorded change; its links are left to the caller
+func NewReceipt(entry Entry) Receipt {
+	return Receipt{
+		ID:        receiptPrefix + strings.TrimPrefix(entry.ID, "audit_"),
+		Tool:      entry.Tool,
+		Object:    entry.Target,
+		Before:    entry.Before,
+		After:     entry.After,
+		Operator:  entry.Operator,
+		Session:   entry.Session,
+		Timestamp: entry.Timestamp,
+		AuditID:   entry.ID,
+	}
+}
+
+// Change returns the audit entry of the successful change a receipt ID refers to
+func (l *Log) Change(receiptID string) (Entry, bool) {
+	suffix, ok := strings.CutPrefix(receiptID, receiptPrefix)
+	if !ok {
+		return Entry{}, false
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		entry := l.entries[i]
+		if entry.ID == "audit_"+suffix {
+			if entry.Action != ActionMutation || entry.Outcome != OutcomeSuccess {
+				return Entry{}, false
+			}
+			return entry, true
+		}
+	}
+	return Entry{}, false
+}
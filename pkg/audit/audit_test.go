@@ -0,0 +1,85 @@
+package audit
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"aviagent/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestLogExport(t *testing.T) {
+	cfg := config.AuditConfig{File: filepath.Join(t.TempDir(), "audit.jsonl")}
+	log, err := NewLog(cfg, zaptest.NewLogger(t))
+	require.NoError(t, err)
+
+	day := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	_, err = log.Record(Entry{Timestamp: day, Action: ActionMutation, Operator: "alice", Tool: "delete_pool", Target: "pool-1", Outcome: OutcomeSuccess})
+	require.NoError(t, err)
+	_, err = log.Record(Entry{Timestamp: day.AddDate(0, 0, 2), Action: ActionMutation, Tool: "create_pool", Arguments: `{"name":"web, \"new\""}`, Outcome: OutcomeFailure, Error: "409"})
+	require.NoError(t, err)
+
+	// Entries survive a restart
+	log, err = NewLog(cfg, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	assert.Len(t, log.Query(time.Time{}, time.Time{}), 2)
+
+	entries := log.Query(day, day.AddDate(0, 0, 1))
+	require.Len(t, entries, 1)
+	assert.Equal(t, "alice", entries[0].Operator)
+
+	export, err := NewExport(log.Query(time.Time{}, time.Time{}), FormatCSV, "secret")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(export.Data)), "\n")
+	require.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "id,timestamp,action,operator"))
+	assert.Contains(t, lines[2], "anonymous")
+	assert.Len(t, export.SHA256, 64)
+	assert.True(t, Verify(export.Data, export.Signature, "secret"))
+	assert.False(t, Verify(append(export.Data, 'x'), export.Signature, "secret"))
+
+	unsigned, err := NewExport(nil, FormatJSON, "")
+	require.NoError(t, err)
+	assert.Equal(t, "[]", string(unsigned.Data))
+	assert.Empty(t, unsigned.Signature)
+
+	_, err = NewExport(nil, "xml", "")
+	assert.Error(t, err)
+}
+
+func TestAppendOnlyChain(t *testing.T) {
+	cfg := config.AuditConfig{File: filepath.Join(t.TempDir(), "audit.jsonl"), AppendOnly: true}
+	log, err := NewLog(cfg, zaptest.NewLogger(t))
+	require.NoError(t, err)
+
+	for _, tool := range []string{"create_pool", "update_pool", "delete_pool"} {
+		_, err := log.Record(Entry{Action: ActionMutation, Tool: tool, Outcome: OutcomeSuccess})
+		require.NoError(t, err)
+	}
+	entries := log.Query(time.Time{}, time.Time{})
+	assert.Equal(t, genesisHash, entries[0].PrevHash)
+	assert.Equal(t, entries[0].Hash, entries[1].PrevHash)
+	require.NoError(t, log.Verify())
+
+	// The chain continues across restarts
+	log, err = NewLog(cfg, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	last, err := log.Record(Entry{Action: ActionMutation, Tool: "scale_out_pool", Outcome: OutcomeSuccess})
+	require.NoError(t, err)
+	assert.Equal(t, entries[2].Hash, last.PrevHash)
+	require.NoError(t, log.Verify())
+
+	tampered := log.Query(time.Time{}, time.Time{})
+	tampered[1].Operator = "mallory"
+	var chainErr *ChainError
+	require.ErrorAs(t, VerifyChain(tampered), &chainErr)
+	assert.Equal(t, 1, chainErr.Index)
+
+	removed := append([]Entry{tampered[0]}, log.Query(time.Time{}, time.Time{})[2:]...)
+	assert.Error(t, VerifyChain(removed))
+}
@@ -0,0 +1,68 @@
+package avi
+
+import (
+	"net"
+	"strings"
+)
+
+// hostForURL brackets IPv6 literals so the address can be used as a URL host
+func hostForURL(host string) string {
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+// controllerURL builds an https URL for a controller address, which may be a hostname,
+// an IPv4 address or an IPv6 address with or without brackets
+func controllerURL(host, path string) string {
+	return "https://" + hostForURL(strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")) + path
+}
+
+// IPAddrType returns the Avi IpAddr type (V4, V6 or DNS) for an address
+func IPAddrType(addr string) string {
+	ip := net.ParseIP(strings.Trim(addr, "[]"))
+	switch {
+	case ip == nil:
+		return "DNS"
+	case ip.To4() != nil:
+		return "V4"
+	default:
+		return "V6"
+	}
+}
+
+// sameIP reports whether two addresses are equal, comparing IPv6 addresses in any notation
+func sameIP(a, b string) bool {
+	ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+	if ipA == nil || ipB == nil {
+		return a == b
+	}
+	return ipA.Equal(ipB)
+}
+
+// NormalizeServerIPs sets the ip.type of each server in a pool payload from its address,
+// so IPv6 servers aren't sent as V4 when the model leaves the schema default in place
+func NormalizeServerIPs(data map[string]interface{}) {
+	servers, ok := data["servers"].([]interface{})
+	if !ok {
+		return
+	}
+	for _, item := range servers {
+		server, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		ipObj, ok := server["ip"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		addr, ok := ipObj["addr"].(string)
+		if !ok {
+			continue
+		}
+		addr = strings.Trim(addr, "[]")
+		ipObj["addr"] = addr
+		ipObj["type"] = IPAddrType(addr)
+	}
+}
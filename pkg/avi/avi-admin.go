@@ -0,0 +1,233 @@
+package avi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// AdminRole is the built-in Avi role that administers tenants, users and roles
+const AdminRole = "System-Admin"
+
+// adminTools are the controller administration tools. They are only offered when the account's
+// role is known to be an administrator.
+var adminTools = map[string]bool{
+	"list_tenants":  true,
+	"create_tenant": true,
+	"list_users":    true,
+	"list_roles":    true,
+}
+
+// IsAdminTool reports whether a tool administers tenants, users or roles
+func IsAdminTool(name string) bool {
+	return adminTools[name]
+}
+
+// IsAdmin reports whether the account is a superuser or holds the System-Admin role
+func (p *Permissions) IsAdmin() bool {
+	return p != nil && (p.Superuser || p.Role == AdminRole)
+}
+
+// Tenant is an Avi tenant
+type Tenant struct {
+	UUID        string `json:"uuid"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Local       bool   `json:"local"` // objects are not shared with the admin tenant
+}
+
+// UserRole is a role a user holds in a tenant
+type UserRole struct {
+	Tenant     string `json:"tenant,omitempty"`
+	AllTenants bool   `json:"all_tenants,omitempty"`
+	Role       string `json:"role"`
+}
+
+// UserAccount is a controller user and the roles it holds; credentials are never included
+type UserAccount struct {
+	Username      string     `json:"username"`
+	Name          string     `json:"name,omitempty"`
+	Email         string     `json:"email,omitempty"`
+	Superuser     bool       `json:"superuser"`
+	Active        bool       `json:"active"`
+	DefaultTenant string     `json:"default_tenant,omitempty"`
+	Roles         []UserRole `json:"roles"`
+}
+
+// RoleSummary is a role and the access it grants per resource
+type RoleSummary struct {
+	UUID       string            `json:"uuid"`
+	Name       string            `json:"name"`
+	Tenant     string            `json:"tenant,omitempty"`
+	Privileges map[string]string `json:"privileges"` // resource -> NO_ACCESS, READ_ACCESS or WRITE_ACCESS
+}
+
+// ListTenants reads every tenant on the controller
+func ListTenants(ctx context.Context, exec GenericExecutor) ([]Tenant, error) {
+	results, err := listObjects(ctx, exec, "/tenant", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tenants: %w", err)
+	}
+	tenants := make([]Tenant, 0, len(results))
+	for _, t := range results {
+		tenants = append(tenants, tenantFromObject(t))
+	}
+	return tenants, nil
+}
+
+// CreateTenant creates a tenant. Local tenants don't share the objects of the admin tenant.
+func CreateTenant(ctx context.Context, exec GenericExecutor, name, description string, local bool) (*Tenant, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, fmt.Errorf("tenant name is required")
+	}
+	body := map[string]interface{}{
+		"name":  name,
+		"local": local,
+	}
+	if description != "" {
+		body["description"] = description
+	}
+	raw, err := exec.ExecuteGenericOperation(ctx, "POST", "/tenant", body, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tenant %s: %w", name, err)
+	}
+	created, _ := raw.(map[string]interface{})
+	if created == nil {
+		created = body
+	}
+	tenant := tenantFromObject(created)
+	return &tenant, nil
+}
+
+// ListUsers reads the controller users and their roles, optionally only those with access to
+// a tenant (including users with access to all tenants)
+func ListUsers(ctx context.Context, exec GenericExecutor, tenant string) ([]UserAccount, error) {
+	results, err := listObjects(ctx, exec, "/user", map[string]string{"include_name": "true"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	users := make([]UserAccount, 0, len(results))
+	for _, u := range results {
+		user := UserAccount{Roles: []UserRole{}}
+		user.Username, _ = u["username"].(string)
+		user.Name, _ = u["full_name"].(string)
+		if user.Name == "" {
+			user.Name, _ = u["name"].(string)
+		}
+		user.Email, _ = u["email"].(string)
+		user.Superuser, _ = u["is_superuser"].(bool)
+		user.Active = true
+		if active, ok := u["is_active"].(bool); ok {
+			user.Active = active
+		}
+		if ref, ok := u["default_tenant_ref"].(string); ok {
+			user.DefaultTenant = refName(ref)
+		}
+
+		access, _ := u["access"].([]interface{})
+		for _, item := range access {
+			a, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			role := UserRole{}
+			role.AllTenants, _ = a["all_tenants"].(bool)
+			if ref, ok := a["role_ref"].(string); ok {
+				role.Role = refName(ref)
+			}
+			if ref, ok := a["tenant_ref"].(string); ok {
+				role.Tenant = refName(ref)
+			}
+			user.Roles = append(user.Roles, role)
+		}
+
+		if tenant != "" && !user.hasTenant(tenant) {
+			continue
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// hasTenant reports whether the user holds a role in the tenant
+func (u UserAccount) hasTenant(tenant string) bool {
+	if u.Superuser {
+		return true
+	}
+	for _, role := range u.Roles {
+		if role.AllTenants || role.Tenant == tenant {
+			return true
+		}
+	}
+	return false
+}
+
+// ListRoles reads the roles and the access each grants
+func ListRoles(ctx context.Context, exec GenericExecutor) ([]RoleSummary, error) {
+	results, err := listObjects(ctx, exec, "/role", map[string]string{"include_name": "true"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	roles := make([]RoleSummary, 0, len(results))
+	for _, r := range results {
+		role := RoleSummary{Privileges: make(map[string]string)}
+		role.UUID, _ = r["uuid"].(string)
+		role.Name, _ = r["name"].(string)
+		if ref, ok := r["tenant_ref"].(string); ok {
+			role.Tenant = refName(ref)
+		}
+		privileges, _ := r["privileges"].([]interface{})
+		for _, item := range privileges {
+			if p, ok := item.(map[string]interface{}); ok {
+				resource, _ := p["resource"].(string)
+				access, _ := p["type"].(string)
+				if resource != "" {
+					role.Privileges[resource] = access
+				}
+			}
+		}
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+// listObjects reads the results of a collection through the generic API
+func listObjects(ctx context.Context, exec GenericExecutor, endpoint string, params map[string]string) ([]map[string]interface{}, error) {
+	raw, err := exec.ExecuteGenericOperation(ctx, "GET", endpoint, nil, params)
+	if err != nil {
+		return nil, err
+	}
+	collection, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected response type %T for %s", raw, endpoint)
+	}
+	items, _ := collection["results"].([]interface{})
+	results := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		if obj, ok := item.(map[string]interface{}); ok {
+			results = append(results, obj)
+		}
+	}
+	return results, nil
+}
+
+// tenantFromObject converts a tenant object
+func tenantFromObject(t map[string]interface{}) Tenant {
+	tenant := Tenant{Local: true}
+	tenant.UUID, _ = t["uuid"].(string)
+	tenant.Name, _ = t["name"].(string)
+	tenant.Description, _ = t["description"].(string)
+	if local, ok := t["local"].(bool); ok {
+		tenant.Local = local
+	}
+	return tenant
+}
+
+// refName returns the object name of a reference resolved with include_name
+// (".../api/role/role-1234#System-Admin"), or its UUID when the name isn't included
+func refName(ref string) string {
+	if i := strings.Index(ref, "#"); i >= 0 {
+		return ref[i+1:]
+	}
+	return refUUID(ref)
+}
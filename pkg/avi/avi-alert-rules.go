@@ -0,0 +1,331 @@
+package avi
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// AlertRulePrefix starts the names of the alert rules (AlertConfig and ActionGroupConfig pairs)
+// created through the agent, so they can be listed and removed without touching others
+const AlertRulePrefix = "aviagent-"
+
+// alertComparators map comparison operators to AlertConfig metric comparators
+var alertComparators = map[string]string{
+	"gt": "ALERT_OP_GT",
+	"ge": "ALERT_OP_GE",
+	"lt": "ALERT_OP_LT",
+	"le": "ALERT_OP_LE",
+}
+
+// comparatorSymbols describe the comparators in summaries
+var comparatorSymbols = map[string]string{
+	"ALERT_OP_GT": ">",
+	"ALERT_OP_GE": ">=",
+	"ALERT_OP_LT": "<",
+	"ALERT_OP_LE": "<=",
+}
+
+// alertSeverities map severities to action group alert levels
+var alertSeverities = map[string]string{
+	"low":    "ALERT_LOW",
+	"medium": "ALERT_MEDIUM",
+	"high":   "ALERT_HIGH",
+}
+
+// alertThrottle is how long, in seconds, an alert rule stays quiet after raising an alert
+const alertThrottle = 600
+
+// unsafeNameChars are replaced in generated alert rule names
+var unsafeNameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// AlertRuleRequest describes a metric alert rule on a virtual service, e.g. error rate above 2%
+// for 5 minutes
+type AlertRuleRequest struct {
+	Name           string  // generated from the virtual service and metric when empty
+	VirtualService string  // name or UUID
+	Metric         string  // metric ID or alias such as error_rate or latency
+	Comparator     string  // gt, ge, lt or le
+	Threshold      float64 // in the units of the metric
+	Duration       string  // how long the condition must hold, e.g. 5m
+	Severity       string  // low, medium or high
+	EmailConfig    string  // name of an AlertEmailConfig to notify, optional
+	SyslogConfig   string  // name of an AlertSyslogConfig to notify, optional
+}
+
+// AlertRulePlan is an alert rule ready to be created, or created unless it is a preview
+type AlertRulePlan struct {
+	Name        string                 `json:"name"`
+	Summary     string                 `json:"summary"`
+	Preview     bool                   `json:"preview"`
+	AlertConfig map[string]interface{} `json:"alert_config"`
+	ActionGroup map[string]interface{} `json:"action_group"`
+	Message     string                 `json:"message"`
+}
+
+// AlertRule is an alert rule created through the agent
+type AlertRule struct {
+	Name           string  `json:"name"`
+	UUID           string  `json:"uuid"`
+	VirtualService string  `json:"virtual_service,omitempty"`
+	Metric         string  `json:"metric,omitempty"`
+	Comparator     string  `json:"comparator,omitempty"`
+	Threshold      float64 `json:"threshold"`
+	Duration       int     `json:"duration_seconds,omitempty"`
+	Severity       string  `json:"severity,omitempty"`
+	Enabled        bool    `json:"enabled"`
+	Summary        string  `json:"summary,omitempty"`
+}
+
+// CreateAlertRule builds the ActionGroupConfig and AlertConfig of an alert rule and, unless
+// preview is set, creates them. The action group is removed again when the alert config can't be
+// created, so a failed rule leaves nothing behind.
+func CreateAlertRule(ctx context.Context, exec GenericExecutor, req AlertRuleRequest, preview bool) (*AlertRulePlan, error) {
+	plan, err := planAlertRule(ctx, exec, req)
+	if err != nil {
+		return nil, err
+	}
+	if preview {
+		plan.Preview = true
+		plan.Message = "Nothing was created. Show the rule to the user and create it once they approve."
+		return plan, nil
+	}
+
+	existing, err := listObjects(ctx, exec, "/alertconfig", map[string]string{"name": plan.Name})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for alert rule %s: %w", plan.Name, err)
+	}
+	for _, obj := range existing {
+		if name, _ := obj["name"].(string); name == plan.Name {
+			return nil, fmt.Errorf("alert rule %s already exists", plan.Name)
+		}
+	}
+
+	raw, err := exec.ExecuteGenericOperation(ctx, "POST", "/actiongroupconfig", plan.ActionGroup, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create action group %s: %w", plan.Name, err)
+	}
+	group, _ := raw.(map[string]interface{})
+	groupUUID, _ := group["uuid"].(string)
+	if groupUUID == "" {
+		return nil, fmt.Errorf("controller returned no uuid for action group %s", plan.Name)
+	}
+	plan.AlertConfig["action_group_ref"] = "/api/actiongroupconfig/" + groupUUID
+
+	if _, err := exec.ExecuteGenericOperation(ctx, "POST", "/alertconfig", plan.AlertConfig, nil); err != nil {
+		if _, derr := exec.ExecuteGenericOperation(ctx, "DELETE", "/actiongroupconfig/"+groupUUID, nil, nil); derr != nil {
+			return nil, fmt.Errorf("failed to create alert config %s: %w (action group %s was left behind: %v)", plan.Name, err, groupUUID, derr)
+		}
+		return nil, fmt.Errorf("failed to create alert config %s: %w", plan.Name, err)
+	}
+	plan.Message = "Alert rule created"
+	return plan, nil
+}
+
+// planAlertRule validates a request and builds the objects of the alert rule
+func planAlertRule(ctx context.Context, exec GenericExecutor, req AlertRuleRequest) (*AlertRulePlan, error) {
+	if strings.TrimSpace(req.VirtualService) == "" {
+		return nil, fmt.Errorf("virtual_service parameter required")
+	}
+	metricID := strings.TrimSpace(req.Metric)
+	if alias, ok := metricAliases["virtualservice"][strings.ToLower(metricID)]; ok {
+		metricID = alias
+	}
+	info, ok := lookupMetric(metricID)
+	if !ok || !info.supports("virtualservice") {
+		return nil, fmt.Errorf("unknown metric %q, supported metrics for virtualservice: %s", req.Metric, strings.Join(catalogIDs("virtualservice"), ", "))
+	}
+	comparator, ok := alertComparators[strings.ToLower(valueOrDefault(req.Comparator, "gt"))]
+	if !ok {
+		return nil, fmt.Errorf("unknown comparator %q (use gt, ge, lt or le)", req.Comparator)
+	}
+	if math.IsNaN(req.Threshold) || math.IsInf(req.Threshold, 0) {
+		return nil, fmt.Errorf("invalid threshold")
+	}
+	duration, err := parseTimeRange(valueOrDefault(req.Duration, "5m"))
+	if err != nil {
+		return nil, err
+	}
+	level, ok := alertSeverities[strings.ToLower(valueOrDefault(req.Severity, "medium"))]
+	if !ok {
+		return nil, fmt.Errorf("unknown severity %q (use low, medium or high)", req.Severity)
+	}
+
+	vs, err := findVirtualService(ctx, exec, req.VirtualService)
+	if err != nil {
+		return nil, err
+	}
+	vsName, _ := vs["name"].(string)
+	vsUUID, _ := vs["uuid"].(string)
+
+	name := req.Name
+	if name == "" {
+		name = fmt.Sprintf("%s-%s", vsName, strings.ToLower(strings.TrimSpace(req.Metric)))
+	}
+	name = unsafeNameChars.ReplaceAllString(strings.ReplaceAll(name, "_", "-"), "-")
+	if !strings.HasPrefix(name, AlertRulePrefix) {
+		name = AlertRulePrefix + name
+	}
+
+	seconds := int(duration.Seconds())
+	summary := fmt.Sprintf("%s %s %s %g %s for %s", vsName, metricID, comparatorSymbols[comparator], req.Threshold,
+		strings.ToLower(info.Units), FormatDuration(int64(seconds)))
+
+	group := map[string]interface{}{
+		"name":          name,
+		"level":         level,
+		"external_only": false,
+		"description":   "Created by aviagent: " + summary,
+	}
+	if req.EmailConfig != "" {
+		group["email_config_ref"] = "/api/alertemailconfig?name=" + req.EmailConfig
+	}
+	if req.SyslogConfig != "" {
+		group["syslog_config_ref"] = "/api/alertsyslogconfig?name=" + req.SyslogConfig
+	}
+
+	alert := map[string]interface{}{
+		"name":        name,
+		"description": "Created by aviagent: " + summary,
+		"summary":     summary,
+		"enabled":     true,
+		"source":      "METRICS",
+		"category":    "REALTIME",
+		"object_type": "VIRTUALSERVICE",
+		"obj_uuid":    vsUUID,
+		"threshold":   1,
+		"throttle":    alertThrottle,
+		"alert_rule": map[string]interface{}{
+			"operator": "OPERATOR_AND",
+			"metrics_rule": []interface{}{map[string]interface{}{
+				"metric_id":        metricID,
+				"duration":         seconds,
+				"metric_threshold": map[string]interface{}{"comparator": comparator, "threshold": req.Threshold},
+			}},
+		},
+	}
+	return &AlertRulePlan{Name: name, Summary: summary, AlertConfig: alert, ActionGroup: group}, nil
+}
+
+// ListAlertRules returns the alert rules created through the agent
+func ListAlertRules(ctx context.Context, exec GenericExecutor) ([]AlertRule, error) {
+	objects, err := listAllObjects(ctx, exec, "/alertconfig", map[string]string{"include_name": "true"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alert rules: %w", err)
+	}
+	// Virtual service names and severities are best effort: the rule is listed without them
+	vsNames := map[string]string{}
+	if list, err := listAllObjects(ctx, exec, "/virtualservice", map[string]string{"fields": "name,uuid"}); err == nil {
+		for _, vs := range list {
+			uuid, _ := vs["uuid"].(string)
+			vsNames[uuid], _ = vs["name"].(string)
+		}
+	}
+	levels := map[string]string{}
+	if list, err := listAllObjects(ctx, exec, "/actiongroupconfig", nil); err == nil {
+		for _, group := range list {
+			uuid, _ := group["uuid"].(string)
+			level, _ := group["level"].(string)
+			levels[uuid] = strings.ToLower(strings.TrimPrefix(level, "ALERT_"))
+		}
+	}
+
+	rules := []AlertRule{}
+	for _, obj := range objects {
+		name, _ := obj["name"].(string)
+		if !strings.HasPrefix(name, AlertRulePrefix) {
+			continue
+		}
+		rule := AlertRule{Name: name}
+		rule.UUID, _ = obj["uuid"].(string)
+		rule.Enabled, _ = obj["enabled"].(bool)
+		rule.Summary, _ = obj["summary"].(string)
+		uuid, _ := obj["obj_uuid"].(string)
+		rule.VirtualService = valueOrDefault(vsNames[uuid], uuid)
+		if group, _ := obj["action_group_ref"].(string); group != "" {
+			rule.Severity = levels[refUUID(group)]
+		}
+		alertRule, _ := obj["alert_rule"].(map[string]interface{})
+		if metrics, _ := alertRule["metrics_rule"].([]interface{}); len(metrics) > 0 {
+			metric, _ := metrics[0].(map[string]interface{})
+			rule.Metric, _ = metric["metric_id"].(string)
+			rule.Duration = int(numberValue(metric["duration"]))
+			threshold, _ := metric["metric_threshold"].(map[string]interface{})
+			comparator, _ := threshold["comparator"].(string)
+			rule.Comparator = comparatorSymbols[comparator]
+			rule.Threshold = numberValue(threshold["threshold"])
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// DeleteAlertRule removes an alert rule created through the agent and its action group. Alert
+// rules created otherwise are refused.
+func DeleteAlertRule(ctx context.Context, exec GenericExecutor, name string) error {
+	if !strings.HasPrefix(name, AlertRulePrefix) {
+		name = AlertRulePrefix + name
+	}
+	objects, err := listObjects(ctx, exec, "/alertconfig", map[string]string{"name": name})
+	if err != nil {
+		return fmt.Errorf("failed to find alert rule %s: %w", name, err)
+	}
+	var rule map[string]interface{}
+	for _, obj := range objects {
+		if n, _ := obj["name"].(string); n == name {
+			rule = obj
+		}
+	}
+	if rule == nil {
+		return fmt.Errorf("alert rule %s not found among the rules created through the agent", name)
+	}
+
+	uuid, _ := rule["uuid"].(string)
+	if _, err := exec.ExecuteGenericOperation(ctx, "DELETE", "/alertconfig/"+uuid, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete alert config %s: %w", name, err)
+	}
+	if ref, _ := rule["action_group_ref"].(string); ref != "" {
+		group, err := getObject(ctx, exec, "/actiongroupconfig/"+refUUID(ref))
+		if err != nil {
+			return fmt.Errorf("deleted alert config %s but not its action group: %w", name, err)
+		}
+		if groupName, _ := group["name"].(string); strings.HasPrefix(groupName, AlertRulePrefix) {
+			if _, err := exec.ExecuteGenericOperation(ctx, "DELETE", "/actiongroupconfig/"+refUUID(ref), nil, nil); err != nil {
+				return fmt.Errorf("deleted alert config %s but not its action group: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// findVirtualService reads a virtual service by name, or by UUID when no name matches
+func findVirtualService(ctx context.Context, exec GenericExecutor, nameOrUUID string) (map[string]interface{}, error) {
+	return findObject(ctx, exec, "virtualservice", "virtual service", nameOrUUID)
+}
+
+// findObject looks an object of the given type up by name, then by UUID
+func findObject(ctx context.Context, exec GenericExecutor, objType, label, nameOrUUID string) (map[string]interface{}, error) {
+	results, err := listObjects(ctx, exec, "/"+objType, map[string]string{"name": nameOrUUID})
+	if err == nil {
+		for _, obj := range results {
+			if name, _ := obj["name"].(string); name == nameOrUUID {
+				return obj, nil
+			}
+		}
+	}
+	obj, err := getObject(ctx, exec, "/"+objType+"/"+nameOrUUID)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s not found", label, nameOrUUID)
+	}
+	return obj, nil
+}
+
+// valueOrDefault returns value, or def when value is empty
+func valueOrDefault(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
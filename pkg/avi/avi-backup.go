@@ -0,0 +1,85 @@
+package avi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// ConfigExportSummary counts the objects of a configuration export by type
+type ConfigExportSummary struct {
+	Version     string         `json:"version,omitempty"`
+	Total       int            `json:"total"`
+	Objects     map[string]int `json:"objects"`
+	Types       []string       `json:"types"`
+	DownloadURL string         `json:"download_url,omitempty"`
+}
+
+// SummarizeConfigExport counts the objects in a configuration export. The full export is
+// usually far too large to hand to the LLM, so tools return this summary and a download link.
+func SummarizeConfigExport(export interface{}) (*ConfigExportSummary, error) {
+	obj, ok := export.(map[string]interface{})
+	if !ok {
+		raw, err := json.Marshal(export)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal configuration export: %w", err)
+		}
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return nil, fmt.Errorf("unexpected configuration export format: %w", err)
+		}
+	}
+
+	summary := &ConfigExportSummary{Objects: make(map[string]int)}
+	for objType, value := range obj {
+		if objType == "META" {
+			if meta, ok := value.(map[string]interface{}); ok {
+				if version, ok := meta["version"].(map[string]interface{}); ok {
+					summary.Version, _ = version["Version"].(string)
+				}
+			}
+			continue
+		}
+		if list, ok := value.([]interface{}); ok {
+			summary.Objects[objType] = len(list)
+			summary.Total += len(list)
+			summary.Types = append(summary.Types, objType)
+		}
+	}
+	sort.Strings(summary.Types)
+	return summary, nil
+}
+
+// ResolveBackupConfiguration returns the backup configuration to run a backup with: the given
+// UUID, or the controller's only/default backup configuration when none is given
+func ResolveBackupConfiguration(ctx context.Context, exec GenericExecutor, uuid string) (string, error) {
+	if uuid != "" {
+		return uuid, nil
+	}
+
+	raw, err := exec.ExecuteGenericOperation(ctx, "GET", "/backupconfiguration", nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to list backup configurations: %w", err)
+	}
+	collection, _ := raw.(map[string]interface{})
+	results, _ := collection["results"].([]interface{})
+
+	var fallback string
+	for _, item := range results {
+		cfg, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		id, _ := cfg["uuid"].(string)
+		if name, _ := cfg["name"].(string); name == "Backup-Configuration" {
+			return id, nil
+		}
+		if fallback == "" {
+			fallback = id
+		}
+	}
+	if fallback == "" || len(results) > 1 {
+		return "", fmt.Errorf("found %d backup configurations, specify which one to use", len(results))
+	}
+	return fallback, nil
+}
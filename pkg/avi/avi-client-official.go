@@ -0,0 +1,606 @@
+package avi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"aviagent/pkg/config"
+	"github.com/vmware/alb-sdk/go/clients"
+	"github.com/vmware/alb-sdk/go/models"
+	"github.com/vmware/alb-sdk/go/session"
+	"go.uber.org/zap"
+)
+
+// OfficialClient represents the Avi Load Balancer API client using official SDK
+type OfficialClient struct {
+	aviClient *clients.AviClient
+	analytics *clients.AviClient // session on avi.analytics_host for analytics reads, nil when unset
+	config    *config.AviConfig
+	logger    *zap.Logger
+}
+
+// NewOfficialClient creates a new Avi client using the official SDK
+func NewOfficialClient(cfg *config.AviConfig, logger *zap.Logger) (*OfficialClient, error) {
+	logger.Info("Creating Avi client using official SDK",
+		zap.String("host", cfg.Host),
+		zap.String("username", cfg.Username),
+		zap.String("tenant", cfg.Tenant),
+		zap.String("version", cfg.Version))
+
+	// Create Avi client using official SDK
+	options := []func(*session.AviSession) error{
+		session.SetPassword(cfg.Password),
+		session.SetTenant(cfg.Tenant),
+	}
+	
+	// Set insecure option if configured
+	if cfg.Insecure {
+		options = append(options, session.SetInsecure)
+	}
+	
+	// Set version if specified
+	if cfg.Version != "" {
+		options = append(options, session.SetVersion(cfg.Version))
+	}
+
+	// The SDK only knows insecure or CA-verified sessions; pinning needs its own transport
+	if len(cfg.PinnedFingerprints) > 0 {
+		options = append(options, session.SetClient(&http.Client{
+			Transport: &http.Transport{TLSClientConfig: controllerTLSConfig(cfg), TLSHandshakeTimeout: 10 * time.Second},
+			Timeout:   time.Duration(cfg.Timeout) * time.Second,
+		}))
+	}
+
+	// The analytics node is reached directly, without failing over to the other nodes, and a
+	// failed query isn't retried so it falls back to the leader right away
+	analyticsOptions := append([]func(*session.AviSession) error(nil), options...)
+	analyticsOptions = append(analyticsOptions, session.SetMaxApiRetries(1), session.DisableControllerStatusCheckOnFailure(true))
+
+	// Fail over from the cluster VIP to the individual controller nodes
+	if len(cfg.Nodes) > 0 {
+		hosts := controllerHosts(cfg)
+		logger.Info("Controller failover enabled", zap.Strings("controllers", hosts))
+		transport := &http.Transport{
+			TLSClientConfig: controllerTLSConfig(cfg),
+			DialContext: (&net.Dialer{
+				Timeout:   10 * time.Second, // Fail fast on dead nodes so the next address is tried in time
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		}
+		options = append(options, session.SetClient(&http.Client{
+			Transport: newFailoverTransport(transport, hosts, logger),
+			Timeout:   time.Duration(cfg.Timeout) * time.Second,
+		}))
+	}
+	
+	aviClient, err := clients.NewAviClient(cfg.Host, cfg.Username, options...)
+	if err != nil {
+		logger.Error("Failed to create Avi client using official SDK", zap.Error(err))
+		return nil, fmt.Errorf("failed to create Avi client: %w", err)
+	}
+
+	logger.Info("Successfully created Avi client using official SDK")
+
+	client := &OfficialClient{
+		aviClient: aviClient,
+		config:    cfg,
+		logger:    logger,
+	}
+
+	// Heavy metric and log queries go to a follower node, keeping the leader free for configuration
+	// changes. Without it they are read from the leader.
+	if cfg.AnalyticsHost != "" {
+		analytics, err := clients.NewAviClient(cfg.AnalyticsHost, cfg.Username, analyticsOptions...)
+		if err != nil {
+			logger.Warn("Failed to log in to the analytics node, analytics are read from the leader",
+				zap.String("analytics_host", cfg.AnalyticsHost), zap.Error(err))
+		} else {
+			logger.Info("Analytics queries routed to a dedicated node", zap.String("analytics_host", cfg.AnalyticsHost))
+			client.analytics = analytics
+		}
+	}
+	return client, nil
+}
+
+// ListVirtualServices lists all virtual services
+func (c *OfficialClient) ListVirtualServices(ctx context.Context, params map[string]string) (interface{}, error) {
+	c.logger.Info("Listing virtual services using official SDK")
+	return c.aviClient.VirtualService.GetAll()
+}
+
+// GetVirtualService gets a specific virtual service by UUID
+func (c *OfficialClient) GetVirtualService(ctx context.Context, uuid string, params map[string]string) (interface{}, error) {
+	c.logger.Info("Getting virtual service using official SDK", zap.String("uuid", uuid))
+	return c.aviClient.VirtualService.Get(uuid)
+}
+
+// CreateVirtualService creates a new virtual service
+func (c *OfficialClient) CreateVirtualService(ctx context.Context, data map[string]interface{}) (interface{}, error) {
+	c.logger.Info("Creating virtual service using official SDK")
+	vs := &models.VirtualService{}
+	if err := convertToModel(data, vs); err != nil {
+		return nil, err
+	}
+	return c.aviClient.VirtualService.Create(vs)
+}
+
+// UpdateVirtualService updates the given fields of an existing virtual service
+func (c *OfficialClient) UpdateVirtualService(ctx context.Context, uuid string, data map[string]interface{}) (interface{}, error) {
+	c.logger.Info("Updating virtual service using official SDK", zap.String("uuid", uuid))
+	return c.aviClient.VirtualService.Patch(uuid, data, "replace")
+}
+
+// DeleteVirtualService deletes a virtual service
+func (c *OfficialClient) DeleteVirtualService(ctx context.Context, uuid string) error {
+	c.logger.Info("Deleting virtual service using official SDK", zap.String("uuid", uuid))
+	return c.aviClient.VirtualService.Delete(uuid)
+}
+
+// SetVirtualServiceEnabled enables or disables a virtual service
+func (c *OfficialClient) SetVirtualServiceEnabled(ctx context.Context, uuid string, enabled bool) (interface{}, error) {
+	c.logger.Info("Setting virtual service enabled state using official SDK",
+		zap.String("uuid", uuid),
+		zap.Bool("enabled", enabled))
+	return c.aviClient.VirtualService.Patch(uuid, map[string]interface{}{"enabled": enabled}, "replace")
+}
+
+// VirtualServiceAction runs a runtime action (scaleout, scalein, migrate, switchover) on a virtual service
+func (c *OfficialClient) VirtualServiceAction(ctx context.Context, uuid, action string, body map[string]interface{}) (interface{}, error) {
+	c.logger.Info("Running virtual service action using official SDK",
+		zap.String("uuid", uuid),
+		zap.String("action", action))
+	if err := validateVirtualServiceAction(action); err != nil {
+		return nil, err
+	}
+	return c.ExecuteGenericOperation(ctx, "POST", fmt.Sprintf("/virtualservice/%s/%s", uuid, action), body, nil)
+}
+
+// ListPools lists all pools
+func (c *OfficialClient) ListPools(ctx context.Context, params map[string]string) (interface{}, error) {
+	c.logger.Info("Listing pools using official SDK")
+	return c.aviClient.Pool.GetAll()
+}
+
+// GetPool gets a specific pool by UUID
+func (c *OfficialClient) GetPool(ctx context.Context, uuid string, params map[string]string) (interface{}, error) {
+	c.logger.Info("Getting pool using official SDK", zap.String("uuid", uuid))
+	return c.aviClient.Pool.Get(uuid)
+}
+
+// CreatePool creates a new pool
+func (c *OfficialClient) CreatePool(ctx context.Context, data map[string]interface{}) (interface{}, error) {
+	c.logger.Info("Creating pool using official SDK")
+	pool := &models.Pool{}
+	if err := convertToModel(data, pool); err != nil {
+		return nil, err
+	}
+	return c.aviClient.Pool.Create(pool)
+}
+
+// UpdatePool updates the given fields of an existing pool
+func (c *OfficialClient) UpdatePool(ctx context.Context, uuid string, data map[string]interface{}) (interface{}, error) {
+	c.logger.Info("Updating pool using official SDK", zap.String("uuid", uuid))
+	return c.aviClient.Pool.Patch(uuid, data, "replace")
+}
+
+// DeletePool deletes a pool
+func (c *OfficialClient) DeletePool(ctx context.Context, uuid string) error {
+	c.logger.Info("Deleting pool using official SDK", zap.String("uuid", uuid))
+	return c.aviClient.Pool.Delete(uuid)
+}
+
+// ScaleOutPool scales out a pool by adding servers. The SDK has no model for the action, so it is
+// posted through the session.
+func (c *OfficialClient) ScaleOutPool(ctx context.Context, uuid string, params map[string]interface{}) error {
+	c.logger.Info("Scaling out pool using official SDK", zap.String("uuid", uuid))
+	if _, err := c.ExecuteGenericOperation(ctx, "POST", "/pool/"+uuid+"/scaleout", poolScaleBody(params), nil); err != nil {
+		return fmt.Errorf("scale out failed: %w", err)
+	}
+	return nil
+}
+
+// ScaleInPool scales in a pool by removing servers, posted through the session like ScaleOutPool
+func (c *OfficialClient) ScaleInPool(ctx context.Context, uuid string, params map[string]interface{}) error {
+	c.logger.Info("Scaling in pool using official SDK", zap.String("uuid", uuid))
+	if _, err := c.ExecuteGenericOperation(ctx, "POST", "/pool/"+uuid+"/scalein", poolScaleBody(params), nil); err != nil {
+		return fmt.Errorf("scale in failed: %w", err)
+	}
+	return nil
+}
+
+// poolScaleBody is the body of a pool scale action; the controller expects an object even
+// without parameters
+func poolScaleBody(params map[string]interface{}) map[string]interface{} {
+	if params == nil {
+		return map[string]interface{}{}
+	}
+	return params
+}
+
+// SetPoolServerEnabled enables or disables a single server (by IP and optional port) within a pool
+func (c *OfficialClient) SetPoolServerEnabled(ctx context.Context, uuid, ip string, port int, enabled bool) (interface{}, error) {
+	c.logger.Info("Setting pool server state using official SDK",
+		zap.String("uuid", uuid),
+		zap.String("server", ip),
+		zap.Int("port", port),
+		zap.Bool("enabled", enabled))
+
+	raw, err := c.ExecuteGenericOperation(ctx, "GET", "/pool/"+uuid, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	pool, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected pool response type %T", raw)
+	}
+
+	patch, err := poolServerPatch(pool, ip, port, enabled)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.aviClient.Pool.Patch(uuid, patch, "replace")
+}
+
+// ListHealthMonitors lists all health monitors
+func (c *OfficialClient) ListHealthMonitors(ctx context.Context, params map[string]string) (interface{}, error) {
+	c.logger.Info("Listing health monitors using official SDK")
+	return c.aviClient.HealthMonitor.GetAll()
+}
+
+// GetHealthMonitor gets a specific health monitor by UUID
+func (c *OfficialClient) GetHealthMonitor(ctx context.Context, uuid string, params map[string]string) (interface{}, error) {
+	c.logger.Info("Getting health monitor using official SDK", zap.String("uuid", uuid))
+	return c.aviClient.HealthMonitor.Get(uuid)
+}
+
+// CreateHealthMonitor creates a new health monitor
+func (c *OfficialClient) CreateHealthMonitor(ctx context.Context, data map[string]interface{}) (interface{}, error) {
+	c.logger.Info("Creating health monitor using official SDK")
+	hm := &models.HealthMonitor{}
+	if err := convertToModel(data, hm); err != nil {
+		return nil, err
+	}
+	return c.aviClient.HealthMonitor.Create(hm)
+}
+
+// UpdateHealthMonitor updates the given fields of an existing health monitor
+func (c *OfficialClient) UpdateHealthMonitor(ctx context.Context, uuid string, data map[string]interface{}) (interface{}, error) {
+	c.logger.Info("Updating health monitor using official SDK", zap.String("uuid", uuid))
+	return c.aviClient.HealthMonitor.Patch(uuid, data, "replace")
+}
+
+// DeleteHealthMonitor deletes a health monitor
+func (c *OfficialClient) DeleteHealthMonitor(ctx context.Context, uuid string) error {
+	c.logger.Info("Deleting health monitor using official SDK", zap.String("uuid", uuid))
+	return c.aviClient.HealthMonitor.Delete(uuid)
+}
+
+// ListApplicationProfiles lists all application profiles
+func (c *OfficialClient) ListApplicationProfiles(ctx context.Context, params map[string]string) (interface{}, error) {
+	c.logger.Info("Listing application profiles using official SDK")
+	return c.aviClient.ApplicationProfile.GetAll()
+}
+
+// GetApplicationProfile gets a specific application profile by UUID
+func (c *OfficialClient) GetApplicationProfile(ctx context.Context, uuid string, params map[string]string) (interface{}, error) {
+	c.logger.Info("Getting application profile using official SDK", zap.String("uuid", uuid))
+	return c.aviClient.ApplicationProfile.Get(uuid)
+}
+
+// CreateApplicationProfile creates a new application profile
+func (c *OfficialClient) CreateApplicationProfile(ctx context.Context, data map[string]interface{}) (interface{}, error) {
+	c.logger.Info("Creating application profile using official SDK")
+	profile := &models.ApplicationProfile{}
+	if err := convertToModel(data, profile); err != nil {
+		return nil, err
+	}
+	return c.aviClient.ApplicationProfile.Create(profile)
+}
+
+// AttachApplicationProfile sets the application profile of a virtual service
+func (c *OfficialClient) AttachApplicationProfile(ctx context.Context, vsUUID, profileUUID string) (interface{}, error) {
+	c.logger.Info("Attaching application profile using official SDK",
+		zap.String("vs_uuid", vsUUID),
+		zap.String("profile_uuid", profileUUID))
+	patch := map[string]interface{}{
+		"application_profile_ref": "/api/applicationprofile/" + profileUUID,
+	}
+	return c.aviClient.VirtualService.Patch(vsUUID, patch, "replace")
+}
+
+// ListPersistenceProfiles lists all application persistence profiles
+func (c *OfficialClient) ListPersistenceProfiles(ctx context.Context, params map[string]string) (interface{}, error) {
+	c.logger.Info("Listing persistence profiles using official SDK")
+	return c.aviClient.ApplicationPersistenceProfile.GetAll()
+}
+
+// GetPersistenceProfile gets a specific application persistence profile by UUID
+func (c *OfficialClient) GetPersistenceProfile(ctx context.Context, uuid string, params map[string]string) (interface{}, error) {
+	c.logger.Info("Getting persistence profile using official SDK", zap.String("uuid", uuid))
+	return c.aviClient.ApplicationPersistenceProfile.Get(uuid)
+}
+
+// CreatePersistenceProfile creates a new application persistence profile
+func (c *OfficialClient) CreatePersistenceProfile(ctx context.Context, data map[string]interface{}) (interface{}, error) {
+	c.logger.Info("Creating persistence profile using official SDK")
+	profile := &models.ApplicationPersistenceProfile{}
+	if err := convertToModel(data, profile); err != nil {
+		return nil, err
+	}
+	return c.aviClient.ApplicationPersistenceProfile.Create(profile)
+}
+
+// AttachPersistenceProfile sets the persistence profile of a pool. Persistence lives on the pool in Avi,
+// so when poolUUID is empty the default pool of the virtual service is used.
+func (c *OfficialClient) AttachPersistenceProfile(ctx context.Context, vsUUID, poolUUID, profileUUID string) (interface{}, error) {
+	c.logger.Info("Attaching persistence profile using official SDK",
+		zap.String("vs_uuid", vsUUID),
+		zap.String("pool_uuid", poolUUID),
+		zap.String("profile_uuid", profileUUID))
+
+	if poolUUID == "" {
+		vs, err := c.aviClient.VirtualService.Get(vsUUID)
+		if err != nil {
+			return nil, err
+		}
+		if vs.PoolRef != nil {
+			poolUUID = refUUID(*vs.PoolRef)
+		}
+		if poolUUID == "" {
+			return nil, fmt.Errorf("virtual service %s has no default pool, specify the pool", vsUUID)
+		}
+	}
+
+	patch := map[string]interface{}{
+		"application_persistence_profile_ref": "/api/applicationpersistenceprofile/" + profileUUID,
+	}
+	return c.aviClient.Pool.Patch(poolUUID, patch, "replace")
+}
+
+// ListServiceEngines lists all service engines
+func (c *OfficialClient) ListServiceEngines(ctx context.Context, params map[string]string) (interface{}, error) {
+	c.logger.Info("Listing service engines using official SDK")
+	return c.aviClient.ServiceEngine.GetAll()
+}
+
+// GetServiceEngine gets a specific service engine by UUID
+func (c *OfficialClient) GetServiceEngine(ctx context.Context, uuid string, params map[string]string) (interface{}, error) {
+	c.logger.Info("Getting service engine using official SDK", zap.String("uuid", uuid))
+	return c.aviClient.ServiceEngine.Get(uuid)
+}
+
+// ServiceEngineAction runs a runtime action (reboot) on a service engine
+func (c *OfficialClient) ServiceEngineAction(ctx context.Context, uuid, action string, body map[string]interface{}) (interface{}, error) {
+	c.logger.Info("Running service engine action using official SDK",
+		zap.String("uuid", uuid),
+		zap.String("action", action))
+	if err := validateServiceEngineAction(action); err != nil {
+		return nil, err
+	}
+	return c.ExecuteGenericOperation(ctx, "POST", fmt.Sprintf("/serviceengine/%s/%s", uuid, action), body, nil)
+}
+
+// ListVRFContexts lists all VRF contexts
+func (c *OfficialClient) ListVRFContexts(ctx context.Context, params map[string]string) (interface{}, error) {
+	c.logger.Info("Listing VRF contexts using official SDK")
+	return c.aviClient.VrfContext.GetAll()
+}
+
+// GetVRFContext gets a specific VRF context by UUID
+func (c *OfficialClient) GetVRFContext(ctx context.Context, uuid string, params map[string]string) (interface{}, error) {
+	c.logger.Info("Getting VRF context using official SDK", zap.String("uuid", uuid))
+	return c.aviClient.VrfContext.Get(uuid)
+}
+
+// GetBGPPeerStatus gets the runtime BGP peering state of a service engine
+func (c *OfficialClient) GetBGPPeerStatus(ctx context.Context, seUUID string, params map[string]string) (interface{}, error) {
+	c.logger.Info("Getting BGP peer status using official SDK", zap.String("uuid", seUUID))
+	return c.ExecuteGenericOperation(ctx, "GET", "/serviceengine/"+seUUID+"/bgp", nil, params)
+}
+
+// ListBackups lists the configuration backups stored on the controller
+func (c *OfficialClient) ListBackups(ctx context.Context, params map[string]string) (interface{}, error) {
+	c.logger.Info("Listing backups using official SDK")
+	return c.ExecuteGenericOperation(ctx, "GET", "/backup", nil, params)
+}
+
+// TriggerBackup runs an on-demand backup with a backup configuration
+func (c *OfficialClient) TriggerBackup(ctx context.Context, configUUID string) (interface{}, error) {
+	c.logger.Info("Triggering backup using official SDK", zap.String("backup_configuration", configUUID))
+	return c.ExecuteGenericOperation(ctx, "POST", "/backupconfiguration/"+configUUID+"/backup", map[string]interface{}{}, nil)
+}
+
+// ExportConfiguration gets a full configuration export
+func (c *OfficialClient) ExportConfiguration(ctx context.Context, params map[string]string) (interface{}, error) {
+	c.logger.Info("Exporting configuration using official SDK")
+	return c.ExecuteGenericOperation(ctx, "GET", "/configuration/export", nil, params)
+}
+
+// GetAnalytics gets metrics for a resource. params carries the metrics API parameters
+// (metric_id, step, limit), see MetricsQuery. The SDK has no metrics model, so the series are read
+// through the session, from the analytics node when one is configured.
+func (c *OfficialClient) GetAnalytics(ctx context.Context, resourceType, uuid string, params map[string]string) (interface{}, error) {
+	c.logger.Info("Getting analytics using official SDK", 
+		zap.String("resource_type", resourceType),
+		zap.String("uuid", uuid))
+	endpoint := fmt.Sprintf("/analytics/metrics/%s/%s", resourceType, uuid)
+	return c.ExecuteGenericOperation(ctx, "GET", endpoint, nil, params)
+}
+
+// GetInventory gets runtime and health-score inventory for a resource type, or a single object when uuid is set
+func (c *OfficialClient) GetInventory(ctx context.Context, resourceType, uuid string, params map[string]string) (interface{}, error) {
+	c.logger.Info("Getting inventory using official SDK",
+		zap.String("resource_type", resourceType),
+		zap.String("uuid", uuid))
+	endpoint := fmt.Sprintf("/%s-inventory", resourceType)
+	if uuid != "" {
+		endpoint += "/" + uuid
+	}
+	return c.ExecuteGenericOperation(ctx, "GET", endpoint, nil, params)
+}
+
+// GetHealthScore gets the analytics health score series (with sub-scores) for an object
+func (c *OfficialClient) GetHealthScore(ctx context.Context, resourceType, uuid string, params map[string]string) (interface{}, error) {
+	c.logger.Info("Getting health score using official SDK",
+		zap.String("resource_type", resourceType),
+		zap.String("uuid", uuid))
+	endpoint := fmt.Sprintf("/analytics/healthscore/%s/%s", resourceType, uuid)
+	return c.ExecuteGenericOperation(ctx, "GET", endpoint, nil, params)
+}
+
+// ExecuteGenericOperation executes a generic API operation
+func (c *OfficialClient) ExecuteGenericOperation(ctx context.Context, method, endpoint string, body interface{}, params map[string]string) (interface{}, error) {
+	c.logger.Info("Executing generic operation using official SDK", 
+		zap.String("method", method),
+		zap.String("endpoint", endpoint))
+	
+	endpoint, params, err := NormalizeEndpoint(endpoint, params)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build the full URL (the SDK session prefix already ends with a slash)
+	fullURL := "api" + endpoint
+	
+	// Create a result interface
+	var result interface{}
+	
+	// Execute the request based on method
+	switch method {
+	case "GET":
+		// Read through Download so files are described rather than decoded as JSON
+		file, err := c.Download(ctx, endpoint, params)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Body.Close()
+		if IsFile(file.ContentType) {
+			return file.Result(endpoint, params), nil
+		}
+		data, err := io.ReadAll(file.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response body: %w", err)
+		}
+		if len(data) > 0 && json.Unmarshal(data, &result) != nil {
+			return string(data), nil
+		}
+		return result, nil
+	case "POST":
+		err := c.aviClient.AviSession.Post(fullURL, body, &result, session.SetParams(params))
+		return result, err
+	case "PUT":
+		err := c.aviClient.AviSession.Put(fullURL, body, &result, session.SetParams(params))
+		return result, err
+	case "DELETE":
+		err := c.aviClient.AviSession.Delete(fullURL)
+		return nil, err
+	case "PATCH":
+		op, payload := patchOperation(body)
+		err := c.aviClient.AviSession.Patch(fullURL, payload, op, &result, session.SetParams(params))
+		return result, err
+	default:
+		return nil, fmt.Errorf("unsupported HTTP method: %s", method)
+	}
+}
+
+// patchOperation splits a PATCH body such as {"replace": {...}} into the operation and its payload,
+// as the SDK wraps the payload itself. Bodies without an operation are replacements.
+func patchOperation(body interface{}) (string, interface{}) {
+	if m, ok := body.(map[string]interface{}); ok && len(m) == 1 {
+		for _, op := range []string{"add", "replace", "delete"} {
+			if payload, ok := m[op]; ok {
+				return op, payload
+			}
+		}
+	}
+	return "replace", body
+}
+
+// Download requests a file from the controller and returns it unread, so it can be streamed. The
+// SDK session has no context support: the body is closed when ctx ends, and the transfer is also
+// bounded by the session timeout (avi.timeout).
+func (c *OfficialClient) Download(ctx context.Context, endpoint string, params map[string]string) (*Download, error) {
+	endpoint, params, err := NormalizeEndpoint(endpoint, params)
+	if err != nil {
+		return nil, err
+	}
+	uri := "api" + endpoint
+	if len(params) > 0 {
+		values := url.Values{}
+		for key, value := range params {
+			values.Set(key, value)
+		}
+		uri += "?" + values.Encode()
+	}
+
+	resp, err := c.get(endpoint, uri)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, ParseError(resp.StatusCode, detail)
+	}
+	stop := context.AfterFunc(ctx, func() { resp.Body.Close() })
+	download := newDownload(resp, endpoint, params)
+	download.Body = &stopCloser{ReadCloser: resp.Body, stop: stop}
+	return download, nil
+}
+
+// get reads uri, from the analytics node for analytics endpoints when one is configured. A failing
+// analytics node falls back to the leader, so answers don't depend on it.
+func (c *OfficialClient) get(endpoint, uri string) (*http.Response, error) {
+	if c.analytics != nil && IsAnalyticsEndpoint(endpoint) {
+		resp, err := c.analytics.AviSession.RestRequest(http.MethodGet, uri, nil, "", nil)
+		if err == nil && resp.StatusCode < http.StatusInternalServerError {
+			return resp, nil
+		}
+		if err == nil {
+			resp.Body.Close()
+			err = fmt.Errorf("status %d", resp.StatusCode)
+		}
+		c.logger.Warn("Analytics node failed, reading from the leader",
+			zap.String("analytics_host", c.config.AnalyticsHost), zap.String("endpoint", endpoint), zap.Error(err))
+	}
+	return c.aviClient.AviSession.RestRequest(http.MethodGet, uri, nil, "", nil)
+}
+
+// stopCloser releases the context watch of a download body when it is closed
+type stopCloser struct {
+	io.ReadCloser
+	stop func() bool
+}
+
+func (s *stopCloser) Close() error {
+	s.stop()
+	return s.ReadCloser.Close()
+}
+
+// convertToModel converts a generic object map into an SDK model via its JSON representation
+func convertToModel(data map[string]interface{}, model interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal object: %w", err)
+	}
+	if err := json.Unmarshal(raw, model); err != nil {
+		return fmt.Errorf("invalid object definition: %w", err)
+	}
+	return nil
+}
+
+// Close closes the Avi client connection
+func (c *OfficialClient) Close() error {
+	c.logger.Info("Closing Avi client")
+	// The official SDK doesn't have an explicit close method
+	// Session management is handled automatically
+	return nil
+}
\ No newline at end of file
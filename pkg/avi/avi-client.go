@@ -3,7 +3,6 @@ package avi
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -15,7 +14,8 @@ import (
 	"sync"
 	"time"
 
-	"aviagent/internal/config"
+	"aviagent/internal/requestid"
+	"aviagent/pkg/config"
 
 	"go.uber.org/zap"
 )
@@ -24,6 +24,7 @@ import (
 type Client struct {
 	config     *config.AviConfig
 	httpClient *http.Client
+	streamClient *http.Client // downloads, bounded by their context instead of avi.timeout
 	baseURL    string
 	logger     *zap.Logger
 	session    *Session
@@ -85,10 +86,7 @@ func NewClient(cfg *config.AviConfig, logger *zap.Logger) (*Client, error) {
 
 	// Create HTTP client with optimized transport for SSL handling
 	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: cfg.Insecure,
-			MinVersion:         tls.VersionTLS12, // Enforce minimum TLS version
-		},
+		TLSClientConfig:     controllerTLSConfig(cfg), // TLS 1.2 at least, pinned certificate when configured
 		MaxIdleConns:        100,              // Maximum number of idle connections
 		IdleConnTimeout:     90 * time.Second,  // Timeout for idle connections
 		TLSHandshakeTimeout: 10 * time.Second,  // Timeout for TLS handshake
@@ -99,8 +97,14 @@ func NewClient(cfg *config.AviConfig, logger *zap.Logger) (*Client, error) {
 		}).DialContext,
 	}
 
+	var roundTripper http.RoundTripper = transport
+	if len(cfg.Nodes) > 0 {
+		// Fail over from the cluster VIP to the individual controller nodes
+		roundTripper = newFailoverTransport(transport, controllerHosts(cfg), logger)
+	}
+
 	httpClient := &http.Client{
-		Transport: transport,
+		Transport: roundTripper,
 		Timeout:   time.Duration(cfg.Timeout) * time.Second,
 	}
 
@@ -113,7 +117,8 @@ func NewClient(cfg *config.AviConfig, logger *zap.Logger) (*Client, error) {
 	client := &Client{
 		config:     cfg,
 		httpClient: httpClient,
-		baseURL:    fmt.Sprintf("https://%s/api", cfg.Host),
+		streamClient: &http.Client{Transport: roundTripper},
+		baseURL:    controllerURL(cfg.Host, "/api"),
 		logger:     logger,
 		cache:      newCache(30 * time.Second), // 30 second cache TTL
 		authMethod: authMethod,
@@ -203,7 +208,7 @@ func (c *Client) authenticate() error {
 
 // authenticateSession performs session-based authentication (recommended method)
 func (c *Client) authenticateSession() error {
-	loginURL := fmt.Sprintf("https://%s/login", c.config.Host)
+	loginURL := controllerURL(c.config.Host, "/login")
 	
 	loginData := map[string]string{
 		"username": c.config.Username,
@@ -264,17 +269,23 @@ func (c *Client) authenticateBasic() error {
 
 // makeRequest performs an authenticated API request with context support
 func (c *Client) makeRequest(ctx context.Context, method, endpoint string, body interface{}, params map[string]string) (*http.Response, error) {
+	return c.request(ctx, c.httpClient, method, endpoint, body, params)
+}
+
+// request performs an authenticated API request with the given HTTP client
+func (c *Client) request(ctx context.Context, httpClient *http.Client, method, endpoint string, body interface{}, params map[string]string) (*http.Response, error) {
+	logger := requestid.Logger(ctx, c.logger)
 	if c.session == nil {
 		return nil, fmt.Errorf("not authenticated")
 	}
 
-	var bodyReader io.Reader
+	var jsonData []byte
 	if body != nil {
-		jsonData, err := json.Marshal(body)
+		var err error
+		jsonData, err = json.Marshal(body)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal request body: %w", err)
 		}
-		bodyReader = bytes.NewBuffer(jsonData)
 	}
 
 	// Build URL with parameters
@@ -287,6 +298,46 @@ func (c *Client) makeRequest(ctx context.Context, method, endpoint string, body
 		requestURL += "?" + values.Encode()
 	}
 
+	logger.Debug("Making API request",
+		zap.String("method", method),
+		zap.String("endpoint", endpoint),
+		zap.Any("params", params),
+		zap.String("url", requestURL),
+		zap.String("auth_method", c.authMethod))
+
+	resp, err := c.doAuthenticated(ctx, httpClient, method, requestURL, jsonData)
+	if err != nil {
+		logger.Error("API request failed",
+			zap.String("method", method),
+			zap.String("endpoint", endpoint),
+			zap.Error(err))
+		return nil, fmt.Errorf("API request failed: %w", err)
+	}
+
+	// The session expired or the controller failed over to a node that doesn't know it:
+	// log in again and retry once
+	if resp.StatusCode == http.StatusUnauthorized && c.authMethod != "basic" {
+		resp.Body.Close()
+		logger.Info("Avi session rejected, re-authenticating", zap.String("endpoint", endpoint))
+		if err := c.authenticate(); err != nil {
+			return nil, fmt.Errorf("re-authentication failed: %w", err)
+		}
+		resp, err = c.doAuthenticated(ctx, httpClient, method, requestURL, jsonData)
+		if err != nil {
+			return nil, fmt.Errorf("API request failed: %w", err)
+		}
+	}
+
+	return resp, nil
+}
+
+// doAuthenticated sends a single request with the current session credentials
+func (c *Client) doAuthenticated(ctx context.Context, httpClient *http.Client, method, requestURL string, jsonData []byte) (*http.Response, error) {
+	var bodyReader io.Reader
+	if jsonData != nil {
+		bodyReader = bytes.NewReader(jsonData)
+	}
+
 	req, err := http.NewRequestWithContext(ctx, method, requestURL, bodyReader)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -296,6 +347,7 @@ func (c *Client) makeRequest(ctx context.Context, method, endpoint string, body
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-Avi-Version", c.config.Version)
 	req.Header.Set("X-Avi-Tenant", c.config.Tenant)
+	requestid.SetHeader(ctx, req)
 	if c.session.CSRFToken != "" {
 		req.Header.Set("X-CSRFToken", c.session.CSRFToken)
 	}
@@ -316,23 +368,7 @@ func (c *Client) makeRequest(ctx context.Context, method, endpoint string, body
 		})
 	}
 
-	c.logger.Debug("Making API request",
-		zap.String("method", method),
-		zap.String("endpoint", endpoint),
-		zap.Any("params", params),
-		zap.String("url", requestURL),
-		zap.String("auth_method", c.authMethod))
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		c.logger.Error("API request failed",
-			zap.String("method", method),
-			zap.String("endpoint", endpoint),
-			zap.Error(err))
-		return nil, fmt.Errorf("API request failed: %w", err)
-	}
-
-	return resp, nil
+	return httpClient.Do(req)
 }
 
 // ListVirtualServices retrieves all virtual services
@@ -354,7 +390,7 @@ func (c *Client) ListVirtualServices(ctx context.Context, params map[string]stri
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, ParseError(resp.StatusCode, body)
 	}
 
 	var result APIResponse
@@ -380,7 +416,7 @@ func (c *Client) GetVirtualService(ctx context.Context, uuid string, params map[
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, ParseError(resp.StatusCode, body)
 	}
 
 	var result map[string]interface{}
@@ -401,7 +437,7 @@ func (c *Client) CreateVirtualService(ctx context.Context, vsData map[string]int
 
 	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, ParseError(resp.StatusCode, body)
 	}
 
 	var result map[string]interface{}
@@ -423,7 +459,7 @@ func (c *Client) UpdateVirtualService(ctx context.Context, uuid string, vsData m
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, ParseError(resp.StatusCode, body)
 	}
 
 	var result map[string]interface{}
@@ -445,12 +481,28 @@ func (c *Client) DeleteVirtualService(ctx context.Context, uuid string) error {
 
 	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return ParseError(resp.StatusCode, body)
 	}
 
 	return nil
 }
 
+// SetVirtualServiceEnabled enables or disables a virtual service
+func (c *Client) SetVirtualServiceEnabled(ctx context.Context, uuid string, enabled bool) (map[string]interface{}, error) {
+	endpoint := fmt.Sprintf("/virtualservice/%s", uuid)
+	patch := map[string]interface{}{"enabled": enabled}
+	return c.doRequest(ctx, "PATCH", endpoint, map[string]interface{}{"replace": patch}, nil)
+}
+
+// VirtualServiceAction runs a runtime action (scaleout, scalein, migrate, switchover) on a virtual service
+func (c *Client) VirtualServiceAction(ctx context.Context, uuid, action string, body map[string]interface{}) (map[string]interface{}, error) {
+	if err := validateVirtualServiceAction(action); err != nil {
+		return nil, err
+	}
+	endpoint := fmt.Sprintf("/virtualservice/%s/%s", uuid, action)
+	return c.doRequest(ctx, "POST", endpoint, body, nil)
+}
+
 // ListPools retrieves all pools
 func (c *Client) ListPools(ctx context.Context, params map[string]string) (*APIResponse, error) {
 	// Generate cache key for this request
@@ -470,7 +522,7 @@ func (c *Client) ListPools(ctx context.Context, params map[string]string) (*APIR
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, ParseError(resp.StatusCode, body)
 	}
 
 	var result APIResponse
@@ -496,7 +548,7 @@ func (c *Client) GetPool(ctx context.Context, uuid string, params map[string]str
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, ParseError(resp.StatusCode, body)
 	}
 
 	var result map[string]interface{}
@@ -517,7 +569,7 @@ func (c *Client) CreatePool(ctx context.Context, poolData map[string]interface{}
 
 	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, ParseError(resp.StatusCode, body)
 	}
 
 	var result map[string]interface{}
@@ -528,6 +580,28 @@ func (c *Client) CreatePool(ctx context.Context, poolData map[string]interface{}
 	return result, nil
 }
 
+// UpdatePool updates the given fields of an existing pool
+func (c *Client) UpdatePool(ctx context.Context, uuid string, poolData map[string]interface{}) (map[string]interface{}, error) {
+	return c.PatchPool(ctx, uuid, "replace", poolData)
+}
+
+// DeletePool deletes a pool
+func (c *Client) DeletePool(ctx context.Context, uuid string) error {
+	endpoint := fmt.Sprintf("/pool/%s", uuid)
+	resp, err := c.makeRequest(ctx, "DELETE", endpoint, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return ParseError(resp.StatusCode, body)
+	}
+
+	return nil
+}
+
 // ScaleOutPool scales out a pool by adding servers
 func (c *Client) ScaleOutPool(ctx context.Context, uuid string, params map[string]interface{}) error {
 	endpoint := fmt.Sprintf("/pool/%s/scaleout", uuid)
@@ -539,7 +613,7 @@ func (c *Client) ScaleOutPool(ctx context.Context, uuid string, params map[strin
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("scale out failed with status %d: %s", resp.StatusCode, string(body))
+		return fmt.Errorf("scale out failed: %w", ParseError(resp.StatusCode, body))
 	}
 
 	return nil
@@ -556,12 +630,33 @@ func (c *Client) ScaleInPool(ctx context.Context, uuid string, params map[string
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("scale in failed with status %d: %s", resp.StatusCode, string(body))
+		return fmt.Errorf("scale in failed: %w", ParseError(resp.StatusCode, body))
 	}
 
 	return nil
 }
 
+// PatchPool applies a PATCH operation (add, replace or delete) to a pool
+func (c *Client) PatchPool(ctx context.Context, uuid, patchOp string, data map[string]interface{}) (map[string]interface{}, error) {
+	endpoint := fmt.Sprintf("/pool/%s", uuid)
+	return c.doRequest(ctx, "PATCH", endpoint, map[string]interface{}{patchOp: data}, nil)
+}
+
+// SetPoolServerEnabled enables or disables a single server (by IP and optional port) within a pool
+func (c *Client) SetPoolServerEnabled(ctx context.Context, uuid, ip string, port int, enabled bool) (map[string]interface{}, error) {
+	pool, err := c.GetPool(ctx, uuid, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	patch, err := poolServerPatch(pool, ip, port, enabled)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.PatchPool(ctx, uuid, "replace", patch)
+}
+
 // ListHealthMonitors retrieves all health monitors
 func (c *Client) ListHealthMonitors(ctx context.Context, params map[string]string) (*APIResponse, error) {
 	resp, err := c.makeRequest(ctx, "GET", "/healthmonitor", nil, params)
@@ -572,7 +667,7 @@ func (c *Client) ListHealthMonitors(ctx context.Context, params map[string]strin
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, ParseError(resp.StatusCode, body)
 	}
 
 	var result APIResponse
@@ -594,7 +689,28 @@ func (c *Client) GetHealthMonitor(ctx context.Context, uuid string, params map[s
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, ParseError(resp.StatusCode, body)
+	}
+
+	var result map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return result, nil
+}
+
+// CreateHealthMonitor creates a new health monitor
+func (c *Client) CreateHealthMonitor(ctx context.Context, hmData map[string]interface{}) (map[string]interface{}, error) {
+	resp, err := c.makeRequest(ctx, "POST", "/healthmonitor", hmData, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, ParseError(resp.StatusCode, body)
 	}
 
 	var result map[string]interface{}
@@ -605,6 +721,90 @@ func (c *Client) GetHealthMonitor(ctx context.Context, uuid string, params map[s
 	return result, nil
 }
 
+// UpdateHealthMonitor updates the given fields of an existing health monitor
+func (c *Client) UpdateHealthMonitor(ctx context.Context, uuid string, hmData map[string]interface{}) (map[string]interface{}, error) {
+	endpoint := fmt.Sprintf("/healthmonitor/%s", uuid)
+	return c.doRequest(ctx, "PATCH", endpoint, map[string]interface{}{"replace": hmData}, nil)
+}
+
+// DeleteHealthMonitor deletes a health monitor
+func (c *Client) DeleteHealthMonitor(ctx context.Context, uuid string) error {
+	endpoint := fmt.Sprintf("/healthmonitor/%s", uuid)
+	resp, err := c.makeRequest(ctx, "DELETE", endpoint, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return ParseError(resp.StatusCode, body)
+	}
+
+	return nil
+}
+
+// ListApplicationProfiles retrieves all application profiles
+func (c *Client) ListApplicationProfiles(ctx context.Context, params map[string]string) (map[string]interface{}, error) {
+	return c.doRequest(ctx, "GET", "/applicationprofile", nil, params)
+}
+
+// GetApplicationProfile retrieves a specific application profile by UUID
+func (c *Client) GetApplicationProfile(ctx context.Context, uuid string, params map[string]string) (map[string]interface{}, error) {
+	endpoint := fmt.Sprintf("/applicationprofile/%s", uuid)
+	return c.doRequest(ctx, "GET", endpoint, nil, params)
+}
+
+// CreateApplicationProfile creates a new application profile
+func (c *Client) CreateApplicationProfile(ctx context.Context, profileData map[string]interface{}) (map[string]interface{}, error) {
+	return c.doRequest(ctx, "POST", "/applicationprofile", profileData, nil)
+}
+
+// AttachApplicationProfile sets the application profile of a virtual service
+func (c *Client) AttachApplicationProfile(ctx context.Context, vsUUID, profileUUID string) (map[string]interface{}, error) {
+	endpoint := fmt.Sprintf("/virtualservice/%s", vsUUID)
+	patch := map[string]interface{}{
+		"application_profile_ref": "/api/applicationprofile/" + profileUUID,
+	}
+	return c.doRequest(ctx, "PATCH", endpoint, map[string]interface{}{"replace": patch}, nil)
+}
+
+// ListPersistenceProfiles retrieves all application persistence profiles
+func (c *Client) ListPersistenceProfiles(ctx context.Context, params map[string]string) (map[string]interface{}, error) {
+	return c.doRequest(ctx, "GET", "/applicationpersistenceprofile", nil, params)
+}
+
+// GetPersistenceProfile retrieves a specific application persistence profile by UUID
+func (c *Client) GetPersistenceProfile(ctx context.Context, uuid string, params map[string]string) (map[string]interface{}, error) {
+	endpoint := fmt.Sprintf("/applicationpersistenceprofile/%s", uuid)
+	return c.doRequest(ctx, "GET", endpoint, nil, params)
+}
+
+// CreatePersistenceProfile creates a new application persistence profile
+func (c *Client) CreatePersistenceProfile(ctx context.Context, profileData map[string]interface{}) (map[string]interface{}, error) {
+	return c.doRequest(ctx, "POST", "/applicationpersistenceprofile", profileData, nil)
+}
+
+// AttachPersistenceProfile sets the persistence profile of a pool. Persistence lives on the pool in Avi,
+// so when poolUUID is empty the default pool of the virtual service is used.
+func (c *Client) AttachPersistenceProfile(ctx context.Context, vsUUID, poolUUID, profileUUID string) (map[string]interface{}, error) {
+	if poolUUID == "" {
+		vs, err := c.GetVirtualService(ctx, vsUUID, nil)
+		if err != nil {
+			return nil, err
+		}
+		poolRef, _ := vs["pool_ref"].(string)
+		if poolUUID = refUUID(poolRef); poolUUID == "" {
+			return nil, fmt.Errorf("virtual service %s has no default pool, specify the pool", vsUUID)
+		}
+	}
+
+	patch := map[string]interface{}{
+		"application_persistence_profile_ref": "/api/applicationpersistenceprofile/" + profileUUID,
+	}
+	return c.PatchPool(ctx, poolUUID, "replace", patch)
+}
+
 // ListServiceEngines retrieves all service engines
 func (c *Client) ListServiceEngines(ctx context.Context, params map[string]string) (*APIResponse, error) {
 	resp, err := c.makeRequest(ctx, "GET", "/serviceengine", nil, params)
@@ -615,7 +815,7 @@ func (c *Client) ListServiceEngines(ctx context.Context, params map[string]strin
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, ParseError(resp.StatusCode, body)
 	}
 
 	var result APIResponse
@@ -637,7 +837,7 @@ func (c *Client) GetServiceEngine(ctx context.Context, uuid string, params map[s
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, ParseError(resp.StatusCode, body)
 	}
 
 	var result map[string]interface{}
@@ -648,9 +848,52 @@ func (c *Client) GetServiceEngine(ctx context.Context, uuid string, params map[s
 	return result, nil
 }
 
-// GetAnalytics retrieves analytics data for a specific resource
+// ListVRFContexts retrieves all VRF contexts
+func (c *Client) ListVRFContexts(ctx context.Context, params map[string]string) (map[string]interface{}, error) {
+	return c.doRequest(ctx, "GET", "/vrfcontext", nil, params)
+}
+
+// GetVRFContext retrieves a specific VRF context (static routes and BGP profile) by UUID
+func (c *Client) GetVRFContext(ctx context.Context, uuid string, params map[string]string) (map[string]interface{}, error) {
+	endpoint := fmt.Sprintf("/vrfcontext/%s", uuid)
+	return c.doRequest(ctx, "GET", endpoint, nil, params)
+}
+
+// GetBGPPeerStatus retrieves the runtime BGP peering state of a service engine
+func (c *Client) GetBGPPeerStatus(ctx context.Context, seUUID string, params map[string]string) (map[string]interface{}, error) {
+	endpoint := fmt.Sprintf("/serviceengine/%s/bgp", seUUID)
+	return c.doRequest(ctx, "GET", endpoint, nil, params)
+}
+
+// ServiceEngineAction runs a runtime action (reboot) on a service engine
+func (c *Client) ServiceEngineAction(ctx context.Context, uuid, action string, body map[string]interface{}) (map[string]interface{}, error) {
+	if err := validateServiceEngineAction(action); err != nil {
+		return nil, err
+	}
+	endpoint := fmt.Sprintf("/serviceengine/%s/%s", uuid, action)
+	return c.doRequest(ctx, "POST", endpoint, body, nil)
+}
+
+// ListBackups retrieves the configuration backups stored on the controller
+func (c *Client) ListBackups(ctx context.Context, params map[string]string) (map[string]interface{}, error) {
+	return c.doRequest(ctx, "GET", "/backup", nil, params)
+}
+
+// TriggerBackup runs an on-demand backup with a backup configuration
+func (c *Client) TriggerBackup(ctx context.Context, configUUID string) (map[string]interface{}, error) {
+	endpoint := fmt.Sprintf("/backupconfiguration/%s/backup", configUUID)
+	return c.doRequest(ctx, "POST", endpoint, map[string]interface{}{}, nil)
+}
+
+// ExportConfiguration retrieves a full configuration export
+func (c *Client) ExportConfiguration(ctx context.Context, params map[string]string) (map[string]interface{}, error) {
+	return c.doRequest(ctx, "GET", "/configuration/export", nil, params)
+}
+
+// GetAnalytics retrieves metrics for a specific resource. params carries the metrics API
+// parameters (metric_id, step, limit), see MetricsQuery.
 func (c *Client) GetAnalytics(ctx context.Context, resourceType, uuid string, params map[string]string) (map[string]interface{}, error) {
-	endpoint := fmt.Sprintf("/analytics/%s/%s", resourceType, uuid)
+	endpoint := fmt.Sprintf("/analytics/metrics/%s/%s", resourceType, uuid)
 	resp, err := c.makeRequest(ctx, "GET", endpoint, nil, params)
 	if err != nil {
 		return nil, err
@@ -659,7 +902,7 @@ func (c *Client) GetAnalytics(ctx context.Context, resourceType, uuid string, pa
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, ParseError(resp.StatusCode, body)
 	}
 
 	var result map[string]interface{}
@@ -670,11 +913,64 @@ func (c *Client) GetAnalytics(ctx context.Context, resourceType, uuid string, pa
 	return result, nil
 }
 
+// doRequest performs an API request and decodes a JSON object response
+func (c *Client) doRequest(ctx context.Context, method, endpoint string, body interface{}, params map[string]string) (map[string]interface{}, error) {
+	resp, err := c.makeRequest(ctx, method, endpoint, body, params)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, ParseError(resp.StatusCode, body)
+	}
+
+	var result map[string]interface{}
+	if resp.StatusCode == http.StatusNoContent {
+		return result, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return result, nil
+}
+
+// GetHealthScore retrieves the analytics health score series (with performance, resources,
+// anomaly and security sub-scores) for an object
+func (c *Client) GetHealthScore(ctx context.Context, resourceType, uuid string, params map[string]string) (map[string]interface{}, error) {
+	endpoint := fmt.Sprintf("/analytics/healthscore/%s/%s", resourceType, uuid)
+	return c.doRequest(ctx, "GET", endpoint, nil, params)
+}
+
+// refUUID extracts the object UUID from an Avi reference URL such as
+// https://controller/api/pool/pool-1234#web-pool
+func refUUID(ref string) string {
+	ref = strings.SplitN(ref, "#", 2)[0]
+	ref = strings.SplitN(ref, "?", 2)[0]
+	ref = strings.TrimRight(ref, "/")
+	if i := strings.LastIndex(ref, "/"); i >= 0 {
+		return ref[i+1:]
+	}
+	return ref
+}
+
+// GetInventory retrieves runtime and health-score inventory for a resource type
+// (virtualservice, pool, serviceengine), or a single object when uuid is set
+func (c *Client) GetInventory(ctx context.Context, resourceType, uuid string, params map[string]string) (map[string]interface{}, error) {
+	endpoint := fmt.Sprintf("/%s-inventory", resourceType)
+	if uuid != "" {
+		endpoint += "/" + uuid
+	}
+	return c.doRequest(ctx, "GET", endpoint, nil, params)
+}
+
 // ExecuteGenericOperation performs a generic API operation
 func (c *Client) ExecuteGenericOperation(ctx context.Context, method, endpoint string, body interface{}, params map[string]string) (interface{}, error) {
-	// Ensure endpoint starts with /
-	if !strings.HasPrefix(endpoint, "/") {
-		endpoint = "/" + endpoint
+	endpoint, params, err := NormalizeEndpoint(endpoint, params)
+	if err != nil {
+		return nil, err
 	}
 
 	resp, err := c.makeRequest(ctx, method, endpoint, body, params)
@@ -683,13 +979,18 @@ func (c *Client) ExecuteGenericOperation(ctx context.Context, method, endpoint s
 	}
 	defer resp.Body.Close()
 
+	// Files aren't read: the result points at the proxy URL that streams them
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 && IsFile(resp.Header.Get("Content-Type")) {
+		return newDownload(resp, endpoint, params).Result(endpoint, params), nil
+	}
+
 	responseBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(responseBody))
+		return nil, ParseError(resp.StatusCode, responseBody)
 	}
 
 	// Try to parse as JSON
@@ -704,11 +1005,35 @@ func (c *Client) ExecuteGenericOperation(ctx context.Context, method, endpoint s
 	return result, nil
 }
 
+// Download requests a file from the controller and returns it unread, so it can be streamed. The
+// transfer is bounded by ctx rather than avi.timeout, as bundles can take minutes.
+func (c *Client) Download(ctx context.Context, endpoint string, params map[string]string) (*Download, error) {
+	endpoint, params, err := NormalizeEndpoint(endpoint, params)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := c.streamClient
+	if httpClient == nil {
+		httpClient = c.httpClient
+	}
+	resp, err := c.request(ctx, httpClient, http.MethodGet, endpoint, nil, params)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, ParseError(resp.StatusCode, detail)
+	}
+	return newDownload(resp, endpoint, params), nil
+}
+
 // Close closes the client and performs cleanup
 func (c *Client) Close() error {
 	// Perform logout if needed
 	if c.session != nil {
-		logoutURL := fmt.Sprintf("https://%s/logout", c.config.Host)
+		logoutURL := controllerURL(c.config.Host, "/logout")
 		req, err := http.NewRequest("POST", logoutURL, nil)
 		if err == nil {
 			req.Header.Set("X-Avi-Version", c.config.Version)
@@ -0,0 +1,173 @@
+package avi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"aviagent/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// clockSkewCheckInterval is how long a skew measurement is reused before the controller is asked again
+const clockSkewCheckInterval = 5 * time.Minute
+
+// ClockSkewChecker compares the controller clock with the agent clock. Analytics and log
+// queries are expressed in agent time, so a large skew shifts the window the controller returns.
+type ClockSkewChecker struct {
+	hosts     []string
+	client    *http.Client
+	threshold time.Duration
+	logger    *zap.Logger
+
+	mu        sync.Mutex
+	skew      time.Duration
+	err       error
+	checkedAt time.Time
+	measuring chan struct{} // closed when the measurement in flight is done, nil when none is
+}
+
+// NewClockSkewChecker creates a clock skew checker for the configured controller
+func NewClockSkewChecker(cfg *config.AviConfig, logger *zap.Logger) *ClockSkewChecker {
+	return &ClockSkewChecker{
+		hosts: controllerHosts(cfg),
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: controllerTLSConfig(cfg),
+			},
+		},
+		threshold: time.Duration(cfg.ClockSkewThreshold) * time.Second,
+		logger:    logger,
+	}
+}
+
+// Enabled reports whether a skew threshold is configured
+func (c *ClockSkewChecker) Enabled() bool {
+	return c != nil && c.threshold > 0
+}
+
+// Skew returns how far the controller clock is ahead of the agent clock (negative when behind).
+// Measurements are cached for a few minutes. The controller is asked without holding the lock:
+// concurrent callers wait for the measurement in flight rather than each asking it.
+func (c *ClockSkewChecker) Skew(ctx context.Context) (time.Duration, error) {
+	for {
+		c.mu.Lock()
+		if !c.checkedAt.IsZero() && time.Since(c.checkedAt) < clockSkewCheckInterval {
+			skew, err := c.skew, c.err
+			c.mu.Unlock()
+			return skew, err
+		}
+		if measuring := c.measuring; measuring != nil {
+			c.mu.Unlock()
+			select {
+			case <-measuring:
+				continue
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			}
+		}
+		measuring := make(chan struct{})
+		c.measuring = measuring
+		c.mu.Unlock()
+
+		skew, err := c.measure(ctx)
+
+		c.mu.Lock()
+		// A measurement cut short by its caller isn't kept; a waiting caller measures again
+		if ctx.Err() == nil {
+			c.skew, c.err = skew, err
+			c.checkedAt = time.Now()
+		}
+		c.measuring = nil
+		c.mu.Unlock()
+		close(measuring)
+
+		if err != nil {
+			c.logger.Warn("Failed to measure controller clock skew", zap.Error(err))
+		} else if c.exceeds(skew) {
+			c.logger.Warn("Controller clock skew detected",
+				zap.Duration("skew", skew),
+				zap.Duration("threshold", c.threshold))
+		}
+		return skew, err
+	}
+}
+
+// Reset drops the cached measurement, so the next call measures the skew again
+func (c *ClockSkewChecker) Reset() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checkedAt = time.Time{}
+}
+
+// Warning returns a user-facing notice when the skew exceeds the threshold, or an empty string
+func (c *ClockSkewChecker) Warning(ctx context.Context) string {
+	if !c.Enabled() {
+		return ""
+	}
+	skew, err := c.Skew(ctx)
+	if err != nil || !c.exceeds(skew) {
+		return ""
+	}
+
+	direction := "ahead of"
+	if skew < 0 {
+		direction = "behind"
+	}
+	return fmt.Sprintf("The Avi controller clock is %s %s the agent clock. Time-range analytics and log results may be shifted by that amount; check NTP on the controller and the agent host.",
+		absDuration(skew).Round(time.Second), direction)
+}
+
+// exceeds reports whether a skew is larger than the threshold in either direction
+func (c *ClockSkewChecker) exceeds(skew time.Duration) bool {
+	return absDuration(skew) > c.threshold
+}
+
+// measure reads the Date header of an unauthenticated controller endpoint. The local reference
+// time is the midpoint of the request so network latency doesn't count as skew.
+func (c *ClockSkewChecker) measure(ctx context.Context) (time.Duration, error) {
+	var lastErr error
+	for _, host := range c.hosts {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, controllerURL(host, "/api/initial-data"), nil)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		start := time.Now()
+		resp, err := c.client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp.Body.Close()
+		local := start.Add(time.Since(start) / 2)
+
+		date := resp.Header.Get("Date")
+		if date == "" {
+			lastErr = fmt.Errorf("controller %s did not send a Date header", host)
+			continue
+		}
+		remote, err := http.ParseTime(date)
+		if err != nil {
+			lastErr = fmt.Errorf("invalid Date header from controller %s: %w", host, err)
+			continue
+		}
+		return remote.Sub(local.Truncate(time.Second)), nil
+	}
+	return 0, fmt.Errorf("failed to read controller time: %w", lastErr)
+}
+
+// absDuration returns the absolute value of a duration
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
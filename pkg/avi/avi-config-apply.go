@@ -0,0 +1,230 @@
+package avi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Configuration apply outcomes
+const (
+	ApplyCreated = "created"
+	ApplyUpdated = "updated"
+	ApplyValid   = "valid" // dry run: the object passed validation
+	ApplyInvalid = "invalid"
+	ApplyFailed  = "failed"
+)
+
+// applyOrder lists the object types a configuration apply accepts, in dependency order so
+// referenced objects exist before the objects that reference them
+var applyOrder = []string{
+	"tenant",
+	"vrfcontext",
+	"healthmonitor",
+	"applicationprofile",
+	"applicationpersistenceprofile",
+	"networkprofile",
+	"sslprofile",
+	"pkiprofile",
+	"sslkeyandcertificate",
+	"wafpolicy",
+	"httppolicyset",
+	"stringgroup",
+	"ipaddrgroup",
+	"pool",
+	"poolgroup",
+	"vsvip",
+	"virtualservice",
+}
+
+// readOnlyFields are server-assigned fields dropped from imported objects
+var readOnlyFields = []string{"uuid", "url", "_last_modified"}
+
+// ConfigObject is a single object of a configuration to apply
+type ConfigObject struct {
+	Type string                 `json:"type"`
+	Name string                 `json:"name"`
+	Data map[string]interface{} `json:"data"`
+}
+
+// ApplyResult is the outcome of applying one object
+type ApplyResult struct {
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	UUID   string `json:"uuid,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ApplyReport is the per-object outcome of a configuration apply
+type ApplyReport struct {
+	DryRun    bool          `json:"dry_run"`
+	Total     int           `json:"total"`
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Results   []ApplyResult `json:"results"`
+}
+
+// ParseConfiguration reads the objects of a configuration in export layout: a map of object type
+// (model name such as "Pool" or API name such as "pool") to a list of objects. A partial set with
+// only some types is accepted; the META section of a full export is ignored.
+func ParseConfiguration(configuration interface{}) ([]ConfigObject, error) {
+	data, ok := configuration.(map[string]interface{})
+	if !ok {
+		raw, err := json.Marshal(configuration)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal configuration: %w", err)
+		}
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return nil, fmt.Errorf("configuration must be a JSON object of object type to object list: %w", err)
+		}
+	}
+
+	types := make([]string, 0, len(data))
+	for key := range data {
+		if key != "META" {
+			types = append(types, key)
+		}
+	}
+	sort.Strings(types)
+
+	var objects []ConfigObject
+	for _, key := range types {
+		objType := strings.ToLower(key)
+		list, ok := data[key].([]interface{})
+		if !ok {
+			if single, isObject := data[key].(map[string]interface{}); isObject {
+				list = []interface{}{single}
+			} else {
+				return nil, fmt.Errorf("%s must be a list of objects", key)
+			}
+		}
+		for _, item := range list {
+			obj, _ := item.(map[string]interface{})
+			name, _ := obj["name"].(string)
+			objects = append(objects, ConfigObject{Type: objType, Name: name, Data: obj})
+		}
+	}
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("configuration contains no objects")
+	}
+	sortObjects(objects)
+	return objects, nil
+}
+
+// sortObjects puts objects in dependency order, the types applyOrder doesn't know last
+func sortObjects(objects []ConfigObject) {
+	rank := make(map[string]int, len(applyOrder))
+	for i, objType := range applyOrder {
+		rank[objType] = i
+	}
+	sort.SliceStable(objects, func(i, j int) bool {
+		ri, okI := rank[objects[i].Type]
+		rj, okJ := rank[objects[j].Type]
+		if !okI || !okJ {
+			return okI && !okJ
+		}
+		return ri < rj
+	})
+}
+
+// validateConfigObject checks that an object can be applied
+func validateConfigObject(obj ConfigObject) error {
+	if obj.Data == nil {
+		return fmt.Errorf("not a JSON object")
+	}
+	supported := false
+	for _, objType := range applyOrder {
+		if objType == obj.Type {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return fmt.Errorf("unsupported object type %s", obj.Type)
+	}
+	if obj.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	return nil
+}
+
+// ApplyProgress is told the outcome of each object as soon as it is applied
+type ApplyProgress func(index int, result ApplyResult)
+
+// ApplyConfiguration creates or updates each object by name and reports the outcome per object.
+// Objects that fail validation are skipped and a failure doesn't stop the remaining objects. With
+// dryRun set the objects are only validated. progress, when not nil, is called after each object.
+func ApplyConfiguration(ctx context.Context, exec GenericExecutor, objects []ConfigObject, dryRun bool, progress ApplyProgress) *ApplyReport {
+	report := &ApplyReport{DryRun: dryRun, Total: len(objects), Results: []ApplyResult{}}
+
+	for i, obj := range objects {
+		result := ApplyResult{Type: obj.Type, Name: obj.Name}
+		if err := validateConfigObject(obj); err != nil {
+			result.Status = ApplyInvalid
+			result.Error = err.Error()
+		} else if dryRun {
+			result.Status = ApplyValid
+		} else {
+			result.UUID, result.Status, err = applyConfigObject(ctx, exec, obj)
+			if err != nil {
+				result.Status = ApplyFailed
+				result.Error = err.Error()
+			}
+		}
+
+		if result.Status == ApplyInvalid || result.Status == ApplyFailed {
+			report.Failed++
+		} else {
+			report.Succeeded++
+		}
+		report.Results = append(report.Results, result)
+		if progress != nil {
+			progress(i, result)
+		}
+	}
+	return report
+}
+
+// applyConfigObject updates the object with the same name, or creates it when there is none
+func applyConfigObject(ctx context.Context, exec GenericExecutor, obj ConfigObject) (string, string, error) {
+	body := make(map[string]interface{}, len(obj.Data))
+	for k, v := range obj.Data {
+		body[k] = v
+	}
+	for _, field := range readOnlyFields {
+		delete(body, field)
+	}
+
+	endpoint := "/" + obj.Type
+	raw, err := exec.ExecuteGenericOperation(ctx, "GET", endpoint, nil, map[string]string{"name": obj.Name})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to look up existing object: %w", err)
+	}
+	collection, _ := raw.(map[string]interface{})
+	results, _ := collection["results"].([]interface{})
+	for _, item := range results {
+		existing, _ := item.(map[string]interface{})
+		if name, _ := existing["name"].(string); name != obj.Name {
+			continue
+		}
+		uuid, _ := existing["uuid"].(string)
+		body["uuid"] = uuid
+		if _, err := exec.ExecuteGenericOperation(ctx, "PUT", endpoint+"/"+uuid, body, nil); err != nil {
+			return uuid, "", err
+		}
+		return uuid, ApplyUpdated, nil
+	}
+
+	created, err := exec.ExecuteGenericOperation(ctx, "POST", endpoint, body, nil)
+	if err != nil {
+		return "", "", err
+	}
+	uuid := ""
+	if m, ok := created.(map[string]interface{}); ok {
+		uuid, _ = m["uuid"].(string)
+	}
+	return uuid, ApplyCreated, nil
+}
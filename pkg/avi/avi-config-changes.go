@@ -0,0 +1,187 @@
+package avi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Event IDs of the controller's configuration audit trail
+const (
+	eventConfigCreate = "CONFIG_CREATE"
+	eventConfigUpdate = "CONFIG_UPDATE"
+	eventConfigDelete = "CONFIG_DELETE"
+)
+
+// maxDigestFields caps the changed fields listed for one update
+const maxDigestFields = 10
+
+// ignoredDiffFields change on every update and aren't worth reporting
+var ignoredDiffFields = map[string]bool{
+	"_last_modified": true,
+	"url":            true,
+	"uuid":           true,
+}
+
+// ConfigChange is one entry of the controller's configuration audit trail. Fields lists the top
+// level fields an update changed.
+type ConfigChange struct {
+	Time       string   `json:"time"`
+	Action     string   `json:"action"` // create, update or delete
+	ObjectType string   `json:"object_type"`
+	ObjectName string   `json:"object_name"`
+	User       string   `json:"user,omitempty"`
+	Fields     []string `json:"fields,omitempty"`
+}
+
+// ConfigChangeDigest is what changed on the controller over a period: the changes in time order
+// and how many each user made
+type ConfigChangeDigest struct {
+	Period  string         `json:"period"`
+	Start   string         `json:"start"`
+	End     string         `json:"end"`
+	Changes []ConfigChange `json:"changes"`
+	Objects int            `json:"objects"` // distinct objects changed
+	ByUser  map[string]int `json:"by_user"`
+	ByType  map[string]int `json:"by_type"`
+}
+
+// GetConfigChanges reads the controller's configuration audit trail over the period ending now
+// (a time range such as 24h or 7d) and diffs the old and new data of every update
+func GetConfigChanges(ctx context.Context, exec GenericExecutor, period string, now time.Time) (*ConfigChangeDigest, error) {
+	if period == "" {
+		period = "24h"
+	}
+	length, err := parseTimeRange(period)
+	if err != nil {
+		return nil, err
+	}
+	end := now.UTC()
+	start := end.Add(-length)
+
+	params := map[string]string{
+		"type":   "2", // event logs
+		"filter": fmt.Sprintf("eq(event_id,[%s,%s,%s])", eventConfigCreate, eventConfigUpdate, eventConfigDelete),
+		"start":  start.Format(time.RFC3339),
+		"end":    end.Format(time.RFC3339),
+	}
+	logs, err := listAllObjects(ctx, exec, "/analytics/logs", params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the configuration audit trail: %w", err)
+	}
+
+	digest := &ConfigChangeDigest{
+		Period:  period,
+		Start:   start.Format(time.RFC3339),
+		End:     end.Format(time.RFC3339),
+		Changes: []ConfigChange{},
+		ByUser:  map[string]int{},
+		ByType:  map[string]int{},
+	}
+	type timedChange struct {
+		ConfigChange
+		at time.Time
+	}
+	var changes []timedChange
+	objects := make(map[string]bool)
+	for _, log := range logs {
+		change, at, ok := parseConfigEvent(log)
+		if !ok || at.Before(start) || at.After(end) {
+			continue
+		}
+		changes = append(changes, timedChange{change, at})
+		objects[change.ObjectType+"/"+change.ObjectName] = true
+		digest.ByUser[valueOrUnknown(change.User)]++
+		digest.ByType[change.ObjectType]++
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].at.Before(changes[j].at) })
+	for _, change := range changes {
+		digest.Changes = append(digest.Changes, change.ConfigChange)
+	}
+	digest.Objects = len(objects)
+	return digest, nil
+}
+
+// parseConfigEvent reads a CONFIG_CREATE, CONFIG_UPDATE or CONFIG_DELETE event log
+func parseConfigEvent(log map[string]interface{}) (ConfigChange, time.Time, bool) {
+	id, _ := log["event_id"].(string)
+	reported, _ := log["report_timestamp"].(string)
+	at, err := parseAviTime(reported)
+	if err != nil {
+		return ConfigChange{}, at, false
+	}
+	change := ConfigChange{Time: at.UTC().Format(time.RFC3339)}
+	switch id {
+	case eventConfigCreate:
+		change.Action = "create"
+	case eventConfigUpdate:
+		change.Action = "update"
+	case eventConfigDelete:
+		change.Action = "delete"
+	default:
+		return ConfigChange{}, at, false
+	}
+
+	// The details are under config_create_details, config_update_details or config_delete_details
+	var details map[string]interface{}
+	if eventDetails, ok := log["event_details"].(map[string]interface{}); ok {
+		for key, value := range eventDetails {
+			if d, ok := value.(map[string]interface{}); ok && strings.HasPrefix(key, "config_") {
+				details = d
+				break
+			}
+		}
+	}
+	change.ObjectType, _ = log["obj_type"].(string)
+	if resourceType, ok := details["resource_type"].(string); ok && resourceType != "" {
+		change.ObjectType = resourceType
+	}
+	change.ObjectType = strings.ToLower(change.ObjectType)
+	change.ObjectName, _ = log["obj_name"].(string)
+	if name, ok := details["resource_name"].(string); ok && name != "" {
+		change.ObjectName = name
+	}
+	change.User, _ = details["user"].(string)
+	if change.Action == "update" {
+		oldData, _ := details["old_resource_data"].(string)
+		newData, _ := details["new_resource_data"].(string)
+		change.Fields = changedFields(oldData, newData)
+	}
+	return change, at, true
+}
+
+// changedFields returns the top level fields that differ between two JSON objects, sorted
+func changedFields(oldData, newData string) []string {
+	var before, after map[string]interface{}
+	if json.Unmarshal([]byte(oldData), &before) != nil || json.Unmarshal([]byte(newData), &after) != nil {
+		return nil
+	}
+	var fields []string
+	for key, value := range after {
+		if !ignoredDiffFields[key] && !reflect.DeepEqual(before[key], value) {
+			fields = append(fields, key)
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok && !ignoredDiffFields[key] {
+			fields = append(fields, key)
+		}
+	}
+	sort.Strings(fields)
+	if len(fields) > maxDigestFields {
+		fields = append(fields[:maxDigestFields], fmt.Sprintf("%d more", len(fields)-maxDigestFields))
+	}
+	return fields
+}
+
+// valueOrUnknown names changes whose user the audit trail doesn't record
+func valueOrUnknown(user string) string {
+	if user == "" {
+		return "unknown"
+	}
+	return user
+}
@@ -0,0 +1,147 @@
+package avi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// ErrObjectNotFound is returned by CollectObjects for an object the controller doesn't have
+var ErrObjectNotFound = errors.New("object not found")
+
+// maxCollectedObjects bounds the objects read by CollectObjects, references included
+const maxCollectedObjects = 200
+
+// unfollowedTypes are referenced types CollectObjects leaves to the environment: the tenant,
+// cloud and routing context an application runs in rather than objects it owns
+var unfollowedTypes = map[string]bool{"tenant": true, "cloud": true, "vrfcontext": true, "serviceenginegroup": true}
+
+// ObjectRef names an object of the controller by type and name
+type ObjectRef struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+// ParseRef returns the object a reference field names: a reference read with include_name,
+// https://controller/api/pool/pool-1#web, or one written by name, /api/pool?name=web as in
+// exports. A reference by UUID alone has no name to return.
+func ParseRef(ref string) (ObjectRef, bool) {
+	link, name, named := strings.Cut(ref, "#")
+	link, query, _ := strings.Cut(link, "?")
+	if !named {
+		values, err := url.ParseQuery(query)
+		if err != nil || values.Get("name") == "" {
+			return ObjectRef{}, false
+		}
+		name = values.Get("name")
+	}
+	_, objPath, found := strings.Cut(link, "/api/")
+	if !found || name == "" {
+		return ObjectRef{}, false
+	}
+	objType, _, _ := strings.Cut(strings.Trim(objPath, "/"), "/")
+	if objType == "" {
+		return ObjectRef{}, false
+	}
+	return ObjectRef{Type: objType, Name: name}, true
+}
+
+// IsRefField reports whether a field of an object refers to other objects
+func IsRefField(field string) bool {
+	return strings.HasSuffix(field, "_ref") || strings.HasSuffix(field, "_refs")
+}
+
+// CollectObjects reads objects from the controller by type and name, with their references
+// named, and returns them in dependency order. With follow set, the objects they refer to are
+// read too, and the objects those refer to, except the controller's System- defaults and the
+// tenant, cloud, VRF and SE group they run in.
+func CollectObjects(ctx context.Context, exec GenericExecutor, refs []ObjectRef, follow bool) ([]ConfigObject, error) {
+	var objects []ConfigObject
+	requested := make(map[ObjectRef]bool, len(refs))
+	queue := make([]ObjectRef, len(refs))
+	for i, ref := range refs {
+		queue[i] = ObjectRef{Type: strings.ToLower(ref.Type), Name: ref.Name}
+		requested[queue[i]] = true
+	}
+	seen := make(map[ObjectRef]bool)
+	for len(queue) > 0 {
+		ref := queue[0]
+		queue = queue[1:]
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		if len(objects) == maxCollectedObjects {
+			return nil, fmt.Errorf("more than %d objects to read; name fewer", maxCollectedObjects)
+		}
+
+		// Objects asked for must exist; references that don't are left as references
+		obj, found, err := readObject(ctx, exec, ref)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			if requested[ref] {
+				return nil, fmt.Errorf("%s %s: %w", ref.Type, ref.Name, ErrObjectNotFound)
+			}
+			continue
+		}
+		objects = append(objects, obj)
+		if follow {
+			queue = append(queue, followedRefs(obj.Data)...)
+		}
+	}
+	sortObjects(objects)
+	return objects, nil
+}
+
+// readObject reads an object by type and name
+func readObject(ctx context.Context, exec GenericExecutor, ref ObjectRef) (ConfigObject, bool, error) {
+	raw, err := exec.ExecuteGenericOperation(ctx, "GET", "/"+ref.Type, nil, map[string]string{"name": ref.Name, "include_name": "true"})
+	if err != nil {
+		return ConfigObject{}, false, fmt.Errorf("failed to read %s %s: %w", ref.Type, ref.Name, err)
+	}
+	collection, _ := raw.(map[string]interface{})
+	results, _ := collection["results"].([]interface{})
+	for _, item := range results {
+		if data, _ := item.(map[string]interface{}); data["name"] == ref.Name {
+			return ConfigObject{Type: ref.Type, Name: ref.Name, Data: data}, true, nil
+		}
+	}
+	return ConfigObject{}, false, nil
+}
+
+// followedRefs returns the objects a value refers to that CollectObjects reads too, in order
+func followedRefs(value interface{}) []ObjectRef {
+	var refs []ObjectRef
+	var walk func(field string, value interface{})
+	walk = func(field string, value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				walk(key, v[key])
+			}
+		case []interface{}:
+			for _, item := range v {
+				walk(field, item)
+			}
+		case string:
+			if !IsRefField(field) {
+				return
+			}
+			if ref, ok := ParseRef(v); ok && !unfollowedTypes[ref.Type] && !strings.HasPrefix(ref.Name, "System-") {
+				refs = append(refs, ref)
+			}
+		}
+	}
+	walk("", value)
+	return refs
+}
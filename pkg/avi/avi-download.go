@@ -0,0 +1,100 @@
+package avi
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ProxyPrefix is the path of the web server's Avi API proxy, which streams file downloads
+const ProxyPrefix = "/api/v1/avi"
+
+// Download is a file returned by the controller, such as a tech-support bundle, a configuration
+// export or a packet capture. The body is streamed from the controller; the caller closes it.
+type Download struct {
+	Body          io.ReadCloser
+	Filename      string
+	ContentType   string
+	ContentLength int64 // -1 when the controller doesn't say
+}
+
+// FileResult stands in for a file returned by a tool: the file isn't decoded or passed to the
+// model, the operator downloads it through the API proxy instead
+type FileResult struct {
+	Endpoint    string `json:"endpoint"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size,omitempty"`
+	DownloadURL string `json:"download_url"`
+}
+
+// IsFile reports whether a response content type is a file rather than an API answer: JSON and
+// text are API answers, anything else (archives, pcaps, octet streams) is a file
+func IsFile(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "" {
+		return false
+	}
+	return mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") &&
+		!strings.HasPrefix(mediaType, "text/")
+}
+
+// newDownload wraps a successful controller response
+func newDownload(resp *http.Response, endpoint string, params map[string]string) *Download {
+	return &Download{
+		Body:          resp.Body,
+		Filename:      downloadFilename(resp.Header, endpoint, params),
+		ContentType:   resp.Header.Get("Content-Type"),
+		ContentLength: resp.ContentLength,
+	}
+}
+
+// Result describes the download for a tool result, with the proxy URL it can be fetched from
+func (d *Download) Result(endpoint string, params map[string]string) *FileResult {
+	return &FileResult{
+		Endpoint:    endpoint,
+		Filename:    d.Filename,
+		ContentType: d.ContentType,
+		Size:        max(d.ContentLength, 0),
+		DownloadURL: ProxyDownloadURL(endpoint, params),
+	}
+}
+
+// ProxyDownloadURL is the API proxy URL that downloads an endpoint as a file
+func ProxyDownloadURL(endpoint string, params map[string]string) string {
+	values := url.Values{}
+	for key, value := range params {
+		values.Set(key, value)
+	}
+	values.Set("download", "true")
+	return ProxyPrefix + endpoint + "?" + values.Encode()
+}
+
+// downloadFilename takes the file name from Content-Disposition, or from the endpoint: the uri
+// parameter of fileservice paths (controller://tech_support/bundle.tar.gz) or the last segment
+func downloadFilename(header http.Header, endpoint string, params map[string]string) string {
+	if _, disposition, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil {
+		if name := baseName(disposition["filename"]); name != "" {
+			return name
+		}
+	}
+	if name := baseName(params["uri"]); name != "" {
+		return name
+	}
+	if name := baseName(endpoint); name != "" {
+		return name
+	}
+	return "download"
+}
+
+// baseName is the last path element, empty when there is none
+func baseName(p string) string {
+	name := path.Base(strings.ReplaceAll(p, "\\", "/"))
+	if name == "/" || name == "." || name == ".." {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,71 @@
+package avi
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+// NormalizeEndpoint cleans the endpoint of a generic operation, as written by the model or passed
+// to the API proxy: it adds the leading slash, drops a repeated /api prefix (the clients add it)
+// and moves a query string into the parameters, where explicitly passed parameters win.
+// Endpoints that would leave the API, such as absolute URLs or ".." segments, are rejected.
+func NormalizeEndpoint(endpoint string, params map[string]string) (string, map[string]string, error) {
+	endpoint = strings.TrimSpace(endpoint)
+	if endpoint == "" {
+		return "", nil, fmt.Errorf("endpoint is required")
+	}
+	if strings.IndexFunc(endpoint, unicode.IsControl) >= 0 {
+		return "", nil, fmt.Errorf("endpoint %q contains control characters", endpoint)
+	}
+	if strings.Contains(endpoint, "://") {
+		return "", nil, fmt.Errorf("endpoint %q must be a path relative to /api, not a URL", endpoint)
+	}
+
+	path, query, hasQuery := strings.Cut(endpoint, "?")
+	path, _, _ = strings.Cut(path, "#")
+	path = strings.TrimSpace(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	for path == "/api" || strings.HasPrefix(path, "/api/") {
+		path = strings.TrimPrefix(path, "/api")
+		if path == "" {
+			path = "/"
+		}
+	}
+	for _, segment := range strings.Split(path, "/") {
+		decoded, err := url.PathUnescape(segment)
+		if err != nil {
+			return "", nil, fmt.Errorf("endpoint %q has an invalid escape: %w", endpoint, err)
+		}
+		if decoded == ".." || strings.ContainsAny(decoded, "/\\") {
+			return "", nil, fmt.Errorf("endpoint %q must not leave /api", endpoint)
+		}
+	}
+
+	if !hasQuery {
+		return path, params, nil
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return "", nil, fmt.Errorf("endpoint %q has an invalid query: %w", endpoint, err)
+	}
+	merged := make(map[string]string, len(values)+len(params))
+	for key, value := range values {
+		if len(value) > 0 {
+			merged[key] = value[0]
+		}
+	}
+	for key, value := range params {
+		merged[key] = value
+	}
+	return path, merged, nil
+}
+
+// IsAnalyticsEndpoint reports whether a normalized endpoint reads metrics, logs or health scores,
+// the queries routed to avi.analytics_host
+func IsAnalyticsEndpoint(endpoint string) bool {
+	return endpoint == "/analytics" || strings.HasPrefix(endpoint, "/analytics/")
+}
@@ -0,0 +1,163 @@
+package avi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/vmware/alb-sdk/go/session"
+)
+
+// Kinds of controller errors that have an explanation
+const (
+	ErrorDuplicateName    = "duplicate_name"
+	ErrorMissingRef       = "missing_reference"
+	ErrorPermissionDenied = "permission_denied"
+	ErrorNotFound         = "not_found"
+	ErrorInvalidField     = "invalid_field"
+	ErrorController       = "controller_error" // any other error the controller answered
+)
+
+var (
+	duplicateMessage  = regexp.MustCompile(`(?i)already exist`)
+	permissionMessage = regexp.MustCompile(`(?i)permission|not authorized|unauthorized|access denied`)
+	missingMessage    = regexp.MustCompile(`(?i)cannot find|could not find|not found|does not exist|doesn't exist|no such`)
+	refMessage        = regexp.MustCompile(`(?i)\b(\w+_refs?)\b|cannot find object|could not find object`)
+	fieldMessage      = regexp.MustCompile(`(?i)\bfield '?(\w+)'?|\b(\w+_refs?)\b`)
+)
+
+// messageKeys are the keys of an error body that hold its message rather than name a field
+var messageKeys = []string{"error", "detail", "message"}
+
+// AviError is an error the controller answered, with its message and the field it names parsed
+// from the body, and the kind of error it is when it is a common one
+type AviError struct {
+	Status  int    `json:"status"`          // HTTP status of the answer
+	Code    string `json:"code"`            // kind of error, ErrorDuplicateName and so on
+	Message string `json:"message"`         // the controller's message
+	Field   string `json:"field,omitempty"` // field the message is about
+}
+
+func (e *AviError) Error() string {
+	return fmt.Sprintf("request failed with status %d: %s", e.Status, e.Message)
+}
+
+// Explanation describes the error for the operator and what to do about it, or returns an empty
+// string for errors that aren't common enough to have one
+func (e *AviError) Explanation() string {
+	switch e.Code {
+	case ErrorDuplicateName:
+		return "An object of this type with that name already exists in the tenant: choose another name, or update the existing object instead of creating one."
+	case ErrorMissingRef:
+		if e.Field != "" {
+			return fmt.Sprintf("%s refers to an object the controller doesn't have: check its name, or create it first.", e.Field)
+		}
+		return "It refers to an object the controller doesn't have: check its name, or create it first."
+	case ErrorPermissionDenied:
+		return "The controller refused the agent's Avi account: its role lacks the permission, or the object is in a tenant it can't access. An Avi administrator can grant it."
+	case ErrorNotFound:
+		return "The object doesn't exist, or was deleted or renamed: list the objects to find its current name."
+	case ErrorInvalidField:
+		if e.Field != "" {
+			return fmt.Sprintf("The controller rejected the value of %s: check it against the values the field allows.", e.Field)
+		}
+		return "The controller rejected a value of the object: check the fields against the values they allow."
+	}
+	return ""
+}
+
+// ParseError returns the error of a controller answer from its status and body. The body is
+// usually {"error": "..."}; validation errors may name the field instead, {"servers": ["..."]}.
+func ParseError(status int, body []byte) *AviError {
+	e := &AviError{Status: status, Message: strings.TrimSpace(string(body))}
+	var fields map[string]interface{}
+	if json.Unmarshal(body, &fields) == nil {
+		e.readFields(fields)
+	}
+	e.classify()
+	return e
+}
+
+// AsAviError finds the controller error in err's chain: an *AviError, or an error of the SDK
+// session, whose message is the body printed as a Go map
+func AsAviError(err error) (*AviError, bool) {
+	var aviErr *AviError
+	if errors.As(err, &aviErr) {
+		return aviErr, true
+	}
+	var sdkErr session.AviError
+	if !errors.As(err, &sdkErr) || sdkErr.HttpStatusCode == 0 {
+		return nil, false
+	}
+	e := &AviError{Status: sdkErr.HttpStatusCode}
+	if sdkErr.Message != nil {
+		e.Message = *sdkErr.Message
+		if inner, ok := strings.CutPrefix(e.Message, "map["); ok {
+			// Only a body of one key can be split unambiguously, which errors usually are
+			key, value, _ := strings.Cut(strings.TrimSuffix(inner, "]"), ":")
+			e.readFields(map[string]interface{}{key: strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")})
+		}
+	}
+	e.classify()
+	return e, true
+}
+
+// readFields reads the message and the field it is about from a decoded error body
+func (e *AviError) readFields(fields map[string]interface{}) {
+	for _, key := range messageKeys {
+		if text, ok := fields[key].(string); ok && text != "" {
+			e.Message = text
+			return
+		}
+	}
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var texts []string
+		switch v := fields[key].(type) {
+		case string:
+			texts = []string{v}
+		case []interface{}:
+			for _, item := range v {
+				if text, ok := item.(string); ok {
+					texts = append(texts, text)
+				}
+			}
+		}
+		if len(texts) > 0 {
+			e.Field = key
+			e.Message = key + ": " + strings.Join(texts, "; ")
+			return
+		}
+	}
+}
+
+// classify sets the kind of error from the status and the message, and the field the message
+// names when the body didn't
+func (e *AviError) classify() {
+	if e.Field == "" {
+		if match := fieldMessage.FindStringSubmatch(e.Message); match != nil {
+			e.Field = match[1] + match[2]
+		}
+	}
+	switch {
+	case e.Status == 401 || e.Status == 403 || permissionMessage.MatchString(e.Message):
+		e.Code = ErrorPermissionDenied
+	case e.Status == 409 || duplicateMessage.MatchString(e.Message):
+		e.Code = ErrorDuplicateName
+	case missingMessage.MatchString(e.Message) && refMessage.MatchString(e.Message):
+		e.Code = ErrorMissingRef
+	case e.Status == 404:
+		e.Code = ErrorNotFound
+	case e.Status == 400 && e.Field != "":
+		e.Code = ErrorInvalidField
+	default:
+		e.Code = ErrorController
+	}
+}
@@ -0,0 +1,119 @@
+package avi
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"aviagent/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+// controllerHosts returns the cluster VIP followed by the individual controller node addresses
+func controllerHosts(cfg *config.AviConfig) []string {
+	seen := make(map[string]bool)
+	var hosts []string
+	for _, host := range append([]string{cfg.Host}, cfg.Nodes...) {
+		if host == "" || seen[host] {
+			continue
+		}
+		seen[host] = true
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+// failoverTransport sends requests to the active controller address and moves on to the next
+// configured address when it stops answering. A session that isn't valid on the new node is
+// answered with 401, which makes the caller log in again against the surviving node.
+type failoverTransport struct {
+	base   http.RoundTripper
+	hosts  []string
+	logger *zap.Logger
+
+	mu     sync.Mutex
+	active int
+}
+
+// newFailoverTransport creates a failover transport over the given controller addresses
+func newFailoverTransport(base http.RoundTripper, hosts []string, logger *zap.Logger) *failoverTransport {
+	return &failoverTransport{
+		base:   base,
+		hosts:  hosts,
+		logger: logger,
+	}
+}
+
+// ActiveHost returns the controller address requests are currently sent to
+func (t *failoverTransport) ActiveHost() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.hosts[t.active]
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *failoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	start := t.active
+	t.mu.Unlock()
+
+	// Requests whose body can't be replayed only get one attempt
+	attempts := len(t.hosts)
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		attempts = 1
+	}
+
+	for i := 0; i < attempts; i++ {
+		idx := (start + i) % len(t.hosts)
+		host := t.hosts[idx]
+
+		attempt := req.Clone(req.Context())
+		attempt.URL.Host = hostForURL(host)
+		attempt.Host = ""
+		if i > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to replay request body: %w", err)
+			}
+			attempt.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(attempt)
+		failed := err != nil || isControllerUnavailable(resp.StatusCode)
+		if !failed || req.Context().Err() != nil || i == attempts-1 {
+			if !failed && idx != start {
+				t.switchTo(start, idx)
+			}
+			return resp, err
+		}
+
+		if resp != nil {
+			resp.Body.Close()
+		}
+		t.logger.Warn("Avi controller unavailable, trying next address",
+			zap.String("host", host),
+			zap.String("next_host", t.hosts[(idx+1)%len(t.hosts)]),
+			zap.Error(err))
+	}
+
+	return nil, fmt.Errorf("no Avi controller address available")
+}
+
+// switchTo makes idx the active controller unless another request already moved it
+func (t *failoverTransport) switchTo(from, idx int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.active != from {
+		return
+	}
+	t.active = idx
+	t.logger.Warn("Avi controller failover",
+		zap.String("from", t.hosts[from]),
+		zap.String("to", t.hosts[idx]))
+}
+
+// isControllerUnavailable reports whether a status means the node is down or not the cluster leader
+func isControllerUnavailable(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
@@ -0,0 +1,270 @@
+package avi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// HealthSummary is an operator-oriented view of an object's runtime inventory
+type HealthSummary struct {
+	UUID             string   `json:"uuid"`
+	Name             string   `json:"name"`
+	OperState        string   `json:"oper_state,omitempty"`
+	HealthScore      float64  `json:"health_score"`
+	Color            string   `json:"color"`
+	PerformanceScore float64  `json:"performance_score"`
+	ResourcesPenalty float64  `json:"resources_penalty"`
+	AnomalyPenalty   float64  `json:"anomaly_penalty"`
+	SecurityPenalty  float64  `json:"security_penalty"`
+	Reasons          []string `json:"reasons,omitempty"`
+}
+
+// HealthColor maps an Avi health score to the color shown in the Avi UI
+func HealthColor(score float64) string {
+	switch {
+	case score >= 85:
+		return "green"
+	case score >= 65:
+		return "yellow"
+	case score >= 1:
+		return "red"
+	default:
+		return "grey"
+	}
+}
+
+// SummarizeInventory converts an inventory response (single object or collection) into health summaries
+func SummarizeInventory(inventory interface{}) []HealthSummary {
+	data, ok := inventory.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	var summaries []HealthSummary
+	if results, ok := data["results"].([]interface{}); ok {
+		for _, item := range results {
+			if obj, ok := item.(map[string]interface{}); ok {
+				summaries = append(summaries, summarizeInventoryObject(obj))
+			}
+		}
+	} else {
+		summaries = append(summaries, summarizeInventoryObject(data))
+	}
+
+	// Worst health first so degraded objects lead the answer
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return summaries[i].HealthScore < summaries[j].HealthScore
+	})
+
+	return summaries
+}
+
+// summarizeInventoryObject extracts health information from a single inventory object
+func summarizeInventoryObject(obj map[string]interface{}) HealthSummary {
+	summary := HealthSummary{}
+
+	summary.UUID, _ = obj["uuid"].(string)
+	if cfg, ok := obj["config"].(map[string]interface{}); ok {
+		summary.Name, _ = cfg["name"].(string)
+		if summary.UUID == "" {
+			summary.UUID, _ = cfg["uuid"].(string)
+		}
+	}
+
+	if runtime, ok := obj["runtime"].(map[string]interface{}); ok {
+		if oper, ok := runtime["oper_status"].(map[string]interface{}); ok {
+			summary.OperState, _ = oper["state"].(string)
+			summary.Reasons = append(summary.Reasons, stringList(oper["reason"])...)
+		}
+	}
+
+	if hs, ok := obj["health_score"].(map[string]interface{}); ok {
+		summary.HealthScore = numberValue(hs["health_score"])
+		summary.PerformanceScore = numberValue(hs["performance_score"])
+		summary.ResourcesPenalty = numberValue(hs["resources_penalty"])
+		summary.AnomalyPenalty = numberValue(hs["anomaly_penalty"])
+		summary.SecurityPenalty = numberValue(hs["security_penalty"])
+		summary.Reasons = append(summary.Reasons, stringList(hs["reason"])...)
+	}
+
+	// Explain the score drop when the controller didn't provide a reason
+	summary.Reasons = append(summary.Reasons, healthFactors(summary.PerformanceScore, summary.ResourcesPenalty, summary.AnomalyPenalty, summary.SecurityPenalty)...)
+
+	summary.Color = HealthColor(summary.HealthScore)
+	return summary
+}
+
+// healthFactors describes the sub-scores that pull a health score below 100
+func healthFactors(performance, resources, anomaly, security float64) []string {
+	var factors []string
+	if performance > 0 && performance < 100 {
+		factors = append(factors, fmt.Sprintf("performance score reduced to %.0f", performance))
+	}
+	if resources > 0 {
+		factors = append(factors, fmt.Sprintf("resources penalty of %.0f (CPU, memory or bandwidth pressure)", resources))
+	}
+	if anomaly > 0 {
+		factors = append(factors, fmt.Sprintf("anomaly penalty of %.0f (traffic deviates from learned baseline)", anomaly))
+	}
+	if security > 0 {
+		factors = append(factors, fmt.Sprintf("security penalty of %.0f (weak SSL settings or attacks detected)", security))
+	}
+	return factors
+}
+
+// HealthScoreReport explains an object's health score from the analytics healthscore series
+type HealthScoreReport struct {
+	UUID             string   `json:"uuid"`
+	HealthScore      float64  `json:"health_score"`
+	Color            string   `json:"color"`
+	PerformanceScore float64  `json:"performance_score"`
+	ResourcesPenalty float64  `json:"resources_penalty"`
+	AnomalyPenalty   float64  `json:"anomaly_penalty"`
+	SecurityPenalty  float64  `json:"security_penalty"`
+	Timestamp        string   `json:"timestamp,omitempty"`
+	LowestScore      float64  `json:"lowest_score"` // lowest health score within the requested window
+	Samples          int      `json:"samples"`
+	Factors          []string `json:"factors,omitempty"`
+	Explanation      string   `json:"explanation"`
+}
+
+// healthScoreFields lists the report fields, each read from the data point field or the
+// healthscore metric ID of that name
+var healthScoreFields = []string{"health_score", "performance_score", "resources_penalty", "anomaly_penalty", "security_penalty"}
+
+// SummarizeHealthScore converts an /analytics/healthscore response into an operator-friendly report.
+// Sub-scores are read from the latest data point, either as fields of the point or as separate series.
+func SummarizeHealthScore(uuid string, response interface{}) HealthScoreReport {
+	report := HealthScoreReport{UUID: uuid}
+	values := make(map[string]float64)
+	lowest := -1.0
+
+	data, _ := response.(map[string]interface{})
+	series, _ := data["series"].([]interface{})
+	for _, item := range series {
+		s, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		metricID := ""
+		if header, ok := s["header"].(map[string]interface{}); ok {
+			metricID, _ = header["metric_id"].(string)
+		}
+		points, _ := s["data"].([]interface{})
+
+		for i, p := range points {
+			point, ok := p.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			// Track the worst health score across the window
+			score, hasScore := point["health_score"]
+			if !hasScore && strings.HasSuffix(metricID, "health_score_value") {
+				score, hasScore = point["value"], true
+			}
+			if hasScore {
+				report.Samples++
+				if v := numberValue(score); lowest < 0 || v < lowest {
+					lowest = v
+				}
+			}
+
+			if i != len(points)-1 {
+				continue
+			}
+			if ts, ok := point["timestamp"].(string); ok && ts > report.Timestamp {
+				report.Timestamp = ts
+			}
+			for _, field := range healthScoreFields {
+				if v, ok := point[field]; ok {
+					values[field] = numberValue(v)
+				} else if strings.Contains(metricID, field) {
+					values[field] = numberValue(point["value"])
+				}
+			}
+		}
+	}
+
+	report.HealthScore = values["health_score"]
+	report.PerformanceScore = values["performance_score"]
+	report.ResourcesPenalty = values["resources_penalty"]
+	report.AnomalyPenalty = values["anomaly_penalty"]
+	report.SecurityPenalty = values["security_penalty"]
+	report.Color = HealthColor(report.HealthScore)
+	if lowest >= 0 {
+		report.LowestScore = lowest
+	}
+	report.Factors = healthFactors(report.PerformanceScore, report.ResourcesPenalty, report.AnomalyPenalty, report.SecurityPenalty)
+	report.Explanation = explainHealthScore(report)
+
+	return report
+}
+
+// explainHealthScore writes a plain-language explanation of a health score report
+func explainHealthScore(r HealthScoreReport) string {
+	if r.Samples == 0 && r.HealthScore == 0 {
+		return "No health score data is available for this object yet (it may be disabled or have no traffic)."
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Health score is %.0f (%s). Avi computes it as the performance score (%.0f) minus the resources (%.0f), anomaly (%.0f) and security (%.0f) penalties.",
+		r.HealthScore, r.Color, r.PerformanceScore, r.ResourcesPenalty, r.AnomalyPenalty, r.SecurityPenalty)
+
+	if r.PerformanceScore > 0 && r.PerformanceScore < 100 {
+		b.WriteString(" Performance is reduced: clients see higher latency, connection errors or HTTP errors than usual.")
+	}
+	if r.ResourcesPenalty > 0 {
+		b.WriteString(" Resources are constrained: the Service Engines or backend servers are short on CPU, memory, bandwidth or connections.")
+	}
+	if r.AnomalyPenalty > 0 {
+		b.WriteString(" Traffic is anomalous: request rates or response patterns deviate from the learned baseline.")
+	}
+	if r.SecurityPenalty > 0 {
+		b.WriteString(" Security is weakened: TLS settings (protocols, ciphers, certificates) are weak or attacks such as DDoS were detected.")
+	}
+	if len(r.Factors) == 0 {
+		b.WriteString(" No penalties are applied.")
+	}
+	if r.Samples > 1 && r.LowestScore < r.HealthScore {
+		fmt.Fprintf(&b, " The lowest score in the requested window was %.0f.", r.LowestScore)
+	}
+
+	return b.String()
+}
+
+// stringList converts a string or list of strings from JSON into a slice
+func stringList(v interface{}) []string {
+	switch val := v.(type) {
+	case string:
+		if val == "" {
+			return nil
+		}
+		return []string{val}
+	case []interface{}:
+		var out []string
+		for _, item := range val {
+			if str, ok := item.(string); ok && str != "" {
+				out = append(out, str)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+// numberValue converts a JSON number into a float64
+func numberValue(v interface{}) float64 {
+	switch val := v.(type) {
+	case float64:
+		return val
+	case int:
+		return float64(val)
+	case int64:
+		return float64(val)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,211 @@
+package avi
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// incidentMetrics are the virtual service metrics scanned for spikes when explaining its health
+var incidentMetrics = []string{
+	"l4_client.avg_total_rtt",
+	"l7_server.avg_resp_latency",
+	"l7_client.avg_error_responses",
+	"l4_client.avg_complete_conns",
+}
+
+// spikeDeviations is how many standard deviations above the mean a point must be to count as a spike
+const spikeDeviations = 2.0
+
+// TimelineEvent is something that happened to a virtual service or its pools
+type TimelineEvent struct {
+	Time        string `json:"time"`
+	Source      string `json:"source"` // metric, anomaly, pool
+	Description string `json:"description"`
+}
+
+// HealthEvidence is what is known about a virtual service's health over a time window: its
+// health score breakdown, metric summaries, spikes and anomalies, and the state of its pools
+type HealthEvidence struct {
+	UUID      string             `json:"uuid"`
+	Name      string             `json:"name,omitempty"`
+	TimeRange string             `json:"time_range"`
+	Health    *HealthScoreReport `json:"health,omitempty"`
+	Metrics   []MetricSeries     `json:"metrics,omitempty"` // statistics only, points are dropped
+	Pools     []HealthSummary    `json:"pools,omitempty"`
+	Timeline  []TimelineEvent    `json:"timeline"`
+	Warnings  []string           `json:"warnings,omitempty"` // evidence that couldn't be read
+}
+
+// CollectHealthEvidence reads the health score, key metrics, anomalies and pool state of a
+// virtual service and merges the notable events into a timeline. Sources that can't be read
+// are reported as warnings; only a missing virtual service is an error.
+func CollectHealthEvidence(ctx context.Context, exec GenericExecutor, uuid, timeRange string) (*HealthEvidence, error) {
+	if timeRange == "" {
+		timeRange = "1h"
+	}
+	query, err := BuildMetricsQuery("virtualservice", uuid, incidentMetrics, timeRange)
+	if err != nil {
+		return nil, err
+	}
+	vs, err := getObject(ctx, exec, "/virtualservice/"+uuid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read virtual service: %w", err)
+	}
+
+	ev := &HealthEvidence{UUID: uuid, TimeRange: timeRange, Timeline: []TimelineEvent{}}
+	ev.Name, _ = vs["name"].(string)
+	window := map[string]string{"step": strconv.Itoa(query.Step), "limit": strconv.Itoa(query.Limit)}
+
+	if raw, err := exec.ExecuteGenericOperation(ctx, "GET", "/analytics/healthscore/virtualservice/"+uuid, nil, window); err != nil {
+		ev.Warnings = append(ev.Warnings, fmt.Sprintf("health score: %v", err))
+	} else {
+		report := SummarizeHealthScore(uuid, raw)
+		ev.Health = &report
+	}
+
+	if metrics, err := QueryMetrics(ctx, exec, query); err != nil {
+		ev.Warnings = append(ev.Warnings, fmt.Sprintf("metrics: %v", err))
+	} else {
+		for _, series := range metrics.Series {
+			ev.Timeline = append(ev.Timeline, metricSpikes(series)...)
+			series.Points = nil
+			ev.Metrics = append(ev.Metrics, series)
+		}
+	}
+
+	if raw, err := exec.ExecuteGenericOperation(ctx, "GET", "/analytics/anomaly/virtualservice/"+uuid, nil, window); err != nil {
+		ev.Warnings = append(ev.Warnings, fmt.Sprintf("anomalies: %v", err))
+	} else {
+		ev.Timeline = append(ev.Timeline, anomalyEvents(raw)...)
+	}
+
+	for _, poolUUID := range vsPoolUUIDs(vs) {
+		if raw, err := exec.ExecuteGenericOperation(ctx, "GET", "/pool-inventory/"+poolUUID, nil, map[string]string{"include_name": "true"}); err != nil {
+			ev.Warnings = append(ev.Warnings, fmt.Sprintf("pool %s health: %v", poolUUID, err))
+		} else {
+			ev.Pools = append(ev.Pools, SummarizeInventory(raw)...)
+		}
+		if raw, err := exec.ExecuteGenericOperation(ctx, "GET", "/pool/"+poolUUID+"/runtime/server", nil, nil); err != nil {
+			ev.Warnings = append(ev.Warnings, fmt.Sprintf("pool %s servers: %v", poolUUID, err))
+		} else {
+			ev.Timeline = append(ev.Timeline, serverStateEvents(raw)...)
+		}
+	}
+
+	sort.SliceStable(ev.Timeline, func(i, j int) bool {
+		return ev.Timeline[i].Time < ev.Timeline[j].Time
+	})
+	return ev, nil
+}
+
+// vsPoolUUIDs returns the pools referenced by a virtual service
+func vsPoolUUIDs(vs map[string]interface{}) []string {
+	var pools []string
+	if ref, ok := vs["pool_ref"].(string); ok && ref != "" {
+		pools = append(pools, refUUID(ref))
+	}
+	return pools
+}
+
+// metricSpikes returns the points of a series that are well above its mean
+func metricSpikes(series MetricSeries) []TimelineEvent {
+	if len(series.Points) < 3 {
+		return nil
+	}
+	var mean, variance float64
+	for _, p := range series.Points {
+		mean += p.Value
+	}
+	mean /= float64(len(series.Points))
+	for _, p := range series.Points {
+		variance += (p.Value - mean) * (p.Value - mean)
+	}
+	stddev := math.Sqrt(variance / float64(len(series.Points)))
+	if stddev == 0 {
+		return nil
+	}
+
+	var events []TimelineEvent
+	for _, p := range series.Points {
+		if p.Value > mean+spikeDeviations*stddev {
+			events = append(events, TimelineEvent{
+				Time:        p.Timestamp,
+				Source:      "metric",
+				Description: fmt.Sprintf("%s spiked to %.1f (window average %.1f)", series.MetricID, p.Value, mean),
+			})
+		}
+	}
+	return events
+}
+
+// anomalyEvents converts an anomaly analytics response into timeline events. Each result carries
+// a timestamp and the metrics that deviated from the learned baseline.
+func anomalyEvents(raw interface{}) []TimelineEvent {
+	response, _ := raw.(map[string]interface{})
+	results, _ := response["results"].([]interface{})
+	var events []TimelineEvent
+	for _, item := range results {
+		anomaly, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		timestamp, _ := anomaly["timestamp"].(string)
+		metrics, _ := anomaly["metrics"].([]interface{})
+		for _, m := range metrics {
+			metric, ok := m.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			id, _ := metric["metric_id"].(string)
+			events = append(events, TimelineEvent{
+				Time:   timestamp,
+				Source: "anomaly",
+				Description: fmt.Sprintf("%s was anomalous: %.1f against an expected %.1f",
+					id, numberValue(metric["current_value"]), numberValue(metric["expected_value"])),
+			})
+		}
+	}
+	return events
+}
+
+// serverStateEvents reports pool servers that aren't up, with the time their state changed
+func serverStateEvents(raw interface{}) []TimelineEvent {
+	var servers []interface{}
+	switch r := raw.(type) {
+	case []interface{}:
+		servers = r
+	case map[string]interface{}:
+		servers, _ = r["results"].([]interface{})
+	}
+
+	var events []TimelineEvent
+	for _, item := range servers {
+		server, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		status, _ := server["oper_status"].(map[string]interface{})
+		state, _ := status["state"].(string)
+		if state == "" || state == "OPER_UP" {
+			continue
+		}
+		event := TimelineEvent{
+			Source:      "pool",
+			Description: fmt.Sprintf("pool member %s:%.0f is %s", addrString(server["server_ip"]), numberValue(server["port"]), state),
+		}
+		if changed, ok := status["last_changed_time"].(map[string]interface{}); ok {
+			if secs := numberValue(changed["secs"]); secs > 0 {
+				event.Time = time.Unix(int64(secs), 0).UTC().Format(time.RFC3339)
+			}
+		}
+		if reasons := stringList(status["reason"]); len(reasons) > 0 {
+			event.Description += " (" + reasons[0] + ")"
+		}
+		events = append(events, event)
+	}
+	return events
+}
@@ -0,0 +1,140 @@
+package avi
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Inventory is a snapshot of the controller objects simulations and entity matching are computed from
+type Inventory struct {
+	Taken           time.Time
+	pools           []map[string]interface{}
+	poolGroups      []map[string]interface{}
+	virtualServices []map[string]interface{} // virtual service inventory items, with config and runtime placement
+	healthMonitors  []map[string]interface{}
+	serviceEngines  []map[string]interface{}
+}
+
+// InventoryMatch is an inventory object a name or address refers to
+type InventoryMatch struct {
+	Type      string `json:"type"` // virtual_service, pool, health_monitor or service_engine
+	Name      string `json:"name"`
+	UUID      string `json:"uuid"`
+	MatchedBy string `json:"matched_by"` // name, vip or server
+	Value     string `json:"value"`      // the name or address that matched
+}
+
+// LoadInventory reads the pools, pool groups, virtual services with their placement, health
+// monitors and service engines
+func LoadInventory(ctx context.Context, exec GenericExecutor) (*Inventory, error) {
+	inv := &Inventory{Taken: time.Now()}
+	for _, list := range []struct {
+		endpoint string
+		objects  *[]map[string]interface{}
+	}{
+		{"/pool", &inv.pools},
+		{"/poolgroup", &inv.poolGroups},
+		{"/virtualservice-inventory", &inv.virtualServices},
+		{"/healthmonitor", &inv.healthMonitors},
+		{"/serviceengine", &inv.serviceEngines},
+	} {
+		objects, err := listAllObjects(ctx, exec, list.endpoint, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", strings.TrimPrefix(list.endpoint, "/"), err)
+		}
+		*list.objects = objects
+	}
+	return inv, nil
+}
+
+// Match finds the inventory objects named in text or in names, the virtual services whose VIP is
+// one of addresses and the pools with one of addresses as a server. Names match case-insensitively,
+// as whole words of the text.
+func (inv *Inventory) Match(text string, names, addresses []string) []InventoryMatch {
+	wanted := map[string]bool{}
+	for _, name := range names {
+		wanted[strings.ToLower(name)] = true
+	}
+	matches := []InventoryMatch{}
+	seen := map[string]bool{}
+	add := func(objType, name, uuid, matchedBy, value string) {
+		key := uuid + "/" + matchedBy + "/" + value
+		if !seen[key] {
+			seen[key] = true
+			matches = append(matches, InventoryMatch{Type: objType, Name: name, UUID: uuid, MatchedBy: matchedBy, Value: value})
+		}
+	}
+	byName := func(objType string, objects []map[string]interface{}) {
+		for _, obj := range objects {
+			name := stringValue(obj["name"])
+			if name != "" && (wanted[strings.ToLower(name)] || containsWord(text, name)) {
+				add(objType, name, stringValue(obj["uuid"]), "name", name)
+			}
+		}
+	}
+
+	vsConfigs := make([]map[string]interface{}, 0, len(inv.virtualServices))
+	for _, item := range inv.virtualServices {
+		config, _ := item["config"].(map[string]interface{})
+		vsConfigs = append(vsConfigs, map[string]interface{}{"name": config["name"], "uuid": valueOrDefault(stringValue(config["uuid"]), stringValue(item["uuid"])), "vip": config["vip"]})
+	}
+	byName("virtual_service", vsConfigs)
+	byName("pool", inv.pools)
+	byName("health_monitor", inv.healthMonitors)
+	byName("service_engine", inv.serviceEngines)
+
+	for _, addr := range addresses {
+		for _, config := range vsConfigs {
+			vips, _ := config["vip"].([]interface{})
+			for _, v := range vips {
+				vip, _ := v.(map[string]interface{})
+				ip, _ := vip["ip_address"].(map[string]interface{})
+				if sameIP(stringValue(ip["addr"]), addr) {
+					add("virtual_service", stringValue(config["name"]), stringValue(config["uuid"]), "vip", addr)
+				}
+			}
+		}
+		for _, pool := range inv.pools {
+			servers, _ := pool["servers"].([]interface{})
+			for _, item := range servers {
+				server, _ := item.(map[string]interface{})
+				if serverMatches(pool, server, addr, 0) {
+					add("pool", stringValue(pool["name"]), stringValue(pool["uuid"]), "server", addr)
+				}
+			}
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Type < matches[j].Type })
+	return matches
+}
+
+// containsWord reports whether text contains word case-insensitively, not as part of a longer name
+func containsWord(text, word string) bool {
+	matched, _ := regexp.MatchString(`(?i)(^|[^\w.-])`+regexp.QuoteMeta(word)+`($|[^\w-]|\.(\s|$))`, text)
+	return matched
+}
+
+// findVirtualService returns the inventory item and configuration of a virtual service by name or UUID
+func (inv *Inventory) findVirtualService(nameOrUUID string) (map[string]interface{}, map[string]interface{}) {
+	for _, item := range inv.virtualServices {
+		config, _ := item["config"].(map[string]interface{})
+		if stringValue(config["name"]) == nameOrUUID || stringValue(config["uuid"]) == nameOrUUID || stringValue(item["uuid"]) == nameOrUUID {
+			return item, config
+		}
+	}
+	return nil, nil
+}
+
+// findInventoryObject returns the object with the given name or UUID
+func findInventoryObject(objects []map[string]interface{}, nameOrUUID string) map[string]interface{} {
+	for _, obj := range objects {
+		if stringValue(obj["name"]) == nameOrUUID || stringValue(obj["uuid"]) == nameOrUUID {
+			return obj
+		}
+	}
+	return nil
+}
@@ -0,0 +1,322 @@
+package avi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Analytics steps (seconds per data point) supported by the metrics API
+const (
+	StepFiveMinutes = 300
+	StepHour        = 3600
+	StepDay         = 86400
+)
+
+// maxMetricPoints caps the points requested per series
+const maxMetricPoints = 288
+
+// MetricInfo describes a metric ID the metrics API accepts
+type MetricInfo struct {
+	ID          string   `json:"id"`
+	Entities    []string `json:"entities"` // resource types the metric is collected for
+	Units       string   `json:"units"`
+	Description string   `json:"description"`
+}
+
+// metricCatalog lists the supported metric IDs. The prefix selects the collection: l4_client and
+// l7_client are measured between clients and the VS, l4_server and l7_server between the SEs and
+// pool servers, se_* on the service engine itself.
+var metricCatalog = []MetricInfo{
+	{"l4_client.avg_bandwidth", []string{"virtualservice"}, "BITS_PER_SECOND", "Average client-side throughput"},
+	{"l4_client.avg_complete_conns", []string{"virtualservice"}, "PER_SECOND", "Completed client connections per second"},
+	{"l4_client.avg_new_established_conns", []string{"virtualservice"}, "PER_SECOND", "New client connections per second"},
+	{"l4_client.max_open_conns", []string{"virtualservice"}, "COUNT", "Peak concurrent client connections"},
+	{"l4_client.avg_total_rtt", []string{"virtualservice"}, "MILLISECONDS", "Average client round trip time"},
+	{"l4_client.avg_errored_connections", []string{"virtualservice"}, "PER_SECOND", "Client connections that ended in an error"},
+	{"l4_client.avg_rx_pkts", []string{"virtualservice"}, "PER_SECOND", "Packets received from clients per second"},
+	{"l4_client.avg_tx_pkts", []string{"virtualservice"}, "PER_SECOND", "Packets sent to clients per second"},
+	{"l7_client.avg_complete_responses", []string{"virtualservice"}, "PER_SECOND", "HTTP responses per second"},
+	{"l7_client.avg_error_responses", []string{"virtualservice"}, "PER_SECOND", "HTTP error responses per second"},
+	{"l7_client.pct_response_errors", []string{"virtualservice"}, "PERCENT", "Percentage of HTTP responses that are errors"},
+	{"l7_client.avg_resp_4xx", []string{"virtualservice"}, "PER_SECOND", "HTTP 4xx responses per second"},
+	{"l7_client.avg_resp_5xx", []string{"virtualservice"}, "PER_SECOND", "HTTP 5xx responses per second"},
+	{"l7_client.avg_page_load_time", []string{"virtualservice"}, "MILLISECONDS", "Average page load time reported by clients"},
+	{"l7_client.avg_ssl_handshakes_new", []string{"virtualservice"}, "PER_SECOND", "New TLS handshakes per second"},
+	{"l4_server.avg_bandwidth", []string{"virtualservice", "pool"}, "BITS_PER_SECOND", "Average server-side throughput"},
+	{"l4_server.avg_open_conns", []string{"virtualservice", "pool"}, "COUNT", "Average open connections to pool servers"},
+	{"l4_server.avg_total_rtt", []string{"virtualservice", "pool"}, "MILLISECONDS", "Average server round trip time"},
+	{"l4_server.avg_errored_connections", []string{"virtualservice", "pool"}, "PER_SECOND", "Server connections that ended in an error"},
+	{"l7_server.avg_resp_latency", []string{"virtualservice", "pool"}, "MILLISECONDS", "Average server response latency"},
+	{"l7_server.avg_application_response_time", []string{"virtualservice", "pool"}, "MILLISECONDS", "Average application response time"},
+	{"l7_server.avg_complete_responses", []string{"virtualservice", "pool"}, "PER_SECOND", "Server HTTP responses per second"},
+	{"l7_server.avg_resp_5xx", []string{"virtualservice", "pool"}, "PER_SECOND", "Server HTTP 5xx responses per second"},
+	{"se_stats.avg_cpu_usage", []string{"serviceengine"}, "PERCENT", "Average service engine CPU usage"},
+	{"se_stats.avg_mem_usage", []string{"serviceengine"}, "PERCENT", "Average service engine memory usage"},
+	{"se_stats.avg_connection_mem_usage", []string{"serviceengine"}, "PERCENT", "Memory used by connections"},
+	{"se_stats.avg_packet_buffer_usage", []string{"serviceengine"}, "PERCENT", "Packet buffer usage"},
+	{"se_stats.avg_bandwidth", []string{"serviceengine"}, "BITS_PER_SECOND", "Average service engine throughput"},
+	{"se_if.avg_bandwidth", []string{"serviceengine"}, "BITS_PER_SECOND", "Average throughput of the data interfaces"},
+	{"healthscore.health_score_value", []string{"virtualservice", "pool", "serviceengine"}, "SCORE", "Health score (0-100)"},
+}
+
+// defaultMetrics are queried when no metric is named
+var defaultMetrics = map[string][]string{
+	"virtualservice": {"l4_client.avg_bandwidth", "l4_client.avg_complete_conns", "l7_client.avg_error_responses"},
+	"pool":           {"l4_server.avg_open_conns", "l7_server.avg_resp_latency"},
+	"serviceengine":  {"se_stats.avg_cpu_usage", "se_stats.avg_mem_usage", "se_if.avg_bandwidth"},
+}
+
+// metricAliases map the informal metric names used in questions to metric IDs
+var metricAliases = map[string]map[string]string{
+	"virtualservice": {
+		"connections": "l4_client.avg_complete_conns",
+		"throughput":  "l4_client.avg_bandwidth",
+		"bandwidth":   "l4_client.avg_bandwidth",
+		"latency":     "l4_client.avg_total_rtt",
+		"errors":      "l7_client.avg_error_responses",
+		"error_rate":  "l7_client.pct_response_errors",
+		"health":      "healthscore.health_score_value",
+	},
+	"pool": {
+		"connections": "l4_server.avg_open_conns",
+		"throughput":  "l4_server.avg_bandwidth",
+		"bandwidth":   "l4_server.avg_bandwidth",
+		"latency":     "l7_server.avg_resp_latency",
+		"errors":      "l4_server.avg_errored_connections",
+	},
+	"serviceengine": {
+		"cpu":        "se_stats.avg_cpu_usage",
+		"memory":     "se_stats.avg_mem_usage",
+		"throughput": "se_if.avg_bandwidth",
+		"bandwidth":  "se_if.avg_bandwidth",
+	},
+}
+
+// MetricCatalog returns the metric IDs supported for a resource type, or all of them when
+// the type is empty
+func MetricCatalog(entity string) []MetricInfo {
+	if entity == "" {
+		return metricCatalog
+	}
+	var metrics []MetricInfo
+	for _, m := range metricCatalog {
+		if m.supports(entity) {
+			metrics = append(metrics, m)
+		}
+	}
+	return metrics
+}
+
+// supports reports whether the metric is collected for the resource type
+func (m MetricInfo) supports(entity string) bool {
+	for _, e := range m.Entities {
+		if e == entity {
+			return true
+		}
+	}
+	return false
+}
+
+// MetricsQuery is a request to /analytics/metrics/{entity}/{uuid}
+type MetricsQuery struct {
+	Entity    string   `json:"entity"`
+	UUID      string   `json:"uuid"`
+	MetricIDs []string `json:"metric_ids"`
+	Step      int      `json:"step"`  // seconds per data point
+	Limit     int      `json:"limit"` // number of data points
+}
+
+// BuildMetricsQuery validates the metrics against the catalog and converts a time range
+// ("30m", "6h", "7d") into a step and number of points. Informal names such as "throughput" are
+// mapped to metric IDs; with no metrics the entity's default set is queried.
+func BuildMetricsQuery(entity, uuid string, metrics []string, timeRange string) (*MetricsQuery, error) {
+	if _, ok := defaultMetrics[entity]; !ok {
+		return nil, fmt.Errorf("unsupported resource type %q (use virtualservice, pool or serviceengine)", entity)
+	}
+	if uuid == "" {
+		return nil, fmt.Errorf("uuid is required")
+	}
+
+	query := &MetricsQuery{Entity: entity, UUID: uuid}
+	if len(metrics) == 0 {
+		query.MetricIDs = append(query.MetricIDs, defaultMetrics[entity]...)
+	}
+	for _, name := range metrics {
+		id := strings.TrimSpace(name)
+		if alias, ok := metricAliases[entity][strings.ToLower(id)]; ok {
+			id = alias
+		}
+		info, ok := lookupMetric(id)
+		if !ok {
+			return nil, fmt.Errorf("unknown metric %q, supported metrics for %s: %s", name, entity, strings.Join(catalogIDs(entity), ", "))
+		}
+		if !info.supports(entity) {
+			return nil, fmt.Errorf("metric %s is not collected for %s", id, entity)
+		}
+		query.MetricIDs = append(query.MetricIDs, id)
+	}
+
+	window, err := parseTimeRange(timeRange)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case window <= 24*time.Hour:
+		query.Step = StepFiveMinutes
+	case window <= 7*24*time.Hour:
+		query.Step = StepHour
+	default:
+		query.Step = StepDay
+	}
+	query.Limit = int(window / (time.Duration(query.Step) * time.Second))
+	if query.Limit < 1 {
+		query.Limit = 1
+	}
+	if query.Limit > maxMetricPoints {
+		query.Limit = maxMetricPoints
+	}
+	return query, nil
+}
+
+// Endpoint returns the metrics API path of the query
+func (q *MetricsQuery) Endpoint() string {
+	return fmt.Sprintf("/analytics/metrics/%s/%s", q.Entity, q.UUID)
+}
+
+// Params returns the metrics API query parameters
+func (q *MetricsQuery) Params() map[string]string {
+	return map[string]string{
+		"metric_id":        strings.Join(q.MetricIDs, ","),
+		"step":             strconv.Itoa(q.Step),
+		"limit":            strconv.Itoa(q.Limit),
+		"pad_missing_data": "false",
+	}
+}
+
+// lookupMetric finds a metric in the catalog
+func lookupMetric(id string) (MetricInfo, bool) {
+	for _, m := range metricCatalog {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return MetricInfo{}, false
+}
+
+// catalogIDs returns the sorted metric IDs supported for a resource type
+func catalogIDs(entity string) []string {
+	var ids []string
+	for _, m := range MetricCatalog(entity) {
+		ids = append(ids, m.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// parseTimeRange parses a time range such as "30m", "6h" or "7d", defaulting to one hour
+func parseTimeRange(value string) (time.Duration, error) {
+	value = strings.TrimSpace(strings.ToLower(value))
+	if value == "" {
+		return time.Hour, nil
+	}
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil || days <= 0 {
+			return 0, fmt.Errorf("invalid time range %q (use e.g. 30m, 6h or 7d)", value)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid time range %q (use e.g. 30m, 6h or 7d)", value)
+	}
+	return d, nil
+}
+
+// MetricPoint is a single data point of a series
+type MetricPoint struct {
+	Timestamp string  `json:"timestamp"`
+	Value     float64 `json:"value"`
+}
+
+// MetricSeries is a metric's statistics and data points over the queried window
+type MetricSeries struct {
+	MetricID string        `json:"metric_id"`
+	Units    string        `json:"units,omitempty"`
+	Min      float64       `json:"min"`
+	Max      float64       `json:"max"`
+	Mean     float64       `json:"mean"`
+	Latest   float64       `json:"latest"`
+	Points   []MetricPoint `json:"points"`
+}
+
+// MetricsReport is the result of a metrics query
+type MetricsReport struct {
+	Query  *MetricsQuery  `json:"query"`
+	Series []MetricSeries `json:"series"`
+}
+
+// QueryMetrics runs a metrics query and summarizes each returned series
+func QueryMetrics(ctx context.Context, exec GenericExecutor, query *MetricsQuery) (*MetricsReport, error) {
+	raw, err := exec.ExecuteGenericOperation(ctx, "GET", query.Endpoint(), nil, query.Params())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metrics: %w", err)
+	}
+	return &MetricsReport{Query: query, Series: SummarizeMetrics(raw)}, nil
+}
+
+// SummarizeMetrics converts a metrics API response into series with min, max, mean and latest
+// values. Statistics the controller includes in the series header are preferred.
+func SummarizeMetrics(raw interface{}) []MetricSeries {
+	response, _ := raw.(map[string]interface{})
+	items, _ := response["series"].([]interface{})
+	series := make([]MetricSeries, 0, len(items))
+	for _, item := range items {
+		s, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		out := MetricSeries{Points: []MetricPoint{}}
+		header, _ := s["header"].(map[string]interface{})
+		out.MetricID, _ = header["name"].(string)
+		if out.MetricID == "" {
+			out.MetricID, _ = header["metric_id"].(string)
+		}
+		out.Units, _ = header["units"].(string)
+
+		data, _ := s["data"].([]interface{})
+		for _, d := range data {
+			if p, ok := d.(map[string]interface{}); ok {
+				point := MetricPoint{Value: numberValue(p["value"])}
+				point.Timestamp, _ = p["timestamp"].(string)
+				out.Points = append(out.Points, point)
+			}
+		}
+		for i, p := range out.Points {
+			if i == 0 || p.Value < out.Min {
+				out.Min = p.Value
+			}
+			if i == 0 || p.Value > out.Max {
+				out.Max = p.Value
+			}
+			out.Mean += p.Value
+		}
+		if n := len(out.Points); n > 0 {
+			out.Mean /= float64(n)
+			out.Latest = out.Points[n-1].Value
+		}
+		if stats, ok := header["statistics"].(map[string]interface{}); ok {
+			if _, ok := stats["mean"]; ok {
+				out.Min = numberValue(stats["min"])
+				out.Max = numberValue(stats["max"])
+				out.Mean = numberValue(stats["mean"])
+			}
+		}
+		series = append(series, out)
+	}
+	return series
+}
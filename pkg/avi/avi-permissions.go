@@ -0,0 +1,276 @@
+package avi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Avi role privilege access types
+const (
+	AccessNone  = "NO_ACCESS"
+	AccessRead  = "READ_ACCESS"
+	AccessWrite = "WRITE_ACCESS"
+)
+
+// toolPermission is the role privilege a tool needs
+type toolPermission struct {
+	resource string
+	write    bool
+}
+
+// endpointPermission stands for the role privilege of the object type a generic operation, a
+// rollback or a download calls, checked per call by AllowsOperation
+const endpointPermission = "PERMISSION_<OBJECT>"
+
+// toolPermissions maps tools to the Avi role privilege they exercise. Tools that aren't listed
+// are always offered.
+var toolPermissions = map[string]toolPermission{
+	"list_virtual_services":            {"PERMISSION_VIRTUALSERVICE", false},
+	"get_virtual_service":              {"PERMISSION_VIRTUALSERVICE", false},
+	"create_virtual_service":           {"PERMISSION_VIRTUALSERVICE", true},
+	"update_virtual_service":           {"PERMISSION_VIRTUALSERVICE", true},
+	"delete_virtual_service":           {"PERMISSION_VIRTUALSERVICE", true},
+	"enable_virtual_service":           {"PERMISSION_VIRTUALSERVICE", true},
+	"disable_virtual_service":          {"PERMISSION_VIRTUALSERVICE", true},
+	"scale_out_virtual_service":        {"PERMISSION_VIRTUALSERVICE", true},
+	"scale_in_virtual_service":         {"PERMISSION_VIRTUALSERVICE", true},
+	"migrate_virtual_service":          {"PERMISSION_VIRTUALSERVICE", true},
+	"switchover_virtual_service":       {"PERMISSION_VIRTUALSERVICE", true},
+	"list_pools":                       {"PERMISSION_POOL", false},
+	"get_pool":                         {"PERMISSION_POOL", false},
+	"create_pool":                      {"PERMISSION_POOL", true},
+	"update_pool":                      {"PERMISSION_POOL", true},
+	"delete_pool":                      {"PERMISSION_POOL", true},
+	"scale_out_pool":                   {"PERMISSION_POOL", true},
+	"scale_in_pool":                    {"PERMISSION_POOL", true},
+	"enable_pool_server":               {"PERMISSION_POOL", true},
+	"disable_pool_server":              {"PERMISSION_POOL", true},
+	"list_health_monitors":             {"PERMISSION_HEALTHMONITOR", false},
+	"get_health_monitor":               {"PERMISSION_HEALTHMONITOR", false},
+	"create_health_monitor":            {"PERMISSION_HEALTHMONITOR", true},
+	"update_health_monitor":            {"PERMISSION_HEALTHMONITOR", true},
+	"delete_health_monitor":            {"PERMISSION_HEALTHMONITOR", true},
+	"list_application_profiles":        {"PERMISSION_APPLICATIONPROFILE", false},
+	"get_application_profile":          {"PERMISSION_APPLICATIONPROFILE", false},
+	"create_application_profile":       {"PERMISSION_APPLICATIONPROFILE", true},
+	"attach_application_profile":       {"PERMISSION_VIRTUALSERVICE", true},
+	"list_persistence_profiles":        {"PERMISSION_APPLICATIONPERSISTENCEPROFILE", false},
+	"get_persistence_profile":          {"PERMISSION_APPLICATIONPERSISTENCEPROFILE", false},
+	"create_persistence_profile":       {"PERMISSION_APPLICATIONPERSISTENCEPROFILE", true},
+	"attach_persistence_profile":       {"PERMISSION_POOL", true},
+	"list_service_engines":             {"PERMISSION_SERVICEENGINE", false},
+	"get_service_engine":               {"PERMISSION_SERVICEENGINE", false},
+	"reboot_service_engine":            {"PERMISSION_SERVICEENGINE", true},
+	"service_engine_maintenance":       {"PERMISSION_SERVICEENGINE", true},
+	"list_vrf_contexts":                {"PERMISSION_VRFCONTEXT", false},
+	"get_vrf_routing":                  {"PERMISSION_VRFCONTEXT", false},
+	"get_bgp_peer_status":              {"PERMISSION_SERVICEENGINE", false},
+	"get_virtual_service_health":       {"PERMISSION_VIRTUALSERVICE", false},
+	"get_virtual_service_health_score": {"PERMISSION_VIRTUALSERVICE", false},
+	"explain_vs_health":                {"PERMISSION_VIRTUALSERVICE", false},
+	"get_pool_health":                  {"PERMISSION_POOL", false},
+	"get_service_engine_health":        {"PERMISSION_SERVICEENGINE", false},
+	"security_audit":                   {"PERMISSION_VIRTUALSERVICE", false},
+	"simulate_change":                  {"PERMISSION_POOL", false},
+	"get_top_virtual_services":         {"PERMISSION_VIRTUALSERVICE", false},
+	"get_sla_report":                   {"PERMISSION_VIRTUALSERVICE", false},
+	"create_alert_rule":                {"PERMISSION_ALERTCONFIG", true},
+	"list_alert_rules":                 {"PERMISSION_ALERTCONFIG", false},
+	"delete_alert_rule":                {"PERMISSION_ALERTCONFIG", true},
+	"get_controller_info":              {"PERMISSION_SYSTEMCONFIGURATION", false},
+	"get_upgrade_status":               {"PERMISSION_UPGRADE", false},
+	"get_license_usage":                {"PERMISSION_CONTROLLER", false},
+	"generate_tech_support":            {"PERMISSION_TECHSUPPORT", true},
+	"list_tech_support":                {"PERMISSION_TECHSUPPORT", false},
+	"list_tenants":                     {"PERMISSION_TENANT", false},
+	"create_tenant":                    {"PERMISSION_TENANT", true},
+	"list_users":                       {"PERMISSION_USER", false},
+	"list_roles":                       {"PERMISSION_ROLE", false},
+	"list_backups":                     {"PERMISSION_BACKUPCONFIGURATION", false},
+	"trigger_backup":                   {"PERMISSION_BACKUPCONFIGURATION", true},
+	"export_configuration":             {"PERMISSION_SYSTEMCONFIGURATION", false},
+	"apply_configuration":              {"PERMISSION_VIRTUALSERVICE", true},
+	"apply_blueprint":                  {"PERMISSION_VIRTUALSERVICE", true},
+	"resume_workflow":                  {"PERMISSION_VIRTUALSERVICE", true},
+	"rollback_change":                  {endpointPermission, true},
+	"check_drift":                      {"PERMISSION_VIRTUALSERVICE", false},
+	"generate_terraform":               {"PERMISSION_VIRTUALSERVICE", false},
+	"generate_ansible_playbook":        {"PERMISSION_VIRTUALSERVICE", false},
+	"get_analytics":                    {"PERMISSION_VIRTUALSERVICE", false},
+	"list_metrics":                     {"PERMISSION_VIRTUALSERVICE", false},
+	"download_file":                    {endpointPermission, false},
+	"execute_generic_operation":        {endpointPermission, false},
+}
+
+// Permissions are the role privileges of the configured Avi account in its tenant
+type Permissions struct {
+	Username  string            `json:"username"`
+	Tenant    string            `json:"tenant"`
+	Role      string            `json:"role"`
+	Superuser bool              `json:"superuser"`
+	Access    map[string]string `json:"access"` // resource -> NO_ACCESS, READ_ACCESS or WRITE_ACCESS
+}
+
+// LoadPermissions reads the account's user object and the role it holds in the tenant
+func LoadPermissions(ctx context.Context, exec GenericExecutor, username, tenant string) (*Permissions, error) {
+	raw, err := exec.ExecuteGenericOperation(ctx, "GET", "/user", nil, map[string]string{
+		"username":     username,
+		"include_name": "true",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user account: %w", err)
+	}
+	user, err := findUser(raw, username)
+	if err != nil {
+		return nil, err
+	}
+
+	perms := &Permissions{
+		Username: username,
+		Tenant:   tenant,
+		Access:   make(map[string]string),
+	}
+	perms.Superuser, _ = user["is_superuser"].(bool)
+	if perms.Superuser {
+		perms.Role = "superuser"
+		return perms, nil
+	}
+
+	roleRef := tenantRoleRef(user, tenant)
+	if roleRef == "" {
+		return nil, fmt.Errorf("user %s has no role in tenant %s", username, tenant)
+	}
+	if i := strings.Index(roleRef, "#"); i >= 0 {
+		perms.Role = roleRef[i+1:]
+	}
+
+	role, err := getObject(ctx, exec, refEndpoint(roleRef))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read role: %w", err)
+	}
+	if name, ok := role["name"].(string); ok {
+		perms.Role = name
+	}
+	privileges, _ := role["privileges"].([]interface{})
+	for _, item := range privileges {
+		if p, ok := item.(map[string]interface{}); ok {
+			resource, _ := p["resource"].(string)
+			access, _ := p["type"].(string)
+			if resource != "" {
+				perms.Access[resource] = access
+			}
+		}
+	}
+	return perms, nil
+}
+
+// findUser picks the user object from a /user collection response
+func findUser(raw interface{}, username string) (map[string]interface{}, error) {
+	collection, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected user response type %T", raw)
+	}
+	results, _ := collection["results"].([]interface{})
+	for _, item := range results {
+		if user, ok := item.(map[string]interface{}); ok {
+			if name, _ := user["username"].(string); name == username {
+				return user, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("user %s not found", username)
+}
+
+// tenantRoleRef returns the role reference the user holds in the tenant
+func tenantRoleRef(user map[string]interface{}, tenant string) string {
+	access, _ := user["access"].([]interface{})
+	for _, item := range access {
+		a, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		roleRef, _ := a["role_ref"].(string)
+		if all, _ := a["all_tenants"].(bool); all {
+			return roleRef
+		}
+		tenantRef, _ := a["tenant_ref"].(string)
+		if strings.HasSuffix(tenantRef, "#"+tenant) || refUUID(tenantRef) == tenant {
+			return roleRef
+		}
+	}
+	return ""
+}
+
+// ToolPermission returns the Avi role privilege a tool needs and whether it needs write access;
+// ok is false for tools that are always offered
+func ToolPermission(name string) (resource string, write, ok bool) {
+	required, ok := toolPermissions[name]
+	return required.resource, required.write, ok
+}
+
+// AllowsTool reports whether the account's role permits the operation behind a tool.
+// Administration tools additionally need the role to be known and to be an administrator.
+func (p *Permissions) AllowsTool(name string) bool {
+	if IsAdminTool(name) && !p.IsAdmin() {
+		return false
+	}
+	if p == nil || p.Superuser {
+		return true
+	}
+	required, ok := toolPermissions[name]
+	if !ok || required.resource == endpointPermission {
+		return true
+	}
+	switch p.Access[required.resource] {
+	case AccessWrite:
+		return true
+	case AccessRead:
+		return !required.write
+	default:
+		return false
+	}
+}
+
+// AllowsOperation checks that the account's role permits a generic operation, from the object
+// type of its endpoint: a change needs write access to it, a read is only refused when the role
+// grants no access to it. Endpoints the role doesn't name, such as /analytics, are left to the
+// controller for reads.
+func (p *Permissions) AllowsOperation(method, endpoint string) error {
+	if p == nil || p.Superuser {
+		return nil
+	}
+	objectType, _, _ := strings.Cut(strings.TrimPrefix(endpoint, "/"), "/")
+	resource := "PERMISSION_" + strings.ToUpper(strings.ReplaceAll(objectType, "-", ""))
+	access, listed := p.Access[resource]
+	switch {
+	case access == AccessWrite:
+		return nil
+	case strings.EqualFold(method, "GET") && (!listed || access == AccessRead):
+		return nil
+	case strings.EqualFold(method, "GET"):
+		return fmt.Errorf("%s %s is not permitted: the Avi account's role (%s) has no access to %s", method, endpoint, p.Role, resource)
+	}
+	return fmt.Errorf("%s %s is not permitted: the Avi account's role (%s) has no write access to %s", method, endpoint, p.Role, resource)
+}
+
+// AllowsApply checks that the account's role can write every object type of a configuration.
+// A dry run only needs read access.
+func (p *Permissions) AllowsApply(objects []ConfigObject, dryRun bool) error {
+	if p == nil || p.Superuser {
+		return nil
+	}
+	for _, obj := range objects {
+		resource := "PERMISSION_" + strings.ToUpper(obj.Type)
+		switch p.Access[resource] {
+		case AccessWrite:
+			continue
+		case AccessRead:
+			if dryRun {
+				continue
+			}
+		}
+		return fmt.Errorf("%s %s can't be applied: the Avi account's role (%s) has no write access to %s", obj.Type, obj.Name, p.Role, resource)
+	}
+	return nil
+}
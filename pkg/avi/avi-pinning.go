@@ -0,0 +1,99 @@
+package avi
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"aviagent/pkg/config"
+)
+
+// controllerTLSConfig returns the TLS configuration for connections to the controller. With
+// pinned fingerprints the chain isn't checked against the CAs: the controller's certificate
+// must be pinned, or be issued by a pinned CA certificate of its chain, instead. Several pins may be listed so a renewal can be prepared by
+// adding the new pin beforehand, and the old one removed afterwards.
+func controllerTLSConfig(cfg *config.AviConfig) *tls.Config {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: cfg.Insecure,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if len(cfg.PinnedFingerprints) == 0 {
+		return tlsConfig
+	}
+	pins, err := cfg.CertificatePins()
+	tlsConfig.InsecureSkipVerify = true // replaced by VerifyConnection
+	tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+		if err != nil {
+			return err
+		}
+		return verifyPins(state, pins)
+	}
+	return tlsConfig
+}
+
+// verifyPins accepts a connection when the controller's certificate matches one of the pins. The
+// handshake only proves the controller holds the key of that certificate, the first of the
+// chain, so a pin matching a certificate further up is trusted as a CA instead: the controller's
+// certificate must be issued by it for the controller's name.
+func verifyPins(state tls.ConnectionState, pins []config.CertificatePin) error {
+	if len(state.PeerCertificates) == 0 {
+		return fmt.Errorf("controller %s presented no certificate to check against avi.pinned_fingerprints", state.ServerName)
+	}
+	leaf := state.PeerCertificates[0]
+	if matchesPin(leaf, pins) {
+		return nil
+	}
+	var chainErr error
+	for i, ca := range state.PeerCertificates[1:] {
+		if !matchesPin(ca, pins) {
+			continue
+		}
+		roots := x509.NewCertPool()
+		roots.AddCert(ca)
+		intermediates := x509.NewCertPool()
+		for _, cert := range state.PeerCertificates[1 : i+1] {
+			intermediates.AddCert(cert)
+		}
+		_, err := leaf.Verify(x509.VerifyOptions{DNSName: state.ServerName, Roots: roots, Intermediates: intermediates})
+		if err == nil {
+			return nil
+		}
+		chainErr = fmt.Errorf("controller %s certificate (subject %q) isn't issued by the pinned CA certificate %q: %w",
+			state.ServerName, leaf.Subject.CommonName, ca.Subject.CommonName, err)
+	}
+	if chainErr != nil {
+		return chainErr
+	}
+	certDigest, keyDigest := certificateDigests(leaf)
+	return fmt.Errorf("controller %s certificate matches none of avi.pinned_fingerprints: it presented fingerprint %s (public key pin sha256/%s, subject %q, expires %s). If the controller certificate was renewed, add the new fingerprint to avi.pinned_fingerprints",
+		state.ServerName, formatFingerprint(certDigest), base64.StdEncoding.EncodeToString(keyDigest[:]),
+		leaf.Subject.CommonName, leaf.NotAfter.Format("2006-01-02"))
+}
+
+// matchesPin reports whether a certificate, or its public key, is one of the pins
+func matchesPin(cert *x509.Certificate, pins []config.CertificatePin) bool {
+	certDigest, keyDigest := certificateDigests(cert)
+	for _, pin := range pins {
+		if (pin.PublicKey && pin.Digest == keyDigest) || (!pin.PublicKey && pin.Digest == certDigest) {
+			return true
+		}
+	}
+	return false
+}
+
+// certificateDigests returns the SHA-256 digests of a certificate and of its public key
+func certificateDigests(cert *x509.Certificate) (certDigest, keyDigest [sha256.Size]byte) {
+	return sha256.Sum256(cert.Raw), sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+}
+
+// formatFingerprint writes a digest the way openssl x509 -fingerprint prints it
+func formatFingerprint(digest [sha256.Size]byte) string {
+	parts := make([]string, len(digest))
+	for i, b := range digest {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
@@ -0,0 +1,91 @@
+package avi
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ParseServerAddress parses a pool server address given as "ip", "ip:port" or "[ipv6]:port"
+func ParseServerAddress(address string) (string, int, error) {
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return "", 0, fmt.Errorf("server address cannot be empty")
+	}
+
+	// Bare IPv6 addresses contain colons but no port
+	if ip := net.ParseIP(strings.Trim(address, "[]")); ip != nil {
+		return ip.String(), 0, nil
+	}
+
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		// Hostnames without a port (DNS servers)
+		if !strings.Contains(address, ":") {
+			return address, 0, nil
+		}
+		return "", 0, fmt.Errorf("invalid server address %q: %w", address, err)
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return "", 0, fmt.Errorf("invalid server port %q", portStr)
+	}
+
+	return host, port, nil
+}
+
+// poolServerPatch finds the server matching ip (and port, when non-zero) in a pool object and
+// returns the PATCH payload that sets its enabled flag without touching the other servers
+func poolServerPatch(pool map[string]interface{}, ip string, port int, enabled bool) (map[string]interface{}, error) {
+	servers, _ := pool["servers"].([]interface{})
+
+	var matches []map[string]interface{}
+	for _, item := range servers {
+		server, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		addr := ""
+		if ipObj, ok := server["ip"].(map[string]interface{}); ok {
+			addr, _ = ipObj["addr"].(string)
+		}
+		hostname, _ := server["hostname"].(string)
+		if !sameIP(addr, ip) && hostname != ip {
+			continue
+		}
+		if port != 0 {
+			serverPort := int(numberValue(server["port"]))
+			if serverPort == 0 {
+				serverPort = int(numberValue(pool["default_server_port"]))
+			}
+			if serverPort != port {
+				continue
+			}
+		}
+		matches = append(matches, server)
+	}
+
+	poolName, _ := pool["name"].(string)
+	switch len(matches) {
+	case 0:
+		if port != 0 {
+			return nil, fmt.Errorf("server %s:%d not found in pool %s", ip, port, poolName)
+		}
+		return nil, fmt.Errorf("server %s not found in pool %s", ip, poolName)
+	case 1:
+	default:
+		return nil, fmt.Errorf("server %s matches %d entries in pool %s, specify the port", ip, len(matches), poolName)
+	}
+
+	server := make(map[string]interface{}, len(matches[0]))
+	for key, value := range matches[0] {
+		server[key] = value
+	}
+	server["enabled"] = enabled
+
+	return map[string]interface{}{
+		"servers": []interface{}{server},
+	}, nil
+}
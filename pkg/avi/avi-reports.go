@@ -0,0 +1,191 @@
+package avi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Report limits
+const (
+	reportPageSize      = 200
+	maxReportObjects    = 5000 // objects read per collection at most
+	maxDegradedInReport = 20
+)
+
+// HealthOverview is the health of every virtual service at a point in time
+type HealthOverview struct {
+	VirtualServices int             `json:"virtual_services"`
+	Up              int             `json:"up"`
+	Down            int             `json:"down"`     // not OPER_UP
+	Degraded        int             `json:"degraded"` // up with a health score below 85
+	Worst           []HealthSummary `json:"worst"`    // down and degraded virtual services, worst first
+}
+
+// GetHealthOverview reads the inventory of every virtual service and counts how many are up,
+// down or degraded
+func GetHealthOverview(ctx context.Context, exec GenericExecutor) (*HealthOverview, error) {
+	objects, err := listAllObjects(ctx, exec, "/virtualservice-inventory", map[string]string{"include_name": "true"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read virtual service inventory: %w", err)
+	}
+
+	overview := &HealthOverview{VirtualServices: len(objects), Worst: []HealthSummary{}}
+	for _, obj := range objects {
+		summary := summarizeInventoryObject(obj)
+		switch {
+		case summary.OperState != "" && summary.OperState != "OPER_UP":
+			overview.Down++
+		case summary.HealthScore < 85:
+			overview.Up++
+			overview.Degraded++
+		default:
+			overview.Up++
+			continue
+		}
+		overview.Worst = append(overview.Worst, summary)
+	}
+	sort.SliceStable(overview.Worst, func(i, j int) bool {
+		return overview.Worst[i].HealthScore < overview.Worst[j].HealthScore
+	})
+	if len(overview.Worst) > maxDegradedInReport {
+		overview.Worst = overview.Worst[:maxDegradedInReport]
+	}
+	return overview, nil
+}
+
+// CertificateStatus is the expiry of an SSL key and certificate
+type CertificateStatus struct {
+	Name     string    `json:"name"`
+	UUID     string    `json:"uuid"`
+	NotAfter string    `json:"not_after"`
+	Expires  time.Time `json:"expires"`   // NotAfter parsed
+	DaysLeft int       `json:"days_left"` // negative once expired
+}
+
+// CertificateExpiryReport lists the certificates that expire within a window
+type CertificateExpiryReport struct {
+	WithinDays int                 `json:"within_days"`
+	Checked    int                 `json:"checked"`
+	Expiring   []CertificateStatus `json:"expiring"` // soonest first, expired certificates included
+}
+
+// GetCertificateExpiry reads every SSL key and certificate and returns those that have expired or
+// expire within the given number of days
+func GetCertificateExpiry(ctx context.Context, exec GenericExecutor, withinDays int, now time.Time) (*CertificateExpiryReport, error) {
+	objects, err := listAllObjects(ctx, exec, "/sslkeyandcertificate", map[string]string{"fields": "name,uuid,certificate"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificates: %w", err)
+	}
+
+	report := &CertificateExpiryReport{WithinDays: withinDays, Checked: len(objects), Expiring: []CertificateStatus{}}
+	for _, obj := range objects {
+		details, _ := obj["certificate"].(map[string]interface{})
+		notAfter, _ := details["not_after"].(string)
+		expires, err := parseAviTime(notAfter)
+		if err != nil {
+			continue
+		}
+		daysLeft := int(expires.Sub(now).Hours() / 24)
+		if daysLeft > withinDays {
+			continue
+		}
+		status := CertificateStatus{NotAfter: notAfter, Expires: expires, DaysLeft: daysLeft}
+		status.Name, _ = obj["name"].(string)
+		status.UUID, _ = obj["uuid"].(string)
+		report.Expiring = append(report.Expiring, status)
+	}
+	sort.SliceStable(report.Expiring, func(i, j int) bool {
+		return report.Expiring[i].DaysLeft < report.Expiring[j].DaysLeft
+	})
+	return report, nil
+}
+
+// SEGroupCapacity is the number of service engines of a group against its maximum
+type SEGroupCapacity struct {
+	Name           string  `json:"name"`
+	ServiceEngines int     `json:"service_engines"`
+	MaxSE          int     `json:"max_se"`
+	UsagePercent   float64 `json:"usage_percent"`
+}
+
+// CapacityReport is the license and service engine capacity of the controller
+type CapacityReport struct {
+	License         *LicenseSummary   `json:"license,omitempty"`
+	VirtualServices int               `json:"virtual_services"`
+	ServiceEngines  int               `json:"service_engines"`
+	SEGroups        []SEGroupCapacity `json:"se_groups"` // fullest first
+	Warnings        []string          `json:"warnings,omitempty"`
+}
+
+// GetCapacity reads license usage and counts virtual services and service engines per SE group.
+// Sources that can't be read are reported as warnings.
+func GetCapacity(ctx context.Context, exec GenericExecutor) (*CapacityReport, error) {
+	report := &CapacityReport{SEGroups: []SEGroupCapacity{}}
+
+	if license, err := GetLicenseSummary(ctx, exec); err != nil {
+		report.Warnings = append(report.Warnings, err.Error())
+	} else {
+		report.License = license
+	}
+
+	if services, err := listAllObjects(ctx, exec, "/virtualservice", map[string]string{"fields": "uuid"}); err != nil {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("virtual services: %v", err))
+	} else {
+		report.VirtualServices = len(services)
+	}
+
+	engines, err := listAllObjects(ctx, exec, "/serviceengine", map[string]string{"fields": "uuid,se_group_ref"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service engines: %w", err)
+	}
+	report.ServiceEngines = len(engines)
+	perGroup := make(map[string]int)
+	for _, se := range engines {
+		if ref, ok := se["se_group_ref"].(string); ok {
+			perGroup[refUUID(ref)]++
+		}
+	}
+
+	groups, err := listAllObjects(ctx, exec, "/serviceenginegroup", map[string]string{"fields": "name,uuid,max_se"})
+	if err != nil {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("service engine groups: %v", err))
+		return report, nil
+	}
+	for _, group := range groups {
+		capacity := SEGroupCapacity{MaxSE: int(numberValue(group["max_se"]))}
+		capacity.Name, _ = group["name"].(string)
+		uuid, _ := group["uuid"].(string)
+		capacity.ServiceEngines = perGroup[uuid]
+		if capacity.MaxSE > 0 {
+			capacity.UsagePercent = float64(capacity.ServiceEngines) * 100 / float64(capacity.MaxSE)
+		}
+		report.SEGroups = append(report.SEGroups, capacity)
+	}
+	sort.SliceStable(report.SEGroups, func(i, j int) bool {
+		return report.SEGroups[i].UsagePercent > report.SEGroups[j].UsagePercent
+	})
+	return report, nil
+}
+
+// listAllObjects reads a collection page by page, up to maxReportObjects objects
+func listAllObjects(ctx context.Context, exec GenericExecutor, endpoint string, params map[string]string) ([]map[string]interface{}, error) {
+	var objects []map[string]interface{}
+	for page := 1; len(objects) < maxReportObjects; page++ {
+		pageParams := map[string]string{"page_size": strconv.Itoa(reportPageSize), "page": strconv.Itoa(page)}
+		for key, value := range params {
+			pageParams[key] = value
+		}
+		results, err := listObjects(ctx, exec, endpoint, pageParams)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, results...)
+		if len(results) < reportPageSize {
+			break
+		}
+	}
+	return objects, nil
+}
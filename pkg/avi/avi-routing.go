@@ -0,0 +1,127 @@
+package avi
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// StaticRouteSummary is a compact view of a VRF static route
+type StaticRouteSummary struct {
+	RouteID   string `json:"route_id"`
+	Prefix    string `json:"prefix"`
+	NextHop   string `json:"next_hop"`
+	Interface string `json:"interface,omitempty"`
+}
+
+// BGPPeerSummary is a compact view of a configured BGP peer (secrets are left out)
+type BGPPeerSummary struct {
+	PeerIP          string `json:"peer_ip"`
+	RemoteAS        int    `json:"remote_as,omitempty"`
+	Subnet          string `json:"subnet,omitempty"`
+	BFD             bool   `json:"bfd"`
+	AdvertiseVIP    bool   `json:"advertise_vip"`
+	AdvertiseSNATIP bool   `json:"advertise_snat_ip"`
+	Shutdown        bool   `json:"shutdown,omitempty"`
+}
+
+// RoutingSummary describes the routing configuration of a VRF context
+type RoutingSummary struct {
+	UUID         string               `json:"uuid"`
+	Name         string               `json:"name"`
+	StaticRoutes []StaticRouteSummary `json:"static_routes"`
+	BGPEnabled   bool                 `json:"bgp_enabled"`
+	LocalAS      int                  `json:"local_as,omitempty"`
+	IBGP         bool                 `json:"ibgp,omitempty"`
+	BGPPeers     []BGPPeerSummary     `json:"bgp_peers,omitempty"`
+}
+
+// SummarizeRouting extracts static routes and BGP peering from a VRF context. The VRF can be a
+// raw API map or an SDK model.
+func SummarizeRouting(vrf interface{}) (*RoutingSummary, error) {
+	obj, ok := vrf.(map[string]interface{})
+	if !ok {
+		raw, err := json.Marshal(vrf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal VRF context: %w", err)
+		}
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return nil, fmt.Errorf("unexpected VRF context format: %w", err)
+		}
+	}
+
+	summary := &RoutingSummary{StaticRoutes: []StaticRouteSummary{}}
+	summary.UUID, _ = obj["uuid"].(string)
+	summary.Name, _ = obj["name"].(string)
+
+	routes, _ := obj["static_routes"].([]interface{})
+	for _, item := range routes {
+		route, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		sr := StaticRouteSummary{
+			Prefix:  prefixString(route["prefix"]),
+			NextHop: addrString(route["next_hop"]),
+		}
+		sr.RouteID, _ = route["route_id"].(string)
+		sr.Interface, _ = route["if_name"].(string)
+		summary.StaticRoutes = append(summary.StaticRoutes, sr)
+	}
+
+	bgp, ok := obj["bgp_profile"].(map[string]interface{})
+	if !ok {
+		return summary, nil
+	}
+	summary.BGPEnabled = true
+	if shutdown, _ := bgp["shutdown"].(bool); shutdown {
+		summary.BGPEnabled = false
+	}
+	summary.LocalAS = int(numberValue(bgp["local_as"]))
+	summary.IBGP, _ = bgp["ibgp"].(bool)
+
+	peers, _ := bgp["peers"].([]interface{})
+	for _, item := range peers {
+		peer, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		p := BGPPeerSummary{
+			PeerIP:   addrString(peer["peer_ip"]),
+			RemoteAS: int(numberValue(peer["remote_as"])),
+			Subnet:   prefixString(peer["subnet"]),
+		}
+		if p.PeerIP == "" {
+			p.PeerIP = addrString(peer["peer_ip6"])
+			p.Subnet = prefixString(peer["subnet6"])
+		}
+		p.BFD, _ = peer["bfd"].(bool)
+		p.AdvertiseVIP, _ = peer["advertise_vip"].(bool)
+		p.AdvertiseSNATIP, _ = peer["advertise_snat_ip"].(bool)
+		p.Shutdown, _ = peer["shutdown"].(bool)
+		summary.BGPPeers = append(summary.BGPPeers, p)
+	}
+
+	return summary, nil
+}
+
+// addrString returns the address of an Avi IpAddr object
+func addrString(v interface{}) string {
+	if ip, ok := v.(map[string]interface{}); ok {
+		addr, _ := ip["addr"].(string)
+		return addr
+	}
+	return ""
+}
+
+// prefixString formats an Avi IpAddrPrefix object as CIDR notation
+func prefixString(v interface{}) string {
+	prefix, ok := v.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	addr := addrString(prefix["ip_addr"])
+	if addr == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%d", addr, int(numberValue(prefix["mask"])))
+}
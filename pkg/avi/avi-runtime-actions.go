@@ -0,0 +1,93 @@
+package avi
+
+import "fmt"
+
+// Virtual service runtime actions (POST /virtualservice/{uuid}/{action})
+const (
+	VSActionScaleOut   = "scaleout"
+	VSActionScaleIn    = "scalein"
+	VSActionMigrate    = "migrate"
+	VSActionSwitchover = "switchover"
+)
+
+// Service engine runtime actions (POST /serviceengine/{uuid}/{action})
+const (
+	SEActionReboot = "reboot"
+)
+
+var (
+	virtualServiceActions = map[string]bool{VSActionScaleOut: true, VSActionScaleIn: true, VSActionMigrate: true, VSActionSwitchover: true}
+	serviceEngineActions  = map[string]bool{SEActionReboot: true}
+)
+
+// seRef builds a service engine reference from a UUID
+func seRef(uuid string) string {
+	return "/api/serviceengine/" + uuid
+}
+
+// VirtualServiceActionBody builds the request body of a virtual service runtime action from
+// tool arguments: vip_id (default "0"), from_se and to_se (service engine UUIDs) and to_new_se.
+func VirtualServiceActionBody(action string, args map[string]interface{}) (map[string]interface{}, error) {
+	if err := validateVirtualServiceAction(action); err != nil {
+		return nil, err
+	}
+
+	vipID := "0"
+	switch v := args["vip_id"].(type) {
+	case string:
+		if v != "" {
+			vipID = v
+		}
+	case float64:
+		vipID = fmt.Sprintf("%d", int(v))
+	}
+	body := map[string]interface{}{"vip_id": vipID}
+
+	fromSE, _ := args["from_se"].(string)
+	toSE, _ := args["to_se"].(string)
+	toNewSE, _ := args["to_new_se"].(bool)
+
+	switch action {
+	case VSActionScaleOut:
+		if toSE != "" {
+			body["to_se_ref"] = seRef(toSE)
+		} else {
+			body["to_new_se"] = toNewSE
+		}
+	case VSActionScaleIn:
+		if fromSE == "" {
+			return nil, fmt.Errorf("from_se parameter required to scale in")
+		}
+		body["from_se_ref"] = seRef(fromSE)
+		if primary, ok := args["scalein_primary"].(bool); ok {
+			body["scalein_primary"] = primary
+		}
+	case VSActionMigrate:
+		if fromSE == "" {
+			return nil, fmt.Errorf("from_se parameter required to migrate")
+		}
+		body["from_se_ref"] = seRef(fromSE)
+		if toSE != "" {
+			body["to_se_ref"] = seRef(toSE)
+		} else {
+			body["to_new_se"] = toNewSE
+		}
+	}
+	return body, nil
+}
+
+// validateServiceEngineAction checks a service engine runtime action name
+func validateServiceEngineAction(action string) error {
+	if !serviceEngineActions[action] {
+		return fmt.Errorf("unsupported service engine action %q", action)
+	}
+	return nil
+}
+
+// validateVirtualServiceAction checks a virtual service runtime action name
+func validateVirtualServiceAction(action string) error {
+	if !virtualServiceActions[action] {
+		return fmt.Errorf("unsupported virtual service action %q", action)
+	}
+	return nil
+}
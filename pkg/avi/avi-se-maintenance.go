@@ -0,0 +1,354 @@
+package avi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Service engine maintenance steps, run one at a time in this order so the operator approves each
+const (
+	MaintenancePlan    = "plan"    // show the virtual services placed on the service engine
+	MaintenanceDisable = "disable" // disable the service engine so its virtual services migrate
+	MaintenanceWait    = "wait"    // wait until no virtual service is placed on it
+	MaintenanceVerify  = "verify"  // confirm the migrated virtual services are up
+	MaintenanceEnable  = "enable"  // enable the service engine again after the maintenance
+)
+
+// MaintenanceSteps lists the maintenance steps in order
+var MaintenanceSteps = []string{MaintenancePlan, MaintenanceDisable, MaintenanceWait, MaintenanceVerify, MaintenanceEnable}
+
+// Service engine enable states
+const (
+	seStateEnabled  = "SE_STATE_ENABLED"
+	seStateDisabled = "SE_STATE_DISABLED"
+)
+
+// ErrMaintenanceAborted is wrapped by the errors of steps that aborted the maintenance and
+// enabled the service engine again
+var ErrMaintenanceAborted = errors.New("maintenance aborted")
+
+// maintenancePollInterval is how often virtual service placement is checked while waiting for
+// the migration off a disabled service engine
+var maintenancePollInterval = 5 * time.Second
+
+// MaintenanceReport is the outcome of a maintenance step
+type MaintenanceReport struct {
+	ServiceEngine   string          `json:"service_engine"`
+	UUID            string          `json:"uuid"`
+	Group           string          `json:"se_group,omitempty"`
+	Step            string          `json:"step"`
+	EnableState     string          `json:"enable_state"`
+	VirtualServices []MaintenanceVS `json:"virtual_services"`
+	Message         string          `json:"message"`
+	NextStep        string          `json:"next_step,omitempty"`
+	InProgress      bool            `json:"in_progress,omitempty"` // the step hasn't finished and is run again
+}
+
+// MaintenanceVS is a virtual service affected by the maintenance
+type MaintenanceVS struct {
+	Name            string   `json:"name"`
+	UUID            string   `json:"uuid"`
+	OperState       string   `json:"oper_state"`
+	HealthScore     float64  `json:"health_score"`
+	ServiceEngines  []string `json:"service_engines"`
+	OnServiceEngine bool     `json:"on_service_engine"`
+}
+
+// serviceEngine is the state of the service engine under maintenance
+type serviceEngine struct {
+	name, uuid, group, state string
+}
+
+// ServiceEngineMaintenance runs one step of the maintenance of a service engine: plan, disable,
+// wait, verify or enable. Each step checks that the previous one completed. A wait that runs out
+// of time reports the migration still in progress, to be waited for again. The workflow aborts
+// when the virtual services aren't up once they have migrated: the service engine is enabled
+// again and the error says why.
+func ServiceEngineMaintenance(ctx context.Context, exec GenericExecutor, nameOrUUID, step string, wait time.Duration) (*MaintenanceReport, error) {
+	if strings.TrimSpace(nameOrUUID) == "" {
+		return nil, fmt.Errorf("service_engine parameter required")
+	}
+	se, err := loadServiceEngine(ctx, exec, nameOrUUID)
+	if err != nil {
+		return nil, err
+	}
+	report := &MaintenanceReport{ServiceEngine: se.name, UUID: se.uuid, Group: refName(se.group), Step: step, EnableState: se.state}
+
+	switch step {
+	case MaintenancePlan:
+		return planMaintenance(ctx, exec, se, report)
+	case MaintenanceDisable:
+		return disableForMaintenance(ctx, exec, se, report)
+	case MaintenanceWait:
+		return waitForMigration(ctx, exec, se, report, wait)
+	case MaintenanceVerify:
+		return verifyMigration(ctx, exec, se, report)
+	case MaintenanceEnable:
+		if se.state == seStateEnabled {
+			return nil, fmt.Errorf("service engine %s is already enabled", se.name)
+		}
+		if err := setServiceEngineState(ctx, exec, se, seStateEnabled); err != nil {
+			return nil, err
+		}
+		report.EnableState = seStateEnabled
+		report.Message = "The service engine is enabled and takes new placements again. Virtual services are not moved back automatically; scale them out or migrate them to rebalance."
+		return report, nil
+	}
+	return nil, fmt.Errorf("unknown maintenance step %q, expected one of %s", step, strings.Join(MaintenanceSteps, ", "))
+}
+
+// planMaintenance lists the virtual services the maintenance moves and checks they have somewhere to go
+func planMaintenance(ctx context.Context, exec GenericExecutor, se *serviceEngine, report *MaintenanceReport) (*MaintenanceReport, error) {
+	placed, err := maintenancePlacement(ctx, exec, se)
+	if err != nil {
+		return nil, err
+	}
+	report.VirtualServices = onServiceEngine(placed)
+
+	if se.state != seStateEnabled {
+		report.Message = fmt.Sprintf("The service engine is already out of service (%s).", se.state)
+		report.NextStep = MaintenanceWait
+		return report, nil
+	}
+	others, err := otherEnabledServiceEngines(ctx, exec, se)
+	if err != nil {
+		return nil, err
+	}
+	if len(others) == 0 {
+		report.Message = fmt.Sprintf("No other enabled service engine in group %s: the virtual services can't migrate, so the service engine can't be taken out of service.", report.Group)
+		return report, nil
+	}
+	report.Message = fmt.Sprintf("%d virtual services will migrate to the other service engines of group %s (%s). Nothing was changed.",
+		len(report.VirtualServices), report.Group, strings.Join(others, ", "))
+	report.NextStep = MaintenanceDisable
+	return report, nil
+}
+
+// disableForMaintenance disables the service engine, which migrates its virtual services
+func disableForMaintenance(ctx context.Context, exec GenericExecutor, se *serviceEngine, report *MaintenanceReport) (*MaintenanceReport, error) {
+	if se.state != seStateEnabled {
+		return nil, fmt.Errorf("service engine %s is already out of service (%s)", se.name, se.state)
+	}
+	others, err := otherEnabledServiceEngines(ctx, exec, se)
+	if err != nil {
+		return nil, err
+	}
+	if len(others) == 0 {
+		return nil, fmt.Errorf("no other enabled service engine in group %s, the virtual services of %s can't migrate", refName(se.group), se.name)
+	}
+	if err := setServiceEngineState(ctx, exec, se, seStateDisabled); err != nil {
+		return nil, err
+	}
+	report.EnableState = seStateDisabled
+	report.Message = "The service engine is disabled and its virtual services are migrating."
+	report.NextStep = MaintenanceWait
+	return report, nil
+}
+
+// waitForMigration waits up to wait, and no longer than the context allows, until no virtual
+// service is placed on the service engine. When time runs out the migration is still going: the
+// report asks for the wait to be run again and the service engine stays disabled.
+func waitForMigration(ctx context.Context, exec GenericExecutor, se *serviceEngine, report *MaintenanceReport, wait time.Duration) (*MaintenanceReport, error) {
+	if se.state == seStateEnabled {
+		return nil, fmt.Errorf("service engine %s is enabled, disable it first", se.name)
+	}
+	deadline := time.Now().Add(wait)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	start := time.Now()
+	for {
+		placed, err := maintenancePlacement(ctx, exec, se)
+		if err != nil {
+			return nil, err
+		}
+		report.VirtualServices = placed
+		remaining := onServiceEngine(placed)
+		if len(remaining) == 0 {
+			report.Message = "No virtual service is placed on the service engine any more."
+			report.NextStep = MaintenanceVerify
+			return report, nil
+		}
+
+		if time.Now().Add(maintenancePollInterval).After(deadline) {
+			report.Message = fmt.Sprintf("Still migrating after %s: %s still placed on the service engine, which stays disabled. Run the wait step again.",
+				FormatDuration(int64(time.Since(start).Seconds())), strings.Join(vsNames(remaining), ", "))
+			report.NextStep = MaintenanceWait
+			report.InProgress = true
+			return report, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(maintenancePollInterval):
+		}
+	}
+}
+
+// verifyMigration checks the virtual services of the group are up on the remaining service engines
+func verifyMigration(ctx context.Context, exec GenericExecutor, se *serviceEngine, report *MaintenanceReport) (*MaintenanceReport, error) {
+	if se.state == seStateEnabled {
+		return nil, fmt.Errorf("service engine %s is enabled, disable it first", se.name)
+	}
+	placed, err := maintenancePlacement(ctx, exec, se)
+	if err != nil {
+		return nil, err
+	}
+	if remaining := onServiceEngine(placed); len(remaining) > 0 {
+		return nil, fmt.Errorf("%s still placed on service engine %s, wait for the migration first", strings.Join(vsNames(remaining), ", "), se.name)
+	}
+	report.VirtualServices = placed
+
+	var down []string
+	for _, vs := range placed {
+		if vs.OperState != "OPER_UP" {
+			down = append(down, fmt.Sprintf("%s (%s)", vs.Name, vs.OperState))
+		}
+	}
+	if len(down) > 0 {
+		return nil, abortMaintenance(ctx, exec, se, "virtual services not up after the migration: "+strings.Join(down, ", "))
+	}
+	report.Message = "All virtual services of the group are up. The service engine can be maintained; enable it again afterwards."
+	report.NextStep = MaintenanceEnable
+	return report, nil
+}
+
+// abortMaintenance enables the service engine again and describes why the maintenance stopped
+func abortMaintenance(ctx context.Context, exec GenericExecutor, se *serviceEngine, reason string) error {
+	if err := setServiceEngineState(ctx, exec, se, seStateEnabled); err != nil {
+		return fmt.Errorf("%w on %s: %s; enabling it again failed, enable it manually: %v", ErrMaintenanceAborted, se.name, reason, err)
+	}
+	return fmt.Errorf("%w on %s: %s; the service engine was enabled again", ErrMaintenanceAborted, se.name, reason)
+}
+
+// loadServiceEngine reads the service engine by name or UUID
+func loadServiceEngine(ctx context.Context, exec GenericExecutor, nameOrUUID string) (*serviceEngine, error) {
+	obj, err := findObject(ctx, exec, "serviceengine", "service engine", nameOrUUID)
+	if err != nil {
+		return nil, err
+	}
+	se := &serviceEngine{}
+	se.name, _ = obj["name"].(string)
+	se.uuid, _ = obj["uuid"].(string)
+	se.group, _ = obj["se_group_ref"].(string)
+	se.state = valueOrDefault(stringValue(obj["enable_state"]), seStateEnabled)
+	return se, nil
+}
+
+// setServiceEngineState changes the enable state of the service engine
+func setServiceEngineState(ctx context.Context, exec GenericExecutor, se *serviceEngine, state string) error {
+	patch := map[string]interface{}{"replace": map[string]interface{}{"enable_state": state}}
+	if _, err := exec.ExecuteGenericOperation(ctx, "PATCH", "/serviceengine/"+se.uuid, patch, nil); err != nil {
+		return fmt.Errorf("failed to set service engine %s to %s: %w", se.name, state, err)
+	}
+	se.state = state
+	return nil
+}
+
+// otherEnabledServiceEngines returns the names of the other enabled service engines of the group
+func otherEnabledServiceEngines(ctx context.Context, exec GenericExecutor, se *serviceEngine) ([]string, error) {
+	engines, err := listAllObjects(ctx, exec, "/serviceengine", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list service engines: %w", err)
+	}
+	var names []string
+	for _, obj := range engines {
+		uuid, _ := obj["uuid"].(string)
+		group, _ := obj["se_group_ref"].(string)
+		state := valueOrDefault(stringValue(obj["enable_state"]), seStateEnabled)
+		if uuid != se.uuid && refUUID(group) == refUUID(se.group) && state == seStateEnabled {
+			names = append(names, stringValue(obj["name"]))
+		}
+	}
+	return names, nil
+}
+
+// maintenancePlacement returns the enabled virtual services placed on the service engine or on
+// another service engine of its group, from the runtime vip_summary of the virtual service inventory
+func maintenancePlacement(ctx context.Context, exec GenericExecutor, se *serviceEngine) ([]MaintenanceVS, error) {
+	engines, err := listAllObjects(ctx, exec, "/serviceengine", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list service engines: %w", err)
+	}
+	groupEngines := map[string]string{}
+	for _, obj := range engines {
+		if group, _ := obj["se_group_ref"].(string); refUUID(group) == refUUID(se.group) {
+			groupEngines[stringValue(obj["uuid"])] = stringValue(obj["name"])
+		}
+	}
+
+	inventory, err := listAllObjects(ctx, exec, "/virtualservice-inventory", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read virtual service placement: %w", err)
+	}
+	var placed []MaintenanceVS
+	for _, item := range inventory {
+		config, _ := item["config"].(map[string]interface{})
+		if enabled, ok := config["enabled"].(bool); ok && !enabled {
+			continue
+		}
+		summary := summarizeInventoryObject(item)
+		vs := MaintenanceVS{Name: summary.Name, UUID: summary.UUID, OperState: summary.OperState, HealthScore: summary.HealthScore}
+		for _, uuid := range placementEngines(item) {
+			name, inGroup := groupEngines[uuid]
+			if !inGroup {
+				continue
+			}
+			vs.ServiceEngines = append(vs.ServiceEngines, name)
+			if uuid == se.uuid {
+				vs.OnServiceEngine = true
+			}
+		}
+		if len(vs.ServiceEngines) > 0 {
+			placed = append(placed, vs)
+		}
+	}
+	sort.Slice(placed, func(i, j int) bool { return placed[i].Name < placed[j].Name })
+	return placed, nil
+}
+
+// placementEngines returns the UUIDs of the service engines a virtual service is placed on
+func placementEngines(item map[string]interface{}) []string {
+	runtime, _ := item["runtime"].(map[string]interface{})
+	vips, _ := runtime["vip_summary"].([]interface{})
+	var uuids []string
+	for _, v := range vips {
+		vip, _ := v.(map[string]interface{})
+		engines, _ := vip["service_engine"].([]interface{})
+		for _, e := range engines {
+			engine, _ := e.(map[string]interface{})
+			uuid := stringValue(engine["uuid"])
+			if uuid == "" {
+				uuid = refUUID(stringValue(engine["url"]))
+			}
+			if uuid != "" {
+				uuids = append(uuids, uuid)
+			}
+		}
+	}
+	return uuids
+}
+
+// onServiceEngine keeps the virtual services still placed on the service engine
+func onServiceEngine(placed []MaintenanceVS) []MaintenanceVS {
+	result := []MaintenanceVS{}
+	for _, vs := range placed {
+		if vs.OnServiceEngine {
+			result = append(result, vs)
+		}
+	}
+	return result
+}
+
+// vsNames returns the names of the virtual services
+func vsNames(list []MaintenanceVS) []string {
+	names := make([]string, len(list))
+	for i, vs := range list {
+		names[i] = vs.Name
+	}
+	return names
+}
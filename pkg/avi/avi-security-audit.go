@@ -0,0 +1,431 @@
+package avi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GenericExecutor is implemented by both Avi clients and is all the audit needs to read objects
+type GenericExecutor interface {
+	ExecuteGenericOperation(ctx context.Context, method, endpoint string, body interface{}, params map[string]string) (interface{}, error)
+}
+
+// Security finding severities, from most to least severe
+const (
+	SeverityCritical = "critical"
+	SeverityHigh     = "high"
+	SeverityMedium   = "medium"
+	SeverityLow      = "low"
+	SeverityInfo     = "info"
+)
+
+// severityPenalty is the number of points a finding removes from the audit score
+var severityPenalty = map[string]int{
+	SeverityCritical: 40,
+	SeverityHigh:     20,
+	SeverityMedium:   10,
+	SeverityLow:      5,
+	SeverityInfo:     0,
+}
+
+// SecurityFinding is a single issue found by the security audit
+type SecurityFinding struct {
+	Category    string `json:"category"` // tls, certificate, headers, waf
+	Severity    string `json:"severity"`
+	Issue       string `json:"issue"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+// SecurityReport is the graded result of a virtual service security audit
+type SecurityReport struct {
+	UUID           string            `json:"uuid"`
+	VirtualService string            `json:"virtual_service"`
+	Grade          string            `json:"grade"`
+	Score          int               `json:"score"`
+	Findings       []SecurityFinding `json:"findings"`
+	Passed         []string          `json:"passed,omitempty"`
+}
+
+// weakCipherTokens are OpenSSL cipher string fragments that enable broken or obsolete ciphers
+var weakCipherTokens = []string{"RC4", "DES-CBC3", "3DES", "NULL", "EXPORT", "MD5", "aNULL", "eNULL"}
+
+// securityHeaders are the response headers checked in HTTP policies (HSTS is also an application profile setting)
+var securityHeaders = []string{"Strict-Transport-Security", "X-Frame-Options", "X-Content-Type-Options", "Content-Security-Policy"}
+
+// securityAudit collects findings while the audit runs
+type securityAudit struct {
+	ctx    context.Context
+	exec   GenericExecutor
+	report *SecurityReport
+}
+
+// RunSecurityAudit inspects a virtual service's SSL profile, certificate chain, security headers
+// and WAF status and returns a graded report with remediation suggestions
+func RunSecurityAudit(ctx context.Context, exec GenericExecutor, uuid string) (*SecurityReport, error) {
+	vs, err := getObject(ctx, exec, "/virtualservice/"+uuid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get virtual service: %w", err)
+	}
+
+	a := &securityAudit{
+		ctx:    ctx,
+		exec:   exec,
+		report: &SecurityReport{UUID: uuid},
+	}
+	a.report.VirtualService, _ = vs["name"].(string)
+
+	if a.auditTLSEnabled(vs) {
+		a.auditSSLProfile(vs)
+		a.auditCertificates(vs)
+	}
+	a.auditHeaders(vs)
+	a.auditWAF(vs)
+
+	a.grade()
+	return a.report, nil
+}
+
+// getObject reads a single object through the generic API
+func getObject(ctx context.Context, exec GenericExecutor, endpoint string) (map[string]interface{}, error) {
+	raw, err := exec.ExecuteGenericOperation(ctx, "GET", endpoint, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected response type %T for %s", raw, endpoint)
+	}
+	return obj, nil
+}
+
+// refEndpoint converts an Avi reference URL into a generic API endpoint ("/sslprofile/<uuid>")
+func refEndpoint(ref string) string {
+	ref = strings.SplitN(ref, "#", 2)[0]
+	ref = strings.SplitN(ref, "?", 2)[0]
+	if i := strings.Index(ref, "/api/"); i >= 0 {
+		return ref[i+len("/api"):]
+	}
+	return ref
+}
+
+// fetchRef reads a referenced object, recording a finding when it can't be inspected
+func (a *securityAudit) fetchRef(category, what, ref string) map[string]interface{} {
+	obj, err := getObject(a.ctx, a.exec, refEndpoint(ref))
+	if err != nil {
+		a.add(category, SeverityInfo, fmt.Sprintf("Could not inspect the %s: %v", what, err), "")
+		return nil
+	}
+	return obj
+}
+
+// add records a finding
+func (a *securityAudit) add(category, severity, issue, remediation string) {
+	a.report.Findings = append(a.report.Findings, SecurityFinding{
+		Category:    category,
+		Severity:    severity,
+		Issue:       issue,
+		Remediation: remediation,
+	})
+}
+
+// pass records a check that passed
+func (a *securityAudit) pass(check string) {
+	a.report.Passed = append(a.report.Passed, check)
+}
+
+// auditTLSEnabled checks that at least one service port terminates TLS
+func (a *securityAudit) auditTLSEnabled(vs map[string]interface{}) bool {
+	services, _ := vs["services"].([]interface{})
+	for _, item := range services {
+		if svc, ok := item.(map[string]interface{}); ok {
+			if enabled, _ := svc["enable_ssl"].(bool); enabled {
+				a.pass("TLS is enabled on at least one service port")
+				return true
+			}
+		}
+	}
+
+	a.add("tls", SeverityHigh, "The virtual service does not terminate TLS on any port, traffic is sent in clear text.",
+		"Enable SSL on the service port (usually 443), attach an SSL profile and a certificate, and redirect port 80 to HTTPS.")
+	return false
+}
+
+// auditSSLProfile checks protocol versions and ciphers
+func (a *securityAudit) auditSSLProfile(vs map[string]interface{}) {
+	ref, _ := vs["ssl_profile_ref"].(string)
+	if ref == "" {
+		a.add("tls", SeverityMedium, "No SSL profile is attached, the controller default applies.",
+			"Attach an explicit SSL profile such as System-Standard so protocol and cipher settings are reviewed.")
+		return
+	}
+	profile := a.fetchRef("tls", "SSL profile", ref)
+	if profile == nil {
+		return
+	}
+
+	versions := map[string]bool{}
+	accepted, _ := profile["accepted_versions"].([]interface{})
+	for _, item := range accepted {
+		if v, ok := item.(map[string]interface{}); ok {
+			if t, ok := v["type"].(string); ok {
+				versions[t] = true
+			}
+		}
+	}
+	var legacy []string
+	for _, v := range []string{"SSL_VERSION_SSLV3", "SSL_VERSION_TLS1", "SSL_VERSION_TLS1_1"} {
+		if versions[v] {
+			legacy = append(legacy, strings.TrimPrefix(v, "SSL_VERSION_"))
+		}
+	}
+	switch {
+	case versions["SSL_VERSION_SSLV3"]:
+		a.add("tls", SeverityCritical, fmt.Sprintf("Obsolete protocol versions are accepted: %s.", strings.Join(legacy, ", ")),
+			"Remove SSLv3, TLS 1.0 and TLS 1.1 from the SSL profile's accepted versions.")
+	case len(legacy) > 0:
+		a.add("tls", SeverityHigh, fmt.Sprintf("Deprecated protocol versions are accepted: %s.", strings.Join(legacy, ", ")),
+			"Remove TLS 1.0 and TLS 1.1 from the SSL profile's accepted versions.")
+	default:
+		a.pass("Only TLS 1.2 or newer is accepted")
+	}
+	if len(versions) > 0 && !versions["SSL_VERSION_TLS1_3"] {
+		a.add("tls", SeverityLow, "TLS 1.3 is not enabled.", "Add TLS 1.3 to the SSL profile's accepted versions.")
+	}
+
+	ciphers, _ := profile["accepted_ciphers"].(string)
+	var weak []string
+	for _, token := range weakCipherTokens {
+		if containsCipher(ciphers, token) {
+			weak = append(weak, token)
+		}
+	}
+	if len(weak) > 0 {
+		a.add("tls", SeverityHigh, fmt.Sprintf("Weak ciphers are allowed: %s.", strings.Join(weak, ", ")),
+			"Restrict accepted_ciphers to AEAD suites such as ECDHE-ECDSA-AES256-GCM-SHA384:ECDHE-RSA-AES256-GCM-SHA384 or use System-Standard.")
+	} else if ciphers != "" {
+		a.pass("No broken ciphers are allowed")
+	}
+
+	if preferred, ok := profile["prefer_client_cipher_ordering"].(bool); ok && preferred {
+		a.add("tls", SeverityLow, "The client's cipher order is preferred over the server's.",
+			"Disable prefer_client_cipher_ordering so the strongest server-side cipher is negotiated.")
+	}
+}
+
+// containsCipher reports whether an OpenSSL cipher string enables a token (tokens prefixed with ! or - are excluded)
+func containsCipher(ciphers, token string) bool {
+	for _, part := range strings.FieldsFunc(ciphers, func(r rune) bool { return r == ':' || r == ',' || r == ' ' }) {
+		if strings.HasPrefix(part, "!") || strings.HasPrefix(part, "-") {
+			continue
+		}
+		if strings.Contains(strings.ToUpper(part), strings.ToUpper(token)) {
+			return true
+		}
+	}
+	return false
+}
+
+// auditCertificates checks expiry, key strength, signature algorithm and chain of the VS certificates
+func (a *securityAudit) auditCertificates(vs map[string]interface{}) {
+	refs := stringList(vs["ssl_key_and_certificate_refs"])
+	if len(refs) == 0 {
+		a.add("certificate", SeverityHigh, "No certificate is attached to the virtual service.",
+			"Attach an SSL key and certificate issued by a trusted CA.")
+		return
+	}
+
+	for _, ref := range refs {
+		cert := a.fetchRef("certificate", "certificate", ref)
+		if cert == nil {
+			continue
+		}
+		name, _ := cert["name"].(string)
+		details, _ := cert["certificate"].(map[string]interface{})
+
+		switch status, _ := details["expiry_status"].(string); status {
+		case "SSL_CERTIFICATE_EXPIRED":
+			a.add("certificate", SeverityCritical, fmt.Sprintf("Certificate %s has expired.", name),
+				"Renew the certificate and update the SSL key and certificate object.")
+		case "SSL_CERTIFICATE_EXPIRY_WARNING":
+			a.add("certificate", SeverityMedium, fmt.Sprintf("Certificate %s expires soon (%v).", name, details["not_after"]),
+				"Renew the certificate before it expires, or enable automatic renewal (ACME or a certificate management profile).")
+		default:
+			if notAfter, ok := details["not_after"].(string); ok {
+				if t, err := time.Parse("2006-01-02 15:04:05", notAfter); err == nil && time.Until(t) < 30*24*time.Hour {
+					a.add("certificate", SeverityMedium, fmt.Sprintf("Certificate %s expires on %s.", name, notAfter),
+						"Renew the certificate before it expires.")
+					break
+				}
+			}
+			a.pass(fmt.Sprintf("Certificate %s is not close to expiry", name))
+		}
+
+		if sig, _ := details["signature_algorithm"].(string); strings.Contains(strings.ToLower(sig), "sha1") || strings.Contains(strings.ToLower(sig), "md5") {
+			a.add("certificate", SeverityHigh, fmt.Sprintf("Certificate %s uses a weak signature algorithm (%s).", name, sig),
+				"Reissue the certificate with a SHA-256 or stronger signature.")
+		}
+
+		if keyParams, ok := cert["key_params"].(map[string]interface{}); ok {
+			if rsa, ok := keyParams["rsa_params"].(map[string]interface{}); ok {
+				size, _ := rsa["key_size"].(string)
+				if size == "SSL_KEY_1024_BITS" {
+					a.add("certificate", SeverityHigh, fmt.Sprintf("Certificate %s uses a 1024-bit RSA key.", name),
+						"Reissue the certificate with an RSA 2048-bit or larger key, or an ECDSA key.")
+				}
+			}
+		}
+
+		selfSigned, _ := details["self_signed"].(bool)
+		chain, _ := cert["ca_certs"].([]interface{})
+		switch {
+		case selfSigned:
+			a.add("certificate", SeverityMedium, fmt.Sprintf("Certificate %s is self-signed, clients will not trust it.", name),
+				"Replace it with a certificate issued by a trusted CA.")
+		case len(chain) == 0:
+			a.add("certificate", SeverityLow, fmt.Sprintf("No intermediate CA certificates are linked to %s, the chain may be incomplete.", name),
+				"Import the issuing CA certificates so Avi can send the full chain.")
+		default:
+			a.pass(fmt.Sprintf("Certificate %s has its CA chain linked", name))
+		}
+	}
+}
+
+// auditHeaders checks HSTS and cookie settings in the application profile and security headers in HTTP policies
+func (a *securityAudit) auditHeaders(vs map[string]interface{}) {
+	headers := map[string]bool{}
+
+	if ref, _ := vs["application_profile_ref"].(string); ref != "" {
+		if profile := a.fetchRef("headers", "application profile", ref); profile != nil {
+			if httpProfile, ok := profile["http_profile"].(map[string]interface{}); ok {
+				if hsts, _ := httpProfile["hsts_enabled"].(bool); hsts {
+					headers["Strict-Transport-Security"] = true
+				}
+				if redirect, _ := httpProfile["http_to_https"].(bool); !redirect {
+					a.add("headers", SeverityLow, "HTTP requests are not redirected to HTTPS by the application profile.",
+						"Enable http_to_https in the application profile's HTTP settings.")
+				}
+				if secure, _ := httpProfile["secure_cookie_enabled"].(bool); !secure {
+					a.add("headers", SeverityLow, "Cookies inserted by Avi are not marked Secure.",
+						"Enable secure_cookie_enabled in the application profile's HTTP settings.")
+				}
+			}
+		}
+	}
+
+	policies, _ := vs["http_policies"].([]interface{})
+	for _, item := range policies {
+		policy, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		ref, _ := policy["http_policy_set_ref"].(string)
+		if ref == "" {
+			continue
+		}
+		if set := a.fetchRef("headers", "HTTP policy set", ref); set != nil {
+			collectResponseHeaders(set, headers)
+		}
+	}
+
+	remediation := map[string]string{
+		"Strict-Transport-Security": "Enable HSTS (hsts_enabled) in the application profile with a max age of at least 365 days.",
+		"X-Frame-Options":           "Add an HTTP response policy that inserts X-Frame-Options: DENY (or SAMEORIGIN).",
+		"X-Content-Type-Options":    "Add an HTTP response policy that inserts X-Content-Type-Options: nosniff.",
+		"Content-Security-Policy":   "Add an HTTP response policy that inserts a Content-Security-Policy suited to the application.",
+	}
+	for _, header := range securityHeaders {
+		if headers[header] {
+			a.pass(header + " is set")
+			continue
+		}
+		severity := SeverityLow
+		if header == "Strict-Transport-Security" {
+			severity = SeverityMedium
+		}
+		a.add("headers", severity, header+" is not set.", remediation[header])
+	}
+}
+
+// collectResponseHeaders records headers added or replaced by the response rules of an HTTP policy set
+func collectResponseHeaders(set map[string]interface{}, headers map[string]bool) {
+	responsePolicy, _ := set["http_response_policy"].(map[string]interface{})
+	rules, _ := responsePolicy["rules"].([]interface{})
+	for _, item := range rules {
+		rule, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		actions, _ := rule["hdr_action"].([]interface{})
+		for _, act := range actions {
+			action, ok := act.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if op, _ := action["action"].(string); op == "HTTP_REMOVE_HDR" {
+				continue
+			}
+			if hdr, ok := action["hdr"].(map[string]interface{}); ok {
+				if name, ok := hdr["name"].(string); ok {
+					for _, header := range securityHeaders {
+						if strings.EqualFold(name, header) {
+							headers[header] = true
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// auditWAF checks that a WAF policy is attached and enforcing
+func (a *securityAudit) auditWAF(vs map[string]interface{}) {
+	ref, _ := vs["waf_policy_ref"].(string)
+	if ref == "" {
+		a.add("waf", SeverityMedium, "No WAF policy is attached.",
+			"Attach a WAF policy (for example one based on System-WAF-Policy) to protect the application against OWASP Top 10 attacks.")
+		return
+	}
+	policy := a.fetchRef("waf", "WAF policy", ref)
+	if policy == nil {
+		return
+	}
+	if mode, _ := policy["mode"].(string); mode == "WAF_MODE_DETECTION_ONLY" {
+		a.add("waf", SeverityLow, "The WAF policy is in detection-only mode, attacks are logged but not blocked.",
+			"Review WAF logs for false positives, then switch the policy to WAF_MODE_ENFORCEMENT.")
+		return
+	}
+	a.pass("WAF policy is attached and enforcing")
+}
+
+// grade computes the score and letter grade and orders findings by severity
+func (a *securityAudit) grade() {
+	order := map[string]int{SeverityCritical: 0, SeverityHigh: 1, SeverityMedium: 2, SeverityLow: 3, SeverityInfo: 4}
+	sort.SliceStable(a.report.Findings, func(i, j int) bool {
+		return order[a.report.Findings[i].Severity] < order[a.report.Findings[j].Severity]
+	})
+
+	score := 100
+	for _, f := range a.report.Findings {
+		score -= severityPenalty[f.Severity]
+	}
+	if score < 0 {
+		score = 0
+	}
+	a.report.Score = score
+
+	switch {
+	case score >= 90:
+		a.report.Grade = "A"
+	case score >= 80:
+		a.report.Grade = "B"
+	case score >= 70:
+		a.report.Grade = "C"
+	case score >= 60:
+		a.report.Grade = "D"
+	default:
+		a.report.Grade = "F"
+	}
+}
@@ -0,0 +1,529 @@
+package avi
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Changes a simulation can evaluate
+const (
+	SimulateRemoveServer        = "remove_server"           // remove a server from its pool
+	SimulateDisableServer       = "disable_server"          // disable a pool server, keeping it configured
+	SimulateDeletePool          = "delete_pool"             // delete a pool
+	SimulateDeleteHealthMonitor = "delete_health_monitor"   // delete a health monitor
+	SimulateDisableVS           = "disable_virtual_service" // disable a virtual service
+	SimulateDisableSE           = "disable_service_engine"  // disable a service engine
+)
+
+// SimulatedChanges lists the changes a simulation can evaluate
+var SimulatedChanges = []string{SimulateRemoveServer, SimulateDisableServer, SimulateDeletePool, SimulateDeleteHealthMonitor, SimulateDisableVS, SimulateDisableSE}
+
+// SimulatedChange is a proposed change to evaluate
+type SimulatedChange struct {
+	Action string `json:"action"`
+	Target string `json:"target"`           // name or UUID of the pool, health monitor, virtual service or service engine; for servers, the pool (optional)
+	Server string `json:"server,omitempty"` // pool server address ("ip" or "ip:port") of server changes
+}
+
+// SimulationReport is the computed outcome of a proposed change; nothing is changed on the controller
+type SimulationReport struct {
+	Change          string          `json:"change"`
+	Target          string          `json:"target"`
+	InventoryTaken  time.Time       `json:"inventory_taken"`
+	Pools           []SimulatedPool `json:"pools,omitempty"`
+	VirtualServices []SimulatedVS   `json:"virtual_services,omitempty"`
+	HealthMonitors  []string        `json:"health_monitors,omitempty"` // health monitors whose probing changes
+	Blockers        []string        `json:"blockers,omitempty"`        // constraints that make the controller reject the change
+	Risks           []string        `json:"risks,omitempty"`
+	Summary         string          `json:"summary"`
+	Assessment      string          `json:"assessment,omitempty"` // the model's reasoning about the computed impact, set by the caller
+}
+
+// SimulatedPool is the capacity of a pool before and after the change
+type SimulatedPool struct {
+	Name            string   `json:"name"`
+	ServersBefore   int      `json:"enabled_servers_before"`
+	ServersAfter    int      `json:"enabled_servers_after"`
+	CapacityPercent float64  `json:"remaining_capacity_percent"` // share of the ratio-weighted capacity of the enabled servers left
+	MinServersUp    int      `json:"min_servers_up,omitempty"`
+	HealthMonitors  []string `json:"health_monitors,omitempty"`
+	VirtualServices []string `json:"virtual_services,omitempty"`
+}
+
+// SimulatedVS is the effect of the change on a virtual service
+type SimulatedVS struct {
+	Name      string `json:"name"`
+	OperState string `json:"oper_state"`
+	Effect    string `json:"effect"`
+	GoesDown  bool   `json:"goes_down"`
+}
+
+// poolUser is a virtual service sending traffic to a pool, directly or through a pool group
+type poolUser struct {
+	name, operState string
+	group           string // pool group the pool is reached through, empty for the default pool
+}
+
+// Simulate computes the outcome of a change from the inventory, without calling the controller
+func (inv *Inventory) Simulate(change SimulatedChange) (*SimulationReport, error) {
+	report := &SimulationReport{Change: change.Action, Target: change.Target, InventoryTaken: inv.Taken}
+	var err error
+	switch change.Action {
+	case SimulateRemoveServer, SimulateDisableServer:
+		err = inv.simulateServer(change, report)
+	case SimulateDeletePool:
+		err = inv.simulateDeletePool(change.Target, report)
+	case SimulateDeleteHealthMonitor:
+		err = inv.simulateDeleteHealthMonitor(change.Target, report)
+	case SimulateDisableVS:
+		err = inv.simulateDisableVS(change.Target, report)
+	case SimulateDisableSE:
+		err = inv.simulateDisableSE(change.Target, report)
+	default:
+		return nil, fmt.Errorf("unknown change %q, expected one of %s", change.Action, strings.Join(SimulatedChanges, ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+	report.Summary += fmt.Sprintf(" Computed from the inventory read at %s; nothing was changed on the controller.", inv.Taken.Format(time.RFC3339))
+	return report, nil
+}
+
+// simulateServer computes the capacity left in the pools of a server that is removed or disabled
+func (inv *Inventory) simulateServer(change SimulatedChange, report *SimulationReport) error {
+	ip, port, err := ParseServerAddress(change.Server)
+	if err != nil {
+		return err
+	}
+	pools := inv.pools
+	if change.Target != "" {
+		pool := findInventoryObject(inv.pools, change.Target)
+		if pool == nil {
+			return fmt.Errorf("pool %s not found", change.Target)
+		}
+		pools = []map[string]interface{}{pool}
+	}
+	verb := "Removing"
+	if change.Action == SimulateDisableServer {
+		verb = "Disabling"
+	}
+
+	var effects []string
+	monitors := map[string]bool{}
+	for _, pool := range pools {
+		name := stringValue(pool["name"])
+		before, after, capacityBefore, capacityAfter, matched := 0, 0, 0.0, 0.0, 0
+		servers, _ := pool["servers"].([]interface{})
+		for _, item := range servers {
+			server, _ := item.(map[string]interface{})
+			enabled := server["enabled"] != false
+			ratio := math.Max(numberValue(server["ratio"]), 1)
+			match := serverMatches(pool, server, ip, port)
+			if match {
+				matched++
+			}
+			if enabled {
+				before++
+				capacityBefore += ratio
+				if !match {
+					after++
+					capacityAfter += ratio
+				}
+			}
+		}
+		if matched == 0 {
+			continue
+		}
+
+		simulated := SimulatedPool{
+			Name: name, ServersBefore: before, ServersAfter: after,
+			MinServersUp:   int(numberValue(pool["min_servers_up"])),
+			HealthMonitors: refNames(pool["health_monitor_refs"]),
+		}
+		if capacityBefore > 0 {
+			simulated.CapacityPercent = math.Round(capacityAfter/capacityBefore*1000) / 10
+		}
+		for _, monitor := range simulated.HealthMonitors {
+			monitors[monitor] = true
+		}
+		if before == after {
+			report.Risks = append(report.Risks, fmt.Sprintf("%s is already disabled in pool %s: the change doesn't affect traffic.", change.Server, name))
+		}
+		if matched > 1 {
+			report.Risks = append(report.Risks, fmt.Sprintf("%s matches %d servers of pool %s; give the port to change only one.", change.Server, matched, name))
+		}
+
+		poolDown := after == 0 || after < simulated.MinServersUp
+		for _, user := range inv.poolUsers(stringValue(pool["uuid"])) {
+			simulated.VirtualServices = append(simulated.VirtualServices, user.name)
+			vs := SimulatedVS{Name: user.name, OperState: user.operState}
+			switch {
+			case poolDown && user.group != "":
+				vs.Effect = fmt.Sprintf("pool %s of pool group %s goes down; the group serves from its other pools", name, user.group)
+			case after == 0:
+				vs.Effect, vs.GoesDown = fmt.Sprintf("no enabled server left in pool %s: the virtual service goes down", name), true
+			case after < simulated.MinServersUp:
+				vs.Effect, vs.GoesDown = fmt.Sprintf("pool %s drops below its min_servers_up of %d and is marked down", name, simulated.MinServersUp), true
+			default:
+				vs.Effect = fmt.Sprintf("serves with %d of %d servers of pool %s (%.1f%% of its capacity)", after, before, name, simulated.CapacityPercent)
+			}
+			report.VirtualServices = append(report.VirtualServices, vs)
+		}
+		if after == 1 && before > 1 {
+			report.Risks = append(report.Risks, fmt.Sprintf("Pool %s is left with a single enabled server: no redundancy.", name))
+		}
+		report.Pools = append(report.Pools, simulated)
+		effects = append(effects, fmt.Sprintf("pool %s keeps %d of %d enabled servers (%.1f%% capacity)", name, after, before, simulated.CapacityPercent))
+	}
+	if len(report.Pools) == 0 {
+		if change.Target != "" {
+			return fmt.Errorf("server %s not found in pool %s", change.Server, change.Target)
+		}
+		return fmt.Errorf("server %s not found in any pool", change.Server)
+	}
+	report.HealthMonitors = sortedKeys(monitors)
+	report.Summary = fmt.Sprintf("%s %s: %s.", verb, change.Server, strings.Join(effects, "; "))
+	if len(report.HealthMonitors) > 0 {
+		report.Summary += fmt.Sprintf(" Health monitors %s stop probing it.", strings.Join(report.HealthMonitors, ", "))
+	}
+	report.Summary += downSummary(report.VirtualServices)
+	return nil
+}
+
+// simulateDeletePool finds what references a pool and the health monitors only it uses
+func (inv *Inventory) simulateDeletePool(target string, report *SimulationReport) error {
+	pool := findInventoryObject(inv.pools, target)
+	if pool == nil {
+		return fmt.Errorf("pool %s not found", target)
+	}
+	name, uuid := stringValue(pool["name"]), stringValue(pool["uuid"])
+	simulated := SimulatedPool{Name: name, HealthMonitors: refNames(pool["health_monitor_refs"])}
+	for _, user := range inv.poolUsers(uuid) {
+		simulated.VirtualServices = append(simulated.VirtualServices, user.name)
+		if user.group != "" {
+			report.Blockers = append(report.Blockers, fmt.Sprintf("Pool %s is a member of pool group %s used by virtual service %s.", name, user.group, user.name))
+			report.VirtualServices = append(report.VirtualServices, SimulatedVS{Name: user.name, OperState: user.operState,
+				Effect: fmt.Sprintf("pool group %s loses pool %s once it's removed from the group", user.group, name)})
+			continue
+		}
+		report.Blockers = append(report.Blockers, fmt.Sprintf("Pool %s is the default pool of virtual service %s.", name, user.name))
+		report.VirtualServices = append(report.VirtualServices, SimulatedVS{Name: user.name, OperState: user.operState,
+			Effect: "needs another pool (or none) before the pool can be deleted; without a pool it stops serving", GoesDown: true})
+	}
+	for _, group := range inv.poolGroups {
+		if groupHasPool(group, uuid) && len(inv.poolUsersOfGroup(group)) == 0 {
+			report.Blockers = append(report.Blockers, fmt.Sprintf("Pool %s is a member of pool group %s.", name, stringValue(group["name"])))
+		}
+	}
+	for _, monitor := range simulated.HealthMonitors {
+		if !inv.monitorUsedElsewhere(monitor, uuid) {
+			report.HealthMonitors = append(report.HealthMonitors, monitor)
+		}
+	}
+	report.Pools = []SimulatedPool{simulated}
+
+	if len(report.Blockers) > 0 {
+		report.Summary = fmt.Sprintf("The controller rejects deleting pool %s while it is referenced (%d references); remove the references first.", name, len(report.Blockers))
+	} else {
+		report.Summary = fmt.Sprintf("Pool %s isn't used by any virtual service and can be deleted.", name)
+	}
+	if len(report.HealthMonitors) > 0 {
+		report.Summary += fmt.Sprintf(" Health monitors %s would no longer be used.", strings.Join(report.HealthMonitors, ", "))
+	}
+	return nil
+}
+
+// simulateDeleteHealthMonitor finds the pools a health monitor probes and those left unmonitored
+func (inv *Inventory) simulateDeleteHealthMonitor(target string, report *SimulationReport) error {
+	monitor := findInventoryObject(inv.healthMonitors, target)
+	if monitor == nil {
+		return fmt.Errorf("health monitor %s not found", target)
+	}
+	name, uuid := stringValue(monitor["name"]), stringValue(monitor["uuid"])
+	report.HealthMonitors = []string{name}
+	if strings.HasPrefix(name, "System-") {
+		report.Risks = append(report.Risks, fmt.Sprintf("%s is a system default health monitor; other objects may rely on it.", name))
+	}
+	unmonitored := 0
+	for _, pool := range inv.pools {
+		refs, _ := pool["health_monitor_refs"].([]interface{})
+		attached := false
+		for _, ref := range refs {
+			if refUUID(stringValue(ref)) == uuid {
+				attached = true
+			}
+		}
+		if !attached {
+			continue
+		}
+		poolName := stringValue(pool["name"])
+		simulated := SimulatedPool{Name: poolName, HealthMonitors: refNames(refs)}
+		report.Blockers = append(report.Blockers, fmt.Sprintf("Health monitor %s is attached to pool %s.", name, poolName))
+		if len(refs) == 1 {
+			unmonitored++
+			report.Risks = append(report.Risks, fmt.Sprintf("Once detached, pool %s has no health monitor: failed servers keep receiving traffic.", poolName))
+		}
+		for _, user := range inv.poolUsers(stringValue(pool["uuid"])) {
+			simulated.VirtualServices = append(simulated.VirtualServices, user.name)
+			effect := fmt.Sprintf("pool %s keeps its other health monitors", poolName)
+			if len(refs) == 1 {
+				effect = fmt.Sprintf("servers of pool %s are no longer probed and are considered up", poolName)
+			}
+			report.VirtualServices = append(report.VirtualServices, SimulatedVS{Name: user.name, OperState: user.operState, Effect: effect})
+		}
+		report.Pools = append(report.Pools, simulated)
+	}
+
+	if len(report.Pools) == 0 {
+		report.Summary = fmt.Sprintf("Health monitor %s isn't attached to any pool and can be deleted.", name)
+		return nil
+	}
+	report.Summary = fmt.Sprintf("The controller rejects deleting health monitor %s while it is attached to %d pools; detach it first.", name, len(report.Pools))
+	if unmonitored > 0 {
+		report.Summary += fmt.Sprintf(" %d of them would be left without a health monitor.", unmonitored)
+	}
+	return nil
+}
+
+// simulateDisableVS finds the VIPs a virtual service stops answering on and the pools it leaves idle
+func (inv *Inventory) simulateDisableVS(target string, report *SimulationReport) error {
+	item, config := inv.findVirtualService(target)
+	if item == nil {
+		return fmt.Errorf("virtual service %s not found", target)
+	}
+	name := stringValue(config["name"])
+	summary := summarizeInventoryObject(item)
+	if config["enabled"] == false {
+		report.Summary = fmt.Sprintf("Virtual service %s is already disabled: disabling it changes nothing.", name)
+		return nil
+	}
+
+	addresses := vsAddresses(config)
+	report.VirtualServices = []SimulatedVS{{Name: name, OperState: summary.OperState, GoesDown: true,
+		Effect: fmt.Sprintf("stops answering on %s; client connections are closed", strings.Join(addresses, ", "))}}
+	for _, pool := range inv.pools {
+		users := inv.poolUsers(stringValue(pool["uuid"]))
+		for _, user := range users {
+			if user.name != name {
+				continue
+			}
+			simulated := SimulatedPool{Name: stringValue(pool["name"]), HealthMonitors: refNames(pool["health_monitor_refs"])}
+			for _, other := range users {
+				simulated.VirtualServices = append(simulated.VirtualServices, other.name)
+			}
+			if len(users) == 1 {
+				report.Risks = append(report.Risks, fmt.Sprintf("Pool %s receives no traffic while %s is disabled.", simulated.Name, name))
+			}
+			report.Pools = append(report.Pools, simulated)
+			break
+		}
+	}
+	report.Summary = fmt.Sprintf("Disabling virtual service %s stops traffic on %s.", name, strings.Join(addresses, ", "))
+	if len(report.Pools) > 0 {
+		report.Summary += " Its pools keep their servers and health monitoring."
+	}
+	return nil
+}
+
+// simulateDisableSE finds the virtual services placed on a service engine and where they can go
+func (inv *Inventory) simulateDisableSE(target string, report *SimulationReport) error {
+	se := findInventoryObject(inv.serviceEngines, target)
+	if se == nil {
+		return fmt.Errorf("service engine %s not found", target)
+	}
+	name, uuid, group := stringValue(se["name"]), stringValue(se["uuid"]), stringValue(se["se_group_ref"])
+	if valueOrDefault(stringValue(se["enable_state"]), seStateEnabled) != seStateEnabled {
+		report.Summary = fmt.Sprintf("Service engine %s is already out of service.", name)
+		return nil
+	}
+	names := map[string]string{}
+	var others []string
+	for _, engine := range inv.serviceEngines {
+		names[stringValue(engine["uuid"])] = stringValue(engine["name"])
+		if stringValue(engine["uuid"]) != uuid && refUUID(stringValue(engine["se_group_ref"])) == refUUID(group) &&
+			valueOrDefault(stringValue(engine["enable_state"]), seStateEnabled) == seStateEnabled {
+			others = append(others, stringValue(engine["name"]))
+		}
+	}
+
+	for _, item := range inv.virtualServices {
+		config, _ := item["config"].(map[string]interface{})
+		if config["enabled"] == false {
+			continue
+		}
+		placed, onEngine := []string{}, false
+		for _, engine := range placementEngines(item) {
+			if engine == uuid {
+				onEngine = true
+			} else {
+				placed = append(placed, valueOrDefault(names[engine], engine))
+			}
+		}
+		if !onEngine {
+			continue
+		}
+		vs := SimulatedVS{Name: stringValue(config["name"]), OperState: summarizeInventoryObject(item).OperState}
+		switch {
+		case len(placed) > 0:
+			vs.Effect = fmt.Sprintf("keeps serving from %s while it is placed again", strings.Join(placed, ", "))
+		case len(others) > 0:
+			vs.Effect = fmt.Sprintf("migrates to another service engine of group %s (%s); connections on %s are reset", refName(group), strings.Join(others, ", "), name)
+		default:
+			vs.Effect, vs.GoesDown = fmt.Sprintf("no other enabled service engine in group %s: the virtual service goes down", refName(group)), true
+		}
+		report.VirtualServices = append(report.VirtualServices, vs)
+	}
+
+	if len(others) == 0 && len(report.VirtualServices) > 0 {
+		report.Risks = append(report.Risks, fmt.Sprintf("No other enabled service engine in group %s; use service engine maintenance after adding capacity.", refName(group)))
+	} else if len(others) == 1 {
+		report.Risks = append(report.Risks, fmt.Sprintf("Only %s is left in group %s: no redundancy until %s is enabled again.", others[0], refName(group), name))
+	}
+	report.Summary = fmt.Sprintf("Disabling service engine %s affects %d virtual services; enabled service engines left in group %s: %d.",
+		name, len(report.VirtualServices), refName(group), len(others))
+	report.Summary += downSummary(report.VirtualServices)
+	return nil
+}
+
+// poolUsers returns the enabled virtual services sending traffic to a pool, sorted by name
+func (inv *Inventory) poolUsers(poolUUID string) []poolUser {
+	var users []poolUser
+	for _, item := range inv.virtualServices {
+		config, _ := item["config"].(map[string]interface{})
+		if config["enabled"] == false {
+			continue
+		}
+		user := poolUser{name: stringValue(config["name"]), operState: summarizeInventoryObject(item).OperState}
+		if refUUID(stringValue(config["pool_ref"])) == poolUUID {
+			users = append(users, user)
+			continue
+		}
+		groupUUID := refUUID(stringValue(config["pool_group_ref"]))
+		for _, group := range inv.poolGroups {
+			if groupUUID != "" && stringValue(group["uuid"]) == groupUUID && groupHasPool(group, poolUUID) {
+				user.group = stringValue(group["name"])
+				users = append(users, user)
+			}
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].name < users[j].name })
+	return users
+}
+
+// poolUsersOfGroup returns the names of the virtual services using a pool group
+func (inv *Inventory) poolUsersOfGroup(group map[string]interface{}) []string {
+	var names []string
+	for _, item := range inv.virtualServices {
+		config, _ := item["config"].(map[string]interface{})
+		if refUUID(stringValue(config["pool_group_ref"])) == stringValue(group["uuid"]) {
+			names = append(names, stringValue(config["name"]))
+		}
+	}
+	return names
+}
+
+// monitorUsedElsewhere reports whether a health monitor, by name, is attached to a pool other than poolUUID
+func (inv *Inventory) monitorUsedElsewhere(monitor, poolUUID string) bool {
+	for _, pool := range inv.pools {
+		if stringValue(pool["uuid"]) == poolUUID {
+			continue
+		}
+		for _, name := range refNames(pool["health_monitor_refs"]) {
+			if name == monitor {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// serverMatches reports whether a pool server has the address ip (and port, when non-zero)
+func serverMatches(pool, server map[string]interface{}, ip string, port int) bool {
+	addr := ""
+	if ipObj, ok := server["ip"].(map[string]interface{}); ok {
+		addr = stringValue(ipObj["addr"])
+	}
+	if !sameIP(addr, ip) && stringValue(server["hostname"]) != ip {
+		return false
+	}
+	if port == 0 {
+		return true
+	}
+	serverPort := int(numberValue(server["port"]))
+	if serverPort == 0 {
+		serverPort = int(numberValue(pool["default_server_port"]))
+	}
+	return serverPort == port
+}
+
+// groupHasPool reports whether a pool group has the pool as a member
+func groupHasPool(group map[string]interface{}, poolUUID string) bool {
+	members, _ := group["members"].([]interface{})
+	for _, m := range members {
+		member, _ := m.(map[string]interface{})
+		if refUUID(stringValue(member["pool_ref"])) == poolUUID {
+			return true
+		}
+	}
+	return false
+}
+
+// vsAddresses returns the VIP addresses and service ports of a virtual service, e.g. 10.0.0.1:443
+func vsAddresses(config map[string]interface{}) []string {
+	var ports []string
+	services, _ := config["services"].([]interface{})
+	for _, s := range services {
+		service, _ := s.(map[string]interface{})
+		ports = append(ports, fmt.Sprint(numberValue(service["port"])))
+	}
+	var addresses []string
+	vips, _ := config["vip"].([]interface{})
+	for _, v := range vips {
+		vip, _ := v.(map[string]interface{})
+		ip, _ := vip["ip_address"].(map[string]interface{})
+		if addr := stringValue(ip["addr"]); addr != "" {
+			addresses = append(addresses, hostForURL(addr)+":"+strings.Join(ports, ","))
+		}
+	}
+	if len(addresses) == 0 {
+		return []string{"ports " + strings.Join(ports, ",")}
+	}
+	return addresses
+}
+
+// refNames returns the object names of a list of references
+func refNames(v interface{}) []string {
+	refs, _ := v.([]interface{})
+	var names []string
+	for _, ref := range refs {
+		names = append(names, refName(stringValue(ref)))
+	}
+	return names
+}
+
+// downSummary names the virtual services the change takes down
+func downSummary(list []SimulatedVS) string {
+	var down []string
+	for _, vs := range list {
+		if vs.GoesDown {
+			down = append(down, vs.Name)
+		}
+	}
+	if len(down) == 0 {
+		return " No virtual service goes down."
+	}
+	return fmt.Sprintf(" Goes down: %s.", strings.Join(down, ", "))
+}
+
+// sortedKeys returns the keys of a set in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
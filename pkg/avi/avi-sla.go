@@ -0,0 +1,308 @@
+package avi
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultSLATarget is the availability target, in percent, when none is given
+const DefaultSLATarget = 99.9
+
+// degradedHealthScore is the health score below which a virtual service counts as degraded
+const degradedHealthScore = 85
+
+// Event IDs of virtual service oper-state transitions
+const (
+	eventVSDown = "VS_DOWN"
+	eventVSUp   = "VS_UP"
+)
+
+// AvailabilityReport is the availability of the virtual services over a period, against an SLA
+// target
+type AvailabilityReport struct {
+	Period          string           `json:"period"`
+	Start           string           `json:"start"`
+	End             string           `json:"end"`
+	Target          float64          `json:"target"`           // percent
+	Availability    float64          `json:"availability"`     // percent, over all monitored virtual services
+	Breaches        int              `json:"breaches"`         // virtual services below the target
+	VirtualServices []VSAvailability `json:"virtual_services"` // least available first, disabled ones last
+	Warnings        []string         `json:"warnings,omitempty"`
+}
+
+// VSAvailability is the availability of a virtual service, computed from its VS_DOWN and VS_UP
+// events. Disabled virtual services are listed but not measured.
+type VSAvailability struct {
+	Name                   string  `json:"name"`
+	UUID                   string  `json:"uuid"`
+	State                  string  `json:"state"` // current oper state
+	Disabled               bool    `json:"disabled,omitempty"`
+	Availability           float64 `json:"availability"` // percent
+	DowntimeSeconds        int64   `json:"downtime_seconds"`
+	Outages                int     `json:"outages"`
+	LongestOutageSeconds   int64   `json:"longest_outage_seconds"`
+	BudgetRemainingSeconds int64   `json:"budget_remaining_seconds"` // downtime left before the target is missed, negative once it is
+	Breached               bool    `json:"breached"`
+	HealthAverage          float64 `json:"health_average,omitempty"`
+	HealthLowest           float64 `json:"health_lowest,omitempty"`
+	DegradedPercent        float64 `json:"degraded_percent"` // share of health samples below 85
+}
+
+// stateEvent is an oper-state transition of a virtual service
+type stateEvent struct {
+	down bool
+	at   time.Time
+}
+
+// GetAvailability computes the availability of the virtual services over the period ending now
+// (a time range such as 24h, 7d or 30d) from their oper-state transition events, and their health
+// score over the period. Only the named virtual services are included when names is not empty.
+// Health scores that can't be read are reported as warnings.
+func GetAvailability(ctx context.Context, exec GenericExecutor, period string, target float64, names []string, now time.Time) (*AvailabilityReport, error) {
+	if period == "" {
+		period = "30d"
+	}
+	length, err := parseTimeRange(period)
+	if err != nil {
+		return nil, err
+	}
+	if target <= 0 || target > 100 {
+		target = DefaultSLATarget
+	}
+	end := now.UTC()
+	start := end.Add(-length)
+
+	objects, err := listAllObjects(ctx, exec, "/virtualservice-inventory", map[string]string{"include_name": "true"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read virtual service inventory: %w", err)
+	}
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	events, err := stateEvents(ctx, exec, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &AvailabilityReport{
+		Period:          period,
+		Start:           start.Format(time.RFC3339),
+		End:             end.Format(time.RFC3339),
+		Target:          target,
+		VirtualServices: []VSAvailability{},
+	}
+	step, limit := healthWindow(length)
+	var measured, up time.Duration
+	for _, obj := range objects {
+		summary := summarizeInventoryObject(obj)
+		if len(wanted) > 0 && !wanted[summary.Name] && !wanted[summary.UUID] {
+			continue
+		}
+		vs := VSAvailability{Name: summary.Name, UUID: summary.UUID, State: summary.OperState}
+		config, _ := obj["config"].(map[string]interface{})
+		if enabled, ok := config["enabled"].(bool); (ok && !enabled) || vs.State == "OPER_DISABLED" {
+			vs.Disabled = true
+			report.VirtualServices = append(report.VirtualServices, vs)
+			continue
+		}
+
+		downtime := vs.measure(events[vs.UUID], start, end)
+		vs.Availability = roundPercent(100 * (1 - downtime.Seconds()/length.Seconds()))
+		budget := time.Duration(float64(length) * (100 - target) / 100)
+		vs.BudgetRemainingSeconds = int64((budget - downtime).Seconds())
+		vs.Breached = vs.Availability < target
+		if vs.Breached {
+			report.Breaches++
+		}
+		measured += length
+		up += length - downtime
+
+		raw, err := exec.ExecuteGenericOperation(ctx, "GET", "/analytics/healthscore/virtualservice/"+vs.UUID, nil,
+			map[string]string{"step": strconv.Itoa(step), "limit": strconv.Itoa(limit)})
+		if err != nil {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("health score of %s: %v", vs.Name, err))
+		} else {
+			vs.health(healthScoreValues(raw))
+		}
+		report.VirtualServices = append(report.VirtualServices, vs)
+	}
+	if measured > 0 {
+		report.Availability = roundPercent(100 * up.Seconds() / measured.Seconds())
+	}
+
+	sort.SliceStable(report.VirtualServices, func(i, j int) bool {
+		a, b := report.VirtualServices[i], report.VirtualServices[j]
+		if a.Disabled != b.Disabled {
+			return b.Disabled
+		}
+		return a.Availability < b.Availability
+	})
+	return report, nil
+}
+
+// measure adds up the downtime of the virtual service within the window from its transitions.
+// Without transitions the current state is assumed for the whole window; when the first
+// transition is VS_UP the virtual service was down from the start of the window.
+func (vs *VSAvailability) measure(events []stateEvent, start, end time.Time) time.Duration {
+	down := len(events) == 0 && vs.State != "" && vs.State != "OPER_UP"
+	if len(events) > 0 {
+		down = !events[0].down
+	}
+	var downtime time.Duration
+	downSince := start
+	if down {
+		vs.Outages++
+	}
+	closeOutage := func(at time.Time) {
+		outage := at.Sub(downSince)
+		downtime += outage
+		vs.LongestOutageSeconds = max(vs.LongestOutageSeconds, int64(outage.Seconds()))
+	}
+	for _, event := range events {
+		switch {
+		case event.down && !down:
+			down, downSince = true, event.at
+			vs.Outages++
+		case !event.down && down:
+			closeOutage(event.at)
+			down = false
+		}
+	}
+	if down {
+		closeOutage(end)
+	}
+	vs.DowntimeSeconds = int64(downtime.Seconds())
+	return downtime
+}
+
+// health sets the health statistics from the health scores of the period
+func (vs *VSAvailability) health(scores []float64) {
+	if len(scores) == 0 {
+		return
+	}
+	var sum float64
+	degraded := 0
+	vs.HealthLowest = scores[0]
+	for _, score := range scores {
+		sum += score
+		vs.HealthLowest = math.Min(vs.HealthLowest, score)
+		if score < degradedHealthScore {
+			degraded++
+		}
+	}
+	vs.HealthAverage = math.Round(sum/float64(len(scores))*10) / 10
+	vs.DegradedPercent = roundPercent(100 * float64(degraded) / float64(len(scores)))
+}
+
+// stateEvents reads the VS_DOWN and VS_UP events of the window, by virtual service and in time
+// order
+func stateEvents(ctx context.Context, exec GenericExecutor, start, end time.Time) (map[string][]stateEvent, error) {
+	params := map[string]string{
+		"type":   "2", // event logs
+		"filter": fmt.Sprintf("eq(event_id,[%s,%s])", eventVSDown, eventVSUp),
+		"start":  start.Format(time.RFC3339),
+		"end":    end.Format(time.RFC3339),
+	}
+	logs, err := listAllObjects(ctx, exec, "/analytics/logs", params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read virtual service events: %w", err)
+	}
+
+	events := make(map[string][]stateEvent)
+	for _, log := range logs {
+		id, _ := log["event_id"].(string)
+		uuid, _ := log["obj_uuid"].(string)
+		reported, _ := log["report_timestamp"].(string)
+		at, err := parseAviTime(reported)
+		if uuid == "" || err != nil || (id != eventVSDown && id != eventVSUp) || at.Before(start) || at.After(end) {
+			continue
+		}
+		events[uuid] = append(events[uuid], stateEvent{down: id == eventVSDown, at: at.UTC()})
+	}
+	for _, list := range events {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].at.Before(list[j].at) })
+	}
+	return events, nil
+}
+
+// healthWindow returns the health score step, in seconds, and number of points covering a period
+func healthWindow(length time.Duration) (step, limit int) {
+	switch {
+	case length <= 48*time.Hour:
+		step = 300
+	case length <= 31*24*time.Hour:
+		step = 3600
+	default:
+		step = 86400
+	}
+	return step, max(1, int(length.Seconds())/step)
+}
+
+// healthScoreValues returns the health scores of an /analytics/healthscore response
+func healthScoreValues(response interface{}) []float64 {
+	data, _ := response.(map[string]interface{})
+	series, _ := data["series"].([]interface{})
+	var scores []float64
+	for _, item := range series {
+		s, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		header, _ := s["header"].(map[string]interface{})
+		metricID, _ := header["metric_id"].(string)
+		points, _ := s["data"].([]interface{})
+		for _, p := range points {
+			point, ok := p.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if score, ok := point["health_score"]; ok {
+				scores = append(scores, numberValue(score))
+			} else if strings.HasSuffix(metricID, "health_score_value") {
+				scores = append(scores, numberValue(point["value"]))
+			}
+		}
+	}
+	return scores
+}
+
+// roundPercent rounds a percentage to three decimals, enough to tell 99.95 from 99.9
+func roundPercent(p float64) float64 {
+	return math.Round(p*1000) / 1000
+}
+
+// FormatDuration writes a number of seconds as e.g. 1h 12m, 5m or 45s
+func FormatDuration(seconds int64) string {
+	negative := seconds < 0
+	if negative {
+		seconds = -seconds
+	}
+	d := time.Duration(seconds) * time.Second
+	var major, minor int
+	var units string
+	switch {
+	case d >= 24*time.Hour:
+		major, minor, units = int(d.Hours())/24, int(d.Hours())%24, "dh"
+	case d >= time.Hour:
+		major, minor, units = int(d.Hours()), int(d.Minutes())%60, "hm"
+	case d >= time.Minute:
+		major, minor, units = int(d.Minutes()), int(d.Seconds())%60, "ms"
+	default:
+		major, units = int(d.Seconds()), "s"
+	}
+	s := fmt.Sprintf("%d%c", major, units[0])
+	if minor > 0 {
+		s += fmt.Sprintf(" %d%c", minor, units[1])
+	}
+	if negative {
+		return "-" + s
+	}
+	return s
+}
@@ -0,0 +1,285 @@
+package avi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ControllerNode is a controller cluster member and its runtime state
+type ControllerNode struct {
+	Name    string `json:"name"`
+	IP      string `json:"ip,omitempty"`
+	Role    string `json:"role,omitempty"`
+	State   string `json:"state,omitempty"`
+	UpSince string `json:"up_since,omitempty"`
+}
+
+// ControllerInfo summarizes the controller version, cluster and system configuration
+type ControllerInfo struct {
+	Version            string           `json:"version,omitempty"`
+	Build              string           `json:"build,omitempty"`
+	ClusterName        string           `json:"cluster_name,omitempty"`
+	ClusterVIP         string           `json:"cluster_vip,omitempty"`
+	ClusterState       string           `json:"cluster_state,omitempty"`
+	Nodes              []ControllerNode `json:"nodes,omitempty"`
+	DNSServers         []string         `json:"dns_servers,omitempty"`
+	NTPServers         []string         `json:"ntp_servers,omitempty"`
+	DefaultLicenseTier string           `json:"default_license_tier,omitempty"`
+	Warnings           []string         `json:"warnings,omitempty"` // parts of the system information that couldn't be read
+}
+
+// GetControllerInfo reads the controller version, cluster membership and state, and the
+// system configuration. Sections that can't be read are reported as warnings.
+func GetControllerInfo(ctx context.Context, exec GenericExecutor) (*ControllerInfo, error) {
+	info := &ControllerInfo{}
+
+	initial, err := getObject(ctx, exec, "/initial-data")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read controller version: %w", err)
+	}
+	if version, ok := initial["version"].(map[string]interface{}); ok {
+		info.Version, _ = version["Version"].(string)
+		if build, ok := version["build"]; ok {
+			info.Build = fmt.Sprintf("%v", build)
+		}
+	}
+
+	if cluster, err := getObject(ctx, exec, "/cluster"); err != nil {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("cluster configuration: %v", err))
+	} else {
+		info.ClusterName, _ = cluster["name"].(string)
+		info.ClusterVIP = addrString(cluster["virtual_ip"])
+		nodes, _ := cluster["nodes"].([]interface{})
+		for _, item := range nodes {
+			if n, ok := item.(map[string]interface{}); ok {
+				node := ControllerNode{IP: addrString(n["ip"])}
+				node.Name, _ = n["name"].(string)
+				info.Nodes = append(info.Nodes, node)
+			}
+		}
+	}
+
+	if runtime, err := getObject(ctx, exec, "/cluster/runtime"); err != nil {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("cluster runtime: %v", err))
+	} else {
+		if state, ok := runtime["cluster_state"].(map[string]interface{}); ok {
+			info.ClusterState, _ = state["state"].(string)
+		}
+		states, _ := runtime["node_states"].([]interface{})
+		for _, item := range states {
+			s, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := s["name"].(string)
+			node := info.node(name)
+			node.Role, _ = s["role"].(string)
+			node.State, _ = s["state"].(string)
+			node.UpSince, _ = s["up_since"].(string)
+		}
+	}
+
+	if system, err := getObject(ctx, exec, "/systemconfiguration"); err != nil {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("system configuration: %v", err))
+	} else {
+		info.DefaultLicenseTier, _ = system["default_license_tier"].(string)
+		if dns, ok := system["dns_configuration"].(map[string]interface{}); ok {
+			servers, _ := dns["server_list"].([]interface{})
+			for _, server := range servers {
+				if addr := addrString(server); addr != "" {
+					info.DNSServers = append(info.DNSServers, addr)
+				}
+			}
+		}
+		if ntp, ok := system["ntp_configuration"].(map[string]interface{}); ok {
+			servers, _ := ntp["ntp_servers"].([]interface{})
+			for _, item := range servers {
+				if server, ok := item.(map[string]interface{}); ok {
+					if addr := addrString(server["server"]); addr != "" {
+						info.NTPServers = append(info.NTPServers, addr)
+					}
+				}
+			}
+		}
+	}
+	return info, nil
+}
+
+// node returns the cluster node with the given name or IP, adding it when it isn't known
+func (info *ControllerInfo) node(name string) *ControllerNode {
+	for i := range info.Nodes {
+		if info.Nodes[i].Name == name || info.Nodes[i].IP == name {
+			return &info.Nodes[i]
+		}
+	}
+	info.Nodes = append(info.Nodes, ControllerNode{Name: name})
+	return &info.Nodes[len(info.Nodes)-1]
+}
+
+// activeUpgradeStates are the upgrade FSM states of an upgrade that is still running
+var activeUpgradeStates = map[string]bool{
+	"UPGRADE_FSM_INIT":                   true,
+	"UPGRADE_FSM_STARTED":                true,
+	"UPGRADE_FSM_WAITING":                true,
+	"UPGRADE_FSM_IN_PROGRESS":            true,
+	"UPGRADE_FSM_ENQUEUED":               true,
+	"UPGRADE_FSM_SE_UPGRADE_IN_PROGRESS": true,
+	"UPGRADE_FSM_ABORT_IN_PROGRESS":      true,
+}
+
+// UpgradeNode is the upgrade state of the controller cluster or a service engine group
+type UpgradeNode struct {
+	Name            string  `json:"name"`
+	NodeType        string  `json:"node_type,omitempty"`
+	Operation       string  `json:"operation,omitempty"`
+	State           string  `json:"state"`
+	Version         string  `json:"version,omitempty"`
+	PreviousVersion string  `json:"previous_version,omitempty"`
+	Progress        float64 `json:"progress"`
+	StartTime       string  `json:"start_time,omitempty"`
+	EndTime         string  `json:"end_time,omitempty"`
+	Reason          string  `json:"reason,omitempty"`
+}
+
+// UpgradeStatus reports whether an upgrade is in progress anywhere in the system
+type UpgradeStatus struct {
+	InProgress bool          `json:"in_progress"`
+	Active     []string      `json:"active,omitempty"`    // nodes with a running upgrade
+	Attention  []string      `json:"attention,omitempty"` // nodes whose upgrade failed, was paused or suspended
+	Nodes      []UpgradeNode `json:"nodes"`
+}
+
+// GetUpgradeStatus reads /upgradestatusinfo for the controller cluster and service engine groups
+func GetUpgradeStatus(ctx context.Context, exec GenericExecutor) (*UpgradeStatus, error) {
+	raw, err := getObject(ctx, exec, "/upgradestatusinfo")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upgrade status: %w", err)
+	}
+	return SummarizeUpgradeStatus(raw), nil
+}
+
+// SummarizeUpgradeStatus converts an /upgradestatusinfo collection into an upgrade status
+func SummarizeUpgradeStatus(collection map[string]interface{}) *UpgradeStatus {
+	status := &UpgradeStatus{Nodes: []UpgradeNode{}}
+	results, _ := collection["results"].([]interface{})
+	for _, item := range results {
+		u, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		node := UpgradeNode{Progress: numberValue(u["progress"])}
+		node.Name, _ = u["name"].(string)
+		node.NodeType, _ = u["node_type"].(string)
+		node.Operation, _ = u["upgrade_ops"].(string)
+		node.Version, _ = u["version"].(string)
+		node.PreviousVersion, _ = u["previous_version"].(string)
+		node.StartTime, _ = u["start_time"].(string)
+		node.EndTime, _ = u["end_time"].(string)
+		if state, ok := u["state"].(map[string]interface{}); ok {
+			node.State, _ = state["state"].(string)
+			node.Reason, _ = state["reason"].(string)
+		}
+
+		switch {
+		case activeUpgradeStates[node.State]:
+			status.InProgress = true
+			status.Active = append(status.Active, node.Name)
+		case strings.Contains(node.State, "ERROR"), strings.Contains(node.State, "FAILED"),
+			strings.HasSuffix(node.State, "PAUSED"), strings.HasSuffix(node.State, "SUSPENDED"):
+			status.Attention = append(status.Attention, node.Name)
+		}
+		status.Nodes = append(status.Nodes, node)
+	}
+	return status
+}
+
+// License is a single license installed on the controller
+type License struct {
+	ID           string  `json:"id"`
+	Name         string  `json:"name,omitempty"`
+	Tier         string  `json:"tier,omitempty"`
+	Cores        float64 `json:"cores,omitempty"`
+	ServiceCores float64 `json:"service_cores,omitempty"`
+	Sockets      float64 `json:"sockets,omitempty"`
+	ValidUntil   string  `json:"valid_until,omitempty"`
+	Expired      bool    `json:"expired"`
+}
+
+// LicenseSummary reports the installed license capacity and how much of it is in use
+type LicenseSummary struct {
+	Licenses       []License `json:"licenses"`
+	TotalCores     float64   `json:"total_cores"`
+	UsedCores      float64   `json:"used_cores"`
+	AvailableCores float64   `json:"available_cores"`
+	UsagePercent   float64   `json:"usage_percent"`
+	Warnings       []string  `json:"warnings,omitempty"`
+}
+
+// GetLicenseSummary reads the installed licenses from /license and the consumed capacity from
+// /licenseusage. Expired licenses don't count towards the capacity.
+func GetLicenseSummary(ctx context.Context, exec GenericExecutor) (*LicenseSummary, error) {
+	raw, err := getObject(ctx, exec, "/license")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read licenses: %w", err)
+	}
+	summary := SummarizeLicenses(raw, time.Now())
+
+	if usage, err := getObject(ctx, exec, "/licenseusage"); err != nil {
+		summary.Warnings = append(summary.Warnings, fmt.Sprintf("license usage: %v", err))
+	} else {
+		summary.UsedCores = numberValue(usage["num_se_vcpus"])
+		if licensed := numberValue(usage["licensed_cores"]); licensed > 0 {
+			summary.TotalCores = licensed
+		}
+	}
+
+	summary.AvailableCores = summary.TotalCores - summary.UsedCores
+	if summary.TotalCores > 0 {
+		summary.UsagePercent = float64(int(summary.UsedCores/summary.TotalCores*1000)) / 10
+	}
+	if summary.AvailableCores < 0 {
+		summary.Warnings = append(summary.Warnings, fmt.Sprintf("license capacity exceeded by %.0f cores", -summary.AvailableCores))
+	}
+	return summary, nil
+}
+
+// SummarizeLicenses lists the licenses of a /license response and totals their unexpired cores
+func SummarizeLicenses(raw map[string]interface{}, now time.Time) *LicenseSummary {
+	summary := &LicenseSummary{Licenses: []License{}}
+	licenses, _ := raw["licenses"].([]interface{})
+	for _, item := range licenses {
+		l, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		license := License{
+			Cores:        numberValue(l["cores"]),
+			ServiceCores: numberValue(l["service_cores"]),
+			Sockets:      numberValue(l["sockets"]),
+		}
+		license.ID, _ = l["license_id"].(string)
+		license.Name, _ = l["license_name"].(string)
+		license.Tier, _ = l["tier_type"].(string)
+		license.ValidUntil, _ = l["valid_until"].(string)
+		if expiry, err := parseAviTime(license.ValidUntil); err == nil && expiry.Before(now) {
+			license.Expired = true
+		}
+		if !license.Expired {
+			summary.TotalCores += license.Cores
+		}
+		summary.Licenses = append(summary.Licenses, license)
+	}
+	return summary
+}
+
+// parseAviTime parses the timestamp formats used in Avi objects
+func parseAviTime(value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time %q", value)
+}
@@ -0,0 +1,171 @@
+package avi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TechSupportLevels are the tech-support collection levels, with whether they collect for an
+// object given by UUID
+var TechSupportLevels = map[string]bool{
+	"controller":     false,
+	"clustering":     false,
+	"debuglogs":      false,
+	"upgrade":        false,
+	"gslb":           false,
+	"serviceengine":  true,
+	"virtualservice": true,
+	"pool":           true,
+}
+
+// techSupportPollInterval is how often the bundle list is checked while a collection runs
+var techSupportPollInterval = 5 * time.Second
+
+// TechSupportRequest asks the controller to collect a tech-support bundle
+type TechSupportRequest struct {
+	Level       string
+	UUID        string // object to collect for, required by the object levels
+	CaseNumber  string // support case the bundle is attached to
+	Description string
+}
+
+// TechSupportBundle is a tech-support bundle stored on the controller
+type TechSupportBundle struct {
+	Name        string `json:"name"`
+	Level       string `json:"level,omitempty"`
+	Size        int64  `json:"size,omitempty"`
+	Created     string `json:"created,omitempty"`
+	DownloadURL string `json:"download_url"`
+}
+
+// TechSupportStatus is the outcome of a collection that didn't finish while it was awaited
+type TechSupportStatus struct {
+	Level   string `json:"level"`
+	State   string `json:"state"` // in_progress
+	Message string `json:"message"`
+}
+
+// techSupportParams are the query parameters of a bundle download
+func techSupportParams(name string) map[string]string {
+	return map[string]string{"uri": "controller://tech_support/" + name}
+}
+
+// ListTechSupportBundles returns the tech-support bundles on the controller, newest first
+func ListTechSupportBundles(ctx context.Context, exec GenericExecutor) ([]TechSupportBundle, error) {
+	raw, err := exec.ExecuteGenericOperation(ctx, "GET", "/techsupport", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tech-support bundles: %w", err)
+	}
+	collection, _ := raw.(map[string]interface{})
+	results, _ := collection["results"].([]interface{})
+
+	bundles := make([]TechSupportBundle, 0, len(results))
+	for _, item := range results {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name := baseName(stringValue(entry["name"]))
+		if name == "" {
+			continue
+		}
+		size, _ := entry["size"].(float64)
+		bundles = append(bundles, TechSupportBundle{
+			Name:        name,
+			Level:       stringValue(entry["level"]),
+			Size:        int64(size),
+			Created:     stringValue(entry["start_time"]),
+			DownloadURL: ProxyDownloadURL("/fileservice", techSupportParams(name)),
+		})
+	}
+	sort.SliceStable(bundles, func(i, j int) bool { return bundles[i].Created > bundles[j].Created })
+	return bundles, nil
+}
+
+// CollectTechSupport starts a tech-support collection and waits up to wait for the new bundle to
+// appear in the bundle list. A finished collection is returned as a *FileResult the operator
+// downloads through the API proxy; one still running as a *TechSupportStatus, as collections can
+// take several minutes.
+func CollectTechSupport(ctx context.Context, exec GenericExecutor, req TechSupportRequest, wait time.Duration) (interface{}, error) {
+	needsUUID, ok := TechSupportLevels[req.Level]
+	if !ok {
+		return nil, fmt.Errorf("unknown tech-support level %q, expected one of %s", req.Level, strings.Join(techSupportLevelNames(), ", "))
+	}
+	if needsUUID && req.UUID == "" {
+		return nil, fmt.Errorf("uuid parameter required for %s tech-support", req.Level)
+	}
+
+	existing, err := ListTechSupportBundles(ctx, exec)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(existing))
+	for _, bundle := range existing {
+		known[bundle.Name] = true
+	}
+
+	params := map[string]string{}
+	if req.UUID != "" {
+		params["uuid"] = req.UUID
+	}
+	if req.CaseNumber != "" {
+		params["case_number"] = req.CaseNumber
+	}
+	if req.Description != "" {
+		params["description"] = req.Description
+	}
+	if _, err := exec.ExecuteGenericOperation(ctx, "GET", "/techsupport/"+req.Level, nil, params); err != nil {
+		return nil, fmt.Errorf("failed to start tech-support collection: %w", err)
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		bundles, err := ListTechSupportBundles(ctx, exec)
+		if err != nil {
+			return nil, err
+		}
+		for _, bundle := range bundles {
+			if !known[bundle.Name] {
+				return &FileResult{
+					Endpoint:    "/fileservice",
+					Filename:    bundle.Name,
+					ContentType: "application/gzip",
+					Size:        bundle.Size,
+					DownloadURL: bundle.DownloadURL,
+				}, nil
+			}
+		}
+
+		if time.Now().Add(techSupportPollInterval).After(deadline) {
+			return &TechSupportStatus{
+				Level:   req.Level,
+				State:   "in_progress",
+				Message: "The controller is still collecting the bundle. List the tech-support bundles in a few minutes to get its download link.",
+			}, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(techSupportPollInterval):
+		}
+	}
+}
+
+// techSupportLevelNames returns the collection levels in order
+func techSupportLevelNames() []string {
+	names := make([]string, 0, len(TechSupportLevels))
+	for name := range TechSupportLevels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// stringValue returns v when it is a string
+func stringValue(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
@@ -0,0 +1,148 @@
+package avi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// topNMetrics maps the traffic measures a top-N report ranks by to virtual service metric IDs
+var topNMetrics = map[string]string{
+	"connections": "l4_client.avg_complete_conns",
+	"throughput":  "l4_client.avg_bandwidth",
+	"errors":      "l7_client.avg_error_responses",
+}
+
+// Top-N report limits
+const (
+	defaultTopN       = 10
+	topNConcurrency   = 8    // metrics queries in flight at once
+	topNPageSize      = 200  // virtual services read per page
+	maxTopNCandidates = 2000 // virtual services considered at most
+)
+
+// TopNEntry is a ranked virtual service
+type TopNEntry struct {
+	Rank   int     `json:"rank"`
+	Name   string  `json:"name"`
+	UUID   string  `json:"uuid"`
+	Mean   float64 `json:"mean"`
+	Max    float64 `json:"max"`
+	Latest float64 `json:"latest"`
+}
+
+// TopNReport ranks virtual services by the mean of a traffic metric over a time range
+type TopNReport struct {
+	By        string      `json:"by"`
+	MetricID  string      `json:"metric_id"`
+	Units     string      `json:"units,omitempty"`
+	TimeRange string      `json:"time_range"`
+	Evaluated int         `json:"evaluated"`        // virtual services whose metrics were read
+	Failed    []string    `json:"failed,omitempty"` // virtual services whose metrics couldn't be read
+	Entries   []TopNEntry `json:"entries"`
+}
+
+// TopVirtualServices reads a traffic metric (connections, throughput or errors, or a metric ID)
+// of every virtual service and returns the n highest by mean over the time range
+func TopVirtualServices(ctx context.Context, exec GenericExecutor, by string, n int, timeRange string) (*TopNReport, error) {
+	if by == "" {
+		by = "connections"
+	}
+	metricID, ok := topNMetrics[by]
+	if !ok {
+		metricID = by
+	}
+	if n <= 0 {
+		n = defaultTopN
+	}
+	if timeRange == "" {
+		timeRange = "1h"
+	}
+	// Validate the metric and time range once before fanning out
+	if _, err := BuildMetricsQuery("virtualservice", "validate", []string{metricID}, timeRange); err != nil {
+		return nil, err
+	}
+
+	services, err := listVirtualServiceRefs(ctx, exec)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &TopNReport{By: by, MetricID: metricID, TimeRange: timeRange, Entries: []TopNEntry{}}
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		limiter = make(chan struct{}, topNConcurrency)
+	)
+	for _, vs := range services {
+		wg.Add(1)
+		go func(name, uuid string) {
+			defer wg.Done()
+			limiter <- struct{}{}
+			defer func() { <-limiter }()
+
+			query, _ := BuildMetricsQuery("virtualservice", uuid, []string{metricID}, timeRange)
+			result, err := QueryMetrics(ctx, exec, query)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil || len(result.Series) == 0 {
+				report.Failed = append(report.Failed, name)
+				return
+			}
+			series := result.Series[0]
+			report.Evaluated++
+			report.Units = series.Units
+			report.Entries = append(report.Entries, TopNEntry{Name: name, UUID: uuid, Mean: series.Mean, Max: series.Max, Latest: series.Latest})
+		}(vs["name"], vs["uuid"])
+	}
+	wg.Wait()
+
+	sort.Slice(report.Entries, func(i, j int) bool {
+		if report.Entries[i].Mean != report.Entries[j].Mean {
+			return report.Entries[i].Mean > report.Entries[j].Mean
+		}
+		return report.Entries[i].Name < report.Entries[j].Name
+	})
+	sort.Strings(report.Failed)
+	if len(report.Entries) > n {
+		report.Entries = report.Entries[:n]
+	}
+	for i := range report.Entries {
+		report.Entries[i].Rank = i + 1
+	}
+	if report.Units == "" {
+		if info, ok := lookupMetric(metricID); ok {
+			report.Units = info.Units
+		}
+	}
+	return report, nil
+}
+
+// listVirtualServiceRefs reads the name and UUID of every virtual service, page by page
+func listVirtualServiceRefs(ctx context.Context, exec GenericExecutor) ([]map[string]string, error) {
+	var services []map[string]string
+	for page := 1; len(services) < maxTopNCandidates; page++ {
+		results, err := listObjects(ctx, exec, "/virtualservice", map[string]string{
+			"fields":    "name,uuid",
+			"page_size": strconv.Itoa(topNPageSize),
+			"page":      strconv.Itoa(page),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list virtual services: %w", err)
+		}
+		for _, vs := range results {
+			name, _ := vs["name"].(string)
+			uuid, _ := vs["uuid"].(string)
+			if uuid != "" {
+				services = append(services, map[string]string{"name": name, "uuid": uuid})
+			}
+		}
+		if len(results) < topNPageSize {
+			break
+		}
+	}
+	return services, nil
+}
@@ -0,0 +1,254 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// vaultTimeout bounds a secret read from Vault
+const vaultTimeout = 10 * time.Second
+
+// masked replaces a secret in the masked configuration
+const masked = "***"
+
+// secretKeys are the settings holding a secret
+var secretKeys = map[string]bool{
+	"password":     true,
+	"api_key":      true,
+	"token":        true,
+	"vault_token":  true,
+	"proxy_secret": true,
+	"signing_key":  true,
+}
+
+// HasSecretReferences reports whether a secret is read from a file or Vault, and can therefore rotate
+func (c *Config) HasSecretReferences() bool {
+	if c.hasAviPasswordReference() || c.Mistral.APIKeyFile != "" || c.Mistral.APIKeyVault != "" {
+		return true
+	}
+	for _, account := range c.AviUsers.Accounts {
+		if account.PasswordFile != "" || account.PasswordVault != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAviPasswordReference reports whether the Avi password is read from a file or Vault; the
+// sandbox supplies its own credentials, so the reference isn't used in training mode
+func (c *Config) hasAviPasswordReference() bool {
+	return !c.Sandbox.Enabled && (c.Avi.PasswordFile != "" || c.Avi.PasswordVault != "")
+}
+
+// ResolveSecrets replaces avi.password, mistral.api_key and the passwords of avi_users.accounts
+// with the secrets their _file or _vault references point to. Values without a reference are kept
+// as configured.
+func ResolveSecrets(cfg *Config) error {
+	var password string
+	if cfg.hasAviPasswordReference() {
+		var err error
+		if password, err = resolveSecret(cfg.Secrets, "avi.password", cfg.Avi.PasswordFile, cfg.Avi.PasswordVault); err != nil {
+			return err
+		}
+	}
+	apiKey, err := resolveSecret(cfg.Secrets, "mistral.api_key", cfg.Mistral.APIKeyFile, cfg.Mistral.APIKeyVault)
+	if err != nil {
+		return err
+	}
+	if password != "" {
+		cfg.Avi.Password = password
+	}
+	if apiKey != "" {
+		cfg.Mistral.APIKey = apiKey
+	}
+
+	// The accounts are copied so resolving doesn't change a configuration sharing them
+	if len(cfg.AviUsers.Accounts) > 0 {
+		accounts := make([]AviUserAccount, len(cfg.AviUsers.Accounts))
+		for i, account := range cfg.AviUsers.Accounts {
+			key := fmt.Sprintf("avi_users.accounts[%s].password", account.Operator)
+			password, err := resolveSecret(cfg.Secrets, key, account.PasswordFile, account.PasswordVault)
+			if err != nil {
+				return err
+			}
+			if password != "" {
+				account.Password = password
+			}
+			accounts[i] = account
+		}
+		cfg.AviUsers.Accounts = accounts
+	}
+	return nil
+}
+
+// Masked returns the configuration as its settings are named in config.yaml, with passwords, API
+// keys, tokens and request headers replaced by ***. Webhook URLs often carry a secret in their
+// path, so only their scheme and host are kept; other URLs lose their credentials and query.
+func (c *Config) Masked() map[string]interface{} {
+	return maskValue("", reflect.ValueOf(*c)).(map[string]interface{})
+}
+
+// maskValue converts a configuration value named key to plain maps and slices, masking secrets
+func maskValue(key string, value reflect.Value) interface{} {
+	switch value.Kind() {
+	case reflect.Struct:
+		settings := make(map[string]interface{}, value.NumField())
+		for i := 0; i < value.NumField(); i++ {
+			name := value.Type().Field(i).Tag.Get("mapstructure")
+			if name == "" || name == "-" {
+				continue
+			}
+			settings[name] = maskValue(name, value.Field(i))
+		}
+		return settings
+	case reflect.Map:
+		settings := make(map[string]interface{}, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			name := fmt.Sprint(iter.Key().Interface())
+			if key == "headers" {
+				settings[name] = masked
+				continue
+			}
+			settings[name] = maskValue(name, iter.Value())
+		}
+		return settings
+	case reflect.Slice:
+		items := make([]interface{}, value.Len())
+		for i := range items {
+			items[i] = maskValue(key, value.Index(i))
+		}
+		return items
+	case reflect.String:
+		text := value.String()
+		switch {
+		case text == "":
+			return text
+		case secretKeys[key]:
+			return masked
+		case key == "url":
+			return maskURL(text, false)
+		case strings.HasSuffix(key, "_url"):
+			return maskURL(text, true)
+		}
+		return text
+	}
+	return value.Interface()
+}
+
+// maskURL keeps the scheme, host and, with keepPath, the path of a URL
+func maskURL(text string, keepPath bool) string {
+	u, err := url.Parse(text)
+	if err != nil || u.Host == "" {
+		return masked
+	}
+	kept := u.Scheme + "://" + u.Host
+	if keepPath || u.Path == "" || u.Path == "/" {
+		kept += u.Path
+	} else {
+		kept += "/" + masked
+	}
+	if u.RawQuery != "" {
+		kept += "?" + masked
+	}
+	return kept
+}
+
+// resolveSecret reads the secret named key from its file or Vault reference, empty when it has neither
+func resolveSecret(secrets SecretsConfig, key, file, vaultRef string) (string, error) {
+	switch {
+	case file != "" && vaultRef != "":
+		return "", fmt.Errorf("%s_file and %s_vault can't both be set", key, key)
+	case file != "":
+		value, err := readSecretFile(file)
+		if err != nil {
+			return "", fmt.Errorf("%s_file: %w", key, err)
+		}
+		return value, nil
+	case vaultRef != "":
+		value, err := readVaultSecret(secrets, vaultRef)
+		if err != nil {
+			return "", fmt.Errorf("%s_vault: %w", key, err)
+		}
+		return value, nil
+	}
+	return "", nil
+}
+
+// readSecretFile reads a secret file, without the trailing newline most tools write
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return value, nil
+}
+
+// readVaultSecret reads a field of a Vault secret referenced as path#field, e.g.
+// secret/data/aviagent#password. Both KV version 1 and version 2 paths are supported.
+func readVaultSecret(secrets SecretsConfig, ref string) (string, error) {
+	path, field, ok := strings.Cut(ref, "#")
+	path = strings.Trim(path, "/")
+	if !ok || path == "" || field == "" {
+		return "", fmt.Errorf("invalid reference %q, expected path#field", ref)
+	}
+	if secrets.VaultAddr == "" {
+		return "", fmt.Errorf("secrets.vault_addr is required to read %s", ref)
+	}
+	token := secrets.VaultToken
+	if secrets.VaultTokenFile != "" {
+		var err error
+		if token, err = readSecretFile(secrets.VaultTokenFile); err != nil {
+			return "", fmt.Errorf("failed to read the Vault token: %w", err)
+		}
+	}
+	if token == "" {
+		return "", fmt.Errorf("secrets.vault_token or secrets.vault_token_file is required to read %s", ref)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(secrets.VaultAddr, "/")+"/v1/"+path, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if secrets.VaultNamespace != "" {
+		req.Header.Set("X-Vault-Namespace", secrets.VaultNamespace)
+	}
+	resp, err := (&http.Client{Timeout: vaultTimeout}).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s from Vault: %w", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to read %s from Vault: HTTP %d", path, resp.StatusCode)
+	}
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", fmt.Errorf("failed to parse the Vault response for %s: %w", path, err)
+	}
+	data := secret.Data
+	// KV version 2 nests the secret under data next to its metadata
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, versioned := data["metadata"]; versioned {
+			data = inner
+		}
+	}
+	value, ok := data[field].(string)
+	if !ok || value == "" {
+		return "", fmt.Errorf("Vault secret %s has no field %s", path, field)
+	}
+	return value, nil
+}
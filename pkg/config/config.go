@@ -0,0 +1,1145 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/viper"
+)
+
+// Config holds the application configuration
+type Config struct {
+	Server    ServerConfig    `mapstructure:"server"`
+	Avi       AviConfig       `mapstructure:"avi"`
+	AviUsers  AviUsersConfig  `mapstructure:"avi_users"`
+	LLM       LLMConfig       `mapstructure:"llm"`
+	Mistral   MistralConfig   `mapstructure:"mistral"`
+	Context   ContextConfig   `mapstructure:"context"`
+	Log       LogConfig       `mapstructure:"log"`
+	Pricing   PricingConfig   `mapstructure:"pricing"`
+	Routing   RoutingConfig   `mapstructure:"routing"`
+	Fallback  FallbackConfig  `mapstructure:"fallback"`
+	Tools     ToolsConfig     `mapstructure:"tools"`
+	Results   ResultsConfig   `mapstructure:"results"`
+	Audit     AuditConfig     `mapstructure:"audit"`
+	Sandbox   SandboxConfig   `mapstructure:"sandbox"`
+	Moderation ModerationConfig `mapstructure:"moderation"`
+	Style     StyleConfig     `mapstructure:"style"`
+	Insights  InsightsConfig  `mapstructure:"insights"`
+	Scheduler SchedulerConfig `mapstructure:"scheduler"`
+	Notifications NotificationsConfig `mapstructure:"notifications"`
+	Alerts    AlertsConfig    `mapstructure:"alerts"`
+	Runbooks  RunbooksConfig  `mapstructure:"runbooks"`
+	Workflows WorkflowsConfig `mapstructure:"workflows"`
+	Memory    MemoryConfig    `mapstructure:"memory"`
+	Approvals ApprovalsConfig `mapstructure:"approvals"`
+	Changes   ChangesConfig   `mapstructure:"changes"`
+	Drift     DriftConfig     `mapstructure:"drift"`
+	Blueprints BlueprintsConfig `mapstructure:"blueprints"`
+	Simulation SimulationConfig `mapstructure:"simulation"`
+	Secrets   SecretsConfig   `mapstructure:"secrets"`
+	Admin     AdminConfig     `mapstructure:"admin"`
+	Provider  string          `mapstructure:"provider"` // "ollama" or "mistral"
+}
+
+// DefaultContentSecurityPolicy allows the web UI's own scripts and the CDNs it loads htmx,
+// Bootstrap and Font Awesome from
+const DefaultContentSecurityPolicy = "default-src 'self'; " +
+	"script-src 'self' https://unpkg.com https://cdn.jsdelivr.net; " +
+	"style-src 'self' 'unsafe-inline' https://cdn.jsdelivr.net https://cdnjs.cloudflare.com; " +
+	"font-src 'self' https://cdnjs.cloudflare.com; img-src 'self' data:; frame-ancestors 'none'"
+
+// ServerConfig holds web server configuration
+type ServerConfig struct {
+	Host         string `mapstructure:"host"` // bind address, empty listens on all IPv4 and IPv6 interfaces
+	Port         int    `mapstructure:"port"`
+	ReadTimeout  int    `mapstructure:"read_timeout"`
+	WriteTimeout int    `mapstructure:"write_timeout"`
+	IdleTimeout  int    `mapstructure:"idle_timeout"`
+	DebugEndpoints bool `mapstructure:"debug_endpoints"` // expose admin debugging endpoints: prompt rendering, runtime log level, session debug logging
+	UIEnabled    bool   `mapstructure:"ui_enabled"` // serve the web UI; when false, or its assets are missing, only the JSON API is served
+	UIDir        string `mapstructure:"ui_dir"`     // directory holding templates/ and static/ served instead of the embedded UI, for UI development
+	TicketURL    string `mapstructure:"ticket_url"` // issue tracker URL opened by the "create ticket" message action; {title} and {description} are replaced URL-encoded
+	TLS          TLSConfig `mapstructure:"tls"`
+	CORSOrigins  []string `mapstructure:"cors_origins"` // origins allowed to call the API from a browser, "*" for any; none allows only the UI's own origin
+	SecurityHeaders SecurityHeadersConfig `mapstructure:"security_headers"`
+	Compression  CompressionConfig `mapstructure:"compression"`
+	StaticMaxAge int    `mapstructure:"static_max_age"` // seconds browsers may cache the UI's static assets without revalidating; 0 revalidates every time
+}
+
+// CompressionConfig holds the gzip compression of text responses: pages, assets and JSON
+type CompressionConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	MinSize int  `mapstructure:"min_size"` // bytes; smaller responses are sent uncompressed
+	Level   int  `mapstructure:"level"`    // gzip level from 1 (fastest) to 9 (smallest)
+}
+
+// SecurityHeadersConfig holds the security headers added to every response; an empty value omits its header
+type SecurityHeadersConfig struct {
+	ContentSecurityPolicy string `mapstructure:"content_security_policy"`
+	HSTSMaxAge   int    `mapstructure:"hsts_max_age"` // Strict-Transport-Security max-age in seconds, sent over TLS only; 0 omits it
+	FrameOptions string `mapstructure:"frame_options"` // X-Frame-Options: DENY or SAMEORIGIN
+}
+
+// TLSConfig holds the certificate the web server listens with; without one it serves plain HTTP
+type TLSConfig struct {
+	CertFile     string `mapstructure:"cert_file"` // PEM certificate chain, re-read on SIGHUP
+	KeyFile      string `mapstructure:"key_file"`  // PEM private key of the certificate
+	ClientCAFile string `mapstructure:"client_ca_file"` // PEM CA bundle client certificates must chain to; enables mTLS, re-read on SIGHUP
+	ClientAuth   string `mapstructure:"client_auth"` // with a client CA: "require" a certificate, or "optional" to verify one only when sent
+	MinVersion   string `mapstructure:"min_version"` // "1.2" or "1.3"
+}
+
+// Enabled reports whether the web server serves HTTPS
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != ""
+}
+
+// AviConfig holds VMware Avi Load Balancer configuration
+type AviConfig struct {
+	Host      string `mapstructure:"host"`
+	Username  string `mapstructure:"username"`
+	Password  string `mapstructure:"password"`
+	PasswordFile  string `mapstructure:"password_file"`  // file holding the password, e.g. a mounted Kubernetes secret
+	PasswordVault string `mapstructure:"password_vault"` // Vault secret holding the password, as path#field
+	Version   string `mapstructure:"version"`
+	Tenant    string `mapstructure:"tenant"`
+	Timeout   int    `mapstructure:"timeout"`
+	Insecure  bool   `mapstructure:"insecure"`
+	PinnedFingerprints []string `mapstructure:"pinned_fingerprints"` // SHA-256 fingerprints of the controller certificate, or sha256/<base64> public key pins, trusted instead of the CA
+	AuthMethod string `mapstructure:"auth_method"` // "session" or "basic"
+	Nodes     []string `mapstructure:"nodes"`       // individual controller node addresses used when the cluster VIP (host) fails
+	AnalyticsHost string `mapstructure:"analytics_host"` // follower node serving metric, log and health score queries, the leader when empty
+	LeastPrivilege bool `mapstructure:"least_privilege"` // read the account's role at startup and only offer tools it permits
+	ClockSkewThreshold int `mapstructure:"clock_skew_threshold"` // seconds of controller/agent clock difference before time-range answers carry a warning, 0 disables the check
+}
+
+// CertificatePin is a SHA-256 digest the controller's certificate chain must present: of a whole
+// certificate, or of a certificate's public key (SubjectPublicKeyInfo)
+type CertificatePin struct {
+	PublicKey bool
+	Digest    [sha256.Size]byte
+}
+
+// CertificatePins parses the pinned fingerprints. A certificate fingerprint is 64 hex digits,
+// colons allowed as openssl prints them; a public key pin is sha256/<base64> as in HPKP.
+func (c AviConfig) CertificatePins() ([]CertificatePin, error) {
+	pins := make([]CertificatePin, 0, len(c.PinnedFingerprints))
+	for _, fingerprint := range c.PinnedFingerprints {
+		fingerprint = strings.TrimSpace(fingerprint)
+		var pin CertificatePin
+		if encoded, ok := strings.CutPrefix(fingerprint, "sha256/"); ok {
+			digest, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(encoded, "/"))
+			if err != nil || len(digest) != sha256.Size {
+				return nil, fmt.Errorf("avi.pinned_fingerprints: %q is not a sha256/<base64> public key pin", fingerprint)
+			}
+			pin.PublicKey = true
+			copy(pin.Digest[:], digest)
+		} else {
+			digest, err := hex.DecodeString(strings.ReplaceAll(fingerprint, ":", ""))
+			if err != nil || len(digest) != sha256.Size {
+				return nil, fmt.Errorf("avi.pinned_fingerprints: %q is not a SHA-256 certificate fingerprint (64 hex digits) or a sha256/<base64> public key pin", fingerprint)
+			}
+			copy(pin.Digest[:], digest)
+		}
+		pins = append(pins, pin)
+	}
+	return pins, nil
+}
+
+// AviUsersConfig maps the operators of the web UI and API to their own Avi accounts, so the
+// controller's audit log attributes their changes to them instead of the agent's account. The
+// operator is the AUDIT_OPERATOR_HEADER header; requests without one use the agent's account.
+// Once a trusted proxy or proxy secret is set, in any mode, the header is only believed from the
+// trusted proxy, or along with the proxy secret.
+type AviUsersConfig struct {
+	Mode              string           `mapstructure:"mode"`                // "" uses the agent's account for everyone, "map" or "passthrough" (HTTP Basic credentials of the request)
+	Fallback          string           `mapstructure:"fallback"`            // operators without credentials: "deny" or "shared" (the agent's account)
+	Accounts          []AviUserAccount `mapstructure:"accounts"`            // for map mode
+	TrustedProxies    []string         `mapstructure:"trusted_proxies"`     // addresses or CIDRs of the reverse proxies allowed to name the operator
+	ProxySecret       string           `mapstructure:"proxy_secret"`        // secret the reverse proxy sends in ProxySecretHeader
+	ProxySecretHeader string           `mapstructure:"proxy_secret_header"` // default X-Proxy-Secret
+}
+
+// TrustedNetworks parses the trusted proxies, a single address standing for itself
+func (c AviUsersConfig) TrustedNetworks() ([]netip.Prefix, error) {
+	networks := make([]netip.Prefix, 0, len(c.TrustedProxies))
+	for _, proxy := range c.TrustedProxies {
+		if addr, err := netip.ParseAddr(proxy); err == nil {
+			networks = append(networks, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		network, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("avi_users.trusted_proxies: %q is not an address or CIDR", proxy)
+		}
+		networks = append(networks, network.Masked())
+	}
+	return networks, nil
+}
+
+// AviUserAccount is the Avi account of an operator. Accounts are a list rather than a map by
+// operator since viper splits keys on dots, which operator names often contain.
+type AviUserAccount struct {
+	Operator      string `mapstructure:"operator"` // operator name from AUDIT_OPERATOR_HEADER, matched case-insensitively
+	Username      string `mapstructure:"username"` // the operator name when empty
+	Password      string `mapstructure:"password"`
+	PasswordFile  string `mapstructure:"password_file"`
+	PasswordVault string `mapstructure:"password_vault"` // path#field
+}
+
+// LLMConfig holds Ollama LLM configuration
+type LLMConfig struct {
+	OllamaHost    string   `mapstructure:"ollama_host"`
+	DefaultModel  string   `mapstructure:"default_model"`
+	Models        []string `mapstructure:"models"`
+	Timeout       int      `mapstructure:"timeout"`
+	Temperature   float64  `mapstructure:"temperature"`
+	MaxTokens     int      `mapstructure:"max_tokens"`
+	JSONMode      bool     `mapstructure:"json_mode"` // constrain the tool-selection turn to JSON output
+}
+
+// MistralConfig holds Mistral AI configuration
+type MistralConfig struct {
+	APIBaseURL   string   `mapstructure:"api_base_url"`
+	APIKey       string   `mapstructure:"api_key"`
+	APIKeyFile   string   `mapstructure:"api_key_file"`  // file holding the API key
+	APIKeyVault  string   `mapstructure:"api_key_vault"` // Vault secret holding the API key, as path#field
+	DefaultModel string   `mapstructure:"default_model"`
+	Models       []string `mapstructure:"models"`
+	Timeout      int      `mapstructure:"timeout"`
+	Temperature  float64  `mapstructure:"temperature"`
+	MaxTokens    int      `mapstructure:"max_tokens"`
+	MaxRetries   int      `mapstructure:"max_retries"` // retries on HTTP 429 rate limiting
+	JSONMode     bool     `mapstructure:"json_mode"`   // request response_format json_object on the tool-selection turn
+}
+
+// ContextConfig holds the token budget of the conversation history sent with each question.
+// Older turns beyond it are replaced by a summary written by the model.
+type ContextConfig struct {
+	MaxTokens  int `mapstructure:"max_tokens"`  // approximate tokens of history sent to the model, 0 sends the whole history
+	KeepRecent int `mapstructure:"keep_recent"` // most recent messages always sent as they are
+}
+
+// PricingConfig holds per-model token pricing used for cost estimates. Models are a list rather
+// than a map keyed by name, as viper splits keys on dots and names like llama3.2 would be lost.
+type PricingConfig struct {
+	Currency string         `mapstructure:"currency"`
+	Models   []ModelPricing `mapstructure:"models"`
+}
+
+// ModelPricing holds the price of a model per million tokens
+type ModelPricing struct {
+	Model            string  `mapstructure:"model"`
+	InputPerMillion  float64 `mapstructure:"input_per_million"`
+	OutputPerMillion float64 `mapstructure:"output_per_million"`
+}
+
+// EstimateCost returns the estimated cost of a model call, or false if the model has no pricing
+func (p PricingConfig) EstimateCost(model string, promptTokens, completionTokens int) (float64, bool) {
+	for _, price := range p.Models {
+		if strings.EqualFold(price.Model, model) {
+			cost := float64(promptTokens)/1e6*price.InputPerMillion + float64(completionTokens)/1e6*price.OutputPerMillion
+			return cost, true
+		}
+	}
+	return 0, false
+}
+
+// RoutingConfig holds the automatic model selection policy
+type RoutingConfig struct {
+	Enabled          bool     `mapstructure:"enabled"`
+	SimpleModel      string   `mapstructure:"simple_model"`      // cheap/fast model for simple read queries
+	ComplexModel     string   `mapstructure:"complex_model"`     // stronger model for planning and write operations
+	WriteKeywords    []string `mapstructure:"write_keywords"`    // words that indicate a change to the controller
+	PlanningKeywords []string `mapstructure:"planning_keywords"` // words that indicate a multi-step request
+	MaxSimpleWords   int      `mapstructure:"max_simple_words"`  // longer queries are treated as complex
+	Intents          bool     `mapstructure:"intents"`           // answer obvious read queries with their tool, without the model
+}
+
+// FallbackConfig holds the models a question is sent to, in order, when its model fails
+type FallbackConfig struct {
+	Models  []FallbackModel `mapstructure:"models"`
+	Timeout int             `mapstructure:"timeout"` // seconds a model may take before the next one is asked, 0 leaves it to the provider timeout
+}
+
+// FallbackModel is a model of the fallback chain. Models are a list rather than provider:model
+// strings, as Ollama model names contain colons.
+type FallbackModel struct {
+	Model    string `mapstructure:"model"`
+	Provider string `mapstructure:"provider"` // "ollama" or "mistral", the configured provider when empty
+}
+
+// ToolsConfig selects the tools offered to the model in this deployment. A tool is offered when
+// enabled is empty or lists it, and disabled doesn't list it.
+type ToolsConfig struct {
+	Enabled          []string               `mapstructure:"enabled"`  // the only tools offered, empty offers every tool
+	Disabled         []string               `mapstructure:"disabled"` // tools never offered, e.g. execute_generic_operation
+	GenericOperation GenericOperationConfig `mapstructure:"generic_operation"`
+	Custom           []CustomToolConfig     `mapstructure:"custom"` // tools of the deployment's own, calling Avi API endpoints
+}
+
+// CustomToolConfig defines a tool calling an Avi API endpoint, for endpoints the built-in tools
+// don't cover yet. Arguments named in braces in the endpoint are filled in; the others are sent
+// as query parameters of a GET or DELETE and as the body of the other methods.
+type CustomToolConfig struct {
+	Name        string                 `mapstructure:"name"`
+	Description string                 `mapstructure:"description"` // tells the model what the tool does and when to call it
+	Method      string                 `mapstructure:"method"`      // HTTP method, GET when empty
+	Endpoint    string                 `mapstructure:"endpoint"`    // e.g. /virtualservice/{uuid}/runtime/detail
+	Parameters  map[string]interface{} `mapstructure:"parameters"`  // JSON schema of the arguments, an object
+}
+
+// GenericOperationConfig limits the API calls the model can make with execute_generic_operation.
+// It is read-only by default; calls that change configuration also need the operator's approval.
+type GenericOperationConfig struct {
+	Methods      []string `mapstructure:"methods"`        // HTTP methods allowed, GET when empty
+	Endpoints    []string `mapstructure:"endpoints"`      // endpoint prefixes allowed (e.g. /pool matches /pool/{uuid}), every endpoint when empty
+	MaxBodyBytes int      `mapstructure:"max_body_bytes"` // largest request body, as JSON
+}
+
+// ResultsConfig holds how tool results are reduced before they are added to the answer, which
+// the model reads again with every later question
+type ResultsConfig struct {
+	MaxItems       int      `mapstructure:"max_items"`       // items kept of a list
+	MaxBytes       int      `mapstructure:"max_bytes"`       // JSON size above which lists are cut further
+	Fields         []string `mapstructure:"fields"`          // fields kept of the items of the list tools when the call asks for none, all when empty
+	Summarize      bool     `mapstructure:"summarize"`       // results still larger than summarize_above are summarized by the model
+	SummarizeAbove int      `mapstructure:"summarize_above"` // JSON size in bytes
+	ExplainErrors  bool     `mapstructure:"explain_errors"`  // have the model explain failed tool calls and suggest a fix
+}
+
+// AuditConfig holds the audit trail of chat-driven changes
+type AuditConfig struct {
+	File           string `mapstructure:"file"`            // JSONL file the audit trail is appended to, empty keeps it in memory only
+	SigningKey     string `mapstructure:"signing_key"`     // HMAC-SHA256 key used to sign audit exports
+	OperatorHeader string `mapstructure:"operator_header"` // request header carrying the authenticated operator (set by a reverse proxy)
+	AppendOnly     bool   `mapstructure:"append_only"`     // hash-chain entries (each record includes the hash of the previous) for tamper evidence
+}
+
+// SandboxConfig holds the training mode that runs the agent against the bundled mock controller
+type SandboxConfig struct {
+	Enabled bool `mapstructure:"enabled"` // ignore the avi section and serve sample data from an in-process controller
+}
+
+// ModerationConfig holds the filter applied to chat answers before they are rendered
+type ModerationConfig struct {
+	Enabled      bool             `mapstructure:"enabled"`       // redact private keys, credentials and session tokens from answers
+	Rules        []ModerationRule `mapstructure:"rules"`         // additional rules applied after the built-in ones
+	Model        string           `mapstructure:"model"`         // model asked to allow or block each answer, empty disables model moderation
+	Policy       string           `mapstructure:"policy"`        // disallowed content the moderation model enforces (e.g. actions operators must not be told to run)
+	BlockMessage string           `mapstructure:"block_message"` // shown instead of a blocked answer
+}
+
+// ModerationRule is a regular expression that redacts or blocks matching answer text
+type ModerationRule struct {
+	Name        string `mapstructure:"name"`
+	Pattern     string `mapstructure:"pattern"`
+	Action      string `mapstructure:"action"`      // "redact" (default) or "block"
+	Replacement string `mapstructure:"replacement"` // redaction text, may reference capture groups; defaults to [REDACTED]
+}
+
+// StyleConfig holds the deployment style rules answers are corrected to follow
+type StyleConfig struct {
+	NoEmojis      bool   `mapstructure:"no_emojis"`      // remove emojis outside code blocks
+	IncludeUUIDs  bool   `mapstructure:"include_uuids"`  // add the UUID after the first mention of each object the tool results named
+	IncludeTenant bool   `mapstructure:"include_tenant"` // add the tenant to answers that don't name it
+	Template      string `mapstructure:"template"`       // Go template answers are rendered into, with .Answer, .Tenant and .Objects (Name, UUID)
+}
+
+// InsightsConfig holds the acknowledgment state of insights raised from tool results
+type InsightsConfig struct {
+	StateFile string `mapstructure:"state_file"` // JSON file acknowledgments and snoozes are saved to, empty keeps them in memory only
+}
+
+// SchedulerConfig holds the reports run on a schedule and where they are delivered
+type SchedulerConfig struct {
+	Enabled      bool                         `mapstructure:"enabled"`
+	Timezone     string                       `mapstructure:"timezone"`     // IANA time zone schedules are evaluated in
+	Locale       string                       `mapstructure:"locale"`       // number and date conventions of reports, e.g. de-DE; ISO dates and plain numbers when empty
+	SMTP         SMTPConfig                   `mapstructure:"smtp"`         // mail server used by email destinations
+	Destinations map[string]ReportDestination `mapstructure:"destinations"` // by name, referenced from jobs
+	Jobs         []ReportJob                  `mapstructure:"jobs"`
+}
+
+// SMTPConfig holds the mail server reports are sent through
+type SMTPConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"` // empty sends without authentication
+	Password string `mapstructure:"password"`
+	From     string `mapstructure:"from"`
+}
+
+// ReportDestination is where a rendered report is delivered
+type ReportDestination struct {
+	Type    string            `mapstructure:"type"`    // "webhook" (JSON POST), "slack" (incoming webhook), "email" or "notification"
+	URL     string            `mapstructure:"url"`     // webhook and slack
+	Headers map[string]string `mapstructure:"headers"` // extra webhook request headers, e.g. Authorization
+	To      []string          `mapstructure:"to"`      // email recipients
+	Channel string            `mapstructure:"channel"` // notification channel the report is sent to
+}
+
+// ReportJob runs a predefined report on a cron schedule
+type ReportJob struct {
+	Name         string   `mapstructure:"name"`
+	Schedule     string   `mapstructure:"schedule"`     // cron expression (minute hour day-of-month month day-of-week) or @hourly, @daily, @weekly, @every 6h
+	Report       string   `mapstructure:"report"`       // health_summary, cert_expiry, capacity, sla or change_digest
+	Destinations []string `mapstructure:"destinations"` // destination names
+	WithinDays   int      `mapstructure:"within_days"`  // cert_expiry window, 30 days when unset
+	Period       string   `mapstructure:"period"`       // sla period such as 7d or 30d, 30d when unset; change_digest period, 24h when unset
+	Target       float64  `mapstructure:"target"`       // sla availability target in percent, 99.9 when unset
+	Locale       string   `mapstructure:"locale"`       // number and date conventions of this report, scheduler.locale when unset
+}
+
+// NotificationsConfig holds the outbound webhook channels events are sent to
+type NotificationsConfig struct {
+	Channels map[string]NotificationChannel `mapstructure:"channels"` // by name
+}
+
+// NotificationChannel is a webhook (PagerDuty, Opsgenie, Mattermost, ...) that receives events
+// rendered by a template
+type NotificationChannel struct {
+	URL         string            `mapstructure:"url"`          // ${VAR} references are expanded from the environment
+	Method      string            `mapstructure:"method"`       // POST when empty
+	Headers     map[string]string `mapstructure:"headers"`      // e.g. Authorization: "GenieKey ${OPSGENIE_API_KEY}"
+	ContentType string            `mapstructure:"content_type"` // application/json when empty
+	Template    string            `mapstructure:"template"`     // Go text/template of the request body, the event as JSON when empty
+	Events      []string          `mapstructure:"events"`       // event types sent by Publish, e.g. "report.failed" or "report.*"; all when empty
+}
+
+// AlertsConfig holds the receiver of Avi controller alert webhooks
+type AlertsConfig struct {
+	Enabled   bool   `mapstructure:"enabled"`
+	Token     string `mapstructure:"token"`      // shared secret expected in the X-Alert-Token header or ?token=; required
+	MaxAlerts int    `mapstructure:"max_alerts"` // alerts kept, oldest dropped first
+	StateFile string `mapstructure:"state_file"` // JSON file alerts are saved to, empty keeps them in memory only
+	Summarize bool   `mapstructure:"summarize"`  // have the LLM explain each alert
+	Model     string `mapstructure:"model"`      // model writing summaries, the default model when empty
+	Notify    bool   `mapstructure:"notify"`     // publish alert.received events to the notification channels
+	Session   string `mapstructure:"session"`    // chat session alerts are posted to: a session ID, "latest" or empty for none
+}
+
+// RunbooksConfig holds the runbooks external systems, such as monitoring or CI, trigger by
+// webhook
+type RunbooksConfig struct {
+	Token     string    `mapstructure:"token"` // shared secret expected in the X-Runbook-Token header or ?token=; required with playbooks
+	Playbooks []Runbook `mapstructure:"playbooks"`
+}
+
+// Runbook is a playbook the agent runs as a chat session, one message per step
+type Runbook struct {
+	Name         string   `mapstructure:"name"` // the trigger path is /api/v1/runbooks/{name}/trigger
+	Description  string   `mapstructure:"description"`
+	Parameters   []string `mapstructure:"parameters"`    // parameters every trigger must pass
+	Steps        []string `mapstructure:"steps"`         // chat messages sent in order, Go text/templates of the parameters, e.g. "Is {{.vs}} up?"
+	Model        string   `mapstructure:"model"`         // the default model when empty
+	AllowChanges bool     `mapstructure:"allow_changes"` // run the tool calls that change configuration; they are declined otherwise
+	Channels     []string `mapstructure:"channels"`      // notification channels the outcome is sent to; published to the subscribed channels when empty
+}
+
+// runbookName is the form of runbook names, which are part of the trigger path
+var runbookName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Playbook returns the runbook with a name
+func (c RunbooksConfig) Playbook(name string) (Runbook, bool) {
+	for _, runbook := range c.Playbooks {
+		if runbook.Name == name {
+			return runbook, true
+		}
+	}
+	return Runbook{}, false
+}
+
+// WorkflowsConfig holds the progress of multi-step operations (configuration applies, service
+// engine maintenance) kept so interrupted runs can be resumed
+type WorkflowsConfig struct {
+	StateFile string `mapstructure:"state_file"` // JSON file run progress is saved to after every step, empty keeps it in memory only
+	MaxRuns   int    `mapstructure:"max_runs"`   // runs kept, oldest completed dropped first
+}
+
+// MemoryConfig holds the deployment memory: durable facts about the environment, such as naming
+// conventions, object owners or objects to leave alone, confirmed by administrators and sent to
+// the model with every question
+type MemoryConfig struct {
+	Enabled   bool     `mapstructure:"enabled"`
+	StateFile string   `mapstructure:"state_file"` // JSON file the facts are saved to, empty keeps them in memory only
+	Admins    []string `mapstructure:"admins"`     // operators allowed to add, change and delete facts
+	MaxFacts  int      `mapstructure:"max_facts"`  // facts kept, so the prompt stays small
+}
+
+// ApprovalsConfig holds the two-person rule for high-risk changes: tool calls matching one of the
+// rules wait in a queue until an approver other than the operator who asked approves them
+type ApprovalsConfig struct {
+	Rules       []ApprovalRule `mapstructure:"rules"`        // changes that need a second person, none when empty
+	Approvers   []string       `mapstructure:"approvers"`    // operators allowed to approve or reject queued changes
+	StateFile   string         `mapstructure:"state_file"`   // JSON file the queue is saved to, empty keeps it in memory only
+	MaxRequests int            `mapstructure:"max_requests"` // requests kept, oldest decided dropped first
+}
+
+// ApprovalRule matches the tool calls that change configuration and need a second person's
+// approval. A call matches when it matches every list the rule sets.
+type ApprovalRule struct {
+	Name    string   `mapstructure:"name"`
+	Actions []string `mapstructure:"actions"` // kinds of change: delete, create, update, or the verb of other tools such as reboot or scale
+	Tools   []string `mapstructure:"tools"`   // tool names, * matching any characters, e.g. delete_*
+	Tenants []string `mapstructure:"tenants"` // tenants changed: the call's tenant argument, or avi.tenant
+}
+
+// ChangesConfig holds the change history: the object each write made through the agent created,
+// changed or deleted, as it was before and after, for diffs and rollbacks
+type ChangesConfig struct {
+	StateFile  string `mapstructure:"state_file"`  // JSON file the history is saved to, empty keeps it in memory only
+	MaxChanges int    `mapstructure:"max_changes"` // changes kept, oldest dropped first
+}
+
+// DriftConfig holds drift detection: the objects declared in a directory of configuration files
+// are compared with the live controller, and the differences reported
+type DriftConfig struct {
+	Enabled   bool   `mapstructure:"enabled"`
+	Directory string `mapstructure:"directory"` // JSON and YAML files in export layout: object type to list of objects
+	Interval  int    `mapstructure:"interval"`  // seconds between checks, 0 only checks on request
+}
+
+// BlueprintsConfig holds the blueprints of common application patterns, such as an HTTPS
+// application with an HTTP redirect, that expand into the objects to create
+type BlueprintsConfig struct {
+	Directory string `mapstructure:"directory"` // YAML or JSON blueprints added to the built-in ones, overriding those of the same name
+}
+
+// SimulationConfig holds the inventory "what if" simulations are computed from
+type SimulationConfig struct {
+	InventoryTTL int `mapstructure:"inventory_ttl"` // seconds an inventory snapshot is reused before it is read again
+}
+
+// SecretsConfig holds where secrets referenced by avi.password_vault and mistral.api_key_vault
+// are read from, and how often referenced secrets are read again to pick up rotations
+type SecretsConfig struct {
+	VaultAddr       string `mapstructure:"vault_addr"`       // e.g. https://vault.example.com:8200
+	VaultToken      string `mapstructure:"vault_token"`
+	VaultTokenFile  string `mapstructure:"vault_token_file"` // file holding the token, e.g. written by a Vault agent; read on every refresh
+	VaultNamespace  string `mapstructure:"vault_namespace"`  // Vault Enterprise namespace
+	RefreshInterval int    `mapstructure:"refresh_interval"` // seconds between re-reads of referenced secrets, 0 disables
+}
+
+// AdminConfig holds the /admin API for operating the agent: cache flushes, active sessions,
+// effective configuration and tool state
+type AdminConfig struct {
+	Token string `mapstructure:"token"` // bearer token the API requires; the API is disabled when empty
+}
+
+// LogConfig holds logging configuration
+type LogConfig struct {
+	Level    string            `mapstructure:"level"`
+	Format   string            `mapstructure:"format"`   // "json" or "console"
+	Redact   bool              `mapstructure:"redact"`   // scrub passwords, API keys, session IDs and Authorization headers from every entry
+	Sampling LogSamplingConfig `mapstructure:"sampling"` // drops repeated entries under load
+	Access   AccessLogConfig   `mapstructure:"access"`
+}
+
+// LogSamplingConfig logs the first Initial entries with the same level and message each second,
+// then every Thereafter-th; an Initial of 0 logs every entry
+type LogSamplingConfig struct {
+	Initial    int `mapstructure:"initial"`
+	Thereafter int `mapstructure:"thereafter"`
+}
+
+// AccessLogConfig holds the HTTP access log
+type AccessLogConfig struct {
+	Enabled       bool     `mapstructure:"enabled"`
+	SampleRate    float64  `mapstructure:"sample_rate"`    // share of successful requests logged, from 0 to 1; errors and slow requests are always logged
+	SlowThreshold int      `mapstructure:"slow_threshold"` // milliseconds after which a request is logged as slow, 0 disables
+	SkipPaths     []string `mapstructure:"skip_paths"`     // paths never logged, e.g. health checks polled by probes
+}
+
+// Load loads configuration from file and environment variables
+func Load(configPath string) (*Config, error) {
+	// Set default values
+	viper.SetDefault("server.port", 8080)
+	viper.SetDefault("server.read_timeout", 30)
+	viper.SetDefault("server.write_timeout", 30)
+	viper.SetDefault("server.idle_timeout", 60)
+	viper.SetDefault("server.ui_enabled", true)
+	viper.SetDefault("server.tls.client_auth", "require")
+	viper.SetDefault("server.tls.min_version", "1.2")
+	viper.SetDefault("server.security_headers.content_security_policy", DefaultContentSecurityPolicy)
+	viper.SetDefault("server.security_headers.hsts_max_age", 31536000)
+	viper.SetDefault("server.security_headers.frame_options", "DENY")
+	viper.SetDefault("server.compression.enabled", true)
+	viper.SetDefault("server.compression.min_size", 1024)
+	viper.SetDefault("server.compression.level", 5)
+	viper.SetDefault("server.static_max_age", 3600)
+	
+	viper.SetDefault("avi.version", "31.2.1")
+	viper.SetDefault("avi.tenant", "admin")
+	viper.SetDefault("avi.timeout", 30)
+	viper.SetDefault("avi.insecure", false) // Changed to false for security
+	viper.SetDefault("avi.auth_method", "session") // Default to session-based auth
+	viper.SetDefault("avi.clock_skew_threshold", 30)
+	viper.SetDefault("avi.least_privilege", true)
+	viper.SetDefault("avi_users.fallback", "deny")
+	viper.SetDefault("avi_users.proxy_secret_header", "X-Proxy-Secret")
+	
+	viper.SetDefault("llm.ollama_host", "http://localhost:11434")
+	viper.SetDefault("llm.default_model", "llama3.2")
+	viper.SetDefault("llm.models", []string{"llama3.2", "mistral", "codellama"})
+	viper.SetDefault("llm.timeout", 60)
+	viper.SetDefault("llm.temperature", 0.7)
+	viper.SetDefault("llm.max_tokens", 2048)
+	viper.SetDefault("llm.json_mode", true)
+
+	// Mistral AI configuration defaults
+	viper.SetDefault("mistral.api_base_url", "https://api.mistral.ai")
+	viper.SetDefault("mistral.api_key", "")
+	viper.SetDefault("mistral.default_model", "mistral-medium")
+	viper.SetDefault("mistral.models", []string{"mistral-tiny", "mistral-small", "mistral-medium"})
+	viper.SetDefault("mistral.timeout", 60)
+	viper.SetDefault("mistral.temperature", 0.7)
+	viper.SetDefault("mistral.max_tokens", 2048)
+	viper.SetDefault("mistral.max_retries", 3)
+	viper.SetDefault("mistral.json_mode", true)
+
+	// Default to Ollama for backward compatibility
+	viper.SetDefault("provider", "ollama")
+	
+	viper.SetDefault("log.level", "info")
+	viper.SetDefault("log.format", "json")
+	viper.SetDefault("log.redact", true)
+	viper.SetDefault("log.sampling.initial", 100)
+	viper.SetDefault("log.sampling.thereafter", 100)
+	viper.SetDefault("log.access.enabled", true)
+	viper.SetDefault("log.access.sample_rate", 1.0)
+	viper.SetDefault("log.access.slow_threshold", 5000)
+
+	viper.SetDefault("pricing.currency", "USD")
+
+	viper.SetDefault("routing.enabled", false)
+	viper.SetDefault("routing.write_keywords", []string{
+		"create", "add", "update", "modify", "change", "set", "delete", "remove",
+		"enable", "disable", "scale", "drain", "migrate", "apply", "restart",
+	})
+	viper.SetDefault("routing.planning_keywords", []string{
+		"then", "after", "before", "plan", "steps", "step by step", "troubleshoot", "why", "compare",
+	})
+	viper.SetDefault("routing.max_simple_words", 25)
+	viper.SetDefault("routing.intents", false)
+
+	viper.SetDefault("results.max_items", 50)
+	viper.SetDefault("results.max_bytes", 48<<10)
+	viper.SetDefault("results.summarize_above", 16<<10)
+	viper.SetDefault("results.explain_errors", true)
+	viper.SetDefault("tools.generic_operation.methods", []string{"GET"})
+	viper.SetDefault("tools.generic_operation.max_body_bytes", 65536)
+
+	viper.SetDefault("audit.operator_header", "X-Remote-User")
+
+	viper.SetDefault("moderation.enabled", true)
+
+	viper.SetDefault("scheduler.enabled", false)
+	viper.SetDefault("scheduler.timezone", "UTC")
+	viper.SetDefault("scheduler.smtp.port", 587)
+
+	viper.SetDefault("alerts.enabled", false)
+	viper.SetDefault("alerts.max_alerts", 500)
+	viper.SetDefault("alerts.notify", true)
+
+	viper.SetDefault("workflows.max_runs", 100)
+
+	viper.SetDefault("context.max_tokens", 6000)
+	viper.SetDefault("context.keep_recent", 6)
+
+	viper.SetDefault("memory.enabled", false)
+	viper.SetDefault("memory.max_facts", 100)
+
+	viper.SetDefault("approvals.max_requests", 500)
+	viper.SetDefault("changes.max_changes", 1000)
+	viper.SetDefault("drift.enabled", false)
+	viper.SetDefault("drift.interval", 900)
+
+	viper.SetDefault("simulation.inventory_ttl", 300)
+
+	viper.SetDefault("secrets.refresh_interval", 300)
+
+	// Set environment variable bindings
+	viper.SetEnvPrefix("AVI_AGENT")
+	viper.AutomaticEnv()
+
+	// Bind specific environment variables
+	viper.BindEnv("avi.host", "AVI_HOST")
+	viper.BindEnv("avi.username", "AVI_USERNAME")
+	viper.BindEnv("avi.password", "AVI_PASSWORD")
+	viper.BindEnv("avi.password_file", "AVI_PASSWORD_FILE")
+	viper.BindEnv("avi.password_vault", "AVI_PASSWORD_VAULT")
+	viper.BindEnv("avi_users.mode", "AVI_USERS_MODE")
+	viper.BindEnv("avi_users.fallback", "AVI_USERS_FALLBACK")
+	viper.BindEnv("avi_users.proxy_secret", "AVI_USERS_PROXY_SECRET")
+	viper.BindEnv("avi.version", "AVI_VERSION")
+	viper.BindEnv("avi.tenant", "AVI_TENANT")
+	viper.BindEnv("avi.timeout", "AVI_TIMEOUT")
+	viper.BindEnv("avi.insecure", "AVI_INSECURE")
+	viper.BindEnv("avi.pinned_fingerprints", "AVI_PINNED_FINGERPRINTS")
+	viper.BindEnv("avi.auth_method", "AVI_AUTH_METHOD")
+	viper.BindEnv("avi.nodes", "AVI_NODES")
+	viper.BindEnv("avi.analytics_host", "AVI_ANALYTICS_HOST")
+	viper.BindEnv("avi.clock_skew_threshold", "AVI_CLOCK_SKEW_THRESHOLD")
+	viper.BindEnv("avi.least_privilege", "AVI_LEAST_PRIVILEGE")
+
+	viper.BindEnv("llm.ollama_host", "OLLAMA_HOST")
+	viper.BindEnv("llm.default_model", "OLLAMA_DEFAULT_MODEL")
+	viper.BindEnv("llm.models", "OLLAMA_MODELS")
+	viper.BindEnv("llm.timeout", "OLLAMA_TIMEOUT")
+	viper.BindEnv("llm.temperature", "OLLAMA_TEMPERATURE")
+	viper.BindEnv("llm.max_tokens", "OLLAMA_MAX_TOKENS")
+	viper.BindEnv("llm.json_mode", "OLLAMA_JSON_MODE")
+
+	viper.BindEnv("mistral.api_base_url", "MISTRAL_API_BASE_URL")
+	viper.BindEnv("mistral.api_key", "MISTRAL_API_KEY")
+	viper.BindEnv("mistral.api_key_file", "MISTRAL_API_KEY_FILE")
+	viper.BindEnv("mistral.api_key_vault", "MISTRAL_API_KEY_VAULT")
+	viper.BindEnv("mistral.default_model", "MISTRAL_DEFAULT_MODEL")
+	viper.BindEnv("mistral.models", "MISTRAL_MODELS")
+	viper.BindEnv("mistral.timeout", "MISTRAL_TIMEOUT")
+	viper.BindEnv("mistral.temperature", "MISTRAL_TEMPERATURE")
+	viper.BindEnv("mistral.max_tokens", "MISTRAL_MAX_TOKENS")
+	viper.BindEnv("mistral.max_retries", "MISTRAL_MAX_RETRIES")
+	viper.BindEnv("mistral.json_mode", "MISTRAL_JSON_MODE")
+
+	viper.BindEnv("server.host", "SERVER_HOST")
+	viper.BindEnv("server.port", "SERVER_PORT")
+	viper.BindEnv("server.read_timeout", "SERVER_READ_TIMEOUT")
+	viper.BindEnv("server.write_timeout", "SERVER_WRITE_TIMEOUT")
+	viper.BindEnv("server.idle_timeout", "SERVER_IDLE_TIMEOUT")
+	viper.BindEnv("server.debug_endpoints", "SERVER_DEBUG_ENDPOINTS")
+	viper.BindEnv("server.ui_enabled", "SERVER_UI_ENABLED")
+	viper.BindEnv("server.ui_dir", "SERVER_UI_DIR")
+	viper.BindEnv("server.ticket_url", "SERVER_TICKET_URL")
+	viper.BindEnv("server.cors_origins", "SERVER_CORS_ORIGINS")
+	viper.BindEnv("server.tls.cert_file", "SERVER_TLS_CERT_FILE")
+	viper.BindEnv("server.tls.key_file", "SERVER_TLS_KEY_FILE")
+	viper.BindEnv("server.tls.client_ca_file", "SERVER_TLS_CLIENT_CA_FILE")
+	viper.BindEnv("server.tls.client_auth", "SERVER_TLS_CLIENT_AUTH")
+	viper.BindEnv("server.tls.min_version", "SERVER_TLS_MIN_VERSION")
+	viper.BindEnv("server.security_headers.content_security_policy", "SERVER_CONTENT_SECURITY_POLICY")
+	viper.BindEnv("server.security_headers.hsts_max_age", "SERVER_HSTS_MAX_AGE")
+	viper.BindEnv("server.security_headers.frame_options", "SERVER_FRAME_OPTIONS")
+	viper.BindEnv("server.compression.enabled", "SERVER_COMPRESSION_ENABLED")
+	viper.BindEnv("server.static_max_age", "SERVER_STATIC_MAX_AGE")
+
+	viper.BindEnv("log.level", "LOG_LEVEL")
+	viper.BindEnv("log.format", "LOG_FORMAT")
+	viper.BindEnv("log.redact", "LOG_REDACT")
+	viper.BindEnv("log.access.enabled", "LOG_ACCESS_ENABLED")
+	viper.BindEnv("log.access.sample_rate", "LOG_ACCESS_SAMPLE_RATE")
+
+	viper.BindEnv("provider", "LLM_PROVIDER")
+
+	viper.BindEnv("routing.enabled", "ROUTING_ENABLED")
+	viper.BindEnv("routing.simple_model", "ROUTING_SIMPLE_MODEL")
+	viper.BindEnv("routing.complex_model", "ROUTING_COMPLEX_MODEL")
+	viper.BindEnv("routing.intents", "ROUTING_INTENTS")
+	viper.BindEnv("fallback.timeout", "FALLBACK_TIMEOUT")
+	viper.BindEnv("fallback_models", "FALLBACK_MODELS") // read below, as it lists fallback.models of the provider
+	viper.BindEnv("results.max_items", "RESULTS_MAX_ITEMS")
+	viper.BindEnv("results.max_bytes", "RESULTS_MAX_BYTES")
+	viper.BindEnv("results.fields", "RESULTS_FIELDS")
+	viper.BindEnv("results.summarize", "RESULTS_SUMMARIZE")
+	viper.BindEnv("results.summarize_above", "RESULTS_SUMMARIZE_ABOVE")
+	viper.BindEnv("results.explain_errors", "RESULTS_EXPLAIN_ERRORS")
+	viper.BindEnv("tools.enabled", "TOOLS_ENABLED")
+	viper.BindEnv("tools.disabled", "TOOLS_DISABLED")
+	viper.BindEnv("tools.generic_operation.methods", "GENERIC_OPERATION_METHODS")
+	viper.BindEnv("tools.generic_operation.endpoints", "GENERIC_OPERATION_ENDPOINTS")
+	viper.BindEnv("tools.generic_operation.max_body_bytes", "GENERIC_OPERATION_MAX_BODY_BYTES")
+
+	viper.BindEnv("audit.file", "AUDIT_FILE")
+	viper.BindEnv("audit.signing_key", "AUDIT_SIGNING_KEY")
+	viper.BindEnv("audit.operator_header", "AUDIT_OPERATOR_HEADER")
+	viper.BindEnv("audit.append_only", "AUDIT_APPEND_ONLY")
+
+	viper.BindEnv("sandbox.enabled", "SANDBOX_MODE")
+
+	viper.BindEnv("moderation.enabled", "MODERATION_ENABLED")
+	viper.BindEnv("moderation.model", "MODERATION_MODEL")
+
+	viper.BindEnv("insights.state_file", "INSIGHTS_STATE_FILE")
+
+	viper.BindEnv("scheduler.enabled", "SCHEDULER_ENABLED")
+	viper.BindEnv("scheduler.timezone", "SCHEDULER_TIMEZONE")
+	viper.BindEnv("scheduler.locale", "SCHEDULER_LOCALE")
+	viper.BindEnv("scheduler.smtp.host", "SMTP_HOST")
+	viper.BindEnv("scheduler.smtp.username", "SMTP_USERNAME")
+	viper.BindEnv("scheduler.smtp.password", "SMTP_PASSWORD")
+	viper.BindEnv("scheduler.smtp.from", "SMTP_FROM")
+
+	viper.BindEnv("alerts.enabled", "ALERTS_ENABLED")
+	viper.BindEnv("alerts.token", "ALERTS_TOKEN")
+	viper.BindEnv("alerts.max_alerts", "ALERTS_MAX_ALERTS")
+	viper.BindEnv("alerts.state_file", "ALERTS_STATE_FILE")
+	viper.BindEnv("alerts.summarize", "ALERTS_SUMMARIZE")
+	viper.BindEnv("alerts.model", "ALERTS_MODEL")
+	viper.BindEnv("alerts.notify", "ALERTS_NOTIFY")
+	viper.BindEnv("alerts.session", "ALERTS_SESSION")
+	viper.BindEnv("runbooks.token", "RUNBOOKS_TOKEN")
+	viper.BindEnv("admin.token", "ADMIN_TOKEN")
+	viper.BindEnv("workflows.state_file", "WORKFLOWS_STATE_FILE")
+	viper.BindEnv("style.no_emojis", "STYLE_NO_EMOJIS")
+	viper.BindEnv("style.include_uuids", "STYLE_INCLUDE_UUIDS")
+	viper.BindEnv("style.include_tenant", "STYLE_INCLUDE_TENANT")
+	viper.BindEnv("context.max_tokens", "CONTEXT_MAX_TOKENS")
+	viper.BindEnv("context.keep_recent", "CONTEXT_KEEP_RECENT")
+	viper.BindEnv("memory.enabled", "MEMORY_ENABLED")
+	viper.BindEnv("memory.state_file", "MEMORY_STATE_FILE")
+	viper.BindEnv("approvals.state_file", "APPROVALS_STATE_FILE")
+	viper.BindEnv("changes.state_file", "CHANGES_STATE_FILE")
+	viper.BindEnv("drift.enabled", "DRIFT_ENABLED")
+	viper.BindEnv("drift.directory", "DRIFT_DIRECTORY")
+	viper.BindEnv("blueprints.directory", "BLUEPRINTS_DIRECTORY")
+	viper.BindEnv("simulation.inventory_ttl", "SIMULATION_INVENTORY_TTL")
+	viper.BindEnv("secrets.vault_addr", "VAULT_ADDR")
+	viper.BindEnv("secrets.vault_token", "VAULT_TOKEN")
+	viper.BindEnv("secrets.vault_token_file", "VAULT_TOKEN_FILE")
+	viper.BindEnv("secrets.vault_namespace", "VAULT_NAMESPACE")
+	viper.BindEnv("secrets.refresh_interval", "SECRETS_REFRESH_INTERVAL")
+
+	// Load configuration file if it exists
+	if configPath != "" && fileExists(configPath) {
+		viper.SetConfigFile(configPath)
+		if err := viper.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+	}
+
+	// Unmarshal configuration
+	var cfg Config
+	if err := viper.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+
+	// Handle comma-separated environment variables for models and controller nodes
+	if ollamaModels := viper.GetString("OLLAMA_MODELS"); ollamaModels != "" {
+		cfg.LLM.Models = parseCommaSeparated(ollamaModels)
+	}
+	if mistralModels := viper.GetString("MISTRAL_MODELS"); mistralModels != "" {
+		cfg.Mistral.Models = parseCommaSeparated(mistralModels)
+	}
+	if aviNodes := viper.GetString("AVI_NODES"); aviNodes != "" {
+		cfg.Avi.Nodes = parseCommaSeparated(aviNodes)
+	}
+	if pins := viper.GetString("AVI_PINNED_FINGERPRINTS"); pins != "" {
+		cfg.Avi.PinnedFingerprints = parseCommaSeparated(pins)
+	}
+	if fallbacks := viper.GetString("fallback_models"); fallbacks != "" {
+		// Models of the configured provider
+		cfg.Fallback.Models = nil
+		for _, model := range parseCommaSeparated(fallbacks) {
+			cfg.Fallback.Models = append(cfg.Fallback.Models, FallbackModel{Model: model})
+		}
+	}
+	for i, origin := range cfg.Server.CORSOrigins {
+		cfg.Server.CORSOrigins[i] = strings.TrimSpace(origin)
+	}
+	for i, name := range cfg.Tools.Enabled {
+		cfg.Tools.Enabled[i] = strings.TrimSpace(name)
+	}
+	for i, name := range cfg.Tools.Disabled {
+		cfg.Tools.Disabled[i] = strings.TrimSpace(name)
+	}
+	for i, field := range cfg.Results.Fields {
+		cfg.Results.Fields[i] = strings.TrimSpace(field)
+	}
+	for i, method := range cfg.Tools.GenericOperation.Methods {
+		cfg.Tools.GenericOperation.Methods[i] = strings.ToUpper(strings.TrimSpace(method))
+	}
+	for i, endpoint := range cfg.Tools.GenericOperation.Endpoints {
+		cfg.Tools.GenericOperation.Endpoints[i] = strings.TrimSpace(endpoint)
+	}
+	for i := range cfg.Tools.Custom {
+		cfg.Tools.Custom[i].Name = strings.TrimSpace(cfg.Tools.Custom[i].Name)
+		cfg.Tools.Custom[i].Method = strings.ToUpper(strings.TrimSpace(cfg.Tools.Custom[i].Method))
+	}
+
+	// Controller addresses may be written as bracketed IPv6 literals ("[2001:db8::1]")
+	cfg.Avi.Host = trimIPv6Brackets(cfg.Avi.Host)
+	cfg.Avi.AnalyticsHost = trimIPv6Brackets(cfg.Avi.AnalyticsHost)
+	for i, node := range cfg.Avi.Nodes {
+		cfg.Avi.Nodes[i] = trimIPv6Brackets(node)
+	}
+
+	// Secrets referenced by file or Vault path replace the plaintext values
+	if err := ResolveSecrets(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to resolve secrets: %w", err)
+	}
+
+	// Validate required configuration
+	if err := validateConfig(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return &cfg, nil
+}
+
+// validateConfig validates required configuration values
+func validateConfig(cfg *Config) error {
+	// The sandbox supplies its own controller, so no credentials are needed in training mode
+	if !cfg.Sandbox.Enabled {
+		if cfg.Avi.Host == "" {
+			return fmt.Errorf("avi.host is required")
+		}
+		if cfg.Avi.Username == "" {
+			return fmt.Errorf("avi.username is required")
+		}
+		if cfg.Avi.Password == "" {
+			return fmt.Errorf("avi.password is required")
+		}
+		if _, err := cfg.Avi.CertificatePins(); err != nil {
+			return err
+		}
+		if cfg.Avi.Insecure && len(cfg.Avi.PinnedFingerprints) > 0 {
+			return fmt.Errorf("avi.insecure and avi.pinned_fingerprints can't both be set: pinning verifies the certificate insecure skips")
+		}
+	}
+
+	if tls := cfg.Server.TLS; (tls.CertFile == "") != (tls.KeyFile == "") {
+		return fmt.Errorf("server.tls.cert_file and server.tls.key_file must be set together")
+	} else if tls.ClientCAFile != "" && !tls.Enabled() {
+		return fmt.Errorf("server.tls.client_ca_file requires server.tls.cert_file and server.tls.key_file")
+	} else if tls.ClientAuth != "require" && tls.ClientAuth != "optional" {
+		return fmt.Errorf("unsupported server.tls.client_auth %q. Use 'require' or 'optional'", tls.ClientAuth)
+	} else if tls.MinVersion != "1.2" && tls.MinVersion != "1.3" {
+		return fmt.Errorf("unsupported server.tls.min_version %q. Use '1.2' or '1.3'", tls.MinVersion)
+	}
+
+	switch cfg.Server.SecurityHeaders.FrameOptions {
+	case "", "DENY", "SAMEORIGIN":
+	default:
+		return fmt.Errorf("unsupported server.security_headers.frame_options %q. Use 'DENY', 'SAMEORIGIN' or ''", cfg.Server.SecurityHeaders.FrameOptions)
+	}
+	if compression := cfg.Server.Compression; compression.Enabled && (compression.Level < 1 || compression.Level > 9) {
+		return fmt.Errorf("server.compression.level must be between 1 and 9, got %d", compression.Level)
+	}
+	for _, origin := range cfg.Server.CORSOrigins {
+		if origin != "*" && !strings.Contains(origin, "://") {
+			return fmt.Errorf("server.cors_origins: %q is not an origin such as https://ops.example.com", origin)
+		}
+	}
+
+	if cfg.Log.Format != "json" && cfg.Log.Format != "console" {
+		return fmt.Errorf("unsupported log.format %q. Use 'json' or 'console'", cfg.Log.Format)
+	}
+	if cfg.Log.Sampling.Initial < 0 || cfg.Log.Sampling.Thereafter < 0 {
+		return fmt.Errorf("log.sampling.initial and log.sampling.thereafter must not be negative")
+	}
+	if rate := cfg.Log.Access.SampleRate; rate < 0 || rate > 1 {
+		return fmt.Errorf("log.access.sample_rate must be between 0 and 1, got %g", rate)
+	}
+
+	if cfg.Context.MaxTokens < 0 || cfg.Context.KeepRecent < 0 {
+		return fmt.Errorf("context.max_tokens and context.keep_recent must not be negative")
+	}
+
+	// Validate based on provider
+	if cfg.Provider == "ollama" {
+		if cfg.LLM.OllamaHost == "" {
+			return fmt.Errorf("llm.ollama_host is required when using Ollama provider")
+		}
+		if len(cfg.LLM.Models) == 0 {
+			return fmt.Errorf("at least one LLM model must be configured for Ollama")
+		}
+	} else if cfg.Provider == "mistral" {
+		if cfg.Mistral.APIBaseURL == "" {
+			return fmt.Errorf("mistral.api_base_url is required when using Mistral provider")
+		}
+		if cfg.Mistral.APIKey == "" {
+			return fmt.Errorf("mistral.api_key is required when using Mistral provider")
+		}
+		if len(cfg.Mistral.Models) == 0 {
+			return fmt.Errorf("at least one Mistral model must be configured")
+		}
+	} else {
+		return fmt.Errorf("unsupported provider: %s. Use 'ollama' or 'mistral'", cfg.Provider)
+	}
+
+	for i, fallback := range cfg.Fallback.Models {
+		switch {
+		case fallback.Model == "":
+			return fmt.Errorf("fallback.models[%d]: a model is required", i)
+		case fallback.Provider == "" || fallback.Provider == cfg.Provider:
+		case fallback.Provider == "ollama":
+			if cfg.LLM.OllamaHost == "" {
+				return fmt.Errorf("fallback.models[%d]: llm.ollama_host is required for an Ollama fallback model", i)
+			}
+		case fallback.Provider == "mistral":
+			if cfg.Mistral.APIBaseURL == "" || cfg.Mistral.APIKey == "" {
+				return fmt.Errorf("fallback.models[%d]: mistral.api_base_url and mistral.api_key are required for a Mistral fallback model", i)
+			}
+		default:
+			return fmt.Errorf("fallback.models[%d]: unsupported provider %q. Use 'ollama' or 'mistral'", i, fallback.Provider)
+		}
+	}
+	if cfg.Fallback.Timeout < 0 {
+		return fmt.Errorf("fallback.timeout must not be negative")
+	}
+
+	if cfg.Results.MaxItems < 1 || cfg.Results.MaxBytes < 1 {
+		return fmt.Errorf("results.max_items and results.max_bytes must be positive")
+	}
+	if cfg.Results.Summarize && cfg.Results.SummarizeAbove < 1 {
+		return fmt.Errorf("results.summarize_above must be positive when results are summarized")
+	}
+
+	for _, method := range cfg.Tools.GenericOperation.Methods {
+		switch method {
+		case "GET", "POST", "PUT", "PATCH", "DELETE":
+		default:
+			return fmt.Errorf("unsupported tools.generic_operation.methods entry %q. Use GET, POST, PUT, PATCH or DELETE", method)
+		}
+	}
+	for _, endpoint := range cfg.Tools.GenericOperation.Endpoints {
+		if !strings.HasPrefix(endpoint, "/") {
+			return fmt.Errorf("tools.generic_operation.endpoints entry %q must be a path such as /pool", endpoint)
+		}
+	}
+	if cfg.Tools.GenericOperation.MaxBodyBytes <= 0 {
+		return fmt.Errorf("tools.generic_operation.max_body_bytes must be positive")
+	}
+
+	if cfg.Routing.Enabled && (cfg.Routing.SimpleModel == "" || cfg.Routing.ComplexModel == "") {
+		return fmt.Errorf("routing.simple_model and routing.complex_model are required when routing is enabled")
+	}
+
+	networks, err := cfg.AviUsers.TrustedNetworks()
+	if err != nil {
+		return err
+	}
+	switch cfg.AviUsers.Mode {
+	case "", "passthrough":
+	case "map":
+		// The operator header decides whose account is used, so only the proxy may set it
+		if len(networks) == 0 && cfg.AviUsers.ProxySecret == "" {
+			return fmt.Errorf("avi_users.mode map requires avi_users.trusted_proxies or avi_users.proxy_secret, so only the reverse proxy can name the operator")
+		}
+		operators := make(map[string]bool, len(cfg.AviUsers.Accounts))
+		for i, account := range cfg.AviUsers.Accounts {
+			operator := strings.ToLower(account.Operator)
+			switch {
+			case operator == "":
+				return fmt.Errorf("avi_users.accounts[%d]: an operator is required", i)
+			case operators[operator]:
+				return fmt.Errorf("avi_users.accounts[%d]: operator %s is listed twice", i, account.Operator)
+			case account.Password == "":
+				return fmt.Errorf("avi_users.accounts[%d]: a password is required for operator %s", i, account.Operator)
+			}
+			operators[operator] = true
+		}
+	default:
+		return fmt.Errorf("unsupported avi_users.mode %q. Use 'map' or 'passthrough'", cfg.AviUsers.Mode)
+	}
+	if cfg.AviUsers.Fallback != "deny" && cfg.AviUsers.Fallback != "shared" {
+		return fmt.Errorf("unsupported avi_users.fallback %q. Use 'deny' or 'shared'", cfg.AviUsers.Fallback)
+	}
+
+	if cfg.Alerts.Enabled && cfg.Alerts.Token == "" {
+		return fmt.Errorf("alerts.token is required when the alert receiver is enabled")
+	}
+
+	if len(cfg.Runbooks.Playbooks) > 0 && cfg.Runbooks.Token == "" {
+		return fmt.Errorf("runbooks.token is required when runbooks are configured")
+	}
+	runbooks := make(map[string]bool, len(cfg.Runbooks.Playbooks))
+	for i, runbook := range cfg.Runbooks.Playbooks {
+		switch {
+		case !runbookName.MatchString(runbook.Name):
+			return fmt.Errorf("runbooks.playbooks[%d]: invalid name %q, use letters, digits, '-' and '_'", i, runbook.Name)
+		case runbooks[runbook.Name]:
+			return fmt.Errorf("runbooks.playbooks[%d]: runbook %s is listed twice", i, runbook.Name)
+		case len(runbook.Steps) == 0:
+			return fmt.Errorf("runbook %s has no steps", runbook.Name)
+		}
+		runbooks[runbook.Name] = true
+		for j, step := range runbook.Steps {
+			if _, err := template.New(runbook.Name).Parse(step); err != nil {
+				return fmt.Errorf("runbook %s: invalid step %d: %w", runbook.Name, j+1, err)
+			}
+		}
+		for _, channel := range runbook.Channels {
+			if _, ok := cfg.Notifications.Channels[strings.ToLower(channel)]; !ok {
+				return fmt.Errorf("runbook %s: unknown notification channel %s", runbook.Name, channel)
+			}
+		}
+	}
+
+	if len(cfg.Approvals.Rules) > 0 && len(cfg.Approvals.Approvers) == 0 {
+		return fmt.Errorf("approvals.approvers is required when approval rules are configured")
+	}
+	for i, rule := range cfg.Approvals.Rules {
+		if rule.Name == "" {
+			return fmt.Errorf("approvals.rules[%d]: name is required", i)
+		}
+		if len(rule.Actions) == 0 && len(rule.Tools) == 0 && len(rule.Tenants) == 0 {
+			return fmt.Errorf("approval rule %s matches every change, set actions, tools or tenants", rule.Name)
+		}
+		for _, tool := range rule.Tools {
+			if _, err := path.Match(tool, ""); err != nil {
+				return fmt.Errorf("approval rule %s: invalid tool pattern %q", rule.Name, tool)
+			}
+		}
+	}
+	if len(cfg.Approvals.Rules) > 0 && cfg.AviUsers.Mode != "passthrough" && len(networks) == 0 && cfg.AviUsers.ProxySecret == "" {
+		// A second person named by a header anyone may send isn't a second person
+		return fmt.Errorf("approval rules require authenticated operators: avi_users.mode passthrough, or avi_users.trusted_proxies or avi_users.proxy_secret so only the reverse proxy can name the operator")
+	}
+
+	if cfg.Drift.Enabled && cfg.Drift.Directory == "" {
+		return fmt.Errorf("drift.directory is required when drift detection is enabled")
+	}
+	if cfg.Drift.Interval < 0 {
+		return fmt.Errorf("drift.interval must not be negative")
+	}
+
+	return nil
+}
+
+// Address returns the listen address of the web server, bracketing IPv6 bind addresses
+func (s ServerConfig) Address() string {
+	return net.JoinHostPort(trimIPv6Brackets(s.Host), strconv.Itoa(s.Port))
+}
+
+// trimIPv6Brackets removes the brackets around an IPv6 literal without a port
+func trimIPv6Brackets(host string) string {
+	host = strings.TrimSpace(host)
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		return host[1 : len(host)-1]
+	}
+	return host
+}
+
+// parseCommaSeparated parses comma-separated string to slice
+func parseCommaSeparated(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	var result []string
+	for _, item := range strings.Split(s, ",") {
+		trimmed := strings.TrimSpace(item)
+		if trimmed != "" {
+			result = append(result, trimmed)
+		}
+	}
+	return result
+}
+
+// fileExists checks if a file exists
+func fileExists(filename string) bool {
+	_, err := os.Stat(filename)
+	return !os.IsNotExist(err)
+}
\ No newline at end of file
@@ -0,0 +1,510 @@
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadYAML loads a configuration file with the given content into a fresh viper
+func loadYAML(t *testing.T, content string) (*Config, error) {
+	t.Helper()
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return Load(path)
+}
+
+func TestEstimateCost(t *testing.T) {
+	pricing := PricingConfig{Models: []ModelPricing{
+		{Model: "llama3.2", InputPerMillion: 1, OutputPerMillion: 2},
+		{Model: "mistral-small-3.1", InputPerMillion: 0.1, OutputPerMillion: 0.3},
+	}}
+
+	cost, ok := pricing.EstimateCost("llama3.2", 500000, 250000)
+	assert.True(t, ok)
+	assert.InDelta(t, 1.0, cost, 1e-9)
+
+	cost, ok = pricing.EstimateCost("Mistral-Small-3.1", 1000000, 1000000)
+	assert.True(t, ok, "model names match case-insensitively")
+	assert.InDelta(t, 0.4, cost, 1e-9)
+
+	_, ok = pricing.EstimateCost("llama3", 1000, 1000)
+	assert.False(t, ok)
+}
+
+func TestLoadPricing(t *testing.T) {
+	cfg, err := loadYAML(t, `
+avi:
+  host: controller.example.com
+  username: admin
+  password: secret
+pricing:
+  currency: EUR
+  models:
+    - model: llama3.2
+      input_per_million: 0.5
+      output_per_million: 1.5
+`)
+	require.NoError(t, err)
+	assert.Equal(t, "EUR", cfg.Pricing.Currency)
+	require.Len(t, cfg.Pricing.Models, 1)
+	assert.Equal(t, "llama3.2", cfg.Pricing.Models[0].Model, "dotted model names are kept whole")
+	_, ok := cfg.Pricing.EstimateCost("llama3.2", 1000, 1000)
+	assert.True(t, ok)
+}
+
+// newVaultServer returns a Vault answering reads of the given paths with their data, expecting token
+func newVaultServer(t *testing.T, token string, secrets map[string]interface{}) SecretsConfig {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != token {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		data, ok := secrets[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	t.Cleanup(server.Close)
+	return SecretsConfig{VaultAddr: server.URL, VaultToken: token}
+}
+
+func TestReadVaultSecret(t *testing.T) {
+	secrets := newVaultServer(t, "s.token", map[string]interface{}{
+		"/v1/kv/aviagent": map[string]interface{}{"password": "v1-secret"},
+		"/v1/secret/data/aviagent": map[string]interface{}{
+			"data":     map[string]interface{}{"password": "v2-secret"},
+			"metadata": map[string]interface{}{"version": 3},
+		},
+	})
+
+	value, err := readVaultSecret(secrets, "kv/aviagent#password")
+	require.NoError(t, err)
+	assert.Equal(t, "v1-secret", value, "KV version 1")
+
+	value, err = readVaultSecret(secrets, "/secret/data/aviagent#password")
+	require.NoError(t, err)
+	assert.Equal(t, "v2-secret", value, "KV version 2")
+
+	_, err = readVaultSecret(secrets, "secret/data/aviagent#api_key")
+	assert.ErrorContains(t, err, "has no field api_key")
+
+	_, err = readVaultSecret(secrets, "secret/data/other#password")
+	assert.ErrorContains(t, err, "HTTP 404")
+
+	_, err = readVaultSecret(secrets, "secret/data/aviagent")
+	assert.ErrorContains(t, err, "expected path#field")
+
+	secrets.VaultToken = "s.wrong"
+	_, err = readVaultSecret(secrets, "kv/aviagent#password")
+	assert.ErrorContains(t, err, "HTTP 403")
+
+	// The token file wins over the token and is read on every call
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("s.token\n"), 0o600))
+	secrets.VaultTokenFile = tokenFile
+	value, err = readVaultSecret(secrets, "kv/aviagent#password")
+	require.NoError(t, err)
+	assert.Equal(t, "v1-secret", value)
+}
+
+func TestResolveSecrets(t *testing.T) {
+	secrets := newVaultServer(t, "s.token", map[string]interface{}{
+		"/v1/kv/mistral": map[string]interface{}{"api_key": "vault-key"},
+	})
+	passwordFile := filepath.Join(t.TempDir(), "avi-password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("file-password\n"), 0o600))
+
+	cfg := &Config{Secrets: secrets}
+	cfg.Avi.Password = "configured"
+	cfg.Avi.PasswordFile = passwordFile
+	cfg.Mistral.APIKeyVault = "kv/mistral#api_key"
+	require.NoError(t, ResolveSecrets(cfg))
+	assert.Equal(t, "file-password", cfg.Avi.Password)
+	assert.Equal(t, "vault-key", cfg.Mistral.APIKey)
+
+	// A rotated file is picked up by the next resolve
+	require.NoError(t, os.WriteFile(passwordFile, []byte("rotated-password"), 0o600))
+	require.NoError(t, ResolveSecrets(cfg))
+	assert.Equal(t, "rotated-password", cfg.Avi.Password)
+
+	cfg.Avi.PasswordVault = "kv/avi#password"
+	assert.ErrorContains(t, ResolveSecrets(cfg), "avi.password_file and avi.password_vault can't both be set")
+
+	cfg.Avi.PasswordFile, cfg.Avi.PasswordVault = "", ""
+	cfg.Mistral.APIKeyVault = "kv/mistral#token"
+	assert.ErrorContains(t, ResolveSecrets(cfg), "mistral.api_key_vault: Vault secret kv/mistral has no field token")
+}
+
+func TestLoadAviUserAccounts(t *testing.T) {
+	const base = `
+avi:
+  host: controller.example.com
+  username: admin
+  password: secret
+`
+	cfg, err := loadYAML(t, base+`
+avi_users:
+  mode: map
+  trusted_proxies: ["10.0.0.5", "192.168.10.0/24"]
+  accounts:
+    - operator: alice.smith@example.com
+      username: asmith
+      password: one
+    - operator: bob
+      password: two
+`)
+	require.NoError(t, err)
+	require.Len(t, cfg.AviUsers.Accounts, 2)
+	assert.Equal(t, "alice.smith@example.com", cfg.AviUsers.Accounts[0].Operator, "dotted operator names are kept whole")
+	assert.Equal(t, "asmith", cfg.AviUsers.Accounts[0].Username)
+	assert.Equal(t, "X-Proxy-Secret", cfg.AviUsers.ProxySecretHeader)
+	networks, err := cfg.AviUsers.TrustedNetworks()
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.5/32", networks[0].String())
+	assert.Equal(t, "192.168.10.0/24", networks[1].String())
+
+	_, err = loadYAML(t, base+`
+avi_users:
+  mode: map
+  accounts:
+    - operator: bob
+      password: two
+`)
+	assert.ErrorContains(t, err, "requires avi_users.trusted_proxies or avi_users.proxy_secret")
+
+	_, err = loadYAML(t, base+`
+avi_users:
+  mode: map
+  proxy_secret: shared
+  accounts:
+    - operator: bob
+      password: two
+    - operator: Bob
+      password: three
+`)
+	assert.ErrorContains(t, err, "operator Bob is listed twice")
+
+	_, err = loadYAML(t, base+`
+avi_users:
+  mode: map
+  trusted_proxies: ["proxy.example.com"]
+`)
+	assert.ErrorContains(t, err, "is not an address or CIDR")
+}
+
+func TestLoadCertificatePins(t *testing.T) {
+	const base = `
+avi:
+  host: controller.example.com
+  username: admin
+  password: secret
+`
+	cfg, err := loadYAML(t, base+`
+  pinned_fingerprints:
+    - "AB:CD:EF:01:23:45:67:89:AB:CD:EF:01:23:45:67:89:AB:CD:EF:01:23:45:67:89:AB:CD:EF:01:23:45:67:89"
+    - "sha256//AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+`)
+	require.NoError(t, err)
+	pins, err := cfg.Avi.CertificatePins()
+	require.NoError(t, err)
+	require.Len(t, pins, 2)
+	assert.False(t, pins[0].PublicKey)
+	assert.Equal(t, byte(0xab), pins[0].Digest[0])
+	assert.True(t, pins[1].PublicKey)
+
+	_, err = loadYAML(t, base+`
+  pinned_fingerprints: ["AB:CD:EF"]
+`)
+	assert.ErrorContains(t, err, "is not a SHA-256 certificate fingerprint")
+
+	_, err = loadYAML(t, base+`
+  insecure: true
+  pinned_fingerprints: ["sha256/AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="]
+`)
+	assert.ErrorContains(t, err, "can't both be set")
+}
+
+func TestLoadRunbooks(t *testing.T) {
+	const base = `
+avi:
+  host: controller.example.com
+  username: admin
+  password: secret
+notifications:
+  channels:
+    ops:
+      url: https://hooks.example.com/ops
+`
+	cfg, err := loadYAML(t, base+`
+runbooks:
+  token: s3cret
+  playbooks:
+    - name: pool-check
+      parameters: [pool]
+      steps: ["Is pool {{.pool}} up?"]
+      channels: [Ops]
+`)
+	require.NoError(t, err)
+	runbook, ok := cfg.Runbooks.Playbook("pool-check")
+	require.True(t, ok)
+	assert.Equal(t, []string{"pool"}, runbook.Parameters)
+
+	_, err = loadYAML(t, base+`
+runbooks:
+  playbooks:
+    - name: pool-check
+      steps: ["Are the pools up?"]
+`)
+	assert.ErrorContains(t, err, "runbooks.token is required")
+
+	_, err = loadYAML(t, base+`
+runbooks:
+  token: s3cret
+  playbooks:
+    - name: pool check
+      steps: ["Are the pools up?"]
+`)
+	assert.ErrorContains(t, err, `invalid name "pool check"`)
+
+	_, err = loadYAML(t, base+`
+runbooks:
+  token: s3cret
+  playbooks:
+    - name: pool-check
+      steps: ["Is pool {{.pool up?"]
+`)
+	assert.ErrorContains(t, err, "invalid step 1")
+
+	_, err = loadYAML(t, base+`
+runbooks:
+  token: s3cret
+  playbooks:
+    - name: pool-check
+      steps: ["Are the pools up?"]
+      channels: [pager]
+`)
+	assert.ErrorContains(t, err, "unknown notification channel pager")
+}
+
+func TestLoadFallback(t *testing.T) {
+	const base = `
+avi:
+  host: controller.example.com
+  username: admin
+  password: secret
+`
+	cfg, err := loadYAML(t, base+`
+fallback:
+  timeout: 20
+  models:
+    - model: llama3.1:8b
+    - model: mistral-small-latest
+      provider: mistral
+mistral:
+  api_key: key
+`)
+	require.NoError(t, err)
+	assert.Equal(t, []FallbackModel{{Model: "llama3.1:8b"}, {Model: "mistral-small-latest", Provider: "mistral"}}, cfg.Fallback.Models)
+
+	t.Setenv("FALLBACK_MODELS", "llama3.1:8b, qwen2.5")
+	cfg, err = loadYAML(t, base)
+	require.NoError(t, err)
+	assert.Equal(t, []FallbackModel{{Model: "llama3.1:8b"}, {Model: "qwen2.5"}}, cfg.Fallback.Models)
+	t.Setenv("FALLBACK_MODELS", "")
+
+	_, err = loadYAML(t, base+`
+fallback:
+  models:
+    - model: mistral-small-latest
+      provider: mistral
+`)
+	assert.ErrorContains(t, err, "mistral.api_key are required for a Mistral fallback model")
+
+	_, err = loadYAML(t, base+`
+fallback:
+  models:
+    - model: gpt-4o
+      provider: openai
+`)
+	assert.ErrorContains(t, err, `unsupported provider "openai"`)
+}
+
+func TestLoadResults(t *testing.T) {
+	const base = `
+avi:
+  host: controller.example.com
+  username: admin
+  password: secret
+`
+	cfg, err := loadYAML(t, base)
+	require.NoError(t, err)
+	assert.Equal(t, ResultsConfig{MaxItems: 50, MaxBytes: 48 << 10, SummarizeAbove: 16 << 10, ExplainErrors: true}, cfg.Results)
+
+	t.Setenv("RESULTS_FIELDS", "enabled, lb_algorithm")
+	t.Setenv("RESULTS_SUMMARIZE", "true")
+	t.Setenv("RESULTS_EXPLAIN_ERRORS", "false")
+	cfg, err = loadYAML(t, base)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"enabled", "lb_algorithm"}, cfg.Results.Fields)
+	assert.True(t, cfg.Results.Summarize)
+	assert.False(t, cfg.Results.ExplainErrors)
+
+	_, err = loadYAML(t, base+`
+results:
+  max_items: 0
+`)
+	assert.ErrorContains(t, err, "results.max_items and results.max_bytes must be positive")
+}
+
+func TestLoadTools(t *testing.T) {
+	const base = `
+avi:
+  host: controller.example.com
+  username: admin
+  password: secret
+`
+	cfg, err := loadYAML(t, base+`
+tools:
+  disabled: [execute_generic_operation, delete_virtual_service]
+`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"execute_generic_operation", "delete_virtual_service"}, cfg.Tools.Disabled)
+	assert.Empty(t, cfg.Tools.Enabled)
+	assert.Equal(t, GenericOperationConfig{Methods: []string{"GET"}, MaxBodyBytes: 65536}, cfg.Tools.GenericOperation)
+
+	t.Setenv("TOOLS_ENABLED", "list_pools, get_pool")
+	cfg, err = loadYAML(t, base)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"list_pools", "get_pool"}, cfg.Tools.Enabled)
+
+	cfg, err = loadYAML(t, base+`
+tools:
+  generic_operation:
+    methods: [get, patch]
+    endpoints: [/pool, /healthmonitor]
+`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"GET", "PATCH"}, cfg.Tools.GenericOperation.Methods)
+	assert.Equal(t, []string{"/pool", "/healthmonitor"}, cfg.Tools.GenericOperation.Endpoints)
+
+	_, err = loadYAML(t, base+`
+tools:
+  generic_operation:
+    methods: [GET, TRACE]
+`)
+	assert.ErrorContains(t, err, `unsupported tools.generic_operation.methods entry "TRACE"`)
+
+	_, err = loadYAML(t, base+`
+tools:
+  generic_operation:
+    endpoints: [pool]
+`)
+	assert.ErrorContains(t, err, "must be a path such as /pool")
+
+	cfg, err = loadYAML(t, base+`
+tools:
+  custom:
+    - name: " scale_out_vs "
+      description: Scale out a virtual service
+      method: post
+      endpoint: /virtualservice/{uuid}/scaleout
+      parameters:
+        type: object
+        properties:
+          uuid: {type: string}
+        required: [uuid]
+`)
+	require.NoError(t, err)
+	require.Len(t, cfg.Tools.Custom, 1)
+	assert.Equal(t, "scale_out_vs", cfg.Tools.Custom[0].Name)
+	assert.Equal(t, "POST", cfg.Tools.Custom[0].Method)
+	assert.Equal(t, "/virtualservice/{uuid}/scaleout", cfg.Tools.Custom[0].Endpoint)
+	assert.Equal(t, map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"uuid": map[string]interface{}{"type": "string"}},
+		"required":   []interface{}{"uuid"},
+	}, cfg.Tools.Custom[0].Parameters)
+}
+
+func TestLoadApprovals(t *testing.T) {
+	const base = `
+avi:
+  host: controller.example.com
+  username: admin
+  password: secret
+`
+	cfg, err := loadYAML(t, base+`
+avi_users:
+  trusted_proxies: [10.0.0.0/8]
+approvals:
+  approvers: [carol]
+  rules:
+    - name: deletes
+      actions: [delete]
+    - name: prod
+      tenants: [prod]
+      tools: ["*_virtual_service"]
+`)
+	require.NoError(t, err)
+	require.Len(t, cfg.Approvals.Rules, 2)
+	assert.Equal(t, []string{"prod"}, cfg.Approvals.Rules[1].Tenants)
+	assert.Equal(t, 500, cfg.Approvals.MaxRequests)
+
+	// The approver must be authenticated, not named by a header anyone may send
+	_, err = loadYAML(t, base+`
+approvals:
+  approvers: [carol]
+  rules:
+    - name: deletes
+      actions: [delete]
+`)
+	assert.ErrorContains(t, err, "approval rules require authenticated operators")
+	_, err = loadYAML(t, base+`
+avi_users:
+  mode: passthrough
+approvals:
+  approvers: [carol]
+  rules:
+    - name: deletes
+      actions: [delete]
+`)
+	assert.NoError(t, err)
+
+	_, err = loadYAML(t, base+`
+approvals:
+  rules:
+    - name: deletes
+      actions: [delete]
+`)
+	assert.ErrorContains(t, err, "approvals.approvers is required")
+
+	_, err = loadYAML(t, base+`
+approvals:
+  approvers: [carol]
+  rules:
+    - name: everything
+`)
+	assert.ErrorContains(t, err, "approval rule everything matches every change")
+
+	_, err = loadYAML(t, base+`
+approvals:
+  approvers: [carol]
+  rules:
+    - name: deletes
+      tools: ["delete_[pool"]
+`)
+	assert.ErrorContains(t, err, `invalid tool pattern "delete_[pool"`)
+}
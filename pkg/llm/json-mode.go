@@ -0,0 +1,31 @@
+package llm
+
+import "sync"
+
+// JSONModeSupport remembers the models that rejected JSON mode, so providers stop requesting it
+// from them. The zero value is ready to use.
+type JSONModeSupport struct {
+	mu          sync.Mutex
+	unsupported map[string]bool
+}
+
+// Enabled reports whether JSON mode should be requested for the model when the provider is
+// configured to use it
+func (j *JSONModeSupport) Enabled(configured bool, model string) bool {
+	if !configured {
+		return false
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return !j.unsupported[model]
+}
+
+// SetUnsupported stops requesting JSON mode for a model that rejected it
+func (j *JSONModeSupport) SetUnsupported(model string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.unsupported == nil {
+		j.unsupported = make(map[string]bool)
+	}
+	j.unsupported[model] = true
+}
@@ -7,9 +7,12 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
-	"aviagent/internal/config"
+	"aviagent/internal/requestid"
+	"aviagent/pkg/audit"
+	"aviagent/pkg/config"
 
 	"go.uber.org/zap"
 )
@@ -19,6 +22,9 @@ type Client struct {
 	config     *config.LLMConfig
 	httpClient *http.Client
 	logger     *zap.Logger
+
+	// jsonMode remembers models that rejected format "json"
+	jsonMode JSONModeSupport
 }
 
 // ChatMessage represents a chat message
@@ -45,9 +51,16 @@ type ChatRequest struct {
 	Model       string        `json:"model"`
 	Messages    []ChatMessage `json:"messages"`
 	Tools       []Tool        `json:"tools,omitempty"`
+	Format      string        `json:"format,omitempty"` // "json" constrains the output to a JSON object
 	Stream      bool          `json:"stream"`
 	Temperature float64       `json:"temperature,omitempty"`
 	MaxTokens   int           `json:"max_tokens,omitempty"`
+	Options     *ChatOptions  `json:"options,omitempty"`
+}
+
+// ChatOptions are Ollama model options
+type ChatOptions struct {
+	Seed *int `json:"seed,omitempty"` // fixed sampling seed for reproducible output
 }
 
 // ChatResponse represents a chat completion response
@@ -70,6 +83,8 @@ type ToolCall struct {
 	Type     string                 `json:"type"`
 	Function ToolCallFunction       `json:"function"`
 	Args     map[string]interface{} `json:"args,omitempty"`
+	// InvocationID identifies the executed call so it can be re-run, set by the server
+	InvocationID string `json:"invocation_id,omitempty"`
 }
 
 // ToolCallFunction represents the function part of a tool call
@@ -169,6 +184,7 @@ func (c *Client) ChatCompletion(ctx context.Context, req ChatRequest) (*ChatResp
 	}
 
 	httpReq.Header.Set("Content-Type", "application/json")
+	requestid.SetHeader(ctx, httpReq)
 
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
@@ -178,6 +194,17 @@ func (c *Client) ChatCompletion(ctx context.Context, req ChatRequest) (*ChatResp
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
+
+		// Older Ollama versions and some models reject JSON mode, retry with free-form output
+		if req.Format != "" && isFormatRejection(resp.StatusCode, body) {
+			requestid.Logger(ctx, c.logger).Warn("Ollama rejected JSON mode, falling back to free-form output",
+				zap.String("model", req.Model),
+				zap.String("error", string(body)))
+			c.jsonMode.SetUnsupported(req.Model)
+			req.Format = ""
+			return c.ChatCompletion(ctx, req)
+		}
+
 		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
@@ -189,8 +216,31 @@ func (c *Client) ChatCompletion(ctx context.Context, req ChatRequest) (*ChatResp
 	return &chatResp, nil
 }
 
+// isFormatRejection reports whether an error response was caused by the format of the request,
+// rather than by another problem such as an unknown model or an oversized prompt
+func isFormatRejection(status int, body []byte) bool {
+	if status != http.StatusBadRequest {
+		return false
+	}
+	return strings.Contains(strings.ToLower(string(body)), "format")
+}
+
 // processNaturalLanguageQueryInternal processes a natural language query and returns tool calls (internal implementation)
 func (c *Client) processNaturalLanguageQueryInternal(ctx context.Context, query, model string, tools []Tool, conversationHistory []ChatMessage) (*LLMResponse, error) {
+	chatReq := c.buildChatRequest(ctx, query, model, tools, conversationHistory)
+
+	// Send request to Ollama
+	chatResp, err := c.ChatCompletion(ctx, chatReq)
+	if err != nil {
+		return nil, fmt.Errorf("chat completion failed: %w", err)
+	}
+
+	// Process response and extract tool calls
+	return c.processLLMResponse(chatResp)
+}
+
+// buildChatRequest renders the system prompt, conversation history, query and tools into the request sent to Ollama
+func (c *Client) buildChatRequest(ctx context.Context, query, model string, tools []Tool, conversationHistory []ChatMessage) ChatRequest {
 	// Build messages including conversation history
 	messages := make([]ChatMessage, 0, len(conversationHistory)+2)
 	
@@ -199,6 +249,10 @@ func (c *Client) processNaturalLanguageQueryInternal(ctx context.Context, query,
 		Role:    "system",
 		Content: c.buildSystemPrompt(),
 	}
+	jsonMode := c.jsonMode.Enabled(c.config.JSONMode, model)
+	if jsonMode {
+		systemMessage.Content += JSONModeInstruction
+	}
 	messages = append(messages, systemMessage)
 
 	// Add conversation history
@@ -221,22 +275,66 @@ func (c *Client) processNaturalLanguageQueryInternal(ctx context.Context, query,
 		MaxTokens:   c.config.MaxTokens,
 	}
 
-	// Send request to Ollama
-	chatResp, err := c.ChatCompletion(ctx, chatReq)
-	if err != nil {
-		return nil, fmt.Errorf("chat completion failed: %w", err)
+	if jsonMode {
+		chatReq.Format = "json"
 	}
 
-	// Process response and extract tool calls
-	return c.processLLMResponse(chatResp)
+	if seed, ok := SeedFrom(ctx); ok {
+		chatReq.Options = &ChatOptions{Seed: &seed}
+	}
+
+	return chatReq
 }
 
 // LLMResponse represents a processed LLM response
 type LLMResponse struct {
-	Message   string     `json:"message"`
-	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
-	Model     string     `json:"model"`
-	Usage     Usage      `json:"usage"`
+	Message     string          `json:"message"`
+	ToolCalls   []ToolCall      `json:"tool_calls,omitempty"`
+	Model       string          `json:"model"`
+	Usage       Usage           `json:"usage"`
+	Notices     []string        `json:"notices,omitempty"`      // provider status notes shown to the user (e.g. rate limit retries)
+	ToolErrors  []ToolError     `json:"tool_errors,omitempty"`  // tool calls that failed, also described in Message
+	Downloads   []Download      `json:"downloads,omitempty"`    // files returned by tool calls, downloaded through the API proxy
+	Approvals   []string        `json:"approvals,omitempty"`    // invocation IDs of tool calls held until the operator approves them
+	Receipts    []audit.Receipt `json:"receipts,omitempty"`     // changes made by tool calls, for change tickets to reference
+	Provider    string          `json:"provider,omitempty"`     // provider of the model that answered
+	Fallbacks   []ModelFailure  `json:"fallbacks,omitempty"`    // models that failed, in order, before Model answered
+	ToolResults []ToolResult    `json:"tool_results,omitempty"` // outcome of each tool call, for frontends to render
+}
+
+// Tool result statuses
+const (
+	ToolResultOK       = "ok"
+	ToolResultError    = "error"
+	ToolResultDeclined = "declined"
+	ToolResultPending  = "awaiting_approval"
+	ToolResultInput    = "awaiting_input" // held until the operator gives the arguments it lacks
+)
+
+// ToolResult is the outcome of a tool call of an answer, typed for frontends that render it
+// (as a table, say) rather than read it from the message
+type ToolResult struct {
+	Tool         string                 `json:"tool"`
+	InvocationID string                 `json:"invocation_id,omitempty"`
+	Arguments    map[string]interface{} `json:"arguments,omitempty"`
+	Status       string                 `json:"status"`
+	Data         interface{}            `json:"data,omitempty"`    // the result as added for the model: projected, cut and redacted
+	Summary      string                 `json:"summary,omitempty"` // the summary added in place of a result too large
+	Error        string                 `json:"error,omitempty"`
+}
+
+// ModelFailure is a model of the fallback chain that failed to answer
+type ModelFailure struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Reason   string `json:"reason"`
+}
+
+// Download is a link to a file a tool call returned
+type Download struct {
+	Filename string `json:"filename"`
+	URL      string `json:"url"`
+	Size     int64  `json:"size,omitempty"`
 }
 
 // Usage represents token usage statistics
@@ -266,6 +364,8 @@ func (c *Client) processLLMResponse(chatResp *ChatResponse) (*LLMResponse, error
 		c.logger.Warn("Failed to extract tool calls", zap.Error(err))
 	} else if len(toolCalls) > 0 {
 		response.ToolCalls = toolCalls
+	} else {
+		response.Message = UnwrapJSONMessage(response.Message)
 	}
 
 	return response, nil
@@ -302,6 +402,68 @@ func (c *Client) extractToolCalls(content string) ([]ToolCall, error) {
 	return toolCalls, nil
 }
 
+// Completer sends a single prompt to an LLM and returns the reply
+type Completer interface {
+	Complete(ctx context.Context, model, system, prompt string) (string, error)
+}
+
+type seedKey struct{}
+
+// WithSeed returns a context that asks the LLM provider to sample with a fixed seed, so a
+// turn can be reproduced when investigating a bad tool selection
+func WithSeed(ctx context.Context, seed int) context.Context {
+	return context.WithValue(ctx, seedKey{}, seed)
+}
+
+// SeedFrom returns the sampling seed stored in the context, if any
+func SeedFrom(ctx context.Context) (int, bool) {
+	seed, ok := ctx.Value(seedKey{}).(int)
+	return seed, ok
+}
+
+type statusKey struct{}
+
+// WithStatus returns a context whose provider calls report their progress, such as a rate limit
+// retry, to report while the answer is still being produced
+func WithStatus(ctx context.Context, report func(status string)) context.Context {
+	return context.WithValue(ctx, statusKey{}, report)
+}
+
+// ReportStatus passes a status update to the reporter of the context, if any
+func ReportStatus(ctx context.Context, status string) {
+	if report, ok := ctx.Value(statusKey{}).(func(string)); ok && report != nil {
+		report(status)
+	}
+}
+
+// JSONModeInstruction is appended to the system prompt when the provider constrains output to JSON,
+// so answers that don't need a tool still come back as a parseable object
+const JSONModeInstruction = `
+Your reply must always be a single JSON object. When no API operation is needed, reply with:
+{"message": "your answer to the user"}
+`
+
+// UnwrapJSONMessage returns the "message" field of a JSON-mode answer, or the content unchanged
+// when it isn't one (free-form output from a provider without JSON mode)
+func UnwrapJSONMessage(content string) string {
+	trimmed := strings.TrimSpace(content)
+	if !strings.HasPrefix(trimmed, "{") {
+		return content
+	}
+
+	var answer map[string]interface{}
+	if err := json.Unmarshal([]byte(trimmed), &answer); err != nil {
+		return content
+	}
+	for _, key := range []string{"message", "response", "answer"} {
+		if msg, ok := answer[key].(string); ok {
+			return msg
+		}
+	}
+	return content
+}
+
+
 // buildSystemPrompt creates the system prompt for the LLM
 func (c *Client) buildSystemPrompt() string {
 	return `You are an intelligent assistant for VMware Avi Load Balancer management. Your role is to help users interact with the Avi Load Balancer API using natural language queries.
@@ -314,11 +476,25 @@ When users ask questions about Avi Load Balancer, you should:
 4. Provide context and explanations for the data returned
 
 You have access to the following types of operations:
-- Virtual Service management (list, create, update, delete, scale)
+- Virtual Service management (list, create, update, delete, enable/disable, scale)
 - Pool management (list, create, update, scale out/in)
 - Health Monitor management (list, create, update)
-- Service Engine management (list, status, metrics)
-- Analytics and monitoring data retrieval
+- Application and persistence profiles (HTTP/2 settings, cookie persistence)
+- Service Engine management (list, status, metrics) and maintenance: disable a service engine, wait for its virtual services to migrate, confirm they are healthy and enable it again, one approved step at a time
+- Resuming interrupted multi-step runs (configuration applies, service engine maintenance) from their last completed step
+- Session notes: remember facts the user states for the session (change windows, the object being troubleshot) and recall them
+- Routing (VRF contexts, static routes, BGP peers and peering state)
+- Health scores and operational status (why an object is degraded), incident summaries correlating spikes with pool member failures, acknowledging or snoozing insights
+- SLA and uptime reports: availability, outages and remaining error budget per virtual service over a period
+- Alert rules from plain requests ("alert me when the error rate exceeds 2% for 5 minutes"): preview the rule, create it once the user approves, list and remove rules created in the chat
+- Security audits (TLS grade, certificates, HSTS and security headers, WAF)
+- "What if" simulations (removing a server, deleting a pool or health monitor, disabling a virtual service or service engine): remaining capacity, affected virtual services and monitors, computed without changing anything; answer what-if questions with these, never with the change itself
+- Controller version, cluster state, upgrade status and license capacity
+- Tenant, user and role administration (administrator accounts only)
+- Controller backups, configuration export and configuration import/apply
+- Tech-support bundle collection for support cases, at controller, service engine, virtual service or pool level
+- File downloads (tech-support bundles, packet captures, exports) offered as links to the user
+- Analytics and monitoring data retrieval (metric IDs such as l4_client.avg_bandwidth over a time range)
 
 When you need to perform an API operation, respond with a JSON object containing:
 {
@@ -329,12 +505,15 @@ When you need to perform an API operation, respond with a JSON object containing
   }
 }
 
+` + TruncatedResultsPrompt + `
+
 Always provide clear, helpful responses and ask for clarification if the user's request is ambiguous.
 
 Examples:
 - "List all virtual services" → {"tool": "list_virtual_services", "parameters": {}}
 - "Show me pools with health issues" → {"tool": "list_pools", "parameters": {"health_status": "down"}}
 - "Create a new pool with servers 10.1.1.10 and 10.1.1.11" → {"tool": "create_pool", "parameters": {"name": "new_pool", "servers": [{"ip": {"addr": "10.1.1.10", "type": "V4"}}, {"ip": {"addr": "10.1.1.11", "type": "V4"}}]}}
+- "Create a pool web6 with server 2001:db8::10 on port 8080" → {"tool": "create_pool", "parameters": {"name": "web6", "servers": [{"ip": {"addr": "2001:db8::10", "type": "V6"}, "port": 8080}]}}
 `
 }
 
@@ -368,6 +547,35 @@ func (c *Client) ProcessNaturalLanguageQuery(ctx context.Context, query, model s
 	return c.processNaturalLanguageQueryInternal(ctx, query, model, ollamaTools, ollamaHistory)
 }
 
+// Complete sends a single system and user message without tools and returns the reply text.
+// It is used for auxiliary tasks such as moderation rather than operator chat.
+func (c *Client) Complete(ctx context.Context, model, system, prompt string) (string, error) {
+	resp, err := c.ChatCompletion(ctx, ChatRequest{
+		Model: model,
+		Messages: []ChatMessage{
+			{Role: "system", Content: system},
+			{Role: "user", Content: prompt},
+		},
+		Stream:      false,
+		Temperature: 0,
+		MaxTokens:   c.config.MaxTokens,
+	})
+	if err != nil {
+		return "", fmt.Errorf("completion failed: %w", err)
+	}
+	return resp.Message.Content, nil
+}
+
+// RenderPrompt returns the chat request a query would send to Ollama, without sending it
+func (c *Client) RenderPrompt(ctx context.Context, query, model string, tools interface{}, conversationHistory interface{}) (interface{}, error) {
+	ollamaTools, ok1 := tools.([]Tool)
+	ollamaHistory, ok2 := conversationHistory.([]ChatMessage)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("invalid parameter types for Ollama client")
+	}
+	return c.buildChatRequest(ctx, query, model, ollamaTools, ollamaHistory), nil
+}
+
 // GetAvailableModels returns the list of configured available models
 func (c *Client) GetAvailableModels() []string {
 	return c.config.Models
@@ -0,0 +1,341 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"aviagent/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestToolArgs(t *testing.T) {
+	args := ParseToolArguments(`{"uuid": "vs-1", "count": "5", "limit": 12.7, "port": 443, "dry_run": "true", "local": false, "fields": ["a"], "nothing": null}`)
+	require.NotNil(t, args)
+
+	uuid, ok := ArgString(args, "uuid")
+	assert.True(t, ok)
+	assert.Equal(t, "vs-1", uuid)
+	port, ok := ArgString(args, "port")
+	assert.True(t, ok)
+	assert.Equal(t, "443", port)
+	_, ok = ArgString(args, "fields")
+	assert.False(t, ok)
+
+	count, ok := ArgInt(args, "count")
+	assert.True(t, ok)
+	assert.Equal(t, 5, count)
+	limit, _ := ArgInt(args, "limit")
+	assert.Equal(t, 12, limit)
+	_, ok = ArgInt(args, "uuid")
+	assert.False(t, ok)
+
+	dryRun, ok := ArgBool(args, "dry_run")
+	assert.True(t, ok && dryRun)
+	local, ok := ArgBool(args, "local")
+	assert.True(t, ok)
+	assert.False(t, local)
+
+	assert.Equal(t, map[string]string{"uuid": "vs-1", "count": "5", "limit": "12.7", "port": "443", "dry_run": "true", "local": "false"}, StringParams(args))
+	assert.False(t, IsMutatingTool("apply_configuration", args))
+	assert.True(t, IsMutatingTool("create_alert_rule", args))
+	assert.False(t, IsMutatingTool("create_alert_rule", map[string]interface{}{"preview": "true"}))
+	assert.False(t, IsMutatingTool("service_engine_maintenance", map[string]interface{}{"step": "plan"}))
+	assert.True(t, IsMutatingTool("service_engine_maintenance", map[string]interface{}{"step": "wait"}))
+	assert.Nil(t, ParseToolArguments(`["not", "an", "object"]`))
+}
+
+func TestToolErrorFrom(t *testing.T) {
+	plain := ToolErrorFrom("get_pool", errors.New("uuid parameter required"))
+	assert.Equal(t, ToolError{Tool: "get_pool", Message: "uuid parameter required"}, plain)
+	assert.Equal(t, "tool get_pool failed: uuid parameter required", plain.Error())
+
+	panicked := fmt.Errorf("rerun: %w", &ToolError{Tool: "get_pool", Message: "nil map", Panic: true})
+	assert.True(t, ToolErrorFrom("other", panicked).Panic)
+	assert.Equal(t, "get_pool", ToolErrorFrom("other", panicked).Tool)
+}
+
+func TestValidateToolArgs(t *testing.T) {
+	err := ValidateToolArgs("get_virtual_service", map[string]interface{}{"fields": "name"})
+	require.Error(t, err)
+	toolErr := ToolErrorFrom("other", err)
+	assert.Equal(t, "get_virtual_service", toolErr.Tool)
+	assert.Equal(t, []ArgumentError{{Argument: "uuid", Problem: "is required"}}, toolErr.Invalid)
+	assert.Contains(t, toolErr.Message, "uuid is required")
+
+	err = ValidateToolArgs("service_engine_maintenance", map[string]interface{}{"service_engine": "se-1", "step": "reboot"})
+	require.Error(t, err)
+	assert.Equal(t, []ArgumentError{{Argument: "step", Problem: "must be one of plan, disable, wait, verify, enable"}}, ToolErrorFrom("", err).Invalid)
+	assert.NoError(t, ValidateToolArgs("service_engine_maintenance", map[string]interface{}{"service_engine": "se-1", "step": "Plan"}))
+
+	// Types are read as leniently as the Arg helpers read them
+	assert.NoError(t, ValidateToolArgs("create_pool", ParseToolArguments(`{"name": "web-pool", "default_server_port": "8080", "servers": [{"ip": {"addr": "10.0.0.1"}, "port": 80, "enabled": "true"}]}`)))
+
+	err = ValidateToolArgs("create_pool", ParseToolArguments(`{"name": "", "servers": [{"port": "http"}, {"ip": "10.0.0.2"}], "default_server_port": true}`))
+	require.Error(t, err)
+	assert.Equal(t, []ArgumentError{
+		{Argument: "name", Problem: "is required"},
+		{Argument: "default_server_port", Problem: "must be an integer"},
+		{Argument: "servers[0].ip", Problem: "is required"},
+		{Argument: "servers[0].port", Problem: "must be an integer"},
+		{Argument: "servers[1].ip", Problem: "must be an object"},
+	}, ToolErrorFrom("", err).Invalid)
+
+	err = ValidateToolArgs("create_pool", map[string]interface{}{"name": "web-pool", "servers": "10.0.0.1"})
+	require.Error(t, err)
+	assert.Equal(t, []ArgumentError{{Argument: "servers", Problem: "must be a list"}}, ToolErrorFrom("", err).Invalid)
+
+	assert.NoError(t, ValidateToolArgs("no_such_tool", nil))
+}
+
+func TestNewTools(t *testing.T) {
+	all := len(GetAviToolDefinitions())
+	tools, err := NewTools(config.ToolsConfig{})
+	require.NoError(t, err)
+	assert.Len(t, tools.Definitions(), all)
+
+	tools, err = NewTools(config.ToolsConfig{Disabled: []string{"execute_generic_operation", "delete_virtual_service"}})
+	require.NoError(t, err)
+	assert.Len(t, tools.Definitions(), all-2)
+	assert.Len(t, tools.All(), all)
+	assert.NotContains(t, tools.Names(), "execute_generic_operation")
+	assert.False(t, tools.Enabled("delete_virtual_service"))
+	assert.True(t, tools.Enabled("list_virtual_services"))
+	_, err = tools.ByName("delete_virtual_service")
+	assert.Error(t, err)
+
+	// Each set is its own: the built-in tools are still all offered elsewhere
+	assert.Len(t, GetAviToolDefinitions(), all)
+	assert.Contains(t, GetToolNames(), "execute_generic_operation")
+
+	// Disabled wins over enabled
+	tools, err = NewTools(config.ToolsConfig{Enabled: []string{"list_pools", "delete_pool"}, Disabled: []string{"delete_pool"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"list_pools"}, tools.Names())
+
+	_, err = NewTools(config.ToolsConfig{Disabled: []string{"delete_everything", "list_pools"}})
+	assert.EqualError(t, err, "unknown tools \"delete_everything\" (see `aviagent tools list`)")
+}
+
+func TestCustomTools(t *testing.T) {
+	builtin := len(GetAviToolDefinitions())
+	runtime := config.CustomToolConfig{
+		Name:        "get_vs_runtime_detail",
+		Description: "Runtime detail of a virtual service",
+		Endpoint:    "/virtualservice/{uuid}/runtime/detail",
+		Parameters: map[string]interface{}{
+			"properties": map[string]interface{}{
+				"uuid":   map[string]interface{}{"type": "string"},
+				"detail": map[string]interface{}{"type": "string", "enum": []interface{}{"summary", "full"}},
+			},
+			"required": []interface{}{"uuid"},
+		},
+	}
+	scale := config.CustomToolConfig{
+		Name:        "scale_out_vs",
+		Description: "Scale out a virtual service",
+		Method:      "POST",
+		Endpoint:    "/virtualservice/{uuid}/scaleout",
+		Parameters: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"uuid": map[string]interface{}{"type": "string"}},
+			"required":   []interface{}{"uuid"},
+		},
+	}
+	tools, err := NewTools(config.ToolsConfig{Custom: []config.CustomToolConfig{runtime, scale}, Disabled: []string{"scale_out_vs"}})
+	require.NoError(t, err)
+	assert.Len(t, tools.All(), builtin+2)
+	assert.Contains(t, tools.Names(), "get_vs_runtime_detail")
+	assert.NotContains(t, tools.Names(), "scale_out_vs")
+	assert.NotContains(t, GetToolNames(), "get_vs_runtime_detail")
+	assert.False(t, tools.IsMutating("get_vs_runtime_detail", nil))
+	assert.True(t, tools.IsMutating("scale_out_vs", nil))
+
+	// The schema read from YAML is checked as the built-in ones are
+	tool, err := tools.ByName("get_vs_runtime_detail")
+	require.NoError(t, err)
+	assert.Equal(t, "object", tool.Function.Parameters.(map[string]interface{})["type"])
+	assert.NoError(t, tools.ValidateArgs("get_vs_runtime_detail", map[string]interface{}{"uuid": "vs-1"}))
+	err = tools.ValidateArgs("get_vs_runtime_detail", map[string]interface{}{"detail": "all"})
+	assert.ErrorContains(t, err, "uuid is required")
+	assert.ErrorContains(t, err, "detail must be one of summary, full")
+
+	method, endpoint, body, params, err := CustomToolRequest(runtime, map[string]interface{}{"uuid": "vs 1", "detail": "full"})
+	require.NoError(t, err)
+	assert.Equal(t, "GET", method)
+	assert.Equal(t, "/virtualservice/vs%201/runtime/detail", endpoint)
+	assert.Nil(t, body)
+	assert.Equal(t, map[string]string{"detail": "full"}, params)
+	method, endpoint, body, params, err = CustomToolRequest(scale, map[string]interface{}{"uuid": "vs-1", "vip_id": "0"})
+	require.NoError(t, err)
+	assert.Equal(t, "POST /virtualservice/vs-1/scaleout", method+" "+endpoint)
+	assert.Equal(t, map[string]interface{}{"vip_id": "0"}, body)
+	assert.Nil(t, params)
+	_, _, _, _, err = CustomToolRequest(scale, nil)
+	assert.EqualError(t, err, "uuid parameter required")
+
+	// An invalid custom tool is refused
+	for _, tc := range []struct {
+		tool config.CustomToolConfig
+		err  string
+	}{
+		{config.CustomToolConfig{Name: "Get-Thing", Description: "x", Endpoint: "/thing"}, `custom tool 1: name "Get-Thing" must be lowercase letters, digits and underscores, starting with a letter`},
+		{config.CustomToolConfig{Name: "list_pools", Description: "x", Endpoint: "/pool"}, "custom tool list_pools: a built-in tool has that name"},
+		{config.CustomToolConfig{Name: "thing", Endpoint: "/thing"}, "custom tool thing: description required"},
+		{config.CustomToolConfig{Name: "thing", Description: "x", Method: "HEAD", Endpoint: "/thing"}, `custom tool thing: method must be GET, POST, PUT, PATCH or DELETE, not "HEAD"`},
+		{config.CustomToolConfig{Name: "thing", Description: "x", Endpoint: "https://evil/api/thing"}, `custom tool thing: endpoint must be an API path such as /virtualservice/{uuid}, not "https://evil/api/thing"`},
+		{config.CustomToolConfig{Name: "thing", Description: "x", Endpoint: "/thing/{uuid}"}, "custom tool thing: endpoint argument {uuid} is not among the parameters"},
+		{config.CustomToolConfig{Name: "thing", Description: "x", Endpoint: "/thing/{uuid}",
+			Parameters: map[string]interface{}{"properties": map[string]interface{}{"uuid": map[string]interface{}{"type": "string"}}}},
+			"custom tool thing: endpoint argument {uuid} must be required"},
+	} {
+		_, err := NewTools(config.ToolsConfig{Custom: []config.CustomToolConfig{tc.tool}})
+		assert.EqualError(t, err, tc.err)
+	}
+	_, err = NewTools(config.ToolsConfig{Custom: []config.CustomToolConfig{runtime, runtime}})
+	assert.EqualError(t, err, "custom tool get_vs_runtime_detail: defined twice")
+}
+
+// FuzzExtractToolCalls feeds model output to the tool-call extraction and JSON answer unwrapping
+func FuzzExtractToolCalls(f *testing.F) {
+	for _, seed := range []string{
+		`{"tool": "list_virtual_services", "parameters": {}}`,
+		`{"tool": "get_virtual_service", "parameters": {"uuid": "vs-1", "fields": "name"}}`,
+		`{"tool": 42, "parameters": "uuid=vs-1"}`,
+		`{"tool": "get_analytics", "parameters": {"metrics": ["l4_client.avg_bandwidth"], "step": "300"}}`,
+		`{"message": "There are 3 virtual services."}`,
+		`{"tool": null}`,
+		`[{"tool": "list_pools"}]`,
+		"```json\n{\"tool\": \"list_pools\"}\n```",
+		`not json at all`,
+		``,
+	} {
+		f.Add(seed)
+	}
+
+	client := &Client{logger: zap.NewNop()}
+	f.Fuzz(func(t *testing.T, content string) {
+		calls, err := client.extractToolCalls(content)
+		require.NoError(t, err)
+		for _, call := range calls {
+			assert.NotEmpty(t, call.ID)
+			assert.Equal(t, content, call.Function.Arguments)
+		}
+		UnwrapJSONMessage(content)
+	})
+}
+
+// FuzzToolArgs reads arbitrary tool arguments the way executeToolCall does
+func FuzzToolArgs(f *testing.F) {
+	for _, seed := range []string{
+		`{"uuid": "vs-1", "step": 300, "limit": "12", "dry_run": true}`,
+		`{"uuid": 7, "count": "ten", "dry_run": "yes", "method": "get"}`,
+		`{"count": 1e308, "limit": -1e308, "step": "NaN", "local": "TRUE"}`,
+		`{"parameters": {"name": "shop", "page_size": 25}, "body": [1, 2]}`,
+		`{"uuid": {"nested": true}, "fields": null}`,
+		`{}`,
+	} {
+		f.Add(seed)
+	}
+
+	keys := []string{"uuid", "name", "count", "limit", "step", "dry_run", "local", "method", "fields", "parameters"}
+	f.Fuzz(func(t *testing.T, arguments string) {
+		args := ParseToolArguments(arguments)
+		for _, key := range keys {
+			ArgString(args, key)
+			ArgInt(args, key)
+			ArgBool(args, key)
+		}
+		params := StringParams(args)
+		for key, value := range params {
+			coerced, ok := ArgString(args, key)
+			assert.True(t, ok)
+			assert.Equal(t, coerced, value)
+		}
+		for _, name := range GetToolNames() {
+			IsMutatingTool(name, args)
+		}
+		if _, err := json.Marshal(params); err != nil {
+			t.Fatalf("params can't be encoded: %v", err)
+		}
+	})
+}
+
+func TestModelRouterRoute(t *testing.T) {
+	router := NewModelRouter(config.RoutingConfig{
+		Enabled:          true,
+		SimpleModel:      "small",
+		ComplexModel:     "large",
+		WriteKeywords:    []string{"add", "disable"},
+		PlanningKeywords: []string{"then", "step by step"},
+		MaxSimpleWords:   8,
+	})
+
+	tests := []struct {
+		name       string
+		query      string
+		model      string
+		complexity string
+		reason     string
+	}{
+		{"read query", "list the virtual services", "small", ComplexitySimple, "read-only query"},
+		{"write keyword", "Disable web-vs now", "large", ComplexityComplex, `write operation ("disable")`},
+		{"keyword next to punctuation", "please add: 10.0.0.5", "large", ComplexityComplex, `write operation ("add")`},
+		{"keyword inside a word", "what is the address of web-vs?", "small", ComplexitySimple, "read-only query"},
+		{"planning keyword", "check the pool then the health monitor", "large", ComplexityComplex, `multi-step request ("then")`},
+		{"multi-word planning keyword", "explain it step by step", "large", ComplexityComplex, `multi-step request ("step by step")`},
+		{"write before planning", "add a server then check it", "large", ComplexityComplex, `write operation ("add")`},
+		{"word limit", "show me the health of every pool in the shop tenant", "large", ComplexityComplex, "long request (11 words)"},
+		{"at the word limit", "show the health of every pool in shop", "small", ComplexitySimple, "read-only query"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := router.Route(tt.query)
+			assert.Equal(t, ModelRoute{Model: tt.model, Complexity: tt.complexity, Reason: tt.reason}, route)
+		})
+	}
+
+	assert.False(t, (*ModelRouter)(nil).Enabled())
+	assert.Equal(t, ComplexitySimple, NewModelRouter(config.RoutingConfig{}).Route(strings.Repeat("word ", 100)).Complexity, "no word limit when unset")
+}
+
+func TestJSONModeFallback(t *testing.T) {
+	var formats []string
+	var reject string // the error of a request asking for JSON mode
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		formats = append(formats, req.Format)
+		if req.Format != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(reject))
+			return
+		}
+		json.NewEncoder(w).Encode(ChatResponse{Model: req.Model, Message: ChatMessage{Role: "assistant", Content: "ok"}, Done: true})
+	}))
+	defer server.Close()
+	client, err := NewClient(&config.LLMConfig{OllamaHost: server.URL, Timeout: 5, JSONMode: true}, zap.NewNop())
+	require.NoError(t, err)
+
+	// A 400 about something else is reported, JSON mode is still requested
+	reject = `{"error": "model \"llama3\" not found, try pulling it first"}`
+	_, err = client.ChatCompletion(context.Background(), ChatRequest{Model: "llama3", Format: "json"})
+	assert.ErrorContains(t, err, "status 400")
+	assert.Equal(t, []string{"json"}, formats)
+	assert.True(t, client.jsonMode.Enabled(true, "llama3"))
+
+	reject = `{"error": "invalid format: json is not supported by this model"}`
+	resp, err := client.ChatCompletion(context.Background(), ChatRequest{Model: "llama3", Format: "json"})
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp.Message.Content)
+	assert.Equal(t, []string{"json", "json", ""}, formats, "the request is retried in free-form mode")
+	assert.False(t, client.jsonMode.Enabled(true, "llama3"))
+}
@@ -0,0 +1,86 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"aviagent/pkg/config"
+)
+
+// AutoModel is the model name that asks the router to pick a model
+const AutoModel = "auto"
+
+// Task complexity levels used for model routing
+const (
+	ComplexitySimple  = "simple"
+	ComplexityComplex = "complex"
+)
+
+// ModelRoute is the routing decision for a query
+type ModelRoute struct {
+	Model      string `json:"model"`
+	Complexity string `json:"complexity"`
+	Reason     string `json:"reason"`
+}
+
+// ModelRouter picks a cheap model for simple read queries and a stronger model for
+// write operations and multi-step requests
+type ModelRouter struct {
+	policy config.RoutingConfig
+}
+
+// NewModelRouter creates a new model router from the routing policy
+func NewModelRouter(policy config.RoutingConfig) *ModelRouter {
+	return &ModelRouter{policy: policy}
+}
+
+// Enabled reports whether automatic model selection is configured
+func (r *ModelRouter) Enabled() bool {
+	return r != nil && r.policy.Enabled
+}
+
+// Route classifies the query and returns the model to use
+func (r *ModelRouter) Route(query string) ModelRoute {
+	words := strings.FieldsFunc(strings.ToLower(query), func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsNumber(c) && c != '_' && c != '-'
+	})
+	// Pad with spaces so keywords only match whole words ("add" must not match "address")
+	normalized := " " + strings.Join(words, " ") + " "
+
+	if kw := matchKeyword(normalized, r.policy.WriteKeywords); kw != "" {
+		return r.complex(fmt.Sprintf("write operation (%q)", kw))
+	}
+	if kw := matchKeyword(normalized, r.policy.PlanningKeywords); kw != "" {
+		return r.complex(fmt.Sprintf("multi-step request (%q)", kw))
+	}
+	if r.policy.MaxSimpleWords > 0 && len(words) > r.policy.MaxSimpleWords {
+		return r.complex(fmt.Sprintf("long request (%d words)", len(words)))
+	}
+
+	return ModelRoute{
+		Model:      r.policy.SimpleModel,
+		Complexity: ComplexitySimple,
+		Reason:     "read-only query",
+	}
+}
+
+// complex returns a route to the stronger model
+func (r *ModelRouter) complex(reason string) ModelRoute {
+	return ModelRoute{
+		Model:      r.policy.ComplexModel,
+		Complexity: ComplexityComplex,
+		Reason:     reason,
+	}
+}
+
+// matchKeyword returns the first keyword found as whole words in the normalized query
+func matchKeyword(normalized string, keywords []string) string {
+	for _, kw := range keywords {
+		kw = strings.ToLower(strings.TrimSpace(kw))
+		if kw != "" && strings.Contains(normalized, " "+kw+" ") {
+			return kw
+		}
+	}
+	return ""
+}
@@ -0,0 +1,131 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Models don't reliably follow the parameter types of the tool schemas: numbers arrive as
+// strings ("10"), booleans as "true", names as numbers. These helpers read tool arguments
+// whatever their JSON type and never panic on unexpected values.
+
+// ParseToolArguments decodes the JSON arguments string of a tool call. Arguments that aren't a
+// JSON object yield nil.
+func ParseToolArguments(arguments string) map[string]interface{} {
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(arguments)), &args); err != nil {
+		return nil
+	}
+	return args
+}
+
+// ArgString returns a string argument. Numbers and booleans are formatted; other types and
+// missing arguments report false.
+func ArgString(args map[string]interface{}, key string) (string, bool) {
+	switch v := args[key].(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case int:
+		return strconv.Itoa(v), true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
+
+// ArgInt returns an integer argument given as a number or a numeric string. Fractions are
+// truncated; values that aren't finite or fall outside the 32-bit range, so they fit an int on
+// every platform, report false.
+func ArgInt(args map[string]interface{}, key string) (int, bool) {
+	var f float64
+	switch v := args[key].(type) {
+	case float64:
+		f = v
+	case int:
+		return v, true
+	case json.Number:
+		n, err := v.Float64()
+		if err != nil {
+			return 0, false
+		}
+		f = n
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, false
+		}
+		f = n
+	default:
+		return 0, false
+	}
+	if math.IsNaN(f) || f >= math.MaxInt32 || f <= math.MinInt32 {
+		return 0, false
+	}
+	return int(f), true
+}
+
+// ArgBool returns a boolean argument given as a bool or as "true"/"false"
+func ArgBool(args map[string]interface{}, key string) (bool, bool) {
+	switch v := args[key].(type) {
+	case bool:
+		return v, true
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		return b, err == nil
+	}
+	return false, false
+}
+
+// StringParams converts the scalar arguments of a tool call to query parameters. Objects and
+// lists are skipped.
+func StringParams(args map[string]interface{}) map[string]string {
+	params := make(map[string]string)
+	for key := range args {
+		if value, ok := ArgString(args, key); ok {
+			params[key] = value
+		}
+	}
+	return params
+}
+
+// ToolError is a failed tool call as reported back in the answer, so the model and the operator
+// see why a call produced no result. Panic marks a tool that crashed on its arguments; Code and
+// Field are set for errors the controller answered.
+type ToolError struct {
+	Tool    string          `json:"tool"`
+	Message string          `json:"message"`
+	Panic   bool            `json:"panic,omitempty"`
+	Invalid []ArgumentError `json:"invalid,omitempty"` // arguments that don't match the tool's schema
+	Code    string          `json:"code,omitempty"`    // kind of controller error, e.g. duplicate_name
+	Field   string          `json:"field,omitempty"`   // field the controller error is about
+	Advice  string          `json:"advice,omitempty"`  // the model's explanation of the error and how to fix it
+	Cause   error           `json:"-"`
+}
+
+func (e *ToolError) Error() string {
+	if e.Panic {
+		return fmt.Sprintf("tool %s failed with an internal error: %s", e.Tool, e.Message)
+	}
+	return fmt.Sprintf("tool %s failed: %s", e.Tool, e.Message)
+}
+
+func (e *ToolError) Unwrap() error {
+	return e.Cause
+}
+
+// ToolErrorFrom converts the error of a tool call to a ToolError, keeping one returned as is
+func ToolErrorFrom(tool string, err error) ToolError {
+	var toolErr *ToolError
+	if errors.As(err, &toolErr) {
+		return *toolErr
+	}
+	return ToolError{Tool: tool, Message: err.Error()}
+}
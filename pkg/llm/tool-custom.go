@@ -0,0 +1,207 @@
+package llm
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"aviagent/pkg/config"
+)
+
+// customToolName is the form of a custom tool name, one every provider accepts for a function
+var customToolName = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// endpointArgument matches an argument named in braces in the endpoint of a custom tool
+var endpointArgument = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// customMethods are the HTTP methods a custom tool may use
+var customMethods = map[string]bool{"GET": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true}
+
+// All returns the tool definitions for Avi Load Balancer API functions, the custom tools of the
+// tools configuration and the tools it disables included
+func (t *Tools) All() []Tool {
+	tools := builtinToolDefinitions()
+	if t == nil {
+		return tools
+	}
+	for _, tool := range t.custom {
+		tools = append(tools, Tool{
+			Type: "function",
+			Function: Function{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  customParameters(tool),
+			},
+		})
+	}
+	return tools
+}
+
+// Custom returns the custom tool of the tools configuration with a name
+func (t *Tools) Custom(name string) (config.CustomToolConfig, bool) {
+	if t == nil {
+		return config.CustomToolConfig{}, false
+	}
+	for _, tool := range t.custom {
+		if tool.Name == name {
+			return tool, true
+		}
+	}
+	return config.CustomToolConfig{}, false
+}
+
+// CustomToolRequest returns the API call of a custom tool called with arguments: its method, its
+// endpoint with the arguments it names filled in, and the other arguments as the query
+// parameters of a GET or DELETE and as the body of the other methods
+func CustomToolRequest(tool config.CustomToolConfig, args map[string]interface{}) (method, endpoint string, body map[string]interface{}, params map[string]string, err error) {
+	rest := make(map[string]interface{}, len(args))
+	for key, value := range args {
+		rest[key] = value
+	}
+	endpoint = endpointArgument.ReplaceAllStringFunc(tool.Endpoint, func(match string) string {
+		name := match[1 : len(match)-1]
+		value, ok := ArgString(args, name)
+		if !ok || value == "" {
+			if err == nil {
+				err = fmt.Errorf("%s parameter required", name)
+			}
+			return match
+		}
+		delete(rest, name)
+		return url.PathEscape(value)
+	})
+	if err != nil {
+		return "", "", nil, nil, err
+	}
+
+	method = customMethod(tool)
+	if method == "GET" || method == "DELETE" {
+		return method, endpoint, nil, StringParams(rest), nil
+	}
+	if len(rest) > 0 {
+		body = rest
+	}
+	return method, endpoint, body, nil, nil
+}
+
+// customMethod returns the HTTP method of a custom tool, GET when the configuration leaves it out
+func customMethod(tool config.CustomToolConfig) string {
+	if method := strings.ToUpper(strings.TrimSpace(tool.Method)); method != "" {
+		return method
+	}
+	return "GET"
+}
+
+// customParameters returns the parameter schema of a custom tool as the schema checks and the
+// providers read it: an object, with the lists of required properties and enum values as strings
+func customParameters(tool config.CustomToolConfig) map[string]interface{} {
+	schema, _ := normalizeSchema(tool.Parameters).(map[string]interface{})
+	if schema == nil {
+		schema = make(map[string]interface{})
+	}
+	if _, ok := schema["type"]; !ok {
+		schema["type"] = "object"
+	}
+	if _, ok := schema["properties"]; !ok {
+		schema["properties"] = map[string]interface{}{}
+	}
+	return schema
+}
+
+// normalizeSchema copies a schema read from the configuration, where lists decode as
+// []interface{}, with the required and enum lists as []string
+func normalizeSchema(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		schema := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if list, ok := item.([]interface{}); ok && (key == "required" || key == "enum") {
+				if strs, ok := stringList(list); ok {
+					schema[key] = strs
+					continue
+				}
+			}
+			schema[key] = normalizeSchema(item)
+		}
+		return schema
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = normalizeSchema(item)
+		}
+		return list
+	}
+	return value
+}
+
+// stringList returns a list of strings as []string
+func stringList(list []interface{}) ([]string, bool) {
+	strs := make([]string, len(list))
+	for i, item := range list {
+		s, ok := item.(string)
+		if !ok {
+			return nil, false
+		}
+		strs[i] = s
+	}
+	return strs, true
+}
+
+// validateCustomTools checks the custom tools of the tools configuration: a valid name no other
+// tool has, a description, a method, an API endpoint, and a parameter schema that defines and
+// requires every argument the endpoint names
+func validateCustomTools(custom []config.CustomToolConfig, builtin map[string]bool) error {
+	seen := make(map[string]bool, len(custom))
+	for i, tool := range custom {
+		if !customToolName.MatchString(tool.Name) {
+			return fmt.Errorf("custom tool %d: name %q must be lowercase letters, digits and underscores, starting with a letter", i+1, tool.Name)
+		}
+		if builtin[tool.Name] {
+			return fmt.Errorf("custom tool %s: a built-in tool has that name", tool.Name)
+		}
+		if seen[tool.Name] {
+			return fmt.Errorf("custom tool %s: defined twice", tool.Name)
+		}
+		seen[tool.Name] = true
+		if strings.TrimSpace(tool.Description) == "" {
+			return fmt.Errorf("custom tool %s: description required", tool.Name)
+		}
+		if !customMethods[customMethod(tool)] {
+			return fmt.Errorf("custom tool %s: method must be GET, POST, PUT, PATCH or DELETE, not %q", tool.Name, tool.Method)
+		}
+		if !strings.HasPrefix(tool.Endpoint, "/") || strings.Contains(tool.Endpoint, "://") {
+			return fmt.Errorf("custom tool %s: endpoint must be an API path such as /virtualservice/{uuid}, not %q", tool.Name, tool.Endpoint)
+		}
+
+		schema := customParameters(tool)
+		if schemaType, _ := schema["type"].(string); schemaType != "object" {
+			return fmt.Errorf("custom tool %s: parameters must be an object schema", tool.Name)
+		}
+		properties, ok := schema["properties"].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("custom tool %s: parameters.properties must be a map of the arguments", tool.Name)
+		}
+		required, _ := schema["required"].([]string)
+		for _, match := range endpointArgument.FindAllStringSubmatch(tool.Endpoint, -1) {
+			name := match[1]
+			if _, ok := properties[name]; !ok {
+				return fmt.Errorf("custom tool %s: endpoint argument {%s} is not among the parameters", tool.Name, name)
+			}
+			if !containsString(required, name) {
+				return fmt.Errorf("custom tool %s: endpoint argument {%s} must be required", tool.Name, name)
+			}
+		}
+	}
+	return nil
+}
+
+// containsString reports whether a list holds a string
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package llm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"aviagent/pkg/config"
+)
+
+// Tools is the set of tools a deployment offers, built from its tools configuration: the
+// built-in tools it enables and its custom tools. Each server or agent keeps its own, so two in
+// one process may offer different tools. A nil *Tools offers every built-in tool.
+type Tools struct {
+	enabled  map[string]bool // nil offers every tool
+	disabled map[string]bool
+	custom   []config.CustomToolConfig
+}
+
+// NewTools returns the tools a tools configuration offers, its custom tools added to the
+// built-in ones. It fails when the configuration names an unknown tool or defines an invalid one.
+func NewTools(cfg config.ToolsConfig) (*Tools, error) {
+	if err := ValidateToolConfig(cfg); err != nil {
+		return nil, err
+	}
+	tools := &Tools{
+		disabled: make(map[string]bool, len(cfg.Disabled)),
+		custom:   append([]config.CustomToolConfig(nil), cfg.Custom...),
+	}
+	if len(cfg.Enabled) > 0 {
+		tools.enabled = make(map[string]bool, len(cfg.Enabled))
+		for _, name := range cfg.Enabled {
+			tools.enabled[name] = true
+		}
+	}
+	for _, name := range cfg.Disabled {
+		tools.disabled[name] = true
+	}
+	return tools, nil
+}
+
+// Definitions returns the tool definitions offered to the model: the tools disabled by the
+// tools configuration are left out
+func (t *Tools) Definitions() []Tool {
+	all := t.All()
+	tools := make([]Tool, 0, len(all))
+	for _, tool := range all {
+		if t.Enabled(tool.Function.Name) {
+			tools = append(tools, tool)
+		}
+	}
+	return tools
+}
+
+// Enabled reports whether the tools configuration offers a tool
+func (t *Tools) Enabled(name string) bool {
+	if t == nil {
+		return true
+	}
+	return (t.enabled == nil || t.enabled[name]) && !t.disabled[name]
+}
+
+// Names returns the names of the tools offered to the model
+func (t *Tools) Names() []string {
+	tools := t.Definitions()
+	names := make([]string, len(tools))
+	for i, tool := range tools {
+		names[i] = tool.Function.Name
+	}
+	return names
+}
+
+// Find returns the definition of a tool offered to the model
+func (t *Tools) Find(name string) (Tool, bool) {
+	for _, tool := range t.Definitions() {
+		if tool.Function.Name == name {
+			return tool, true
+		}
+	}
+	return Tool{}, false
+}
+
+// ValidateToolConfig checks that the tools configuration only names defined tools, built-in or
+// custom, and that its custom tools are valid
+func ValidateToolConfig(cfg config.ToolsConfig) error {
+	defined := make(map[string]bool)
+	for _, tool := range builtinToolDefinitions() {
+		defined[tool.Function.Name] = true
+	}
+	if err := validateCustomTools(cfg.Custom, defined); err != nil {
+		return err
+	}
+	for _, tool := range cfg.Custom {
+		defined[tool.Name] = true
+	}
+	var unknown []string
+	for _, name := range append(append([]string{}, cfg.Enabled...), cfg.Disabled...) {
+		if !defined[name] {
+			unknown = append(unknown, fmt.Sprintf("%q", name))
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown tools %s (see `aviagent tools list`)", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// GetAviToolDefinitions returns the definitions of the built-in tools for Avi Load Balancer API
+// functions; the tools a deployment offers are those of its Tools
+func GetAviToolDefinitions() []Tool {
+	return (*Tools)(nil).Definitions()
+}
@@ -0,0 +1,166 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ArgumentError is an argument of a tool call that doesn't match the parameters schema of the
+// tool
+type ArgumentError struct {
+	Argument string `json:"argument"` // path of the argument, e.g. servers[0].ip
+	Problem  string `json:"problem"`
+}
+
+// FindTool returns the definition of a built-in tool
+func FindTool(name string) (Tool, bool) {
+	return (*Tools)(nil).Find(name)
+}
+
+// ValidateToolArgs checks the arguments of a call to a built-in tool, as Tools.ValidateArgs
+func ValidateToolArgs(name string, args map[string]interface{}) error {
+	return (*Tools)(nil).ValidateArgs(name, args)
+}
+
+// ValidateArgs checks the arguments of a tool call against the parameters schema of the tool:
+// required arguments, types and enums, at any depth. It returns a *ToolError listing every
+// mismatch, worded for the model to correct its call, or nil when the arguments match or the
+// tool isn't offered. Types are checked as leniently as the Arg helpers read them, so a number
+// given as a numeric string passes; arguments the schema doesn't describe are ignored.
+func (t *Tools) ValidateArgs(name string, args map[string]interface{}) error {
+	tool, ok := t.Find(name)
+	if !ok {
+		return nil
+	}
+	schema, _ := tool.Function.Parameters.(map[string]interface{})
+	var invalid []ArgumentError
+	validateObject(schema, args, "", &invalid)
+	if len(invalid) == 0 {
+		return nil
+	}
+	problems := make([]string, len(invalid))
+	for i, arg := range invalid {
+		problems[i] = arg.Argument + " " + arg.Problem
+	}
+	return &ToolError{
+		Tool:    name,
+		Message: fmt.Sprintf("invalid arguments: %s. Correct them and call the tool again", strings.Join(problems, "; ")),
+		Invalid: invalid,
+	}
+}
+
+// validateObject checks the required arguments and the described properties of an object
+func validateObject(schema map[string]interface{}, object map[string]interface{}, path string, invalid *[]ArgumentError) {
+	required, _ := schema["required"].([]string)
+	for _, name := range required {
+		if value, ok := object[name]; !ok || value == nil || value == "" {
+			*invalid = append(*invalid, ArgumentError{Argument: argPath(path, name), Problem: "is required"})
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		property, ok := properties[name].(map[string]interface{})
+		if ok && object[name] != nil {
+			validateValue(property, object[name], argPath(path, name), invalid)
+		}
+	}
+}
+
+// validateValue checks the type and the enum of an argument
+func validateValue(schema map[string]interface{}, value interface{}, path string, invalid *[]ArgumentError) {
+	// The scalar types are read by the Arg helpers
+	arg := map[string]interface{}{"": value}
+	problem := ""
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			problem = "must be an object"
+			break
+		}
+		validateObject(schema, object, path, invalid)
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			problem = "must be a list"
+			break
+		}
+		if itemSchema, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range items {
+				if item != nil {
+					validateValue(itemSchema, item, fmt.Sprintf("%s[%d]", path, i), invalid)
+				}
+			}
+		}
+	case "string":
+		if _, ok := ArgString(arg, ""); !ok {
+			problem = "must be a string"
+		}
+	case "integer":
+		if _, ok := ArgInt(arg, ""); !ok {
+			problem = "must be an integer"
+		}
+	case "number":
+		if !isNumber(value) {
+			problem = "must be a number"
+		}
+	case "boolean":
+		if _, ok := ArgBool(arg, ""); !ok {
+			problem = "must be true or false"
+		}
+	}
+	if problem == "" {
+		problem = enumProblem(schema, arg)
+	}
+	if problem != "" {
+		*invalid = append(*invalid, ArgumentError{Argument: path, Problem: problem})
+	}
+}
+
+// enumProblem describes an argument outside the enum of its schema, compared without case
+func enumProblem(schema map[string]interface{}, arg map[string]interface{}) string {
+	enum, ok := schema["enum"].([]string)
+	if !ok {
+		return ""
+	}
+	if text, ok := ArgString(arg, ""); ok {
+		for _, allowed := range enum {
+			if strings.EqualFold(text, allowed) {
+				return ""
+			}
+		}
+	}
+	return "must be one of " + strings.Join(enum, ", ")
+}
+
+// isNumber reports whether a value is a number or a numeric string
+func isNumber(value interface{}) bool {
+	switch v := value.(type) {
+	case float64, int:
+		return true
+	case json.Number:
+		_, err := v.Float64()
+		return err == nil
+	case string:
+		_, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return err == nil
+	}
+	return false
+}
+
+// argPath returns the path of a property of the object at path
+func argPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,2064 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// builtinToolDefinitions returns the tool definitions for Avi Load Balancer API functions the
+// agent ships with, the tools disabled by the tools configuration included
+func builtinToolDefinitions() []Tool {
+	tools := []Tool{
+		// Virtual Service Operations
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "list_virtual_services",
+				Description: "List all virtual services with optional filtering. Use this when users ask to see, list, or get information about virtual services.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"name": map[string]interface{}{
+							"type":        "string",
+							"description": "Filter by virtual service name",
+						},
+						"tenant": map[string]interface{}{
+							"type":        "string",
+							"description": "Filter by tenant name",
+						},
+						"enabled": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Filter by enabled status (true/false)",
+						},
+						"fields": map[string]interface{}{
+							"type":        "string",
+							"description": "Comma-separated list of fields to return (name,uuid,enabled,services,pool_ref)",
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "get_virtual_service",
+				Description: "Get details of a specific virtual service by UUID or name. Use this when users ask for detailed information about a specific virtual service.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the virtual service (required)",
+						},
+						"fields": map[string]interface{}{
+							"type":        "string",
+							"description": "Comma-separated list of fields to return",
+						},
+					},
+					"required": []string{"uuid"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "create_virtual_service",
+				Description: "Create a new virtual service. Use this when users want to create or set up a new virtual service.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"name": map[string]interface{}{
+							"type":        "string",
+							"description": "Name of the virtual service (required)",
+						},
+						"services": map[string]interface{}{
+							"type":        "array",
+							"description": "List of services with port and SSL configuration",
+							"items": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"port": map[string]interface{}{
+										"type":        "integer",
+										"description": "Service port number (80, 443, etc.)",
+									},
+									"enable_ssl": map[string]interface{}{
+										"type":        "boolean",
+										"description": "Enable SSL for this service",
+									},
+								},
+							},
+						},
+						"pool_ref": map[string]interface{}{
+							"type":        "string",
+							"description": "Reference to the backend pool",
+						},
+						"vsvip_ref": map[string]interface{}{
+							"type":        "string",
+							"description": "Reference to the virtual service VIP",
+						},
+					},
+					"required": []string{"name"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "update_virtual_service",
+				Description: "Update an existing virtual service. Use this when users want to modify or change virtual service configuration.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the virtual service to update (required)",
+						},
+						"name": map[string]interface{}{
+							"type":        "string",
+							"description": "New name for the virtual service",
+						},
+						"enabled": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Enable or disable the virtual service",
+						},
+						"services": map[string]interface{}{
+							"type":        "array",
+							"description": "Updated list of services",
+							"items": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"port": map[string]interface{}{
+										"type": "integer",
+									},
+									"enable_ssl": map[string]interface{}{
+										"type": "boolean",
+									},
+								},
+							},
+						},
+					},
+					"required": []string{"uuid"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "delete_virtual_service",
+				Description: "Delete a virtual service. Use this when users want to remove or delete a virtual service.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the virtual service to delete (required)",
+						},
+					},
+					"required": []string{"uuid"},
+				},
+			},
+		},
+
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "enable_virtual_service",
+				Description: "Enable a virtual service so it starts serving traffic again. Use this when users want to enable, turn on or bring back a virtual service; prefer it over update_virtual_service.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the virtual service to enable (required)",
+						},
+					},
+					"required": []string{"uuid"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "disable_virtual_service",
+				Description: "Disable a virtual service so it stops serving traffic, keeping its configuration. Use this when users want to disable, turn off or take down a virtual service; prefer it over update_virtual_service.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the virtual service to disable (required)",
+						},
+					},
+					"required": []string{"uuid"},
+				},
+			},
+		},
+
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "scale_out_virtual_service",
+				Description: "Scale out a virtual service by placing it on an additional service engine. Use this when users want to add data plane capacity to a virtual service.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the virtual service (required)",
+						},
+						"vip_id": map[string]interface{}{
+							"type":        "string",
+							"description": "VIP ID within the virtual service (default \"0\")",
+						},
+						"to_se": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the service engine to place the virtual service on",
+						},
+						"to_new_se": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Create a new service engine for the placement instead of using an existing one",
+						},
+					},
+					"required": []string{"uuid"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "scale_in_virtual_service",
+				Description: "Scale in a virtual service by removing it from one of its service engines. Use this when users want to reduce the number of service engines a virtual service runs on.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the virtual service (required)",
+						},
+						"vip_id": map[string]interface{}{
+							"type":        "string",
+							"description": "VIP ID within the virtual service (default \"0\")",
+						},
+						"from_se": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the service engine to remove the virtual service from (required)",
+						},
+						"scalein_primary": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Allow scaling in from the primary service engine",
+						},
+					},
+					"required": []string{"uuid", "from_se"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "migrate_virtual_service",
+				Description: "Migrate a virtual service from one service engine to another (or to a new service engine). Use this when users want to move a virtual service off a busy or failing service engine.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the virtual service (required)",
+						},
+						"vip_id": map[string]interface{}{
+							"type":        "string",
+							"description": "VIP ID within the virtual service (default \"0\")",
+						},
+						"from_se": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the service engine to move the virtual service from (required)",
+						},
+						"to_se": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the destination service engine",
+						},
+						"to_new_se": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Migrate to a newly created service engine",
+						},
+					},
+					"required": []string{"uuid", "from_se"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "switchover_virtual_service",
+				Description: "Switch the primary service engine of a virtual service over to its standby. Use this when users ask to fail over or switch over a virtual service.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the virtual service (required)",
+						},
+						"vip_id": map[string]interface{}{
+							"type":        "string",
+							"description": "VIP ID within the virtual service (default \"0\")",
+						},
+					},
+					"required": []string{"uuid"},
+				},
+			},
+		},
+
+		// Pool Operations
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "list_pools",
+				Description: "List all pools with optional filtering. Use this when users ask about backend pools, server pools, or load balancing pools.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"name": map[string]interface{}{
+							"type":        "string",
+							"description": "Filter by pool name",
+						},
+						"enabled": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Filter by enabled status",
+						},
+						"health_status": map[string]interface{}{
+							"type":        "string",
+							"description": "Filter by health status (up, down, partial)",
+						},
+						"fields": map[string]interface{}{
+							"type":        "string",
+							"description": "Comma-separated list of fields to return",
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "get_pool",
+				Description: "Get details of a specific pool by UUID. Use this for detailed pool information including servers and health status.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the pool (required)",
+						},
+						"fields": map[string]interface{}{
+							"type":        "string",
+							"description": "Comma-separated list of fields to return",
+						},
+					},
+					"required": []string{"uuid"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "create_pool",
+				Description: "Create a new pool with backend servers. Use this when users want to create a new server pool or backend pool.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"name": map[string]interface{}{
+							"type":        "string",
+							"description": "Name of the pool (required)",
+						},
+						"servers": map[string]interface{}{
+							"type":        "array",
+							"description": "List of backend servers",
+							"items": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"ip": map[string]interface{}{
+										"type": "object",
+										"properties": map[string]interface{}{
+											"addr": map[string]interface{}{
+												"type":        "string",
+												"description": "IPv4 or IPv6 address of the server, without brackets",
+											},
+											"type": map[string]interface{}{
+												"type":        "string",
+												"description": "IP address type (V4, V6, DNS), derived from addr when omitted",
+											},
+										},
+										"required": []string{"addr"},
+									},
+									"port": map[string]interface{}{
+										"type":        "integer",
+										"description": "Server port number",
+									},
+									"enabled": map[string]interface{}{
+										"type":        "boolean",
+										"description": "Server enabled status",
+										"default":     true,
+									},
+								},
+								"required": []string{"ip"},
+							},
+						},
+						"default_server_port": map[string]interface{}{
+							"type":        "integer",
+							"description": "Default port for servers",
+							"default":     80,
+						},
+						"lb_algorithm": map[string]interface{}{
+							"type":        "string",
+							"description": "Load balancing algorithm (LB_ALGORITHM_ROUND_ROBIN, LB_ALGORITHM_LEAST_CONNECTIONS, LB_ALGORITHM_FASTEST_RESPONSE)",
+							"default":     "LB_ALGORITHM_LEAST_CONNECTIONS",
+						},
+					},
+					"required": []string{"name"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "update_pool",
+				Description: "Update an existing pool. Only the given fields are changed. Use this when users want to modify pool settings such as the load balancing algorithm, health monitors or the full server list.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the pool to update (required)",
+						},
+						"name": map[string]interface{}{
+							"type":        "string",
+							"description": "New name for the pool",
+						},
+						"enabled": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Enable or disable the pool",
+						},
+						"default_server_port": map[string]interface{}{
+							"type":        "integer",
+							"description": "Default port for servers",
+						},
+						"lb_algorithm": map[string]interface{}{
+							"type":        "string",
+							"description": "Load balancing algorithm (LB_ALGORITHM_ROUND_ROBIN, LB_ALGORITHM_LEAST_CONNECTIONS, LB_ALGORITHM_FASTEST_RESPONSE)",
+						},
+						"health_monitor_refs": map[string]interface{}{
+							"type":        "array",
+							"description": "Health monitor references, e.g. /api/healthmonitor?name=System-HTTP",
+							"items": map[string]interface{}{
+								"type": "string",
+							},
+						},
+						"servers": map[string]interface{}{
+							"type":        "array",
+							"description": "Replacement list of backend servers (replaces all existing servers), e.g. [{\"ip\": {\"addr\": \"2001:db8::10\", \"type\": \"V6\"}, \"port\": 80}]",
+							"items": map[string]interface{}{
+								"type": "object",
+							},
+						},
+					},
+					"required": []string{"uuid"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "delete_pool",
+				Description: "Delete a pool. Use this when users want to remove or delete a pool. The pool must not be referenced by a virtual service.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the pool to delete (required)",
+						},
+					},
+					"required": []string{"uuid"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "scale_out_pool",
+				Description: "Scale out a pool by adding more servers. Use this when users want to add capacity or scale out backend servers.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the pool to scale out (required)",
+						},
+						"reason": map[string]interface{}{
+							"type":        "string",
+							"description": "Reason for scaling out",
+							"default":     "Manual scale out operation",
+						},
+					},
+					"required": []string{"uuid"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "scale_in_pool",
+				Description: "Scale in a pool by removing servers. Use this when users want to reduce capacity or scale in backend servers.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the pool to scale in (required)",
+						},
+						"servers": map[string]interface{}{
+							"type":        "array",
+							"description": "List of servers to remove",
+							"items": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"ip": map[string]interface{}{
+										"type": "object",
+										"properties": map[string]interface{}{
+											"addr": map[string]interface{}{
+												"type":        "string",
+												"description": "IPv4 or IPv6 address of the server, without brackets",
+											},
+											"type": map[string]interface{}{
+												"type":        "string",
+												"description": "IP address type (V4, V6, DNS), derived from addr when omitted",
+											},
+										},
+									},
+									"port": map[string]interface{}{
+										"type": "integer",
+									},
+								},
+							},
+						},
+						"reason": map[string]interface{}{
+							"type":        "string",
+							"description": "Reason for scaling in",
+							"default":     "Manual scale in operation",
+						},
+					},
+					"required": []string{"uuid"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "enable_pool_server",
+				Description: "Enable a single backend server within a pool, identified by IP:port, without changing the other servers. Use this when users want to put a server back into rotation after maintenance.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the pool containing the server (required)",
+						},
+						"server": map[string]interface{}{
+							"type":        "string",
+							"description": "Server address as IP:port (e.g. 10.1.1.10:80 or [2001:db8::10]:80), or just the IP if it appears once in the pool (required)",
+						},
+					},
+					"required": []string{"uuid", "server"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "disable_pool_server",
+				Description: "Disable a single backend server within a pool, identified by IP:port, without changing the other servers. Use this when users want to drain a backend or take one server out of rotation for maintenance.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the pool containing the server (required)",
+						},
+						"server": map[string]interface{}{
+							"type":        "string",
+							"description": "Server address as IP:port (e.g. 10.1.1.10:80 or [2001:db8::10]:80), or just the IP if it appears once in the pool (required)",
+						},
+					},
+					"required": []string{"uuid", "server"},
+				},
+			},
+		},
+
+		// Health Monitor Operations
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "list_health_monitors",
+				Description: "List all health monitors. Use this when users ask about health checks, monitoring, or health status.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"name": map[string]interface{}{
+							"type":        "string",
+							"description": "Filter by health monitor name",
+						},
+						"type": map[string]interface{}{
+							"type":        "string",
+							"description": "Filter by health monitor type (HEALTH_MONITOR_HTTP, HEALTH_MONITOR_HTTPS, HEALTH_MONITOR_TCP, etc.)",
+						},
+						"fields": map[string]interface{}{
+							"type":        "string",
+							"description": "Comma-separated list of fields to return",
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "get_health_monitor",
+				Description: "Get details of a specific health monitor by UUID. Use this for detailed health monitor configuration.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the health monitor (required)",
+						},
+						"fields": map[string]interface{}{
+							"type":        "string",
+							"description": "Comma-separated list of fields to return",
+						},
+					},
+					"required": []string{"uuid"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "create_health_monitor",
+				Description: "Create a new health monitor. Use this when users want to add a health check for backend servers.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"name": map[string]interface{}{
+							"type":        "string",
+							"description": "Name of the health monitor (required)",
+						},
+						"type": map[string]interface{}{
+							"type":        "string",
+							"description": "Health monitor type (HEALTH_MONITOR_HTTP, HEALTH_MONITOR_HTTPS, HEALTH_MONITOR_TCP, HEALTH_MONITOR_PING, etc.) (required)",
+						},
+						"send_interval": map[string]interface{}{
+							"type":        "integer",
+							"description": "Seconds between health checks",
+							"default":     10,
+						},
+						"receive_timeout": map[string]interface{}{
+							"type":        "integer",
+							"description": "Seconds to wait for a response",
+							"default":     4,
+						},
+						"successful_checks": map[string]interface{}{
+							"type":        "integer",
+							"description": "Consecutive successful checks before marking a server up",
+							"default":     2,
+						},
+						"failed_checks": map[string]interface{}{
+							"type":        "integer",
+							"description": "Consecutive failed checks before marking a server down",
+							"default":     2,
+						},
+						"http_monitor": map[string]interface{}{
+							"type":        "object",
+							"description": "HTTP settings, e.g. {\"http_request\": \"HEAD / HTTP/1.0\", \"http_response_code\": [\"HTTP_2XX\", \"HTTP_3XX\"]}",
+						},
+					},
+					"required": []string{"name", "type"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "update_health_monitor",
+				Description: "Update an existing health monitor. Only the given fields are changed. Use this when users want to tune check intervals, timeouts or HTTP settings.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the health monitor to update (required)",
+						},
+						"send_interval": map[string]interface{}{
+							"type":        "integer",
+							"description": "Seconds between health checks",
+						},
+						"receive_timeout": map[string]interface{}{
+							"type":        "integer",
+							"description": "Seconds to wait for a response",
+						},
+						"successful_checks": map[string]interface{}{
+							"type":        "integer",
+							"description": "Consecutive successful checks before marking a server up",
+						},
+						"failed_checks": map[string]interface{}{
+							"type":        "integer",
+							"description": "Consecutive failed checks before marking a server down",
+						},
+						"http_monitor": map[string]interface{}{
+							"type":        "object",
+							"description": "HTTP settings",
+						},
+					},
+					"required": []string{"uuid"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "delete_health_monitor",
+				Description: "Delete a health monitor. Use this when users want to remove a health check. The monitor must not be referenced by a pool.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the health monitor to delete (required)",
+						},
+					},
+					"required": []string{"uuid"},
+				},
+			},
+		},
+
+		// Application and Persistence Profile Operations
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "list_application_profiles",
+				Description: "List application profiles. Use this when users ask about HTTP, L4 or SSL application settings such as HTTP/2, compression, X-Forwarded-For or connection multiplexing.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"name": map[string]interface{}{
+							"type":        "string",
+							"description": "Filter by profile name",
+						},
+						"type": map[string]interface{}{
+							"type":        "string",
+							"description": "Filter by profile type (APPLICATION_PROFILE_TYPE_HTTP, APPLICATION_PROFILE_TYPE_L4, APPLICATION_PROFILE_TYPE_SSL, etc.)",
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "get_application_profile",
+				Description: "Get details of a specific application profile by UUID, including its HTTP and HTTP/2 settings.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the application profile (required)",
+						},
+					},
+					"required": []string{"uuid"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "create_application_profile",
+				Description: "Create a new application profile. Use this when users want custom HTTP behaviour, for example enabling HTTP/2 or X-Forwarded-For.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"name": map[string]interface{}{
+							"type":        "string",
+							"description": "Name of the application profile (required)",
+						},
+						"type": map[string]interface{}{
+							"type":        "string",
+							"description": "Profile type (APPLICATION_PROFILE_TYPE_HTTP, APPLICATION_PROFILE_TYPE_L4, APPLICATION_PROFILE_TYPE_SSL) (required)",
+							"default":     "APPLICATION_PROFILE_TYPE_HTTP",
+						},
+						"http_profile": map[string]interface{}{
+							"type":        "object",
+							"description": "HTTP settings, e.g. {\"x_forwarded_proto_enabled\": true, \"xff_enabled\": true, \"connection_multiplexing_enabled\": true, \"http2_profile\": {\"max_http2_concurrent_streams_per_connection\": 128}}",
+						},
+					},
+					"required": []string{"name", "type"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "attach_application_profile",
+				Description: "Attach an application profile to a virtual service, replacing its current one.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the virtual service (required)",
+						},
+						"profile_uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the application profile to attach (required)",
+						},
+					},
+					"required": []string{"uuid", "profile_uuid"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "list_persistence_profiles",
+				Description: "List application persistence profiles. Use this when users ask about session persistence, sticky sessions or cookie persistence.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"name": map[string]interface{}{
+							"type":        "string",
+							"description": "Filter by profile name",
+						},
+						"type": map[string]interface{}{
+							"type":        "string",
+							"description": "Filter by persistence type (PERSISTENCE_TYPE_HTTP_COOKIE, PERSISTENCE_TYPE_CLIENT_IP_ADDRESS, PERSISTENCE_TYPE_CUSTOM_HTTP_HEADER, etc.)",
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "get_persistence_profile",
+				Description: "Get details of a specific application persistence profile by UUID.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the persistence profile (required)",
+						},
+					},
+					"required": []string{"uuid"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "create_persistence_profile",
+				Description: "Create a new application persistence profile. Use this when users want sticky sessions, for example HTTP cookie persistence.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"name": map[string]interface{}{
+							"type":        "string",
+							"description": "Name of the persistence profile (required)",
+						},
+						"persistence_type": map[string]interface{}{
+							"type":        "string",
+							"description": "Persistence type (PERSISTENCE_TYPE_HTTP_COOKIE, PERSISTENCE_TYPE_CLIENT_IP_ADDRESS, PERSISTENCE_TYPE_CUSTOM_HTTP_HEADER, PERSISTENCE_TYPE_APP_COOKIE) (required)",
+						},
+						"http_cookie_persistence_profile": map[string]interface{}{
+							"type":        "object",
+							"description": "Cookie settings, e.g. {\"cookie_name\": \"AVISESSION\", \"timeout\": 60, \"always_send_cookie\": false}",
+						},
+						"ip_persistence_profile": map[string]interface{}{
+							"type":        "object",
+							"description": "Client IP settings, e.g. {\"ip_persistent_timeout\": 5}",
+						},
+					},
+					"required": []string{"name", "persistence_type"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "attach_persistence_profile",
+				Description: "Attach a persistence profile to a virtual service. Persistence is configured on the pool, so the virtual service's default pool is updated unless a pool is given.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the virtual service (required)",
+						},
+						"profile_uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the persistence profile to attach (required)",
+						},
+						"pool_uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the pool to update instead of the virtual service's default pool",
+						},
+					},
+					"required": []string{"uuid", "profile_uuid"},
+				},
+			},
+		},
+
+		// Service Engine Operations
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "list_service_engines",
+				Description: "List all service engines. Use this when users ask about service engines, load balancer instances, or data plane components.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"name": map[string]interface{}{
+							"type":        "string",
+							"description": "Filter by service engine name",
+						},
+						"se_group_ref": map[string]interface{}{
+							"type":        "string",
+							"description": "Filter by service engine group reference",
+						},
+						"fields": map[string]interface{}{
+							"type":        "string",
+							"description": "Comma-separated list of fields to return",
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "get_service_engine",
+				Description: "Get details of a specific service engine by UUID. Use this for detailed service engine information.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the service engine (required)",
+						},
+						"fields": map[string]interface{}{
+							"type":        "string",
+							"description": "Comma-separated list of fields to return",
+						},
+					},
+					"required": []string{"uuid"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "reboot_service_engine",
+				Description: "Reboot a service engine. Virtual services placed on it fail over or are disrupted while it restarts. Use this only when users explicitly ask to reboot or restart a service engine.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the service engine to reboot (required)",
+						},
+					},
+					"required": []string{"uuid"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "service_engine_maintenance",
+				Description: "Take a service engine out of service for maintenance (e.g. an upgrade) and back, one step per call: plan lists the virtual services placed on it, disable disables it so they migrate to the other service engines of its group, wait waits until none is left on it, verify confirms the migrated virtual services are up, enable puts it back in service. Run the steps in this order, show the result of each and only run the next once the user approves. When the migration doesn't finish in time, wait reports it still in progress and the service engine stays disabled: run wait again. When a virtual service is down after the migration, verify fails and the service engine is enabled again. In the web UI and API the disable and enable steps are held until the operator approves them.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"service_engine": map[string]interface{}{
+							"type":        "string",
+							"description": "Name or UUID of the service engine (required)",
+						},
+						"step": map[string]interface{}{
+							"type":        "string",
+							"enum":        []string{"plan", "disable", "wait", "verify", "enable"},
+							"description": "Maintenance step to run (required)",
+						},
+					},
+					"required": []string{"service_engine", "step"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "list_workflows",
+				Description: "List the recorded multi-step runs (configuration applies and service engine maintenance) with their state, completed steps and next step. Use this when users ask what was interrupted or what can be resumed.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"resumable": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Only list failed or interrupted runs that can be resumed",
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "resume_workflow",
+				Description: "Resume a failed or interrupted run from its first unfinished step: a configuration apply applies only the objects that weren't applied, a service engine maintenance runs its next step, a runbook sends its remaining steps to its session in the background. Show the run with list_workflows and get the user's approval first.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"id": map[string]interface{}{
+							"type":        "string",
+							"description": "ID of the run, e.g. wf-20260101120000-3 (required)",
+						},
+					},
+					"required": []string{"id"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "show_recent_changes",
+				Description: "List the changes made through the agent, most recent first, with the fields each of them changed (before and after values), who made it and its receipt. Use this when users ask what the agent changed, e.g. \"what did the agent change today on web-app-vs\", or before rolling a change back. Changes made on the controller directly aren't listed.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"object": map[string]interface{}{
+							"type":        "string",
+							"description": "Name or UUID of the changed object",
+						},
+						"since": map[string]interface{}{
+							"type":        "string",
+							"description": "Only changes since: today, a time range such as 2h or 7d, a date (2026-01-02) or an RFC 3339 time",
+						},
+						"operator": map[string]interface{}{
+							"type":        "string",
+							"description": "Only changes made by this operator",
+						},
+						"limit": map[string]interface{}{
+							"type":        "integer",
+							"description": "Most recent changes listed (default 20)",
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "rollback_change",
+				Description: "Roll back a change made through the agent by restoring the object as it was before: an updated object is put back, a deleted one is created again, a created one is deleted. Use this when users ask to undo or revert a change, e.g. \"undo my last change\": without an id it rolls back the user's last change that wasn't rolled back. It refuses when the object changed again since, unless force is set; tell the user what would be overwritten and get their approval before forcing.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"id": map[string]interface{}{
+							"type":        "string",
+							"description": "ID of the change, e.g. chg-12; omit it for the user's last change",
+						},
+						"force": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Roll back even when the object changed again after the change, undoing the later changes too",
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "check_drift",
+				Description: "Compare the objects declared in the configuration files of the drift directory with the live controller and list the ones that drifted: fields set to other values than declared and declared objects missing from the controller. Use this when users ask whether the controller still matches the declared or desired configuration, or what was changed outside of it.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"object": map[string]interface{}{
+							"type":        "string",
+							"description": "Name of a declared object, to show its comparison even when it is in sync",
+						},
+					},
+				},
+			},
+		},
+
+		// Session notes
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "remember",
+				Description: "Keep a fact for the rest of the chat session, such as a change window, the service the user is troubleshooting or a decision taken. Kept notes are shown with every later question. Use this when users ask you to remember or note something, or state a constraint that applies to later requests.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"note": map[string]interface{}{
+							"type":        "string",
+							"description": "The fact to keep, as a short self-contained sentence (required)",
+						},
+					},
+					"required": []string{"note"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "recall",
+				Description: "List the notes kept for the chat session with remember. Use this when users ask what was noted or agreed earlier in the session.",
+				Parameters: map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{},
+				},
+			},
+		},
+
+		// Network Routing Operations
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "list_vrf_contexts",
+				Description: "List VRF contexts (routing domains) with their static routes and BGP profile",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"name": map[string]interface{}{
+							"type":        "string",
+							"description": "Filter by VRF context name",
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "get_vrf_routing",
+				Description: "Get the routing configuration of a VRF context: static routes (prefix, next hop, interface) and BGP settings (local AS, peers, remote AS, BFD, VIP/SNAT advertisement). Use this when users ask about routes, default gateways or configured BGP peers.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the VRF context (required)",
+						},
+					},
+					"required": []string{"uuid"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "get_bgp_peer_status",
+				Description: "Get the runtime BGP peering state of a service engine (peer state, uptime, advertised and received routes). Use this when users ask whether BGP sessions are up or why VIPs are not advertised.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the service engine (required)",
+						},
+					},
+					"required": []string{"uuid"},
+				},
+			},
+		},
+
+		// Health and Operational Status Operations
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "get_virtual_service_health",
+				Description: "Get the runtime health score and operational status of virtual services, including the reasons for degraded scores (performance, resources, anomaly, security penalties). Use this when users ask why a virtual service is unhealthy, yellow/red, down, or what its health score is.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the virtual service (omit to summarize all virtual services)",
+						},
+						"name": map[string]interface{}{
+							"type":        "string",
+							"description": "Filter by virtual service name",
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "get_virtual_service_health_score",
+				Description: "Get the health score history of a virtual service from analytics, broken down into performance score and resources, anomaly and security penalties, with a plain-language explanation. Use this when users ask what is dragging a virtual service's health score down or how it changed recently.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the virtual service (required)",
+						},
+						"step": map[string]interface{}{
+							"type":        "integer",
+							"description": "Granularity in seconds (5, 300, 3600, 86400)",
+							"default":     300,
+						},
+						"limit": map[string]interface{}{
+							"type":        "integer",
+							"description": "Number of data points to look back",
+							"default":     12,
+						},
+					},
+					"required": []string{"uuid"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "get_pool_health",
+				Description: "Get the runtime health score and operational status of pools, including the reasons for degraded scores (performance, resources, anomaly, security penalties). Use this when users ask why a pool is unhealthy, yellow/red, down, or what its health score is.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the pool (omit to summarize all pools)",
+						},
+						"name": map[string]interface{}{
+							"type":        "string",
+							"description": "Filter by pool name",
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "get_service_engine_health",
+				Description: "Get the runtime health score and operational status of service engines, including the reasons for degraded scores (performance, resources, anomaly, security penalties). Use this when users ask why a service engine is unhealthy, yellow/red, down, or what its health score is.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the service engine (omit to summarize all service engines)",
+						},
+						"name": map[string]interface{}{
+							"type":        "string",
+							"description": "Filter by service engine name",
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "explain_vs_health",
+				Description: "Explain what happened to a virtual service's health as a short incident summary. Reads its health score, latency, error and connection metrics, anomalies and pool member state over a time range and correlates them on a timeline (e.g. latency spiked at 14:02 when a pool member went down). Use this when users ask why a virtual service is unhealthy, slow or erroring.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the virtual service (required)",
+						},
+						"time_range": map[string]interface{}{
+							"type":        "string",
+							"description": "Time range to explain (e.g., '1h', '6h', '24h', '7d')",
+							"default":     "1h",
+						},
+					},
+					"required": []string{"uuid"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "acknowledge_insight",
+				Description: "Acknowledge or snooze an insight (an expiring certificate or anomalous traffic warning shown as 'Insight <id>') so it isn't repeated to the operator. Use this when users say they know about a warning or want it silenced for a while.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"insight_id": map[string]interface{}{
+							"type":        "string",
+							"description": "ID of the insight, e.g. cert-1a2b3c4d (required)",
+						},
+						"snooze": map[string]interface{}{
+							"type":        "string",
+							"description": "Hide the insight for this long (e.g. 4h, 7d) instead of acknowledging it permanently",
+						},
+					},
+					"required": []string{"insight_id"},
+				},
+			},
+		},
+
+		// Security Operations
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "security_audit",
+				Description: "Audit the security posture of a virtual service: TLS protocol versions and ciphers of its SSL profile, certificate expiry, key strength and chain, HSTS and other HTTP security headers, and WAF status. Returns an A-F grade with findings and remediation suggestions. Use this when users ask how secure a virtual service is, for a TLS grade, or about HSTS, ciphers or WAF.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the virtual service (required)",
+						},
+					},
+					"required": []string{"uuid"},
+				},
+			},
+		},
+
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "simulate_change",
+				Description: "Answer \"what if\" questions without changing anything: evaluates a proposed change against a cached inventory of pools, virtual services, health monitors and service engines and returns the remaining pool capacity, the virtual services that degrade or go down, the health monitors affected, constraints that make the controller reject the change, and risks. Use this when users ask what would happen if they removed a server, deleted a pool or health monitor, or disabled a virtual service or service engine, and before recommending such a change. Never call the real change tool to answer a what-if question.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"action": map[string]interface{}{
+							"type":        "string",
+							"description": "Proposed change (required)",
+							"enum":        []string{"remove_server", "disable_server", "delete_pool", "delete_health_monitor", "disable_virtual_service", "disable_service_engine"},
+						},
+						"target": map[string]interface{}{
+							"type":        "string",
+							"description": "Name or UUID of the pool, health monitor, virtual service or service engine; for server changes, the pool (optional, all pools containing the server when omitted)",
+						},
+						"server": map[string]interface{}{
+							"type":        "string",
+							"description": "Pool server address, \"ip\" or \"ip:port\" (required for remove_server and disable_server)",
+						},
+						"refresh": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Read the inventory again instead of using the cached one, e.g. after a change was made",
+						},
+					},
+					"required": []string{"action"},
+				},
+			},
+		},
+
+		// Controller System Operations
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "get_controller_info",
+				Description: "Get the controller version and build, controller cluster nodes and their state, and system settings (DNS, NTP, default license tier). Use this when users ask what version the controller runs or whether the cluster is healthy.",
+				Parameters: map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "get_upgrade_status",
+				Description: "Get the upgrade status of the controller cluster and service engine groups: whether an upgrade is in progress, its progress, current and previous versions, and failed or paused upgrades. Use this when users ask about upgrades.",
+				Parameters: map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "get_license_usage",
+				Description: "Get the installed licenses and license capacity: total, used and remaining cores, usage percentage and expired licenses. Use this when users ask about licensing or how much license capacity is left.",
+				Parameters: map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{},
+				},
+			},
+		},
+
+		// Tenant and User Administration Operations (offered only to administrator accounts)
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "list_tenants",
+				Description: "List the tenants on the controller with their description and whether they are local (don't share the admin tenant's objects).",
+				Parameters: map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "create_tenant",
+				Description: "Create a new tenant on the controller",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"name": map[string]interface{}{
+							"type":        "string",
+							"description": "Name of the tenant",
+						},
+						"description": map[string]interface{}{
+							"type":        "string",
+							"description": "Description of the tenant",
+						},
+						"local": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Keep the tenant's objects separate from the admin tenant (default true)",
+							"default":     true,
+						},
+					},
+					"required": []string{"name"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "list_users",
+				Description: "List the controller users with their roles per tenant, superuser and active status. Use this when users ask who has access or which role a user holds.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"tenant": map[string]interface{}{
+							"type":        "string",
+							"description": "Only list users with access to this tenant",
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "list_roles",
+				Description: "List the roles defined on the controller and the access (NO_ACCESS, READ_ACCESS or WRITE_ACCESS) each grants per resource",
+				Parameters: map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{},
+				},
+			},
+		},
+
+		// Controller Backup and Configuration Operations
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "list_backups",
+				Description: "List the configuration backups stored on the controller, newest first. Use this when users ask about existing backups or when the last backup ran.",
+				Parameters: map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "trigger_backup",
+				Description: "Run an on-demand configuration backup on the controller. Use this when users ask to back up the controller configuration now.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"backup_configuration": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the backup configuration to use (defaults to Backup-Configuration)",
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "export_configuration",
+				Description: "Export the full controller configuration. Returns object counts per type and a download link for the complete export. Use this when users want a configuration export or a count of configured objects.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"full_system": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Include system objects (cloud, SE groups, system configuration)",
+						},
+					},
+				},
+			},
+		},
+
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "apply_configuration",
+				Description: "Apply an Avi configuration: create or update objects by name from a configuration export or a partial set of objects. Reports success or failure per object. Use this when users want to import, restore or apply configuration JSON. Run with dry_run first to validate.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"configuration": map[string]interface{}{
+							"type":        "object",
+							"description": "Configuration in export layout: object type (e.g. Pool, HealthMonitor, VirtualService) mapped to a list of objects, each with a name",
+						},
+						"dry_run": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Only validate the objects without applying them",
+						},
+					},
+					"required": []string{"configuration"},
+				},
+			},
+		},
+
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "list_blueprints",
+				Description: "List the blueprints of common application patterns, such as an HTTPS application with an HTTP redirect or an L4 TCP application, with the parameters each takes. Use this when users ask to create a standard application, to pick the blueprint and learn its parameters.",
+				Parameters: map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "apply_blueprint",
+				Description: "Create the objects of a blueprint (health monitor, pool, VIP, virtual service, ...) in dependency order from its parameters, e.g. \"create a standard HTTPS app called foo on 10.1.1.50 with backends 10.0.0.1 and 10.0.0.2\". Parameter values are validated first and every missing or invalid one is reported; refuses when an object of the blueprint already exists. Run with dry_run first to show the objects and validate them.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"blueprint": map[string]interface{}{
+							"type":        "string",
+							"description": "Name of the blueprint, e.g. https-app (required)",
+						},
+						"parameters": map[string]interface{}{
+							"type":        "object",
+							"description": "Parameter values by name, e.g. {\"name\": \"foo\", \"vip\": \"10.1.1.50\", \"servers\": [\"10.0.0.1\", \"10.0.0.2:8080\"]}",
+						},
+						"dry_run": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Only expand and validate the objects without creating them",
+						},
+					},
+					"required": []string{"blueprint", "parameters"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "generate_terraform",
+				Description: "Write existing objects, or a proposed change, as configuration of the vmware/avi Terraform provider (HCL) instead of changing the controller. Use this when users manage Avi with Terraform or infrastructure as code and want the configuration to commit, e.g. \"give me the Terraform for the web virtual service\". Show the hcl of the result in an hcl code block.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"objects": map[string]interface{}{
+							"type":        "array",
+							"description": "Existing objects to write, by type and name",
+							"items": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"type": map[string]interface{}{
+										"type":        "string",
+										"description": "Object type as the API names it, e.g. virtualservice, pool, healthmonitor, vsvip",
+									},
+									"name": map[string]interface{}{
+										"type":        "string",
+										"description": "Name of the object",
+									},
+								},
+								"required": []string{"type", "name"},
+							},
+						},
+						"include_references": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Also write the objects they refer to, such as the pool and VIP of a virtual service, rather than look them up by name",
+							"default":     true,
+						},
+						"configuration": map[string]interface{}{
+							"type":        "object",
+							"description": "A proposed change instead of existing objects, in export layout as apply_configuration takes it: object type mapped to a list of objects, each with a name",
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "generate_ansible_playbook",
+				Description: "Write existing objects, or a proposed change, as an Ansible playbook of the vmware.alb avi_* modules instead of changing the controller. Use this when users automate Avi with Ansible and want tasks to run, e.g. \"give me an Ansible playbook for the web virtual service\". Show the playbook of the result in a yaml code block and give the download_url when there is one.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"objects": map[string]interface{}{
+							"type":        "array",
+							"description": "Existing objects to write, by type and name",
+							"items": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"type": map[string]interface{}{
+										"type":        "string",
+										"description": "Object type as the API names it, e.g. virtualservice, pool, healthmonitor, vsvip",
+									},
+									"name": map[string]interface{}{
+										"type":        "string",
+										"description": "Name of the object",
+									},
+								},
+								"required": []string{"type", "name"},
+							},
+						},
+						"include_references": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Also write the objects they refer to, such as the pool and VIP of a virtual service, rather than refer to them by name",
+							"default":     true,
+						},
+						"configuration": map[string]interface{}{
+							"type":        "object",
+							"description": "A proposed change instead of existing objects, in export layout as apply_configuration takes it: object type mapped to a list of objects, each with a name",
+						},
+					},
+				},
+			},
+		},
+
+		// Analytics Operations
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "get_analytics",
+				Description: "Get metrics for a virtual service, pool or service engine over a time range, with min, max, mean and latest values and the data points. Use this when users ask about performance, traffic, throughput, connections, latency, errors or resource usage. Metric IDs look like l4_client.avg_bandwidth; call list_metrics to see the supported IDs.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"resource_type": map[string]interface{}{
+							"type":        "string",
+							"description": "Type of resource (virtualservice, pool, serviceengine) (required)",
+							"enum":        []string{"virtualservice", "pool", "serviceengine"},
+						},
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the resource (required)",
+						},
+						"metrics": map[string]interface{}{
+							"type":        "array",
+							"description": "Metric IDs to retrieve (e.g. l4_client.avg_bandwidth, l7_client.avg_error_responses, se_stats.avg_cpu_usage) or the shorthands connections, throughput, latency, errors, cpu, memory. Defaults to the main traffic metrics of the resource type.",
+							"items":       map[string]interface{}{"type": "string"},
+						},
+						"time_range": map[string]interface{}{
+							"type":        "string",
+							"description": "Time range for metrics (e.g. 30m, 1h, 6h, 24h, 7d)",
+							"default":     "1h",
+						},
+					},
+					"required": []string{"resource_type", "uuid"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "list_metrics",
+				Description: "List the metric IDs get_analytics supports, with their units and description, optionally for one resource type",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"resource_type": map[string]interface{}{
+							"type":        "string",
+							"description": "Only list metrics collected for this resource type",
+							"enum":        []string{"virtualservice", "pool", "serviceengine"},
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "get_top_virtual_services",
+				Description: "Rank all virtual services by connections, throughput or errors over a time range and return the top N with mean, peak and latest values. Use this for questions like 'which virtual services have the most traffic' or 'top 5 VSs by errors today' instead of querying each virtual service.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"by": map[string]interface{}{
+							"type":        "string",
+							"description": "Measure to rank by",
+							"enum":        []string{"connections", "throughput", "errors"},
+							"default":     "connections",
+						},
+						"count": map[string]interface{}{
+							"type":        "integer",
+							"description": "Number of virtual services to return",
+							"default":     10,
+						},
+						"time_range": map[string]interface{}{
+							"type":        "string",
+							"description": "Time range to rank over (e.g. 1h, 6h, 24h, 7d)",
+							"default":     "1h",
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "create_alert_rule",
+				Description: "Create a metric alert rule on a virtual service from a request such as 'alert me when payments-vs error rate exceeds 2% for 5 minutes': an Avi AlertConfig with its ActionGroupConfig. Always call it with preview=true first, show the rule to the user and only call it again with preview=false once they approve.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"virtual_service": map[string]interface{}{
+							"type":        "string",
+							"description": "Name or UUID of the virtual service (required)",
+						},
+						"metric": map[string]interface{}{
+							"type":        "string",
+							"description": "Metric to watch: error_rate (percent of HTTP errors), errors, latency, connections, throughput, health or a metric ID from list_metrics (required)",
+						},
+						"comparator": map[string]interface{}{
+							"type":        "string",
+							"description": "Alert when the metric is greater than (gt), at least (ge), less than (lt) or at most (le) the threshold",
+							"enum":        []string{"gt", "ge", "lt", "le"},
+							"default":     "gt",
+						},
+						"threshold": map[string]interface{}{
+							"type":        "number",
+							"description": "Threshold in the units of the metric, e.g. 2 for 2% (required)",
+						},
+						"duration": map[string]interface{}{
+							"type":        "string",
+							"description": "How long the condition must hold (e.g. 1m, 5m, 15m)",
+							"default":     "5m",
+						},
+						"severity": map[string]interface{}{
+							"type":    "string",
+							"enum":    []string{"low", "medium", "high"},
+							"default": "medium",
+						},
+						"name": map[string]interface{}{
+							"type":        "string",
+							"description": "Name of the rule (generated when omitted)",
+						},
+						"email_config": map[string]interface{}{
+							"type":        "string",
+							"description": "Name of an existing alert email configuration to notify",
+						},
+						"syslog_config": map[string]interface{}{
+							"type":        "string",
+							"description": "Name of an existing alert syslog configuration to notify",
+						},
+						"preview": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Only build and return the rule without creating it",
+						},
+					},
+					"required": []string{"virtual_service", "metric", "threshold"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "list_alert_rules",
+				Description: "List the alert rules created through the chat with their virtual service, condition and severity.",
+				Parameters: map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "delete_alert_rule",
+				Description: "Remove an alert rule created through the chat, with its action group. Rules created otherwise can't be removed with this tool.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"name": map[string]interface{}{
+							"type":        "string",
+							"description": "Name of the alert rule (required)",
+						},
+					},
+					"required": []string{"name"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "get_sla_report",
+				Description: "Availability of the virtual services over a period against an SLA target, computed from their down and up transitions: availability percent, downtime, number of outages, longest outage, remaining error budget and average health score, least available first. Use this for uptime, SLA or error budget questions.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"period": map[string]interface{}{
+							"type":        "string",
+							"description": "Period to report on (e.g. 24h, 7d, 30d)",
+							"default":     "30d",
+						},
+						"target": map[string]interface{}{
+							"type":        "number",
+							"description": "Availability target in percent",
+							"default":     99.9,
+						},
+						"virtual_services": map[string]interface{}{
+							"type":        "array",
+							"description": "Names or UUIDs of the virtual services to report on (omit for all)",
+							"items":       map[string]interface{}{"type": "string"},
+						},
+					},
+				},
+			},
+		},
+
+		// Generic Operations
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "generate_tech_support",
+				Description: "Collect a tech-support bundle for a support case and return its download link. The controller gathers logs and state for the chosen level; the call waits for the collection and, if it is still running, says to list the bundles later.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"level": map[string]interface{}{
+							"type":        "string",
+							"description": "What to collect for (required)",
+							"enum":        []string{"controller", "clustering", "debuglogs", "upgrade", "gslb", "serviceengine", "virtualservice", "pool"},
+						},
+						"uuid": map[string]interface{}{
+							"type":        "string",
+							"description": "UUID of the service engine, virtual service or pool (required for those levels)",
+						},
+						"case_number": map[string]interface{}{
+							"type":        "string",
+							"description": "Support case number to attach the bundle to",
+						},
+						"description": map[string]interface{}{
+							"type":        "string",
+							"description": "Short description of the problem",
+						},
+					},
+					"required": []string{"level"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "list_tech_support",
+				Description: "List the tech-support bundles on the controller, newest first, with their download links",
+				Parameters: map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "download_file",
+				Description: "Prepare a download link for a file on the controller, such as a tech-support bundle, a configuration export or a packet capture. The file is streamed to the user's browser, not read by you. Controller files are served from /fileservice with a uri parameter, e.g. controller://tech_support/bundle.tar.gz.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"endpoint": map[string]interface{}{
+							"type":        "string",
+							"description": "API endpoint path returning the file (e.g., /fileservice) (required)",
+						},
+						"parameters": map[string]interface{}{
+							"type":        "object",
+							"description": "Query parameters as key-value pairs, e.g. {\"uri\": \"controller://tech_support/bundle.tar.gz\"}",
+						},
+					},
+					"required": []string{"endpoint"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "execute_generic_operation",
+				Description: "Execute a generic API operation when specific tools don't cover the user's request. Use this as a fallback for advanced or specific API calls. The deployment limits the methods and endpoints it allows, GET only by default, and the size of the body; calls that change configuration wait for the operator's approval.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"method": map[string]interface{}{
+							"type":        "string",
+							"description": "HTTP method (GET, POST, PUT, DELETE, PATCH) (required)",
+							"enum":        []string{"GET", "POST", "PUT", "DELETE", "PATCH"},
+						},
+						"endpoint": map[string]interface{}{
+							"type":        "string",
+							"description": "API endpoint path (e.g., /virtualservice, /pool/uuid/scaleout) (required)",
+						},
+						"body": map[string]interface{}{
+							"type":        "object",
+							"description": "Request body for POST/PUT/PATCH operations",
+						},
+						"parameters": map[string]interface{}{
+							"type":        "object",
+							"description": "Query parameters as key-value pairs",
+						},
+					},
+					"required": []string{"method", "endpoint"},
+				},
+			},
+		},
+	}
+	for _, tool := range tools {
+		if PagedTools[tool.Function.Name] {
+			properties := tool.Function.Parameters.(map[string]interface{})["properties"].(map[string]interface{})
+			properties["page"] = map[string]interface{}{
+				"type":        "integer",
+				"description": "Page of results to return, starting at 1; use the page a truncated result names to read the rest",
+			}
+			properties["page_size"] = map[string]interface{}{
+				"type":        "integer",
+				"description": "Results per page, 25 when omitted",
+			}
+		}
+	}
+	return tools
+}
+
+// PagedTools are the list tools whose arguments are sent to the controller as query parameters,
+// so page and page_size read the rest of a long list
+var PagedTools = map[string]bool{
+	"list_virtual_services":     true,
+	"list_pools":                true,
+	"list_health_monitors":      true,
+	"list_application_profiles": true,
+	"list_persistence_profiles": true,
+	"list_service_engines":      true,
+}
+
+// TruncatedResultsPrompt tells the model how to read the truncation markers of cut-short results
+const TruncatedResultsPrompt = `Long tool results are cut short. A result with a "_truncated" field lists, for each cut list, how many items are shown, how many were omitted and the total; "next" gives the arguments that read the following page. Never present the shown items as the whole list: state the total, and read the next page or narrow the request with a filter when the user needs the rest.`
+
+// GetToolByName returns a built-in tool definition by name
+func GetToolByName(name string) (*Tool, error) {
+	return (*Tools)(nil).ByName(name)
+}
+
+// ByName returns the definition of a tool offered to the model
+func (t *Tools) ByName(name string) (*Tool, error) {
+	if tool, ok := t.Find(name); ok {
+		return &tool, nil
+	}
+	return nil, fmt.Errorf("tool '%s' not found", name)
+}
+
+// GetToolNames returns a list of all built-in tool names
+func GetToolNames() []string {
+	return (*Tools)(nil).Names()
+}
+
+// mutatingTools are the tools that change controller configuration
+var mutatingTools = map[string]bool{
+	"create_virtual_service":     true,
+	"update_virtual_service":     true,
+	"delete_virtual_service":     true,
+	"enable_virtual_service":     true,
+	"disable_virtual_service":    true,
+	"scale_out_virtual_service":  true,
+	"scale_in_virtual_service":   true,
+	"migrate_virtual_service":    true,
+	"switchover_virtual_service": true,
+	"reboot_service_engine":      true,
+	"service_engine_maintenance": true,
+	"resume_workflow":            true,
+	"rollback_change":            true,
+	"trigger_backup":             true,
+	"apply_configuration":        true,
+	"apply_blueprint":            true,
+	"create_tenant":              true,
+	"create_pool":                true,
+	"update_pool":                true,
+	"delete_pool":                true,
+	"scale_out_pool":             true,
+	"scale_in_pool":              true,
+	"enable_pool_server":         true,
+	"disable_pool_server":        true,
+	"create_health_monitor":      true,
+	"update_health_monitor":      true,
+	"delete_health_monitor":      true,
+	"create_application_profile": true,
+	"attach_application_profile": true,
+	"create_persistence_profile": true,
+	"attach_persistence_profile": true,
+	"create_alert_rule":          true,
+	"delete_alert_rule":          true,
+}
+
+// IsMutatingTool reports whether a call to a built-in tool changes controller configuration, as
+// Tools.IsMutating
+func IsMutatingTool(name string, args map[string]interface{}) bool {
+	return (*Tools)(nil).IsMutating(name, args)
+}
+
+// IsMutating reports whether a tool call changes controller configuration. Generic operations
+// are mutating unless they use GET, configuration and blueprint applies unless they are dry
+// runs, alert rules unless they are previews, service engine maintenance unless it is the plan
+// step (verifying re-enables the service engine when it aborts), and custom tools unless they
+// use GET.
+func (t *Tools) IsMutating(name string, args map[string]interface{}) bool {
+	switch name {
+	case "execute_generic_operation":
+		method, _ := args["method"].(string)
+		return !strings.EqualFold(method, "GET")
+	case "apply_configuration", "apply_blueprint":
+		dryRun, _ := ArgBool(args, "dry_run")
+		return !dryRun
+	case "create_alert_rule":
+		preview, _ := ArgBool(args, "preview")
+		return !preview
+	case "service_engine_maintenance":
+		step, _ := args["step"].(string)
+		return step != "plan"
+	}
+	if tool, ok := t.Custom(name); ok {
+		return customMethod(tool) != "GET"
+	}
+	return mutatingTools[name]
+}
+
+// timeRangeTools are tools whose results depend on a time window computed from the agent clock
+var timeRangeTools = map[string]bool{
+	"explain_vs_health":                true,
+	"get_analytics":                    true,
+	"get_top_virtual_services":         true,
+	"get_sla_report":                   true,
+	"get_virtual_service_health_score": true,
+}
+
+// IsTimeRangeTool reports whether the results of a tool depend on a time window computed from
+// the agent clock, so controller clock skew shifts them
+func IsTimeRangeTool(name string) bool {
+	return timeRangeTools[name]
+}
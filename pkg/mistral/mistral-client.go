@@ -7,10 +7,14 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
-	"aviagent/internal/config"
-	"aviagent/internal/llm"
+	"aviagent/internal/requestid"
+	"aviagent/pkg/config"
+	"aviagent/pkg/llm"
 
 	"go.uber.org/zap"
 )
@@ -21,8 +25,18 @@ type Client struct {
 	httpClient *http.Client
 	logger     *zap.Logger
 	apiKey     string
+
+	// rateLimitedUntil queues requests behind an active 429 back-off
+	rateLimitMu      sync.Mutex
+	rateLimitedUntil time.Time
+
+	// jsonMode remembers models that rejected response_format
+	jsonMode llm.JSONModeSupport
 }
 
+// maxRetryAfter caps how long a single Retry-After back-off may last
+const maxRetryAfter = 60 * time.Second
+
 // ChatMessage represents a chat message for Mistral AI
 type ChatMessage struct {
 	Role    string `json:"role"`
@@ -48,9 +62,16 @@ type ChatRequest struct {
 	Messages   []ChatMessage `json:"messages"`
 	Tools      []Tool        `json:"tools,omitempty"`
 	ToolChoice interface{}   `json:"tool_choice,omitempty"`
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
 	Stream     bool          `json:"stream,omitempty"`
 	Temperature float64     `json:"temperature,omitempty"`
 	MaxTokens  int           `json:"max_tokens,omitempty"`
+	RandomSeed *int          `json:"random_seed,omitempty"` // fixed sampling seed for reproducible output
+}
+
+// ResponseFormat constrains the format of the model output
+type ResponseFormat struct {
+	Type string `json:"type"` // "text" or "json_object"
 }
 
 // ChatResponse represents a chat completion response from Mistral AI
@@ -62,6 +83,9 @@ type ChatResponse struct {
 	Choices         []Choice    `json:"choices"`
 	Usage           Usage       `json:"usage"`
 	SystemFingerprint string    `json:"system_fingerprint"`
+
+	// Notices records rate limit retries that happened while producing this response
+	Notices []string `json:"-"`
 }
 
 // Choice represents a response choice from Mistral AI
@@ -148,6 +172,7 @@ func NewClient(cfg *config.MistralConfig, apiKey string, logger *zap.Logger) (*C
 
 // makeRequest performs an authenticated API request to Mistral AI
 func (c *Client) makeRequest(ctx context.Context, method, endpoint string, body interface{}) (*http.Response, error) {
+	logger := requestid.Logger(ctx, c.logger)
 	var bodyReader io.Reader
 	if body != nil {
 		jsonData, err := json.Marshal(body)
@@ -167,16 +192,17 @@ func (c *Client) makeRequest(ctx context.Context, method, endpoint string, body
 	// Set required headers
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
+	requestid.SetHeader(ctx, req)
 
 	// Log complete HTTP request details
-	c.logger.Info("HTTP Request Details",
+	logger.Debug("HTTP Request Details",
 		zap.String("method", method),
 		zap.String("url", requestURL),
 		zap.String("content_type", req.Header.Get("Content-Type")),
 		zap.String("authorization", "Bearer ***REDACTED***"))
 
 	// Log request headers
-	c.logger.Info("Request Headers",
+	logger.Debug("Request Headers",
 		zap.Any("headers", req.Header))
 
 	// If this is a POST request with a body, log the body content
@@ -184,26 +210,26 @@ func (c *Client) makeRequest(ctx context.Context, method, endpoint string, body
 		// Try to read the body content for logging
 		if bytesBuffer, ok := bodyReader.(*bytes.Buffer); ok {
 			bodyContent := bytesBuffer.Bytes()
-			c.logger.Info("HTTP Request Body Content",
+			logger.Debug("HTTP Request Body Content",
 				zap.String("body_content", string(bodyContent)),
 				zap.Int("body_length", len(bodyContent)))
 			
 			// Critical validation: ensure body is not empty
 			if len(bodyContent) == 0 {
-				c.logger.Error("CRITICAL: HTTP request body is empty for POST request!")
+				logger.Error("CRITICAL: HTTP request body is empty for POST request!")
 			}
 		} else {
-			c.logger.Info("Request body is not a bytes.Buffer, cannot log content without consuming it")
+			logger.Debug("Request body is not a bytes.Buffer, cannot log content without consuming it")
 		}
 	} else if method == "POST" && bodyReader == nil {
-		c.logger.Error("CRITICAL: POST request has nil body reader!")
+		logger.Error("CRITICAL: POST request has nil body reader!")
 	}
 
-	c.logger.Info("Making Mistral AI API request")
+	logger.Debug("Making Mistral AI API request")
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		c.logger.Error("Mistral AI request failed",
+		logger.Error("Mistral AI request failed",
 			zap.String("method", method),
 			zap.String("endpoint", endpoint),
 			zap.Error(err))
@@ -211,7 +237,7 @@ func (c *Client) makeRequest(ctx context.Context, method, endpoint string, body
 	}
 
 	// Log response details
-	c.logger.Info("HTTP Response Received",
+	logger.Debug("HTTP Response Received",
 		zap.Int("status_code", resp.StatusCode),
 		zap.String("status", resp.Status))
 
@@ -241,6 +267,7 @@ func (c *Client) ListModels(ctx context.Context) ([]Model, error) {
 
 // ChatCompletion sends a chat completion request to Mistral AI
 func (c *Client) ChatCompletion(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	logger := requestid.Logger(ctx, c.logger)
 	// Set default model if not specified
 	if req.Model == "" {
 		req.Model = c.config.DefaultModel
@@ -257,8 +284,8 @@ func (c *Client) ChatCompletion(ctx context.Context, req ChatRequest) (*ChatResp
 	}
 
 	// Comprehensive debug logging for request analysis
-	c.logger.Info("=== MISTRAL API REQUEST START ===")
-	c.logger.Info("Mistral ChatCompletion request details",
+	logger.Debug("=== MISTRAL API REQUEST START ===")
+	logger.Debug("Mistral ChatCompletion request details",
 		zap.String("model", req.Model),
 		zap.Int("message_count", len(req.Messages)),
 		zap.Bool("has_tools", len(req.Tools) > 0),
@@ -268,7 +295,7 @@ func (c *Client) ChatCompletion(ctx context.Context, req ChatRequest) (*ChatResp
 
 	// Log each message individually for detailed analysis
 	for i, msg := range req.Messages {
-		c.logger.Info("Message analysis",
+		logger.Debug("Message analysis",
 			zap.Int("message_index", i),
 			zap.String("role", msg.Role),
 			zap.String("content_length", fmt.Sprintf("%d", len(msg.Content))),
@@ -277,7 +304,7 @@ func (c *Client) ChatCompletion(ctx context.Context, req ChatRequest) (*ChatResp
 
 	// Log tools if present
 	if len(req.Tools) > 0 {
-		c.logger.Info("Tools included in request", zap.Int("tool_count", len(req.Tools)))
+		logger.Debug("Tools included in request", zap.Int("tool_count", len(req.Tools)))
 	}
 
 	jsonData, err := json.Marshal(req)
@@ -286,37 +313,153 @@ func (c *Client) ChatCompletion(ctx context.Context, req ChatRequest) (*ChatResp
 	}
 
 	// Log the complete JSON payload
-	c.logger.Info("Complete Mistral API request payload",
+	logger.Debug("Complete Mistral API request payload",
 		zap.String("json_length", fmt.Sprintf("%d", len(jsonData))),
 		zap.String("full_json", string(jsonData)))
 
-	// Pass the request struct directly to makeRequest for proper marshaling
-	resp, err := c.makeRequest(ctx, "POST", "/v1/chat/completions", req)
-	if err != nil {
-		return nil, err
+	var notices []string
+	for attempt := 0; ; attempt++ {
+		// Queue behind any back-off requested by an earlier 429
+		if err := c.waitForRateLimit(ctx); err != nil {
+			return nil, err
+		}
+
+		// Pass the request struct directly to makeRequest for proper marshaling
+		resp, err := c.makeRequest(ctx, "POST", "/v1/chat/completions", req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < c.config.MaxRetries {
+			delay := parseRetryAfter(resp.Header.Get("Retry-After"), attempt)
+			resp.Body.Close()
+			c.setRateLimited(delay)
+
+			notice := fmt.Sprintf("Mistral AI rate limited, retrying in %ds (attempt %d of %d)", int(delay.Round(time.Second).Seconds()), attempt+1, c.config.MaxRetries)
+			notices = append(notices, notice)
+			llm.ReportStatus(ctx, notice)
+			logger.Warn("Mistral AI rate limit hit, retrying",
+				zap.Duration("retry_after", delay),
+				zap.Int("attempt", attempt+1),
+				zap.Int("max_retries", c.config.MaxRetries))
+			continue
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			// Models without structured output support reject response_format, retry in text mode
+			if req.ResponseFormat != nil && isResponseFormatRejection(resp.StatusCode, body) {
+				logger.Warn("Mistral AI rejected JSON mode, falling back to text output",
+					zap.String("model", req.Model),
+					zap.String("error", string(body)))
+				c.jsonMode.SetUnsupported(req.Model)
+				req.ResponseFormat = nil
+				continue
+			}
+
+			return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		}
+
+		var chatResp ChatResponse
+		err = json.NewDecoder(resp.Body).Decode(&chatResp)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+
+		chatResp.Notices = notices
+		return &chatResp, nil
 	}
-	defer resp.Body.Close()
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+// isResponseFormatRejection reports whether an error response was caused by response_format
+func isResponseFormatRejection(status int, body []byte) bool {
+	if status != http.StatusBadRequest && status != http.StatusUnprocessableEntity {
+		return false
 	}
+	return strings.Contains(strings.ToLower(string(body)), "response_format")
+}
 
-	var chatResp ChatResponse
-	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+// parseRetryAfter converts a Retry-After header (seconds or HTTP date) into a delay,
+// falling back to exponential back-off when the header is missing or invalid
+func parseRetryAfter(header string, attempt int) time.Duration {
+	delay := time.Duration(1<<uint(attempt)) * time.Second
+	if header != "" {
+		if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+			delay = time.Duration(seconds) * time.Second
+		} else if date, err := http.ParseTime(header); err == nil {
+			delay = time.Until(date)
+		}
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	if delay > maxRetryAfter {
+		delay = maxRetryAfter
+	}
+	return delay
+}
+
+// setRateLimited records a back-off window shared by all requests from this client
+func (c *Client) setRateLimited(delay time.Duration) {
+	c.rateLimitMu.Lock()
+	defer c.rateLimitMu.Unlock()
+	until := time.Now().Add(delay)
+	if until.After(c.rateLimitedUntil) {
+		c.rateLimitedUntil = until
+	}
+}
+
+// waitForRateLimit blocks until the current back-off window has passed or the context is done
+func (c *Client) waitForRateLimit(ctx context.Context) error {
+	c.rateLimitMu.Lock()
+	wait := time.Until(c.rateLimitedUntil)
+	c.rateLimitMu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+		return fmt.Errorf("Mistral AI rate limited for another %ds, which exceeds the request deadline", int(wait.Round(time.Second).Seconds()))
 	}
 
-	return &chatResp, nil
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
 }
 
 // processNaturalLanguageQueryInternal processes a natural language query and returns tool calls (internal implementation)
 func (c *Client) processNaturalLanguageQueryInternal(ctx context.Context, query, model string, tools []Tool, conversationHistory []ChatMessage) (*LLMResponse, error) {
-	c.logger.Info("=== MESSAGE CONSTRUCTION START ===")
+	chatReq, err := c.buildChatRequest(ctx, query, model, tools, conversationHistory)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send request to Mistral AI
+	chatResp, err := c.ChatCompletion(ctx, chatReq)
+	if err != nil {
+		return nil, fmt.Errorf("chat completion failed: %w", err)
+	}
+
+	// Process response and extract tool calls
+	return c.processLLMResponse(chatResp)
+}
+
+// buildChatRequest renders the system prompt, conversation history, query and tools into the request sent to Mistral AI
+func (c *Client) buildChatRequest(ctx context.Context, query, model string, tools []Tool, conversationHistory []ChatMessage) (ChatRequest, error) {
+	c.logger.Debug("=== MESSAGE CONSTRUCTION START ===")
 	
 	// Ensure conversation history is not nil
 	if conversationHistory == nil {
-		c.logger.Info("Nil conversation history detected, converting to empty slice")
+		c.logger.Debug("Nil conversation history detected, converting to empty slice")
 		conversationHistory = []ChatMessage{}
 	}
 
@@ -328,13 +471,17 @@ func (c *Client) processNaturalLanguageQueryInternal(ctx context.Context, query,
 		Role:    "system",
 		Content: c.buildSystemPrompt(),
 	}
+	jsonMode := c.jsonMode.Enabled(c.config.JSONMode, model)
+	if jsonMode {
+		systemMessage.Content += "\n" + llm.JSONModeInstruction
+	}
 	messages = append(messages, systemMessage)
-	c.logger.Info("Added system message", zap.Int("system_content_length", len(systemMessage.Content)))
+	c.logger.Debug("Added system message", zap.Int("system_content_length", len(systemMessage.Content)))
 
 	// Add conversation history
-	c.logger.Info("Adding conversation history", zap.Int("history_message_count", len(conversationHistory)))
+	c.logger.Debug("Adding conversation history", zap.Int("history_message_count", len(conversationHistory)))
 	for i, msg := range conversationHistory {
-		c.logger.Info("History message",
+		c.logger.Debug("History message",
 			zap.Int("history_index", i),
 			zap.String("role", msg.Role),
 			zap.Int("content_length", len(msg.Content)))
@@ -347,12 +494,12 @@ func (c *Client) processNaturalLanguageQueryInternal(ctx context.Context, query,
 		Content: query,
 	}
 	messages = append(messages, userMessage)
-	c.logger.Info("Added user message", zap.String("user_query", query))
+	c.logger.Debug("Added user message", zap.String("user_query", query))
 
 	// Validate message alternation pattern
-	c.logger.Info("Message alternation validation")
+	c.logger.Debug("Message alternation validation")
 	for i, msg := range messages {
-		c.logger.Info("Message validation",
+		c.logger.Debug("Message validation",
 			zap.Int("message_index", i),
 			zap.String("role", msg.Role),
 			zap.Int("content_length", len(msg.Content)))
@@ -361,10 +508,10 @@ func (c *Client) processNaturalLanguageQueryInternal(ctx context.Context, query,
 	// Validate that we have at least the system and user messages
 	if len(messages) < 2 {
 		c.logger.Error("Invalid message construction", zap.Int("actual_message_count", len(messages)))
-		return nil, fmt.Errorf("invalid message construction: expected at least system and user messages, got %d", len(messages))
+		return ChatRequest{}, fmt.Errorf("invalid message construction: expected at least system and user messages, got %d", len(messages))
 	}
 	
-	c.logger.Info("Message construction completed successfully", zap.Int("total_messages", len(messages)))
+	c.logger.Debug("Message construction completed successfully", zap.Int("total_messages", len(messages)))
 
 	// Create chat request
 	chatReq := ChatRequest{
@@ -377,14 +524,16 @@ func (c *Client) processNaturalLanguageQueryInternal(ctx context.Context, query,
 		MaxTokens:   c.config.MaxTokens,
 	}
 
-	// Send request to Mistral AI
-	chatResp, err := c.ChatCompletion(ctx, chatReq)
-	if err != nil {
-		return nil, fmt.Errorf("chat completion failed: %w", err)
+	// Constrain the tool-selection turn to JSON so prose doesn't leak around the answer
+	if jsonMode {
+		chatReq.ResponseFormat = &ResponseFormat{Type: "json_object"}
 	}
 
-	// Process response and extract tool calls
-	return c.processLLMResponse(chatResp)
+	if seed, ok := llm.SeedFrom(ctx); ok {
+		chatReq.RandomSeed = &seed
+	}
+
+	return chatReq, nil
 }
 
 // LLMResponse represents a processed LLM response
@@ -394,6 +543,7 @@ type LLMResponse struct {
 	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
 	Model     string     `json:"model"`
 	Usage     Usage      `json:"usage"`
+	Notices   []string   `json:"notices,omitempty"`
 }
 
 // processLLMResponse processes the raw LLM response and extracts tool calls
@@ -405,16 +555,16 @@ func (c *Client) processLLMResponse(chatResp *ChatResponse) (*LLMResponse, error
 	choice := chatResp.Choices[0]
 	
 	// Debug logging for tool calls
-	c.logger.Info("Mistral API response analysis",
+	c.logger.Debug("Mistral API response analysis",
 		zap.Int("choice_count", len(chatResp.Choices)),
 		zap.String("message_content", choice.Message.Content),
 		zap.Int("message_content_length", len(choice.Message.Content)),
 		zap.Int("tool_calls_count", len(choice.ToolCalls)))
 	
 	if len(choice.ToolCalls) > 0 {
-		c.logger.Info("Tool calls detected in Mistral response")
+		c.logger.Debug("Tool calls detected in Mistral response")
 		for i, toolCall := range choice.ToolCalls {
-			c.logger.Info("Tool call details",
+			c.logger.Debug("Tool call details",
 				zap.Int("tool_call_index", i),
 				zap.String("function_name", toolCall.Function.Name),
 				zap.String("arguments", toolCall.Function.Arguments))
@@ -425,14 +575,21 @@ func (c *Client) processLLMResponse(chatResp *ChatResponse) (*LLMResponse, error
 		Message: choice.Message.Content,
 		Model:   chatResp.Model,
 		Usage:   chatResp.Usage,
+		Notices: chatResp.Notices,
 	}
 
 	// Extract tool calls if present
 	if len(choice.ToolCalls) > 0 {
 		response.ToolCalls = choice.ToolCalls
-		c.logger.Info("Successfully extracted tool calls", zap.Int("count", len(response.ToolCalls)))
+		for i, call := range response.ToolCalls {
+			if call.Args == nil {
+				response.ToolCalls[i].Args = llm.ParseToolArguments(call.Function.Arguments)
+			}
+		}
+		c.logger.Debug("Successfully extracted tool calls", zap.Int("count", len(response.ToolCalls)))
 	} else {
-		c.logger.Info("No tool calls found in Mistral response")
+		c.logger.Debug("No tool calls found in Mistral response")
+		response.Message = llm.UnwrapJSONMessage(response.Message)
 	}
 
 	return response, nil
@@ -446,6 +603,8 @@ When a user asks you to perform an action that requires API access (like listing
 
 If you can perform the action directly, provide the information. If you need to use a tool, you can call the appropriate function.
 
+` + llm.TruncatedResultsPrompt + `
+
 Always be helpful, clear, and provide context for your responses.`
 }
 
@@ -493,8 +652,8 @@ func convertMistralUsage(mistralUsage Usage) llm.Usage {
 // ProcessNaturalLanguageQuery implements the LLMClient interface method
 func (c *Client) ProcessNaturalLanguageQuery(ctx context.Context, query, model string, tools interface{}, conversationHistory interface{}) (*llm.LLMResponse, error) {
 	// Log method entry with parameter details
-	c.logger.Info("=== PROCESS NATURAL LANGUAGE QUERY START ===")
-	c.logger.Info("ProcessNaturalLanguageQuery called",
+	c.logger.Debug("=== PROCESS NATURAL LANGUAGE QUERY START ===")
+	c.logger.Debug("ProcessNaturalLanguageQuery called",
 		zap.String("query", query),
 		zap.String("model", model),
 		zap.String("tools_type", fmt.Sprintf("%T", tools)),
@@ -514,12 +673,12 @@ func (c *Client) ProcessNaturalLanguageQuery(ctx context.Context, query, model s
 	}
 
 	// Log conversation history details
-	c.logger.Info("Conversation history analysis",
+	c.logger.Debug("Conversation history analysis",
 		zap.Int("history_length", len(mistralHistory)),
 		zap.Bool("history_is_nil", mistralHistory == nil))
 
 	// Log tools details
-	c.logger.Info("Tools analysis",
+	c.logger.Debug("Tools analysis",
 		zap.Int("tools_length", len(mistralTools)))
 
 	// Call the actual Mistral implementation
@@ -530,7 +689,7 @@ func (c *Client) ProcessNaturalLanguageQuery(ctx context.Context, query, model s
 	}
 
 	// Log successful response
-	c.logger.Info("ProcessNaturalLanguageQuery completed successfully",
+	c.logger.Debug("ProcessNaturalLanguageQuery completed successfully",
 		zap.String("response_message", mistralResp.Message),
 		zap.Int("tool_calls_count", len(mistralResp.ToolCalls)))
 
@@ -540,9 +699,40 @@ func (c *Client) ProcessNaturalLanguageQuery(ctx context.Context, query, model s
 		ToolCalls: convertMistralToolCalls(mistralResp.ToolCalls),
 		Model:     mistralResp.Model,
 		Usage:     convertMistralUsage(mistralResp.Usage),
+		Notices:   mistralResp.Notices,
 	}, nil
 }
 
+// Complete sends a single system and user message without tools and returns the reply text.
+// It is used for auxiliary tasks such as moderation rather than operator chat.
+func (c *Client) Complete(ctx context.Context, model, system, prompt string) (string, error) {
+	resp, err := c.ChatCompletion(ctx, ChatRequest{
+		Model: model,
+		Messages: []ChatMessage{
+			{Role: "system", Content: system},
+			{Role: "user", Content: prompt},
+		},
+		MaxTokens: c.config.MaxTokens,
+	})
+	if err != nil {
+		return "", fmt.Errorf("completion failed: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("completion returned no choices")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+// RenderPrompt returns the chat request a query would send to Mistral AI, without sending it
+func (c *Client) RenderPrompt(ctx context.Context, query, model string, tools interface{}, conversationHistory interface{}) (interface{}, error) {
+	mistralTools, ok1 := tools.([]Tool)
+	mistralHistory, ok2 := conversationHistory.([]ChatMessage)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("invalid parameter types for Mistral client")
+	}
+	return c.buildChatRequest(ctx, query, model, mistralTools, mistralHistory)
+}
+
 // GetAvailableModels returns the list of configured available models
 func (c *Client) GetAvailableModels() []string {
 	return c.config.Models
@@ -0,0 +1,121 @@
+package mistral
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aviagent/pkg/config"
+	"aviagent/pkg/llm"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// newTestClient returns a client of the Mistral API served by handler
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	client, err := NewClient(&config.MistralConfig{APIBaseURL: server.URL, Timeout: 5, MaxRetries: 2, JSONMode: true}, "key", zap.NewNop())
+	require.NoError(t, err)
+	return client
+}
+
+func TestResponseFormatFallback(t *testing.T) {
+	var formats []*ResponseFormat
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req ChatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		formats = append(formats, req.ResponseFormat)
+		if req.ResponseFormat != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"message": "Invalid response_format for this model"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(ChatResponse{Choices: []Choice{{Message: ChatMessage{Role: "assistant", Content: "ok"}}}})
+	})
+
+	req, err := client.buildChatRequest(context.Background(), "list pools", "open-mistral-7b", nil, nil)
+	require.NoError(t, err)
+	require.NotNil(t, req.ResponseFormat)
+
+	resp, err := client.ChatCompletion(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp.Choices[0].Message.Content)
+	require.Len(t, formats, 2)
+	assert.Equal(t, "json_object", formats[0].Type)
+	assert.Nil(t, formats[1], "the request is retried in text mode")
+
+	// The model is remembered and later requests no longer ask for JSON
+	req, err = client.buildChatRequest(context.Background(), "list pools", "open-mistral-7b", nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, req.ResponseFormat)
+	assert.True(t, client.jsonMode.Enabled(true, "mistral-large"))
+}
+
+func TestIsResponseFormatRejection(t *testing.T) {
+	assert.True(t, isResponseFormatRejection(http.StatusBadRequest, []byte(`{"detail": "response_format is not supported"}`)))
+	assert.True(t, isResponseFormatRejection(http.StatusUnprocessableEntity, []byte(`Response_Format: extra fields not permitted`)))
+	assert.False(t, isResponseFormatRejection(http.StatusBadRequest, []byte(`{"detail": "max_tokens too large"}`)))
+	assert.False(t, isResponseFormatRejection(http.StatusInternalServerError, []byte(`response_format`)))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	assert.Equal(t, 7*time.Second, parseRetryAfter("7", 0))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("0", 3))
+	assert.Equal(t, maxRetryAfter, parseRetryAfter("3600", 0), "long back-offs are capped")
+
+	date := time.Now().Add(20 * time.Second).UTC().Format(http.TimeFormat)
+	assert.InDelta(t, float64(20*time.Second), float64(parseRetryAfter(date, 0)), float64(2*time.Second))
+	past := time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)
+	assert.Equal(t, time.Duration(0), parseRetryAfter(past, 0))
+
+	// Missing or invalid headers back off exponentially
+	assert.Equal(t, time.Second, parseRetryAfter("", 0))
+	assert.Equal(t, 4*time.Second, parseRetryAfter("soon", 2))
+	assert.Equal(t, maxRetryAfter, parseRetryAfter("", 10))
+}
+
+func TestWaitForRateLimit(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {})
+	assert.NoError(t, client.waitForRateLimit(context.Background()))
+
+	client.setRateLimited(30 * time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	err := client.waitForRateLimit(ctx)
+	assert.ErrorContains(t, err, "exceeds the request deadline")
+	assert.Less(t, time.Since(start), 500*time.Millisecond, "a wait past the deadline fails right away")
+
+	client.rateLimitedUntil = time.Now().Add(50 * time.Millisecond)
+	assert.NoError(t, client.waitForRateLimit(context.Background()))
+}
+
+func TestRateLimitRetry(t *testing.T) {
+	requests := 0
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		json.NewEncoder(w).Encode(ChatResponse{Choices: []Choice{{Message: ChatMessage{Role: "assistant", Content: "ok"}}}})
+	})
+
+	var statuses []string
+	ctx := llm.WithStatus(context.Background(), func(status string) {
+		statuses = append(statuses, status)
+	})
+	resp, err := client.ChatCompletion(ctx, ChatRequest{Model: "mistral-small"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, []string{"Mistral AI rate limited, retrying in 0s (attempt 1 of 2)"}, statuses, "the retry is reported while it waits")
+	assert.Equal(t, statuses, resp.Notices)
+}
@@ -38,8 +38,8 @@ check_env_file() {
                     # Extract port from .env or use default
                     LOCAL_PORT=$(grep -E "^SERVER_PORT=" .env | cut -d'=' -f2 || echo "8080")
                     echo "🌐 Access the application at: http://localhost:$LOCAL_PORT"
-                    echo "📊 Health check endpoint: http://localhost:$LOCAL_PORT/api/health"
-                    echo "💬 API endpoint: http://localhost:$LOCAL_PORT/api/chat"
+                    echo "📊 Health check endpoint: http://localhost:$LOCAL_PORT/api/v1/health"
+                    echo "💬 API endpoint: http://localhost:$LOCAL_PORT/api/v1/chat"
                     echo
                     echo "📋 To stop the application, run: docker-compose down"
                     echo "📋 To view logs, run: docker-compose logs -f avi-llm-agent"
@@ -189,8 +189,8 @@ if [ $? -eq 0 ]; then
     echo "✅ Application started successfully!"
     echo
     echo "🌐 Access the application at: http://localhost:$SERVER_PORT"
-    echo "📊 Health check endpoint: http://localhost:$SERVER_PORT/api/health"
-    echo "💬 API endpoint: http://localhost:$SERVER_PORT/api/chat"
+    echo "📊 Health check endpoint: http://localhost:$SERVER_PORT/api/v1/health"
+    echo "💬 API endpoint: http://localhost:$SERVER_PORT/api/v1/chat"
     echo
     echo "📋 To stop the application, run: docker-compose down"
     echo "📋 To view logs, run: docker-compose logs -f avi-llm-agent"
@@ -37,8 +37,8 @@ check_env_file() {
                     # Extract port from .env or use default
                     LOCAL_PORT=$(grep -E "^SERVER_PORT=" .env | cut -d'=' -f2 || echo "8080")
                     echo "🌐 Access the application at: http://localhost:$LOCAL_PORT"
-                    echo "📊 Health check endpoint: http://localhost:$LOCAL_PORT/api/health"
-                    echo "💬 API endpoint: http://localhost:$LOCAL_PORT/api/chat"
+                    echo "📊 Health check endpoint: http://localhost:$LOCAL_PORT/api/v1/health"
+                    echo "💬 API endpoint: http://localhost:$LOCAL_PORT/api/v1/chat"
                     echo
                     echo "🔄 Pulling required LLM models (this may take a while)..."
                     echo "📋 To pull models, run: docker-compose exec ollama ollama pull llama3.2"
@@ -197,8 +197,8 @@ if [ $? -eq 0 ]; then
     echo "✅ Application started successfully!"
     echo
     echo "🌐 Access the application at: http://localhost:$SERVER_PORT"
-    echo "📊 Health check endpoint: http://localhost:$SERVER_PORT/api/health"
-    echo "💬 API endpoint: http://localhost:$SERVER_PORT/api/chat"
+    echo "📊 Health check endpoint: http://localhost:$SERVER_PORT/api/v1/health"
+    echo "💬 API endpoint: http://localhost:$SERVER_PORT/api/v1/chat"
     echo
     echo "🔄 Pulling required LLM models (this may take a while)..."
     echo "📋 To pull models, run: docker-compose exec ollama ollama pull llama3.2"
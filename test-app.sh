@@ -58,7 +58,7 @@ echo
 # Build the application
 echo "🔨 Building application..."
 export PATH=$PATH:/usr/local/go/bin
-go build -o aviagent-test .
+go build -o aviagent-test ./cmd/server
 
 if [ $? -ne 0 ]; then
     echo "❌ Failed to build application"
@@ -81,7 +81,7 @@ sleep 3
 
 # Test the health endpoint
 echo "📊 Testing health endpoint..."
-HEALTH_RESPONSE=$(curl -s http://localhost:$SERVER_PORT/api/health)
+HEALTH_RESPONSE=$(curl -s http://localhost:$SERVER_PORT/api/v1/health)
 
 if [ $? -eq 0 ]; then
     echo "✅ Health endpoint responded:"
@@ -96,7 +96,7 @@ echo
 # Test a simple chat request if health check passed
 if [ -n "$HEALTH_RESPONSE" ]; then
     echo "💬 Testing chat endpoint..."
-    CHAT_RESPONSE=$(curl -s -X POST http://localhost:$SERVER_PORT/api/chat \
+    CHAT_RESPONSE=$(curl -s -X POST http://localhost:$SERVER_PORT/api/v1/chat \
         -H "Content-Type: application/json" \
         -d '{"message": "What is the current time?", "model": "mistral-medium"}')
     
@@ -7,7 +7,7 @@ echo "Testing Avi LLM Agent with chat message..."
 
 # Send a chat request using curl
 # This simulates the "show all virtual service" request
-curl -X POST "http://localhost:8080/api/chat" \
+curl -X POST "http://localhost:8080/api/v1/chat" \
   -H "Content-Type: application/json" \
   -d '{"message": "show all virtual service", "model": "mistral-medium"}'
 
@@ -66,7 +66,7 @@ function saveDarkModePreference(isDarkMode) {
 
 // Function to display version information
 function displayVersionInfo() {
-    fetch('/api/health')
+    fetch('/api/v1/health')
         .then(response => response.json())
         .then(data => {
             if (data.version) {
@@ -88,12 +88,14 @@ document.addEventListener('DOMContentLoaded', function() {
     
     // Display version information
     displayVersionInfo();
+
+    // Message actions and keyboard shortcuts
+    initializeMessageActions();
+    initializeKeyboardShortcuts();
     
     // Get DOM elements once
     const messageInput = document.getElementById('message-input');
     const chatForm = document.getElementById('chat-form');
-    const clearChatButton = document.getElementById('clear-chat');
-    const exportChatButton = document.getElementById('export-chat');
     
     // Auto-focus on message input
     if (messageInput) {
@@ -201,8 +203,166 @@ document.addEventListener('DOMContentLoaded', function() {
     }
 });
 
+// Message actions: copy an answer as JSON or open a ticket with it. Handled by delegation so
+// answers added by HTMX and loaded from history get them too.
+function initializeMessageActions() {
+    document.addEventListener('click', function(e) {
+        const button = e.target.closest('[data-action]');
+        if (!button) return;
+        const message = button.closest('.message');
+        if (!message) return;
+
+        if (button.dataset.action === 'copy-json') {
+            e.preventDefault();
+            copyMessageAsJSON(message, button);
+        } else if (button.dataset.action === 'create-ticket') {
+            e.preventDefault();
+            createTicket(message);
+        }
+    });
+}
+
+function messageToJSON(message) {
+    const content = message.querySelector('.message-content').cloneNode(true);
+    content.querySelectorAll('.tool-calls').forEach(el => el.remove());
+    const model = message.querySelector('.message-header .badge');
+    const timestamp = message.querySelector('.timestamp');
+
+    const toolCalls = [];
+    message.querySelectorAll('[data-tool-call]').forEach(function(el) {
+        try {
+            toolCalls.push(JSON.parse(el.dataset.toolCall));
+        } catch (err) {
+            console.warn('Failed to parse tool call:', err);
+        }
+    });
+
+    return {
+        role: message.classList.contains('user-message') ? 'user' : 'assistant',
+        timestamp: timestamp ? timestamp.textContent.trim() : '',
+        model: model ? model.textContent.trim() : '',
+        content: content.textContent.trim(),
+        tool_calls: toolCalls
+    };
+}
+
+function copyMessageAsJSON(message, button) {
+    const json = JSON.stringify(messageToJSON(message), null, 2);
+    navigator.clipboard.writeText(json)
+        .then(function() {
+            if (!button) return;
+            const icon = button.innerHTML;
+            button.innerHTML = '<i class="fas fa-check"></i>';
+            setTimeout(function() { button.innerHTML = icon; }, 1500);
+        })
+        .catch(function(error) {
+            console.warn('Failed to copy message:', error);
+        });
+}
+
+// createTicket opens the configured issue tracker URL with the answer and the question it replied to
+function createTicket(message) {
+    const template = document.body.dataset.ticketUrl;
+    if (!template) {
+        alert('No ticket URL is configured (server.ticket_url).');
+        return;
+    }
+
+    let question = message.previousElementSibling;
+    while (question && !question.classList.contains('user-message')) {
+        question = question.previousElementSibling;
+    }
+    const data = messageToJSON(message);
+    let title = question ? question.querySelector('.message-content').textContent.trim() : 'Avi agent finding';
+    if (title.length > 80) {
+        title = title.substring(0, 77) + '...';
+    }
+    let description = data.content;
+    if (data.tool_calls.length > 0) {
+        description += '\n\nTool calls: ' + data.tool_calls.map(call => call.function.name).join(', ');
+    }
+    if (description.length > 1500) {
+        description = description.substring(0, 1497) + '...';
+    }
+
+    const url = template
+        .replace('{title}', encodeURIComponent(title))
+        .replace('{description}', encodeURIComponent(description));
+    window.open(url, '_blank', 'noopener');
+}
+
+// lastAnswer returns the newest assistant message of the conversation, excluding the welcome message
+function lastAnswer() {
+    const answers = document.querySelectorAll('#chat-messages > .assistant-message');
+    return answers.length > 0 ? answers[answers.length - 1] : null;
+}
+
+function initializeKeyboardShortcuts() {
+    const help = document.getElementById('shortcut-help');
+    const toggleHelp = function() {
+        if (help) help.classList.toggle('d-none');
+    };
+
+    const showShortcutsButton = document.getElementById('show-shortcuts');
+    if (showShortcutsButton) {
+        showShortcutsButton.addEventListener('click', toggleHelp);
+    }
+
+    document.addEventListener('keydown', function(e) {
+        const target = e.target;
+        const typing = target.tagName === 'INPUT' || target.tagName === 'TEXTAREA' || target.tagName === 'SELECT' || target.isContentEditable;
+
+        if (e.key === 'Escape') {
+            if (help) help.classList.add('d-none');
+            if (typing) target.blur();
+            return;
+        }
+
+        if (e.altKey && !e.ctrlKey && !e.metaKey) {
+            // e.code, as Alt changes e.key on some keyboard layouts
+            let handled = true;
+            switch (e.code) {
+                case 'KeyC': {
+                    const answer = lastAnswer();
+                    if (answer) copyMessageAsJSON(answer, answer.querySelector('[data-action="copy-json"]'));
+                    break;
+                }
+                case 'KeyR': {
+                    const buttons = document.querySelectorAll('#chat-messages .rerun-tool');
+                    if (buttons.length > 0) buttons[buttons.length - 1].click();
+                    break;
+                }
+                case 'KeyT': {
+                    const answer = lastAnswer();
+                    if (answer) createTicket(answer);
+                    break;
+                }
+                case 'KeyL': {
+                    const clearButton = document.getElementById('clear-chat');
+                    if (clearButton) clearButton.click();
+                    break;
+                }
+                default:
+                    handled = false;
+            }
+            if (handled) e.preventDefault();
+            return;
+        }
+
+        if (typing || e.ctrlKey || e.metaKey) return;
+        if (e.key === '/') {
+            e.preventDefault();
+            const messageInput = document.getElementById('message-input');
+            if (messageInput) messageInput.focus();
+        } else if (e.key === '?') {
+            e.preventDefault();
+            toggleHelp();
+        }
+    });
+}
+
 function checkConnectionStatus() {
-    fetch('/api/health')
+    fetch('/api/v1/health')
         .then(response => response.json())
         .then(data => {
             const indicator = document.getElementById('connection-indicator');
@@ -0,0 +1,10 @@
+// Package web holds the templates and static assets of the web UI, embedded into the binary so
+// it runs from any working directory
+package web
+
+import "embed"
+
+// Assets holds the templates/ and static/ directories
+//
+//go:embed templates static
+var Assets embed.FS